@@ -20,6 +20,7 @@ import (
 type CliConfig struct {
 	Debug          bool
 	Quiet          bool
+	Format         string
 	URL            string
 	OneShot        bool
 	Removable      bool
@@ -27,15 +28,20 @@ type CliConfig struct {
 	Secure         bool
 	SecureMethod   string
 	SecureOptions  map[string]interface{}
+	Encrypt        bool
 	Archive        bool
+	Tree           bool
 	ArchiveMethod  string
 	ArchiveOptions map[string]interface{}
 	DownloadBinary string
 	Comments       string
 	Login          string
 	Password       string
+	SharePassword  bool
 	TTL            int
 	ExtendTTL      bool
+	Public         bool
+	DataBackend    string
 	AutoUpdate     bool
 	Token          string
 	DisableStdin   bool
@@ -43,8 +49,15 @@ type CliConfig struct {
 
 	filePaths        []string
 	filenameOverride string
+	serve            bool
+	deleteOnExit     bool
+	rotateToken      bool
+	gracePeriod      int
 }
 
+// serveTTL is the default TTL of the uploads shared with plik serve
+const serveTTL = 86400
+
 // NewUploadConfig construct a new configuration with default values
 func NewUploadConfig() (config *CliConfig) {
 	config = new(CliConfig)
@@ -229,6 +242,21 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		config.Quiet = true
 	}
 
+	// Output format
+	if opts["--format"] != nil && opts["--format"].(string) != "" {
+		config.Format = opts["--format"].(string)
+	}
+	if config.Format != "" {
+		switch config.Format {
+		case "url", "markdown", "html", "json":
+		default:
+			return fmt.Errorf("Invalid output format %s, expected url|markdown|html|json", config.Format)
+		}
+
+		// Only the formatted file urls are displayed
+		config.Quiet = true
+	}
+
 	// Plik server url
 	if opts["--server"] != nil && opts["--server"].(string) != "" {
 		config.URL = opts["--server"].(string)
@@ -254,6 +282,35 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		}
 	}
 
+	// Ad-hoc share of a single file with sensible defaults, the command line options still apply
+	if opts["serve"].(bool) {
+		if len(config.filePaths) != 1 || config.Archive {
+			return fmt.Errorf("plik serve shares a single file")
+		}
+		config.serve = true
+		config.OneShot = false
+		config.Stream = false
+		config.TTL = serveTTL
+		config.deleteOnExit = opts["--delete-on-exit"].(bool)
+	} else if opts["--delete-on-exit"].(bool) {
+		return fmt.Errorf("--delete-on-exit can only be used with plik serve")
+	}
+
+	// Replace the token by a new one
+	if opts["token"].(bool) {
+		config.rotateToken = true
+	}
+	if opts["--grace"] != nil && opts["--grace"].(string) != "" {
+		if !config.rotateToken {
+			return fmt.Errorf("--grace can only be used with plik token rotate")
+		}
+		grace, err := parseDuration(opts["--grace"].(string))
+		if err != nil {
+			return fmt.Errorf("Invalid grace period %s", opts["--grace"].(string))
+		}
+		config.gracePeriod = grace
+	}
+
 	// Override file name if specified
 	if opts["--name"] != nil && opts["--name"].(string) != "" {
 		config.filenameOverride = opts["--name"].(string)
@@ -277,29 +334,25 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 
 	// Configure upload expire date
 	if opts["--ttl"] != nil && opts["--ttl"].(string) != "" {
-		ttlStr := opts["--ttl"].(string)
-		mul := 1
-		if string(ttlStr[len(ttlStr)-1]) == "m" {
-			mul = 60
-		} else if string(ttlStr[len(ttlStr)-1]) == "h" {
-			mul = 3600
-		} else if string(ttlStr[len(ttlStr)-1]) == "d" {
-			mul = 86400
-		}
-		if mul != 1 {
-			ttlStr = ttlStr[:len(ttlStr)-1]
-		}
-		ttl, err := strconv.Atoi(ttlStr)
+		ttl, err := parseDuration(opts["--ttl"].(string))
 		if err != nil {
 			return fmt.Errorf("Invalid TTL %s", opts["--ttl"].(string))
 		}
-		config.TTL = ttl * mul
+		config.TTL = ttl
 	}
 
 	if opts["--extend-ttl"].(bool) {
 		config.ExtendTTL = true
 	}
 
+	if opts["--public"].(bool) {
+		config.Public = true
+	}
+
+	if opts["--backend"] != nil && opts["--backend"].(string) != "" {
+		config.DataBackend = opts["--backend"].(string)
+	}
+
 	// Enable archive mode ?
 	if opts["-a"].(bool) || opts["--archive"] != nil || config.Archive {
 		config.Archive = true
@@ -309,6 +362,15 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		}
 	}
 
+	// Upload directories as a tree of files instead of an archive
+	if opts["--tree"].(bool) {
+		if opts["-a"].(bool) || opts["--archive"] != nil {
+			return fmt.Errorf("--tree can't be used with archive mode")
+		}
+		config.Tree = true
+		config.Archive = false
+	}
+
 	// Enable secure mode ?
 	if opts["--not-secure"].(bool) {
 		config.Secure = false
@@ -319,6 +381,14 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		}
 	}
 
+	// Enable client side encryption ?
+	if opts["--encrypt"].(bool) {
+		config.Encrypt = true
+	}
+	if config.Encrypt && config.Stream {
+		return fmt.Errorf("Client side encryption is not compatible with streaming")
+	}
+
 	// Enable password protection ?
 	if opts["-p"].(bool) {
 		fmt.Printf("Login [plik]: ")
@@ -350,6 +420,11 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 		config.Password = password
 	}
 
+	// Embed the password in the upload url ?
+	if opts["--share-password"].(bool) {
+		config.SharePassword = true
+	}
+
 	// Override upload token ?
 	if opts["--token"] != nil && opts["--token"].(string) != "" {
 		config.Token = opts["--token"].(string)
@@ -371,3 +446,29 @@ func (config *CliConfig) UnmarshalArgs(opts docopt.Opts) (err error) {
 
 	return
 }
+
+// parseDuration convert a duration with an optional m|h|d unit to seconds
+func parseDuration(str string) (seconds int, err error) {
+	if str == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	mul := 1
+	if string(str[len(str)-1]) == "m" {
+		mul = 60
+	} else if string(str[len(str)-1]) == "h" {
+		mul = 3600
+	} else if string(str[len(str)-1]) == "d" {
+		mul = 86400
+	}
+	if mul != 1 {
+		str = str[:len(str)-1]
+	}
+
+	seconds, err = strconv.Atoi(str)
+	if err != nil {
+		return 0, err
+	}
+
+	return seconds * mul, nil
+}
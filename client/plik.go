@@ -2,15 +2,24 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/docopt/docopt-go"
+	"github.com/dustin/go-humanize"
 	"github.com/olekukonko/ts"
 	"github.com/root-gg/utils"
 
@@ -25,9 +34,13 @@ var arguments map[string]interface{}
 var config *CliConfig
 var archiveBackend archive.Backend
 var cryptoBackend crypto.Backend
+var encryptionKey []byte
 
 var err error
 
+// batchMaxFileSize is the maximum size of the files sent together in a single request
+const batchMaxFileSize = 1024 * 1024
+
 // Main
 func main() {
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -38,6 +51,9 @@ func main() {
 	usage := `plik
 
 Usage:
+  plik [options] info UPLOAD_ID
+  plik [options] token rotate
+  plik [options] serve FILE
   plik [options] [FILE] ...
 
 Options:
@@ -46,6 +62,8 @@ Options:
   -S, --stream              Enable Streaming ( It will block until remote user starts downloading )
   -t, --ttl TTL             Time before expiration (Upload will be removed in m|h|d)
   --extend-ttl              Extend upload expiration date by TTL when accessed
+  --public                  Allow anonymous downloads if the server requires authentication to download
+  --backend NAME            Store the files in this server data backend ( if selectable )
   -n, --name NAME           Set file name when piping from STDIN
   --stdin                   Enable pipe from stdin explicitly when DisableStdin is set in .plikrc
   --server SERVER           Overrides server url
@@ -53,10 +71,12 @@ Options:
   --comments COMMENT        Set comments of the upload ( MarkDown compatible )
   -p                        Protect the upload with login and password ( be prompted )
   --password PASSWD         Protect the upload with "login:password" ( if omitted default login is "plik" )
+  --share-password          Embed the login and password in the upload url fragment ( never sent to the server )
   -a                        Archive upload using default archive params ( see ~/.plikrc )
   --archive MODE            Archive upload using the specified archive backend : tar|zip
   --compress MODE           [tar] Compression codec : gzip|bzip2|xz|lzip|lzma|lzop|compress|no
   --archive-options OPTIONS [tar|zip] Additional command line options
+  --tree                    Upload the files of directories one by one keeping their relative path instead of archiving them
   -s                        Encrypt upload using the default encryption parameters ( see ~/.plikrc )
   --not-secure              Do not encrypt upload files regardless of the ~/.plikrc configurations
   --secure MODE             Encrypt upload files using the specified crypto backend : openssl|pgp
@@ -64,9 +84,14 @@ Options:
   --passphrase PASSPHRASE   [openssl] Passphrase or '-' to be prompted for a passphrase
   --recipient RECIPIENT     [pgp] Set recipient for pgp backend ( example : --recipient Bob )
   --secure-options OPTIONS  [openssl|pgp] Additional command line options
+  --encrypt                 Encrypt upload files client side, the key is only shared in the file urls fragment
+  --decrypt URL             Download and decrypt to STDOUT a file uploaded with --encrypt
+  --delete-on-exit          [serve] Keep running and delete the upload on Ctrl-C
+  --grace GRACE             [token rotate] Keep the former token valid for this duration (in m|h|d)
   --insecure                (TLS) Do not verify the server's certificate chain and hostname
   --update                  Update client
   -q --quiet                Enable quiet mode
+  --format FORMAT           Only output file urls formatted as : url|markdown|html|json
   -d --debug                Enable debug mode
   -v --version              Show client version
   -i --info                 Show client and server information
@@ -120,6 +145,44 @@ Options:
 		os.Exit(0)
 	}
 
+	// Display the files of an upload
+	if arguments["info"].(bool) {
+		client.Login = config.Login
+		client.Password = config.Password
+
+		err = uploadInfo(client, arguments["UPLOAD_ID"].(string))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Replace the token by a new one
+	if config.rotateToken {
+		client.Token = config.Token
+
+		err = rotateToken(client, config.gracePeriod)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Download and decrypt a file
+	if arguments["--decrypt"] != nil {
+		client.Login = config.Login
+		client.Password = config.Password
+
+		err = decrypt(client, arguments["--decrypt"].(string))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Update
 	updateFlag := arguments["--update"].(bool)
 	err = update(client, updateFlag)
@@ -159,6 +222,8 @@ Options:
 	upload.Stream = config.Stream
 	upload.OneShot = config.OneShot
 	upload.Removable = config.Removable
+	upload.Public = config.Public
+	upload.DataBackend = config.DataBackend
 	upload.Comments = config.Comments
 	upload.Login = config.Login
 	upload.Password = config.Password
@@ -168,7 +233,12 @@ Options:
 			fmt.Fprintf(os.Stderr, "Stdin is disabled by default. Use the --stdin flag to override\n")
 			os.Exit(1)
 		}
-		upload.AddFileFromReader("STDIN", bufio.NewReader(os.Stdin))
+		// Let the server name the file if it has a DefaultFilename
+		name := "STDIN"
+		if serverConfig, err := client.GetServerConfig(); err == nil && serverConfig.DefaultFilename != "" {
+			name = ""
+		}
+		upload.AddFileFromReader(name, bufio.NewReader(os.Stdin))
 	} else {
 		if config.Archive {
 			archiveBackend, err = archive.NewArchiveBackend(config.ArchiveMethod, config.ArchiveOptions)
@@ -193,7 +263,11 @@ Options:
 			upload.AddFileFromReader(filename, reader)
 		} else {
 			for _, path := range config.filePaths {
-				_, err := upload.AddFileFromPath(path)
+				if config.Tree {
+					err = addFileTree(upload, path)
+				} else {
+					_, err = upload.AddFileFromPath(path)
+				}
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "%s : %s\n", path, err)
 					os.Exit(1)
@@ -210,6 +284,13 @@ Options:
 		upload.Files()[0].Name = config.filenameOverride
 	}
 
+	// Send the small files together in a single request if the server allows it
+	if !config.Stream && len(upload.Files()) > 1 {
+		if serverConfig, err := client.GetServerConfig(); err == nil && !serverConfig.IsFeatureDisabled(common.DisableableMultiFileUpload) {
+			client.BatchMaxFileSize = batchMaxFileSize
+		}
+	}
+
 	// Initialize crypto backend
 	if config.Secure {
 		cryptoBackend, err = crypto.NewCryptoBackend(config.SecureMethod, config.SecureOptions)
@@ -224,6 +305,15 @@ Options:
 		}
 	}
 
+	// Generate the client side encryption key, it is only shared in the file urls fragment
+	if config.Encrypt {
+		encryptionKey, err = plik.GenerateEncryptionKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Initialize progress bar display
 	var progress *Progress
 	if !config.Quiet && !config.Debug {
@@ -243,11 +333,31 @@ Options:
 			})
 		}
 
+		if config.Encrypt {
+			err = file.Encrypt(encryptionKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to encrypt file : %s\n", err)
+				os.Exit(1)
+			}
+		}
+
 		if !config.Quiet && !config.Debug {
 			progress.register(file)
 		}
 	}
 
+	// Check that the upload would be accepted before sending any data
+	precheck, err := upload.Precheck()
+	if err != nil {
+		// Servers older than the precheck API will create the upload anyway
+		if config.Debug {
+			fmt.Fprintf(os.Stderr, "Unable to precheck upload : %s\n", err)
+		}
+	} else if !precheck.Accepted {
+		fmt.Fprintf(os.Stderr, "Upload would be rejected by the server : %s\n", precheck.Reason)
+		os.Exit(1)
+	}
+
 	// Create upload on server
 	err = upload.Create()
 	if err != nil {
@@ -261,7 +371,12 @@ Options:
 	// Display upload url
 	printf("Upload successfully created at %s : \n", creationDate)
 
-	uploadURL, err := upload.GetURL()
+	var uploadURL *url.URL
+	if config.SharePassword && upload.Login != "" && upload.Password != "" {
+		uploadURL, err = upload.GetPasswordURL()
+	} else {
+		uploadURL, err = upload.GetURL()
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to get upload url %s\n", err)
 		os.Exit(1)
@@ -271,6 +386,14 @@ Options:
 
 	if config.Stream && !config.Debug {
 		for _, file := range upload.Files() {
+			if config.Format != "" {
+				output, err := getFileOutput(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to get url for file %s : %s\n", file.Name, err)
+				}
+				fmt.Println(output)
+				continue
+			}
 			cmd, err := getFileCommand(file)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Unable to get download command for file %s : %s\n", file.Name, err)
@@ -301,8 +424,14 @@ Options:
 			if file.Error() != nil {
 				continue
 			}
-			if config.Quiet {
-				URL, err := file.GetURL()
+			if config.Format != "" {
+				output, err := getFileOutput(file)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to get url for file %s : %s\n", file.Name, err)
+				}
+				fmt.Println(output)
+			} else if config.Quiet {
+				URL, err := getFileURL(file)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Unable to get download command for file %s : %s\n", file.Name, err)
 				}
@@ -318,6 +447,55 @@ Options:
 	} else {
 		printf("\n")
 	}
+
+	// Keep sharing until interrupted then delete the upload
+	if config.deleteOnExit {
+		printf("\nPress Ctrl-C to stop sharing and delete the upload\n")
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		err = upload.Delete()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to delete upload : %s\n", err)
+			os.Exit(1)
+		}
+		printf("\nUpload deleted\n")
+	}
+}
+
+// addFileTree add the regular files of a directory to the upload with their path relative to the directory parent
+// so the folder tree is rebuilt when the upload is downloaded as an archive
+func addFileTree(upload *plik.Upload, root string) (err error) {
+	parent := filepath.Dir(filepath.Clean(root))
+
+	return filepath.Walk(root, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories, symlinks, devices, ...
+		if !fileInfo.Mode().IsRegular() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(parent, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		file, err := upload.AddFileFromPath(path)
+		if err != nil {
+			return err
+		}
+
+		if relativePath != "." {
+			file.RelativePath = filepath.ToSlash(relativePath)
+		}
+
+		return nil
+	})
 }
 
 func info(client *plik.Client) (err error) {
@@ -343,21 +521,69 @@ func info(client *plik.Client) (err error) {
 	return nil
 }
 
+// rotateToken replace the client token by a new one and print it
+func rotateToken(client *plik.Client, gracePeriod int) (err error) {
+	token, err := client.RotateToken(gracePeriod)
+	if err != nil {
+		return fmt.Errorf("Unable to rotate token : %s", err)
+	}
+
+	if token.Comment != "" {
+		printf("Token %q rotated, update your ~/.plikrc with the new token :\n", token.Comment)
+	} else {
+		printf("Token rotated, update your ~/.plikrc with the new token :\n")
+	}
+	fmt.Println(token.Token)
+
+	if gracePeriod > 0 {
+		printf("The former token remains valid for %s\n", time.Duration(gracePeriod)*time.Second)
+	}
+
+	return nil
+}
+
+// uploadInfo print the files of an upload as a table
+func uploadInfo(client *plik.Client, uploadID string) (err error) {
+	upload, err := client.GetUpload(uploadID)
+	if err != nil {
+		return fmt.Errorf("Unable to get upload : %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSIZE\tTYPE\tMD5\tSTATUS\tDOWNLOADS")
+	for _, file := range upload.Files() {
+		metadata := file.Metadata()
+
+		md5 := metadata.Md5
+		if md5 == "" {
+			md5 = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", metadata.ID, metadata.Name, humanize.Bytes(uint64(metadata.Size)),
+			metadata.Type, md5, metadata.Status, metadata.DownloadCount)
+	}
+
+	return w.Flush()
+}
+
 func getFileCommand(file *plik.File) (command string, err error) {
+	URL, err := getFileURL(file)
+	if err != nil {
+		return "", err
+	}
+
 	// Step one - Downloading file
-	switch config.DownloadBinary {
-	case "wget":
+	switch {
+	case config.Encrypt:
+		command += "plik --decrypt"
+	case config.DownloadBinary == "wget":
 		command += "wget -q -O-"
-	case "curl":
+	case config.DownloadBinary == "curl":
 		command += "curl -s"
 	default:
 		command += config.DownloadBinary
 	}
 
-	URL, err := file.GetURL()
-	if err != nil {
-		return "", err
-	}
 	command += fmt.Sprintf(` "%s"`, URL)
 
 	// If Ssl
@@ -379,6 +605,102 @@ func getFileCommand(file *plik.File) (command string, err error) {
 	return
 }
 
+// getFileURL return the file url with the encryption key in the fragment if the file is encrypted
+func getFileURL(file *plik.File) (URL *url.URL, err error) {
+	URL, err = file.GetURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Encrypt {
+		URL.Fragment = plik.EncodeEncryptionKey(encryptionKey)
+	}
+
+	return URL, nil
+}
+
+// getFileOutput format the file url as requested by the --format option
+func getFileOutput(file *plik.File) (output string, err error) {
+	URL, err := getFileURL(file)
+	if err != nil {
+		return "", err
+	}
+
+	switch config.Format {
+	case "markdown":
+		name := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(file.Name)
+		return fmt.Sprintf("[%s](%s)", name, URL), nil
+	case "html":
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(URL.String()), html.EscapeString(file.Name)), nil
+	case "json":
+		bytes, err := json.Marshal(&struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		}{file.Name, URL.String()})
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	default:
+		return URL.String(), nil
+	}
+}
+
+// decrypt download a file uploaded with --encrypt and print its content to stdout
+// the file url must contain the encryption key in its fragment
+func decrypt(client *plik.Client, fileURL string) (err error) {
+	URL, err := url.Parse(fileURL)
+	if err != nil {
+		return fmt.Errorf("Invalid file url : %s", err)
+	}
+
+	key, err := plik.DecodeEncryptionKey(URL.Fragment)
+	if err != nil {
+		return fmt.Errorf("Missing or invalid encryption key in the file url")
+	}
+
+	// File urls look like SERVER/file/UPLOAD_ID/FILE_ID/FILE_NAME
+	parts := strings.Split(URL.Path, "/")
+	if len(parts) < 4 || parts[len(parts)-4] != "file" {
+		return fmt.Errorf("Invalid file url %s", fileURL)
+	}
+	uploadID := parts[len(parts)-3]
+	fileID := parts[len(parts)-2]
+
+	// Use the server the file has been uploaded to
+	URL.Path = strings.Join(parts[:len(parts)-4], "/")
+	URL.RawPath = ""
+	URL.RawQuery = ""
+	URL.Fragment = ""
+	client.URL = URL.String()
+
+	upload, err := client.GetUpload(uploadID)
+	if err != nil {
+		return fmt.Errorf("Unable to get upload : %s", err)
+	}
+
+	for _, file := range upload.Files() {
+		if file.Metadata().ID != fileID {
+			continue
+		}
+
+		reader, err := file.DownloadDecrypted(key)
+		if err != nil {
+			return fmt.Errorf("Unable to download file : %s", err)
+		}
+		defer func() { _ = reader.Close() }()
+
+		_, err = io.Copy(os.Stdout, reader)
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt file : %s", err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("File %s not found", fileID)
+}
+
 func printf(format string, args ...interface{}) {
 	if !config.Quiet {
 		fmt.Printf(format, args...)
@@ -454,6 +454,51 @@ echo "OK"
 
 #---------------------------------------------
 
+echo -n " - format : "
+
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --format url
+test $(cat $CLIENT_LOG | wc -l) -eq 1
+grep "^$URL/file/.*/.*/FILE1$" $CLIENT_LOG >/dev/null 2>/dev/null
+
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --format markdown
+test $(cat $CLIENT_LOG | wc -l) -eq 1
+grep "^\[FILE1\]($URL/file/.*/.*/FILE1)$" $CLIENT_LOG >/dev/null 2>/dev/null
+
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --format html
+test $(cat $CLIENT_LOG | wc -l) -eq 1
+grep "^<a href=\"$URL/file/.*/.*/FILE1\">FILE1</a>$" $CLIENT_LOG >/dev/null 2>/dev/null
+
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+cp $SPECIMEN $TMPDIR/upload/FILE2
+upload --format json
+test $(cat $CLIENT_LOG | wc -l) -eq 2
+grep '^{"name":"FILE1","url":".*/FILE1"}$' $CLIENT_LOG >/dev/null 2>/dev/null
+grep '^{"name":"FILE2","url":".*/FILE2"}$' $CLIENT_LOG >/dev/null 2>/dev/null
+
+echo "OK"
+
+#---------------------------------------------
+
+echo -n " - encrypt : "
+
+before
+cp $SPECIMEN $TMPDIR/upload/FILE1
+upload --encrypt
+# the key must only be in the url fragment
+grep '^plik --decrypt "'$URL'/file/.*/.*/FILE1#.*"' $CLIENT_LOG >/dev/null 2>/dev/null
+COMMAND=$(grep '^plik --decrypt ' $CLIENT_LOG | sed "s#^plik #$CLIENT #")
+cd $TMPDIR/download && eval "$COMMAND" && check
+echo "OK"
+
+#---------------------------------------------
+
 echo -n " - not secure : "
 
 SECURE="true"
@@ -567,6 +612,22 @@ echo "OK"
 
 #---------------------------------------------
 
+echo -n " - tree directory : "
+before
+mkdir -p $TMPDIR/upload/DIR/SUBDIR
+cp $SPECIMEN $TMPDIR/upload/DIR/FILE1
+cp $SPECIMEN $TMPDIR/upload/DIR/SUBDIR/FILE2
+upload --tree && uploadOpts
+# Download the folder tree as a zip archive
+cd $TMPDIR/download
+curl -s "$URL/archive/$UPLOAD_ID/archive.zip" > archive.zip
+unzip archive.zip >/dev/null 2>/dev/null
+rm archive.zip
+check
+echo "OK"
+
+#---------------------------------------------
+
 echo -n " - zip custom options : "
 before
 cp $SPECIMEN $TMPDIR/upload/FILE1
@@ -1,8 +1,10 @@
 package plik
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -23,6 +25,10 @@ type Client struct {
 	ClientUserAgent string // User-Agent HTTP Header setting
 
 	HTTPClient *http.Client // HTTP Client ot use to make the requests
+
+	// Files up to that size in bytes are sent together in a single request by Upload() ( 0 : One request per file ).
+	// The server must support multiple files uploads ( multi_file_upload feature )
+	BatchMaxFileSize int64
 }
 
 // NewClient creates a new Plik Client
@@ -169,6 +175,61 @@ func (c *Client) GetUploadProtectedByPassword(id string, login string, password
 	return upload, nil
 }
 
+// GetUploadWithToken fetch upload metadata from the server using the upload token
+// This allows to add files to an upload created elsewhere
+func (c *Client) GetUploadWithToken(id string, uploadToken string) (upload *Upload, err error) {
+	uploadParams := c.NewUpload().getParams()
+	uploadParams.ID = id
+	uploadParams.UploadToken = uploadToken
+
+	return c.getUploadWithParams(uploadParams)
+}
+
+// RotateToken replace the client token by a new one with the same user, comment and allowed origins.
+// The former token stays valid for gracePeriod seconds ( 0 : it is invalidated immediately ).
+// The client token is updated to the new token.
+func (c *Client) RotateToken(gracePeriod int) (token *common.Token, err error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing token")
+	}
+
+	params, err := json.Marshal(&struct {
+		GracePeriod int `json:"gracePeriod"`
+	}{GracePeriod: gracePeriod})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.URL+"/me/token/"+c.Token+"/rotate", bytes.NewBuffer(params))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-PlikToken", c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	token = &common.Token{}
+	err = json.Unmarshal(body, token)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Token = token.Token
+
+	return token, nil
+}
+
 // NewHTTPClient Create a new HTTP client with ProxyFromEnvironment and InsecureSkipVerify setup
 func NewHTTPClient(insecure bool) *http.Client {
 	return &http.Client{
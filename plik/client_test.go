@@ -1,6 +1,7 @@
 package plik
 
 import (
+	"archive/zip"
 	"bytes"
 	"fmt"
 	"io/ioutil"
@@ -444,6 +445,34 @@ func TestDownloadArchive(t *testing.T) {
 	require.NotEmpty(t, content, "empty archive")
 }
 
+func TestDownloadArchiveRelativePath(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("filename", bytes.NewBufferString("data"))
+	file.RelativePath = "project/src"
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, "project/src", file.Metadata().RelativePath, "invalid file relative path")
+
+	reader, err := upload.DownloadZipArchive()
+	require.NoError(t, err, "unable to download archive")
+
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read archive")
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err, "unable to read archive")
+	require.Len(t, archive.File, 1, "invalid archive file count")
+	require.Equal(t, "project/src/filename", archive.File[0].Name, "invalid archived file path")
+}
+
 func TestGetArchiveNotFound(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
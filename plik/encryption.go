@@ -0,0 +1,280 @@
+package plik
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+/*
+  Client side encryption design :
+    - Each file is encrypted with a random data key using AES-256-GCM in chunks of 64KiB
+      - Each chunk nonce is made of a random file nonce prefix, the chunk counter and a last chunk flag
+        so chunks can't be reordered and the file can't be truncated without being noticed
+    - The data key is wrapped with AES-256-GCM using the encryption key
+    - Only the file nonce prefix and the wrapped data key are sent to the server along with the file metadata
+    - The encryption key never leaves the client, it has to be shared with the recipients by other means
+      ( like the URL fragment which is never sent to the server by browsers and HTTP clients )
+*/
+
+const encryptionKeySize = 32
+const encryptionNoncePrefixSize = 7
+const encryptionChunkSize = 64 * 1024
+
+// GenerateEncryptionKey generate a random key to encrypt files with
+func GenerateEncryptionKey() (key []byte, err error) {
+	key = make([]byte, encryptionKeySize)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate encryption key : %s", err)
+	}
+	return key, nil
+}
+
+// EncodeEncryptionKey encode the encryption key to be safely embedded in an URL
+func EncodeEncryptionKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// DecodeEncryptionKey decode an encryption key encoded by EncodeEncryptionKey
+func DecodeEncryptionKey(str string) (key []byte, err error) {
+	key, err = base64.RawURLEncoding.DecodeString(str)
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("invalid encryption key")
+	}
+	return key, nil
+}
+
+// EncryptedSize return the size of the file once encrypted
+func EncryptedSize(size int64) int64 {
+	chunks := (size + encryptionChunkSize - 1) / encryptionChunkSize
+	if chunks == 0 {
+		// Empty files still have an authenticated last chunk
+		chunks = 1
+	}
+	return size + chunks*16
+}
+
+// Encrypt the file content with the encryption key while uploading it.
+// It must be called before the upload is created for the server to save the encryption details.
+func (file *File) Encrypt(key []byte) (err error) {
+	dataKey := make([]byte, encryptionKeySize)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return fmt.Errorf("unable to generate data key : %s", err)
+	}
+
+	prefix := make([]byte, encryptionNoncePrefixSize)
+	_, err = rand.Read(prefix)
+	if err != nil {
+		return fmt.Errorf("unable to generate nonce : %s", err)
+	}
+
+	wrappedKey, err := wrapKey(key, dataKey)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	file.EncryptionScheme = common.EncryptionSchemeAES256GCM
+	file.EncryptionNonce = base64.StdEncoding.EncodeToString(prefix)
+	file.WrappedKey = base64.StdEncoding.EncodeToString(wrappedKey)
+
+	if file.Size > 0 {
+		file.Size = EncryptedSize(file.Size)
+	}
+
+	file.WrapReader(func(reader io.ReadCloser) io.ReadCloser {
+		return newChunkReader(reader, encryptionChunkSize, func(dst []byte, chunk []byte, counter uint32, last bool) ([]byte, error) {
+			return aead.Seal(dst, chunkNonce(prefix, counter, last), chunk, nil), nil
+		})
+	})
+
+	return nil
+}
+
+// DownloadDecrypted downloads the file and decrypt it with the encryption key
+func (file *File) DownloadDecrypted(key []byte) (reader io.ReadCloser, err error) {
+	body, err := file.Download()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err = newDecryptReader(file.getParams(), key, body)
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// newDecryptReader decrypt the file content read from reader using the file encryption details
+func newDecryptReader(params *common.File, key []byte, reader io.ReadCloser) (io.ReadCloser, error) {
+	if params.EncryptionScheme != common.EncryptionSchemeAES256GCM {
+		return nil, fmt.Errorf("unsupported file encryption scheme \"%s\"", params.EncryptionScheme)
+	}
+
+	prefix, err := base64.StdEncoding.DecodeString(params.EncryptionNonce)
+	if err != nil || len(prefix) != encryptionNoncePrefixSize {
+		return nil, fmt.Errorf("invalid file encryption nonce")
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(params.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file wrapped key")
+	}
+
+	dataKey, err := unwrapKey(key, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newChunkReader(reader, encryptionChunkSize+aead.Overhead(), func(dst []byte, chunk []byte, counter uint32, last bool) ([]byte, error) {
+		plaintext, err := aead.Open(dst, chunkNonce(prefix, counter, last), chunk, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt file : %s", err)
+		}
+		return plaintext, nil
+	}), nil
+}
+
+func newAEAD(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key : %s", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey encrypt the data key with the encryption key, the random nonce is prepended to the result
+func wrapKey(key []byte, dataKey []byte) (wrappedKey []byte, err error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate nonce : %s", err)
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(common.EncryptionSchemeAES256GCM)), nil
+}
+
+// unwrapKey decrypt a data key wrapped by wrapKey
+func unwrapKey(key []byte, wrappedKey []byte) (dataKey []byte, err error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid file wrapped key")
+	}
+
+	nonce := wrappedKey[:aead.NonceSize()]
+	dataKey, err = aead.Open(nil, nonce, wrappedKey[aead.NonceSize():], []byte(common.EncryptionSchemeAES256GCM))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap file key, invalid encryption key ?")
+	}
+
+	return dataKey, nil
+}
+
+// chunkNonce return the nonce of a chunk : prefix ( 7 bytes ) || counter ( 4 bytes ) || last chunk flag ( 1 byte )
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptionNoncePrefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// chunkReader split the source in chunks of a fixed size and process them one by one
+type chunkReader struct {
+	source  *bufio.Reader
+	closer  io.Closer
+	process func(dst []byte, chunk []byte, counter uint32, last bool) ([]byte, error)
+
+	chunk   []byte // Current input chunk
+	buffer  []byte // Processed chunk storage
+	output  []byte // Processed bytes not read yet
+	counter uint32
+	done    bool
+}
+
+func newChunkReader(source io.ReadCloser, size int, process func(dst []byte, chunk []byte, counter uint32, last bool) ([]byte, error)) *chunkReader {
+	return &chunkReader{
+		source:  bufio.NewReader(source),
+		closer:  source,
+		process: process,
+		chunk:   make([]byte, size),
+	}
+}
+
+// Read implements io.Reader
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	for len(r.output) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(r.source, r.chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		// The last chunk is the first one not followed by any data
+		last := n < len(r.chunk)
+		if !last {
+			_, err = r.source.Peek(1)
+			if err == io.EOF {
+				last = true
+			} else if err != nil {
+				return 0, err
+			}
+		}
+
+		if r.counter == ^uint32(0) {
+			return 0, fmt.Errorf("file is too big to be encrypted")
+		}
+
+		r.buffer, err = r.process(r.buffer[:0], r.chunk[:n], r.counter, last)
+		if err != nil {
+			return 0, err
+		}
+
+		r.output = r.buffer
+		r.counter++
+		r.done = last
+	}
+
+	n = copy(p, r.output)
+	r.output = r.output[n:]
+	return n, nil
+}
+
+// Close implements io.Closer
+func (r *chunkReader) Close() error {
+	return r.closer.Close()
+}
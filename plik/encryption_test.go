@@ -0,0 +1,109 @@
+package plik
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func encryptForTests(t *testing.T, key []byte, data []byte) (file *File, ciphertext []byte) {
+	file = newFileFromReader(nil, "filename", bytes.NewReader(data))
+	file.Size = int64(len(data))
+
+	err := file.Encrypt(key)
+	require.NoError(t, err, "unable to encrypt file")
+
+	ciphertext, err = ioutil.ReadAll(file.reader)
+	require.NoError(t, err, "unable to read encrypted file")
+
+	return file, ciphertext
+}
+
+func TestEncryptionKey(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+	require.Len(t, key, encryptionKeySize, "invalid key size")
+
+	decoded, err := DecodeEncryptionKey(EncodeEncryptionKey(key))
+	require.NoError(t, err, "unable to decode key")
+	require.Equal(t, key, decoded, "invalid decoded key")
+
+	_, err = DecodeEncryptionKey("foo")
+	common.RequireError(t, err, "invalid encryption key")
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+
+	for _, size := range []int{0, 1, encryptionChunkSize - 1, encryptionChunkSize, encryptionChunkSize + 1, 3*encryptionChunkSize + 42} {
+		data := make([]byte, size)
+		_, err = rand.Read(data)
+		require.NoError(t, err, "unable to generate data")
+
+		file, ciphertext := encryptForTests(t, key, data)
+		require.Equal(t, common.EncryptionSchemeAES256GCM, file.EncryptionScheme, "invalid encryption scheme")
+		require.Equal(t, EncryptedSize(int64(size)), int64(len(ciphertext)), "invalid encrypted size for %d bytes", size)
+		if size > 0 {
+			require.Equal(t, file.Size, int64(len(ciphertext)), "invalid file size for %d bytes", size)
+		}
+
+		reader, err := newDecryptReader(file.getParams(), key, ioutil.NopCloser(bytes.NewReader(ciphertext)))
+		require.NoError(t, err, "unable to create decrypt reader")
+
+		plaintext, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to decrypt %d bytes", size)
+		require.Equal(t, data, plaintext, "invalid decrypted data for %d bytes", size)
+	}
+}
+
+func TestDecryptInvalidKey(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+
+	file, ciphertext := encryptForTests(t, key, []byte("data data data"))
+
+	otherKey, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+
+	_, err = newDecryptReader(file.getParams(), otherKey, ioutil.NopCloser(bytes.NewReader(ciphertext)))
+	common.RequireError(t, err, "unable to unwrap file key")
+}
+
+func TestDecryptNotEncrypted(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+
+	_, err = newDecryptReader(&common.File{}, key, ioutil.NopCloser(&bytes.Buffer{}))
+	common.RequireError(t, err, "unsupported file encryption scheme")
+}
+
+func TestDecryptTampered(t *testing.T) {
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate key")
+
+	data := make([]byte, 2*encryptionChunkSize+42)
+	file, ciphertext := encryptForTests(t, key, data)
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[42] ^= 1
+
+	truncated := ciphertext[:encryptionChunkSize+16]
+
+	reordered := append([]byte{}, ciphertext[encryptionChunkSize+16:2*(encryptionChunkSize+16)]...)
+	reordered = append(reordered, ciphertext[:encryptionChunkSize+16]...)
+	reordered = append(reordered, ciphertext[2*(encryptionChunkSize+16):]...)
+
+	for _, invalid := range [][]byte{tampered, truncated, reordered, {}} {
+		reader, err := newDecryptReader(file.getParams(), key, ioutil.NopCloser(bytes.NewReader(invalid)))
+		require.NoError(t, err, "unable to create decrypt reader")
+
+		_, err = ioutil.ReadAll(reader)
+		common.RequireError(t, err, "unable to decrypt file")
+	}
+}
@@ -17,6 +17,14 @@ type File struct {
 	Name string
 	Size int64
 
+	RelativePath string // Folder of the file in the uploaded tree, rebuilt when downloading the upload as an archive
+	TTL          int    // Expire the file before its upload, must be set before the upload is created
+
+	// Client side encryption details ( see Encrypt )
+	EncryptionScheme string
+	EncryptionNonce  string
+	WrappedKey       string
+
 	reader io.ReadCloser // Byte stream to upload
 	upload *Upload       // Link to upload and client
 
@@ -77,6 +85,11 @@ func newFileFromParams(upload *Upload, params *common.File) *File {
 	file.metadata = params
 	file.Name = params.Name
 	file.Size = params.Size
+	file.RelativePath = params.RelativePath
+	file.TTL = params.TTL
+	file.EncryptionScheme = params.EncryptionScheme
+	file.EncryptionNonce = params.EncryptionNonce
+	file.WrappedKey = params.WrappedKey
 	return file
 }
 
@@ -95,6 +108,11 @@ func (file *File) getParams() (params *common.File) {
 
 	params = &common.File{}
 	params.Name = file.Name
+	params.RelativePath = file.RelativePath
+	params.TTL = file.TTL
+	params.EncryptionScheme = file.EncryptionScheme
+	params.EncryptionNonce = file.EncryptionNonce
+	params.WrappedKey = file.WrappedKey
 
 	if file.metadata != nil {
 		params.ID = file.metadata.ID
@@ -165,10 +183,20 @@ func (file *File) Upload() (err error) {
 	defer func() { _ = file.reader.Close() }()
 	fileMetadata, err := file.upload.client.uploadFile(file.upload.getParams(), file.getParams(), file.reader)
 
+	file.uploaded(done, fileMetadata, err)
+
+	return err
+}
+
+// uploaded update the file with the API call result, notify that the upload is done and execute the registered callback
+func (file *File) uploaded(done chan struct{}, fileMetadata *common.File, err error) {
 	// update file with API call result
 	file.lock.Lock()
 	if err == nil {
 		file.metadata = fileMetadata
+		if file.Name == "" {
+			file.Name = fileMetadata.Name // Named by the server from its DefaultFilename
+		}
 	} else {
 		file.err = err
 	}
@@ -182,8 +210,6 @@ func (file *File) Upload() (err error) {
 	if callback != nil {
 		callback(fileMetadata, err)
 	}
-
-	return err
 }
 
 // GetURL returns the URL to download the file
@@ -54,3 +54,42 @@ func TestNotUploadedGetFileURL(t *testing.T) {
 	_, err = file.GetURL()
 	common.RequireError(t, err, "file has not been uploaded yet")
 }
+
+func TestUploadDefaultFilename(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer ps.ShutdownNow()
+
+	ps.GetConfig().DefaultFilename = "upload-{fileId}.txt"
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload, file, err := pc.UploadReader("", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, "upload-"+file.Metadata().ID+".txt", file.Name, "invalid file name")
+	require.Equal(t, file.Name, file.Metadata().Name, "invalid file metadata name")
+
+	fileURL, err := file.GetURL()
+	require.NoError(t, err, "unable to get file URL")
+
+	resp, err := pc.HTTPClient.Get(fileURL.String())
+	require.NoError(t, err, "unable to execute request")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid response status code")
+	require.Contains(t, resp.Header.Get("Content-Disposition"), file.Name, "invalid content disposition")
+
+	require.Len(t, upload.Files(), 1, "invalid files count")
+}
+
+func TestUploadRequireChecksum(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer ps.ShutdownNow()
+
+	ps.GetConfig().RequireUploadChecksum = true
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	_, file, err := pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file")
+	require.Equal(t, common.FileUploaded, file.Metadata().Status, "invalid file status")
+}
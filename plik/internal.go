@@ -2,6 +2,7 @@ package plik
 
 import (
 	"bytes"
+	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -62,6 +63,46 @@ func (c *Client) create(uploadParams *common.Upload) (uploadMetadata *common.Upl
 	return uploadMetadata, nil
 }
 
+// precheck asks the Plik Server whether the upload would be accepted without creating it
+func (c *Client) precheck(uploadParams *common.Upload) (precheck *common.UploadPrecheck, err error) {
+	if uploadParams == nil {
+		return nil, errors.New("missing upload params")
+	}
+
+	var j []byte
+	j, err = json.Marshal(uploadParams)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.UploadRequest(uploadParams, "POST", c.URL+"/upload/precheck", bytes.NewBuffer(j))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	precheck = &common.UploadPrecheck{}
+	err = json.Unmarshal(body, precheck)
+	if err != nil {
+		return nil, err
+	}
+
+	return precheck, nil
+}
+
 // UploadFile uploads a data stream to the Plik Server and return the file metadata
 func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, reader io.Reader) (fileInfo *common.File, err error) {
 	pipeReader, pipeWriter := io.Pipe()
@@ -81,8 +122,18 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 			return
 		}
 
-		_, err = io.Copy(writer, reader)
+		// The md5sum is sent after the file so the server can check the integrity of the data it received
+		md5Hash := md5.New()
+		_, err = io.Copy(writer, io.TeeReader(reader, md5Hash))
+		if err != nil {
+			_ = pipeWriter.CloseWithError(err)
+			errCh <- err
+			return
+		}
+
+		err = multipartWriter.WriteField("md5", fmt.Sprintf("%x", md5Hash.Sum(nil)))
 		if err != nil {
+			err = fmt.Errorf("unable to write md5 form field : %s", err)
 			_ = pipeWriter.CloseWithError(err)
 			errCh <- err
 			return
@@ -156,6 +207,95 @@ func (c *Client) uploadFile(upload *common.Upload, fileParams *common.File, read
 	return fileInfo, nil
 }
 
+// uploadFiles uploads several data streams to the Plik Server in a single request and return the files metadata
+func (c *Client) uploadFiles(upload *common.Upload, filesParams []*common.File, readers []io.Reader) (filesInfo []*common.File, err error) {
+	if upload == nil || len(filesParams) == 0 || len(filesParams) != len(readers) {
+		return nil, errors.New("missing files upload parameter")
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	multipartWriter := multipart.NewWriter(pipeWriter)
+
+	errCh := make(chan error)
+	go func(errCh chan error) {
+		for i, fileParams := range filesParams {
+			writer, err := multipartWriter.CreateFormFile("file", fileParams.Name)
+			if err != nil {
+				err = fmt.Errorf("unable to create multipartWriter : %s", err)
+				_ = pipeWriter.CloseWithError(err)
+				errCh <- err
+				return
+			}
+
+			// The md5sum is sent after each file so the server can check the integrity of the data it received
+			md5Hash := md5.New()
+			_, err = io.Copy(writer, io.TeeReader(readers[i], md5Hash))
+			if err != nil {
+				_ = pipeWriter.CloseWithError(err)
+				errCh <- err
+				return
+			}
+
+			err = multipartWriter.WriteField("md5", fmt.Sprintf("%x", md5Hash.Sum(nil)))
+			if err != nil {
+				err = fmt.Errorf("unable to write md5 form field : %s", err)
+				_ = pipeWriter.CloseWithError(err)
+				errCh <- err
+				return
+			}
+		}
+
+		err := multipartWriter.Close()
+		if err != nil {
+			err = fmt.Errorf("unable to close multipartWriter : %s", err)
+			errCh <- err
+			return
+		}
+
+		_ = pipeWriter.CloseWithError(err)
+		errCh <- err
+	}(errCh)
+
+	req, err := c.UploadRequest(upload, "POST", c.URL+"/upload/"+upload.ID+"/files", pipeReader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+
+	resp, err := c.MakeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = <-errCh
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse json response
+	err = json.Unmarshal(body, &filesInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(filesInfo) != len(filesParams) {
+		return nil, fmt.Errorf("expected %d files but got %d", len(filesParams), len(filesInfo))
+	}
+
+	if c.Debug {
+		fmt.Printf("Files uploaded : %s\n", utils.Sdump(filesInfo))
+	}
+
+	return filesInfo, nil
+}
+
 // UploadRequest creates a new HTTP request with the header generated from the given upload params
 func (c *Client) UploadRequest(upload *common.Upload, method, URL string, body io.Reader) (req *http.Request, err error) {
 	req, err = http.NewRequest(method, URL, body)
@@ -171,6 +311,10 @@ func (c *Client) UploadRequest(upload *common.Upload, method, URL string, body i
 		req.Header.Set("X-UploadToken", upload.UploadToken)
 	}
 
+	if upload.ManagementPassword != "" {
+		req.Header.Set("X-ManagementPassword", upload.ManagementPassword)
+	}
+
 	if upload.Login != "" && upload.Password != "" {
 		// The Authorization header will contain the base64 version of "login:password"
 		header := common.EncodeAuthBasicHeader(upload.Login, upload.Password)
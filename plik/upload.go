@@ -1,6 +1,7 @@
 package plik
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/url"
@@ -21,10 +22,33 @@ type UploadParams struct {
 	ExtendTTL bool   // Extend upload expiration date by TTL when accessed
 	Comments  string // Arbitrary comment to attach to the upload ( the web interface support markdown language )
 
+	TTLFromCompletion bool // Count the TTL from the end of the last file upload instead of the upload creation
+	InactivityTTL     int  // Time in second without download before automatic deletion, replaces TTL if set
+
 	Token string // Authentication token to link an upload to a Plik user
 
 	Login    string // HttpBasic protection for the upload
 	Password string // Login and Password
+
+	ManagementPassword string // Password required to manage (add/remove files, remove) the upload
+
+	Public bool // Allow anonymous downloads if the server requires authentication to download
+
+	DataBackend string // Name of the server data backend to store the files in ( must be selectable )
+
+	DownloadDomain string // Download domain to pin the upload to ( must be configured on the server )
+
+	MaxTotalDownloadBytes int64 // Disable downloads once that many bytes have been served ( 0 means unlimited )
+
+	Preset string // Name of the server upload preset, OneShot and TTL must match the preset settings if they are locked
+
+	AllowedCountries string // Comma separated country codes the files can be downloaded from ( needs a server GeoIP database )
+	BlockedCountries string // Comma separated country codes the files can't be downloaded from
+
+	ExpandArchives bool // Extract the zip / tar archives as separate files ( must be enabled on the server )
+	KeepArchives   bool // Keep the archives once expanded
+
+	UserMetadata map[string]string // Opaque key/value pairs to correlate the upload with your own records
 }
 
 // Upload store the necessary data to upload files to a Plik server
@@ -59,7 +83,15 @@ func newUploadFromMetadata(client *Client, uploadMetadata *common.Upload) (uploa
 	upload.Removable = uploadMetadata.Removable
 	upload.TTL = uploadMetadata.TTL
 	upload.ExtendTTL = uploadMetadata.ExtendTTL
+	upload.TTLFromCompletion = uploadMetadata.TTLFromCompletion
+	upload.InactivityTTL = uploadMetadata.InactivityTTL
 	upload.Comments = uploadMetadata.Comments
+	upload.Public = uploadMetadata.Public
+	upload.DataBackend = uploadMetadata.DataBackend
+	upload.DownloadDomain = uploadMetadata.DownloadDomain
+	upload.MaxTotalDownloadBytes = uploadMetadata.MaxTotalDownloadBytes
+	upload.Preset = uploadMetadata.Preset
+	upload.UserMetadata = uploadMetadata.UserMetadata
 	upload.metadata = uploadMetadata
 
 	// Generate files
@@ -124,10 +156,23 @@ func (upload *Upload) getParams() (params *common.Upload) {
 	params.Removable = upload.Removable
 	params.TTL = upload.TTL
 	params.ExtendTTL = upload.ExtendTTL
+	params.TTLFromCompletion = upload.TTLFromCompletion
+	params.InactivityTTL = upload.InactivityTTL
 	params.Comments = upload.Comments
 	params.Token = upload.Token
 	params.Login = upload.Login
 	params.Password = upload.Password
+	params.ManagementPassword = upload.ManagementPassword
+	params.Public = upload.Public
+	params.DataBackend = upload.DataBackend
+	params.DownloadDomain = upload.DownloadDomain
+	params.MaxTotalDownloadBytes = upload.MaxTotalDownloadBytes
+	params.Preset = upload.Preset
+	params.AllowedCountries = upload.AllowedCountries
+	params.BlockedCountries = upload.BlockedCountries
+	params.ExpandArchives = upload.ExpandArchives
+	params.KeepArchives = upload.KeepArchives
+	params.UserMetadata = upload.UserMetadata
 
 	if upload.metadata != nil {
 		params.ID = upload.metadata.ID
@@ -221,6 +266,19 @@ func (upload *Upload) Create() (err error) {
 	return err
 }
 
+// Precheck asks the Plik Server whether the upload would be accepted given the declared file sizes
+// without creating anything, so that large uploads can fail fast
+func (upload *Upload) Precheck() (precheck *common.UploadPrecheck, err error) {
+	uploadParams := upload.getParams()
+
+	// Declare the file sizes if known
+	for i, file := range upload.Files() {
+		uploadParams.Files[i].Size = file.Size
+	}
+
+	return upload.client.precheck(uploadParams)
+}
+
 // update the upload and files metadata with the result from the Create() API call
 func (upload *Upload) updateUpload(uploadMetadata *common.Upload) (err error) {
 	upload.metadata = uploadMetadata
@@ -234,6 +292,9 @@ LOOP:
 			if file.Reference == reference {
 				f.lock.Lock()
 				f.metadata = file // Update the file metadata
+				if f.Name == "" {
+					f.Name = file.Name // Named by the server from its DefaultFilename
+				}
 				f.lock.Unlock()
 				continue LOOP
 			}
@@ -257,9 +318,20 @@ func (upload *Upload) Upload() (err error) {
 	}
 
 	files := upload.Files()
-	errors := make(chan error, len(files))
+	errors := make(chan error, len(files)+1)
+
+	// Small files are sent together in a single request
+	batch, files := upload.splitBatch(files)
 
 	var wg sync.WaitGroup
+	if len(batch) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errors <- upload.uploadBatch(batch)
+		}()
+	}
+
 	for _, file := range files {
 		wg.Add(1)
 		go func(file *File) {
@@ -290,6 +362,69 @@ func (upload *Upload) Upload() (err error) {
 	return nil
 }
 
+// splitBatch return the files small enough to be sent together in a single request and the other files
+func (upload *Upload) splitBatch(files []*File) (batch []*File, others []*File) {
+	maxFileSize := upload.client.BatchMaxFileSize
+	if maxFileSize <= 0 || upload.Stream {
+		return nil, files
+	}
+
+	for _, file := range files {
+		if file.Name != "" && file.Size > 0 && file.Size <= maxFileSize {
+			batch = append(batch, file)
+		} else {
+			others = append(others, file)
+		}
+	}
+
+	// Not worth an extra code path
+	if len(batch) == 1 {
+		return nil, files
+	}
+
+	return batch, others
+}
+
+// uploadBatch uploads several files in a single request, files already uploaded or being uploaded are skipped
+func (upload *Upload) uploadBatch(files []*File) (err error) {
+	var batch []*File
+	var dones []chan struct{}
+	for _, file := range files {
+		done, abort := file.ready()
+		if abort {
+			continue
+		}
+		batch = append(batch, file)
+		dones = append(dones, done)
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	params := make([]*common.File, len(batch))
+	readers := make([]io.Reader, len(batch))
+	for i, file := range batch {
+		params[i] = file.getParams()
+		readers[i] = file.reader
+	}
+
+	// Upload files to the server
+	filesMetadata, err := upload.client.uploadFiles(upload.getParams(), params, readers)
+
+	for i, file := range batch {
+		_ = file.reader.Close()
+
+		var fileMetadata *common.File
+		if err == nil {
+			fileMetadata = filesMetadata[i]
+		}
+		file.uploaded(dones[i], fileMetadata, err)
+	}
+
+	return err
+}
+
 // GetURL returns the URL page of the upload
 func (upload *Upload) GetURL() (u *url.URL, err error) {
 
@@ -319,6 +454,27 @@ func (upload *Upload) GetAdminURL() (u *url.URL, err error) {
 	return url.Parse(fileURL)
 }
 
+// GetPasswordURL return the URL page of the upload with the upload login and password
+// encoded in the URL fragment. Browsers never send the fragment to the server,
+// the web UI reads it to authenticate the download requests.
+func (upload *Upload) GetPasswordURL() (u *url.URL, err error) {
+	// Get upload metadata
+	uploadMetadata := upload.Metadata()
+	if uploadMetadata == nil || uploadMetadata.ID == "" {
+		return nil, fmt.Errorf("upload has not been created yet")
+	}
+
+	if upload.Login == "" || upload.Password == "" {
+		return nil, fmt.Errorf("upload is not protected by a password")
+	}
+
+	credentials := base64.RawURLEncoding.EncodeToString([]byte(upload.Login + ":" + upload.Password))
+	fileURL := fmt.Sprintf("%s/#/?id=%s&pw=%s", upload.client.URL, uploadMetadata.ID, credentials)
+
+	// Parse to get a nice escaped url
+	return url.Parse(fileURL)
+}
+
 // DownloadZipArchive downloads all the upload files in a zip archive
 func (upload *Upload) DownloadZipArchive() (reader io.ReadCloser, err error) {
 	return upload.client.downloadArchive(upload.getParams())
@@ -1,6 +1,7 @@
 package plik
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -48,3 +49,69 @@ func TestGetUploadAdminURL(t *testing.T) {
 	require.NoError(t, err, "unable to get upload URL")
 	require.Equal(t, fmt.Sprintf("%s/#/?id=%s&uploadToken=%s", pc.URL, upload.ID(), upload.Metadata().UploadToken), uploadURL.String(), "invalid upload URL")
 }
+
+func TestGetUploadPasswordURL(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+
+	_, err = upload.GetPasswordURL()
+	common.RequireError(t, err, "upload has not been created yet")
+
+	err = upload.Create()
+	require.NoError(t, err, "unable to create upload")
+
+	_, err = upload.GetPasswordURL()
+	common.RequireError(t, err, "upload is not protected by a password")
+
+	upload = pc.NewUpload()
+	upload.Login = "foo"
+	upload.Password = "bar?>"
+
+	err = upload.Create()
+	require.NoError(t, err, "unable to create upload")
+
+	uploadURL, err := upload.GetPasswordURL()
+	require.NoError(t, err, "unable to get upload URL")
+	require.Equal(t, fmt.Sprintf("%s/#/?id=%s&pw=Zm9vOmJhcj8-", pc.URL, upload.ID()), uploadURL.String(), "invalid upload URL")
+	require.Empty(t, uploadURL.RawQuery, "credentials should only be in the URL fragment")
+}
+
+func TestUploadBatch(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer ps.ShutdownNow()
+
+	ps.GetConfig().RequireUploadChecksum = true
+	pc.BatchMaxFileSize = 10
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	for _, name := range []string{"a.txt", "b.txt", "a.txt", "big.txt"} {
+		data := "data " + name
+		if name == "big.txt" {
+			data = "more than ten bytes"
+		}
+		file := upload.AddFileFromReader(name, bytes.NewBufferString(data))
+		file.Size = int64(len(data))
+	}
+
+	batch, others := upload.splitBatch(upload.Files())
+	require.Len(t, batch, 3, "invalid batch")
+	require.Len(t, others, 1, "invalid other files")
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+
+	for _, file := range upload.Files() {
+		require.Equal(t, common.FileUploaded, file.Metadata().Status, "invalid file status")
+		require.Equal(t, file.Size, file.Metadata().Size, "invalid file size")
+	}
+
+	require.NotEqual(t, upload.Files()[0].Metadata().ID, upload.Files()[2].Metadata().ID, "files with the same name must not be mixed up")
+}
@@ -213,6 +213,51 @@ func TestUploadWithoutUploadToken(t *testing.T) {
 	require.Contains(t, err.Error(), "you are not allowed to add file to this upload", "invalid error")
 }
 
+func TestAddFileWithUploadToken(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().MaxFilePerUpload = 2
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	err = upload.Create()
+	require.NoError(t, err, "unable to create upload")
+
+	id := upload.ID()
+	uploadToken := upload.Metadata().UploadToken
+
+	// Add files from another client knowing only the upload id and upload token
+	otherClient := NewClient(pc.URL)
+	upload, err = otherClient.GetUploadWithToken(id, uploadToken)
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, upload.Metadata().IsAdmin, "upload token should grant upload admin")
+
+	upload.AddFileFromReader("file1", bytes.NewBufferString("data"))
+	upload.AddFileFromReader("file2", bytes.NewBufferString("data"))
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload files")
+
+	upload, err = pc.GetUpload(id)
+	require.NoError(t, err, "unable to get upload")
+	require.Len(t, upload.Files(), 2, "invalid file count")
+
+	// Upload limits still apply
+	upload, err = otherClient.GetUploadWithToken(id, uploadToken)
+	require.NoError(t, err, "unable to get upload")
+
+	file := upload.AddFileFromReader("file3", bytes.NewBufferString("data"))
+	err = file.Upload()
+	common.RequireError(t, err, "maximum number file per upload reached")
+
+	// An invalid upload token does not grant anything
+	upload, err = otherClient.GetUploadWithToken(id, "invalid")
+	require.NoError(t, err, "unable to get upload")
+	require.False(t, upload.Metadata().IsAdmin, "invalid upload token should not grant upload admin")
+}
+
 func TestStream(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -288,6 +333,23 @@ func TestTTL(t *testing.T) {
 	require.Contains(t, err.Error(), "has expired", "upload has not been created")
 }
 
+func TestUserMetadata(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	upload.UserMetadata = map[string]string{"ticket": "PLIK-42"}
+	err = upload.Create()
+	require.NoError(t, err, "unable to create upload")
+
+	uploadResult, err := pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, upload.UserMetadata, uploadResult.UserMetadata, "invalid user metadata")
+}
+
 func TestQuickUpload(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	ps.GetConfig().DownloadDomain = fmt.Sprintf("http://127.0.0.1:%d", ps.GetConfig().ListenPort)
@@ -362,3 +424,47 @@ func TestCreateUploadWithForbidenOptions(t *testing.T) {
 	require.NotEqual(t, uploadToCreate.UploadToken, upload.Metadata().UploadToken, "invalid upload download domain")
 	require.NotEqual(t, uploadToCreate.CreatedAt, upload.Metadata().CreatedAt, "invalid upload download domain")
 }
+
+func TestEncryptedUpload(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	key, err := GenerateEncryptionKey()
+	require.NoError(t, err, "unable to generate encryption key")
+
+	data := "data data data"
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("filename", bytes.NewBufferString(data))
+	err = file.Encrypt(key)
+	require.NoError(t, err, "unable to encrypt file")
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload file")
+
+	// The server only knows the ciphertext
+	reader, err := file.Download()
+	require.NoError(t, err, "unable to download file")
+	ciphertext, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, EncryptedSize(int64(len(data))), int64(len(ciphertext)), "invalid encrypted file size")
+	require.NotContains(t, string(ciphertext), data, "file is not encrypted")
+
+	// A recipient with the key fetch the encryption details from the server
+	upload, err = pc.GetUpload(upload.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.Len(t, upload.Files(), 1, "invalid file count")
+
+	file = upload.Files()[0]
+	require.Equal(t, common.EncryptionSchemeAES256GCM, file.Metadata().EncryptionScheme, "invalid encryption scheme")
+	require.NotEmpty(t, file.Metadata().EncryptionNonce, "missing encryption nonce")
+	require.NotEmpty(t, file.Metadata().WrappedKey, "missing wrapped key")
+
+	reader, err = file.DownloadDecrypted(key)
+	require.NoError(t, err, "unable to download file")
+	plaintext, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to decrypt file")
+	require.Equal(t, data, string(plaintext), "invalid file content")
+}
@@ -48,6 +48,32 @@ func TestMaxFileSize(t *testing.T) {
 	require.Contains(t, file.Error().Error(), "file too big", "invalid error message")
 }
 
+func TestMaxFileSizePrecheck(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().MaxFileSize = 10
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start plik server")
+
+	upload := pc.NewUpload()
+	file := upload.AddFileFromReader("filename", bytes.NewBufferString("data"))
+	file.Size = 4
+
+	precheck, err := upload.Precheck()
+	require.NoError(t, err, "unable to precheck upload")
+	require.True(t, precheck.Accepted, "upload should be accepted")
+
+	file.Size = 14
+
+	precheck, err = upload.Precheck()
+	require.NoError(t, err, "unable to precheck upload")
+	require.False(t, precheck.Accepted, "upload should not be accepted")
+	require.Contains(t, precheck.Reason, "file is too big", "invalid reason")
+	require.Nil(t, upload.Metadata(), "upload should not have been created")
+}
+
 func TestMaxFilePerUploadCreate(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
 	defer shutdown(ps)
@@ -51,6 +51,50 @@ func TestTokenAuthentication(t *testing.T) {
 	require.Equal(t, data, string(content), "invalid file content")
 }
 
+func TestRotateToken(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().FeatureAuthentication = common.FeatureForced
+
+	user := common.NewUser("ovh", "gg1-ovh")
+	t1 := user.NewToken()
+	t1.Comment = "ci"
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	err = ps.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	_, err = pc.RotateToken(0)
+	common.RequireError(t, err, "missing token")
+
+	pc.Token = t1.Token
+	t2, err := pc.RotateToken(3600)
+	require.NoError(t, err, "unable to rotate token")
+	require.NotEqual(t, t1.Token, t2.Token, "token should have changed")
+	require.Equal(t, "ci", t2.Comment, "invalid token comment")
+	require.Equal(t, t2.Token, pc.Token, "client token should have been updated")
+
+	_, _, err = pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file with the new token")
+
+	// The former token is still valid during the grace period
+	pc.Token = t1.Token
+	_, _, err = pc.UploadReader("filename", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to upload file with the former token")
+
+	// Rotate without grace period
+	pc.Token = t2.Token
+	_, err = pc.RotateToken(0)
+	require.NoError(t, err, "unable to rotate token")
+
+	pc.Token = t2.Token
+	_, _, err = pc.UploadReader("filename", bytes.NewBufferString("data"))
+	common.RequireError(t, err, "invalid token")
+}
+
 // A user authenticated with a token should not be able to control an upload authenticated with another token
 func TestTokenMultipleToken(t *testing.T) {
 	ps, pc := newPlikServerAndClient()
@@ -249,3 +293,112 @@ func TestPasswordAuthenticationInClient(t *testing.T) {
 	require.NoError(t, err, "get upload with password error")
 	require.NotNil(t, upload, "invalid nil upload")
 }
+
+func TestManagementPassword(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	login := "plik"
+	password := "plok"
+	data := "data data data"
+
+	upload := pc.NewUpload()
+	upload.Removable = true
+	upload.Login = login
+	upload.Password = password
+	upload.ManagementPassword = "manage"
+	upload.AddFileFromReader("filename", ioutil.NopCloser(bytes.NewBufferString(data)))
+
+	err = upload.Upload()
+	require.NoError(t, err, "unable to upload file")
+	require.Zero(t, upload.Metadata().ManagementPassword, "management password returned in upload metadata")
+
+	id := upload.ID()
+
+	// The download password is enough to get the files
+	upload, err = pc.GetUploadProtectedByPassword(id, login, password)
+	require.NoError(t, err, "get upload with password error")
+
+	reader, err := upload.Files()[0].Download()
+	require.NoError(t, err, "get file error")
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, data, string(content), "invalid file content")
+
+	// But not to manage the upload
+	err = upload.Delete()
+	common.RequireError(t, err, "you are not allowed to remove this upload")
+
+	upload.ManagementPassword = "invalid"
+	err = upload.Delete()
+	common.RequireError(t, err, "invalid management password")
+
+	upload.ManagementPassword = "manage"
+	err = upload.Delete()
+	require.NoError(t, err, "unable to remove upload")
+}
+
+func TestRequireAuthForDownload(t *testing.T) {
+	ps, pc := newPlikServerAndClient()
+	defer shutdown(ps)
+
+	ps.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ps.GetConfig().RequireAuthForDownload = true
+
+	user := common.NewUser("ovh", "gg3-ovh")
+	token := user.NewToken()
+
+	err := start(ps)
+	require.NoError(t, err, "unable to start Plik server")
+
+	err = ps.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	config, err := pc.GetServerConfig()
+	require.NoError(t, err, "unable to get server config")
+	require.True(t, config.RequireAuthForDownload, "download policy should be advertised")
+
+	private, _, err := pc.UploadReader("filename", ioutil.NopCloser(bytes.NewBufferString("data")))
+	require.NoError(t, err, "unable to upload file")
+
+	public := pc.NewUpload()
+	public.Public = true
+	public.AddFileFromReader("filename", ioutil.NopCloser(bytes.NewBufferString("data")))
+	err = public.Upload()
+	require.NoError(t, err, "unable to upload file")
+
+	// Anonymous users can only download public uploads
+	anonymous := NewClient(pc.URL)
+
+	upload, err := anonymous.GetUpload(private.ID())
+	require.NoError(t, err, "unable to get upload")
+	_, err = upload.Files()[0].Download()
+	common.RequireError(t, err, "please login to download this file")
+
+	_, err = upload.DownloadZipArchive()
+	common.RequireError(t, err, "please login to download this file")
+
+	upload, err = anonymous.GetUpload(public.ID())
+	require.NoError(t, err, "unable to get upload")
+	require.True(t, upload.Public, "upload should be public")
+	reader, err := upload.Files()[0].Download()
+	require.NoError(t, err, "unable to download public file")
+	_ = reader.Close()
+
+	// Authenticated users can download any upload
+	authenticated := NewClient(pc.URL)
+	authenticated.Token = token.Token
+
+	upload, err = authenticated.GetUpload(private.ID())
+	require.NoError(t, err, "unable to get upload")
+	reader, err = upload.Files()[0].Download()
+	require.NoError(t, err, "unable to download file")
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(content), "invalid file content")
+}
@@ -5,8 +5,16 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/metadata"
 )
 
+type exportFlagParams struct {
+	format string
+}
+
+var exportParams = exportFlagParams{}
+
 // exportCmd to export metadata
 var exportCmd = &cobra.Command{
 	Use:   "export",
@@ -15,6 +23,7 @@ var exportCmd = &cobra.Command{
 }
 
 func init() {
+	exportCmd.Flags().StringVar(&exportParams.format, "format", metadata.ExportFormatBinary, "export file format ( binary|jsonl )")
 	rootCmd.AddCommand(exportCmd)
 }
 
@@ -28,7 +37,11 @@ func exportMetadata(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Exporting metadata from %s %s to %s\n", metadataBackend.Config.Driver, metadataBackend.Config.ConnectionString, args[0])
 
-	err := metadataBackend.Export(args[0])
+	exportOptions := &metadata.ExportOptions{
+		Format: exportParams.format,
+	}
+
+	err := metadataBackend.Export(args[0], exportOptions)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
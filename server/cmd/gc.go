@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/server"
+)
+
+type gcFlagParams struct {
+	grace  time.Duration
+	delete bool
+}
+
+var gcParams = gcFlagParams{}
+
+// gcCmd to find and delete orphan data and metadata
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Find (and delete) orphan blobs and files without data",
+	Run:   gc,
+}
+
+func init() {
+	gcCmd.Flags().DurationVar(&gcParams.grace, "grace", time.Hour, "ignore blobs and files more recent than this to not interfere with in-flight uploads")
+	gcCmd.Flags().BoolVar(&gcParams.delete, "delete", false, "delete orphan blobs and files without data (dry run otherwise)")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func gc(cmd *cobra.Command, args []string) {
+	plik := server.NewPlikServer(config)
+
+	initializeMetadataBackend()
+	plik.WithMetadataBackend(metadataBackend)
+
+	initializeDataBackend()
+	plik.WithDataBackend(dataBackend)
+
+	report, err := plik.GarbageCollect(gcParams.grace, gcParams.delete)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, blob := range report.OrphanBlobs {
+		fmt.Printf("orphan blob : %s/%s\n", blob.UploadID, blob.ID)
+	}
+	for _, file := range report.MissingFiles {
+		fmt.Printf("file without data : %s/%s\n", file.UploadID, file.ID)
+	}
+
+	fmt.Printf("%d blobs and %d files checked, %d orphan blobs, %d files without data\n",
+		report.Blobs, report.Files, len(report.OrphanBlobs), len(report.MissingFiles))
+
+	if !gcParams.delete {
+		fmt.Println("dry run, use --delete to delete them")
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Printf("unable to delete %d blobs or files\n", len(report.Errors))
+		os.Exit(1)
+	}
+}
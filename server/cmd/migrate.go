@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending metadata backend migrations",
+	Run:   migrate,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func migrate(cmd *cobra.Command, args []string) {
+	// Migrations are applied when initializing the metadata backend
+	metadataBackendConfig := metadata.NewConfig(config.MetadataBackendConfig)
+	metadataBackendConfig.DisableAutoMigration = false
+
+	backend, err := metadata.NewBackend(metadataBackendConfig, config.NewLogger())
+	if err != nil {
+		fmt.Printf("unable to initialize metadata backend : %s\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = backend.Shutdown() }()
+
+	version, _, err := backend.GetSchemaVersion()
+	if err != nil {
+		fmt.Printf("unable to get database schema version : %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Database schema version : %s\n", version)
+}
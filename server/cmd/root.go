@@ -122,7 +122,7 @@ var dataBackend data.Backend
 func initializeDataBackend() {
 	var err error
 	initializeDataBackendOnce.Do(func() {
-		dataBackend, err = server.NewDataBackend(config.DataBackend, config.DataBackendConfig)
+		dataBackend, err = server.NewDataBackendFromConfig(config)
 		if err != nil {
 			fmt.Printf("unable to initialize data backend : %s\n", err)
 			os.Exit(1)
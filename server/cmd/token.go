@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -126,7 +127,12 @@ func listTokens(cmd *cobra.Command, args []string) {
 			}
 		}
 
-		fmt.Printf("%s %s %s\n", token.UserID, token.Token, token.Comment)
+		lastUsed := "never"
+		if token.LastUsedAt != nil {
+			lastUsed = fmt.Sprintf("%s from %s", token.LastUsedAt.Format(time.RFC3339), token.LastUsedIP)
+		}
+
+		fmt.Printf("%s %s created %s last used %s %s\n", token.UserID, token.Token, token.CreatedAt.Format(time.RFC3339), lastUsed, token.Comment)
 
 		return nil
 	}
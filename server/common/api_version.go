@@ -0,0 +1,58 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// API versions negotiated with the Accept header ( ex : application/vnd.plik.v2+json )
+const (
+	APIVersion1 = 1 // Plik <1.3 upload format with files in a map
+	APIVersion2 = 2 // Current format
+)
+
+// CurrentAPIVersion is the API version used when the client does not ask for one
+const CurrentAPIVersion = APIVersion2
+
+var apiVersionMediaTypeRegexp = regexp.MustCompile(`^application/vnd\.plik\.v(\d+)\+json$`)
+
+// APIVersionMediaType return the media type of an API version
+func APIVersionMediaType(version int) string {
+	return fmt.Sprintf("application/vnd.plik.v%d+json", version)
+}
+
+// IsSupportedAPIVersion return true if the server can serialize responses in this API version
+func IsSupportedAPIVersion(version int) bool {
+	return version == APIVersion1 || version == APIVersion2
+}
+
+// ParseAPIVersion return the API version requested by the Accept header or 0 if none was requested
+// An error is returned if only unsupported API versions are requested
+func ParseAPIVersion(accept string) (version int, err error) {
+	var unsupported []string
+	for _, mediaRange := range strings.Split(accept, ",") {
+		// Ignore media type parameters ( ex : ;q=0.9 )
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(mediaRange, ";")[0]))
+
+		match := apiVersionMediaTypeRegexp.FindStringSubmatch(mediaType)
+		if match == nil {
+			continue
+		}
+
+		v, err := strconv.Atoi(match[1])
+		if err == nil && IsSupportedAPIVersion(v) {
+			return v, nil
+		}
+
+		unsupported = append(unsupported, mediaType)
+	}
+
+	if len(unsupported) > 0 {
+		return 0, fmt.Errorf("unsupported API version %s, supported versions are %s and %s",
+			strings.Join(unsupported, ", "), APIVersionMediaType(APIVersion1), APIVersionMediaType(APIVersion2))
+	}
+
+	return 0, nil
+}
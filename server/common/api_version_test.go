@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAPIVersion(t *testing.T) {
+	for accept, expected := range map[string]int{
+		"":                                 0,
+		"application/json":                 0,
+		"*/*":                              0,
+		"application/vnd.plik.v1+json":     APIVersion1,
+		"application/vnd.plik.v2+json":     APIVersion2,
+		"Application/Vnd.Plik.V2+JSON":     APIVersion2,
+		"application/vnd.plik.v2+json;q=1": APIVersion2,
+		"text/html, application/vnd.plik.v1+json;q=0.9, */*":         APIVersion1,
+		"application/vnd.plik.v3+json, application/vnd.plik.v2+json": APIVersion2,
+	} {
+		version, err := ParseAPIVersion(accept)
+		require.NoError(t, err, accept)
+		require.Equal(t, expected, version, accept)
+	}
+}
+
+func TestParseAPIVersionUnsupported(t *testing.T) {
+	_, err := ParseAPIVersion("application/vnd.plik.v3+json")
+	RequireError(t, err, "unsupported API version application/vnd.plik.v3+json")
+
+	_, err = ParseAPIVersion("application/vnd.plik.v0+json, application/json")
+	RequireError(t, err, "unsupported API version application/vnd.plik.v0+json")
+}
+
+func TestMarshalUploadVersions(t *testing.T) {
+	upload := &Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+
+	current, err := MarshalUpload(upload, 0)
+	require.NoError(t, err)
+
+	v2, err := MarshalUpload(upload, APIVersion2)
+	require.NoError(t, err)
+	require.Equal(t, current, v2, "version 2 is the current format")
+
+	v1, err := MarshalUpload(upload, APIVersion1)
+	require.NoError(t, err)
+	require.Contains(t, string(v1), `"files":{"`+file.ID+`":`)
+
+	_, err = MarshalUpload(upload, 3)
+	RequireError(t, err, "invalid version 3")
+}
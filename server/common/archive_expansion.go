@@ -0,0 +1,142 @@
+package common
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Archive formats that can be expanded on upload
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// GetArchiveFormat return the archive format of a file from its name or an empty string if it is not an archive
+func GetArchiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveFormatTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz
+	default:
+		return ""
+	}
+}
+
+// ArchiveEntry is a regular file of an archive
+type ArchiveEntry struct {
+	Name         string
+	RelativePath string
+	Size         int64
+
+	open func() (io.ReadCloser, error)
+}
+
+// Open return a reader of the entry data.
+// The entries of tar archives can only be read while walking the archive
+func (entry *ArchiveEntry) Open() (io.ReadCloser, error) {
+	return entry.open()
+}
+
+// newArchiveEntry split the path of an archive entry in a file name and a sanitized relative path
+func newArchiveEntry(name string, size int64) (entry *ArchiveEntry, err error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	entry = &ArchiveEntry{Name: path.Base(name), Size: size}
+	if entry.Name == "." || entry.Name == "/" || entry.Name == ".." {
+		return nil, fmt.Errorf("invalid archive entry %s", name)
+	}
+
+	entry.RelativePath, err = SanitizeRelativePath(path.Dir(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive entry %s : %s", name, err)
+	}
+
+	return entry, nil
+}
+
+// WalkArchive call fn for each regular file of the archive, directories, links and other special files are skipped.
+// Walking stops at the first error returned by fn
+func WalkArchive(reader io.ReaderAt, size int64, format string, fn func(entry *ArchiveEntry) error) (err error) {
+	switch format {
+	case ArchiveFormatZip:
+		return walkZipArchive(reader, size, fn)
+	case ArchiveFormatTar:
+		return walkTarArchive(io.NewSectionReader(reader, 0, size), fn)
+	case ArchiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(io.NewSectionReader(reader, 0, size))
+		if err != nil {
+			return fmt.Errorf("invalid gzip data : %s", err)
+		}
+		defer func() { _ = gzipReader.Close() }()
+		return walkTarArchive(gzipReader, fn)
+	default:
+		return fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+func walkZipArchive(reader io.ReaderAt, size int64, fn func(entry *ArchiveEntry) error) (err error) {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive : %s", err)
+	}
+
+	for _, f := range zipReader.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		// The zip reader fails if an entry expands to more than its declared size
+		entry, err := newArchiveEntry(f.Name, int64(f.UncompressedSize64))
+		if err != nil {
+			return err
+		}
+		entry.open = f.Open
+
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkTarArchive(reader io.Reader, fn func(entry *ArchiveEntry) error) (err error) {
+	tarReader := tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive : %s", err)
+		}
+
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		entry, err := newArchiveEntry(header.Name, header.Size)
+		if err != nil {
+			return err
+		}
+		entry.open = func() (io.ReadCloser, error) { return ioutil.NopCloser(tarReader), nil }
+
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+}
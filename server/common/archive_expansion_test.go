@@ -0,0 +1,106 @@
+package common
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetArchiveFormat(t *testing.T) {
+	require.Equal(t, ArchiveFormatZip, GetArchiveFormat("photos.ZIP"))
+	require.Equal(t, ArchiveFormatTar, GetArchiveFormat("backup.tar"))
+	require.Equal(t, ArchiveFormatTarGz, GetArchiveFormat("backup.tar.gz"))
+	require.Equal(t, ArchiveFormatTarGz, GetArchiveFormat("backup.tgz"))
+	require.Equal(t, "", GetArchiveFormat("file.gz"))
+	require.Equal(t, "", GetArchiveFormat("file.txt"))
+}
+
+func createTestZip(t *testing.T, entries map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	for name, data := range entries {
+		writer, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func createTestTar(t *testing.T, entries map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buf)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+	for name, data := range entries {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}))
+		_, err := tarWriter.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return buf.Bytes()
+}
+
+func walkTestArchive(t *testing.T, data []byte, format string) (entries map[string]string, err error) {
+	entries = make(map[string]string)
+	err = WalkArchive(bytes.NewReader(data), int64(len(data)), format, func(entry *ArchiveEntry) error {
+		reader, err := entry.Open()
+		require.NoError(t, err)
+		defer func() { _ = reader.Close() }()
+
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(content)), entry.Size, "invalid entry size")
+
+		entries[entry.RelativePath+"|"+entry.Name] = string(content)
+		return nil
+	})
+	return entries, err
+}
+
+func TestWalkArchive(t *testing.T) {
+	files := map[string]string{"a.txt": "aaa", "dir/sub/b.txt": "bbb", "./c.txt": "ccc"}
+	expected := map[string]string{"|a.txt": "aaa", "dir/sub|b.txt": "bbb", "|c.txt": "ccc"}
+
+	entries, err := walkTestArchive(t, createTestZip(t, files), ArchiveFormatZip)
+	require.NoError(t, err)
+	require.Equal(t, expected, entries)
+
+	tarData := createTestTar(t, files)
+	entries, err = walkTestArchive(t, tarData, ArchiveFormatTar)
+	require.NoError(t, err)
+	require.Equal(t, expected, entries, "directories and links must be skipped")
+
+	buf := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(buf)
+	_, err = gzipWriter.Write(tarData)
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	entries, err = walkTestArchive(t, buf.Bytes(), ArchiveFormatTarGz)
+	require.NoError(t, err)
+	require.Equal(t, expected, entries)
+}
+
+func TestWalkArchiveInvalid(t *testing.T) {
+	_, err := walkTestArchive(t, createTestZip(t, map[string]string{"../evil.txt": "evil"}), ArchiveFormatZip)
+	RequireError(t, err, "invalid archive entry ../evil.txt")
+
+	_, err = walkTestArchive(t, createTestTar(t, map[string]string{"/etc/evil.txt": "evil"}), ArchiveFormatTar)
+	RequireError(t, err, "invalid archive entry /etc/evil.txt")
+
+	_, err = walkTestArchive(t, []byte("not an archive"), ArchiveFormatZip)
+	RequireError(t, err, "invalid zip archive")
+
+	_, err = walkTestArchive(t, []byte("not an archive"), ArchiveFormatTarGz)
+	RequireError(t, err, "invalid gzip data")
+
+	_, err = walkTestArchive(t, []byte{}, "rar")
+	RequireError(t, err, "unsupported archive format rar")
+}
@@ -32,10 +32,11 @@ type SessionAuthenticator struct {
 }
 
 // GenAuthCookies generate a sign a jwt session cookie to authenticate a user
-func (sa *SessionAuthenticator) GenAuthCookies(user *User) (sessionCookie *http.Cookie, xsrfCookie *http.Cookie, err error) {
+func (sa *SessionAuthenticator) GenAuthCookies(user *User, s *Session) (sessionCookie *http.Cookie, xsrfCookie *http.Cookie, err error) {
 	// Generate session jwt
 	session := jwt.New(jwt.SigningMethodHS512)
 	session.Claims.(jwt.MapClaims)["uid"] = user.ID
+	session.Claims.(jwt.MapClaims)["sid"] = s.ID
 
 	// Generate xsrf token
 	xsrfToken, err := uuid.NewV4()
@@ -77,7 +78,7 @@ func (sa *SessionAuthenticator) GenAuthCookies(user *User) (sessionCookie *http.
 }
 
 // ParseSessionCookie parse and validate the session cookie
-func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, xsrf string, err error) {
+func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, sid string, xsrf string, err error) {
 	session, err := jwt.Parse(value, func(t *jwt.Token) (interface{}, error) {
 		// Verify signing algorithm
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -87,7 +88,7 @@ func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, xs
 		return []byte(sa.SignatureKey), nil
 	})
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
 	}
 
 	// Get the user id
@@ -95,10 +96,21 @@ func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, xs
 	if ok {
 		uid, ok = userValue.(string)
 		if !ok || uid == "" {
-			return "", "", fmt.Errorf("invalid user from session cookie")
+			return "", "", "", fmt.Errorf("invalid user from session cookie")
 		}
 	} else {
-		return "", "", fmt.Errorf("missing user from session cookie")
+		return "", "", "", fmt.Errorf("missing user from session cookie")
+	}
+
+	// Get the session id, the session cookie is revoked with the session
+	sessionValue, ok := session.Claims.(jwt.MapClaims)["sid"]
+	if ok {
+		sid, ok = sessionValue.(string)
+		if !ok || sid == "" {
+			return "", "", "", fmt.Errorf("invalid session id from session cookie")
+		}
+	} else {
+		return "", "", "", fmt.Errorf("missing session id from session cookie")
 	}
 
 	// Get the xsrf token
@@ -106,10 +118,10 @@ func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, xs
 	if ok {
 		xsrf, ok = xsrfValue.(string)
 		if !ok || uid == "" {
-			return "", "", fmt.Errorf("invalid xsrf token from session cookie")
+			return "", "", "", fmt.Errorf("invalid xsrf token from session cookie")
 		}
 	} else {
-		return "", "", fmt.Errorf("missing xsrf token from session cookie")
+		return "", "", "", fmt.Errorf("missing xsrf token from session cookie")
 	}
 
 	// Check that the session didn't expire yet.
@@ -119,20 +131,20 @@ func (sa *SessionAuthenticator) ParseSessionCookie(value string) (uid string, xs
 	if ok {
 		createdAtStrValue, ok := createdAtValue.(string)
 		if !ok || createdAtValue == "" {
-			return "", "", fmt.Errorf("invalid creation date from session cookie")
+			return "", "", "", fmt.Errorf("invalid creation date from session cookie")
 		}
 		createdAt, err := strconv.ParseInt(createdAtStrValue, 10, 64)
 		if err != nil {
-			return "", "", fmt.Errorf("unable to parse creation date from session cookie")
+			return "", "", "", fmt.Errorf("unable to parse creation date from session cookie")
 		}
 		if time.Now().After(time.Unix(createdAt, 0).Add(time.Duration(sa.SessionTimeout) * time.Second)) {
-			return "", "", fmt.Errorf("session timeout")
+			return "", "", "", fmt.Errorf("session timeout")
 		}
 	} else {
-		return "", "", fmt.Errorf("missing creation date from session cookie")
+		return "", "", "", fmt.Errorf("missing creation date from session cookie")
 	}
 
-	return uid, xsrf, nil
+	return uid, sid, xsrf, nil
 }
 
 // Logout delete session cookies
@@ -174,6 +186,13 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// HashUploadSecret return bcrypt hash ( with salt ) of an upload secret like the management password
+// The default cost is used as the secret is checked on every request managing the upload
+func HashUploadSecret(secret string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(bytes), err
+}
+
 // CheckPasswordHash check password against bcrypt password hash
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
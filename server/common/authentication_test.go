@@ -3,10 +3,13 @@ package common
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestSessionAuthenticator(t *testing.T) {
@@ -16,8 +19,9 @@ func TestSessionAuthenticator(t *testing.T) {
 	sa := &SessionAuthenticator{SignatureKey: setting.Value, SecureCookies: true, SessionTimeout: maxAge, Path: path}
 
 	user := NewUser("local", "user")
+	session := NewSession(user, "1.1.1.1", "agent")
 
-	sessionCookie, xsrfCookie, err := sa.GenAuthCookies(user)
+	sessionCookie, xsrfCookie, err := sa.GenAuthCookies(user, session)
 	require.NoError(t, err, "unable to generate cookies")
 	require.NotNil(t, sessionCookie, "missing session cookie")
 	require.NotNil(t, xsrfCookie, "missing xsrf cookie")
@@ -32,16 +36,43 @@ func TestSessionAuthenticator(t *testing.T) {
 	require.Equal(t, path, xsrfCookie.Path, "invalid xsrf cookie path")
 	require.True(t, xsrfCookie.Secure, "invalid xsrf cookie not secure")
 
-	uid, xsrf, err := sa.ParseSessionCookie(sessionCookie.Value)
+	uid, sid, xsrf, err := sa.ParseSessionCookie(sessionCookie.Value)
 	require.NoError(t, err, "unable to parse session cookie")
 	require.Equal(t, user.ID, uid, "invalid user id")
+	require.Equal(t, session.ID, sid, "invalid session id")
 	require.Equal(t, xsrfCookie.Value, xsrf, "invalid xsrf token")
 
 	time.Sleep(time.Second)
-	uid, xsrf, err = sa.ParseSessionCookie(sessionCookie.Value)
+	_, _, _, err = sa.ParseSessionCookie(sessionCookie.Value)
 	require.Error(t, err, "session timeout")
 }
 
+func TestSessionAuthenticatorMissingSessionID(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key", SessionTimeout: 3600}
+
+	// Session cookies issued before sessions were tracked can't be revoked
+	session := jwt.New(jwt.SigningMethodHS512)
+	session.Claims.(jwt.MapClaims)["uid"] = "local:user"
+	session.Claims.(jwt.MapClaims)["xsrf"] = "xsrf"
+	session.Claims.(jwt.MapClaims)["created_at"] = strconv.FormatInt(time.Now().Unix(), 10)
+	value, err := session.SignedString([]byte(sa.SignatureKey))
+	require.NoError(t, err, "unable to sign session cookie")
+
+	_, _, _, err = sa.ParseSessionCookie(value)
+	RequireError(t, err, "missing session id from session cookie")
+}
+
+func TestNewSession(t *testing.T) {
+	user := NewUser("local", "user")
+	session := NewSession(user, "1.1.1.1", string(make([]byte, 1000)))
+	require.NotEmpty(t, session.ID, "missing session id")
+	require.Equal(t, user.ID, session.UserID, "invalid session user")
+	require.Equal(t, "1.1.1.1", session.IP, "invalid session ip")
+	require.Len(t, session.UserAgent, 512, "user agent should be truncated")
+	require.False(t, session.CreatedAt.IsZero(), "missing session creation date")
+	require.Equal(t, session.CreatedAt, session.LastSeenAt, "invalid session last seen date")
+}
+
 func TestLogout(t *testing.T) {
 	path := "/path"
 
@@ -82,3 +113,14 @@ func TestHashPassword(t *testing.T) {
 	ok = CheckPasswordHash("invalid", hash)
 	require.False(t, ok)
 }
+
+func TestHashUploadSecret(t *testing.T) {
+	hash, err := HashUploadSecret("password")
+	require.NoError(t, err, "hash upload secret error")
+	require.True(t, CheckPasswordHash("password", hash))
+	require.False(t, CheckPasswordHash("invalid", hash))
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	require.NoError(t, err, "unable to get hash cost")
+	require.Equal(t, bcrypt.DefaultCost, cost, "invalid hash cost")
+}
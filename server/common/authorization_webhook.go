@@ -0,0 +1,80 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AuthorizationActionUpload when files are added to an upload
+const AuthorizationActionUpload = "upload"
+
+// AuthorizationActionDownload when files are downloaded
+const AuthorizationActionDownload = "download"
+
+// AuthorizationActionDelete when an upload or files are removed
+const AuthorizationActionDelete = "delete"
+
+// AuthorizationRequest is posted as JSON to the AuthorizationWebhookURL before an action is performed
+type AuthorizationRequest struct {
+	Action   string `json:"action"`
+	UploadID string `json:"uploadId,omitempty"`
+	FileID   string `json:"fileId,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+	User     string `json:"user,omitempty"`
+	SourceIP string `json:"sourceIp,omitempty"`
+}
+
+// AuthorizationResponse is expected from the AuthorizationWebhookURL
+type AuthorizationResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+const authorizationWebhookTimeout = 5 * time.Second
+
+func (config *Configuration) initializeAuthorizationWebhook() (err error) {
+	if config.AuthorizationWebhookURL == "" {
+		return nil
+	}
+
+	webhookURL, err := url.Parse(config.AuthorizationWebhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid AuthorizationWebhookURL %s : %s", config.AuthorizationWebhookURL, err)
+	}
+	if (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "" {
+		return fmt.Errorf("invalid AuthorizationWebhookURL %s : expected an absolute http(s) URL", config.AuthorizationWebhookURL)
+	}
+
+	return nil
+}
+
+// Authorize ask the AuthorizationWebhookURL whether the action is allowed
+// An error is returned if the webhook can't be reached or its response can't be understood
+func (config *Configuration) Authorize(request *AuthorizationRequest) (response *AuthorizationResponse, err error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize authorization request : %s", err)
+	}
+
+	resp, err := config.NewHTTPClient(authorizationWebhookTimeout).Post(config.AuthorizationWebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to post authorization request : %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected authorization webhook response status %d", resp.StatusCode)
+	}
+
+	response = &AuthorizationResponse{}
+	err = json.NewDecoder(resp.Body).Decode(response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to deserialize authorization response : %s", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeAuthorizationWebhook(t *testing.T) {
+	config := NewConfiguration()
+	config.AuthorizationWebhookURL = "https://authz.plik.root.gg/check"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+
+	config = NewConfiguration()
+	config.AuthorizationWebhookURL = "authz.plik.root.gg"
+	err = config.Initialize()
+	RequireError(t, err, "invalid AuthorizationWebhookURL")
+
+	config = NewConfiguration()
+	config.AuthorizationWebhookURL = "ftp://authz.plik.root.gg"
+	err = config.Initialize()
+	RequireError(t, err, "expected an absolute http(s) URL")
+}
+
+func TestAuthorize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "POST", req.Method, "invalid method")
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"), "invalid content type")
+
+		request := &AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, "upload", request.UploadID, "invalid upload id")
+		require.Equal(t, "1.2.3.4", request.SourceIP, "invalid source ip")
+
+		if request.Action == AuthorizationActionDownload {
+			_, _ = resp.Write([]byte(`{"allow":true}`))
+		} else {
+			_, _ = resp.Write([]byte(`{"allow":false,"reason":"read only"}`))
+		}
+	}))
+	defer server.Close()
+
+	config := NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+
+	response, err := config.Authorize(&AuthorizationRequest{Action: AuthorizationActionDownload, UploadID: "upload", SourceIP: "1.2.3.4"})
+	require.NoError(t, err, "unable to authorize")
+	require.True(t, response.Allow, "download should be allowed")
+
+	response, err = config.Authorize(&AuthorizationRequest{Action: AuthorizationActionDelete, UploadID: "upload", SourceIP: "1.2.3.4"})
+	require.NoError(t, err, "unable to authorize")
+	require.False(t, response.Allow, "delete should not be allowed")
+	require.Equal(t, "read only", response.Reason, "invalid reason")
+}
+
+func TestAuthorizeWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+
+	_, err := config.Authorize(&AuthorizationRequest{Action: AuthorizationActionUpload})
+	RequireError(t, err, "unexpected authorization webhook response status 500")
+
+	server = httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte("not json"))
+	}))
+	defer server.Close()
+	config.AuthorizationWebhookURL = server.URL
+
+	_, err = config.Authorize(&AuthorizationRequest{Action: AuthorizationActionUpload})
+	RequireError(t, err, "unable to deserialize authorization response")
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter shares a maximum bandwidth fairly between all the active readers
+// so that one big download can't starve the others
+type BandwidthLimiter struct {
+	bytesPerSecond int64
+
+	mu     sync.Mutex
+	active int64
+}
+
+// NewBandwidthLimiter creates a new BandwidthLimiter
+func NewBandwidthLimiter(bytesPerSecond int64) (limiter *BandwidthLimiter) {
+	limiter = new(BandwidthLimiter)
+	limiter.bytesPerSecond = bytesPerSecond
+	return limiter
+}
+
+// GetActiveReaders return the number of readers currently sharing the bandwidth
+func (limiter *BandwidthLimiter) GetActiveReaders() int64 {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	return limiter.active
+}
+
+// GetShare return the bandwidth currently allocated to each active reader in bytes per second
+func (limiter *BandwidthLimiter) GetShare() int64 {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	share := limiter.bytesPerSecond
+	if limiter.active > 1 {
+		share = share / limiter.active
+	}
+	if share < 1 {
+		share = 1
+	}
+
+	return share
+}
+
+// NewReader wraps a reader to limit its throughput to a fair share of the bandwidth
+// The reader must be closed to release its share
+func (limiter *BandwidthLimiter) NewReader(reader io.Reader) (limitedReader *BandwidthLimitedReader) {
+	limiter.mu.Lock()
+	limiter.active++
+	limiter.mu.Unlock()
+
+	limitedReader = new(BandwidthLimitedReader)
+	limitedReader.reader = reader
+	limitedReader.limiter = limiter
+	limitedReader.start = time.Now()
+
+	return limitedReader
+}
+
+func (limiter *BandwidthLimiter) release() {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.active--
+}
+
+// BandwidthLimitedReader is an io.Reader which throughput is limited by a BandwidthLimiter
+type BandwidthLimitedReader struct {
+	reader  io.Reader
+	limiter *BandwidthLimiter
+
+	start  time.Time
+	bytes  int64
+	closed bool
+}
+
+// Read implements io.Reader
+func (r *BandwidthLimitedReader) Read(p []byte) (n int, err error) {
+	share := r.limiter.GetShare()
+
+	// Read at most 100ms worth of data at a time to quickly adapt to the number of active readers
+	max := share / 10
+	if max < 1 {
+		max = 1
+	}
+	if int64(len(p)) > max {
+		p = p[:max]
+	}
+
+	start := time.Now()
+	n, err = r.reader.Read(p)
+	r.bytes += int64(n)
+
+	// Wait for the time the read should have taken at the current share
+	wait := time.Duration(n)*time.Second/time.Duration(share) - time.Since(start)
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
+
+// Close releases the reader bandwidth share
+func (r *BandwidthLimitedReader) Close() error {
+	if !r.closed {
+		r.closed = true
+		r.limiter.release()
+	}
+	return nil
+}
+
+// GetRate return the effective throughput of the reader in bytes per second
+func (r *BandwidthLimitedReader) GetRate() int64 {
+	elapsed := time.Since(r.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(r.bytes) / elapsed)
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimiterShare(t *testing.T) {
+	limiter := NewBandwidthLimiter(1000)
+	require.Equal(t, int64(1000), limiter.GetShare())
+
+	r1 := limiter.NewReader(bytes.NewBuffer([]byte{}))
+	require.Equal(t, int64(1), limiter.GetActiveReaders())
+	require.Equal(t, int64(1000), limiter.GetShare())
+
+	r2 := limiter.NewReader(bytes.NewBuffer([]byte{}))
+	require.Equal(t, int64(2), limiter.GetActiveReaders())
+	require.Equal(t, int64(500), limiter.GetShare())
+
+	require.NoError(t, r1.Close())
+	require.NoError(t, r1.Close())
+	require.Equal(t, int64(1), limiter.GetActiveReaders())
+	require.Equal(t, int64(1000), limiter.GetShare())
+
+	require.NoError(t, r2.Close())
+	require.Equal(t, int64(0), limiter.GetActiveReaders())
+}
+
+func TestBandwidthLimitedReader(t *testing.T) {
+	limiter := NewBandwidthLimiter(10000)
+
+	data := bytes.Repeat([]byte("x"), 2000)
+	reader := limiter.NewReader(bytes.NewBuffer(data))
+	defer reader.Close()
+
+	start := time.Now()
+	result, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read data")
+	require.Equal(t, data, result, "invalid data")
+
+	// 2000 bytes at 10000 bytes per second should take at least 200ms
+	require.True(t, time.Since(start) >= 150*time.Millisecond, "reader is not limited")
+	require.True(t, reader.GetRate() <= 12000, "invalid rate %d", reader.GetRate())
+}
+
+func TestBandwidthLimitedReaderFairShare(t *testing.T) {
+	limiter := NewBandwidthLimiter(10000)
+
+	data := bytes.Repeat([]byte("x"), 1000)
+
+	var wg sync.WaitGroup
+	rates := make([]int64, 2)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		reader := limiter.NewReader(bytes.NewBuffer(data))
+		go func(i int) {
+			defer wg.Done()
+			defer reader.Close()
+			_, err := ioutil.ReadAll(reader)
+			require.NoError(t, err, "unable to read data")
+			rates[i] = reader.GetRate()
+		}(i)
+	}
+	wg.Wait()
+
+	// 2 x 1000 bytes sharing 10000 bytes per second should take at least 200ms
+	require.True(t, time.Since(start) >= 150*time.Millisecond, "reader is not limited")
+	for _, rate := range rates {
+		require.True(t, rate <= 6000, "invalid rate %d", rate)
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CaptchaHCaptcha verify anonymous uploads with hCaptcha
+const CaptchaHCaptcha = "hcaptcha"
+
+// CaptchaReCaptcha verify anonymous uploads with Google reCAPTCHA
+const CaptchaReCaptcha = "recaptcha"
+
+var captchaVerifyURLs = map[string]string{
+	CaptchaHCaptcha:  "https://hcaptcha.com/siteverify",
+	CaptchaReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+const captchaVerifyTimeout = 10 * time.Second
+
+func (config *Configuration) initializeCaptcha() (err error) {
+	if config.CaptchaProvider == "" {
+		return nil
+	}
+
+	if _, ok := captchaVerifyURLs[config.CaptchaProvider]; !ok {
+		return fmt.Errorf("invalid CaptchaProvider %s. Expecting : %s|%s", config.CaptchaProvider, CaptchaHCaptcha, CaptchaReCaptcha)
+	}
+
+	if config.CaptchaSiteKey == "" || config.CaptchaSecret == "" {
+		return fmt.Errorf("CaptchaProvider needs CaptchaSiteKey and CaptchaSecret")
+	}
+
+	if config.CaptchaVerifyURL == "" {
+		config.CaptchaVerifyURL = captchaVerifyURLs[config.CaptchaProvider]
+	}
+
+	return nil
+}
+
+// VerifyCaptcha check the CAPTCHA response token with the CAPTCHA provider
+func (config *Configuration) VerifyCaptcha(response string, remoteIP string) (err error) {
+	if response == "" {
+		return fmt.Errorf("missing captcha response")
+	}
+
+	params := url.Values{}
+	params.Set("secret", config.CaptchaSecret)
+	params.Set("sitekey", config.CaptchaSiteKey)
+	params.Set("response", response)
+	if remoteIP != "" {
+		params.Set("remoteip", remoteIP)
+	}
+
+	resp, err := config.NewHTTPClient(captchaVerifyTimeout).PostForm(config.CaptchaVerifyURL, params)
+	if err != nil {
+		return fmt.Errorf("unable to verify captcha : %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to verify captcha : unexpected status code %d", resp.StatusCode)
+	}
+
+	result := &struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("unable to verify captcha : %s", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("invalid captcha response %v", result.ErrorCodes)
+	}
+
+	return nil
+}
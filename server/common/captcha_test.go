@@ -0,0 +1,74 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeCaptcha(t *testing.T) {
+	config := NewConfiguration()
+	config.CaptchaProvider = CaptchaHCaptcha
+	config.CaptchaSiteKey = "sitekey"
+	config.CaptchaSecret = "secret"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.Equal(t, "https://hcaptcha.com/siteverify", config.CaptchaVerifyURL, "invalid captcha verify URL")
+
+	config = NewConfiguration()
+	config.CaptchaProvider = "foo"
+	err = config.Initialize()
+	RequireError(t, err, "invalid CaptchaProvider foo")
+
+	config = NewConfiguration()
+	config.CaptchaProvider = CaptchaReCaptcha
+	err = config.Initialize()
+	RequireError(t, err, "CaptchaProvider needs CaptchaSiteKey and CaptchaSecret")
+}
+
+func newCaptchaTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "secret", req.PostFormValue("secret"), "invalid captcha secret")
+		require.Equal(t, "1.2.3.4", req.PostFormValue("remoteip"), "invalid captcha remote ip")
+		if req.PostFormValue("response") == "valid" {
+			_, _ = resp.Write([]byte(`{"success":true}`))
+		} else {
+			_, _ = resp.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+}
+
+func TestVerifyCaptcha(t *testing.T) {
+	server := newCaptchaTestServer(t)
+	defer server.Close()
+
+	config := NewConfiguration()
+	config.CaptchaProvider = CaptchaHCaptcha
+	config.CaptchaSecret = "secret"
+	config.CaptchaVerifyURL = server.URL
+
+	err := config.VerifyCaptcha("valid", "1.2.3.4")
+	require.NoError(t, err, "unable to verify valid captcha")
+
+	err = config.VerifyCaptcha("invalid", "1.2.3.4")
+	RequireError(t, err, "invalid captcha response [invalid-input-response]")
+
+	err = config.VerifyCaptcha("", "1.2.3.4")
+	RequireError(t, err, "missing captcha response")
+}
+
+func TestVerifyCaptchaProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := NewConfiguration()
+	config.CaptchaVerifyURL = server.URL
+
+	err := config.VerifyCaptcha("valid", "1.2.3.4")
+	RequireError(t, err, "unexpected status code 500")
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// APIKeyHeader is the request header identifying client apps
+const APIKeyHeader = "X-API-Key"
+
+// ClientApp is an application uploading to Plik with its own API key and upload policy
+type ClientApp struct {
+	Name              string   `json:"name"`
+	Key               string   `json:"-"`
+	MaxFileSizeStr    string   `json:"-"`
+	MaxFileSize       int64    `json:"-"`
+	MaxTTLStr         string   `json:"-"`
+	MaxTTL            int      `json:"-"`
+	RateLimit         int      `json:"-"` // Maximum number of requests per minute ( 0 : no limit )
+	AllowedExtensions []string `json:"-"` // ex : [".pdf", ".png"] ( empty : all extensions are allowed )
+
+	mu          sync.Mutex
+	window      time.Time
+	windowCount int
+	requests    int64
+	rateLimited int64
+}
+
+// Allow count a request of the client app and return false if the rate limit is exceeded
+func (app *ClientApp) Allow() bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	app.requests++
+
+	if app.RateLimit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(app.window) >= time.Minute {
+		app.window = now
+		app.windowCount = 0
+	}
+
+	if app.windowCount >= app.RateLimit {
+		app.rateLimited++
+		return false
+	}
+
+	app.windowCount++
+	return true
+}
+
+// GetRequestCounts return the number of requests made by the client app since the server started and how many were rate limited
+func (app *ClientApp) GetRequestCounts() (requests int64, rateLimited int64) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	return app.requests, app.rateLimited
+}
+
+// IsAllowedFileName return true if the file extension is allowed by the client app policy
+func (app *ClientApp) IsAllowedFileName(name string) bool {
+	return isAllowedExtension(name, app.AllowedExtensions)
+}
+
+// isAllowedExtension return true if the file extension is in the list, an empty list allows all extensions
+func isAllowedExtension(name string, extensions []string) bool {
+	if len(extensions) == 0 {
+		return true
+	}
+
+	extension := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range extensions {
+		if extension == strings.ToLower(allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (config *Configuration) initializeClientApps() (err error) {
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	for _, app := range config.ClientApps {
+		if app.Name == "" {
+			return fmt.Errorf("missing client app name")
+		}
+		if names[app.Name] {
+			return fmt.Errorf("duplicate client app name %s", app.Name)
+		}
+		names[app.Name] = true
+
+		if app.Key == "" {
+			return fmt.Errorf("missing API key for client app %s", app.Name)
+		}
+		if keys[app.Key] {
+			return fmt.Errorf("duplicate API key for client app %s", app.Name)
+		}
+		keys[app.Key] = true
+
+		if app.MaxFileSizeStr != "" {
+			maxFileSize, err := humanize.ParseBytes(app.MaxFileSizeStr)
+			if err != nil {
+				return fmt.Errorf("unable to parse MaxFileSize of client app %s : %s", app.Name, err)
+			}
+			app.MaxFileSize = int64(maxFileSize)
+		}
+
+		if app.MaxTTLStr != "" {
+			app.MaxTTL, err = ParseTTL(app.MaxTTLStr)
+			if err != nil {
+				return fmt.Errorf("unable to parse MaxTTL of client app %s : %s", app.Name, err)
+			}
+		}
+
+		if app.RateLimit < 0 {
+			return fmt.Errorf("invalid negative RateLimit for client app %s", app.Name)
+		}
+	}
+
+	return nil
+}
+
+// GetClientAppByKey return the client app identified by the API key or nil
+func (config *Configuration) GetClientAppByKey(key string) *ClientApp {
+	for _, app := range config.ClientApps {
+		if subtle.ConstantTimeCompare([]byte(app.Key), []byte(key)) == 1 {
+			return app
+		}
+	}
+	return nil
+}
+
+// GetClientApp return the named client app or nil
+func (config *Configuration) GetClientApp(name string) *ClientApp {
+	for _, app := range config.ClientApps {
+		if app.Name == name {
+			return app
+		}
+	}
+	return nil
+}
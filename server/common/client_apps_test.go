@@ -0,0 +1,91 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeClientApps(t *testing.T) {
+	config := NewConfiguration()
+	config.ClientApps = []*ClientApp{
+		{Name: "backup", Key: "secret", MaxFileSizeStr: "1GB", MaxTTLStr: "90d", RateLimit: 10},
+	}
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, int64(1000000000), config.ClientApps[0].MaxFileSize)
+	require.Equal(t, 90*86400, config.ClientApps[0].MaxTTL)
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Key: "secret"}}
+	RequireError(t, config.Initialize(), "missing client app name")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup"}}
+	RequireError(t, config.Initialize(), "missing API key for client app backup")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret"}, {Name: "backup", Key: "other"}}
+	RequireError(t, config.Initialize(), "duplicate client app name backup")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret"}, {Name: "ci", Key: "secret"}}
+	RequireError(t, config.Initialize(), "duplicate API key for client app ci")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret", MaxFileSizeStr: "foo"}}
+	RequireError(t, config.Initialize(), "unable to parse MaxFileSize of client app backup")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret", MaxTTLStr: "foo"}}
+	RequireError(t, config.Initialize(), "unable to parse MaxTTL of client app backup")
+
+	config = NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret", RateLimit: -1}}
+	RequireError(t, config.Initialize(), "invalid negative RateLimit for client app backup")
+}
+
+func TestGetClientApp(t *testing.T) {
+	config := NewConfiguration()
+	config.ClientApps = []*ClientApp{{Name: "backup", Key: "secret"}}
+
+	require.Equal(t, "backup", config.GetClientAppByKey("secret").Name)
+	require.Nil(t, config.GetClientAppByKey("foo"))
+	require.Nil(t, config.GetClientAppByKey(""))
+
+	require.Equal(t, "secret", config.GetClientApp("backup").Key)
+	require.Nil(t, config.GetClientApp("foo"))
+}
+
+func TestClientAppAllow(t *testing.T) {
+	app := &ClientApp{Name: "backup", RateLimit: 2}
+
+	require.True(t, app.Allow())
+	require.True(t, app.Allow())
+	require.False(t, app.Allow())
+
+	requests, rateLimited := app.GetRequestCounts()
+	require.Equal(t, int64(3), requests)
+	require.Equal(t, int64(1), rateLimited)
+
+	// A new window starts after a minute
+	app.window = app.window.Add(-time.Minute)
+	require.True(t, app.Allow())
+
+	unlimited := &ClientApp{Name: "ci"}
+	for i := 0; i < 100; i++ {
+		require.True(t, unlimited.Allow())
+	}
+}
+
+func TestClientAppIsAllowedFileName(t *testing.T) {
+	app := &ClientApp{Name: "backup"}
+	require.True(t, app.IsAllowedFileName("foo.exe"))
+
+	app.AllowedExtensions = []string{".tar", ".GZ"}
+	require.True(t, app.IsAllowedFileName("backup.tar"))
+	require.True(t, app.IsAllowedFileName("backup.TAR.gz"))
+	require.False(t, app.IsAllowedFileName("backup.exe"))
+	require.False(t, app.IsAllowedFileName("tar"))
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"net"
+)
+
+// AnonymizeIP zero the last octet of IPv4 addresses and the last 80 bits of IPv6 addresses
+func AnonymizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32))
+	}
+	return ip.Mask(net.CIDRMask(48, 128))
+}
+
+// FormatClientIP return the client IP address to write to the logs and metadata
+// The address is anonymized if AnonymizeClientIP is set, access checks must use the full address instead
+func (config *Configuration) FormatClientIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	if config.AnonymizeClientIP {
+		ip = AnonymizeIP(ip)
+	}
+	return ip.String()
+}
@@ -0,0 +1,25 @@
+package common
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	require.Equal(t, "1.2.3.0", AnonymizeIP(net.ParseIP("1.2.3.4")).String())
+	require.Equal(t, "2001:db8:1234::", AnonymizeIP(net.ParseIP("2001:db8:1234:5678:9abc:def0:1234:5678")).String())
+	require.Equal(t, "::", AnonymizeIP(net.ParseIP("::1")).String())
+}
+
+func TestFormatClientIP(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, "", config.FormatClientIP(nil))
+	require.Equal(t, "1.2.3.4", config.FormatClientIP(net.ParseIP("1.2.3.4")))
+
+	config.AnonymizeClientIP = true
+	require.Equal(t, "", config.FormatClientIP(nil))
+	require.Equal(t, "1.2.3.0", config.FormatClientIP(net.ParseIP("1.2.3.4")))
+	require.Equal(t, "2001:db8:1234::", config.FormatClientIP(net.ParseIP("2001:db8:1234:5678::1")))
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Upload comments are rendered as markdown by the web interface which would also render any raw HTML.
+// Tags are escaped so they are displayed as text and script links are neutralized.
+var (
+	commentsTagRegexp        = regexp.MustCompile(`<([a-zA-Z/!?])`)
+	commentsScriptLinkRegexp = regexp.MustCompile(`(?im)(\]\(\s*|^\s*\[[^\]]*\]:\s*)(javascript|vbscript|data)\s*:`)
+)
+
+// SanitizeComments return the comments without any HTML tag or script link the markdown renderer would interpret
+func SanitizeComments(comments string) string {
+	comments = commentsTagRegexp.ReplaceAllString(comments, "&lt;$1")
+	comments = commentsScriptLinkRegexp.ReplaceAllString(comments, "$1#")
+	return comments
+}
+
+// ValidateComments check the length of the comments ( maxLength : maximum number of characters, 0 : no limit )
+func ValidateComments(comments string, maxLength int) (err error) {
+	if maxLength > 0 && utf8.RuneCountInString(comments) > maxLength {
+		return fmt.Errorf("comments too long, maximum length is %d characters", maxLength)
+	}
+	return nil
+}
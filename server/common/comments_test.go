@@ -0,0 +1,28 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeComments(t *testing.T) {
+	require.Equal(t, "", SanitizeComments(""))
+	require.Equal(t, "# Title\n\n**bold** 1 < 2 > 0\n> quote", SanitizeComments("# Title\n\n**bold** 1 < 2 > 0\n> quote"))
+	require.Equal(t, "[link](https://plik.root.gg)", SanitizeComments("[link](https://plik.root.gg)"))
+
+	require.Equal(t, "&lt;script>alert(1)&lt;/script>", SanitizeComments("<script>alert(1)</script>"))
+	require.Equal(t, `&lt;img src=x onerror="alert(1)">`, SanitizeComments(`<img src=x onerror="alert(1)">`))
+	require.Equal(t, "&lt;!-- comment -->", SanitizeComments("<!-- comment -->"))
+	require.Equal(t, "[link](#alert(1))", SanitizeComments("[link](javascript:alert(1))"))
+	require.Equal(t, "[link]( #alert(1))", SanitizeComments("[link]( JavaScript :alert(1))"))
+	require.Equal(t, "text\n[id]: #alert(1)", SanitizeComments("text\n[id]: javascript:alert(1)"))
+}
+
+func TestValidateComments(t *testing.T) {
+	require.NoError(t, ValidateComments("", 0))
+	require.NoError(t, ValidateComments(strings.Repeat("x", 100), 0))
+	require.NoError(t, ValidateComments(strings.Repeat("é", 10), 10))
+	RequireError(t, ValidateComments(strings.Repeat("x", 11), 10), "comments too long, maximum length is 10 characters")
+}
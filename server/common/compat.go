@@ -60,16 +60,16 @@ func UnmarshalUpload(bytes []byte, upload *Upload) (version int, err error) {
 	upload.Login = uploadV1.Login
 	upload.Password = uploadV1.Password
 
-	return 1, nil
+	return APIVersion1, nil
 }
 
-// MarshalUpload unmarshal upload if version is (1) marshal using UploadV1 format
+// MarshalUpload marshal upload in the current format, if version is APIVersion1 marshal using UploadV1 format
 func MarshalUpload(upload *Upload, version int) (bytes []byte, err error) {
-	if version == 0 {
+	if version == 0 || version == APIVersion2 {
 		return json.Marshal(upload)
 	}
 
-	if version == 1 {
+	if version == APIVersion1 {
 		uploadV1 := &UploadV1{}
 
 		uploadV1.ID = upload.ID
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -35,28 +36,147 @@ type Configuration struct {
 	MaxFileSize      int64  `json:"maxFileSize"`
 	MaxFilePerUpload int    `json:"maxFilePerUpload"`
 
+	MaxArchiveSizeStr string `json:"-"`
+	MaxArchiveSize    int64  `json:"maxArchiveSize"`
+	MaxFilesInArchive int    `json:"maxFilesInArchive"`
+
+	MaxUserSizeStr       string `json:"-"`
+	MaxUserSize          int64  `json:"maxUserSize"`
+	QuotaExceededPolicy  string `json:"-"`
+	QuotaEvictionWebhook string `json:"-"`
+
+	MaxDownloadBytesPerSecond int64  `json:"maxDownloadBytesPerSecond"`
+	DownloadIdleTimeout       string `json:"-"`
+
+	MaxUserMetadataSize int `json:"maxUserMetadataSize"`
+	MaxCommentLength    int `json:"maxCommentLength"`
+
+	MaxFilenameLength     int  `json:"maxFilenameLength"`
+	TruncateLongFilenames bool `json:"-"`
+
+	MaxUploadMetadataBytes int `json:"maxUploadMetadataBytes"`
+
+	MaxConnectionsPerIP int `json:"-"`
+
+	OneShotResumeWindow string `json:"-"`
+	RevealGoneReason    bool   `json:"-"`
+	ExpiredPageURL      string `json:"-"`
+
+	ManagementLinkSecret   string `json:"-"`
+	ManagementLinkValidity string `json:"-"`
+
+	MaxUploadPasswordAttempts   int    `json:"-"`
+	UploadPasswordLockout       string `json:"-"`
+	UploadPasswordAttemptsPerIP bool   `json:"-"`
+
+	CaseInsensitiveUploadIDs bool `json:"-"`
+
+	VerifyAfterWrite      bool `json:"-"`
+	RequireUploadChecksum bool `json:"-"`
+
+	GenerateThumbnails bool `json:"generateThumbnails"`
+	ThumbnailSize      int  `json:"thumbnailSize"`
+
+	DetectMediaMetadata bool `json:"-"`
+
+	ContentEncodingPassthrough bool `json:"contentEncodingPassthrough"`
+
+	ExpandArchiveOnUpload bool `json:"expandArchiveOnUpload"`
+	ExpandArchiveMaxRatio int  `json:"-"`
+
+	ExpiryWarningLeadTime string `json:"-"`
+	ExpiryWarningWebhook  string `json:"-"`
+
+	DownloadNotificationWebhook string `json:"-"`
+	DownloadNotificationWindow  string `json:"-"`
+
+	UploadReadyWebhook string `json:"-"`
+
+	UploadWebhookAllowlist []string `json:"-"`
+
+	DeleteRetryBackoff          string `json:"-"`
+	DeleteFailureAlertThreshold int    `json:"-"`
+	DeleteFailureWebhook        string `json:"-"`
+
+	InactiveUserRetention       string `json:"-"`
+	InactiveUserWarningLeadTime string `json:"-"`
+	InactiveUserWebhook         string `json:"-"`
+
 	DefaultTTLStr string `json:"-"`
 	DefaultTTL    int    `json:"defaultTTL"`
+	MinTTLStr     string `json:"-"`
+	MinTTL        int    `json:"minTTL"`
 	MaxTTLStr     string `json:"-"`
 	MaxTTL        int    `json:"maxTTL"`
 
+	AuthenticatedMaxTTLStr string `json:"-"`
+	AuthenticatedMaxTTL    int    `json:"authenticatedMaxTTL"`
+
 	SslEnabled bool   `json:"-"`
 	SslCert    string `json:"-"`
 	SslKey     string `json:"-"`
 
 	NoWebInterface      bool     `json:"-"`
+	RootRedirectURL     string   `json:"-"`
 	DownloadDomain      string   `json:"downloadDomain"`
 	DownloadDomainAlias []string `json:"downloadDomainAlias"`
+	DownloadDomains     []string `json:"downloadDomains,omitempty"`
 	EnhancedWebSecurity bool     `json:"-"`
 	SessionTimeout      string   `json:"-"`
 	AbuseContact        string   `json:"abuseContact"`
+	ServerBanner        string   `json:"serverBanner"`
 	WebappDirectory     string   `json:"-"`
 	ClientsDirectory    string   `json:"-"`
 	ChangelogDirectory  string   `json:"-"`
 
+	UserExportReauthWindow string `json:"-"`
+
+	ContentSecurityPolicy         string `json:"-"`
+	DownloadContentSecurityPolicy string `json:"-"`
+	FrameOptions                  string `json:"-"`
+	ReferrerPolicy                string `json:"-"`
+
+	DefaultContentDisposition string            `json:"-"`
+	ContentDispositions       map[string]string `json:"-"`
+
+	FileNameCollisionPolicy string `json:"-"`
+	DefaultFilename         string `json:"defaultFilename,omitempty"`
+
 	SourceIPHeader  string   `json:"-"`
 	UploadWhitelist []string `json:"-"`
 
+	AnonymizeClientIP bool `json:"-"`
+
+	UseForwardedHeaders bool     `json:"-"`
+	TrustedProxies      []string `json:"-"`
+
+	RequireAuthForDownload bool `json:"requireAuthForDownload"`
+
+	WebDAVEnabled bool `json:"webdavEnabled"`
+
+	TTLFromCompletion bool `json:"ttlFromCompletion"`
+
+	DisabledFeatures map[string]bool `json:"disabledFeatures,omitempty"`
+
+	CaptchaProvider  string `json:"captchaProvider,omitempty"`
+	CaptchaSiteKey   string `json:"captchaSiteKey,omitempty"`
+	CaptchaSecret    string `json:"-"`
+	CaptchaVerifyURL string `json:"-"`
+
+	RequireTOSAcceptance bool   `json:"requireTOSAcceptance"`
+	TOSVersion           string `json:"tosVersion,omitempty"`
+	TOSURL               string `json:"tosURL,omitempty"`
+
+	AuthorizationWebhookURL      string `json:"-"`
+	AuthorizationWebhookFailOpen bool   `json:"-"`
+
+	TrustedCABundle string `json:"-"`
+
+	GeoIPDatabase    string   `json:"-"`
+	GeoIPFailOpen    bool     `json:"-"`
+	AllowedCountries []string `json:"-"`
+	BlockedCountries []string `json:"-"`
+
 	// Feature Flags
 	FeatureAuthentication string `json:"feature_authentication"`
 	FeatureOneShot        string `json:"feature_one_shot"`
@@ -69,6 +189,11 @@ type Configuration struct {
 	FeatureClients        string `json:"feature_clients"`
 	FeatureGithub         string `json:"feature_github"`
 
+	// Upload defaults ( shortcuts to upgrade the matching feature flags )
+	DefaultOneShot   bool `json:"-"`
+	DefaultRemovable bool `json:"-"`
+	ForceOneShot     bool `json:"-"`
+
 	// Deprecated Feature Flags
 	Authentication      bool `json:"authentication"`      // Deprecated: >1.3.6
 	NoAnonymousUploads  bool `json:"noAnonymousUploads"`  // Deprecated: >1.3.6
@@ -81,6 +206,9 @@ type Configuration struct {
 	GoogleAPISecret      string   `json:"-"`
 	GoogleAPIClientID    string   `json:"-"`
 	GoogleValidDomains   []string `json:"-"`
+	AllowedRedirectURLs  []string `json:"-"`
+	AllowedEmailDomains  []string `json:"-"`
+	AutoProvisionUsers   bool     `json:"-"`
 	OvhAuthentication    bool     `json:"ovhAuthentication"`
 	OvhAPIEndpoint       string   `json:"ovhApiEndpoint"`
 	OvhAPIKey            string   `json:"-"`
@@ -90,12 +218,43 @@ type Configuration struct {
 
 	DataBackend       string                 `json:"-"`
 	DataBackendConfig map[string]interface{} `json:"-"`
-
-	downloadDomainURL      *url.URL
-	downloadDomainURLAlias []*url.URL
-	uploadWhitelist        []*net.IPNet
-	clean                  bool
-	sessionTimeout         int
+	DataBackends      []*DataBackendRoute    `json:"dataBackends,omitempty"`
+
+	DataBackendWriteTimeout string `json:"-"`
+	DataBackendMaxRetries   int    `json:"-"`
+
+	DataBackendCircuitBreakerThreshold int    `json:"-"`
+	DataBackendCircuitBreakerCooldown  string `json:"-"`
+
+	DataBackendReadBufferSize int `json:"-"`
+
+	ClientApps []*ClientApp `json:"-"`
+
+	UploadPresets []*UploadPreset `json:"uploadPresets,omitempty"`
+
+	allowedRedirectURLs     []*url.URL
+	uploadWebhookAllowlist  []*url.URL
+	downloadDomainURL       *url.URL
+	downloadDomainURLAlias  []*url.URL
+	downloadDomainsURL      []*url.URL
+	uploadWhitelist         []*net.IPNet
+	trustedProxies          []*net.IPNet
+	clean                   bool
+	sessionTimeout          int
+	userExportReauthWindow  int
+	oneShotResumeWindow     int
+	uploadPasswordLockout   int
+	managementLinkValidity  int
+	dataBackendWriteTimeout int
+	downloadIdleTimeout     int
+	dataBackendCooldown     int
+	expiryWarningLeadTime   int
+	downloadNotifWindow     int
+	deleteRetryBackoff      int
+	inactiveUserRetention   int
+	inactiveUserWarning     int
+	httpTransport           *http.Transport
+	geoIPDatabase           *GeoIPDatabase
 }
 
 // NewConfiguration creates a new configuration
@@ -106,11 +265,36 @@ func NewConfiguration() (config *Configuration) {
 
 	config.ListenAddress = "0.0.0.0"
 	config.ListenPort = 8080
+	config.AutoProvisionUsers = true
 	config.EnhancedWebSecurity = false
+	config.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	config.DownloadContentSecurityPolicy = DefaultDownloadContentSecurityPolicy
+	config.FrameOptions = DefaultFrameOptions
+	config.ReferrerPolicy = DefaultReferrerPolicy
+	config.DefaultContentDisposition = ContentDispositionInline
+	config.FileNameCollisionPolicy = FileNameCollisionFirst
+	config.QuotaExceededPolicy = QuotaExceededReject
 	config.SessionTimeout = "365d"
+	config.UserExportReauthWindow = "15m"
 
 	config.MaxFileSize = 10000000000 // 10GB
 	config.MaxFilePerUpload = 1000
+	config.MaxUserMetadataSize = 4096
+	config.MaxCommentLength = 10000
+	config.MaxFilenameLength = 1024
+	config.OneShotResumeWindow = "0"
+	config.UploadPasswordLockout = "15m"
+	config.ManagementLinkValidity = "30d"
+	config.DataBackendWriteTimeout = "0"
+	config.DownloadIdleTimeout = "0"
+	config.DataBackendCircuitBreakerCooldown = "30s"
+	config.DataBackendReadBufferSize = 1048576 // 1MB
+	config.DeleteRetryBackoff = "1h"
+	config.DownloadNotificationWindow = "1h"
+	config.DeleteFailureAlertThreshold = 5
+	config.InactiveUserWarningLeadTime = "7d"
+	config.ThumbnailSize = DefaultThumbnailSize
+	config.ExpandArchiveMaxRatio = 100
 
 	config.DefaultTTL = 2592000 // 30 days
 	config.MaxTTL = 2592000     // 30 days
@@ -191,11 +375,60 @@ func (config *Configuration) Initialize() (err error) {
 		}
 	}
 
+	err = config.initializeForwardedHeaders()
+	if err != nil {
+		return err
+	}
+
 	err = config.initializeFeatureFlags()
 	if err != nil {
 		return err
 	}
 
+	if config.RequireAuthForDownload && config.FeatureAuthentication == FeatureDisabled {
+		return fmt.Errorf("RequireAuthForDownload needs FeatureAuthentication to be enabled")
+	}
+
+	if config.WebDAVEnabled && config.FeatureAuthentication == FeatureDisabled {
+		return fmt.Errorf("WebDAVEnabled needs FeatureAuthentication to be enabled")
+	}
+
+	err = config.initializeTrustedCABundle()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeCaptcha()
+	if err != nil {
+		return err
+	}
+
+	if config.RequireTOSAcceptance && config.TOSVersion == "" {
+		return fmt.Errorf("RequireTOSAcceptance needs a TOSVersion")
+	}
+
+	err = config.initializeGeoIP()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeAuthorizationWebhook()
+	if err != nil {
+		return err
+	}
+
+	config.initializeSecurityHeaders()
+
+	err = config.initializeRedirectURLs()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeAllowedEmailDomains()
+	if err != nil {
+		return err
+	}
+
 	config.GoogleAuthentication = config.FeatureAuthentication != FeatureDisabled && config.GoogleAPIClientID != "" && config.GoogleAPISecret != ""
 	config.OvhAuthentication = config.FeatureAuthentication != FeatureDisabled && config.OvhAPIKey != "" && config.OvhAPISecret != ""
 
@@ -213,6 +446,36 @@ func (config *Configuration) Initialize() (err error) {
 				config.downloadDomainURLAlias = append(config.downloadDomainURLAlias, domainAlias)
 			}
 		}
+
+		for _, domain := range config.DownloadDomains {
+			if domainURL, err := url.Parse(domain); err != nil {
+				return fmt.Errorf("invalid download domain URL %s : %s", domain, err)
+			} else {
+				config.downloadDomainsURL = append(config.downloadDomainsURL, domainURL)
+			}
+		}
+	} else if len(config.DownloadDomains) > 0 {
+		return fmt.Errorf("DownloadDomains needs a default DownloadDomain")
+	}
+
+	if config.RootRedirectURL != "" {
+		rootRedirectURL, err := url.Parse(config.RootRedirectURL)
+		if err != nil {
+			return fmt.Errorf("invalid root redirect URL %s : %s", config.RootRedirectURL, err)
+		}
+		if (rootRedirectURL.Scheme != "http" && rootRedirectURL.Scheme != "https") || rootRedirectURL.Host == "" {
+			return fmt.Errorf("invalid root redirect URL %s : expected an absolute http(s) URL", config.RootRedirectURL)
+		}
+	}
+
+	if config.ExpiredPageURL != "" {
+		expiredPageURL, err := url.Parse(config.ExpiredPageURL)
+		if err != nil {
+			return fmt.Errorf("invalid expired page URL %s : %s", config.ExpiredPageURL, err)
+		}
+		if (expiredPageURL.Scheme != "http" && expiredPageURL.Scheme != "https") || expiredPageURL.Host == "" {
+			return fmt.Errorf("invalid expired page URL %s : expected an absolute http(s) URL", config.ExpiredPageURL)
+		}
 	}
 
 	if config.MaxFileSizeStr != "" {
@@ -223,6 +486,34 @@ func (config *Configuration) Initialize() (err error) {
 		config.MaxFileSize = int64(maxFileSize)
 	}
 
+	if config.MaxArchiveSizeStr != "" {
+		maxArchiveSize, err := humanize.ParseBytes(config.MaxArchiveSizeStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse MaxArchiveSizeStr : %s", err)
+		}
+		config.MaxArchiveSize = int64(maxArchiveSize)
+	}
+
+	if config.MaxArchiveSize < 0 {
+		return fmt.Errorf("invalid negative value for MaxArchiveSize")
+	}
+
+	if config.MaxFilesInArchive < 0 {
+		return fmt.Errorf("invalid negative value for MaxFilesInArchive")
+	}
+
+	if config.MaxDownloadBytesPerSecond < 0 {
+		return fmt.Errorf("invalid negative value for MaxDownloadBytesPerSecond")
+	}
+
+	config.downloadIdleTimeout, err = ParseTTL(config.DownloadIdleTimeout)
+	if err != nil {
+		return fmt.Errorf("unable to parse DownloadIdleTimeout : %s", err)
+	}
+	if config.downloadIdleTimeout < 0 {
+		return fmt.Errorf("invalid negative value for DownloadIdleTimeout")
+	}
+
 	if config.DefaultTTLStr != "" {
 		config.DefaultTTL, err = ParseTTL(config.DefaultTTLStr)
 		if err != nil {
@@ -241,6 +532,42 @@ func (config *Configuration) Initialize() (err error) {
 		return fmt.Errorf("DefaultTTL should not be more than MaxTTL")
 	}
 
+	if config.MinTTLStr != "" {
+		config.MinTTL, err = ParseTTL(config.MinTTLStr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.MinTTL < 0 {
+		return fmt.Errorf("invalid negative value for MinTTL")
+	}
+
+	if config.MinTTL > 0 {
+		if config.DefaultTTL > 0 && config.DefaultTTL < config.MinTTL {
+			return fmt.Errorf("DefaultTTL should not be less than MinTTL")
+		}
+		if config.MaxTTL > 0 && config.MaxTTL < config.MinTTL {
+			return fmt.Errorf("MaxTTL should not be less than MinTTL")
+		}
+	}
+
+	if config.AuthenticatedMaxTTLStr != "" {
+		config.AuthenticatedMaxTTL, err = ParseTTL(config.AuthenticatedMaxTTLStr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.AuthenticatedMaxTTL > 0 {
+		if config.DefaultTTL > config.AuthenticatedMaxTTL {
+			return fmt.Errorf("DefaultTTL should not be more than AuthenticatedMaxTTL")
+		}
+		if config.MaxTTL <= 0 || config.MaxTTL > config.AuthenticatedMaxTTL {
+			return fmt.Errorf("AuthenticatedMaxTTL should not be less than MaxTTL")
+		}
+	}
+
 	config.sessionTimeout, err = ParseTTL(config.SessionTimeout)
 	if err != nil {
 		return fmt.Errorf("unable to parse SessionTimeout : %s", err)
@@ -249,6 +576,181 @@ func (config *Configuration) Initialize() (err error) {
 		return fmt.Errorf("invalid negative or zero value for SessionTimeout")
 	}
 
+	config.userExportReauthWindow, err = ParseTTL(config.UserExportReauthWindow)
+	if err != nil {
+		return fmt.Errorf("unable to parse UserExportReauthWindow : %s", err)
+	}
+	if config.userExportReauthWindow <= 0 {
+		return fmt.Errorf("invalid negative or zero value for UserExportReauthWindow")
+	}
+
+	config.oneShotResumeWindow, err = ParseTTL(config.OneShotResumeWindow)
+	if err != nil {
+		return fmt.Errorf("unable to parse OneShotResumeWindow : %s", err)
+	}
+
+	if config.ManagementLinkSecret != "" {
+		config.managementLinkValidity, err = ParseTTL(config.ManagementLinkValidity)
+		if err != nil {
+			return fmt.Errorf("unable to parse ManagementLinkValidity : %s", err)
+		}
+		if config.managementLinkValidity <= 0 {
+			return fmt.Errorf("invalid negative or zero value for ManagementLinkValidity")
+		}
+	}
+
+	if config.MaxUploadPasswordAttempts < 0 {
+		return fmt.Errorf("invalid negative value for MaxUploadPasswordAttempts")
+	}
+
+	config.uploadPasswordLockout, err = ParseTTL(config.UploadPasswordLockout)
+	if err != nil {
+		return fmt.Errorf("unable to parse UploadPasswordLockout : %s", err)
+	}
+	if config.MaxUploadPasswordAttempts > 0 && config.uploadPasswordLockout <= 0 {
+		return fmt.Errorf("invalid negative or zero value for UploadPasswordLockout")
+	}
+
+	config.dataBackendWriteTimeout, err = ParseTTL(config.DataBackendWriteTimeout)
+	if err != nil {
+		return fmt.Errorf("unable to parse DataBackendWriteTimeout : %s", err)
+	}
+	if config.dataBackendWriteTimeout < 0 {
+		return fmt.Errorf("invalid negative value for DataBackendWriteTimeout")
+	}
+
+	if config.MaxUserMetadataSize < 0 {
+		return fmt.Errorf("invalid negative value for MaxUserMetadataSize")
+	}
+
+	if config.MaxCommentLength < 0 {
+		return fmt.Errorf("invalid negative value for MaxCommentLength")
+	}
+
+	if config.MaxFilenameLength <= 0 {
+		return fmt.Errorf("invalid MaxFilenameLength, expected a positive value")
+	}
+
+	if config.MaxUploadMetadataBytes < 0 {
+		return fmt.Errorf("invalid negative value for MaxUploadMetadataBytes")
+	}
+
+	if config.ExpandArchiveMaxRatio <= 0 {
+		return fmt.Errorf("invalid negative or zero value for ExpandArchiveMaxRatio")
+	}
+
+	if config.MaxConnectionsPerIP < 0 {
+		return fmt.Errorf("invalid negative value for MaxConnectionsPerIP")
+	}
+
+	if config.DataBackendMaxRetries < 0 {
+		return fmt.Errorf("invalid negative value for DataBackendMaxRetries")
+	}
+
+	if config.DataBackendCircuitBreakerThreshold < 0 {
+		return fmt.Errorf("invalid negative value for DataBackendCircuitBreakerThreshold")
+	}
+
+	if config.DataBackendReadBufferSize < 0 {
+		return fmt.Errorf("invalid negative value for DataBackendReadBufferSize")
+	}
+
+	config.dataBackendCooldown, err = ParseTTL(config.DataBackendCircuitBreakerCooldown)
+	if err != nil {
+		return fmt.Errorf("unable to parse DataBackendCircuitBreakerCooldown : %s", err)
+	}
+	if config.dataBackendCooldown < 0 {
+		return fmt.Errorf("invalid negative value for DataBackendCircuitBreakerCooldown")
+	}
+
+	err = config.initializeUploadWebhooks()
+	if err != nil {
+		return err
+	}
+
+	if config.ExpiryWarningLeadTime != "" {
+		config.expiryWarningLeadTime, err = ParseTTL(config.ExpiryWarningLeadTime)
+		if err != nil {
+			return fmt.Errorf("unable to parse ExpiryWarningLeadTime : %s", err)
+		}
+		if config.expiryWarningLeadTime < 0 {
+			return fmt.Errorf("invalid negative value for ExpiryWarningLeadTime")
+		}
+		if config.expiryWarningLeadTime > 0 && config.ExpiryWarningWebhook == "" && !config.IsUploadWebhookEnabled() {
+			return fmt.Errorf("ExpiryWarningLeadTime needs an ExpiryWarningWebhook or an UploadWebhookAllowlist")
+		}
+	}
+
+	config.downloadNotifWindow, err = ParseTTL(config.DownloadNotificationWindow)
+	if err != nil {
+		return fmt.Errorf("unable to parse DownloadNotificationWindow : %s", err)
+	}
+	if config.downloadNotifWindow < 0 {
+		return fmt.Errorf("invalid negative value for DownloadNotificationWindow")
+	}
+
+	config.deleteRetryBackoff, err = ParseTTL(config.DeleteRetryBackoff)
+	if err != nil {
+		return fmt.Errorf("unable to parse DeleteRetryBackoff : %s", err)
+	}
+	if config.deleteRetryBackoff < 0 {
+		return fmt.Errorf("invalid negative value for DeleteRetryBackoff")
+	}
+
+	if config.DeleteFailureAlertThreshold < 0 {
+		return fmt.Errorf("invalid negative value for DeleteFailureAlertThreshold")
+	}
+
+	err = config.initializeInactiveUserRetention()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeThumbnails()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeContentDispositions()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeDisabledFeatures()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeFileNameCollisionPolicy()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeUserQuota()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeDefaultFilename()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeDataBackendRoutes()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeClientApps()
+	if err != nil {
+		return err
+	}
+
+	err = config.initializeUploadPresets()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -271,6 +773,25 @@ func (config *Configuration) GetDownloadDomain() *url.URL {
 	return config.downloadDomainURL
 }
 
+// IsSelectableDownloadDomain return weather or not an upload can be pinned to the download domain
+func (config *Configuration) IsSelectableDownloadDomain(domain string) bool {
+	if config.DownloadDomain == "" {
+		return false
+	}
+
+	if domain == config.DownloadDomain {
+		return true
+	}
+
+	for _, d := range config.DownloadDomains {
+		if domain == d {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsValidDownloadDomain return weather or not the host is a valid download domain
 func (config *Configuration) IsValidDownloadDomain(host string) bool {
 	if config.downloadDomainURL == nil {
@@ -288,6 +809,13 @@ func (config *Configuration) IsValidDownloadDomain(host string) bool {
 		}
 	}
 
+	// Check if the host is one of the download domains uploads can be pinned to
+	for _, domainURL := range config.downloadDomainsURL {
+		if domainURL.Host == host {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -355,6 +883,107 @@ func (config *Configuration) GetSessionTimeout() int {
 	return config.sessionTimeout
 }
 
+// GetDownloadIdleTimeout return how long a download may last without writing data to the client ( 0 : no timeout )
+func (config *Configuration) GetDownloadIdleTimeout() time.Duration {
+	return time.Duration(config.downloadIdleTimeout) * time.Second
+}
+
+// GetDataBackendWriteTimeout return how long a data backend may spend on a write attempt ( 0 : no timeout )
+func (config *Configuration) GetDataBackendWriteTimeout() time.Duration {
+	return time.Duration(config.dataBackendWriteTimeout) * time.Second
+}
+
+// GetDataBackendCircuitBreakerCooldown return how long the data backend operations fail fast once the circuit breaker is open
+func (config *Configuration) GetDataBackendCircuitBreakerCooldown() time.Duration {
+	return time.Duration(config.dataBackendCooldown) * time.Second
+}
+
+// GetOneShotResumeWindow return how long an interrupted OneShot download can be resumed
+func (config *Configuration) GetOneShotResumeWindow() time.Duration {
+	return time.Duration(config.oneShotResumeWindow) * time.Second
+}
+
+// GetUserExportReauthWindow return how long after logging in users can export all their uploads
+func (config *Configuration) GetUserExportReauthWindow() time.Duration {
+	return time.Duration(config.userExportReauthWindow) * time.Second
+}
+
+// GetManagementLinkValidity return how long the signed management links returned on upload creation can be used
+func (config *Configuration) GetManagementLinkValidity() time.Duration {
+	return time.Duration(config.managementLinkValidity) * time.Second
+}
+
+// GetUploadPasswordLockout return how long password protected uploads are locked after MaxUploadPasswordAttempts failures
+func (config *Configuration) GetUploadPasswordLockout() time.Duration {
+	return time.Duration(config.uploadPasswordLockout) * time.Second
+}
+
+// GetExpiryWarningLeadTime return how long before their expiration date uploads expiry warnings are sent ( 0 : disabled )
+func (config *Configuration) GetExpiryWarningLeadTime() time.Duration {
+	return time.Duration(config.expiryWarningLeadTime) * time.Second
+}
+
+// GetDownloadNotificationWindow return how long downloads are aggregated before being notified ( 0 : notified as soon as possible )
+func (config *Configuration) GetDownloadNotificationWindow() time.Duration {
+	return time.Duration(config.downloadNotifWindow) * time.Second
+}
+
+// NormalizeUploadID return the canonical form of an upload ID, upload IDs are lower case if CaseInsensitiveUploadIDs is enabled
+func (config *Configuration) NormalizeUploadID(uploadID string) string {
+	if config.CaseInsensitiveUploadIDs {
+		return strings.ToLower(uploadID)
+	}
+	return uploadID
+}
+
+// GetDeleteRetryBackoff return the delay before retrying to delete a file from the data backend ( 0 : next cleaning run )
+func (config *Configuration) GetDeleteRetryBackoff() time.Duration {
+	return time.Duration(config.deleteRetryBackoff) * time.Second
+}
+
+// GetInactiveUserRetention return how long users can stay inactive before their account is deleted ( 0 : disabled )
+func (config *Configuration) GetInactiveUserRetention() time.Duration {
+	return time.Duration(config.inactiveUserRetention) * time.Second
+}
+
+// GetInactiveUserWarningLeadTime return how long before their account is deleted inactive users are warned
+func (config *Configuration) GetInactiveUserWarningLeadTime() time.Duration {
+	return time.Duration(config.inactiveUserWarning) * time.Second
+}
+
+func (config *Configuration) initializeInactiveUserRetention() (err error) {
+	if config.InactiveUserRetention == "" {
+		return nil
+	}
+
+	config.inactiveUserRetention, err = ParseTTL(config.InactiveUserRetention)
+	if err != nil {
+		return fmt.Errorf("unable to parse InactiveUserRetention : %s", err)
+	}
+	if config.inactiveUserRetention < 0 {
+		return fmt.Errorf("invalid negative value for InactiveUserRetention")
+	}
+	if config.inactiveUserRetention == 0 {
+		return nil
+	}
+
+	config.inactiveUserWarning, err = ParseTTL(config.InactiveUserWarningLeadTime)
+	if err != nil {
+		return fmt.Errorf("unable to parse InactiveUserWarningLeadTime : %s", err)
+	}
+	if config.inactiveUserWarning <= 0 {
+		return fmt.Errorf("invalid negative or zero value for InactiveUserWarningLeadTime")
+	}
+	if config.inactiveUserWarning >= config.inactiveUserRetention {
+		return fmt.Errorf("InactiveUserWarningLeadTime must be shorter than InactiveUserRetention")
+	}
+	if config.InactiveUserWebhook == "" {
+		return fmt.Errorf("InactiveUserRetention needs an InactiveUserWebhook")
+	}
+
+	return nil
+}
+
 func (config *Configuration) String() string {
 	str := ""
 	if config.DownloadDomain != "" {
@@ -362,23 +991,103 @@ func (config *Configuration) String() string {
 		if len(config.DownloadDomainAlias) > 0 {
 			str += fmt.Sprintf("Download domain alias: %v\n", config.DownloadDomainAlias)
 		}
+		if len(config.DownloadDomains) > 0 {
+			str += fmt.Sprintf("Download domains : %v\n", config.DownloadDomains)
+		}
 	}
 
 	str += fmt.Sprintf("Maximum file size : %s\n", humanize.Bytes(uint64(config.MaxFileSize)))
 	str += fmt.Sprintf("Maximum files per upload : %d\n", config.MaxFilePerUpload)
 
+	if config.MaxArchiveSize > 0 {
+		str += fmt.Sprintf("Maximum archive size : %s\n", humanize.Bytes(uint64(config.MaxArchiveSize)))
+	}
+
+	if config.MaxFilesInArchive > 0 {
+		str += fmt.Sprintf("Maximum files per archive : %d\n", config.MaxFilesInArchive)
+	}
+
+	if config.MaxDownloadBytesPerSecond > 0 {
+		str += fmt.Sprintf("Maximum download bandwidth : %s/s\n", humanize.Bytes(uint64(config.MaxDownloadBytesPerSecond)))
+	}
+
 	if config.DefaultTTL > 0 {
 		str += fmt.Sprintf("Default upload TTL : %s\n", HumanDuration(time.Duration(config.DefaultTTL)*time.Second))
 	} else {
 		str += fmt.Sprintf("Default upload TTL : unlimited\n")
 	}
 
+	if config.MinTTL > 0 {
+		str += fmt.Sprintf("Minimum upload TTL : %s\n", HumanDuration(time.Duration(config.MinTTL)*time.Second))
+	}
+
 	if config.MaxTTL > 0 {
 		str += fmt.Sprintf("Maximum upload TTL : %s\n", HumanDuration(time.Duration(config.MaxTTL)*time.Second))
 	} else {
 		str += fmt.Sprintf("Maximum upload TTL : unlimited\n")
 	}
 
+	if config.AuthenticatedMaxTTL > 0 {
+		str += fmt.Sprintf("Maximum authenticated upload TTL : %s\n", HumanDuration(time.Duration(config.AuthenticatedMaxTTL)*time.Second))
+	} else if config.AuthenticatedMaxTTL < 0 {
+		str += fmt.Sprintf("Maximum authenticated upload TTL : unlimited\n")
+	}
+
+	if config.VerifyAfterWrite {
+		str += fmt.Sprintf("Verify files after write : enabled\n")
+	}
+
+	if config.RequireUploadChecksum {
+		str += fmt.Sprintf("Require upload checksum : enabled\n")
+	}
+
+	if config.UseForwardedHeaders {
+		str += fmt.Sprintf("Forwarded headers : trusted from %s\n", strings.Join(config.TrustedProxies, ", "))
+	}
+	if config.AnonymizeClientIP {
+		str += fmt.Sprintf("Client IP anonymization : enabled\n")
+	}
+
+	if config.ManagementLinkSecret != "" {
+		str += fmt.Sprintf("Management links : valid %s\n", HumanDuration(config.GetManagementLinkValidity()))
+	}
+
+	if config.MaxUploadPasswordAttempts > 0 {
+		str += fmt.Sprintf("Upload password attempts : %d then locked for %s\n", config.MaxUploadPasswordAttempts, HumanDuration(config.GetUploadPasswordLockout()))
+	}
+
+	if config.ExpandArchiveOnUpload {
+		str += fmt.Sprintf("Expand archives on upload : enabled ( maximum ratio %d )\n", config.ExpandArchiveMaxRatio)
+	}
+
+	if config.expiryWarningLeadTime > 0 {
+		str += fmt.Sprintf("Expiry warning lead time : %s\n", HumanDuration(config.GetExpiryWarningLeadTime()))
+	}
+
+	if config.inactiveUserRetention > 0 {
+		str += fmt.Sprintf("Inactive user retention : %s ( warned %s before )\n", HumanDuration(config.GetInactiveUserRetention()), HumanDuration(config.GetInactiveUserWarningLeadTime()))
+	}
+
+	if config.IsUploadWebhookEnabled() {
+		str += fmt.Sprintf("Upload webhook allowlist : %s\n", strings.Join(config.UploadWebhookAllowlist, ", "))
+	}
+
+	if config.DownloadNotificationWebhook != "" {
+		str += fmt.Sprintf("Download notification window : %s\n", HumanDuration(config.GetDownloadNotificationWindow()))
+	}
+
+	for _, route := range config.DataBackends {
+		str += fmt.Sprintf("Data backend %s : %s\n", route.Name, route.Backend)
+	}
+
+	for _, app := range config.ClientApps {
+		str += fmt.Sprintf("Client app : %s\n", app.Name)
+	}
+
+	for _, preset := range config.UploadPresets {
+		str += fmt.Sprintf("Upload preset : %s\n", preset.Name)
+	}
+
 	str += fmt.Sprintf("One shot upload : %s\n", config.FeatureOneShot)
 	str += fmt.Sprintf("Removable upload : %s\n", config.FeatureRemovable)
 	str += fmt.Sprintf("Streaming upload : %s\n", config.FeatureStream)
@@ -388,6 +1097,30 @@ func (config *Configuration) String() string {
 	str += fmt.Sprintf("Upload extend TTL : %s\n", config.FeatureExtendTTL)
 
 	str += fmt.Sprintf("Authentication : %s\n", config.FeatureAuthentication)
+	if config.RequireAuthForDownload {
+		str += fmt.Sprintf("Download requires authentication : enabled\n")
+	}
+	if config.WebDAVEnabled {
+		str += fmt.Sprintf("WebDAV : enabled\n")
+	}
+	if config.CaptchaProvider != "" {
+		str += fmt.Sprintf("Anonymous upload captcha : %s\n", config.CaptchaProvider)
+	}
+	if config.RequireTOSAcceptance {
+		str += fmt.Sprintf("Terms of service acceptance : required ( version %s )\n", config.TOSVersion)
+	}
+	if config.TrustedCABundle != "" {
+		str += fmt.Sprintf("Trusted CA bundle : %s\n", config.TrustedCABundle)
+	}
+	if config.GeoIPDatabase != "" {
+		str += fmt.Sprintf("GeoIP database : %s\n", config.GeoIPDatabase)
+	}
+	if len(config.AllowedCountries) > 0 {
+		str += fmt.Sprintf("Default allowed download countries : %s\n", strings.Join(config.AllowedCountries, ","))
+	}
+	if len(config.BlockedCountries) > 0 {
+		str += fmt.Sprintf("Default blocked download countries : %s\n", strings.Join(config.BlockedCountries, ","))
+	}
 	if config.FeatureAuthentication != FeatureDisabled {
 		if config.GoogleAuthentication {
 			str += fmt.Sprintf("Google authentication : enabled\n")
@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/iancoleman/strcase"
 
@@ -84,6 +85,78 @@ func TestInitializeConfigAuthentication(t *testing.T) {
 	require.NoError(t, err, "unable to initialize config")
 }
 
+func TestInitializeConfigRequireAuthForDownload(t *testing.T) {
+	config := NewConfiguration()
+	config.RequireAuthForDownload = true
+
+	err := config.Initialize()
+	RequireError(t, err, "RequireAuthForDownload needs FeatureAuthentication to be enabled")
+
+	config.FeatureAuthentication = FeatureEnabled
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+}
+
+func TestInitializeConfigRequireTOSAcceptance(t *testing.T) {
+	config := NewConfiguration()
+	config.RequireTOSAcceptance = true
+
+	err := config.Initialize()
+	RequireError(t, err, "RequireTOSAcceptance needs a TOSVersion")
+
+	config.TOSVersion = "2024-01"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+}
+
+func TestInitializeConfigWebDAVEnabled(t *testing.T) {
+	config := NewConfiguration()
+	config.WebDAVEnabled = true
+
+	err := config.Initialize()
+	RequireError(t, err, "WebDAVEnabled needs FeatureAuthentication to be enabled")
+
+	config.FeatureAuthentication = FeatureEnabled
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+}
+
+func TestInitializeConfigRootRedirectURL(t *testing.T) {
+	config := NewConfiguration()
+	config.RootRedirectURL = "https://portal.root.gg/plik"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	config.RootRedirectURL = "/relative"
+	err = config.Initialize()
+	RequireError(t, err, "invalid root redirect URL")
+
+	config.RootRedirectURL = "ftp://portal.root.gg"
+	err = config.Initialize()
+	RequireError(t, err, "invalid root redirect URL")
+
+	config.RootRedirectURL = "https://portal.root.gg/%zz"
+	err = config.Initialize()
+	RequireError(t, err, "invalid root redirect URL")
+}
+
+func TestInitializeConfigExpiredPageURL(t *testing.T) {
+	config := NewConfiguration()
+	config.ExpiredPageURL = "https://portal.root.gg/expired.html"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	config.ExpiredPageURL = "/expired.html"
+	err = config.Initialize()
+	RequireError(t, err, "invalid expired page URL")
+
+	config.ExpiredPageURL = "https://portal.root.gg/%zz"
+	err = config.Initialize()
+	RequireError(t, err, "invalid expired page URL")
+}
+
 func TestInitializeConfigDownloadDomain(t *testing.T) {
 	config := NewConfiguration()
 	config.DownloadDomain = "https://dl.plik.root.gg"
@@ -101,6 +174,21 @@ func TestInitializeConfigInvalidDownloadDomain(t *testing.T) {
 	require.Error(t, err, "able to initialize invalid config")
 }
 
+func TestInitializeConfigDownloadDomains(t *testing.T) {
+	config := NewConfiguration()
+	config.DownloadDomains = []string{"https://team.root.gg"}
+
+	err := config.Initialize()
+	RequireError(t, err, "DownloadDomains needs a default DownloadDomain")
+
+	config = NewConfiguration()
+	config.DownloadDomain = "https://dl.plik.root.gg"
+	config.DownloadDomains = []string{":/invalid"}
+
+	err = config.Initialize()
+	RequireError(t, err, "invalid download domain URL :/invalid")
+}
+
 func TestInitializeInvalidDefaultTTL(t *testing.T) {
 	config := NewConfiguration()
 	config.DefaultTTL = 10 * 86400
@@ -119,6 +207,68 @@ func TestInitializeInfiniteMaxTTL(t *testing.T) {
 	require.NoError(t, err, "unable to initialize valid config")
 }
 
+func TestInitializeMinTTL(t *testing.T) {
+	config := NewConfiguration()
+	config.MinTTLStr = "5m"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, 300, config.MinTTL, "invalid min TTL")
+
+	config = NewConfiguration()
+	config.MinTTL = 10 * 86400
+	config.DefaultTTL = 86400
+	config.MaxTTL = 30 * 86400
+	err = config.Initialize()
+	RequireError(t, err, "DefaultTTL should not be less than MinTTL")
+
+	config = NewConfiguration()
+	config.MinTTL = 60 * 86400
+	config.DefaultTTL = -1
+	config.MaxTTL = 30 * 86400
+	err = config.Initialize()
+	RequireError(t, err, "MaxTTL should not be less than MinTTL")
+
+	config = NewConfiguration()
+	config.MinTTL = -1
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for MinTTL")
+}
+
+func TestInitializeAuthenticatedMaxTTL(t *testing.T) {
+	config := NewConfiguration()
+	config.DefaultTTL = 86400
+	config.MaxTTLStr = "1d"
+	config.AuthenticatedMaxTTLStr = "30d"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.Equal(t, 30*86400, config.AuthenticatedMaxTTL, "invalid authenticated max TTL")
+
+	config = NewConfiguration()
+	config.DefaultTTL = 10 * 86400
+	config.MaxTTL = -1
+	config.AuthenticatedMaxTTL = 86400
+
+	err = config.Initialize()
+	RequireError(t, err, "DefaultTTL should not be more than AuthenticatedMaxTTL")
+
+	config = NewConfiguration()
+	config.DefaultTTL = 86400
+	config.MaxTTL = 10 * 86400
+	config.AuthenticatedMaxTTL = 5 * 86400
+
+	err = config.Initialize()
+	RequireError(t, err, "AuthenticatedMaxTTL should not be less than MaxTTL")
+
+	config = NewConfiguration()
+	config.DefaultTTL = 86400
+	config.MaxTTL = -1
+	config.AuthenticatedMaxTTL = 5 * 86400
+
+	err = config.Initialize()
+	RequireError(t, err, "AuthenticatedMaxTTL should not be less than MaxTTL")
+}
+
 func TestInitializeTTLString(t *testing.T) {
 	config := NewConfiguration()
 	config.DefaultTTLStr = "3d"
@@ -141,6 +291,31 @@ func TestInitializeMaxFileSizeString(t *testing.T) {
 	require.Equal(t, int64(100*1000*1000), config.MaxFileSize, "invalid max file size")
 }
 
+func TestInitializeMaxArchiveSizeString(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxArchiveSizeStr = "10 GB"
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.Equal(t, int64(10*1000*1000*1000), config.MaxArchiveSize, "invalid max archive size")
+
+	config.MaxArchiveSizeStr = "foo"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse MaxArchiveSizeStr")
+
+	config.MaxArchiveSizeStr = ""
+	config.MaxArchiveSize = -1
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxArchiveSize")
+}
+
+func TestInitializeMaxFilesInArchive(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxFilesInArchive = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxFilesInArchive")
+}
+
 func TestDisableAutoClean(t *testing.T) {
 	config := NewConfiguration()
 	require.True(t, config.IsAutoClean(), "invalid auto clean status")
@@ -292,6 +467,351 @@ func TestConfiguration_GetSessionTimeout(t *testing.T) {
 	RequireError(t, err, "unable to parse SessionTimeout")
 }
 
+func TestConfiguration_GetUserExportReauthWindow(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Minute, config.GetUserExportReauthWindow())
+
+	config = NewConfiguration()
+	config.UserExportReauthWindow = "1h"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.GetUserExportReauthWindow())
+
+	config = NewConfiguration()
+	config.UserExportReauthWindow = "0"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative or zero value for UserExportReauthWindow")
+
+	config = NewConfiguration()
+	config.UserExportReauthWindow = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse UserExportReauthWindow")
+}
+
+func TestConfiguration_GetOneShotResumeWindow(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, time.Duration(0), config.GetOneShotResumeWindow())
+
+	// OneShot files are consumed as soon as the download starts by default
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetOneShotResumeWindow())
+
+	config = NewConfiguration()
+	config.OneShotResumeWindow = "5m"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, config.GetOneShotResumeWindow())
+
+	config = NewConfiguration()
+	config.OneShotResumeWindow = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse OneShotResumeWindow")
+}
+
+func TestConfiguration_GetDownloadIdleTimeout(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetDownloadIdleTimeout())
+
+	config = NewConfiguration()
+	config.DownloadIdleTimeout = "5m"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, config.GetDownloadIdleTimeout())
+
+	config = NewConfiguration()
+	config.DownloadIdleTimeout = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse DownloadIdleTimeout")
+
+	config = NewConfiguration()
+	config.DownloadIdleTimeout = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DownloadIdleTimeout")
+}
+
+func TestConfiguration_GetDataBackendWriteTimeout(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetDataBackendWriteTimeout())
+
+	config = NewConfiguration()
+	config.DataBackendWriteTimeout = "5m"
+	config.DataBackendMaxRetries = 3
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, config.GetDataBackendWriteTimeout())
+
+	config = NewConfiguration()
+	config.DataBackendWriteTimeout = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse DataBackendWriteTimeout")
+
+	config = NewConfiguration()
+	config.DataBackendWriteTimeout = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DataBackendWriteTimeout")
+
+	config = NewConfiguration()
+	config.DataBackendMaxRetries = -1
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DataBackendMaxRetries")
+}
+
+func TestConfiguration_GetDataBackendCircuitBreakerCooldown(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, config.GetDataBackendCircuitBreakerCooldown())
+
+	config = NewConfiguration()
+	config.DataBackendCircuitBreakerThreshold = 5
+	config.DataBackendCircuitBreakerCooldown = "2m"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, config.GetDataBackendCircuitBreakerCooldown())
+
+	config = NewConfiguration()
+	config.DataBackendCircuitBreakerCooldown = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse DataBackendCircuitBreakerCooldown")
+
+	config = NewConfiguration()
+	config.DataBackendCircuitBreakerThreshold = -1
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DataBackendCircuitBreakerThreshold")
+}
+
+func TestConfiguration_DataBackendReadBufferSize(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, 1048576, config.DataBackendReadBufferSize)
+
+	config.DataBackendReadBufferSize = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for DataBackendReadBufferSize")
+}
+
+func TestConfiguration_GetExpiryWarningLeadTime(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetExpiryWarningLeadTime())
+
+	config = NewConfiguration()
+	config.ExpiryWarningLeadTime = "24h"
+	config.ExpiryWarningWebhook = "https://hooks.root.gg/plik"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, config.GetExpiryWarningLeadTime())
+
+	config = NewConfiguration()
+	config.ExpiryWarningLeadTime = "24h"
+	err = config.Initialize()
+	RequireError(t, err, "ExpiryWarningLeadTime needs an ExpiryWarningWebhook or an UploadWebhookAllowlist")
+
+	config = NewConfiguration()
+	config.ExpiryWarningLeadTime = "24h"
+	config.UploadWebhookAllowlist = []string{"https://hooks.root.gg/plik"}
+	err = config.Initialize()
+	require.NoError(t, err)
+
+	config = NewConfiguration()
+	config.ExpiryWarningLeadTime = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse ExpiryWarningLeadTime")
+
+	config = NewConfiguration()
+	config.ExpiryWarningLeadTime = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for ExpiryWarningLeadTime")
+}
+
+func TestConfiguration_GetManagementLinkValidity(t *testing.T) {
+	config := NewConfiguration()
+	config.ManagementLinkSecret = "secret"
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 30*24*time.Hour, config.GetManagementLinkValidity())
+
+	config.ManagementLinkValidity = "1h"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.GetManagementLinkValidity())
+
+	config.ManagementLinkValidity = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse ManagementLinkValidity")
+
+	config.ManagementLinkValidity = "0"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative or zero value for ManagementLinkValidity")
+}
+
+func TestConfiguration_GetInactiveUserRetention(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetInactiveUserRetention())
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "365d"
+	config.InactiveUserWebhook = "https://hooks.root.gg/plik"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 365*24*time.Hour, config.GetInactiveUserRetention())
+	require.Equal(t, 7*24*time.Hour, config.GetInactiveUserWarningLeadTime())
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "365d"
+	err = config.Initialize()
+	RequireError(t, err, "InactiveUserRetention needs an InactiveUserWebhook")
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse InactiveUserRetention")
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for InactiveUserRetention")
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "5d"
+	config.InactiveUserWebhook = "https://hooks.root.gg/plik"
+	err = config.Initialize()
+	RequireError(t, err, "InactiveUserWarningLeadTime must be shorter than InactiveUserRetention")
+
+	config = NewConfiguration()
+	config.InactiveUserRetention = "365d"
+	config.InactiveUserWarningLeadTime = "0"
+	config.InactiveUserWebhook = "https://hooks.root.gg/plik"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative or zero value for InactiveUserWarningLeadTime")
+}
+
+func TestConfiguration_GetDownloadNotificationWindow(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.GetDownloadNotificationWindow())
+
+	config = NewConfiguration()
+	config.DownloadNotificationWindow = "15m"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Minute, config.GetDownloadNotificationWindow())
+
+	config = NewConfiguration()
+	config.DownloadNotificationWindow = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse DownloadNotificationWindow")
+
+	config = NewConfiguration()
+	config.DownloadNotificationWindow = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DownloadNotificationWindow")
+}
+
+func TestConfiguration_MaxConnectionsPerIP(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxConnectionsPerIP = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxConnectionsPerIP")
+}
+
+func TestConfiguration_MaxUserMetadataSize(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxUserMetadataSize = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxUserMetadataSize")
+}
+
+func TestConfiguration_MaxCommentLength(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxCommentLength = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxCommentLength")
+}
+
+func TestConfiguration_MaxFilenameLength(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxFilenameLength = 0
+	err := config.Initialize()
+	RequireError(t, err, "invalid MaxFilenameLength, expected a positive value")
+}
+
+func TestConfiguration_MaxUploadMetadataBytes(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxUploadMetadataBytes = -1
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative value for MaxUploadMetadataBytes")
+}
+
+func TestConfiguration_ExpandArchiveMaxRatio(t *testing.T) {
+	config := NewConfiguration()
+	config.ExpandArchiveMaxRatio = 0
+	err := config.Initialize()
+	RequireError(t, err, "invalid negative or zero value for ExpandArchiveMaxRatio")
+}
+
+func TestConfiguration_UploadPasswordLockout(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.Initialize())
+	require.Equal(t, 15*time.Minute, config.GetUploadPasswordLockout())
+
+	config.MaxUploadPasswordAttempts = -1
+	RequireError(t, config.Initialize(), "invalid negative value for MaxUploadPasswordAttempts")
+
+	config.MaxUploadPasswordAttempts = 5
+	config.UploadPasswordLockout = "foo"
+	RequireError(t, config.Initialize(), "unable to parse UploadPasswordLockout")
+
+	config.UploadPasswordLockout = "0"
+	RequireError(t, config.Initialize(), "invalid negative or zero value for UploadPasswordLockout")
+}
+
+func TestConfiguration_NormalizeUploadID(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, "AbCd", config.NormalizeUploadID("AbCd"))
+
+	config.CaseInsensitiveUploadIDs = true
+	require.Equal(t, "abcd", config.NormalizeUploadID("AbCd"))
+}
+
+func TestConfiguration_GetDeleteRetryBackoff(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, config.GetDeleteRetryBackoff())
+
+	config = NewConfiguration()
+	config.DeleteRetryBackoff = "0"
+	err = config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), config.GetDeleteRetryBackoff())
+
+	config = NewConfiguration()
+	config.DeleteRetryBackoff = "azerty"
+	err = config.Initialize()
+	RequireError(t, err, "unable to parse DeleteRetryBackoff")
+
+	config = NewConfiguration()
+	config.DeleteRetryBackoff = "-1"
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DeleteRetryBackoff")
+
+	config = NewConfiguration()
+	config.DeleteFailureAlertThreshold = -1
+	err = config.Initialize()
+	RequireError(t, err, "invalid negative value for DeleteFailureAlertThreshold")
+}
+
 func TestConfiguration_GetPath(t *testing.T) {
 	config := NewConfiguration()
 	require.Equal(t, "/", config.GetPath())
@@ -328,4 +848,28 @@ func TestConfiguration_IsValidDownloadDomain(t *testing.T) {
 	require.True(t, config.IsValidDownloadDomain("plik.root.gg"))
 	require.True(t, config.IsValidDownloadDomain("dl.root.gg"))
 	require.False(t, config.IsValidDownloadDomain("invalid.domain"))
+
+	config = NewConfiguration()
+	config.DownloadDomain = "https://plik.root.gg"
+	config.DownloadDomains = []string{"https://team.root.gg"}
+	err = config.Initialize()
+	require.NoError(t, err)
+
+	require.NotEmpty(t, config.downloadDomainsURL)
+	require.True(t, config.IsValidDownloadDomain("plik.root.gg"))
+	require.True(t, config.IsValidDownloadDomain("team.root.gg"))
+	require.False(t, config.IsValidDownloadDomain("invalid.domain"))
+}
+
+func TestConfiguration_IsSelectableDownloadDomain(t *testing.T) {
+	config := NewConfiguration()
+	require.False(t, config.IsSelectableDownloadDomain(""))
+	require.False(t, config.IsSelectableDownloadDomain("https://plik.root.gg"))
+
+	config.DownloadDomain = "https://plik.root.gg"
+	config.DownloadDomains = []string{"https://team.root.gg"}
+	require.True(t, config.IsSelectableDownloadDomain("https://plik.root.gg"))
+	require.True(t, config.IsSelectableDownloadDomain("https://team.root.gg"))
+	require.False(t, config.IsSelectableDownloadDomain("https://invalid.domain"))
+	require.False(t, config.IsSelectableDownloadDomain(""))
 }
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"context"
+	"net"
+)
+
+type connContextKey struct{}
+
+// WithConn return a copy of the request context holding the client connection.
+// It is meant to be used as the http.Server ConnContext.
+func WithConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// GetConn return the client connection of the request context if any
+func GetConn(ctx context.Context) net.Conn {
+	if conn, ok := ctx.Value(connContextKey{}).(net.Conn); ok {
+		return conn
+	}
+	return nil
+}
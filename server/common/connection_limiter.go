@@ -0,0 +1,55 @@
+package common
+
+import (
+	"sync"
+)
+
+// ConnectionLimiter limits the number of concurrent connections of each client IP address
+// so that one client can't exhaust the server by opening many slow connections
+type ConnectionLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewConnectionLimiter creates a new ConnectionLimiter
+func NewConnectionLimiter(max int) (limiter *ConnectionLimiter) {
+	limiter = new(ConnectionLimiter)
+	limiter.max = max
+	limiter.active = make(map[string]int)
+	return limiter
+}
+
+// Acquire reserve a connection slot for the IP address
+// It returns false if the IP address already uses all its slots, Release must be called otherwise
+func (limiter *ConnectionLimiter) Acquire(ip string) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if limiter.active[ip] >= limiter.max {
+		return false
+	}
+
+	limiter.active[ip]++
+	return true
+}
+
+// Release free a connection slot of the IP address
+func (limiter *ConnectionLimiter) Release(ip string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.active[ip]--
+	if limiter.active[ip] <= 0 {
+		delete(limiter.active, ip)
+	}
+}
+
+// GetActiveConnections return the number of connections currently open by the IP address
+func (limiter *ConnectionLimiter) GetActiveConnections(ip string) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	return limiter.active[ip]
+}
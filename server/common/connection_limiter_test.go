@@ -0,0 +1,28 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionLimiter(t *testing.T) {
+	limiter := NewConnectionLimiter(2)
+
+	require.True(t, limiter.Acquire("1.1.1.1"))
+	require.True(t, limiter.Acquire("1.1.1.1"))
+	require.False(t, limiter.Acquire("1.1.1.1"), "the connection limit should be reached")
+	require.Equal(t, 2, limiter.GetActiveConnections("1.1.1.1"))
+
+	// Other IP addresses are not limited
+	require.True(t, limiter.Acquire("2.2.2.2"))
+
+	limiter.Release("1.1.1.1")
+	require.Equal(t, 1, limiter.GetActiveConnections("1.1.1.1"))
+	require.True(t, limiter.Acquire("1.1.1.1"))
+
+	limiter.Release("1.1.1.1")
+	limiter.Release("1.1.1.1")
+	require.Equal(t, 0, limiter.GetActiveConnections("1.1.1.1"))
+	require.Empty(t, limiter.active["1.1.1.1"], "released IP addresses should not be tracked anymore")
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Content dispositions of downloaded files
+const (
+	ContentDispositionInline     = "inline"
+	ContentDispositionAttachment = "attachment"
+)
+
+// IsValidContentDisposition return true if the value is a known content disposition
+func IsValidContentDisposition(disposition string) bool {
+	return disposition == ContentDispositionInline || disposition == ContentDispositionAttachment
+}
+
+func (config *Configuration) initializeContentDispositions() (err error) {
+	if !IsValidContentDisposition(config.DefaultContentDisposition) {
+		return fmt.Errorf("invalid DefaultContentDisposition %s, expected %s or %s", config.DefaultContentDisposition, ContentDispositionInline, ContentDispositionAttachment)
+	}
+
+	for pattern, disposition := range config.ContentDispositions {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ContentDispositions MIME type pattern %s : %s", pattern, err)
+		}
+		if !IsValidContentDisposition(disposition) {
+			return fmt.Errorf("invalid content disposition %s for MIME type pattern %s, expected %s or %s", disposition, pattern, ContentDispositionInline, ContentDispositionAttachment)
+		}
+	}
+
+	return nil
+}
+
+// GetContentDisposition return whether the file should be displayed inline or downloaded as an attachment
+//   - The upload content disposition if set
+//   - Or the most specific ContentDispositions pattern matching the MIME type ( ex : "image/png" before "image/*" )
+//   - Or DefaultContentDisposition
+func (config *Configuration) GetContentDisposition(upload *Upload, mimeType string) string {
+	if upload.ContentDisposition != "" {
+		return upload.ContentDisposition
+	}
+
+	// Ignore MIME type parameters ( ex : text/plain; charset=utf-8 )
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+
+	disposition := config.DefaultContentDisposition
+	var best string
+	for pattern, d := range config.ContentDispositions {
+		if ok, _ := path.Match(strings.ToLower(pattern), mimeType); !ok {
+			continue
+		}
+		if best == "" || moreSpecificPattern(pattern, best) {
+			best = pattern
+			disposition = d
+		}
+	}
+
+	return disposition
+}
+
+// moreSpecificPattern return true if pattern a takes precedence over pattern b, patterns without wildcard first then
+// the longest pattern. Ties are broken alphabetically to not depend on the map ordering.
+func moreSpecificPattern(a string, b string) bool {
+	aWildcard := strings.ContainsAny(a, "*?[")
+	bWildcard := strings.ContainsAny(b, "*?[")
+	if aWildcard != bWildcard {
+		return !aWildcard
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a < b
+}
+
+// FormatContentDisposition return the Content-Disposition header of a file
+// The ASCII file name is sent as filename and the Unicode file name as filename* ( RFC 6266 ) if they differ
+func FormatContentDisposition(disposition string, filename string, asciiFilename string) string {
+	value := fmt.Sprintf(`filename="%s"`, asciiFilename)
+	if filename != asciiFilename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	if disposition != "" {
+		value = disposition + "; " + value
+	}
+	return value
+}
+
+// encodeRFC5987 percent encode all the bytes of the value but the RFC 5987 attr-char
+func encodeRFC5987(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeContentDispositions(t *testing.T) {
+	config := NewConfiguration()
+	config.ContentDispositions = map[string]string{"image/*": ContentDispositionInline, "*/*": ContentDispositionAttachment}
+	require.NoError(t, config.initializeContentDispositions())
+
+	config.DefaultContentDisposition = "foo"
+	err := config.initializeContentDispositions()
+	RequireError(t, err, "invalid DefaultContentDisposition foo")
+
+	config.DefaultContentDisposition = ContentDispositionAttachment
+	config.ContentDispositions = map[string]string{"image/*": "foo"}
+	err = config.initializeContentDispositions()
+	RequireError(t, err, "invalid content disposition foo for MIME type pattern image/*")
+
+	config.ContentDispositions = map[string]string{"image/[": ContentDispositionInline}
+	err = config.initializeContentDispositions()
+	RequireError(t, err, "invalid ContentDispositions MIME type pattern image/[")
+}
+
+func TestFormatContentDisposition(t *testing.T) {
+	require.Equal(t, `attachment; filename="file.txt"`, FormatContentDisposition(ContentDispositionAttachment, "file.txt", "file.txt"))
+	require.Equal(t, `filename="file.txt"`, FormatContentDisposition("", "file.txt", "file.txt"))
+	require.Equal(t, `inline; filename="cafe (1).txt"; filename*=UTF-8''caf%C3%A9%20%281%29.txt`,
+		FormatContentDisposition(ContentDispositionInline, "café (1).txt", ASCIIFileName("café (1).txt")))
+}
+
+func TestGetContentDisposition(t *testing.T) {
+	config := NewConfiguration()
+	config.DefaultContentDisposition = ContentDispositionAttachment
+	config.ContentDispositions = map[string]string{
+		"image/*":        ContentDispositionInline,
+		"image/svg+xml":  ContentDispositionAttachment,
+		"*/*":            ContentDispositionAttachment,
+		"application/*":  ContentDispositionAttachment,
+		"application/p*": ContentDispositionInline,
+	}
+
+	upload := &Upload{}
+	require.Equal(t, ContentDispositionInline, config.GetContentDisposition(upload, "image/png"))
+	require.Equal(t, ContentDispositionInline, config.GetContentDisposition(upload, "IMAGE/PNG"))
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(upload, "image/svg+xml"))
+	require.Equal(t, ContentDispositionInline, config.GetContentDisposition(upload, "application/pdf"))
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(upload, "application/zip"))
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(upload, "text/plain; charset=utf-8"))
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(upload, ""))
+
+	upload.ContentDisposition = ContentDispositionAttachment
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(upload, "image/png"))
+}
+
+func TestGetContentDispositionDefault(t *testing.T) {
+	config := NewConfiguration()
+	require.Equal(t, ContentDispositionInline, config.GetContentDisposition(&Upload{}, "application/zip"))
+
+	config.ContentDispositions = map[string]string{"text/plain": ContentDispositionAttachment}
+	require.Equal(t, ContentDispositionAttachment, config.GetContentDisposition(&Upload{}, "text/plain; charset=utf-8"))
+	require.Equal(t, ContentDispositionInline, config.GetContentDisposition(&Upload{}, "text/html"))
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+)
+
+// DataBackendRoute configure an additional named data backend and which files to store in it
+//   - Files are stored in the data backend explicitly chosen by the client if it is selectable
+//   - Or in the first data backend matching all its MinFileSize / MinTTL conditions
+//   - Or in the default data backend
+type DataBackendRoute struct {
+	Name       string                 `json:"name"`
+	Backend    string                 `json:"-"`
+	Config     map[string]interface{} `json:"-"`
+	Selectable bool                   `json:"selectable"`
+
+	MinFileSizeStr string `json:"-"`
+	MinFileSize    int64  `json:"-"`
+	MinTTLStr      string `json:"-"`
+	MinTTL         int    `json:"-"`
+}
+
+// match return true if the route conditions are met by the file, routes without condition only match explicit choices
+func (route *DataBackendRoute) match(upload *Upload, file *File) bool {
+	if route.MinFileSize == 0 && route.MinTTL == 0 {
+		return false
+	}
+
+	// Files of unknown size are not routed by size
+	if route.MinFileSize > 0 && file.Size < route.MinFileSize {
+		return false
+	}
+
+	// Uploads without expiration date match any minimum TTL
+	if route.MinTTL > 0 && upload.TTL > 0 && upload.TTL < route.MinTTL {
+		return false
+	}
+
+	return true
+}
+
+func (config *Configuration) initializeDataBackendRoutes() (err error) {
+	names := make(map[string]bool)
+	for _, route := range config.DataBackends {
+		if route.Name == "" {
+			return fmt.Errorf("missing data backend name")
+		}
+		if names[route.Name] {
+			return fmt.Errorf("duplicate data backend name %s", route.Name)
+		}
+		names[route.Name] = true
+
+		if route.Backend == "" {
+			return fmt.Errorf("missing data backend type for data backend %s", route.Name)
+		}
+
+		if route.MinFileSizeStr != "" {
+			minFileSize, err := humanize.ParseBytes(route.MinFileSizeStr)
+			if err != nil {
+				return fmt.Errorf("unable to parse MinFileSize of data backend %s : %s", route.Name, err)
+			}
+			route.MinFileSize = int64(minFileSize)
+		}
+
+		if route.MinTTLStr != "" {
+			route.MinTTL, err = ParseTTL(route.MinTTLStr)
+			if err != nil {
+				return fmt.Errorf("unable to parse MinTTL of data backend %s : %s", route.Name, err)
+			}
+			if route.MinTTL < 0 {
+				return fmt.Errorf("invalid negative MinTTL for data backend %s", route.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetDataBackendRoute return the named data backend configuration or nil
+func (config *Configuration) GetDataBackendRoute(name string) *DataBackendRoute {
+	for _, route := range config.DataBackends {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// SelectDataBackend return the name of the data backend to store the file in, empty for the default data backend
+func (config *Configuration) SelectDataBackend(upload *Upload, file *File) string {
+	if upload.DataBackend != "" {
+		return upload.DataBackend
+	}
+
+	for _, route := range config.DataBackends {
+		if route.match(upload, file) {
+			return route.Name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeDataBackendRoutes(t *testing.T) {
+	config := NewConfiguration()
+	config.DataBackends = []*DataBackendRoute{
+		{Name: "big", Backend: "s3", MinFileSizeStr: "1GB"},
+		{Name: "long", Backend: "s3", MinTTLStr: "30d", Selectable: true},
+	}
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize valid config")
+	require.Equal(t, int64(1000000000), config.DataBackends[0].MinFileSize, "invalid min file size")
+	require.Equal(t, 30*86400, config.DataBackends[1].MinTTL, "invalid min TTL")
+	require.Equal(t, config.DataBackends[1], config.GetDataBackendRoute("long"), "invalid data backend")
+	require.Nil(t, config.GetDataBackendRoute("foo"), "invalid data backend")
+}
+
+func TestInitializeInvalidDataBackendRoutes(t *testing.T) {
+	invalid := map[string][]*DataBackendRoute{
+		"missing data backend name":                {{Backend: "s3"}},
+		"duplicate data backend name foo":          {{Name: "foo", Backend: "s3"}, {Name: "foo", Backend: "s3"}},
+		"missing data backend type":                {{Name: "foo"}},
+		"unable to parse MinFileSize":              {{Name: "foo", Backend: "s3", MinFileSizeStr: "foo"}},
+		"unable to parse MinTTL":                   {{Name: "foo", Backend: "s3", MinTTLStr: "foo"}},
+		"invalid negative MinTTL for data backend": {{Name: "foo", Backend: "s3", MinTTLStr: "-1"}},
+	}
+
+	for message, routes := range invalid {
+		config := NewConfiguration()
+		config.DataBackends = routes
+
+		err := config.Initialize()
+		RequireError(t, err, message)
+	}
+}
+
+func TestSelectDataBackend(t *testing.T) {
+	config := NewConfiguration()
+	config.DataBackends = []*DataBackendRoute{
+		{Name: "explicit", Backend: "s3", Selectable: true},
+		{Name: "big-long", Backend: "s3", MinFileSize: 1000, MinTTL: 86400},
+		{Name: "big", Backend: "s3", MinFileSize: 1000},
+		{Name: "long", Backend: "s3", MinTTL: 86400},
+	}
+
+	require.Equal(t, "", config.SelectDataBackend(&Upload{TTL: 60}, &File{Size: 10}), "invalid data backend")
+	require.Equal(t, "explicit", config.SelectDataBackend(&Upload{TTL: 60, DataBackend: "explicit"}, &File{Size: 10000}), "invalid data backend")
+	require.Equal(t, "big-long", config.SelectDataBackend(&Upload{TTL: 86400}, &File{Size: 1000}), "invalid data backend")
+	require.Equal(t, "big-long", config.SelectDataBackend(&Upload{TTL: -1}, &File{Size: 1000}), "invalid data backend")
+	require.Equal(t, "big", config.SelectDataBackend(&Upload{TTL: 60}, &File{Size: 1000}), "invalid data backend")
+	require.Equal(t, "long", config.SelectDataBackend(&Upload{TTL: 86400}, &File{}), "invalid data backend")
+	require.Equal(t, "long", config.SelectDataBackend(&Upload{TTL: 0}, &File{}), "invalid data backend")
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func (config *Configuration) initializeDefaultFilename() (err error) {
+	if config.DefaultFilename == "" {
+		return nil
+	}
+
+	if config.GetDefaultFilename(&Upload{ID: "uploadID"}, &File{ID: "fileID"}, time.Now()) == "" {
+		return fmt.Errorf("invalid DefaultFilename %s : empty file name", config.DefaultFilename)
+	}
+
+	return nil
+}
+
+// GetDefaultFilename return the name of a file uploaded without a name from the DefaultFilename template
+// Placeholders {date}, {time}, {uploadId} and {fileId} are replaced, the result is sanitized
+func (config *Configuration) GetDefaultFilename(upload *Upload, file *File, now time.Time) string {
+	replacer := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("15-04-05"),
+		"{uploadId}", upload.ID,
+		"{fileId}", file.ID,
+	)
+	return SanitizeFileName(replacer.Replace(config.DefaultFilename))
+}
+
+// SanitizeFileName remove the control characters and replace the path separators of a file name
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." {
+		return ""
+	}
+	if len(name) > 1024 {
+		name = name[:1024]
+	}
+
+	return name
+}
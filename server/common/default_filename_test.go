@@ -0,0 +1,38 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeDefaultFilename(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.initializeDefaultFilename(), "empty template should be valid")
+
+	config.DefaultFilename = "upload-{date}"
+	require.NoError(t, config.initializeDefaultFilename())
+
+	config.DefaultFilename = " \t "
+	RequireError(t, config.initializeDefaultFilename(), "invalid DefaultFilename")
+
+	config.DefaultFilename = ".."
+	RequireError(t, config.initializeDefaultFilename(), "empty file name")
+}
+
+func TestGetDefaultFilename(t *testing.T) {
+	config := NewConfiguration()
+	config.DefaultFilename = "upload-{date}-{time}-{uploadId}-{fileId}.txt"
+
+	now := time.Date(2026, 10, 15, 9, 30, 12, 0, time.UTC)
+	name := config.GetDefaultFilename(&Upload{ID: "upload"}, &File{ID: "file"}, now)
+	require.Equal(t, "upload-2026-10-15-09-30-12-upload-file.txt", name)
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	require.Equal(t, "foo_bar_baz", SanitizeFileName("foo/bar\\baz"))
+	require.Equal(t, "foobar", SanitizeFileName(" foo\x00bar\n "))
+	require.Equal(t, "", SanitizeFileName(".."))
+	require.Len(t, SanitizeFileName(string(make([]byte, 2000))+"x"), 1)
+}
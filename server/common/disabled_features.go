@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// API features that can be turned off by the DisabledFeatures configuration
+const (
+	DisableableQuickUpload     = "quick_upload"      // POST / ( one request upload for curl )
+	DisableableUploadPrecheck  = "upload_precheck"   // POST /upload/precheck
+	DisableableMultiFileUpload = "multi_file_upload" // POST /upload/files and POST /upload/{uploadID}/files
+	DisableableUploadHead      = "upload_head"       // HEAD /upload/{uploadID}
+	DisableableUploadProgress  = "upload_progress"   // GET /upload/{uploadID}/progress
+	DisableableRemoveUpload    = "remove_upload"     // DELETE /upload/{uploadID} and DELETE /me/uploads
+	DisableableRemoveFile      = "remove_file"       // DELETE /file/{uploadID}/{fileID}/{filename}
+	DisableableArchive         = "archive"           // GET /archive/{uploadID}/{filename}
+	DisableableThumbnail       = "thumbnail"         // GET /upload/{uploadID}/{fileID}/thumbnail
+	DisableableQrCode          = "qrcode"            // GET /qrcode
+	DisableableVersion         = "version"           // GET /version
+	DisableableUserUploads     = "user_uploads"      // GET /me/uploads
+	DisableableUserTokens      = "user_tokens"       // GET|POST /me/token, DELETE /me/token/{token} and POST /me/token/{token}/rotate
+	DisableableUploadLinks     = "upload_links"      // POST /me/uploadlink
+	DisableableDeleteAccount   = "delete_account"    // DELETE /me
+	DisableableUserExport      = "user_export"       // GET /me/export
+	DisableableStats           = "stats"             // GET /stats and GET /me/stats
+)
+
+var disableableFeatures = []string{
+	DisableableQuickUpload,
+	DisableableUploadPrecheck,
+	DisableableMultiFileUpload,
+	DisableableUploadHead,
+	DisableableUploadProgress,
+	DisableableRemoveUpload,
+	DisableableRemoveFile,
+	DisableableArchive,
+	DisableableThumbnail,
+	DisableableQrCode,
+	DisableableVersion,
+	DisableableUserUploads,
+	DisableableUserTokens,
+	DisableableUploadLinks,
+	DisableableDeleteAccount,
+	DisableableUserExport,
+	DisableableStats,
+}
+
+func (config *Configuration) initializeDisabledFeatures() (err error) {
+	for name := range config.DisabledFeatures {
+		if !isDisableableFeature(name) {
+			known := append([]string{}, disableableFeatures...)
+			sort.Strings(known)
+			return fmt.Errorf("invalid DisabledFeatures feature %s, expected one of %s", name, strings.Join(known, "|"))
+		}
+	}
+
+	return nil
+}
+
+func isDisableableFeature(name string) bool {
+	for _, feature := range disableableFeatures {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFeatureDisabled return true if the API feature has been turned off by the DisabledFeatures configuration
+func (config *Configuration) IsFeatureDisabled(name string) bool {
+	return config.DisabledFeatures[name]
+}
@@ -0,0 +1,26 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeDisabledFeatures(t *testing.T) {
+	config := NewConfiguration()
+	config.DisabledFeatures = map[string]bool{DisableableRemoveUpload: true, DisableableUserUploads: false}
+	require.NoError(t, config.initializeDisabledFeatures())
+
+	require.True(t, config.IsFeatureDisabled(DisableableRemoveUpload))
+	require.False(t, config.IsFeatureDisabled(DisableableUserUploads))
+	require.False(t, config.IsFeatureDisabled(DisableableArchive))
+
+	config.DisabledFeatures = map[string]bool{"foo": true}
+	RequireError(t, config.initializeDisabledFeatures(), "invalid DisabledFeatures feature foo")
+}
+
+func TestIsFeatureDisabledDefault(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.Initialize())
+	require.False(t, config.IsFeatureDisabled(DisableableRemoveUpload))
+}
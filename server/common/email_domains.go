@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var emailDomainRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+func (config *Configuration) initializeAllowedEmailDomains() (err error) {
+	for i, domain := range config.AllowedEmailDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !emailDomainRegexp.MatchString(domain) {
+			return fmt.Errorf("invalid allowed email domain %s", config.AllowedEmailDomains[i])
+		}
+		config.AllowedEmailDomains[i] = domain
+	}
+
+	return nil
+}
+
+// IsAllowedEmailDomain check that an account may be provisioned for this email address
+// Without AllowedEmailDomains all email addresses are allowed
+func (config *Configuration) IsAllowedEmailDomain(email string) bool {
+	if len(config.AllowedEmailDomains) == 0 {
+		return true
+	}
+
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+
+	for _, allowed := range config.AllowedEmailDomains {
+		if domain == allowed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguration_InitializeAllowedEmailDomains(t *testing.T) {
+	config := NewConfiguration()
+	config.AllowedEmailDomains = []string{" Root.GG ", "mail.plik.io"}
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, []string{"root.gg", "mail.plik.io"}, config.AllowedEmailDomains)
+
+	for _, domain := range []string{"", "root", "@root.gg", "*.root.gg", "root.gg/foo", "-root.gg"} {
+		config = NewConfiguration()
+		config.AllowedEmailDomains = []string{domain}
+		err = config.Initialize()
+		RequireError(t, err, "invalid allowed email domain "+domain)
+	}
+}
+
+func TestConfiguration_IsAllowedEmailDomain(t *testing.T) {
+	config := NewConfiguration()
+	require.True(t, config.IsAllowedEmailDomain("plik@root.gg"))
+	require.True(t, config.IsAllowedEmailDomain(""))
+
+	config.AllowedEmailDomains = []string{"root.gg"}
+	require.True(t, config.IsAllowedEmailDomain("plik@root.gg"))
+	require.True(t, config.IsAllowedEmailDomain("plik@ROOT.gg"))
+	require.False(t, config.IsAllowedEmailDomain("plik@evil.gg"))
+	require.False(t, config.IsAllowedEmailDomain("plik@mail.root.gg"))
+	require.False(t, config.IsAllowedEmailDomain("plik@root.gg@evil.gg"))
+	require.False(t, config.IsAllowedEmailDomain("plik"))
+	require.False(t, config.IsAllowedEmailDomain(""))
+}
@@ -107,6 +107,20 @@ func (config *Configuration) initializeFeatureOneShot() error {
 		return fmt.Errorf("Invalid value for FeatureOneShot : %s", err)
 	}
 
+	if config.ForceOneShot {
+		if config.FeatureOneShot == FeatureDisabled {
+			return fmt.Errorf("ForceOneShot can't be set when FeatureOneShot is disabled")
+		}
+		config.FeatureOneShot = FeatureForced
+	} else if config.DefaultOneShot {
+		if config.FeatureOneShot == FeatureDisabled {
+			return fmt.Errorf("DefaultOneShot can't be set when FeatureOneShot is disabled")
+		}
+		if config.FeatureOneShot == FeatureEnabled {
+			config.FeatureOneShot = FeatureDefault
+		}
+	}
+
 	// Set legacy feature flag for backward compatibility
 	config.OneShot = IsFeatureAvailable(config.FeatureOneShot)
 
@@ -128,6 +142,15 @@ func (config *Configuration) initializeFeatureRemovable() error {
 		return fmt.Errorf("Invalid value for FeatureRemovable : %s", err)
 	}
 
+	if config.DefaultRemovable {
+		if config.FeatureRemovable == FeatureDisabled {
+			return fmt.Errorf("DefaultRemovable can't be set when FeatureRemovable is disabled")
+		}
+		if config.FeatureRemovable == FeatureEnabled {
+			config.FeatureRemovable = FeatureDefault
+		}
+	}
+
 	// Set legacy feature flag for backward compatibility
 	config.Removable = IsFeatureAvailable(config.FeatureRemovable)
 
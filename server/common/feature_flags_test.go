@@ -119,6 +119,35 @@ func Test_initializeFeatureOneShot(t *testing.T) {
 	require.True(t, config.OneShot)
 }
 
+func Test_initializeFeatureOneShotDefaults(t *testing.T) {
+	config := NewConfiguration()
+	config.DefaultOneShot = true
+	require.NoError(t, config.initializeFeatureOneShot())
+	require.Equal(t, FeatureDefault, config.FeatureOneShot)
+
+	config = NewConfiguration()
+	config.FeatureOneShot = FeatureForced
+	config.DefaultOneShot = true
+	require.NoError(t, config.initializeFeatureOneShot())
+	require.Equal(t, FeatureForced, config.FeatureOneShot)
+
+	config = NewConfiguration()
+	config.FeatureOneShot = FeatureDisabled
+	config.DefaultOneShot = true
+	RequireError(t, config.initializeFeatureOneShot(), "DefaultOneShot can't be set when FeatureOneShot is disabled")
+
+	config = NewConfiguration()
+	config.DefaultOneShot = true
+	config.ForceOneShot = true
+	require.NoError(t, config.initializeFeatureOneShot())
+	require.Equal(t, FeatureForced, config.FeatureOneShot)
+
+	config = NewConfiguration()
+	config.FeatureOneShot = FeatureDisabled
+	config.ForceOneShot = true
+	RequireError(t, config.initializeFeatureOneShot(), "ForceOneShot can't be set when FeatureOneShot is disabled")
+}
+
 func Test_initializeFeatureRemovable(t *testing.T) {
 	config := NewConfiguration()
 	config.FeatureRemovable = "invalid"
@@ -157,6 +186,24 @@ func Test_initializeFeatureRemovable(t *testing.T) {
 	require.True(t, config.Removable)
 }
 
+func Test_initializeFeatureRemovableDefaults(t *testing.T) {
+	config := NewConfiguration()
+	config.DefaultRemovable = true
+	require.NoError(t, config.initializeFeatureRemovable())
+	require.Equal(t, FeatureDefault, config.FeatureRemovable)
+
+	config = NewConfiguration()
+	config.FeatureRemovable = FeatureForced
+	config.DefaultRemovable = true
+	require.NoError(t, config.initializeFeatureRemovable())
+	require.Equal(t, FeatureForced, config.FeatureRemovable)
+
+	config = NewConfiguration()
+	config.FeatureRemovable = FeatureDisabled
+	config.DefaultRemovable = true
+	RequireError(t, config.initializeFeatureRemovable(), "DefaultRemovable can't be set when FeatureRemovable is disabled")
+}
+
 func Test_initializeFeatureStream(t *testing.T) {
 	config := NewConfiguration()
 	config.FeatureStream = "invalid"
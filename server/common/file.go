@@ -1,6 +1,10 @@
 package common
 
 import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -19,12 +23,24 @@ const FileRemoved = "removed"
 // FileDeleted when a file has been deleted from the data backend
 const FileDeleted = "deleted"
 
+// EncryptionSchemeAES256GCM when a file has been encrypted by the client in chunks using AES-256-GCM with a random
+// data key. The data key is wrapped with a key the server never knows
+const EncryptionSchemeAES256GCM = "aes-256-gcm-chunked"
+
+// ContentEncodingGzip when a file has been gzipped by the client, the data is stored and served as is
+const ContentEncodingGzip = "gzip"
+
+var md5Regexp = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
 // File object
 type File struct {
 	ID       string `json:"id"`
 	UploadID string `json:"-" gorm:"size:256;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
 	Name     string `json:"fileName"`
 
+	// ASCII only fallback of the file name for clients and HTTP headers not supporting Unicode
+	ASCIIName string `json:"asciiFileName,omitempty"`
+
 	Status string `json:"status"`
 
 	Md5       string `json:"fileMd5"`
@@ -32,8 +48,45 @@ type File struct {
 	Size      int64  `json:"fileSize"`
 	Reference string `json:"reference"`
 
+	// Folder of the file in the uploaded tree ( ex : project/src ), the folder tree is rebuilt in archives
+	RelativePath string `json:"relativePath,omitempty"`
+
+	// Client side encryption details, the server only stores those opaque values and never sees the plaintext
+	EncryptionScheme string `json:"encryptionScheme,omitempty"`
+	EncryptionNonce  string `json:"encryptionNonce,omitempty"`
+	WrappedKey       string `json:"wrappedKey,omitempty"`
+
+	// Encoding of the stored data ( ex : gzip ), served back as the Content-Encoding of the file
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	// Name of the data backend storing the file, the default data backend if empty
+	DataBackend    string `json:"-"`
 	BackendDetails string `json:"-"`
 
+	// Image dimensions in pixels and audio / video duration in seconds, detected if DetectMediaMetadata is enabled
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+
+	// Set once a thumbnail has been generated and stored in the data backend
+	Thumbnail bool `json:"thumbnail"`
+
+	// Number of times the file has been downloaded, resumed downloads are only counted once
+	DownloadCount int `json:"downloadCount"`
+
+	// OneShot download tracking, a OneShot file is only consumed once fully delivered
+	DeliveredBytes     int64      `json:"-"`
+	LastDownloadAt     *time.Time `json:"-"`
+	DownloadInProgress bool       `json:"-" gorm:"not null;default:false"`
+
+	// Files may expire before their upload, the upload is removed once all its files have expired
+	TTL      int        `json:"ttl,omitempty"`
+	ExpireAt *time.Time `json:"expireAt,omitempty" gorm:"index:idx_file_expire_at"`
+
+	// Failed deletions from the data backend by the cleaning routine, retried after a backoff delay
+	DeleteAttempts      int        `json:"-"`
+	NextDeleteAttemptAt *time.Time `json:"-"`
+
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -45,6 +98,22 @@ func NewFile() (file *File) {
 	return
 }
 
+// IsExpired check if the file has expired before its upload
+func (file *File) IsExpired() bool {
+	return file.ExpireAt != nil && time.Now().After(*file.ExpireAt)
+}
+
+// GetMetadataSize return the size in bytes of the metadata set by the client on the file
+func (file *File) GetMetadataSize() int {
+	return len(file.Name) + len(file.Type) + len(file.Reference) + len(file.RelativePath) +
+		len(file.EncryptionScheme) + len(file.EncryptionNonce) + len(file.WrappedKey)
+}
+
+// IsValidMd5 check that the string is an hex encoded md5sum
+func IsValidMd5(md5sum string) bool {
+	return md5Regexp.MatchString(md5sum)
+}
+
 // GenerateID generate a new File ID
 func (file *File) GenerateID() {
 	file.ID = GenerateRandomID(16)
@@ -54,3 +123,47 @@ func (file *File) GenerateID() {
 func (file *File) Sanitize() {
 	file.BackendDetails = ""
 }
+
+// SanitizeRelativePath normalize a relative folder path and reject paths escaping the upload tree
+func SanitizeRelativePath(relativePath string) (string, error) {
+	if len(relativePath) > 1024 {
+		return "", fmt.Errorf("path is too long, maximum length is 1024 characters")
+	}
+
+	// Windows clients may send backslash separated paths
+	relativePath = strings.ReplaceAll(relativePath, "\\", "/")
+
+	if strings.HasPrefix(relativePath, "/") || (len(relativePath) > 1 && relativePath[1] == ':') {
+		return "", fmt.Errorf("path must be relative")
+	}
+
+	var elements []string
+	for _, element := range strings.Split(relativePath, "/") {
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("path must not contain \"..\"")
+		}
+		for _, r := range element {
+			if r < 0x20 || r == 0x7f {
+				return "", fmt.Errorf("path must not contain control characters")
+			}
+		}
+		elements = append(elements, element)
+	}
+
+	return path.Join(elements...), nil
+}
+
+// SanitizeContentEncoding normalize the Content-Encoding of uploaded data and reject unsupported encodings
+func SanitizeContentEncoding(encoding string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return "", nil
+	case "gzip", "x-gzip":
+		return ContentEncodingGzip, nil
+	default:
+		return "", fmt.Errorf("unsupported content encoding %s", encoding)
+	}
+}
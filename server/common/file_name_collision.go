@@ -0,0 +1,29 @@
+package common
+
+import (
+	"fmt"
+	"path"
+)
+
+// Policies to choose the file to serve when several files of an upload share the requested name
+const (
+	FileNameCollisionFirst    = "first"
+	FileNameCollisionConflict = "conflict"
+)
+
+func (config *Configuration) initializeFileNameCollisionPolicy() (err error) {
+	switch config.FileNameCollisionPolicy {
+	case FileNameCollisionFirst, FileNameCollisionConflict:
+		return nil
+	default:
+		return fmt.Errorf("invalid FileNameCollisionPolicy %s, expected %s or %s", config.FileNameCollisionPolicy, FileNameCollisionFirst, FileNameCollisionConflict)
+	}
+}
+
+// MatchName return true if the file is named name or if name is the path of the file in the uploaded tree
+func (file *File) MatchName(name string) bool {
+	if file.Name == name {
+		return true
+	}
+	return file.RelativePath != "" && path.Join(file.RelativePath, file.Name) == name
+}
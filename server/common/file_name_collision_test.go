@@ -0,0 +1,30 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeFileNameCollisionPolicy(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.initializeFileNameCollisionPolicy())
+
+	config.FileNameCollisionPolicy = FileNameCollisionConflict
+	require.NoError(t, config.initializeFileNameCollisionPolicy())
+
+	config.FileNameCollisionPolicy = "foo"
+	err := config.initializeFileNameCollisionPolicy()
+	RequireError(t, err, "invalid FileNameCollisionPolicy foo")
+}
+
+func TestFile_MatchName(t *testing.T) {
+	file := &File{Name: "main.go"}
+	require.True(t, file.MatchName("main.go"))
+	require.False(t, file.MatchName("src/main.go"))
+
+	file.RelativePath = "project/src"
+	require.True(t, file.MatchName("main.go"))
+	require.True(t, file.MatchName("project/src/main.go"))
+	require.False(t, file.MatchName("src/main.go"))
+}
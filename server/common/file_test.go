@@ -1,6 +1,7 @@
 package common
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -18,3 +19,53 @@ func TestFileSanitize(t *testing.T) {
 	file.Sanitize()
 	require.Zero(t, file.BackendDetails, "invalid backend details")
 }
+
+func TestSanitizeRelativePath(t *testing.T) {
+	valid := map[string]string{
+		"":                "",
+		".":               "",
+		"project":         "project",
+		"project/src/":    "project/src",
+		"./project//src":  "project/src",
+		"project\\src":    "project/src",
+		"project/.../src": "project/.../src",
+	}
+	for relativePath, expected := range valid {
+		sanitized, err := SanitizeRelativePath(relativePath)
+		require.NoError(t, err, "unexpected error for %s", relativePath)
+		require.Equal(t, expected, sanitized, "invalid sanitized path for %s", relativePath)
+	}
+
+	invalid := map[string]string{
+		"../etc":                  "must not contain",
+		"project/../../etc":       "must not contain",
+		"project\\..\\..":         "must not contain",
+		"/etc":                    "must be relative",
+		"\\\\server\\share":       "must be relative",
+		"C:\\Windows":             "must be relative",
+		"project/\x00":            "control characters",
+		strings.Repeat("x", 1025): "too long",
+	}
+	for relativePath, expected := range invalid {
+		_, err := SanitizeRelativePath(relativePath)
+		RequireError(t, err, expected)
+	}
+}
+
+func TestSanitizeContentEncoding(t *testing.T) {
+	valid := map[string]string{
+		"":         "",
+		"identity": "",
+		"gzip":     ContentEncodingGzip,
+		" GZIP ":   ContentEncodingGzip,
+		"x-gzip":   ContentEncodingGzip,
+	}
+	for encoding, expected := range valid {
+		sanitized, err := SanitizeContentEncoding(encoding)
+		require.NoError(t, err, "unexpected error for %s", encoding)
+		require.Equal(t, expected, sanitized, "invalid sanitized encoding for %s", encoding)
+	}
+
+	_, err := SanitizeContentEncoding("br")
+	RequireError(t, err, "unsupported content encoding br")
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeFileName return the Unicode NFC form of a file name without the control and format characters
+// Format characters include the bidi overrides ( U+202E ... ) and the zero width characters ( U+200B ... )
+// that can be used to spoof the displayed file name or extension
+func NormalizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, norm.NFC.String(name))
+
+	return strings.TrimSpace(name)
+}
+
+// ASCIIFileName return a file name safe to use in HTTP headers and by clients not supporting Unicode file names
+// Accents are removed, other non ASCII characters and quotes are replaced by an underscore
+func ASCIIFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		case r < 0x20 || r > 0x7e || r == '"' || r == '\\':
+			return '_'
+		default:
+			return r
+		}
+	}, norm.NFD.String(name))
+}
+
+// TruncateFileName shorten the file name to maxLength bytes without splitting a UTF-8 character
+// The extension is kept if it is not longer than half of maxLength
+func TruncateFileName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+
+	ext := path.Ext(name)
+	if len(ext) > maxLength/2 {
+		ext = ""
+	}
+
+	base := name[:len(name)-len(ext)]
+	limit := maxLength - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+
+	return base[:limit] + ext
+}
+
+// CheckFileName normalize the file name and check its length against MaxFilenameLength
+// Names too long are truncated if TruncateLongFilenames is enabled and rejected otherwise
+func (config *Configuration) CheckFileName(name string) (string, error) {
+	name = NormalizeFileName(name)
+	if name == "" {
+		return "", fmt.Errorf("missing file name")
+	}
+
+	if len(name) > config.MaxFilenameLength {
+		if !config.TruncateLongFilenames {
+			return "", fmt.Errorf("file name %s... is too long, maximum length is %d bytes", TruncateFileName(name, 20), config.MaxFilenameLength)
+		}
+		name = TruncateFileName(name, config.MaxFilenameLength)
+	}
+
+	return name, nil
+}
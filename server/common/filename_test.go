@@ -0,0 +1,51 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFileName(t *testing.T) {
+	require.Equal(t, "café.txt", NormalizeFileName("cafe\u0301.txt"), "invalid NFC normalization")
+	require.Equal(t, "invoicefdp.exe", NormalizeFileName("invoice\u202efdp.exe"), "bidi override must be stripped")
+	require.Equal(t, "file.txt", NormalizeFileName("fi\u200ble\u2066.txt\ufeff"), "zero width characters must be stripped")
+	require.Equal(t, "file.txt", NormalizeFileName(" fi\r\nle.txt\x00 "), "control characters must be stripped")
+	require.Equal(t, "file.txt", NormalizeFileName("file\xff.txt"), "invalid UTF-8 must be stripped")
+	require.Equal(t, "", NormalizeFileName("\u200b\u202e"))
+}
+
+func TestASCIIFileName(t *testing.T) {
+	require.Equal(t, "file.txt", ASCIIFileName("file.txt"))
+	require.Equal(t, "cafe creme.txt", ASCIIFileName("café crème.txt"))
+	require.Equal(t, "__.pdf", ASCIIFileName("日本.pdf"))
+	require.Equal(t, "foo_bar_.txt", ASCIIFileName(`foo"bar\.txt`))
+}
+
+func TestTruncateFileName(t *testing.T) {
+	require.Equal(t, "file.txt", TruncateFileName("file.txt", 10))
+	require.Equal(t, "a_long.txt", TruncateFileName("a_long_file_name.txt", 10))
+	require.Equal(t, "a_long_fil", TruncateFileName("a_long_file_name.extension", 10), "long extensions are not kept")
+	require.Equal(t, "éé.txt", TruncateFileName("ééé.txt", 9), "UTF-8 characters must not be split")
+}
+
+func TestCheckFileName(t *testing.T) {
+	config := NewConfiguration()
+	config.MaxFilenameLength = 12
+	require.NoError(t, config.Initialize())
+
+	name, err := config.CheckFileName("report\u202e.pdf")
+	require.NoError(t, err)
+	require.Equal(t, "report.pdf", name)
+
+	_, err = config.CheckFileName("\u200b")
+	RequireError(t, err, "missing file name")
+
+	_, err = config.CheckFileName("a_very_long_file_name.txt")
+	RequireError(t, err, "is too long, maximum length is 12 bytes")
+
+	config.TruncateLongFilenames = true
+	name, err = config.CheckFileName("a_very_long_file_name.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a_very_l.txt", name)
+}
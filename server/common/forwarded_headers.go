@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func (config *Configuration) initializeForwardedHeaders() (err error) {
+	config.trustedProxies = nil
+	for _, cidr := range config.TrustedProxies {
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("failed to parse trusted proxies : %s", cidr)
+		}
+		config.trustedProxies = append(config.trustedProxies, subnet)
+	}
+
+	if config.UseForwardedHeaders && len(config.trustedProxies) == 0 {
+		return fmt.Errorf("UseForwardedHeaders needs TrustedProxies")
+	}
+
+	return nil
+}
+
+// IsTrustedProxy return true if the forwarded headers of the requests sent by this peer can be trusted
+func (config *Configuration) IsTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range config.trustedProxies {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getForwardedHeader return the first value of a X-Forwarded-* header if the request has been sent by a trusted proxy
+func (config *Configuration) getForwardedHeader(req *http.Request, name string) string {
+	if !config.UseForwardedHeaders || req == nil {
+		return ""
+	}
+
+	peer, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil || !config.IsTrustedProxy(net.ParseIP(peer)) {
+		return ""
+	}
+
+	// Proxies chained behind each other append their values, the first one is the closest to the client
+	value := strings.Split(req.Header.Get(name), ",")[0]
+	return strings.TrimSpace(value)
+}
+
+// getForwardedHost return the X-Forwarded-Host header of a trusted proxy if it is a valid host
+func (config *Configuration) getForwardedHost(req *http.Request) string {
+	host := config.getForwardedHeader(req, "X-Forwarded-Host")
+	if strings.ContainsAny(host, "/\\@ ") {
+		return ""
+	}
+	return host
+}
+
+// GetRequestHost return the host the client sent the request to
+// This is the X-Forwarded-Host header if UseForwardedHeaders is enabled and the request comes from a trusted proxy
+func (config *Configuration) GetRequestHost(req *http.Request) string {
+	if host := config.getForwardedHost(req); host != "" {
+		return host
+	}
+	return req.Host
+}
+
+// GetRequestServerURL return the server URL as seen by the client of the request to generate links
+// The scheme and host are read from the X-Forwarded-Proto and X-Forwarded-Host headers
+// if UseForwardedHeaders is enabled and the request comes from a trusted proxy, GetServerURL is used otherwise
+func (config *Configuration) GetRequestServerURL(req *http.Request) *url.URL {
+	URL := config.GetServerURL()
+
+	host := config.getForwardedHost(req)
+	if host == "" {
+		return URL
+	}
+	URL.Host = host
+
+	switch proto := strings.ToLower(config.getForwardedHeader(req, "X-Forwarded-Proto")); proto {
+	case "http", "https":
+		URL.Scheme = proto
+	}
+
+	return URL
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newForwardedTestRequest(t *testing.T, remoteAddr string) *http.Request {
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err, "unable to create new request")
+	req.Host = "127.0.0.1:8080"
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-Host", "plik.root.gg, proxy.internal")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	return req
+}
+
+func TestInitializeForwardedHeaders(t *testing.T) {
+	config := NewConfiguration()
+	config.UseForwardedHeaders = true
+	RequireError(t, config.Initialize(), "UseForwardedHeaders needs TrustedProxies")
+
+	config.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1", "::1"}
+	require.NoError(t, config.Initialize())
+	require.True(t, config.IsTrustedProxy(net.ParseIP("10.1.2.3")))
+	require.True(t, config.IsTrustedProxy(net.ParseIP("192.168.1.1")))
+	require.True(t, config.IsTrustedProxy(net.ParseIP("::1")))
+	require.False(t, config.IsTrustedProxy(net.ParseIP("192.168.1.2")))
+	require.False(t, config.IsTrustedProxy(nil))
+
+	config.TrustedProxies = []string{"invalid"}
+	RequireError(t, config.Initialize(), "failed to parse trusted proxies : invalid")
+}
+
+func TestGetRequestServerURL(t *testing.T) {
+	config := NewConfiguration()
+	config.Path = "/plik"
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	require.NoError(t, config.Initialize())
+
+	req := newForwardedTestRequest(t, "10.0.0.1:1234")
+
+	// Forwarded headers are ignored unless enabled
+	require.Equal(t, "http://127.0.0.1:8080/plik", config.GetRequestServerURL(req).String())
+	require.Equal(t, "127.0.0.1:8080", config.GetRequestHost(req))
+
+	config.UseForwardedHeaders = true
+	require.Equal(t, "https://plik.root.gg/plik", config.GetRequestServerURL(req).String())
+	require.Equal(t, "plik.root.gg", config.GetRequestHost(req))
+
+	// Only trusted proxies can forward headers
+	req = newForwardedTestRequest(t, "1.2.3.4:1234")
+	require.Equal(t, "http://127.0.0.1:8080/plik", config.GetRequestServerURL(req).String())
+	require.Equal(t, "127.0.0.1:8080", config.GetRequestHost(req))
+
+	// Invalid forwarded values are ignored
+	req = newForwardedTestRequest(t, "10.0.0.1:1234")
+	req.Header.Set("X-Forwarded-Proto", "ftp")
+	require.Equal(t, "http://plik.root.gg/plik", config.GetRequestServerURL(req).String())
+
+	req.Header.Set("X-Forwarded-Host", "evil.com/path")
+	require.Equal(t, "http://127.0.0.1:8080/plik", config.GetRequestServerURL(req).String())
+	require.Equal(t, "127.0.0.1:8080", config.GetRequestHost(req))
+}
@@ -0,0 +1,216 @@
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var countryCodeRegexp = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// GeoIPDatabase resolve the country of IP addresses from a CSV database.
+// Each line is either "network,country" ( ex : 192.0.2.0/24,FR ) or "first_ip,last_ip,country"
+// like the DB-IP lite country database. Lines starting with # and a header line are ignored.
+type GeoIPDatabase struct {
+	ranges []*geoIPRange // Sorted by first IP address
+}
+
+type geoIPRange struct {
+	first   net.IP
+	last    net.IP
+	country string
+}
+
+// LoadGeoIPDatabase load a CSV GeoIP database from the file at path
+func LoadGeoIPDatabase(path string) (db *GeoIPDatabase, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return ParseGeoIPDatabase(f)
+}
+
+// ParseGeoIPDatabase parse a CSV GeoIP database
+func ParseGeoIPDatabase(reader io.Reader) (db *GeoIPDatabase, err error) {
+	db = &GeoIPDatabase{}
+
+	r := csv.NewReader(reader)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ipRange, err := parseGeoIPRange(record)
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("invalid line %d : %s", line, err)
+		}
+		db.ranges = append(db.ranges, ipRange)
+	}
+
+	if len(db.ranges) == 0 {
+		return nil, fmt.Errorf("empty GeoIP database")
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].first, db.ranges[j].first) < 0
+	})
+
+	return db, nil
+}
+
+func parseGeoIPRange(record []string) (ipRange *geoIPRange, err error) {
+	ipRange = &geoIPRange{}
+
+	switch len(record) {
+	case 2:
+		_, network, err := net.ParseCIDR(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, err
+		}
+		ipRange.first = network.IP.To16()
+		ipRange.last = make(net.IP, net.IPv6len)
+		mask := network.Mask
+		if len(mask) == net.IPv4len {
+			mask = append(net.CIDRMask(96, 128)[:12], mask...)
+		}
+		for i := range ipRange.first {
+			ipRange.last[i] = ipRange.first[i] | ^mask[i]
+		}
+	case 3:
+		ipRange.first = net.ParseIP(strings.TrimSpace(record[0])).To16()
+		ipRange.last = net.ParseIP(strings.TrimSpace(record[1])).To16()
+		if ipRange.first == nil || ipRange.last == nil {
+			return nil, fmt.Errorf("invalid IP address range %s - %s", record[0], record[1])
+		}
+	default:
+		return nil, fmt.Errorf("expected 2 or 3 fields but got %d", len(record))
+	}
+
+	ipRange.country = strings.ToUpper(strings.TrimSpace(record[len(record)-1]))
+
+	return ipRange, nil
+}
+
+// Country return the ISO 3166 country code of the IP address or an empty string if it is unknown
+func (db *GeoIPDatabase) Country(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+
+	// Last range starting before the IP address
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].first, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].last) > 0 {
+		return ""
+	}
+
+	country := db.ranges[i].country
+	if !countryCodeRegexp.MatchString(country) || country == "ZZ" {
+		return ""
+	}
+
+	return country
+}
+
+// ParseCountryCodes parse a comma separated list of ISO 3166 country codes ( ex : "FR,DE" )
+func ParseCountryCodes(str string) (countries []string, err error) {
+	for _, country := range strings.Split(str, ",") {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			continue
+		}
+		if !countryCodeRegexp.MatchString(country) {
+			return nil, fmt.Errorf("invalid country code %s", country)
+		}
+		countries = append(countries, country)
+	}
+	return countries, nil
+}
+
+func (config *Configuration) initializeGeoIP() (err error) {
+	if config.GeoIPDatabase != "" {
+		config.geoIPDatabase, err = LoadGeoIPDatabase(config.GeoIPDatabase)
+		if err != nil {
+			return fmt.Errorf("unable to load GeoIPDatabase %s : %s", config.GeoIPDatabase, err)
+		}
+	}
+
+	config.AllowedCountries, err = ParseCountryCodes(strings.Join(config.AllowedCountries, ","))
+	if err != nil {
+		return fmt.Errorf("invalid AllowedCountries : %s", err)
+	}
+
+	config.BlockedCountries, err = ParseCountryCodes(strings.Join(config.BlockedCountries, ","))
+	if err != nil {
+		return fmt.Errorf("invalid BlockedCountries : %s", err)
+	}
+
+	if (len(config.AllowedCountries) > 0 || len(config.BlockedCountries) > 0) && config.geoIPDatabase == nil {
+		return fmt.Errorf("AllowedCountries and BlockedCountries need a GeoIPDatabase")
+	}
+
+	return nil
+}
+
+// GetGeoIPDatabase return the GeoIP database or nil if none is configured
+func (config *Configuration) GetGeoIPDatabase() *GeoIPDatabase {
+	return config.geoIPDatabase
+}
+
+// getDownloadCountries return the allowed and blocked download countries of the upload.
+// The server defaults apply to uploads that do not set their own
+func (config *Configuration) getDownloadCountries(upload *Upload) (allowed []string, blocked []string) {
+	allowed, blocked = config.AllowedCountries, config.BlockedCountries
+	if upload.AllowedCountries != "" {
+		allowed = strings.Split(upload.AllowedCountries, ",")
+	}
+	if upload.BlockedCountries != "" {
+		blocked = strings.Split(upload.BlockedCountries, ",")
+	}
+	return allowed, blocked
+}
+
+// IsDownloadCountryRestricted return true if the upload files can't be downloaded from some countries
+func (config *Configuration) IsDownloadCountryRestricted(upload *Upload) bool {
+	allowed, blocked := config.getDownloadCountries(upload)
+	return len(allowed) > 0 || len(blocked) > 0
+}
+
+// IsDownloadCountryAllowed return true if the upload files can be downloaded from the country
+func (config *Configuration) IsDownloadCountryAllowed(upload *Upload, country string) bool {
+	allowed, blocked := config.getDownloadCountries(upload)
+	if len(allowed) > 0 && !isCountryInList(country, allowed) {
+		return false
+	}
+	return !isCountryInList(country, blocked)
+}
+
+func isCountryInList(country string, countries []string) bool {
+	for _, c := range countries {
+		if c == country {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testGeoIPDatabase = `network,country
+# Comment
+192.0.2.0/24,FR
+198.51.100.0,198.51.100.255,de
+2001:db8::/32,US
+203.0.113.0/24,ZZ
+`
+
+func TestParseGeoIPDatabase(t *testing.T) {
+	db, err := ParseGeoIPDatabase(strings.NewReader(testGeoIPDatabase))
+	require.NoError(t, err, "unable to parse GeoIP database")
+
+	require.Equal(t, "FR", db.Country(net.ParseIP("192.0.2.1")))
+	require.Equal(t, "FR", db.Country(net.ParseIP("192.0.2.255")))
+	require.Equal(t, "DE", db.Country(net.ParseIP("198.51.100.42")))
+	require.Equal(t, "US", db.Country(net.ParseIP("2001:db8::1")))
+	require.Equal(t, "", db.Country(net.ParseIP("203.0.113.1")), "unknown country expected")
+	require.Equal(t, "", db.Country(net.ParseIP("192.0.3.1")), "unknown country expected")
+	require.Equal(t, "", db.Country(net.ParseIP("10.0.0.1")), "unknown country expected")
+	require.Equal(t, "", db.Country(nil), "unknown country expected")
+
+	_, err = ParseGeoIPDatabase(strings.NewReader("192.0.2.0/24,FR\ninvalid,FR\n"))
+	RequireError(t, err, "invalid line 2")
+
+	_, err = ParseGeoIPDatabase(strings.NewReader("network,country\n"))
+	RequireError(t, err, "empty GeoIP database")
+}
+
+func TestParseCountryCodes(t *testing.T) {
+	countries, err := ParseCountryCodes(" fr, DE ,,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"FR", "DE"}, countries)
+
+	countries, err = ParseCountryCodes("")
+	require.NoError(t, err)
+	require.Empty(t, countries)
+
+	_, err = ParseCountryCodes("FR,France")
+	RequireError(t, err, "invalid country code FRANCE")
+}
+
+func TestInitializeGeoIP(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.initializeGeoIP())
+	require.Nil(t, config.GetGeoIPDatabase())
+
+	config.AllowedCountries = []string{"fr"}
+	RequireError(t, config.initializeGeoIP(), "need a GeoIPDatabase")
+
+	dir, err := ioutil.TempDir("", "plik_geoip_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	config.GeoIPDatabase = filepath.Join(dir, "geoip.csv")
+	RequireError(t, config.initializeGeoIP(), "unable to load GeoIPDatabase")
+
+	require.NoError(t, ioutil.WriteFile(config.GeoIPDatabase, []byte(testGeoIPDatabase), 0600))
+	require.NoError(t, config.initializeGeoIP())
+	require.NotNil(t, config.GetGeoIPDatabase())
+	require.Equal(t, []string{"FR"}, config.AllowedCountries)
+
+	config.BlockedCountries = []string{"France"}
+	RequireError(t, config.initializeGeoIP(), "invalid BlockedCountries")
+}
+
+func TestIsDownloadCountryAllowed(t *testing.T) {
+	config := NewConfiguration()
+
+	upload := &Upload{}
+	require.False(t, config.IsDownloadCountryRestricted(upload))
+	require.True(t, config.IsDownloadCountryAllowed(upload, "FR"))
+
+	config.BlockedCountries = []string{"DE"}
+	require.True(t, config.IsDownloadCountryRestricted(upload))
+	require.True(t, config.IsDownloadCountryAllowed(upload, "FR"))
+	require.False(t, config.IsDownloadCountryAllowed(upload, "DE"))
+
+	// Uploads override the server defaults
+	upload.AllowedCountries = "FR,BE"
+	upload.BlockedCountries = "BE"
+	require.True(t, config.IsDownloadCountryAllowed(upload, "FR"))
+	require.False(t, config.IsDownloadCountryAllowed(upload, "BE"))
+	require.False(t, config.IsDownloadCountryAllowed(upload, "US"))
+}
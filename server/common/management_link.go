@@ -0,0 +1,75 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ManagementActionRemove is the action of the management links removing an upload
+const ManagementActionRemove = "remove"
+
+// ManagementLink let anyone knowing the signed link manage an upload without a token
+// The link is signed with the ManagementLinkSecret so it can't be forged or altered
+type ManagementLink struct {
+	UploadID string
+	Action   string
+	ExpireAt time.Time
+}
+
+// NewManagementLink create a link performing the action on the upload for ManagementLinkValidity
+func NewManagementLink(config *Configuration, uploadID string, action string) *ManagementLink {
+	return &ManagementLink{
+		UploadID: uploadID,
+		Action:   action,
+		ExpireAt: time.Now().Add(config.GetManagementLinkValidity()),
+	}
+}
+
+// IsExpired return true if the management link can't be used anymore
+func (link *ManagementLink) IsExpired() bool {
+	return time.Now().After(link.ExpireAt)
+}
+
+// GetURL return the signed URL of the management link relative to the Plik server URL
+func (link *ManagementLink) GetURL(secret string) string {
+	query := url.Values{}
+	query.Set("action", link.Action)
+	query.Set("expire", strconv.FormatInt(link.ExpireAt.Unix(), 10))
+	query.Set("signature", link.sign(secret))
+	return fmt.Sprintf("/upload/%s?%s", link.UploadID, query.Encode())
+}
+
+func (link *ManagementLink) sign(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d", link.UploadID, link.Action, link.ExpireAt.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ParseManagementLink verify the signed management link of the upload from the request query parameters
+func ParseManagementLink(secret string, uploadID string, query url.Values) (link *ManagementLink, err error) {
+	expire, err := strconv.ParseInt(query.Get("expire"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid management link")
+	}
+
+	link = &ManagementLink{
+		UploadID: uploadID,
+		Action:   query.Get("action"),
+		ExpireAt: time.Unix(expire, 0),
+	}
+
+	if !hmac.Equal([]byte(link.sign(secret)), []byte(query.Get("signature"))) {
+		return nil, fmt.Errorf("invalid management link")
+	}
+
+	if link.IsExpired() {
+		return nil, fmt.Errorf("management link has expired")
+	}
+
+	return link, nil
+}
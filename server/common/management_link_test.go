@@ -0,0 +1,49 @@
+package common
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestManagementLink(t *testing.T, secret string, uploadID string, linkURL string) (*ManagementLink, error) {
+	u, err := url.Parse(linkURL)
+	require.NoError(t, err, "unable to parse management link URL")
+	return ParseManagementLink(secret, uploadID, u.Query())
+}
+
+func TestManagementLink(t *testing.T) {
+	config := NewConfiguration()
+	config.ManagementLinkSecret = "secret"
+	require.NoError(t, config.Initialize())
+
+	link := NewManagementLink(config, "uploadID", ManagementActionRemove)
+	require.False(t, link.IsExpired(), "invalid expiration")
+
+	linkURL := link.GetURL(config.ManagementLinkSecret)
+	require.Contains(t, linkURL, "/upload/uploadID?action=remove&expire=", "invalid management link URL")
+
+	parsed, err := parseTestManagementLink(t, config.ManagementLinkSecret, "uploadID", linkURL)
+	require.NoError(t, err, "unable to parse management link")
+	require.Equal(t, ManagementActionRemove, parsed.Action, "invalid action")
+	require.Equal(t, link.ExpireAt.Unix(), parsed.ExpireAt.Unix(), "invalid expiration date")
+
+	_, err = parseTestManagementLink(t, "another secret", "uploadID", linkURL)
+	RequireError(t, err, "invalid management link")
+
+	_, err = parseTestManagementLink(t, config.ManagementLinkSecret, "anotherUploadID", linkURL)
+	RequireError(t, err, "invalid management link")
+
+	_, err = parseTestManagementLink(t, config.ManagementLinkSecret, "uploadID", "/upload/uploadID?action=remove")
+	RequireError(t, err, "invalid management link")
+}
+
+func TestManagementLinkExpired(t *testing.T) {
+	link := &ManagementLink{UploadID: "uploadID", Action: ManagementActionRemove, ExpireAt: time.Now().Add(-time.Minute)}
+	require.True(t, link.IsExpired(), "invalid expiration")
+
+	_, err := parseTestManagementLink(t, "secret", "uploadID", link.GetURL("secret"))
+	RequireError(t, err, "management link has expired")
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+)
+
+// MediaMetadataHeaderSize is the number of bytes at the beginning of the uploaded files used to detect their media metadata
+const MediaMetadataHeaderSize = 64 * 1024
+
+// MediaMetadata details of the images, audio and video files detected from the beginning of their data
+type MediaMetadata struct {
+	Width    int
+	Height   int
+	Duration float64 // Seconds
+}
+
+// DetectMediaMetadata return the dimensions of an image or the duration of an audio or video file from the
+// beginning of its data. Only the details found in the header are returned ( ex : the duration of a MP4 video
+// is not detected when the moov box is stored at the end of the file ).
+func DetectMediaMetadata(mimeType string, header []byte) (metadata *MediaMetadata) {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	metadata = &MediaMetadata{}
+
+	switch {
+	case IsThumbnailType(mimeType):
+		config, _, err := image.DecodeConfig(bytes.NewReader(header))
+		if err == nil {
+			metadata.Width = config.Width
+			metadata.Height = config.Height
+		}
+	case mimeType == "audio/wave":
+		metadata.Duration = getWaveDuration(header)
+	case mimeType == "video/mp4", mimeType == "audio/mp4", mimeType == "video/quicktime":
+		metadata.Duration = getMP4Duration(header)
+	}
+
+	return metadata
+}
+
+// getWaveDuration compute the duration of a WAV file from the byte rate of the fmt chunk and the size of the data chunk
+func getWaveDuration(header []byte) float64 {
+	if len(header) < 12 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0
+	}
+
+	var byteRate uint32
+	for offset := 12; offset+8 <= len(header); {
+		id := string(header[offset : offset+4])
+		size := binary.LittleEndian.Uint32(header[offset+4 : offset+8])
+		body := offset + 8
+
+		switch id {
+		case "fmt ":
+			if body+12 > len(header) {
+				return 0
+			}
+			byteRate = binary.LittleEndian.Uint32(header[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			return float64(size) / float64(byteRate)
+		}
+
+		// Chunks are word aligned
+		offset = body + int(size) + int(size%2)
+	}
+
+	return 0
+}
+
+// getMP4Duration compute the duration of a MP4 / QuickTime file from the timescale and duration of the mvhd box
+func getMP4Duration(header []byte) float64 {
+	moov := findMP4Box(header, "moov")
+	if moov == nil {
+		return 0
+	}
+
+	mvhd := findMP4Box(moov, "mvhd")
+	if len(mvhd) < 4 {
+		return 0
+	}
+
+	var timescale uint32
+	var duration uint64
+	if mvhd[0] == 1 {
+		// Version 1 : 64 bits creation and modification times and duration
+		if len(mvhd) < 32 {
+			return 0
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		if len(mvhd) < 20 {
+			return 0
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+
+	if timescale == 0 {
+		return 0
+	}
+
+	return float64(duration) / float64(timescale)
+}
+
+// findMP4Box return the content of the first box of the given type, truncated to the available data
+func findMP4Box(data []byte, boxType string) []byte {
+	for offset := 0; offset+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[offset : offset+4]))
+		headerSize := uint64(8)
+
+		switch size {
+		case 0:
+			// The box extends to the end of the file
+			size = uint64(len(data) - offset)
+		case 1:
+			// 64 bits box size
+			if offset+16 > len(data) {
+				return nil
+			}
+			size = binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			headerSize = 16
+		}
+
+		if size < headerSize {
+			return nil
+		}
+
+		if string(data[offset+4:offset+8]) == boxType {
+			end := uint64(len(data))
+			if uint64(offset)+size < end {
+				end = uint64(offset) + size
+			}
+			return data[uint64(offset)+headerSize : end]
+		}
+
+		if uint64(offset)+size >= uint64(len(data)) {
+			return nil
+		}
+		offset += int(size)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWave(seconds int) []byte {
+	byteRate := 8000 * 2
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+seconds*byteRate))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))        // PCM
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))        // Mono
+	_ = binary.Write(buf, binary.LittleEndian, uint32(8000))     // Sample rate
+	_ = binary.Write(buf, binary.LittleEndian, uint32(byteRate)) // Byte rate
+	_ = binary.Write(buf, binary.LittleEndian, uint16(2))        // Block align
+	_ = binary.Write(buf, binary.LittleEndian, uint16(16))       // Bits per sample
+
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(seconds*byteRate))
+	buf.Write(make([]byte, seconds*byteRate))
+	return buf.Bytes()
+}
+
+func newTestMP4Box(boxType string, content []byte) []byte {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.BigEndian, uint32(8+len(content)))
+	buf.WriteString(boxType)
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func newTestMP4(timescale uint32, duration uint32) []byte {
+	mvhd := &bytes.Buffer{}
+	mvhd.Write([]byte{0, 0, 0, 0}) // Version and flags
+	_ = binary.Write(mvhd, binary.BigEndian, uint32(0))
+	_ = binary.Write(mvhd, binary.BigEndian, uint32(0))
+	_ = binary.Write(mvhd, binary.BigEndian, timescale)
+	_ = binary.Write(mvhd, binary.BigEndian, duration)
+	mvhd.Write(make([]byte, 80))
+
+	buf := &bytes.Buffer{}
+	buf.Write(newTestMP4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41")))
+	buf.Write(newTestMP4Box("moov", newTestMP4Box("mvhd", mvhd.Bytes())))
+	buf.Write(newTestMP4Box("mdat", make([]byte, 1024)))
+	return buf.Bytes()
+}
+
+func TestDetectMediaMetadataImage(t *testing.T) {
+	metadata := DetectMediaMetadata("image/png", newTestPNG(t, 320, 200))
+	require.Equal(t, 320, metadata.Width, "invalid width")
+	require.Equal(t, 200, metadata.Height, "invalid height")
+	require.Zero(t, metadata.Duration, "invalid duration")
+
+	metadata = DetectMediaMetadata("image/png", []byte("not an image"))
+	require.Zero(t, metadata.Width, "invalid width")
+}
+
+func TestDetectMediaMetadataWave(t *testing.T) {
+	data := newTestWave(3)
+	require.Equal(t, "audio/wave", http.DetectContentType(data))
+
+	metadata := DetectMediaMetadata("audio/wave", data[:MediaMetadataHeaderSize/2])
+	require.Equal(t, float64(3), metadata.Duration, "invalid duration")
+
+	metadata = DetectMediaMetadata("audio/wave", data[:20])
+	require.Zero(t, metadata.Duration, "invalid duration")
+}
+
+func TestDetectMediaMetadataMP4(t *testing.T) {
+	data := newTestMP4(1000, 12500)
+	require.Equal(t, "video/mp4", http.DetectContentType(data))
+
+	metadata := DetectMediaMetadata("video/mp4", data)
+	require.Equal(t, 12.5, metadata.Duration, "invalid duration")
+
+	// The moov box is not in the header
+	metadata = DetectMediaMetadata("video/mp4", data[:32])
+	require.Zero(t, metadata.Duration, "invalid duration")
+}
+
+func TestDetectMediaMetadataUnknownType(t *testing.T) {
+	metadata := DetectMediaMetadata("application/octet-stream", newTestMP4(1000, 12500))
+	require.Equal(t, &MediaMetadata{}, metadata)
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Policies to apply when a new upload does not fit in the user storage quota
+const (
+	QuotaExceededReject      = "reject"
+	QuotaExceededEvictOldest = "evict_oldest"
+)
+
+func (config *Configuration) initializeUserQuota() (err error) {
+	if config.MaxUserSizeStr != "" {
+		var maxUserSize uint64
+		maxUserSize, err = humanize.ParseBytes(config.MaxUserSizeStr)
+		if err != nil {
+			return fmt.Errorf("unable to parse MaxUserSizeStr : %s", err)
+		}
+		config.MaxUserSize = int64(maxUserSize)
+	}
+
+	if config.MaxUserSize < 0 {
+		return fmt.Errorf("invalid negative value for MaxUserSize")
+	}
+
+	switch config.QuotaExceededPolicy {
+	case QuotaExceededReject, QuotaExceededEvictOldest:
+		return nil
+	default:
+		return fmt.Errorf("invalid QuotaExceededPolicy %s, expected %s or %s", config.QuotaExceededPolicy, QuotaExceededReject, QuotaExceededEvictOldest)
+	}
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeUserQuota(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.initializeUserQuota())
+	require.Equal(t, int64(0), config.MaxUserSize, "invalid default user quota")
+
+	config.MaxUserSizeStr = "10KB"
+	config.QuotaExceededPolicy = QuotaExceededEvictOldest
+	require.NoError(t, config.initializeUserQuota())
+	require.Equal(t, int64(10000), config.MaxUserSize, "invalid user quota")
+
+	config.MaxUserSizeStr = "foo"
+	RequireError(t, config.initializeUserQuota(), "unable to parse MaxUserSizeStr")
+
+	config.MaxUserSizeStr = ""
+	config.MaxUserSize = -1
+	RequireError(t, config.initializeUserQuota(), "invalid negative value for MaxUserSize")
+
+	config.MaxUserSize = 0
+	config.QuotaExceededPolicy = "foo"
+	RequireError(t, config.initializeUserQuota(), "invalid QuotaExceededPolicy foo")
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+func (config *Configuration) initializeRedirectURLs() (err error) {
+	config.allowedRedirectURLs = nil
+	for _, allowed := range config.AllowedRedirectURLs {
+		allowedURL, err := url.Parse(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid allowed redirect URL %s : %s", allowed, err)
+		}
+		if (allowedURL.Scheme != "http" && allowedURL.Scheme != "https") || allowedURL.Host == "" {
+			return fmt.Errorf("invalid allowed redirect URL %s : expected an absolute http(s) URL", allowed)
+		}
+		config.allowedRedirectURLs = append(config.allowedRedirectURLs, allowedURL)
+	}
+
+	return nil
+}
+
+// IsAllowedRedirectURL check that users may be redirected to this URL after logging in
+// Without AllowedRedirectURLs only the host the request was sent to is allowed
+func (config *Configuration) IsAllowedRedirectURL(redirectURL *url.URL, host string) bool {
+	if redirectURL.Host == "" {
+		return false
+	}
+
+	if len(config.allowedRedirectURLs) == 0 {
+		return strings.EqualFold(redirectURL.Host, host)
+	}
+
+	for _, allowedURL := range config.allowedRedirectURLs {
+		if isURLUnder(redirectURL, allowedURL) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isURLUnder return true if the URL has the same scheme and host than the allowed URL
+// and the allowed URL path is a parent path of the URL path.
+// Dot segments are resolved before the paths are compared so "/allowed/../other" is not under "/allowed" and
+// encoded dot segments are rejected as the target server might decode them after the check
+func isURLUnder(URL *url.URL, allowedURL *url.URL) bool {
+	if allowedURL.Scheme != URL.Scheme || !strings.EqualFold(allowedURL.Host, URL.Host) {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(URL.RawPath), "%2e") {
+		return false
+	}
+
+	allowedPath := strings.TrimSuffix(allowedURL.Path, "/")
+	if allowedPath == "" {
+		return true
+	}
+
+	cleanPath := path.Clean("/" + URL.Path)
+	return cleanPath == allowedPath || strings.HasPrefix(cleanPath, allowedPath+"/")
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeConfigAllowedRedirectURLs(t *testing.T) {
+	config := NewConfiguration()
+	config.AllowedRedirectURLs = []string{"https://plik.root.gg"}
+
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Len(t, config.allowedRedirectURLs, 1, "invalid allowed redirect URLs")
+
+	config.AllowedRedirectURLs = []string{"plik.root.gg"}
+	err = config.Initialize()
+	RequireError(t, err, "invalid allowed redirect URL plik.root.gg")
+}
+
+func TestIsAllowedRedirectURL(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.NoError(t, err, "unable to parse URL")
+		return u
+	}
+
+	// Same origin only by default
+	require.True(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/callback"), "plik.root.gg"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://evil.com/callback"), "plik.root.gg"))
+	require.False(t, config.IsAllowedRedirectURL(parse("/callback"), ""))
+
+	config.AllowedRedirectURLs = []string{"https://plik.root.gg/plik/"}
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	require.True(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/callback"), "internal"))
+	require.True(t, config.IsAllowedRedirectURL(parse("https://PLIK.root.gg/plik"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plikevil/callback"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("http://plik.root.gg/plik/callback"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://internal/plik/callback"), "internal"))
+
+	// Dot segments can't escape the allowed path
+	require.True(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/./callback"), "internal"))
+	require.True(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/a/../callback"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/../admin"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/.."), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/%2e%2e/admin"), "internal"))
+	require.False(t, config.IsAllowedRedirectURL(parse("https://plik.root.gg/plik/%2E/callback"), "internal"))
+}
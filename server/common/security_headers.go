@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const webappContentSecurityPolicy = "default-src 'self'; script-src 'self'%[1]s; style-src 'self' 'unsafe-inline'%[1]s; img-src 'self' data:; connect-src 'self'%[1]s; frame-src 'self'%[1]s; font-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+// DefaultContentSecurityPolicy only allow the web UI to load its own resources and forbid embedding it in other sites
+var DefaultContentSecurityPolicy = fmt.Sprintf(webappContentSecurityPolicy, "")
+
+// DefaultDownloadContentSecurityPolicy prevent downloaded files from running scripts or loading any resource
+const DefaultDownloadContentSecurityPolicy = "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'self'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types; sandbox"
+
+// DefaultFrameOptions forbid embedding Plik in frames
+const DefaultFrameOptions = "DENY"
+
+// DefaultReferrerPolicy never leak upload URLs to other sites
+const DefaultReferrerPolicy = "no-referrer"
+
+// The CAPTCHA widgets load scripts, styles and frames from the CAPTCHA provider
+var captchaContentSecuritySources = map[string]string{
+	CaptchaHCaptcha:  " https://hcaptcha.com https://*.hcaptcha.com",
+	CaptchaReCaptcha: " https://www.google.com https://www.gstatic.com",
+}
+
+func (config *Configuration) initializeSecurityHeaders() {
+	// Only the default policy is adjusted, custom policies are used verbatim
+	if config.ContentSecurityPolicy == DefaultContentSecurityPolicy && config.CaptchaProvider != "" {
+		config.ContentSecurityPolicy = fmt.Sprintf(webappContentSecurityPolicy, captchaContentSecuritySources[config.CaptchaProvider])
+	}
+}
+
+// SecurityHeaders set the configured security headers on every response, empty values disable the header.
+// Handlers serving possibly unsafe content may override them with stricter values.
+func SecurityHeaders(config *Configuration, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("X-Content-Type-Options", "nosniff")
+		if config.ContentSecurityPolicy != "" {
+			resp.Header().Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if config.FrameOptions != "" {
+			resp.Header().Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			resp.Header().Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		handler.ServeHTTP(resp, req)
+	})
+}
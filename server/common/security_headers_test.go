@@ -0,0 +1,67 @@
+package common
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeadersDefault(t *testing.T) {
+	config := NewConfiguration()
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	req, err := http.NewRequest("GET", "/", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := httptest.NewRecorder()
+	SecurityHeaders(config, DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"), "invalid X-Content-Type-Options header")
+	require.Equal(t, DefaultContentSecurityPolicy, rr.Header().Get("Content-Security-Policy"), "invalid Content-Security-Policy header")
+	require.Equal(t, DefaultFrameOptions, rr.Header().Get("X-Frame-Options"), "invalid X-Frame-Options header")
+	require.Equal(t, DefaultReferrerPolicy, rr.Header().Get("Referrer-Policy"), "invalid Referrer-Policy header")
+}
+
+func TestSecurityHeadersOverride(t *testing.T) {
+	config := NewConfiguration()
+	config.ContentSecurityPolicy = "frame-ancestors https://intranet.root.gg"
+	config.FrameOptions = ""
+	config.ReferrerPolicy = "same-origin"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	req, err := http.NewRequest("GET", "/", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := httptest.NewRecorder()
+	SecurityHeaders(config, DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, "frame-ancestors https://intranet.root.gg", rr.Header().Get("Content-Security-Policy"), "invalid Content-Security-Policy header")
+	require.Equal(t, "same-origin", rr.Header().Get("Referrer-Policy"), "invalid Referrer-Policy header")
+	_, ok := rr.Header()["X-Frame-Options"]
+	require.False(t, ok, "disabled X-Frame-Options header should not be set")
+}
+
+func TestSecurityHeadersCaptcha(t *testing.T) {
+	config := NewConfiguration()
+	config.CaptchaProvider = CaptchaHCaptcha
+	config.CaptchaSiteKey = "site"
+	config.CaptchaSecret = "secret"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Contains(t, config.ContentSecurityPolicy, "https://hcaptcha.com", "default policy should allow the captcha provider")
+
+	config = NewConfiguration()
+	config.ContentSecurityPolicy = "default-src 'self'"
+	config.CaptchaProvider = CaptchaReCaptcha
+	config.CaptchaSiteKey = "site"
+	config.CaptchaSecret = "secret"
+	err = config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, "default-src 'self'", config.ContentSecurityPolicy, "custom policy should not be modified")
+}
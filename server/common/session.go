@@ -0,0 +1,39 @@
+package common
+
+import (
+	"time"
+)
+
+// Session is a web session opened by a user login, the session cookie is only valid as long as the session exists
+type Session struct {
+	ID     string `json:"id" gorm:"primary_key"`
+	UserID string `json:"-" gorm:"index:idx_session_user_id"`
+
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+
+	// Whether this is the session of the request
+	Current bool `json:"current" gorm:"-"`
+}
+
+// NewSession create a new session for the user
+func NewSession(user *User, ip string, userAgent string) (session *Session) {
+	// Browsers user agents are usually a few hundred characters
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	now := time.Now()
+	session = &Session{
+		ID:         GenerateRandomID(32),
+		UserID:     user.ID,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	return session
+}
@@ -3,6 +3,9 @@ package common
 // AuthenticationSignatureKeySettingKey setting key for authentication_signature_key
 const AuthenticationSignatureKeySettingKey = "authentication_signature_key"
 
+// ServerBannerSettingKey setting key for server_banner
+const ServerBannerSettingKey = "server_banner"
+
 // Setting is a config object meant to be shard by all Plik instances using the metadata backend
 type Setting struct {
 	Key   string `gorm:"primary_key"`
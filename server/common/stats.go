@@ -8,10 +8,37 @@ type ServerStats struct {
 	Files            int   `json:"files"`
 	TotalSize        int64 `json:"totalSize"`
 	AnonymousSize    int64 `json:"anonymousTotalSize"`
+	ExpiredUploads   int   `json:"expiredUploads"`
+	UploadsToday     int   `json:"uploadsToday"`
+
+	DataBackend   *BackendStats     `json:"dataBackend"`
+	StreamBackend *BackendStats     `json:"streamBackend"`
+	ClientApps    []*ClientAppStats `json:"clientApps,omitempty"`
 	//FileTypeByCount  []FileTypeByCount `json:"fileTypeByCount"`
 	//FileTypeBySize   []FileTypeBySize  `json:"fileTypeBySize"`
 }
 
+// BackendStats statistics of the uploads stored in one backend
+type BackendStats struct {
+	Name      string `json:"name"`
+	Uploads   int    `json:"uploads"`
+	Files     int    `json:"files"`
+	TotalSize int64  `json:"totalSize"`
+
+	CircuitBreaker string `json:"circuitBreaker,omitempty"`
+}
+
+// ClientAppStats statistics of the uploads created by one client app
+// Requests and RateLimited are counted in memory since the server started
+type ClientAppStats struct {
+	Name        string `json:"name"`
+	Uploads     int    `json:"uploads"`
+	Files       int    `json:"files"`
+	TotalSize   int64  `json:"totalSize"`
+	Requests    int64  `json:"requests"`
+	RateLimited int64  `json:"rateLimited"`
+}
+
 // UserStats user statistics
 type UserStats struct {
 	Uploads   int   `json:"uploads"`
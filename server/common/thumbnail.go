@@ -0,0 +1,151 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+
+	// Register the decoders of the supported image formats
+	_ "image/gif"
+	_ "image/png"
+)
+
+// ThumbnailFileIDSuffix is appended to the file ID to derive the data backend key of its thumbnail
+const ThumbnailFileIDSuffix = ".thumbnail"
+
+// DefaultThumbnailSize is the default maximum width and height of thumbnails in pixels
+const DefaultThumbnailSize = 256
+
+// ThumbnailMaxPixels is the size of the largest image to generate a thumbnail for, to not decode decompression bombs
+const ThumbnailMaxPixels = 50 * 1000 * 1000
+
+// ThumbnailContentType is the content type of the thumbnails
+const ThumbnailContentType = "image/jpeg"
+
+// IsThumbnailType return true if a thumbnail can be generated for this MIME type
+func IsThumbnailType(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// ThumbnailFile return the data backend blob storing the file thumbnail
+func (file *File) ThumbnailFile() *File {
+	return &File{
+		ID:             file.ID + ThumbnailFileIDSuffix,
+		UploadID:       file.UploadID,
+		Name:           file.Name,
+		Type:           ThumbnailContentType,
+		DataBackend:    file.DataBackend,
+		BackendDetails: file.BackendDetails,
+		CreatedAt:      file.CreatedAt,
+	}
+}
+
+func (config *Configuration) initializeThumbnails() (err error) {
+	if !config.GenerateThumbnails {
+		return nil
+	}
+	if config.ThumbnailSize <= 0 || config.ThumbnailSize > 2048 {
+		return fmt.Errorf("invalid ThumbnailSize %d, expected a size between 1 and 2048 pixels", config.ThumbnailSize)
+	}
+	return nil
+}
+
+// GenerateThumbnail decode the image and scale it down to fit in a size x size square
+// Images smaller than the thumbnail size are not scaled up
+func GenerateThumbnail(reader io.Reader, size int) (thumbnail []byte, err error) {
+	// Check the image dimensions before decoding the whole image
+	buf := &bytes.Buffer{}
+	imageConfig, _, err := image.DecodeConfig(io.TeeReader(reader, buf))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image : %s", err)
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 || imageConfig.Width*imageConfig.Height > ThumbnailMaxPixels {
+		return nil, fmt.Errorf("image is too large (%dx%d)", imageConfig.Width, imageConfig.Height)
+	}
+
+	src, _, err := image.Decode(io.MultiReader(buf, reader))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image : %s", err)
+	}
+
+	dst := scaleImage(src, size)
+
+	out := &bytes.Buffer{}
+	err = jpeg.Encode(out, dst, &jpeg.Options{Quality: 85})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode thumbnail : %s", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// scaleImage average the source pixels covered by each thumbnail pixel, transparent areas are rendered white
+func scaleImage(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	width, height := srcWidth, srcHeight
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, srcHeight*size/srcWidth
+		} else {
+			width, height = srcWidth*size/srcHeight, size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			// Colors are alpha-premultiplied, blend them over a white background
+			white := (n*0xffff - a)
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(((r + white) / n) >> 8),
+				G: uint8(((g + white) / n) >> 8),
+				B: uint8(((b + white) / n) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dst
+}
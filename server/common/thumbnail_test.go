@@ -0,0 +1,86 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPNG(t *testing.T, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 42, A: 255})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, img)
+	require.NoError(t, err, "unable to encode png")
+	return buf.Bytes()
+}
+
+func TestIsThumbnailType(t *testing.T) {
+	require.True(t, IsThumbnailType("image/png"))
+	require.True(t, IsThumbnailType("image/JPEG"))
+	require.True(t, IsThumbnailType("image/gif; charset=binary"))
+	require.False(t, IsThumbnailType("image/svg+xml"))
+	require.False(t, IsThumbnailType("text/plain"))
+	require.False(t, IsThumbnailType(""))
+}
+
+func TestFileThumbnailFile(t *testing.T) {
+	upload := &Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+	file.Type = "image/png"
+	file.BackendDetails = "details"
+
+	thumbnail := file.ThumbnailFile()
+	require.Equal(t, file.ID+ThumbnailFileIDSuffix, thumbnail.ID)
+	require.Equal(t, file.UploadID, thumbnail.UploadID)
+	require.Equal(t, ThumbnailContentType, thumbnail.Type)
+	require.Equal(t, file.BackendDetails, thumbnail.BackendDetails)
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	thumbnail, err := GenerateThumbnail(bytes.NewReader(newTestPNG(t, 400, 200)), 100)
+	require.NoError(t, err, "unable to generate thumbnail")
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	require.NoError(t, err, "invalid thumbnail")
+	require.Equal(t, 100, img.Bounds().Dx(), "invalid thumbnail width")
+	require.Equal(t, 50, img.Bounds().Dy(), "invalid thumbnail height")
+}
+
+func TestGenerateThumbnailSmallImage(t *testing.T) {
+	thumbnail, err := GenerateThumbnail(bytes.NewReader(newTestPNG(t, 10, 20)), 100)
+	require.NoError(t, err, "unable to generate thumbnail")
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	require.NoError(t, err, "invalid thumbnail")
+	require.Equal(t, 10, img.Bounds().Dx(), "small images should not be upscaled")
+	require.Equal(t, 20, img.Bounds().Dy(), "small images should not be upscaled")
+}
+
+func TestGenerateThumbnailInvalidImage(t *testing.T) {
+	_, err := GenerateThumbnail(bytes.NewReader([]byte("not an image")), 100)
+	require.Error(t, err, "missing error")
+}
+
+func TestConfigurationInitializeThumbnails(t *testing.T) {
+	config := NewConfiguration()
+	config.GenerateThumbnails = true
+	require.NoError(t, config.initializeThumbnails())
+
+	config.ThumbnailSize = 0
+	RequireError(t, config.initializeThumbnails(), "invalid ThumbnailSize")
+
+	config.GenerateThumbnails = false
+	require.NoError(t, config.initializeThumbnails())
+}
@@ -1,20 +1,32 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	uuid "github.com/nu7hatch/gouuid"
 )
 
+// ErrTokenAlreadyRotated is returned when rotating a token which has already been rotated
+var ErrTokenAlreadyRotated = errors.New("token has already been rotated")
+
 // Token provide a very basic authentication mechanism
 type Token struct {
 	Token   string `json:"token" gorm:"primary_key"`
 	Comment string `json:"comment,omitempty"`
 
+	// Web origins the token can be used from ( empty : no restriction )
+	AllowedOrigins TokenOrigins `json:"allowedOrigins,omitempty"`
+
 	UserID string `json:"-" gorm:"size:256;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
 
-	CreatedAt time.Time `json:"createdAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIP,omitempty"`
+
+	// Set once the token has been rotated, the token is invalid after this date
+	ExpireAt *time.Time `json:"expireAt,omitempty"`
 }
 
 // NewToken create a new Token instance
@@ -32,3 +44,17 @@ func (t *Token) Initialize() {
 	}
 	t.Token = token.String()
 }
+
+// Rotate return a new token with the same user, comment and allowed origins
+func (t *Token) Rotate() (rotated *Token) {
+	rotated = NewToken()
+	rotated.Comment = t.Comment
+	rotated.AllowedOrigins = t.AllowedOrigins
+	rotated.UserID = t.UserID
+	return rotated
+}
+
+// IsExpired check if the token has been rotated and its grace period is over
+func (t *Token) IsExpired() bool {
+	return t.ExpireAt != nil && time.Now().After(*t.ExpireAt)
+}
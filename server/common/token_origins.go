@@ -0,0 +1,92 @@
+package common
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TokenOrigins is the list of web origins ( ex : https://app.example.com ) a token can be used from.
+// It is stored as a JSON array.
+type TokenOrigins []string
+
+// Validate check and normalize the origins to their scheme://host[:port] form
+func (origins TokenOrigins) Validate() (err error) {
+	for i, origin := range origins {
+		origins[i], err = normalizeOrigin(origin)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allow return true if the request origin is one of the origins ( empty list : all origins are allowed )
+func (origins TokenOrigins) Allow(origin string) bool {
+	if len(origins) == 0 {
+		return true
+	}
+
+	origin, err := normalizeOrigin(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range origins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOrigin return the lower case scheme://host[:port] of an origin or of a referrer URL
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid origin %s", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// GormDataType store the origins in a string column
+func (origins TokenOrigins) GormDataType() string {
+	return "string"
+}
+
+// Value serialize the origins to the database
+func (origins TokenOrigins) Value() (driver.Value, error) {
+	if len(origins) == 0 {
+		return "", nil
+	}
+
+	serialized, err := json.Marshal(origins)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(serialized), nil
+}
+
+// Scan deserialize the origins from the database
+func (origins *TokenOrigins) Scan(value interface{}) (err error) {
+	var serialized []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		serialized = []byte(v)
+	case []byte:
+		serialized = v
+	default:
+		return fmt.Errorf("unable to scan token origins from %T", value)
+	}
+
+	*origins = nil
+	if len(serialized) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(serialized, origins)
+}
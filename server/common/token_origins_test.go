@@ -0,0 +1,49 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenOrigins_Validate(t *testing.T) {
+	require.NoError(t, TokenOrigins(nil).Validate())
+
+	origins := TokenOrigins{"https://App.Example.com/", "http://localhost:8080"}
+	require.NoError(t, origins.Validate())
+	require.Equal(t, TokenOrigins{"https://app.example.com", "http://localhost:8080"}, origins)
+
+	RequireError(t, TokenOrigins{"app.example.com"}.Validate(), "invalid origin app.example.com")
+	RequireError(t, TokenOrigins{"ftp://app.example.com"}.Validate(), "invalid origin ftp://app.example.com")
+}
+
+func TestTokenOrigins_Allow(t *testing.T) {
+	require.True(t, TokenOrigins(nil).Allow(""))
+	require.True(t, TokenOrigins(nil).Allow("https://evil.example.com"))
+
+	origins := TokenOrigins{"https://app.example.com"}
+	require.True(t, origins.Allow("https://app.example.com"))
+	require.True(t, origins.Allow("https://APP.example.com/upload?id=42"), "referrer should be allowed")
+	require.False(t, origins.Allow("https://evil.example.com"))
+	require.False(t, origins.Allow("http://app.example.com"))
+	require.False(t, origins.Allow(""))
+}
+
+func TestTokenOrigins_ValueScan(t *testing.T) {
+	value, err := TokenOrigins(nil).Value()
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+
+	value, err = TokenOrigins{"https://app.example.com"}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `["https://app.example.com"]`, value)
+
+	var origins TokenOrigins
+	require.NoError(t, origins.Scan(value))
+	require.Equal(t, TokenOrigins{"https://app.example.com"}, origins)
+
+	require.NoError(t, origins.Scan(nil))
+	require.Nil(t, origins)
+
+	require.Error(t, origins.Scan(42))
+}
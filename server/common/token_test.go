@@ -2,6 +2,7 @@ package common
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,3 +12,35 @@ func TestNewToken(t *testing.T) {
 	require.NotNil(t, token, "invalid token")
 	require.NotZero(t, token.Token, "missing token")
 }
+
+func TestTokenRotate(t *testing.T) {
+	token := NewToken()
+	token.Comment = "comment"
+	token.AllowedOrigins = TokenOrigins{"https://plik.root.gg"}
+	token.UserID = "user"
+	now := time.Now()
+	token.LastUsedAt = &now
+	token.LastUsedIP = "1.2.3.4"
+
+	rotated := token.Rotate()
+	require.NotZero(t, rotated.Token, "missing token")
+	require.NotEqual(t, token.Token, rotated.Token, "token should have changed")
+	require.Equal(t, token.Comment, rotated.Comment, "invalid comment")
+	require.Equal(t, token.AllowedOrigins, rotated.AllowedOrigins, "invalid allowed origins")
+	require.Equal(t, token.UserID, rotated.UserID, "invalid user")
+	require.Nil(t, rotated.LastUsedAt, "last used date should not be copied")
+	require.Zero(t, rotated.LastUsedIP, "last used ip should not be copied")
+}
+
+func TestTokenIsExpired(t *testing.T) {
+	token := NewToken()
+	require.False(t, token.IsExpired(), "token without expiration date should not be expired")
+
+	deadline := time.Now().Add(time.Hour)
+	token.ExpireAt = &deadline
+	require.False(t, token.IsExpired(), "token should not be expired yet")
+
+	deadline = time.Now().Add(-time.Hour)
+	token.ExpireAt = &deadline
+	require.True(t, token.IsExpired(), "token should be expired")
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// TOSCookieName is the cookie recording the terms of service acceptance of the browser session
+const TOSCookieName = "plik-tos"
+
+// TOSAcceptanceHeader is the request header carrying a signed terms of service acceptance for clients without cookies
+const TOSAcceptanceHeader = "X-Plik-TOS-Acceptance"
+
+// TOSVersionHeader is the response header telling which terms of service version has to be accepted
+const TOSVersionHeader = "X-Plik-TOS-Version"
+
+const tosAcceptanceType = "tos_acceptance"
+
+// TOSAcceptance records which version of the terms of service was accepted and when
+type TOSAcceptance struct {
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+	Token      string    `json:"token,omitempty"`
+}
+
+type tosAcceptanceClaims struct {
+	Type       string `json:"typ"`
+	Version    string `json:"tos_version"`
+	AcceptedAt int64  `json:"accepted_at"`
+}
+
+func (claims *tosAcceptanceClaims) Valid() error {
+	return nil
+}
+
+// HasAcceptedTOS return true if the user accepted the current version of the terms of service
+func (user *User) HasAcceptedTOS(version string) bool {
+	return user.TOSAcceptedVersion != "" && user.TOSAcceptedVersion == version
+}
+
+// SignTOSAcceptance generate the signed token of a terms of service acceptance
+func (sa *SessionAuthenticator) SignTOSAcceptance(acceptance *TOSAcceptance) (token string, err error) {
+	claims := &tosAcceptanceClaims{
+		Type:       tosAcceptanceType,
+		Version:    acceptance.Version,
+		AcceptedAt: acceptance.AcceptedAt.Unix(),
+	}
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(sa.SignatureKey))
+	if err != nil {
+		return "", fmt.Errorf("unable to sign terms of service acceptance : %s", err)
+	}
+
+	return token, nil
+}
+
+// ParseTOSAcceptance parse and verify the signature of a terms of service acceptance
+func (sa *SessionAuthenticator) ParseTOSAcceptance(token string) (acceptance *TOSAcceptance, err error) {
+	claims := &tosAcceptanceClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		// Verify signing algorithm
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected siging method : %v", t.Header["alg"])
+		}
+
+		return []byte(sa.SignatureKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Session cookies and upload links are signed with the same key
+	if claims.Type != tosAcceptanceType || claims.Version == "" {
+		return nil, fmt.Errorf("invalid terms of service acceptance")
+	}
+
+	acceptance = &TOSAcceptance{
+		Version:    claims.Version,
+		AcceptedAt: time.Unix(claims.AcceptedAt, 0),
+		Token:      token,
+	}
+
+	return acceptance, nil
+}
+
+// GenTOSCookie generate the session cookie recording a terms of service acceptance
+func (sa *SessionAuthenticator) GenTOSCookie(acceptance *TOSAcceptance) (cookie *http.Cookie, err error) {
+	token, err := sa.SignTOSAcceptance(acceptance)
+	if err != nil {
+		return nil, err
+	}
+
+	// No MaxAge, the acceptance lasts for the browser session
+	cookie = &http.Cookie{}
+	cookie.HttpOnly = true
+	cookie.Name = TOSCookieName
+	cookie.Value = token
+	cookie.Path = sa.Path
+	if sa.SecureCookies {
+		cookie.Secure = true
+	}
+
+	return cookie, nil
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOSAcceptanceSignAndParse(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key"}
+
+	acceptance := &TOSAcceptance{Version: "v1", AcceptedAt: time.Now().Truncate(time.Second)}
+	token, err := sa.SignTOSAcceptance(acceptance)
+	require.NoError(t, err)
+
+	parsed, err := sa.ParseTOSAcceptance(token)
+	require.NoError(t, err)
+	require.Equal(t, acceptance.Version, parsed.Version)
+	require.True(t, acceptance.AcceptedAt.Equal(parsed.AcceptedAt))
+	require.Equal(t, token, parsed.Token)
+
+	_, err = (&SessionAuthenticator{SignatureKey: "other"}).ParseTOSAcceptance(token)
+	require.Error(t, err, "terms of service acceptance signed with another key")
+
+	// Upload links are not terms of service acceptances
+	link, err := sa.SignUploadLink(&UploadLink{ID: "id", User: "local:user", ExpireAt: time.Now()})
+	require.NoError(t, err)
+	_, err = sa.ParseTOSAcceptance(link)
+	RequireError(t, err, "invalid terms of service acceptance")
+}
+
+func TestGenTOSCookie(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key", SecureCookies: true, Path: "/plik"}
+
+	cookie, err := sa.GenTOSCookie(&TOSAcceptance{Version: "v1", AcceptedAt: time.Now()})
+	require.NoError(t, err)
+	require.Equal(t, TOSCookieName, cookie.Name)
+	require.True(t, cookie.HttpOnly)
+	require.True(t, cookie.Secure)
+	require.Equal(t, "/plik", cookie.Path)
+	require.Equal(t, 0, cookie.MaxAge, "the acceptance must last for the browser session")
+
+	parsed, err := sa.ParseTOSAcceptance(cookie.Value)
+	require.NoError(t, err)
+	require.Equal(t, "v1", parsed.Version)
+}
+
+func TestUserHasAcceptedTOS(t *testing.T) {
+	user := &User{}
+	require.False(t, user.HasAcceptedTOS(""))
+	require.False(t, user.HasAcceptedTOS("v1"))
+
+	user.TOSAcceptedVersion = "v1"
+	require.True(t, user.HasAcceptedTOS("v1"))
+	require.False(t, user.HasAcceptedTOS("v2"), "a new version has to be accepted again")
+}
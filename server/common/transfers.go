@@ -0,0 +1,71 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTransferAborted is returned to the data backends when the client aborts a file transfer
+var ErrTransferAborted = errors.New("file transfer aborted")
+
+// TransferAbortTimeout is how long to wait for an aborted transfer to stop
+const TransferAbortTimeout = 30 * time.Second
+
+type transfer struct {
+	abort func()
+	done  chan struct{}
+}
+
+// Transfers keep track of the file transfers in flight on this server so they can be aborted
+type Transfers struct {
+	mu        sync.Mutex
+	transfers map[string]*transfer
+}
+
+// NewTransfers creates a new Transfers registry
+func NewTransfers() (transfers *Transfers) {
+	transfers = new(Transfers)
+	transfers.transfers = make(map[string]*transfer)
+	return transfers
+}
+
+// Register the transfer of a file, abort must make the transfer fail as soon as possible
+// The returned function must be called once the transfer is over
+func (transfers *Transfers) Register(fileID string, abort func()) (done func()) {
+	t := &transfer{abort: abort, done: make(chan struct{})}
+
+	transfers.mu.Lock()
+	transfers.transfers[fileID] = t
+	transfers.mu.Unlock()
+
+	return func() {
+		transfers.mu.Lock()
+		if transfers.transfers[fileID] == t {
+			delete(transfers.transfers, fileID)
+		}
+		transfers.mu.Unlock()
+		close(t.done)
+	}
+}
+
+// Abort the transfer of a file and wait for it to stop
+// Return false if the file is not being transferred by this server
+func (transfers *Transfers) Abort(fileID string) (aborted bool, err error) {
+	transfers.mu.Lock()
+	t, ok := transfers.transfers[fileID]
+	transfers.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	t.abort()
+
+	select {
+	case <-t.done:
+		return true, nil
+	case <-time.After(TransferAbortTimeout):
+		return true, errors.New("timeout waiting for the file transfer to stop")
+	}
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransfersAbort(t *testing.T) {
+	transfers := NewTransfers()
+
+	stop := make(chan struct{})
+	done := transfers.Register("file", func() { close(stop) })
+	go func() {
+		<-stop
+		done()
+	}()
+
+	aborted, err := transfers.Abort("file")
+	require.NoError(t, err)
+	require.True(t, aborted)
+
+	aborted, err = transfers.Abort("file")
+	require.NoError(t, err)
+	require.False(t, aborted, "transfer should be unregistered")
+}
+
+func TestTransfersAbortUnknown(t *testing.T) {
+	transfers := NewTransfers()
+
+	aborted, err := transfers.Abort("file")
+	require.NoError(t, err)
+	require.False(t, aborted)
+}
+
+func TestTransfersDone(t *testing.T) {
+	transfers := NewTransfers()
+
+	done := transfers.Register("file", func() { t.Fatal("transfer should not be aborted") })
+	done()
+
+	aborted, err := transfers.Abort("file")
+	require.NoError(t, err)
+	require.False(t, aborted)
+}
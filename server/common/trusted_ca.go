@@ -0,0 +1,49 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+func (config *Configuration) initializeTrustedCABundle() (err error) {
+	if config.TrustedCABundle == "" {
+		return nil
+	}
+
+	bundle, err := ioutil.ReadFile(config.TrustedCABundle)
+	if err != nil {
+		return fmt.Errorf("unable to read TrustedCABundle : %s", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("invalid TrustedCABundle %s : no PEM certificate found", config.TrustedCABundle)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	config.httpTransport = transport
+
+	return nil
+}
+
+// GetHTTPTransport return the transport to use for outbound connections, it trusts the TrustedCABundle if set
+func (config *Configuration) GetHTTPTransport() http.RoundTripper {
+	if config.httpTransport == nil {
+		return http.DefaultTransport
+	}
+	return config.httpTransport
+}
+
+// NewHTTPClient return an http client for outbound connections ( 0 : No timeout )
+func (config *Configuration) NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: config.GetHTTPTransport()}
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeTrustedCABundle(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.initializeTrustedCABundle(), "empty bundle should be valid")
+	require.Equal(t, http.DefaultTransport, config.GetHTTPTransport(), "invalid default transport")
+
+	dir, err := ioutil.TempDir("", "plik_trusted_ca_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	config.TrustedCABundle = filepath.Join(dir, "missing.pem")
+	RequireError(t, config.initializeTrustedCABundle(), "unable to read TrustedCABundle")
+
+	config.TrustedCABundle = filepath.Join(dir, "invalid.pem")
+	require.NoError(t, ioutil.WriteFile(config.TrustedCABundle, []byte("not a certificate"), 0600))
+	RequireError(t, config.initializeTrustedCABundle(), "no PEM certificate found")
+}
+
+func TestNewHTTPClientTrustedCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	config := NewConfiguration()
+	_, err := config.NewHTTPClient(time.Second).Get(server.URL)
+	RequireError(t, err, "certificate")
+
+	dir, err := ioutil.TempDir("", "plik_trusted_ca_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	config.TrustedCABundle = filepath.Join(dir, "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(config.TrustedCABundle, bundle, 0600))
+	require.NoError(t, config.initializeTrustedCABundle(), "unable to load bundle")
+
+	resp, err := config.NewHTTPClient(time.Second).Get(server.URL)
+	require.NoError(t, err, "server certificate should be trusted")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+}
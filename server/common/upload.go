@@ -2,14 +2,25 @@ package common
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
+	"github.com/root-gg/utils"
+
 	"gorm.io/gorm"
 )
 
+// ErrMetadataTooLarge when the metadata set by the client on an upload and its files exceed MaxUploadMetadataBytes
+var ErrMetadataTooLarge = errors.New("upload metadata too large")
+
 var (
-	randRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	randRunes          = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	lowerCaseRandRunes = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
 )
 
 // Upload object
@@ -18,16 +29,25 @@ type Upload struct {
 	TTL       int    `json:"ttl"`
 	ExtendTTL bool   `json:"extend_ttl"`
 
-	DownloadDomain string `json:"downloadDomain" gorm:"-"`
+	// Start the TTL clock when the last file upload completes rather than when the upload is created
+	TTLFromCompletion bool `json:"ttlFromCompletion,omitempty"`
+
+	// Expire the upload once it has not been accessed for that many seconds instead of after TTL
+	InactivityTTL int `json:"inactivityTTL,omitempty"`
+
+	DownloadDomain string `json:"downloadDomain"`
 	RemoteIP       string `json:"uploadIp,omitempty"`
 	Comments       string `json:"comments"`
 
 	Files []*File `json:"files"`
 
 	UploadToken string `json:"uploadToken,omitempty"`
-	User        string `json:"user,omitempty" gorm:"index:idx_upload_user"`
+	User        string `json:"user,omitempty" gorm:"index:idx_upload_user;uniqueIndex:idx_upload_user_idempotency_key,priority:1"`
 	Token       string `json:"token,omitempty" gorm:"index:idx_upload_user_token"`
 
+	IdempotencyKey  *string `json:"-" gorm:"uniqueIndex:idx_upload_user_idempotency_key,priority:2"`
+	IdempotencyHash string  `json:"-"`
+
 	IsAdmin bool `json:"admin" gorm:"-"`
 
 	Stream    bool `json:"stream"`
@@ -38,9 +58,70 @@ type Upload struct {
 	Login               string `json:"login,omitempty"`
 	Password            string `json:"password,omitempty"`
 
+	ManagementPassword string `json:"managementPassword,omitempty"`
+
+	// Signed link removing the upload without a token, only returned on creation if ManagementLinkSecret is set
+	ManagementURL string `json:"managementURL,omitempty" gorm:"-"`
+
+	Public bool `json:"public"`
+
+	// Files can't be downloaded anymore once that many bytes have been served for the upload, 0 means unlimited
+	MaxTotalDownloadBytes int64 `json:"maxTotalDownloadBytes,omitempty"`
+	DownloadedBytes       int64 `json:"downloadedBytes,omitempty"`
+
+	// Number of downloads of the upload files and archives
+	DownloadCount int `json:"downloadCount,omitempty"`
+
+	// Data backend explicitly chosen by the client to store the upload files
+	DataBackend string `json:"dataBackend,omitempty"`
+
+	// Display the upload files inline or download them as attachments, empty to use the server configuration
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+
+	// Client app that created the upload using its API key
+	ClientApp string `json:"clientApp,omitempty"`
+
+	// Upload preset selected by the client, its file extension policy applies to all the upload files
+	Preset string `json:"preset,omitempty"`
+
+	// Comma separated ISO 3166 country codes the files can or can't be downloaded from ( ex : "FR,DE" )
+	// The server defaults apply if empty
+	AllowedCountries string `json:"allowedCountries,omitempty"`
+	BlockedCountries string `json:"blockedCountries,omitempty"`
+
+	// Extract the zip and tar archives added to the upload as separate files, keeping the archives or not
+	ExpandArchives bool `json:"expandArchives,omitempty"`
+	KeepArchives   bool `json:"keepArchives,omitempty"`
+
+	// Download and expiry events of the upload are also posted to this URL, it must match the UploadWebhookAllowlist
+	Webhook string `json:"webhook,omitempty"`
+
+	// Opaque key/value pairs set by the client on creation
+	UserMetadata UserMetadata `json:"userMetadata,omitempty"`
+
+	// Upload link used to create the upload, each link creates only one upload
+	UploadLinkID *string `json:"-" gorm:"uniqueIndex:idx_upload_link_id"`
+	UploadLink   string  `json:"-"`
+
 	CreatedAt time.Time      `json:"createdAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index:idx_upload_deleted_at"`
 	ExpireAt  *time.Time     `json:"expireAt" gorm:"index:idx_upload_expire_at"`
+
+	// Date the last byte of the last uploaded file was received
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+
+	// Date of the last download of a file of the upload ( updated at most once per UploadAccessUpdateInterval )
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+
+	// Set once the expiry warning has been sent, reset when the expiration date is extended
+	ExpiryWarningSent bool `json:"-"`
+
+	// Downloads not notified yet and date of the first one, reset when the download notification is sent
+	PendingDownloads      int        `json:"-"`
+	PendingDownloadsSince *time.Time `json:"-"`
+
+	// Set each time a file upload completes, reset when the upload ready notification is sent
+	ReadyNotificationPending bool `json:"-"`
 }
 
 // NewUpload creates a new upload object
@@ -51,11 +132,31 @@ func NewUpload() (upload *Upload) {
 	return upload
 }
 
+// GetMetadataSize return the size in bytes of the metadata set by the client on the upload, files excluded
+func (upload *Upload) GetMetadataSize() (size int) {
+	size = len(upload.Comments) + len(upload.Login) + len(upload.DownloadDomain) + len(upload.DataBackend) +
+		len(upload.ContentDisposition) + len(upload.Preset) + len(upload.AllowedCountries) + len(upload.BlockedCountries) + len(upload.Webhook)
+
+	if len(upload.UserMetadata) > 0 {
+		serialized, err := json.Marshal(upload.UserMetadata)
+		if err == nil {
+			size += len(serialized)
+		}
+	}
+
+	return size
+}
+
 // GenerateID generate a new Upload ID and UploadToken
 func (upload *Upload) GenerateID() {
 	upload.ID = GenerateRandomID(16)
 }
 
+// GenerateLowerCaseID generate a new lower case Upload ID
+func (upload *Upload) GenerateLowerCaseID() {
+	upload.ID = GenerateRandomLowerCaseID(16)
+}
+
 // GenerateUploadToken generate a new UploadToken
 func (upload *Upload) GenerateUploadToken() {
 	upload.UploadToken = GenerateRandomID(32)
@@ -91,45 +192,147 @@ func (upload *Upload) GetFileByReference(ref string) (file *File) {
 	return nil
 }
 
+// UploadPrecheck tells whether an upload would be accepted by the server
+type UploadPrecheck struct {
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
 // Sanitize clear some fields to hide sensible information from the API.
 func (upload *Upload) Sanitize(config *Configuration) {
 	upload.RemoteIP = ""
 	upload.Login = ""
 	upload.Password = ""
+	upload.ManagementPassword = ""
 	upload.User = ""
 	upload.Token = ""
 
 	if !upload.IsAdmin {
 		upload.UploadToken = ""
+		upload.DownloadedBytes = 0
+		upload.DownloadCount = 0
+		upload.Webhook = ""
+	}
+
+	// Uploads not pinned to a download domain use the default one
+	if upload.DownloadDomain == "" {
+		upload.DownloadDomain = config.DownloadDomain
 	}
 
-	upload.DownloadDomain = config.DownloadDomain
 	for _, file := range upload.Files {
 		file.Sanitize()
 	}
 }
 
+// CheckBasicAuth check the credentials of a password protected upload
+// Authorization header must be set to "Basic base64("login:password")". Only the md5sum
+// of the base64 string is saved in the upload metadata
+func (upload *Upload) CheckBasicAuth(authorization string) (err error) {
+	if authorization == "" {
+		return fmt.Errorf("missing Authorization header")
+	}
+
+	auth := strings.Split(authorization, " ")
+	if len(auth) != 2 {
+		return fmt.Errorf("invalid Authorization header")
+	}
+	if auth[0] != "Basic" {
+		return fmt.Errorf("invalid http authorization scheme")
+	}
+
+	md5sum, err := utils.Md5sum(auth[1])
+	if err != nil {
+		return fmt.Errorf("unable to hash credentials")
+	}
+	if subtle.ConstantTimeCompare([]byte(md5sum), []byte(upload.Password)) != 1 {
+		return fmt.Errorf("invalid credentials")
+	}
+
+	return nil
+}
+
+// IsProtectedByManagementPassword return true if managing the upload requires the management password
+func (upload *Upload) IsProtectedByManagementPassword() bool {
+	return upload.ManagementPassword != ""
+}
+
+// CheckManagementPassword check the management password of an upload
+// Only the bcrypt hash of the management password is saved in the upload metadata
+func (upload *Upload) CheckManagementPassword(password string) (err error) {
+	if !upload.IsProtectedByManagementPassword() {
+		return fmt.Errorf("upload is not protected by a management password")
+	}
+	if password == "" {
+		return fmt.Errorf("missing management password")
+	}
+	if !CheckPasswordHash(password, upload.ManagementPassword) {
+		return fmt.Errorf("invalid management password")
+	}
+
+	return nil
+}
+
 // GenerateRandomID generates a random string with specified length.
 // Used to generate upload id, tokens, ...
 func GenerateRandomID(length int) string {
-	max := *big.NewInt(int64(len(randRunes)))
+	return generateRandomString(randRunes, length)
+}
+
+// GenerateRandomLowerCaseID generates a random lower case string with specified length.
+// Used to generate upload ids which can be looked up case insensitively
+func GenerateRandomLowerCaseID(length int) string {
+	return generateRandomString(lowerCaseRandRunes, length)
+}
+
+func generateRandomString(runes []rune, length int) string {
+	max := *big.NewInt(int64(len(runes)))
 	b := make([]rune, length)
 	for i := range b {
 		n, _ := rand.Int(rand.Reader, &max)
-		b[i] = randRunes[n.Int64()]
+		b[i] = runes[n.Int64()]
 	}
 
 	return string(b)
 }
 
-// ExtendExpirationDate extends the upload expiration date by TTL
+// UploadAccessUpdateInterval throttle the upload last access updates to not write to the DB on every download
+const UploadAccessUpdateInterval = time.Minute
+
+// ExtendExpirationDate extends the upload expiration date by TTL ( or InactivityTTL if set )
 func (upload *Upload) ExtendExpirationDate() {
-	if upload.TTL > 0 {
+	if upload.InactivityTTL > 0 {
+		deadline := time.Now().Add(time.Duration(upload.InactivityTTL) * time.Second)
+		upload.ExpireAt = &deadline
+	} else if upload.TTL > 0 {
 		deadline := time.Now().Add(time.Duration(upload.TTL) * time.Second)
 		upload.ExpireAt = &deadline
 	}
 }
 
+// SetAccessed record a download of the upload, the expiration date is pushed back by InactivityTTL if set
+// Return false if the last access is too recent to be worth saving
+func (upload *Upload) SetAccessed(accessedAt time.Time) bool {
+	if upload.LastAccessedAt != nil && accessedAt.Sub(*upload.LastAccessedAt) < UploadAccessUpdateInterval {
+		return false
+	}
+
+	upload.LastAccessedAt = &accessedAt
+	if upload.InactivityTTL > 0 {
+		deadline := accessedAt.Add(time.Duration(upload.InactivityTTL) * time.Second)
+		upload.ExpireAt = &deadline
+	}
+	return true
+}
+
+// SetCompleted record the end of a file upload, the TTL clock starts over if TTLFromCompletion is set
+func (upload *Upload) SetCompleted(completedAt time.Time) {
+	upload.CompletedAt = &completedAt
+	if upload.TTLFromCompletion && upload.TTL > 0 && upload.InactivityTTL == 0 {
+		deadline := completedAt.Add(time.Duration(upload.TTL) * time.Second)
+		upload.ExpireAt = &deadline
+	}
+}
+
 // IsExpired check if the upload is expired
 func (upload *Upload) IsExpired() bool {
 	if upload.ExpireAt != nil {
@@ -140,6 +343,11 @@ func (upload *Upload) IsExpired() bool {
 	return false
 }
 
+// IsDownloadQuotaExceeded check if the upload maximum total download bytes has been served
+func (upload *Upload) IsDownloadQuotaExceeded() bool {
+	return upload.MaxTotalDownloadBytes > 0 && upload.DownloadedBytes >= upload.MaxTotalDownloadBytes
+}
+
 // InitializeForTests initialize upload for database insert without config checks and override for testing purpose
 func (upload *Upload) InitializeForTests() {
 	if upload.ID == "" {
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// UploadLinkHeader is the request header carrying a signed upload link
+const UploadLinkHeader = "X-UploadLink"
+
+// UploadLinkDefaultValidity is how long upload links can be used when no validity is requested ( in seconds )
+const UploadLinkDefaultValidity = 24 * 60 * 60
+
+// UploadLinkMaxValidity is the maximum validity of upload links ( in seconds )
+const UploadLinkMaxValidity = 30 * 24 * 60 * 60
+
+const uploadLinkType = "upload_link"
+
+// UploadLink let anyone knowing the signed link create exactly one upload on behalf of a user
+// The link constraints apply on top of the user limits
+type UploadLink struct {
+	ID                string    `json:"id"`
+	User              string    `json:"user"`
+	Validity          int       `json:"validity,omitempty"`          // Validity of the link in seconds, only used to create the link
+	MaxFileSize       int64     `json:"maxFileSize,omitempty"`       // 0 : user limit
+	MaxTTL            int       `json:"maxTTL,omitempty"`            // 0 : user limit
+	AllowedExtensions []string  `json:"allowedExtensions,omitempty"` // ex : [".pdf", ".png"] ( empty : all extensions are allowed )
+	ExpireAt          time.Time `json:"expireAt"`
+	Token             string    `json:"token,omitempty"`
+
+	// User creating the upload, set when the link is used
+	Owner *User `json:"-"`
+}
+
+// IsAllowedFileName return true if the file extension is allowed by the upload link
+func (link *UploadLink) IsAllowedFileName(name string) bool {
+	return isAllowedExtension(name, link.AllowedExtensions)
+}
+
+// IsExpired return true if the upload link can't be used anymore
+func (link *UploadLink) IsExpired() bool {
+	return time.Now().After(link.ExpireAt)
+}
+
+// The expiration date is not a standard "exp" claim so the constraints of the
+// uploads created with a link can still be read after the link has expired
+type uploadLinkClaims struct {
+	Type              string   `json:"typ"`
+	ID                string   `json:"link_id"`
+	User              string   `json:"uid"`
+	MaxFileSize       int64    `json:"max_file_size,omitempty"`
+	MaxTTL            int      `json:"max_ttl,omitempty"`
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+	ExpireAt          int64    `json:"expire_at"`
+}
+
+func (claims *uploadLinkClaims) Valid() error {
+	return nil
+}
+
+// SignUploadLink generate the signed token of an upload link
+func (sa *SessionAuthenticator) SignUploadLink(link *UploadLink) (token string, err error) {
+	claims := &uploadLinkClaims{
+		Type:              uploadLinkType,
+		ID:                link.ID,
+		User:              link.User,
+		MaxFileSize:       link.MaxFileSize,
+		MaxTTL:            link.MaxTTL,
+		AllowedExtensions: link.AllowedExtensions,
+		ExpireAt:          link.ExpireAt.Unix(),
+	}
+
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString([]byte(sa.SignatureKey))
+	if err != nil {
+		return "", fmt.Errorf("unable to sign upload link : %s", err)
+	}
+
+	return token, nil
+}
+
+// ParseUploadLink parse and verify the signature of an upload link, the expiration date is not checked
+func (sa *SessionAuthenticator) ParseUploadLink(token string) (link *UploadLink, err error) {
+	claims := &uploadLinkClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		// Verify signing algorithm
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected siging method : %v", t.Header["alg"])
+		}
+
+		return []byte(sa.SignatureKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Session cookies are signed with the same key
+	if claims.Type != uploadLinkType || claims.ID == "" || claims.User == "" {
+		return nil, fmt.Errorf("invalid upload link")
+	}
+
+	link = &UploadLink{
+		ID:                claims.ID,
+		User:              claims.User,
+		MaxFileSize:       claims.MaxFileSize,
+		MaxTTL:            claims.MaxTTL,
+		AllowedExtensions: claims.AllowedExtensions,
+		ExpireAt:          time.Unix(claims.ExpireAt, 0),
+		Token:             token,
+	}
+
+	return link, nil
+}
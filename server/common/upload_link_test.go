@@ -0,0 +1,76 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadLinkSignAndParse(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key"}
+
+	link := &UploadLink{
+		ID:                "id",
+		User:              "local:user",
+		MaxFileSize:       1000,
+		MaxTTL:            3600,
+		AllowedExtensions: []string{".pdf"},
+		ExpireAt:          time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+
+	token, err := sa.SignUploadLink(link)
+	require.NoError(t, err)
+
+	parsed, err := sa.ParseUploadLink(token)
+	require.NoError(t, err)
+	require.Equal(t, link.ID, parsed.ID)
+	require.Equal(t, link.User, parsed.User)
+	require.Equal(t, link.MaxFileSize, parsed.MaxFileSize)
+	require.Equal(t, link.MaxTTL, parsed.MaxTTL)
+	require.Equal(t, link.AllowedExtensions, parsed.AllowedExtensions)
+	require.True(t, link.ExpireAt.Equal(parsed.ExpireAt))
+	require.Equal(t, token, parsed.Token)
+	require.False(t, parsed.IsExpired())
+
+	_, err = (&SessionAuthenticator{SignatureKey: "other"}).ParseUploadLink(token)
+	require.Error(t, err, "upload link signed with another key")
+
+	// Upload links are not session cookies
+	_, _, _, err = sa.ParseSessionCookie(token)
+	require.Error(t, err)
+}
+
+func TestUploadLinkParseSessionCookie(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key", SessionTimeout: 3600}
+
+	user := NewUser(ProviderLocal, "user")
+	sessionCookie, _, err := sa.GenAuthCookies(user, NewSession(user, "", ""))
+	require.NoError(t, err)
+
+	_, err = sa.ParseUploadLink(sessionCookie.Value)
+	RequireError(t, err, "invalid upload link")
+}
+
+func TestUploadLinkExpired(t *testing.T) {
+	sa := &SessionAuthenticator{SignatureKey: "key"}
+
+	token, err := sa.SignUploadLink(&UploadLink{ID: "id", User: "local:user", ExpireAt: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+
+	// Expired links can still be parsed to enforce the constraints of the uploads created with them
+	link, err := sa.ParseUploadLink(token)
+	require.NoError(t, err)
+	require.True(t, link.IsExpired())
+}
+
+func TestUploadLinkIsAllowedFileName(t *testing.T) {
+	link := &UploadLink{}
+	require.True(t, link.IsAllowedFileName("foo.exe"))
+
+	link.AllowedExtensions = []string{".pdf", ".PNG"}
+	require.True(t, link.IsAllowedFileName("foo.pdf"))
+	require.True(t, link.IsAllowedFileName("foo.png"))
+	require.False(t, link.IsAllowedFileName("foo.exe"))
+	require.False(t, link.IsAllowedFileName("foo"))
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"time"
+)
+
+// UploadPasswordAttempts count the failed password attempts on a password protected upload.
+// Attempts are counted per client IP address if UploadPasswordAttemptsPerIP is enabled, the IP is empty otherwise
+type UploadPasswordAttempts struct {
+	UploadID string `gorm:"primary_key"`
+	IP       string `gorm:"primary_key"`
+
+	Failures    int
+	LockedUntil *time.Time
+	UpdatedAt   time.Time
+}
+
+// IsLocked return true if the password can't be tried until the end of the lockout
+func (attempts *UploadPasswordAttempts) IsLocked(now time.Time) bool {
+	return attempts.LockedUntil != nil && now.Before(*attempts.LockedUntil)
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"fmt"
+)
+
+// UploadPreset is a named set of upload settings clients can select when creating an upload
+type UploadPreset struct {
+	Name              string   `json:"name"`
+	TTLStr            string   `json:"-"`
+	TTL               int      `json:"ttl"`
+	OneShot           bool     `json:"oneShot"`
+	RequirePassword   bool     `json:"requirePassword"`
+	AllowedExtensions []string `json:"allowedExtensions,omitempty"` // ex : [".pdf", ".png"] ( empty : all extensions are allowed )
+	LockTTL           bool     `json:"lockTTL"`                     // Clients can't override the preset TTL
+	LockOneShot       bool     `json:"lockOneShot"`                 // Clients can't override the preset OneShot setting
+}
+
+// IsAllowedFileName return true if the file extension is allowed by the upload preset
+func (preset *UploadPreset) IsAllowedFileName(name string) bool {
+	return isAllowedExtension(name, preset.AllowedExtensions)
+}
+
+// Apply set the preset settings as default values of the upload params
+func (preset *UploadPreset) Apply(params *Upload) {
+	params.Preset = preset.Name
+	params.TTL = preset.TTL
+	params.OneShot = preset.OneShot
+}
+
+// Check that the upload params comply with the preset
+func (preset *UploadPreset) Check(params *Upload) error {
+	if preset.LockTTL && params.TTL != preset.TTL {
+		return fmt.Errorf("TTL is locked by upload preset %s", preset.Name)
+	}
+	if preset.LockOneShot && params.OneShot != preset.OneShot {
+		return fmt.Errorf("one shot setting is locked by upload preset %s", preset.Name)
+	}
+	if preset.RequirePassword && params.Password == "" {
+		return fmt.Errorf("upload preset %s requires a password", preset.Name)
+	}
+	return nil
+}
+
+func (config *Configuration) initializeUploadPresets() (err error) {
+	names := make(map[string]bool)
+	for _, preset := range config.UploadPresets {
+		if preset.Name == "" {
+			return fmt.Errorf("missing upload preset name")
+		}
+		if names[preset.Name] {
+			return fmt.Errorf("duplicate upload preset name %s", preset.Name)
+		}
+		names[preset.Name] = true
+
+		if preset.TTLStr != "" {
+			preset.TTL, err = ParseTTL(preset.TTLStr)
+			if err != nil {
+				return fmt.Errorf("unable to parse TTL of upload preset %s : %s", preset.Name, err)
+			}
+		}
+
+		if preset.OneShot && config.FeatureOneShot == FeatureDisabled {
+			return fmt.Errorf("upload preset %s is one shot but one shot uploads are disabled", preset.Name)
+		}
+		if preset.RequirePassword && config.FeaturePassword == FeatureDisabled {
+			return fmt.Errorf("upload preset %s requires a password but upload password protection is disabled", preset.Name)
+		}
+	}
+
+	return nil
+}
+
+// GetUploadPreset return the named upload preset or nil
+func (config *Configuration) GetUploadPreset(name string) *UploadPreset {
+	for _, preset := range config.UploadPresets {
+		if preset.Name == name {
+			return preset
+		}
+	}
+	return nil
+}
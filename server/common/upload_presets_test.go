@@ -0,0 +1,82 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializeUploadPresets(t *testing.T) {
+	config := NewConfiguration()
+	config.UploadPresets = []*UploadPreset{{Name: "confidential", TTLStr: "1d", OneShot: true, RequirePassword: true}}
+	err := config.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, 86400, config.UploadPresets[0].TTL)
+
+	config = NewConfiguration()
+	config.UploadPresets = []*UploadPreset{{TTLStr: "1d"}}
+	RequireError(t, config.Initialize(), "missing upload preset name")
+
+	config = NewConfiguration()
+	config.UploadPresets = []*UploadPreset{{Name: "confidential"}, {Name: "confidential"}}
+	RequireError(t, config.Initialize(), "duplicate upload preset name confidential")
+
+	config = NewConfiguration()
+	config.UploadPresets = []*UploadPreset{{Name: "confidential", TTLStr: "foo"}}
+	RequireError(t, config.Initialize(), "unable to parse TTL of upload preset confidential")
+
+	config = NewConfiguration()
+	config.FeatureOneShot = FeatureDisabled
+	config.UploadPresets = []*UploadPreset{{Name: "confidential", OneShot: true}}
+	RequireError(t, config.Initialize(), "one shot uploads are disabled")
+
+	config = NewConfiguration()
+	config.FeaturePassword = FeatureDisabled
+	config.UploadPresets = []*UploadPreset{{Name: "confidential", RequirePassword: true}}
+	RequireError(t, config.Initialize(), "upload password protection is disabled")
+}
+
+func TestGetUploadPreset(t *testing.T) {
+	config := NewConfiguration()
+	config.UploadPresets = []*UploadPreset{{Name: "confidential"}}
+
+	require.Equal(t, "confidential", config.GetUploadPreset("confidential").Name)
+	require.Nil(t, config.GetUploadPreset("foo"))
+	require.Nil(t, config.GetUploadPreset(""))
+}
+
+func TestUploadPresetApply(t *testing.T) {
+	preset := &UploadPreset{Name: "confidential", TTL: 86400, OneShot: true}
+
+	params := &Upload{}
+	preset.Apply(params)
+	require.Equal(t, "confidential", params.Preset)
+	require.Equal(t, 86400, params.TTL)
+	require.True(t, params.OneShot)
+}
+
+func TestUploadPresetCheck(t *testing.T) {
+	preset := &UploadPreset{Name: "confidential", TTL: 86400, OneShot: true}
+	require.NoError(t, preset.Check(&Upload{TTL: 3600, OneShot: false}), "unlocked settings can be overridden")
+
+	preset.LockTTL = true
+	require.NoError(t, preset.Check(&Upload{TTL: 86400}))
+	RequireError(t, preset.Check(&Upload{TTL: 3600}), "TTL is locked by upload preset confidential")
+
+	preset.LockOneShot = true
+	require.NoError(t, preset.Check(&Upload{TTL: 86400, OneShot: true}))
+	RequireError(t, preset.Check(&Upload{TTL: 86400, OneShot: false}), "one shot setting is locked by upload preset confidential")
+
+	preset.RequirePassword = true
+	require.NoError(t, preset.Check(&Upload{TTL: 86400, OneShot: true, Password: "secret"}))
+	RequireError(t, preset.Check(&Upload{TTL: 86400, OneShot: true}), "upload preset confidential requires a password")
+}
+
+func TestUploadPresetIsAllowedFileName(t *testing.T) {
+	preset := &UploadPreset{Name: "confidential"}
+	require.True(t, preset.IsAllowedFileName("file.exe"))
+
+	preset.AllowedExtensions = []string{".pdf"}
+	require.True(t, preset.IsAllowedFileName("report.PDF"))
+	require.False(t, preset.IsAllowedFileName("file.exe"))
+}
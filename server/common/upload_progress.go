@@ -0,0 +1,158 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// UploadProgressInterval is the minimum delay between two progress updates of a file
+const UploadProgressInterval = 200 * time.Millisecond
+
+const uploadProgressBufferSize = 32
+
+// UploadProgress of a file being uploaded
+type UploadProgress struct {
+	FileID   string `json:"fileId"`
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size,omitempty"`
+	Received int64  `json:"received"`
+	Done     bool   `json:"done"`
+}
+
+// UploadProgressBroker is an in-memory pub/sub of the progress of the files being uploaded keyed by upload ID
+type UploadProgressBroker struct {
+	mu          sync.Mutex
+	inFlight    map[string]map[string]*UploadProgress
+	subscribers map[string]map[chan *UploadProgress]struct{}
+}
+
+// NewUploadProgressBroker creates a new UploadProgressBroker
+func NewUploadProgressBroker() (broker *UploadProgressBroker) {
+	broker = new(UploadProgressBroker)
+	broker.inFlight = make(map[string]map[string]*UploadProgress)
+	broker.subscribers = make(map[string]map[chan *UploadProgress]struct{})
+	return broker
+}
+
+// Subscribe to the progress of an upload files
+// Return the current progress of the files in flight and a cancel function to call once done
+func (broker *UploadProgressBroker) Subscribe(uploadID string) (ch <-chan *UploadProgress, snapshot []*UploadProgress, cancel func()) {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	c := make(chan *UploadProgress, uploadProgressBufferSize)
+	if broker.subscribers[uploadID] == nil {
+		broker.subscribers[uploadID] = make(map[chan *UploadProgress]struct{})
+	}
+	broker.subscribers[uploadID][c] = struct{}{}
+
+	for _, progress := range broker.inFlight[uploadID] {
+		p := *progress
+		snapshot = append(snapshot, &p)
+	}
+
+	cancel = func() {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+
+		delete(broker.subscribers[uploadID], c)
+		if len(broker.subscribers[uploadID]) == 0 {
+			delete(broker.subscribers, uploadID)
+		}
+	}
+
+	return c, snapshot, cancel
+}
+
+// IsInFlight return true if some files of the upload are being uploaded
+func (broker *UploadProgressBroker) IsInFlight(uploadID string) bool {
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	return len(broker.inFlight[uploadID]) > 0
+}
+
+// Track the progress of a file upload, the tracker must be marked as done once the upload ends
+func (broker *UploadProgressBroker) Track(file *File) (tracker *UploadProgressTracker) {
+	tracker = new(UploadProgressTracker)
+	tracker.broker = broker
+	tracker.uploadID = file.UploadID
+	tracker.progress = UploadProgress{FileID: file.ID, FileName: file.Name, Size: file.Size}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+
+	if broker.inFlight[file.UploadID] == nil {
+		broker.inFlight[file.UploadID] = make(map[string]*UploadProgress)
+	}
+	broker.inFlight[file.UploadID][file.ID] = &tracker.progress
+	broker.publish(tracker.uploadID, tracker.progress)
+
+	return tracker
+}
+
+// publish must be called with the lock held
+// Slow subscribers miss intermediate updates but always get the final one
+func (broker *UploadProgressBroker) publish(uploadID string, progress UploadProgress) {
+	for c := range broker.subscribers[uploadID] {
+		p := progress
+		select {
+		case c <- &p:
+		default:
+			if progress.Done {
+				// Make room by dropping the oldest update
+				select {
+				case <-c:
+				default:
+				}
+				c <- &p
+			}
+		}
+	}
+}
+
+// UploadProgressTracker publish the progress of a file upload, a nil tracker does nothing
+type UploadProgressTracker struct {
+	broker   *UploadProgressBroker
+	uploadID string
+	progress UploadProgress
+	last     time.Time
+}
+
+// Update the number of bytes received, updates are rate limited to one every UploadProgressInterval
+func (tracker *UploadProgressTracker) Update(received int64) {
+	if tracker == nil {
+		return
+	}
+
+	tracker.broker.mu.Lock()
+	defer tracker.broker.mu.Unlock()
+
+	tracker.progress.Received = received
+
+	now := time.Now()
+	if now.Sub(tracker.last) < UploadProgressInterval {
+		return
+	}
+	tracker.last = now
+
+	tracker.broker.publish(tracker.uploadID, tracker.progress)
+}
+
+// Done publish the final progress of the file upload
+func (tracker *UploadProgressTracker) Done() {
+	if tracker == nil {
+		return
+	}
+
+	tracker.broker.mu.Lock()
+	defer tracker.broker.mu.Unlock()
+
+	delete(tracker.broker.inFlight[tracker.uploadID], tracker.progress.FileID)
+	if len(tracker.broker.inFlight[tracker.uploadID]) == 0 {
+		delete(tracker.broker.inFlight, tracker.uploadID)
+	}
+
+	tracker.progress.Done = true
+	tracker.broker.publish(tracker.uploadID, tracker.progress)
+}
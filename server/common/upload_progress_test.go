@@ -0,0 +1,80 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadProgressBroker(t *testing.T) {
+	broker := NewUploadProgressBroker()
+
+	upload := &Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+	file.Size = 100
+
+	ch, snapshot, cancel := broker.Subscribe(upload.ID)
+	defer cancel()
+	require.Len(t, snapshot, 0, "invalid snapshot")
+	require.False(t, broker.IsInFlight(upload.ID), "upload should not be in flight")
+
+	tracker := broker.Track(file)
+	require.True(t, broker.IsInFlight(upload.ID), "upload should be in flight")
+
+	progress := <-ch
+	require.Equal(t, file.ID, progress.FileID, "invalid file id")
+	require.Equal(t, int64(100), progress.Size, "invalid size")
+	require.Equal(t, int64(0), progress.Received, "invalid received bytes")
+
+	tracker.Update(10)
+	progress = <-ch
+	require.Equal(t, int64(10), progress.Received, "invalid received bytes")
+
+	// Updates are rate limited
+	tracker.Update(20)
+	require.Len(t, ch, 0, "update should have been rate limited")
+
+	_, snapshot, cancel2 := broker.Subscribe(upload.ID)
+	cancel2()
+	require.Len(t, snapshot, 1, "invalid snapshot")
+	require.Equal(t, int64(20), snapshot[0].Received, "invalid received bytes")
+
+	tracker.Done()
+	progress = <-ch
+	require.True(t, progress.Done, "missing done")
+	require.Equal(t, int64(20), progress.Received, "invalid received bytes")
+	require.False(t, broker.IsInFlight(upload.ID), "upload should not be in flight")
+}
+
+func TestUploadProgressBrokerSlowSubscriber(t *testing.T) {
+	broker := NewUploadProgressBroker()
+
+	upload := &Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+
+	ch, _, cancel := broker.Subscribe(upload.ID)
+	defer cancel()
+
+	tracker := broker.Track(file)
+	for i := 0; i < 2*uploadProgressBufferSize; i++ {
+		tracker.last = tracker.last.Add(-UploadProgressInterval)
+		tracker.Update(int64(i))
+	}
+	require.Len(t, ch, uploadProgressBufferSize, "buffer should be full")
+
+	tracker.Done()
+
+	var last *UploadProgress
+	for len(ch) > 0 {
+		last = <-ch
+	}
+	require.True(t, last.Done, "final update should not be dropped")
+}
+
+func TestUploadProgressTrackerNil(t *testing.T) {
+	var tracker *UploadProgressTracker
+	tracker.Update(1)
+	tracker.Done()
+}
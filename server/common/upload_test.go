@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/root-gg/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,6 +15,12 @@ func TestNewUpload(t *testing.T) {
 	require.NotZero(t, upload.UploadToken, "missing upload token")
 }
 
+func TestGenerateRandomLowerCaseID(t *testing.T) {
+	id := GenerateRandomLowerCaseID(32)
+	require.Len(t, id, 32)
+	require.Regexp(t, "^[a-z0-9]+$", id)
+}
+
 func TestUploadNewFile(t *testing.T) {
 	upload := &Upload{}
 	upload.NewFile()
@@ -29,6 +36,7 @@ func TestUploadSanitize(t *testing.T) {
 	upload.UploadToken = "token"
 	upload.Token = "token"
 	upload.User = "user"
+	upload.DownloadedBytes = 42
 
 	config := NewConfiguration()
 	config.DownloadDomain = "download.domain"
@@ -40,18 +48,32 @@ func TestUploadSanitize(t *testing.T) {
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
 	require.Zero(t, upload.Token, "invalid sanitized upload")
 	require.Zero(t, upload.UploadToken, "invalid sanitized upload")
+	require.Zero(t, upload.DownloadedBytes, "invalid sanitized upload")
 	require.Equal(t, config.DownloadDomain, upload.DownloadDomain, "invalid download domain")
 }
 
+func TestUploadSanitizeDownloadDomain(t *testing.T) {
+	upload := &Upload{}
+	upload.DownloadDomain = "team.domain"
+
+	config := NewConfiguration()
+	config.DownloadDomain = "download.domain"
+	upload.Sanitize(config)
+
+	require.Equal(t, "team.domain", upload.DownloadDomain, "invalid download domain")
+}
+
 func TestUploadSanitizeAdmin(t *testing.T) {
 	upload := &Upload{}
 	upload.NewFile()
 	upload.UploadToken = "token"
+	upload.DownloadedBytes = 42
 	upload.IsAdmin = true
 
 	upload.Sanitize(NewConfiguration())
 
 	require.Equal(t, "token", upload.UploadToken, "invalid sanitized upload")
+	require.Equal(t, int64(42), upload.DownloadedBytes, "invalid sanitized upload")
 }
 
 func TestUpload_GetFile(t *testing.T) {
@@ -118,3 +140,88 @@ func TestUpload_ExtendExpirationDate(t *testing.T) {
 	time.Sleep(time.Second)
 	require.True(t, upload.IsExpired())
 }
+
+func TestUpload_SetCompleted(t *testing.T) {
+	upload := &Upload{TTL: 3600}
+	upload.ExtendExpirationDate()
+	deadline := *upload.ExpireAt
+
+	completedAt := time.Now().Add(time.Hour)
+	upload.SetCompleted(completedAt)
+	require.Equal(t, completedAt, *upload.CompletedAt)
+	require.Equal(t, deadline, *upload.ExpireAt, "expiration date should not change")
+
+	upload.TTLFromCompletion = true
+	upload.SetCompleted(completedAt)
+	require.Equal(t, completedAt.Add(time.Hour), *upload.ExpireAt, "TTL should start from the completion date")
+
+	// Uploads without TTL never expire
+	upload = &Upload{TTLFromCompletion: true}
+	upload.SetCompleted(completedAt)
+	require.Nil(t, upload.ExpireAt)
+}
+
+func TestUpload_SetAccessed(t *testing.T) {
+	upload := &Upload{TTL: 3600}
+	upload.ExtendExpirationDate()
+	deadline := *upload.ExpireAt
+
+	accessedAt := time.Now().Add(time.Hour)
+	require.True(t, upload.SetAccessed(accessedAt))
+	require.Equal(t, accessedAt, *upload.LastAccessedAt)
+	require.Equal(t, deadline, *upload.ExpireAt, "expiration date should not change")
+
+	upload = &Upload{TTL: 3600, InactivityTTL: 60}
+	upload.ExtendExpirationDate()
+	require.True(t, upload.ExpireAt.Before(time.Now().Add(2*time.Minute)), "inactivity TTL should replace TTL")
+
+	require.True(t, upload.SetAccessed(accessedAt))
+	require.Equal(t, accessedAt.Add(time.Minute), *upload.ExpireAt, "expiration date should be pushed back")
+
+	// Close accesses are not saved
+	require.False(t, upload.SetAccessed(accessedAt.Add(time.Second)))
+	require.Equal(t, accessedAt, *upload.LastAccessedAt)
+
+	require.True(t, upload.SetAccessed(accessedAt.Add(UploadAccessUpdateInterval)))
+	require.Equal(t, accessedAt.Add(UploadAccessUpdateInterval+time.Minute), *upload.ExpireAt)
+}
+
+func TestUpload_IsDownloadQuotaExceeded(t *testing.T) {
+	upload := &Upload{DownloadedBytes: 100}
+	require.False(t, upload.IsDownloadQuotaExceeded(), "unlimited upload should not exceed its quota")
+
+	upload.MaxTotalDownloadBytes = 101
+	require.False(t, upload.IsDownloadQuotaExceeded(), "upload should not exceed its quota")
+
+	upload.MaxTotalDownloadBytes = 100
+	require.True(t, upload.IsDownloadQuotaExceeded(), "upload should exceed its quota")
+}
+
+func TestUpload_CheckBasicAuth(t *testing.T) {
+	var err error
+
+	upload := &Upload{}
+	upload.Password, err = utils.Md5sum(EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err)
+
+	require.NoError(t, upload.CheckBasicAuth("Basic "+EncodeAuthBasicHeader("login", "password")))
+	RequireError(t, upload.CheckBasicAuth(""), "missing Authorization header")
+	RequireError(t, upload.CheckBasicAuth("foo"), "invalid Authorization header")
+	RequireError(t, upload.CheckBasicAuth("Bearer foo"), "invalid http authorization scheme")
+	RequireError(t, upload.CheckBasicAuth("Basic "+EncodeAuthBasicHeader("login", "foo")), "invalid credentials")
+}
+
+func TestUpload_CheckManagementPassword(t *testing.T) {
+	var err error
+
+	upload := &Upload{}
+	RequireError(t, upload.CheckManagementPassword("password"), "upload is not protected by a management password")
+
+	upload.ManagementPassword, err = HashUploadSecret("password")
+	require.NoError(t, err)
+	require.True(t, upload.IsProtectedByManagementPassword())
+
+	require.NoError(t, upload.CheckManagementPassword("password"))
+	RequireError(t, upload.CheckManagementPassword(""), "missing management password")
+	RequireError(t, upload.CheckManagementPassword("foo"), "invalid management password")
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (config *Configuration) initializeUploadWebhooks() (err error) {
+	config.uploadWebhookAllowlist = nil
+	for _, allowed := range config.UploadWebhookAllowlist {
+		allowedURL, err := url.Parse(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid upload webhook allowlist URL %s : %s", allowed, err)
+		}
+		if (allowedURL.Scheme != "http" && allowedURL.Scheme != "https") || allowedURL.Host == "" {
+			return fmt.Errorf("invalid upload webhook allowlist URL %s : expected an absolute http(s) URL", allowed)
+		}
+		config.uploadWebhookAllowlist = append(config.uploadWebhookAllowlist, allowedURL)
+	}
+
+	return nil
+}
+
+// IsUploadWebhookEnabled return true if uploads can set their own webhook
+func (config *Configuration) IsUploadWebhookEnabled() bool {
+	return len(config.uploadWebhookAllowlist) > 0
+}
+
+// CheckUploadWebhook check that the upload webhook URL is allowed by the UploadWebhookAllowlist
+// Webhooks are called by the server so they must not be able to target arbitrary hosts
+func (config *Configuration) CheckUploadWebhook(webhook string) (err error) {
+	if !config.IsUploadWebhookEnabled() {
+		return fmt.Errorf("upload webhooks are disabled")
+	}
+
+	webhookURL, err := url.Parse(webhook)
+	if err != nil || webhookURL.User != nil {
+		return fmt.Errorf("invalid webhook URL")
+	}
+
+	for _, allowedURL := range config.uploadWebhookAllowlist {
+		if isURLUnder(webhookURL, allowedURL) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("webhook URL %s is not allowed", webhook)
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUploadWebhook(t *testing.T) {
+	config := NewConfiguration()
+	require.NoError(t, config.Initialize())
+	require.False(t, config.IsUploadWebhookEnabled())
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg/plik"), "upload webhooks are disabled")
+
+	config.UploadWebhookAllowlist = []string{"https://hooks.root.gg/plik", "https://tenant.root.gg"}
+	require.NoError(t, config.Initialize())
+	require.True(t, config.IsUploadWebhookEnabled())
+
+	require.NoError(t, config.CheckUploadWebhook("https://hooks.root.gg/plik"))
+	require.NoError(t, config.CheckUploadWebhook("https://hooks.root.gg/plik/tenant/42?token=secret"))
+	require.NoError(t, config.CheckUploadWebhook("https://TENANT.root.gg/events"))
+
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg/plikevil"), "webhook URL https://hooks.root.gg/plikevil is not allowed")
+	RequireError(t, config.CheckUploadWebhook("http://hooks.root.gg/plik"), "is not allowed")
+	RequireError(t, config.CheckUploadWebhook("https://169.254.169.254/latest"), "is not allowed")
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg@evil.com/plik"), "invalid webhook URL")
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg/%zz"), "invalid webhook URL")
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg/plik/../admin"), "is not allowed")
+	RequireError(t, config.CheckUploadWebhook("https://hooks.root.gg/plik/%2e%2e/admin"), "is not allowed")
+}
+
+func TestInitializeUploadWebhooks(t *testing.T) {
+	config := NewConfiguration()
+	config.UploadWebhookAllowlist = []string{"/relative"}
+	RequireError(t, config.Initialize(), "invalid upload webhook allowlist URL /relative")
+
+	config.UploadWebhookAllowlist = []string{"https://hooks.root.gg/%zz"}
+	RequireError(t, config.Initialize(), "invalid upload webhook allowlist URL")
+}
@@ -29,6 +29,12 @@ type User struct {
 
 	Tokens []*Token `json:"tokens,omitempty"`
 
+	LastLoginAt             *time.Time `json:"lastLoginAt,omitempty"`
+	InactivityWarningSentAt *time.Time `json:"-"`
+
+	TOSAcceptedVersion string     `json:"tosAcceptedVersion,omitempty"`
+	TOSAcceptedAt      *time.Time `json:"tosAcceptedAt,omitempty"`
+
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -63,6 +69,14 @@ func (user *User) NewToken() (token *Token) {
 	return token
 }
 
+// GetLastActivity return the last time the user logged in, or its creation date if it never did
+func (user *User) GetLastActivity() time.Time {
+	if user.LastLoginAt != nil {
+		return *user.LastLoginAt
+	}
+	return user.CreatedAt
+}
+
 // NewToken add a new token to a user
 func (user *User) String() string {
 	str := user.Provider + ":" + user.Login
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// UserMetadata is an opaque set of key/value pairs attached to an upload by the client
+// to correlate it with its own records. It is stored as a JSON object.
+type UserMetadata map[string]string
+
+// Validate check the keys and the size of the user metadata ( maxSize : maximum size of the JSON object in bytes )
+func (metadata UserMetadata) Validate(maxSize int) (err error) {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	if maxSize <= 0 {
+		return fmt.Errorf("user metadata are disabled")
+	}
+
+	for key := range metadata {
+		if key == "" {
+			return fmt.Errorf("invalid empty user metadata key")
+		}
+	}
+
+	serialized, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("unable to serialize user metadata : %s", err)
+	}
+
+	if len(serialized) > maxSize {
+		return fmt.Errorf("user metadata too large, maximum size is %d bytes", maxSize)
+	}
+
+	return nil
+}
+
+// GormDataType store the user metadata in a string column
+func (metadata UserMetadata) GormDataType() string {
+	return "string"
+}
+
+// Value serialize the user metadata to the database
+func (metadata UserMetadata) Value() (driver.Value, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+
+	serialized, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(serialized), nil
+}
+
+// Scan deserialize the user metadata from the database
+func (metadata *UserMetadata) Scan(value interface{}) (err error) {
+	var serialized []byte
+	switch v := value.(type) {
+	case nil:
+	case string:
+		serialized = []byte(v)
+	case []byte:
+		serialized = v
+	default:
+		return fmt.Errorf("unable to scan user metadata from %T", value)
+	}
+
+	*metadata = nil
+	if len(serialized) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(serialized, metadata)
+}
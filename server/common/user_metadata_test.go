@@ -0,0 +1,39 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMetadata_Validate(t *testing.T) {
+	require.NoError(t, UserMetadata(nil).Validate(0))
+	require.NoError(t, UserMetadata{"ticket": "PLIK-42"}.Validate(100))
+
+	RequireError(t, UserMetadata{"ticket": "PLIK-42"}.Validate(0), "user metadata are disabled")
+	RequireError(t, UserMetadata{"": "PLIK-42"}.Validate(100), "invalid empty user metadata key")
+	RequireError(t, UserMetadata{"ticket": strings.Repeat("x", 100)}.Validate(100), "user metadata too large, maximum size is 100 bytes")
+}
+
+func TestUserMetadata_ValueScan(t *testing.T) {
+	value, err := UserMetadata(nil).Value()
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+
+	value, err = UserMetadata{"ticket": "PLIK-42"}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"ticket":"PLIK-42"}`, value)
+
+	var metadata UserMetadata
+	require.NoError(t, metadata.Scan(value))
+	require.Equal(t, UserMetadata{"ticket": "PLIK-42"}, metadata)
+
+	require.NoError(t, metadata.Scan([]byte(`{"foo":"bar"}`)))
+	require.Equal(t, UserMetadata{"foo": "bar"}, metadata)
+
+	require.NoError(t, metadata.Scan(nil))
+	require.Nil(t, metadata)
+
+	require.Error(t, metadata.Scan(42))
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// PostWebhookEvent post the event as JSON to the webhook URL
+func (config *Configuration) PostWebhookEvent(URL string, event interface{}) (err error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to serialize event : %s", err)
+	}
+
+	resp, err := config.NewHTTPClient(webhookTimeout).Post(URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to post event : %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected webhook response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
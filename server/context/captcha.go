@@ -0,0 +1,28 @@
+package context
+
+import (
+	"net/http"
+)
+
+// CaptchaResponseHeader is the HTTP header holding the CAPTCHA response token of anonymous uploads
+const CaptchaResponseHeader = "X-Captcha-Response"
+
+// VerifyCaptcha check the CAPTCHA response of anonymous upload requests
+func (ctx *Context) VerifyCaptcha(req *http.Request) (err error) {
+	config := ctx.GetConfig()
+	if config.CaptchaProvider == "" {
+		return nil
+	}
+
+	// Authenticated users ( session or token ) and upload links are not asked to solve a CAPTCHA
+	if ctx.GetUser() != nil || ctx.GetUploadLink() != nil {
+		return nil
+	}
+
+	var remoteIP string
+	if ctx.GetSourceIP() != nil {
+		remoteIP = ctx.GetSourceIP().String()
+	}
+
+	return config.VerifyCaptcha(req.Header.Get(CaptchaResponseHeader), remoteIP)
+}
@@ -20,12 +20,21 @@ type Context struct {
 	dataBackend         data.Backend
 	streamBackend       data.Backend
 	authenticator       *common.SessionAuthenticator
+	downloadLimiter     *common.BandwidthLimiter
+	connectionLimiter   *common.ConnectionLimiter
+	uploadProgress      *common.UploadProgressBroker
+	transfers           *common.Transfers
 	pagingQuery         *common.PagingQuery
 	sourceIP            net.IP
 	upload              *common.Upload
 	file                *common.File
 	user                *common.User
 	token               *common.Token
+	session             *common.Session
+	clientApp           *common.ClientApp
+	uploadLink          *common.UploadLink
+	managementLink      *common.ManagementLink
+	apiVersion          int
 	isWhitelisted       *bool
 	isRedirectOnFailure bool
 	isQuick             bool
@@ -154,6 +163,70 @@ func (ctx *Context) SetAuthenticator(authenticator *common.SessionAuthenticator)
 	ctx.authenticator = authenticator
 }
 
+// GetDownloadLimiter get downloadLimiter from the context ( nil if download bandwidth is not limited )
+func (ctx *Context) GetDownloadLimiter() *common.BandwidthLimiter {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.downloadLimiter
+}
+
+// SetDownloadLimiter set downloadLimiter in the context
+func (ctx *Context) SetDownloadLimiter(downloadLimiter *common.BandwidthLimiter) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.downloadLimiter = downloadLimiter
+}
+
+// GetConnectionLimiter get connectionLimiter from the context ( nil if concurrent connections are not limited )
+func (ctx *Context) GetConnectionLimiter() *common.ConnectionLimiter {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.connectionLimiter
+}
+
+// SetConnectionLimiter set connectionLimiter in the context
+func (ctx *Context) SetConnectionLimiter(connectionLimiter *common.ConnectionLimiter) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.connectionLimiter = connectionLimiter
+}
+
+// GetUploadProgressBroker get uploadProgress from the context ( nil if upload progress is not published )
+func (ctx *Context) GetUploadProgressBroker() *common.UploadProgressBroker {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.uploadProgress
+}
+
+// SetUploadProgressBroker set uploadProgress in the context
+func (ctx *Context) SetUploadProgressBroker(uploadProgress *common.UploadProgressBroker) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.uploadProgress = uploadProgress
+}
+
+// GetTransfers get transfers from the context ( nil if transfers can't be aborted )
+func (ctx *Context) GetTransfers() *common.Transfers {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.transfers
+}
+
+// SetTransfers set transfers in the context
+func (ctx *Context) SetTransfers(transfers *common.Transfers) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.transfers = transfers
+}
+
 // GetPagingQuery get pagingQuery from the context.
 func (ctx *Context) GetPagingQuery() *common.PagingQuery {
 	ctx.mu.RLock()
@@ -254,6 +327,86 @@ func (ctx *Context) SetToken(token *common.Token) {
 	ctx.token = token
 }
 
+// GetSession get session from the context.
+func (ctx *Context) GetSession() *common.Session {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.session
+}
+
+// SetSession set session in the context
+func (ctx *Context) SetSession(session *common.Session) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.session = session
+}
+
+// GetClientApp get clientApp from the context.
+func (ctx *Context) GetClientApp() *common.ClientApp {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.clientApp
+}
+
+// SetClientApp set clientApp in the context
+func (ctx *Context) SetClientApp(clientApp *common.ClientApp) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.clientApp = clientApp
+}
+
+// GetUploadLink get uploadLink from the context.
+func (ctx *Context) GetUploadLink() *common.UploadLink {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.uploadLink
+}
+
+// SetUploadLink set uploadLink in the context
+func (ctx *Context) SetUploadLink(uploadLink *common.UploadLink) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.uploadLink = uploadLink
+}
+
+// GetManagementLink get managementLink from the context.
+func (ctx *Context) GetManagementLink() *common.ManagementLink {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.managementLink
+}
+
+// SetManagementLink set managementLink in the context
+func (ctx *Context) SetManagementLink(managementLink *common.ManagementLink) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.managementLink = managementLink
+}
+
+// GetAPIVersion get the API version requested by the client, 0 if none was requested
+func (ctx *Context) GetAPIVersion() int {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+
+	return ctx.apiVersion
+}
+
+// SetAPIVersion set apiVersion in the context
+func (ctx *Context) SetAPIVersion(apiVersion int) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.apiVersion = apiVersion
+}
+
 // IsRedirectOnFailure get isRedirectOnFailure from the context.
 func (ctx *Context) IsRedirectOnFailure() bool {
 	ctx.mu.RLock()
@@ -1,17 +1,26 @@
 package context
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"runtime/debug"
 	"strings"
+
+	"github.com/root-gg/plik/server/data"
 )
 
 var internalServerError = "internal server error"
 
 // InternalServerError is a helper to generate http.StatusInternalServerError responses
 func (ctx *Context) InternalServerError(message string, err error) {
+	// Fail fast while the data backend circuit breaker is open
+	if errors.Is(err, data.ErrCircuitOpen) {
+		ctx.ServiceUnavailable()
+		return
+	}
+
 	ctx.mu.Lock()
 	config := ctx.config
 	ctx.mu.Unlock()
@@ -30,6 +39,11 @@ func (ctx *Context) InternalServerError(message string, err error) {
 	ctx.Fail(message, err, http.StatusInternalServerError)
 }
 
+// ServiceUnavailable is a helper to generate http.StatusServiceUnavailable responses
+func (ctx *Context) ServiceUnavailable() {
+	ctx.Fail("data backend temporarily unavailable, please retry later", nil, http.StatusServiceUnavailable)
+}
+
 // BadRequest is a helper to generate http.BadRequest responses
 func (ctx *Context) BadRequest(message string, params ...interface{}) {
 	message = fmt.Sprintf(message, params...)
@@ -54,6 +68,52 @@ func (ctx *Context) Unauthorized(message string, params ...interface{}) {
 	ctx.Fail(message, nil, http.StatusUnauthorized)
 }
 
+// RequestEntityTooLarge is a helper to generate http.StatusRequestEntityTooLarge responses
+func (ctx *Context) RequestEntityTooLarge(message string, params ...interface{}) {
+	message = fmt.Sprintf(message, params...)
+	ctx.Fail(message, nil, http.StatusRequestEntityTooLarge)
+}
+
+// Gone is a helper to generate http.StatusGone responses
+func (ctx *Context) Gone(message string, params ...interface{}) {
+	message = fmt.Sprintf(message, params...)
+	ctx.Fail(message, nil, http.StatusGone)
+}
+
+// Expired is a helper to generate the responses for expired or already downloaded uploads and files
+// Browser downloads are redirected to the ExpiredPageURL if any, clients not accepting HTML get
+// a http.StatusGone response if RevealGoneReason is enabled and a http.StatusNotFound response otherwise
+func (ctx *Context) Expired(message string, params ...interface{}) {
+	message = fmt.Sprintf(message, params...)
+
+	ctx.mu.Lock()
+	config := ctx.config
+	req := ctx.req
+	resp := ctx.resp
+	if ctx.isRedirectOnFailure && req != nil && !strings.Contains(req.Header.Get("Accept"), "text/html") {
+		// Only browsers are redirected to an error page
+		ctx.isRedirectOnFailure = false
+	}
+	isRedirectOnFailure := ctx.isRedirectOnFailure
+	ctx.mu.Unlock()
+
+	if config == nil {
+		ctx.Fail(message, nil, http.StatusNotFound)
+		return
+	}
+
+	if config.ExpiredPageURL != "" && isRedirectOnFailure && resp != nil {
+		http.Redirect(resp, req, config.ExpiredPageURL, http.StatusFound)
+		return
+	}
+
+	if config.RevealGoneReason {
+		ctx.Fail(message, nil, http.StatusGone)
+	} else {
+		ctx.Fail(message, nil, http.StatusNotFound)
+	}
+}
+
 // MissingParameter is a helper to generate http.BadRequest responses
 func (ctx *Context) MissingParameter(message string, params ...interface{}) {
 	message = fmt.Sprintf(message, params...)
@@ -0,0 +1,189 @@
+package context
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// QuotaEvictionEvent is the event type of quota eviction notifications
+const QuotaEvictionEvent = "upload.evicted"
+
+// QuotaEvictionNotification is posted as JSON to the QuotaEvictionWebhook and to the webhook of the evicted upload
+// when an upload is removed to make room for a new upload of its user ( evict_oldest QuotaExceededPolicy )
+type QuotaEvictionNotification struct {
+	Event     string    `json:"event"`
+	UploadID  string    `json:"uploadId"`
+	User      string    `json:"user"`
+	CreatedAt time.Time `json:"createdAt"`
+	Files     int       `json:"files"`
+	Size      int64     `json:"size"`
+	EvictedBy string    `json:"evictedBy"`
+}
+
+// CheckUserQuota check that the upload fits in the storage quota of its user ( MaxUserSize ).
+// Only the declared file sizes of the upload are known at this point.
+// With the evict_oldest QuotaExceededPolicy the upload must fit once the other uploads of the user are evicted,
+// nothing is evicted until EvictUserUploads is called with the saved upload
+func (ctx *Context) CheckUserQuota(upload *common.Upload) (err error) {
+	_, err = ctx.getUploadsToEvict(upload)
+	return err
+}
+
+// EvictUserUploads remove the oldest uploads of the user until the saved upload fits in the user storage quota.
+// Nothing is removed and a quota error is returned if the upload can't fit.
+// Each eviction is logged and posted to the QuotaEvictionWebhook and to the webhook of the evicted upload
+func (ctx *Context) EvictUserUploads(upload *common.Upload) (err error) {
+	uploads, err := ctx.getUploadsToEvict(upload)
+	if err != nil {
+		return err
+	}
+
+	for _, evicted := range uploads {
+		err = ctx.GetMetadataBackend().RemoveUpload(evicted.ID)
+		if err != nil {
+			return fmt.Errorf("unable to evict upload %s : %s", evicted.ID, err)
+		}
+		ctx.notifyUploadEviction(upload, evicted)
+	}
+
+	return nil
+}
+
+// getUploadsToEvict return the oldest uploads of the user to remove for the upload to fit in the user storage quota.
+// The storage used by the user and freed by the evictions only counts the uploaded files
+func (ctx *Context) getUploadsToEvict(upload *common.Upload) (uploads []*common.Upload, err error) {
+	config := ctx.GetConfig()
+	if upload.User == "" || config.MaxUserSize <= 0 {
+		return nil, nil
+	}
+
+	var size int64
+	for _, file := range upload.Files {
+		if file.Size > 0 {
+			size += file.Size
+		}
+	}
+
+	if size > config.MaxUserSize {
+		return nil, fmt.Errorf("upload is too big (%s), maximum user storage is %s", humanize.Bytes(uint64(size)), humanize.Bytes(uint64(config.MaxUserSize)))
+	}
+
+	_, _, used, err := ctx.GetMetadataBackend().GetUploadStatistics(&upload.User, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get user storage usage : %s", err)
+	}
+
+	if used+size <= config.MaxUserSize {
+		return nil, nil
+	}
+
+	if config.QuotaExceededPolicy != common.QuotaExceededEvictOldest {
+		return nil, newQuotaExceededError(used, config.MaxUserSize)
+	}
+
+	oldest, err := ctx.GetMetadataBackend().GetUserUploadsOldestFirst(upload.User)
+	if err != nil {
+		return nil, err
+	}
+
+	left := used
+	for _, candidate := range oldest {
+		if left+size <= config.MaxUserSize {
+			break
+		}
+
+		if candidate.ID == upload.ID {
+			continue
+		}
+
+		left -= getUploadedSize(candidate)
+		uploads = append(uploads, candidate)
+	}
+
+	if left+size > config.MaxUserSize {
+		return nil, newQuotaExceededError(used, config.MaxUserSize)
+	}
+
+	return uploads, nil
+}
+
+// notifyUploadEviction log the eviction of an upload and post it to the QuotaEvictionWebhook and to the upload webhook
+// The webhooks are called in the background not to delay the creation of the new upload
+func (ctx *Context) notifyUploadEviction(upload *common.Upload, evicted *common.Upload) {
+	config := ctx.GetConfig()
+	log := ctx.GetLogger()
+
+	notification := &QuotaEvictionNotification{
+		Event:     QuotaEvictionEvent,
+		UploadID:  evicted.ID,
+		User:      evicted.User,
+		CreatedAt: evicted.CreatedAt,
+		Files:     len(evicted.Files),
+		Size:      getUploadedSize(evicted),
+		EvictedBy: upload.ID,
+	}
+
+	log.Warningf("user %s storage quota exceeded : evicted upload %s created at %s ( %d files, %s ) for upload %s",
+		evicted.User, evicted.ID, evicted.CreatedAt.Format("2006-01-02 15:04:05"), notification.Files, humanize.Bytes(uint64(notification.Size)), upload.ID)
+
+	if config.QuotaEvictionWebhook == "" && evicted.Webhook == "" {
+		return
+	}
+
+	go func() {
+		if config.QuotaEvictionWebhook != "" {
+			err := config.PostWebhookEvent(config.QuotaEvictionWebhook, notification)
+			if err != nil {
+				log.Warningf("unable to send eviction notification for upload %s : %s", evicted.ID, err)
+			}
+		}
+
+		// The upload webhook is checked again as the UploadWebhookAllowlist might have changed since the upload creation
+		if evicted.Webhook != "" {
+			err := config.CheckUploadWebhook(evicted.Webhook)
+			if err == nil {
+				err = config.PostWebhookEvent(evicted.Webhook, notification)
+			}
+			if err != nil {
+				log.Warningf("unable to send eviction notification for upload %s to the upload webhook : %s", evicted.ID, err)
+			}
+		}
+	}()
+}
+
+// GetUserQuotaLeft return the storage left to the owner of the upload ( -1 : No limit )
+func (ctx *Context) GetUserQuotaLeft(upload *common.Upload) (left int64, err error) {
+	config := ctx.GetConfig()
+	if upload.User == "" || config.MaxUserSize <= 0 {
+		return -1, nil
+	}
+
+	_, _, used, err := ctx.GetMetadataBackend().GetUploadStatistics(&upload.User, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get user storage usage : %s", err)
+	}
+
+	if used >= config.MaxUserSize {
+		return 0, nil
+	}
+
+	return config.MaxUserSize - used, nil
+}
+
+// getUploadedSize return the size of the uploaded files of the upload, the only ones counted in the user storage usage
+func getUploadedSize(upload *common.Upload) (size int64) {
+	for _, file := range upload.Files {
+		if file.Status == common.FileUploaded {
+			size += file.Size
+		}
+	}
+	return size
+}
+
+func newQuotaExceededError(used int64, maxUserSize int64) error {
+	return fmt.Errorf("user storage quota exceeded (%s used of %s)", humanize.Bytes(uint64(used)), humanize.Bytes(uint64(maxUserSize)))
+}
@@ -0,0 +1,46 @@
+package context
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CheckTOSAcceptance check that the current version of the terms of service was accepted before creating an upload
+// Users record their acceptance in their account, anonymous clients in a signed session cookie or request header
+func (ctx *Context) CheckTOSAcceptance(req *http.Request) (err error) {
+	config := ctx.GetConfig()
+	if !config.RequireTOSAcceptance {
+		return nil
+	}
+
+	if user := ctx.GetUser(); user != nil && user.HasAcceptedTOS(config.TOSVersion) {
+		return nil
+	}
+
+	token := req.Header.Get(common.TOSAcceptanceHeader)
+	if token == "" {
+		if cookie, err := req.Cookie(common.TOSCookieName); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	if token != "" && ctx.GetAuthenticator() != nil {
+		acceptance, err := ctx.GetAuthenticator().ParseTOSAcceptance(token)
+		if err == nil && acceptance.Version == config.TOSVersion {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("terms of service version %s must be accepted before uploading", config.TOSVersion)
+}
+
+// TOSNotAccepted is a helper to generate http.StatusPreconditionRequired responses
+// telling the client which version of the terms of service has to be accepted
+func (ctx *Context) TOSNotAccepted(err error) {
+	if resp := ctx.GetResp(); resp != nil {
+		resp.Header().Set(common.TOSVersionHeader, ctx.GetConfig().TOSVersion)
+	}
+	ctx.Fail(err.Error(), nil, http.StatusPreconditionRequired)
+}
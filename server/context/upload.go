@@ -2,6 +2,7 @@ package context
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -9,20 +10,41 @@ import (
 	"github.com/root-gg/utils"
 )
 
+// GetUploadByID get an upload from the metadata backend ( return nil and no error if not found )
+// If CaseInsensitiveUploadIDs is enabled the upload ID is normalized first. Uploads created with a mixed case
+// ID before the option was enabled are still found using their exact ID.
+func (ctx *Context) GetUploadByID(uploadID string) (upload *common.Upload, err error) {
+	normalized := ctx.GetConfig().NormalizeUploadID(uploadID)
+
+	upload, err = ctx.GetMetadataBackend().GetUpload(normalized)
+	if err != nil || upload != nil || normalized == uploadID {
+		return upload, err
+	}
+
+	return ctx.GetMetadataBackend().GetUpload(uploadID)
+}
+
 // CreateUpload from params and context (check configuration and default values, generate upload and file IDs, ... )
 func (ctx *Context) CreateUpload(params *common.Upload) (upload *common.Upload, err error) {
 	upload = common.NewUpload()
-
-	if ctx.GetSourceIP() != nil {
-		upload.RemoteIP = ctx.GetSourceIP().String()
+	if ctx.GetConfig().CaseInsensitiveUploadIDs {
+		upload.GenerateLowerCaseID()
 	}
 
+	upload.RemoteIP = ctx.GetConfig().FormatClientIP(ctx.GetSourceIP())
+
 	// Set user
 	err = ctx.setUser(upload)
 	if err != nil {
 		return nil, err
 	}
 
+	// Check the upload preset policy
+	err = ctx.setPreset(upload, params)
+	if err != nil {
+		return nil, err
+	}
+
 	// Set user configurable parameters
 	err = ctx.setParams(upload, params)
 	if err != nil {
@@ -41,24 +63,99 @@ func (ctx *Context) CreateUpload(params *common.Upload) (upload *common.Upload,
 		return nil, err
 	}
 
+	// Handle management password
+	err = ctx.setManagementPassword(upload, params.ManagementPassword)
+	if err != nil {
+		return nil, err
+	}
+
 	// Handle files
 	err = ctx.setFiles(upload, params.Files)
 	if err != nil {
 		return nil, err
 	}
 
+	err = ctx.CheckMetadataSize(upload, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	return upload, nil
 }
 
+// CheckMetadataSize check that the metadata set by the client on the upload and its files don't exceed MaxUploadMetadataBytes.
+// If file is nil the upload files are checked, otherwise the file is checked along with the files already saved in the
+// metadata backend. Return an error wrapping common.ErrMetadataTooLarge if the limit is exceeded
+func (ctx *Context) CheckMetadataSize(upload *common.Upload, file *common.File) (err error) {
+	maxSize := ctx.GetConfig().MaxUploadMetadataBytes
+	if maxSize == 0 {
+		return nil
+	}
+
+	files := upload.Files
+	if file != nil {
+		files, err = ctx.GetMetadataBackend().GetFiles(upload.ID)
+		if err != nil {
+			return fmt.Errorf("unable to get upload files : %s", err)
+		}
+		files = append(files, file)
+	}
+
+	size := upload.GetMetadataSize()
+	for _, f := range files {
+		size += f.GetMetadataSize()
+	}
+
+	if size > maxSize {
+		return fmt.Errorf("%w (%d bytes), maximum size is %d bytes", common.ErrMetadataTooLarge, size, maxSize)
+	}
+
+	return nil
+}
+
+// NewUploadParams return upload params initialized with the server default values
+// those are to be overridden by the params provided by the client
+func (ctx *Context) NewUploadParams() (params *common.Upload) {
+	config := ctx.GetConfig()
+
+	params = &common.Upload{}
+	params.OneShot = common.IsFeatureDefault(config.FeatureOneShot)
+	params.Removable = common.IsFeatureDefault(config.FeatureRemovable)
+	params.TTLFromCompletion = config.TTLFromCompletion
+
+	return params
+}
+
 func (ctx *Context) setUser(upload *common.Upload) (err error) {
 	config := ctx.GetConfig()
 	user := ctx.GetUser()
 	token := ctx.GetToken()
+	app := ctx.GetClientApp()
+
+	// Uploads created with an upload link belong to the user who created the link
+	if link := ctx.GetUploadLink(); link != nil {
+		if link.Owner == nil {
+			return fmt.Errorf("invalid upload link")
+		}
+		user = link.Owner
+		token = nil
+		ctx.SetUser(user)
+		ctx.SetToken(nil)
+
+		linkID := link.ID
+		upload.UploadLinkID = &linkID
+		upload.UploadLink = link.Token
+	}
 
-	if config.FeatureAuthentication == common.FeatureForced && user == nil {
+	// Client apps are identified by their API key so their uploads are not anonymous
+	if config.FeatureAuthentication == common.FeatureForced && user == nil && app == nil {
 		return fmt.Errorf("anonymous uploads are disabled")
 	}
 
+	if app != nil {
+		upload.ClientApp = app.Name
+	}
+
 	if config.FeatureAuthentication == common.FeatureDisabled && user != nil {
 		return fmt.Errorf("authentication is disabled")
 	}
@@ -73,6 +170,25 @@ func (ctx *Context) setUser(upload *common.Upload) (err error) {
 	return nil
 }
 
+func (ctx *Context) setPreset(upload *common.Upload, params *common.Upload) (err error) {
+	if params.Preset == "" {
+		return nil
+	}
+
+	preset := ctx.GetConfig().GetUploadPreset(params.Preset)
+	if preset == nil {
+		return fmt.Errorf("invalid upload preset %s", params.Preset)
+	}
+
+	err = preset.Check(params)
+	if err != nil {
+		return err
+	}
+
+	upload.Preset = preset.Name
+	return nil
+}
+
 func (ctx *Context) setParams(upload *common.Upload, params *common.Upload) (err error) {
 	config := ctx.GetConfig()
 
@@ -97,10 +213,75 @@ func (ctx *Context) setParams(upload *common.Upload, params *common.Upload) (err
 		upload.Stream = true
 	}
 
+	if params.ExpandArchives {
+		if !config.ExpandArchiveOnUpload {
+			return fmt.Errorf("archive expansion is disabled")
+		}
+		// Stream uploads are not stored so the archives can't be read back
+		if upload.Stream {
+			return fmt.Errorf("archive expansion is not available for streaming uploads")
+		}
+		upload.ExpandArchives = true
+		upload.KeepArchives = params.KeepArchives
+	}
+
+	// Public uploads can be downloaded anonymously even if the server requires authentication to download
+	upload.Public = params.Public
+
+	upload.TTLFromCompletion = params.TTLFromCompletion
+
+	// Uploads can be pinned to one of the configured download domains, other values are ignored
+	if config.IsSelectableDownloadDomain(params.DownloadDomain) {
+		upload.DownloadDomain = params.DownloadDomain
+	}
+
+	if params.DataBackend != "" {
+		route := config.GetDataBackendRoute(params.DataBackend)
+		if route == nil || !route.Selectable {
+			return fmt.Errorf("invalid data backend %s", params.DataBackend)
+		}
+		upload.DataBackend = params.DataBackend
+	}
+
+	if params.ContentDisposition != "" {
+		if !common.IsValidContentDisposition(params.ContentDisposition) {
+			return fmt.Errorf("invalid content disposition %s", params.ContentDisposition)
+		}
+		upload.ContentDisposition = params.ContentDisposition
+	}
+
+	if params.MaxTotalDownloadBytes < 0 {
+		return fmt.Errorf("invalid maximum total download bytes %d", params.MaxTotalDownloadBytes)
+	}
+	upload.MaxTotalDownloadBytes = params.MaxTotalDownloadBytes
+
+	if params.Webhook != "" {
+		err = config.CheckUploadWebhook(params.Webhook)
+		if err != nil {
+			return err
+		}
+		upload.Webhook = params.Webhook
+	}
+
+	err = ctx.setDownloadCountries(upload, params)
+	if err != nil {
+		return err
+	}
+
+	err = params.UserMetadata.Validate(config.MaxUserMetadataSize)
+	if err != nil {
+		return err
+	}
+	upload.UserMetadata = params.UserMetadata
+
 	if config.FeatureComments == common.FeatureDisabled {
 		upload.Comments = ""
 	} else {
-		upload.Comments = params.Comments
+		err = common.ValidateComments(params.Comments, config.MaxCommentLength)
+		if err != nil {
+			return err
+		}
+		upload.Comments = common.SanitizeComments(params.Comments)
 	}
 
 	return nil
@@ -134,12 +315,27 @@ func (ctx *Context) setTTL(upload *common.Upload, params *common.Upload) (err er
 
 		maxTTL := config.MaxTTL
 
-		// Override maxTTL with user specific limit
+		// Authenticated users may keep their uploads longer than anonymous users
 		user := ctx.GetUser()
+		if user != nil && config.AuthenticatedMaxTTL != 0 {
+			maxTTL = config.AuthenticatedMaxTTL
+		}
+
+		// Override maxTTL with user specific limit
 		if user != nil && user.MaxTTL != 0 {
 			maxTTL = user.MaxTTL
 		}
 
+		// Override maxTTL with client app specific limit
+		if app := ctx.GetClientApp(); app != nil && app.MaxTTL != 0 {
+			maxTTL = app.MaxTTL
+		}
+
+		// Upload links may only restrict the user limit
+		if link := ctx.GetUploadLink(); link != nil && link.MaxTTL != 0 && (maxTTL <= 0 || link.MaxTTL < maxTTL) {
+			maxTTL = link.MaxTTL
+		}
+
 		if maxTTL > 0 {
 			if TTL <= 0 {
 				return fmt.Errorf("cannot set infinite TTL (maximum allowed is : %d)", maxTTL)
@@ -149,7 +345,26 @@ func (ctx *Context) setTTL(upload *common.Upload, params *common.Upload) (err er
 			}
 		}
 
+		// Uploads without expiration are only limited by maxTTL
+		if config.MinTTL > 0 && TTL > 0 && TTL < config.MinTTL {
+			return fmt.Errorf("invalid TTL. (minimum allowed is : %d)", config.MinTTL)
+		}
+
 		upload.TTL = TTL
+
+		// The expiration date of uploads with an inactivity TTL is pushed back each time they are downloaded
+		if params.InactivityTTL < 0 {
+			return fmt.Errorf("invalid inactivity TTL %d", params.InactivityTTL)
+		}
+		if params.InactivityTTL > 0 {
+			if config.FeatureExtendTTL == common.FeatureDisabled {
+				return fmt.Errorf("inactivity TTL is disabled")
+			}
+			if maxTTL > 0 && params.InactivityTTL > maxTTL {
+				return fmt.Errorf("invalid inactivity TTL. (maximum allowed is : %d)", maxTTL)
+			}
+			upload.InactivityTTL = params.InactivityTTL
+		}
 	}
 
 	upload.CreatedAt = time.Now()
@@ -158,6 +373,51 @@ func (ctx *Context) setTTL(upload *common.Upload, params *common.Upload) (err er
 	return nil
 }
 
+// setDownloadCountries restrict the countries the upload files can be downloaded from
+func (ctx *Context) setDownloadCountries(upload *common.Upload, params *common.Upload) (err error) {
+	allowed, err := common.ParseCountryCodes(params.AllowedCountries)
+	if err != nil {
+		return fmt.Errorf("invalid allowed countries : %s", err)
+	}
+
+	blocked, err := common.ParseCountryCodes(params.BlockedCountries)
+	if err != nil {
+		return fmt.Errorf("invalid blocked countries : %s", err)
+	}
+
+	if (len(allowed) > 0 || len(blocked) > 0) && ctx.GetConfig().GetGeoIPDatabase() == nil {
+		return fmt.Errorf("download country restrictions are not available on this server")
+	}
+
+	upload.AllowedCountries = strings.Join(allowed, ",")
+	upload.BlockedCountries = strings.Join(blocked, ",")
+
+	return nil
+}
+
+// setFileTTL set the file expiration date, a file can't outlive its upload
+func (ctx *Context) setFileTTL(upload *common.Upload, file *common.File, TTL int) (err error) {
+	config := ctx.GetConfig()
+	if config.FeatureSetTTL == common.FeatureDisabled {
+		return fmt.Errorf("file TTL is disabled")
+	}
+	if TTL < 0 {
+		return fmt.Errorf("invalid file TTL %d", TTL)
+	}
+	if upload.TTL > 0 && TTL > upload.TTL {
+		return fmt.Errorf("invalid file TTL. (maximum allowed is the upload TTL : %d)", upload.TTL)
+	}
+	if config.MinTTL > 0 && TTL < config.MinTTL {
+		return fmt.Errorf("invalid file TTL. (minimum allowed is : %d)", config.MinTTL)
+	}
+
+	file.TTL = TTL
+	deadline := time.Now().Add(time.Duration(TTL) * time.Second)
+	file.ExpireAt = &deadline
+
+	return nil
+}
+
 func (ctx *Context) setBasicAuth(upload *common.Upload, login string, password string) (err error) {
 	config := ctx.GetConfig()
 	if config.FeaturePassword == common.FeatureDisabled && password != "" {
@@ -187,6 +447,25 @@ func (ctx *Context) setBasicAuth(upload *common.Upload, login string, password s
 	return nil
 }
 
+func (ctx *Context) setManagementPassword(upload *common.Upload, password string) (err error) {
+	config := ctx.GetConfig()
+	if config.FeaturePassword == common.FeatureDisabled && password != "" {
+		return fmt.Errorf("upload password protection is disabled")
+	}
+
+	if password == "" {
+		return nil
+	}
+
+	// Save only the bcrypt hash of the management password
+	upload.ManagementPassword, err = common.HashUploadSecret(password)
+	if err != nil {
+		return fmt.Errorf("unable to generate management password hash : %s", err)
+	}
+
+	return nil
+}
+
 func (ctx *Context) setFiles(upload *common.Upload, files []*common.File) (err error) {
 	config := ctx.GetConfig()
 
@@ -221,14 +500,78 @@ func (ctx *Context) CreateFile(upload *common.Upload, params *common.File) (file
 	file.Type = params.Type
 	file.Size = params.Size
 	file.Reference = params.Reference
+	file.EncryptionScheme = params.EncryptionScheme
+	file.EncryptionNonce = params.EncryptionNonce
+	file.WrappedKey = params.WrappedKey
+
+	// Files uploaded without a name are named from the DefaultFilename template
+	if file.Name == "" && ctx.GetConfig().DefaultFilename != "" {
+		file.Name = ctx.GetConfig().GetDefaultFilename(upload, file, time.Now())
+	}
 
 	if file.Name == "" {
 		return nil, fmt.Errorf("missing file name")
 	}
 
-	// Check file name length
-	if len(file.Name) > 1024 {
-		return nil, fmt.Errorf("file name %s... is too long, maximum length is 1024 characters", file.Name[:20])
+	// Normalize the file name and check its length
+	file.Name, err = ctx.GetConfig().CheckFileName(file.Name)
+	if err != nil {
+		return nil, err
+	}
+	file.ASCIIName = common.ASCIIFileName(file.Name)
+
+	// Files may expire before their upload
+	if params.TTL != 0 {
+		err = ctx.setFileTTL(upload, file, params.TTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Files of an uploaded folder tree must stay inside the tree once extracted from an archive
+	file.RelativePath, err = common.SanitizeRelativePath(params.RelativePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file relative path : %s", err)
+	}
+
+	// Check client side encryption details
+	switch file.EncryptionScheme {
+	case "":
+		if file.EncryptionNonce != "" || file.WrappedKey != "" {
+			return nil, fmt.Errorf("missing file encryption scheme")
+		}
+	case common.EncryptionSchemeAES256GCM:
+		if file.EncryptionNonce == "" || file.WrappedKey == "" {
+			return nil, fmt.Errorf("missing file encryption nonce or wrapped key")
+		}
+		if len(file.EncryptionNonce) > 256 || len(file.WrappedKey) > 256 {
+			return nil, fmt.Errorf("file encryption nonce or wrapped key is too long, maximum length is 256 characters")
+		}
+	default:
+		return nil, fmt.Errorf("invalid file encryption scheme %s", file.EncryptionScheme)
+	}
+
+	// Check file extension against the policy of the client app that created the upload
+	if upload.ClientApp != "" {
+		if app := ctx.GetConfig().GetClientApp(upload.ClientApp); app != nil && !app.IsAllowedFileName(file.Name) {
+			return nil, fmt.Errorf("file extension of %s is not allowed", file.Name)
+		}
+	}
+
+	// Check file extension against the policy of the upload preset
+	if upload.Preset != "" {
+		if preset := ctx.GetConfig().GetUploadPreset(upload.Preset); preset != nil && !preset.IsAllowedFileName(file.Name) {
+			return nil, fmt.Errorf("file extension of %s is not allowed", file.Name)
+		}
+	}
+
+	// Check file extension against the constraints of the upload link used to create the upload
+	link, err := ctx.getUploadLink(upload)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil && !link.IsAllowedFileName(file.Name) {
+		return nil, fmt.Errorf("file extension of %s is not allowed", file.Name)
 	}
 
 	// Check file size
@@ -237,11 +580,25 @@ func (ctx *Context) CreateFile(upload *common.Upload, params *common.File) (file
 		return nil, fmt.Errorf("file is too big (%s), maximum file size is %s", humanize.Bytes(uint64(file.Size)), humanize.Bytes(uint64(maxFileSize)))
 	}
 
+	// Stream uploads are never stored in a data backend
+	if !upload.Stream {
+		file.DataBackend = ctx.GetConfig().SelectDataBackend(upload, file)
+	}
+
 	return file, nil
 }
 
 // GetMaxFileSize return the maximum allowed file size for the context
 func (ctx *Context) GetMaxFileSize() int64 {
+	return restrictMaxFileSize(ctx.getMaxFileSize(), ctx.GetUploadLink())
+}
+
+func (ctx *Context) getMaxFileSize() int64 {
+	app := ctx.GetClientApp()
+	if app != nil && app.MaxFileSize != 0 {
+		return app.MaxFileSize
+	}
+
 	user := ctx.GetUser()
 	if user != nil && user.MaxFileSize != 0 {
 		return user.MaxFileSize
@@ -249,3 +606,77 @@ func (ctx *Context) GetMaxFileSize() int64 {
 
 	return ctx.GetConfig().MaxFileSize
 }
+
+// Upload links may only restrict the user limit
+func restrictMaxFileSize(maxFileSize int64, link *common.UploadLink) int64 {
+	if link != nil && link.MaxFileSize != 0 && (maxFileSize <= 0 || link.MaxFileSize < maxFileSize) {
+		return link.MaxFileSize
+	}
+	return maxFileSize
+}
+
+// getUploadLink return the upload link used to create the upload if any
+func (ctx *Context) getUploadLink(upload *common.Upload) (link *common.UploadLink, err error) {
+	if upload.UploadLinkID == nil {
+		return nil, nil
+	}
+
+	link = ctx.GetUploadLink()
+	if link != nil && link.ID == *upload.UploadLinkID {
+		return link, nil
+	}
+
+	if ctx.GetAuthenticator() == nil {
+		return nil, fmt.Errorf("unable to verify upload link")
+	}
+
+	link, err = ctx.GetAuthenticator().ParseUploadLink(upload.UploadLink)
+	if err != nil {
+		return nil, fmt.Errorf("unable to verify upload link : %s", err)
+	}
+
+	return link, nil
+}
+
+// GetUploadMaxFileSize return the maximum allowed file size for files added to an existing upload
+// Anyone with the upload token or the management password may add files so the upload owner limits apply
+func (ctx *Context) GetUploadMaxFileSize(upload *common.Upload) (maxFileSize int64, err error) {
+	maxFileSize, err = ctx.getUploadMaxFileSize(upload)
+	if err != nil {
+		return 0, err
+	}
+
+	link, err := ctx.getUploadLink(upload)
+	if err != nil {
+		return 0, err
+	}
+
+	return restrictMaxFileSize(maxFileSize, link), nil
+}
+
+func (ctx *Context) getUploadMaxFileSize(upload *common.Upload) (maxFileSize int64, err error) {
+	if upload.ClientApp != "" {
+		if app := ctx.GetConfig().GetClientApp(upload.ClientApp); app != nil && app.MaxFileSize != 0 {
+			return app.MaxFileSize, nil
+		}
+	}
+
+	if upload.User == "" {
+		return ctx.GetConfig().MaxFileSize, nil
+	}
+
+	user := ctx.GetUser()
+	if user != nil && user.ID == upload.User {
+		return ctx.GetMaxFileSize(), nil
+	}
+
+	owner, err := ctx.GetMetadataBackend().GetUser(upload.User)
+	if err != nil {
+		return 0, err
+	}
+	if owner != nil && owner.MaxFileSize != 0 {
+		return owner.MaxFileSize, nil
+	}
+
+	return ctx.GetConfig().MaxFileSize, nil
+}
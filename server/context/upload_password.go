@@ -0,0 +1,82 @@
+package context
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CheckUploadBasicAuth check the basic auth credentials of a password protected upload.
+// Requests without credentials are not counted as browsers always try without them first.
+// A common.HTTPError is returned if the upload is locked or the attempts can't be counted
+func (ctx *Context) CheckUploadBasicAuth(upload *common.Upload, authorization string) (err error) {
+	if authorization == "" {
+		return upload.CheckBasicAuth(authorization)
+	}
+
+	return ctx.checkUploadSecret(upload, func() error {
+		return upload.CheckBasicAuth(authorization)
+	})
+}
+
+// CheckUploadManagementPassword check the management password of an upload.
+// Failed attempts are counted with the basic auth failed attempts.
+// A common.HTTPError is returned if the upload is locked or the attempts can't be counted
+func (ctx *Context) CheckUploadManagementPassword(upload *common.Upload, password string) (err error) {
+	if !upload.IsProtectedByManagementPassword() || password == "" {
+		return upload.CheckManagementPassword(password)
+	}
+
+	return ctx.checkUploadSecret(upload, func() error {
+		return upload.CheckManagementPassword(password)
+	})
+}
+
+// checkUploadSecret count the attempts to guess a secret of an upload.
+// Each attempt is reserved before the secret is checked, after MaxUploadPasswordAttempts failed attempts the
+// upload is locked for UploadPasswordLockout, a successful attempt resets the counter.
+func (ctx *Context) checkUploadSecret(upload *common.Upload, check func() error) (err error) {
+	config := ctx.GetConfig()
+	if config.MaxUploadPasswordAttempts <= 0 {
+		return check()
+	}
+
+	// Failed attempts are saved, the lockout applies to the anonymized network if AnonymizeClientIP is set
+	var ip string
+	if config.UploadPasswordAttemptsPerIP {
+		ip = config.FormatClientIP(ctx.GetSourceIP())
+	}
+
+	// The attempt is counted before the secret is checked so concurrent requests can't exceed the limit
+	now := time.Now()
+	attempts, err := ctx.GetMetadataBackend().ReserveUploadPasswordAttempt(upload.ID, ip, config.MaxUploadPasswordAttempts, config.GetUploadPasswordLockout(), now)
+	if err != nil {
+		return common.NewHTTPError("unable to count upload password attempts", err, http.StatusInternalServerError)
+	}
+	if attempts.Failures > config.MaxUploadPasswordAttempts {
+		return newUploadLockedError(attempts, now)
+	}
+
+	err = check()
+	if err == nil {
+		errReset := ctx.GetMetadataBackend().ResetUploadPasswordAttempts(upload.ID, ip)
+		if errReset != nil {
+			ctx.GetLogger().Warningf("unable to reset upload %s password attempts : %s", upload.ID, errReset)
+		}
+		return nil
+	}
+
+	if attempts.IsLocked(now) {
+		ctx.GetLogger().Warningf("upload %s locked after %d failed password attempts", upload.ID, attempts.Failures)
+		return newUploadLockedError(attempts, now)
+	}
+
+	return err
+}
+
+func newUploadLockedError(attempts *common.UploadPasswordAttempts, now time.Time) error {
+	retry := attempts.LockedUntil.Sub(now).Round(time.Second)
+	return common.NewHTTPError(fmt.Sprintf("too many failed password attempts, retry in %s", retry), nil, http.StatusTooManyRequests)
+}
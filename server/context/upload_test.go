@@ -1,8 +1,13 @@
 package context
 
 import (
+	"errors"
 	"github.com/root-gg/utils"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -58,6 +63,38 @@ func TestUpload_AuthenticationForced(t *testing.T) {
 	require.Equal(t, ctx.user.ID, upload.User)
 }
 
+func TestUpload_CaseInsensitiveUploadIDs(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.CaseInsensitiveUploadIDs = true
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err)
+	require.Len(t, upload.ID, 16)
+	require.Equal(t, strings.ToLower(upload.ID), upload.ID, "upload id should be lower case")
+}
+
+func TestNewUploadParams(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureOneShot = common.FeatureEnabled
+	ctx.config.FeatureRemovable = common.FeatureEnabled
+
+	params := ctx.NewUploadParams()
+	require.False(t, params.OneShot)
+	require.False(t, params.Removable)
+
+	ctx.config.FeatureOneShot = common.FeatureDefault
+	ctx.config.FeatureRemovable = common.FeatureForced
+
+	params = ctx.NewUploadParams()
+	require.True(t, params.OneShot)
+	require.True(t, params.Removable)
+	require.False(t, params.TTLFromCompletion)
+
+	ctx.config.TTLFromCompletion = true
+	params = ctx.NewUploadParams()
+	require.True(t, params.TTLFromCompletion)
+}
+
 func TestUpload_OneShotDisabled(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureOneShot = common.FeatureDisabled
@@ -133,6 +170,144 @@ func TestUpload_RemovableEnabled(t *testing.T) {
 
 }
 
+func TestUpload_Public(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err)
+	require.False(t, upload.Public)
+
+	upload, err = ctx.CreateUpload(&common.Upload{Public: true})
+	require.NoError(t, err)
+	require.True(t, upload.Public)
+}
+
+func TestUpload_DownloadDomain(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.DownloadDomain = "https://plik.root.gg"
+	ctx.config.DownloadDomains = []string{"https://team.root.gg"}
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err)
+	require.Equal(t, "", upload.DownloadDomain)
+
+	upload, err = ctx.CreateUpload(&common.Upload{DownloadDomain: "https://team.root.gg"})
+	require.NoError(t, err)
+	require.Equal(t, "https://team.root.gg", upload.DownloadDomain)
+
+	upload, err = ctx.CreateUpload(&common.Upload{DownloadDomain: "https://hack.me"})
+	require.NoError(t, err)
+	require.Equal(t, "", upload.DownloadDomain)
+}
+
+func TestUpload_ContentDisposition(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{ContentDisposition: common.ContentDispositionAttachment})
+	require.NoError(t, err)
+	require.Equal(t, common.ContentDispositionAttachment, upload.ContentDisposition)
+
+	_, err = ctx.CreateUpload(&common.Upload{ContentDisposition: "foo"})
+	common.RequireError(t, err, "invalid content disposition foo")
+}
+
+func TestUpload_ExpandArchives(t *testing.T) {
+	ctx := newTestContext()
+
+	_, err := ctx.CreateUpload(&common.Upload{ExpandArchives: true})
+	common.RequireError(t, err, "archive expansion is disabled")
+
+	ctx.GetConfig().ExpandArchiveOnUpload = true
+
+	upload, err := ctx.CreateUpload(&common.Upload{ExpandArchives: true, KeepArchives: true})
+	require.NoError(t, err)
+	require.True(t, upload.ExpandArchives)
+	require.True(t, upload.KeepArchives)
+
+	upload, err = ctx.CreateUpload(&common.Upload{KeepArchives: true})
+	require.NoError(t, err)
+	require.False(t, upload.KeepArchives, "archives are only kept when expanded")
+
+	_, err = ctx.CreateUpload(&common.Upload{ExpandArchives: true, Stream: true})
+	common.RequireError(t, err, "archive expansion is not available for streaming uploads")
+}
+
+func TestUpload_Webhook(t *testing.T) {
+	ctx := newTestContext()
+
+	_, err := ctx.CreateUpload(&common.Upload{Webhook: "https://hooks.root.gg/plik"})
+	common.RequireError(t, err, "upload webhooks are disabled")
+
+	ctx.GetConfig().UploadWebhookAllowlist = []string{"https://hooks.root.gg/plik"}
+	require.NoError(t, ctx.GetConfig().Initialize())
+
+	upload, err := ctx.CreateUpload(&common.Upload{Webhook: "https://hooks.root.gg/plik/42"})
+	require.NoError(t, err)
+	require.Equal(t, "https://hooks.root.gg/plik/42", upload.Webhook)
+
+	_, err = ctx.CreateUpload(&common.Upload{Webhook: "http://127.0.0.1:8080/admin"})
+	common.RequireError(t, err, "webhook URL http://127.0.0.1:8080/admin is not allowed")
+}
+
+func TestUpload_MaxTotalDownloadBytes(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{MaxTotalDownloadBytes: 1000, DownloadedBytes: 42})
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), upload.MaxTotalDownloadBytes)
+	require.Zero(t, upload.DownloadedBytes, "downloaded bytes should not be set by the client")
+
+	_, err = ctx.CreateUpload(&common.Upload{MaxTotalDownloadBytes: -1})
+	common.RequireError(t, err, "invalid maximum total download bytes -1")
+}
+
+func TestUpload_UserMetadata(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{UserMetadata: common.UserMetadata{"ticket": "PLIK-42"}})
+	require.NoError(t, err)
+	require.Equal(t, common.UserMetadata{"ticket": "PLIK-42"}, upload.UserMetadata)
+
+	ctx.config.MaxUserMetadataSize = 10
+	_, err = ctx.CreateUpload(&common.Upload{UserMetadata: common.UserMetadata{"ticket": "PLIK-42"}})
+	common.RequireError(t, err, "user metadata too large, maximum size is 10 bytes")
+}
+
+func TestUpload_DataBackend(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.DataBackends = []*common.DataBackendRoute{
+		{Name: "hidden", Backend: "testing"},
+		{Name: "archive", Backend: "testing", Selectable: true},
+	}
+
+	upload, err := ctx.CreateUpload(&common.Upload{DataBackend: "archive", Files: []*common.File{{Name: "foo"}}})
+	require.NoError(t, err)
+	require.Equal(t, "archive", upload.DataBackend)
+	require.Equal(t, "archive", upload.Files[0].DataBackend)
+
+	_, err = ctx.CreateUpload(&common.Upload{DataBackend: "hidden"})
+	common.RequireError(t, err, "invalid data backend hidden")
+
+	_, err = ctx.CreateUpload(&common.Upload{DataBackend: "foo"})
+	common.RequireError(t, err, "invalid data backend foo")
+}
+
+func TestUpload_DataBackendRouting(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.DataBackends = []*common.DataBackendRoute{
+		{Name: "big", Backend: "testing", MinFileSize: 1000},
+	}
+
+	upload, err := ctx.CreateUpload(&common.Upload{Files: []*common.File{{Name: "small", Size: 10}, {Name: "big", Size: 1000}}})
+	require.NoError(t, err)
+	require.Equal(t, "", upload.Files[0].DataBackend)
+	require.Equal(t, "big", upload.Files[1].DataBackend)
+
+	upload, err = ctx.CreateUpload(&common.Upload{Stream: true, Files: []*common.File{{Name: "big", Size: 1000}}})
+	require.NoError(t, err)
+	require.Equal(t, "", upload.Files[0].DataBackend, "stream files are not stored in a data backend")
+}
+
 func TestUpload_RemovableForced(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureRemovable = common.FeatureForced
@@ -255,6 +430,31 @@ func TestUpload_PasswordDefaultLogin(t *testing.T) {
 	require.True(t, upload.ProtectedByPassword)
 }
 
+func TestUpload_ManagementPassword(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.False(t, upload.IsProtectedByManagementPassword())
+
+	upload, err = ctx.CreateUpload(&common.Upload{ManagementPassword: "password"})
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+	require.True(t, upload.IsProtectedByManagementPassword())
+	require.NotEqual(t, "password", upload.ManagementPassword)
+	require.True(t, common.CheckPasswordHash("password", upload.ManagementPassword))
+}
+
+func TestUpload_ManagementPasswordDisabled(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeaturePassword = common.FeatureDisabled
+
+	upload, err := ctx.CreateUpload(&common.Upload{ManagementPassword: "password"})
+	common.RequireError(t, err, "upload password protection is disabled")
+	require.Nil(t, upload)
+}
+
 func TestUpload_CommentsDisabled(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureComments = common.FeatureDisabled
@@ -286,6 +486,63 @@ func TestUpload_CommentsEnabled(t *testing.T) {
 
 }
 
+func TestUpload_InactivityTTL(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxTTL = 86400
+
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: 3600, InactivityTTL: 60})
+	require.NoError(t, err)
+	require.Equal(t, 60, upload.InactivityTTL)
+	require.True(t, upload.ExpireAt.Before(time.Now().Add(2*time.Minute)), "expiration date should follow the inactivity TTL")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, InactivityTTL: -1})
+	common.RequireError(t, err, "invalid inactivity TTL -1")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, InactivityTTL: 86401})
+	common.RequireError(t, err, "invalid inactivity TTL. (maximum allowed is : 86400)")
+
+	ctx.config.FeatureExtendTTL = common.FeatureDisabled
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, InactivityTTL: 60})
+	common.RequireError(t, err, "inactivity TTL is disabled")
+}
+
+func TestUpload_CommentsTooLong(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxCommentLength = 10
+
+	upload, err := ctx.CreateUpload(&common.Upload{Comments: "0123456789"})
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", upload.Comments)
+
+	upload, err = ctx.CreateUpload(&common.Upload{Comments: "0123456789+"})
+	common.RequireError(t, err, "comments too long, maximum length is 10 characters")
+	require.Nil(t, upload)
+}
+
+func TestUpload_MaxUploadMetadataBytes(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxUploadMetadataBytes = 20
+
+	params := &common.Upload{Comments: "0123456789", Files: []*common.File{{Name: "file.txt"}}}
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+
+	params.UserMetadata = common.UserMetadata{"k": "v"}
+	upload, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "upload metadata too large (27 bytes), maximum size is 20 bytes")
+	require.True(t, errors.Is(err, common.ErrMetadataTooLarge))
+	require.Nil(t, upload)
+}
+
+func TestUpload_CommentsSanitized(t *testing.T) {
+	ctx := newTestContext()
+
+	upload, err := ctx.CreateUpload(&common.Upload{Comments: "**hello** <script>alert(1)</script>"})
+	require.NoError(t, err)
+	require.Equal(t, "**hello** &lt;script>alert(1)&lt;/script>", upload.Comments)
+}
+
 func TestUpload_CommentsForced(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureComments = common.FeatureForced
@@ -346,6 +603,16 @@ func TestUpload_ExtendTTLForced(t *testing.T) {
 	require.True(t, upload.ExtendTTL)
 }
 
+func TestCreateUploadAnonymizedRemoteIP(t *testing.T) {
+	ctx := newTestContext()
+	ctx.sourceIP = net.ParseIP("4.2.4.2")
+	ctx.config.AnonymizeClientIP = true
+
+	upload, err := ctx.CreateUpload(&common.Upload{})
+	require.NoError(t, err, "unable to create upload")
+	require.Equal(t, "4.2.4.0", upload.RemoteIP, "invalid remote IP")
+}
+
 func TestCreateUpload(t *testing.T) {
 	ctx := newTestContext()
 	ctx.sourceIP = net.ParseIP("4.2.4.2")
@@ -450,6 +717,29 @@ func TestSetTTL(t *testing.T) {
 	require.Nil(t, upload)
 }
 
+func TestSetTTLMinTTL(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MinTTL = 60
+	ctx.config.MaxTTL = 0
+
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: 60})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, 60, upload.TTL, "invalid TTL")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 1})
+	common.RequireError(t, err, "invalid TTL. (minimum allowed is : 60)")
+
+	// Default TTL
+	upload, err = ctx.CreateUpload(&common.Upload{TTL: 0})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, ctx.config.DefaultTTL, upload.TTL, "invalid TTL")
+
+	// No expiration
+	upload, err = ctx.CreateUpload(&common.Upload{TTL: -1})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, -1, upload.TTL, "invalid TTL")
+}
+
 func TestSetTTLDisabled(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.FeatureSetTTL = common.FeatureDisabled
@@ -540,6 +830,33 @@ func TestSetTTLUser(t *testing.T) {
 	common.RequireError(t, err, "invalid TTL")
 }
 
+func TestSetTTLAuthenticated(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxTTL = 10
+	ctx.config.AuthenticatedMaxTTL = 100
+	ctx.config.FeatureAuthentication = common.FeatureEnabled
+	_, err := ctx.CreateUpload(&common.Upload{TTL: 60})
+	common.RequireError(t, err, "invalid TTL")
+
+	ctx.user = &common.User{MaxTTL: 0}
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: 60})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, 60, upload.TTL, "invalid TTL")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: -1})
+	common.RequireError(t, err, "cannot set infinite TTL")
+
+	ctx.config.AuthenticatedMaxTTL = -1
+	upload, err = ctx.CreateUpload(&common.Upload{TTL: -1})
+	require.NoError(t, err, "unable to set ttl")
+	require.Equal(t, -1, upload.TTL, "invalid TTL")
+
+	// User specific limit still takes precedence
+	ctx.user = &common.User{MaxTTL: 30}
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 60})
+	common.RequireError(t, err, "invalid TTL")
+}
+
 func TestCreateMissingFilename(t *testing.T) {
 	ctx := newTestContext()
 
@@ -551,6 +868,106 @@ func TestCreateMissingFilename(t *testing.T) {
 	require.Nil(t, upload)
 }
 
+func TestCreateDefaultFilename(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.DefaultFilename = "upload-{uploadId}/{fileId}"
+
+	params := &common.Upload{}
+	params.NewFile()
+
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err)
+	require.Len(t, upload.Files, 1)
+	require.Equal(t, "upload-"+upload.ID+"_"+upload.Files[0].ID, upload.Files[0].Name, "invalid default file name")
+}
+
+func TestCreateWithFileTTL(t *testing.T) {
+	ctx := newTestContext()
+
+	params := &common.Upload{TTL: 3600}
+	params.Files = append(params.Files, &common.File{Name: "manifest", TTL: 60}, &common.File{Name: "data"})
+
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "unable to create upload")
+	require.Equal(t, 60, upload.Files[0].TTL, "invalid file TTL")
+	require.NotNil(t, upload.Files[0].ExpireAt, "missing file expiration date")
+	require.True(t, upload.Files[0].ExpireAt.Before(*upload.ExpireAt), "file should expire before its upload")
+	require.Nil(t, upload.Files[1].ExpireAt, "file without TTL should expire with its upload")
+
+	params.Files[0].TTL = 7200
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "invalid file TTL. (maximum allowed is the upload TTL : 3600)")
+
+	params.Files[0].TTL = -1
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "invalid file TTL -1")
+
+	params.Files[0].TTL = 60
+	ctx.config.FeatureSetTTL = common.FeatureDisabled
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "file TTL is disabled")
+}
+
+func TestCreateWithDownloadCountries(t *testing.T) {
+	ctx := newTestContext()
+
+	params := &common.Upload{AllowedCountries: "fr, de", BlockedCountries: "DE"}
+	_, err := ctx.CreateUpload(params)
+	common.RequireError(t, err, "download country restrictions are not available on this server")
+
+	dir, err := ioutil.TempDir("", "plik_geoip_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	ctx.config.GeoIPDatabase = filepath.Join(dir, "geoip.csv")
+	require.NoError(t, ioutil.WriteFile(ctx.config.GeoIPDatabase, []byte("192.0.2.0/24,FR\n"), 0600))
+	require.NoError(t, ctx.config.Initialize(), "unable to initialize config")
+
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "unable to create upload")
+	require.Equal(t, "FR,DE", upload.AllowedCountries, "invalid allowed countries")
+	require.Equal(t, "DE", upload.BlockedCountries, "invalid blocked countries")
+
+	params.BlockedCountries = "Germany"
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "invalid blocked countries")
+}
+
+func TestCreateWithRelativePath(t *testing.T) {
+	ctx := newTestContext()
+
+	params := &common.Upload{}
+	params.Files = append(params.Files, &common.File{Name: "file", RelativePath: "project//src/"})
+
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "unable to create upload")
+	require.Equal(t, "project/src", upload.Files[0].RelativePath, "invalid file relative path")
+
+	params.Files[0].RelativePath = "../../etc"
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "invalid file relative path")
+}
+
+func TestCreateWithUnicodeFilename(t *testing.T) {
+	ctx := newTestContext()
+	ctx.GetConfig().MaxFilenameLength = 16
+
+	params := &common.Upload{Files: []*common.File{{Name: "re\u0301sume\u0301\u202etxt.exe"}}}
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err)
+	require.Equal(t, "résumétxt.exe", upload.Files[0].Name, "invalid normalized file name")
+	require.Equal(t, "resumetxt.exe", upload.Files[0].ASCIIName, "invalid ascii file name")
+
+	params = &common.Upload{Files: []*common.File{{Name: "a_very_long_file_name.txt"}}}
+	_, err = ctx.CreateUpload(params)
+	common.RequireError(t, err, "is too long, maximum length is 16 bytes")
+
+	ctx.GetConfig().TruncateLongFilenames = true
+	upload, err = ctx.CreateUpload(params)
+	require.NoError(t, err)
+	require.Equal(t, "a_very_long_.txt", upload.Files[0].Name, "invalid truncated file name")
+}
+
 func TestCreateWithFilenameTooLong(t *testing.T) {
 	ctx := newTestContext()
 
@@ -567,6 +984,46 @@ func TestCreateWithFilenameTooLong(t *testing.T) {
 	require.Nil(t, upload)
 }
 
+func TestCreateWithFileEncryption(t *testing.T) {
+	ctx := newTestContext()
+
+	params := &common.Upload{}
+	params.Files = append(params.Files, &common.File{
+		Name:             "foo",
+		EncryptionScheme: common.EncryptionSchemeAES256GCM,
+		EncryptionNonce:  "nonce",
+		WrappedKey:       "key",
+	})
+
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err)
+	require.Len(t, upload.Files, 1)
+	require.Equal(t, common.EncryptionSchemeAES256GCM, upload.Files[0].EncryptionScheme)
+	require.Equal(t, "nonce", upload.Files[0].EncryptionNonce)
+	require.Equal(t, "key", upload.Files[0].WrappedKey)
+}
+
+func TestCreateWithInvalidFileEncryption(t *testing.T) {
+	ctx := newTestContext()
+
+	long := strings.Repeat("x", 512)
+	tests := []struct {
+		file    *common.File
+		message string
+	}{
+		{&common.File{Name: "foo", EncryptionScheme: "rot13", EncryptionNonce: "nonce", WrappedKey: "key"}, "invalid file encryption scheme rot13"},
+		{&common.File{Name: "foo", EncryptionNonce: "nonce", WrappedKey: "key"}, "missing file encryption scheme"},
+		{&common.File{Name: "foo", EncryptionScheme: common.EncryptionSchemeAES256GCM, EncryptionNonce: "nonce"}, "missing file encryption nonce or wrapped key"},
+		{&common.File{Name: "foo", EncryptionScheme: common.EncryptionSchemeAES256GCM, EncryptionNonce: "nonce", WrappedKey: long}, "too long"},
+	}
+
+	for _, test := range tests {
+		upload, err := ctx.CreateUpload(&common.Upload{Files: []*common.File{test.file}})
+		common.RequireError(t, err, test.message)
+		require.Nil(t, upload)
+	}
+}
+
 func TestCreateWithFileTooBig(t *testing.T) {
 	ctx := newTestContext()
 	ctx.config.MaxFileSize = 1024
@@ -607,6 +1064,137 @@ func TestCreateWithFileTooBigUser(t *testing.T) {
 	require.Nil(t, upload)
 }
 
+func TestGetUploadMaxFileSize(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.MaxFileSize = 1024
+	ctx.user = &common.User{ID: "user", MaxFileSize: 100 * 1024}
+
+	maxFileSize, err := ctx.GetUploadMaxFileSize(&common.Upload{})
+	require.NoError(t, err, "unable to get upload max file size")
+	require.Equal(t, int64(1024), maxFileSize, "anonymous uploads should use the default limit")
+
+	maxFileSize, err = ctx.GetUploadMaxFileSize(&common.Upload{User: "user"})
+	require.NoError(t, err, "unable to get upload max file size")
+	require.Equal(t, int64(100*1024), maxFileSize, "upload owner should use its own limit")
+}
+
+func TestCreateUploadClientApp(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureAuthentication = common.FeatureForced
+	ctx.config.MaxFileSize = 1024
+	ctx.config.MaxTTL = 3600
+	ctx.config.ClientApps = []*common.ClientApp{{Name: "backup", Key: "secret", MaxFileSize: 10 * 1024, MaxTTL: 86400, AllowedExtensions: []string{".tar"}}}
+	ctx.clientApp = ctx.config.ClientApps[0]
+
+	params := &common.Upload{TTL: 86400, Files: []*common.File{{Name: "backup.tar", Size: 5 * 1024}}}
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "client apps should be allowed to upload when authentication is forced")
+	require.Equal(t, "backup", upload.ClientApp)
+	require.Equal(t, "", upload.User)
+	require.Equal(t, 86400, upload.TTL)
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 2 * 86400})
+	common.RequireError(t, err, "invalid TTL")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, Files: []*common.File{{Name: "backup.tar", Size: 20 * 1024}}})
+	common.RequireError(t, err, "is too big")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, Files: []*common.File{{Name: "backup.exe"}}})
+	common.RequireError(t, err, "file extension of backup.exe is not allowed")
+
+	// Files added later with the upload token follow the client app policy
+	ctx.clientApp = nil
+	_, err = ctx.CreateFile(upload, &common.File{Name: "malware.exe"})
+	common.RequireError(t, err, "file extension of malware.exe is not allowed")
+
+	maxFileSize, err := ctx.GetUploadMaxFileSize(upload)
+	require.NoError(t, err, "unable to get upload max file size")
+	require.Equal(t, int64(10*1024), maxFileSize, "invalid client app max file size")
+
+	_, err = ctx.CreateUpload(&common.Upload{})
+	common.RequireError(t, err, "anonymous uploads are disabled")
+}
+
+func TestCreateUploadPreset(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.UploadPresets = []*common.UploadPreset{{Name: "confidential", TTL: 86400, LockTTL: true, RequirePassword: true, AllowedExtensions: []string{".pdf"}}}
+
+	params := &common.Upload{Preset: "confidential", TTL: 86400, Password: "secret", Files: []*common.File{{Name: "report.pdf"}}}
+	upload, err := ctx.CreateUpload(params)
+	require.NoError(t, err, "unable to create upload with preset")
+	require.Equal(t, "confidential", upload.Preset)
+	require.True(t, upload.ProtectedByPassword)
+
+	_, err = ctx.CreateUpload(&common.Upload{Preset: "foo"})
+	common.RequireError(t, err, "invalid upload preset foo")
+
+	_, err = ctx.CreateUpload(&common.Upload{Preset: "confidential", TTL: 3600, Password: "secret"})
+	common.RequireError(t, err, "TTL is locked by upload preset confidential")
+
+	_, err = ctx.CreateUpload(&common.Upload{Preset: "confidential", TTL: 86400})
+	common.RequireError(t, err, "upload preset confidential requires a password")
+
+	_, err = ctx.CreateUpload(&common.Upload{Preset: "confidential", TTL: 86400, Password: "secret", Files: []*common.File{{Name: "file.exe"}}})
+	common.RequireError(t, err, "file extension of file.exe is not allowed")
+
+	// Files added later with the upload token follow the preset policy
+	_, err = ctx.CreateFile(upload, &common.File{Name: "malware.exe"})
+	common.RequireError(t, err, "file extension of malware.exe is not allowed")
+}
+
+func TestCreateUploadLink(t *testing.T) {
+	ctx := newTestContext()
+	ctx.config.FeatureAuthentication = common.FeatureForced
+	ctx.config.MaxTTL = 86400
+	ctx.authenticator = &common.SessionAuthenticator{SignatureKey: "key"}
+
+	owner := &common.User{ID: "user", MaxFileSize: 100 * 1024}
+	link := &common.UploadLink{ID: "link", User: owner.ID, MaxFileSize: 10 * 1024, MaxTTL: 3600, AllowedExtensions: []string{".pdf"}, Owner: owner}
+	token, err := ctx.authenticator.SignUploadLink(link)
+	require.NoError(t, err, "unable to sign upload link")
+	link.Token = token
+	ctx.uploadLink = link
+
+	upload, err := ctx.CreateUpload(&common.Upload{TTL: 3600, Files: []*common.File{{Name: "report.pdf", Size: 5 * 1024}}})
+	require.NoError(t, err, "upload links should be allowed to upload when authentication is forced")
+	require.Equal(t, owner.ID, upload.User, "upload should belong to the link owner")
+	require.Equal(t, "link", *upload.UploadLinkID)
+	require.Equal(t, token, upload.UploadLink)
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 86400})
+	common.RequireError(t, err, "invalid TTL")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, Files: []*common.File{{Name: "report.pdf", Size: 20 * 1024}}})
+	common.RequireError(t, err, "is too big")
+
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 3600, Files: []*common.File{{Name: "report.exe"}}})
+	common.RequireError(t, err, "file extension of report.exe is not allowed")
+
+	// Files added later with the upload token follow the upload link constraints
+	ctx.uploadLink = nil
+	ctx.user = nil
+	_, err = ctx.CreateFile(upload, &common.File{Name: "malware.exe"})
+	common.RequireError(t, err, "file extension of malware.exe is not allowed")
+
+	ctx.user = owner
+	maxFileSize, err := ctx.GetUploadMaxFileSize(upload)
+	require.NoError(t, err, "unable to get upload max file size")
+	require.Equal(t, int64(10*1024), maxFileSize, "invalid upload link max file size")
+
+	// Upload links can't raise the user limits
+	link.MaxFileSize = 1000 * 1024
+	link.MaxTTL = 2 * 86400
+	ctx.uploadLink = link
+	require.Equal(t, int64(100*1024), ctx.GetMaxFileSize())
+	_, err = ctx.CreateUpload(&common.Upload{TTL: 2 * 86400})
+	common.RequireError(t, err, "invalid TTL")
+
+	upload.UploadLink = "foo"
+	ctx.uploadLink = nil
+	_, err = ctx.GetUploadMaxFileSize(upload)
+	common.RequireError(t, err, "unable to verify upload link")
+}
+
 func TestCreateFile(t *testing.T) {
 	ctx := newTestContext()
 	file, err := ctx.CreateFile(&common.Upload{}, &common.File{Name: "foo"})
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Ensure CircuitBreakerBackend implements data.Backend, data.Lister, data.AccelRedirecter, data.Sweeper, data.StorageClassTransitioner and data.RangeGetter interfaces
+var _ Backend = (*CircuitBreakerBackend)(nil)
+var _ Lister = (*CircuitBreakerBackend)(nil)
+var _ AccelRedirecter = (*CircuitBreakerBackend)(nil)
+var _ Sweeper = (*CircuitBreakerBackend)(nil)
+var _ StorageClassTransitioner = (*CircuitBreakerBackend)(nil)
+var _ RangeGetter = (*CircuitBreakerBackend)(nil)
+
+// ErrCircuitOpen is returned without calling the data backend while the circuit breaker is open
+var ErrCircuitOpen = errors.New("data backend temporarily unavailable")
+
+// Circuit breaker states
+const (
+	// CircuitClosed when the data backend operations are allowed
+	CircuitClosed = "closed"
+	// CircuitOpen when the data backend operations fail fast until the end of the cooldown
+	CircuitOpen = "open"
+	// CircuitHalfOpen when a single operation probes the data backend after the cooldown
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreakerBackend stop calling a failing data backend after threshold consecutive transient errors.
+// Operations fail fast with ErrCircuitOpen during the cooldown, then a single operation probes the data backend
+// and closes the circuit if it succeeds or opens it for another cooldown if it fails.
+type CircuitBreakerBackend struct {
+	backend   Backend
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerBackend instantiate a new CircuitBreakerBackend
+func NewCircuitBreakerBackend(backend Backend, threshold int, cooldown time.Duration) (b *CircuitBreakerBackend) {
+	b = new(CircuitBreakerBackend)
+	b.backend = backend
+	b.threshold = threshold
+	b.cooldown = cooldown
+	b.state = CircuitClosed
+	return b
+}
+
+// GetState return the circuit breaker state
+func (b *CircuitBreakerBackend) GetState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+
+	return b.state
+}
+
+// allow return true if the operation can call the data backend
+func (b *CircuitBreakerBackend) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// report the outcome of an operation, only transient errors are failures of the data backend
+func (b *CircuitBreakerBackend) report(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+
+	if !failed {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// AddFile add the file to the data backend, errors reading the uploaded data are not failures of the data backend
+func (b *CircuitBreakerBackend) AddFile(file *common.File, reader io.Reader) (err error) {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+
+	source := &sourceReader{reader: reader}
+	err = b.backend.AddFile(file, source)
+	b.report(err != nil && source.err == nil && isTransientError(b.backend, err))
+
+	return err
+}
+
+// GetFile get the file from the data backend
+func (b *CircuitBreakerBackend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	reader, err = b.backend.GetFile(file)
+	b.report(err != nil && isTransientError(b.backend, err))
+
+	return reader, err
+}
+
+// GetFileRange get a part of the file from the data backend
+func (b *CircuitBreakerBackend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	reader, err = GetFileRange(b.backend, file, offset, length)
+	b.report(err != nil && isTransientError(b.backend, err))
+
+	return reader, err
+}
+
+// RemoveFile remove the file from the data backend
+func (b *CircuitBreakerBackend) RemoveFile(file *common.File) (err error) {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+
+	err = b.backend.RemoveFile(file)
+	b.report(err != nil && isTransientError(b.backend, err))
+
+	return err
+}
+
+// ForEachFile execute f for every file of the data backend if it supports listing files
+func (b *CircuitBreakerBackend) ForEachFile(f func(file *common.File) error) (err error) {
+	lister, ok := b.backend.(Lister)
+	if !ok {
+		return fmt.Errorf("data backend does not support listing files")
+	}
+	return lister.ForEachFile(f)
+}
+
+// GetAccelRedirect return the internal location of the file if the data backend supports it
+func (b *CircuitBreakerBackend) GetAccelRedirect(file *common.File) (location string, err error) {
+	if redirecter, ok := b.backend.(AccelRedirecter); ok {
+		return redirecter.GetAccelRedirect(file)
+	}
+	return "", nil
+}
+
+// SweepTempFiles delete the abandoned temporary files of the data backend if it supports it
+func (b *CircuitBreakerBackend) SweepTempFiles() (removed int, err error) {
+	if sweeper, ok := b.backend.(Sweeper); ok {
+		return sweeper.SweepTempFiles()
+	}
+	return 0, nil
+}
+
+// GetTempDir return the temporary directory of the data backend if it supports it
+func (b *CircuitBreakerBackend) GetTempDir(file *common.File) (dir string, err error) {
+	return GetTempDir(b.backend, file)
+}
+
+// GetStorageClassTransitionAge return the age after which files are transitioned if the data backend supports it
+func (b *CircuitBreakerBackend) GetStorageClassTransitionAge() time.Duration {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.GetStorageClassTransitionAge()
+	}
+	return 0
+}
+
+// TransitionStorageClass move the file to a cheaper storage class if the data backend supports it
+func (b *CircuitBreakerBackend) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.TransitionStorageClass(file)
+	}
+	return false, nil
+}
+
+// sourceReader remember the errors reading the uploaded data
+type sourceReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *sourceReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// GetCircuitState return the worst circuit breaker state of the data backend and of the data backends of a Router
+func GetCircuitState(backend Backend) string {
+	switch b := backend.(type) {
+	case *CircuitBreakerBackend:
+		return b.GetState()
+	case *Router:
+		state := CircuitClosed
+		for _, routed := range b.backends {
+			switch GetCircuitState(routed) {
+			case CircuitOpen:
+				return CircuitOpen
+			case CircuitHalfOpen:
+				state = CircuitHalfOpen
+			}
+		}
+		return state
+	default:
+		return CircuitClosed
+	}
+}
@@ -0,0 +1,106 @@
+package data_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func TestCircuitBreakerBackend(t *testing.T) {
+	backend := newFlakyBackend(2, errTransient)
+	breaker := data.NewCircuitBreakerBackend(backend, 2, time.Hour)
+	require.Equal(t, data.CircuitClosed, breaker.GetState(), "invalid state")
+
+	for i := 0; i < 2; i++ {
+		err := breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+		require.Equal(t, errTransient, err, "invalid error")
+	}
+	require.Equal(t, data.CircuitOpen, breaker.GetState(), "invalid state")
+
+	err := breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, data.ErrCircuitOpen, err, "invalid error")
+	require.Equal(t, 2, backend.attempts, "the data backend should not be called while the circuit is open")
+
+	_, err = breaker.GetFile(&common.File{ID: "file"})
+	require.Equal(t, data.ErrCircuitOpen, err, "invalid error")
+
+	err = breaker.RemoveFile(&common.File{ID: "file"})
+	require.Equal(t, data.ErrCircuitOpen, err, "invalid error")
+}
+
+func TestCircuitBreakerBackendProbe(t *testing.T) {
+	backend := newFlakyBackend(2, errTransient)
+	breaker := data.NewCircuitBreakerBackend(backend, 1, 20*time.Millisecond)
+
+	err := breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errTransient, err, "invalid error")
+	require.Equal(t, data.CircuitOpen, breaker.GetState(), "invalid state")
+
+	// A failed probe opens the circuit for another cooldown
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, data.CircuitHalfOpen, breaker.GetState(), "invalid state")
+	err = breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errTransient, err, "invalid error")
+	require.Equal(t, data.CircuitOpen, breaker.GetState(), "invalid state")
+
+	// A successful probe closes the circuit
+	time.Sleep(30 * time.Millisecond)
+	file := &common.File{ID: "file"}
+	err = breaker.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+	require.Equal(t, data.CircuitClosed, breaker.GetState(), "invalid state")
+	require.Equal(t, "data data data", getContent(t, breaker, file), "invalid file content")
+}
+
+func TestCircuitBreakerBackendPermanentError(t *testing.T) {
+	backend := newFlakyBackend(10, errPermanent)
+	breaker := data.NewCircuitBreakerBackend(backend, 1, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		err := breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+		require.Equal(t, errPermanent, err, "invalid error")
+	}
+	require.Equal(t, data.CircuitClosed, breaker.GetState(), "permanent errors should not open the circuit")
+}
+
+func TestCircuitBreakerBackendSourceError(t *testing.T) {
+	backend := newFlakyBackend(10, errTransient)
+	breaker := data.NewCircuitBreakerBackend(backend, 1, time.Hour)
+
+	reader, writer := io.Pipe()
+	_ = writer.CloseWithError(common.ErrTransferAborted)
+
+	err := breaker.AddFile(&common.File{ID: "file"}, reader)
+	require.Equal(t, common.ErrTransferAborted, err, "invalid error")
+	require.Equal(t, data.CircuitClosed, breaker.GetState(), "errors reading the uploaded data should not open the circuit")
+}
+
+func TestCircuitBreakerBackendRetry(t *testing.T) {
+	backend := newFlakyBackend(10, errTransient)
+	retryBackend := data.NewRetryBackend(backend, 0, 1).WithBackoff(time.Millisecond)
+	breaker := data.NewCircuitBreakerBackend(retryBackend, 1, time.Hour)
+
+	err := breaker.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errTransient, err, "invalid error")
+	require.Equal(t, data.CircuitOpen, breaker.GetState(), "invalid state")
+}
+
+func TestGetCircuitState(t *testing.T) {
+	require.Equal(t, data.CircuitClosed, data.GetCircuitState(data_test.NewBackend()), "invalid state")
+
+	backend := newFlakyBackend(10, errTransient)
+	breaker := data.NewCircuitBreakerBackend(backend, 1, time.Hour)
+	router := data.NewRouter(data_test.NewBackend()).Register("flaky", breaker)
+	require.Equal(t, data.CircuitClosed, data.GetCircuitState(router), "invalid state")
+
+	err := router.AddFile(&common.File{ID: "file", DataBackend: "flaky"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errTransient, err, "invalid error")
+	require.Equal(t, data.CircuitOpen, data.GetCircuitState(router), "invalid state")
+}
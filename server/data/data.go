@@ -1,7 +1,10 @@
 package data
 
 import (
+	"context"
 	"io"
+	"io/ioutil"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 )
@@ -14,3 +17,107 @@ type Backend interface {
 	// RemoveFile should not fail if the file is not found
 	RemoveFile(file *common.File) (err error)
 }
+
+// Lister interface describes data backends able to enumerate the files they store.
+// It is used to find files left over without metadata.
+type Lister interface {
+	// ForEachFile execute f for every file in the data backend. Only the file ID, UploadID ( if known ) and
+	// CreatedAt ( last modification date ) are set, which is enough to pass the file to RemoveFile.
+	ForEachFile(f func(file *common.File) error) (err error)
+}
+
+// Sweeper interface describes data backends writing temporary files that may be left behind after a crash.
+type Sweeper interface {
+	// SweepTempFiles delete the abandoned temporary files and return how many were deleted
+	SweepTempFiles() (removed int, err error)
+}
+
+// AccelRedirecter interface describes data backends able to let a frontend reverse proxy serve the files.
+type AccelRedirecter interface {
+	// GetAccelRedirect return the internal location of the file to put in the X-Accel-Redirect header,
+	// or an empty string if offloading is disabled.
+	GetAccelRedirect(file *common.File) (location string, err error)
+}
+
+// StorageClassTransitioner interface describes data backends able to move the files they store to a cheaper
+// storage class once they are old enough.
+type StorageClassTransitioner interface {
+	// GetStorageClassTransitionAge return the age after which files are transitioned, 0 if transitions are disabled
+	GetStorageClassTransitionAge() time.Duration
+	// TransitionStorageClass move the file to the cheaper storage class if it is old enough and has not been moved yet.
+	// The new storage class is saved in the file backend details which must then be saved in the metadata.
+	TransitionStorageClass(file *common.File) (transitioned bool, err error)
+}
+
+// RangeGetter interface describes data backends able to read a part of a file without reading its beginning.
+// Backends must support concurrent reads of different parts of the same file.
+type RangeGetter interface {
+	// GetFileRange return a reader of length bytes of the file starting at offset
+	GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error)
+}
+
+// ContextAdder interface describes data backends able to cancel the write of a file.
+type ContextAdder interface {
+	// AddFileWithContext add the file to the data backend, the write is aborted once the context is canceled
+	AddFileWithContext(ctx context.Context, file *common.File, reader io.Reader) (err error)
+}
+
+// TempDirGetter interface describes data backends writing their temporary files to a local directory.
+// Other temporary copies of their files should be written there as well.
+type TempDirGetter interface {
+	// GetTempDir return the temporary directory of the data backend storing the file, it is created if needed
+	GetTempDir(file *common.File) (dir string, err error)
+}
+
+// GetTempDir return the temporary directory of the data backend storing the file.
+// An empty string ( os.TempDir() ) is returned if the data backend does not implement TempDirGetter.
+func GetTempDir(backend Backend, file *common.File) (dir string, err error) {
+	if getter, ok := backend.(TempDirGetter); ok {
+		return getter.GetTempDir(file)
+	}
+	return "", nil
+}
+
+// GetFileRange return a reader of length bytes of the file starting at offset.
+// The beginning of the file is read and discarded if the data backend does not implement RangeGetter.
+func GetFileRange(backend Backend, file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	if getter, ok := backend.(RangeGetter); ok {
+		return getter.GetFileRange(file, offset, length)
+	}
+
+	fileReader, err := backend.GetFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.CopyN(ioutil.Discard, fileReader, offset)
+	if err != nil {
+		_ = fileReader.Close()
+		return nil, err
+	}
+
+	return NewLimitedReadCloser(fileReader, length), nil
+}
+
+// limitedReadCloser read at most n bytes of the underlying reader and close it
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewLimitedReadCloser return a ReadCloser reading at most n bytes of the reader
+func NewLimitedReadCloser(reader io.ReadCloser, n int64) io.ReadCloser {
+	return &limitedReadCloser{Reader: io.LimitReader(reader, n), Closer: reader}
+}
+
+// RemoveFile remove the file and its thumbnail if any from the data backend
+func RemoveFile(backend Backend, file *common.File) (err error) {
+	if file.Thumbnail {
+		err = backend.RemoveFile(file.ThumbnailFile())
+		if err != nil {
+			return err
+		}
+	}
+
+	return backend.RemoveFile(file)
+}
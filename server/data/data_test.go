@@ -0,0 +1,48 @@
+package data_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+// basicBackend only exposes the data.Backend methods
+type basicBackend struct {
+	data.Backend
+}
+
+func TestGetFileRange(t *testing.T) {
+	file := &common.File{ID: "file"}
+
+	for _, backend := range []data.Backend{data_test.NewBackend(), &basicBackend{data_test.NewBackend()}} {
+		err := backend.AddFile(file, bytes.NewBufferString("0123456789"))
+		require.NoError(t, err, "unable to add file")
+
+		reader, err := data.GetFileRange(backend, file, 2, 4)
+		require.NoError(t, err, "unable to get file range")
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to read file range")
+		require.Equal(t, "2345", string(content), "invalid file range content")
+		require.NoError(t, reader.Close(), "unable to close file range reader")
+
+		_, err = data.GetFileRange(backend, &common.File{ID: "missing"}, 0, 1)
+		require.Error(t, err, "able to get missing file range")
+	}
+}
+
+func TestGetFileRangeOutOfBounds(t *testing.T) {
+	backend := &basicBackend{data_test.NewBackend()}
+	file := &common.File{ID: "file"}
+
+	err := backend.AddFile(file, bytes.NewBufferString("0123456789"))
+	require.NoError(t, err, "unable to add file")
+
+	_, err = data.GetFileRange(backend, file, 42, 1)
+	require.Error(t, err, "able to get file range after the end of the file")
+}
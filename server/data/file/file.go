@@ -3,7 +3,11 @@ package file
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/root-gg/utils"
 
@@ -11,12 +15,22 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure File Data Backend implements data.Backend interface
+// Ensure File Data Backend implements data.Backend, data.Lister, data.AccelRedirecter, data.Sweeper and data.RangeGetter interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.Lister = (*Backend)(nil)
+var _ data.AccelRedirecter = (*Backend)(nil)
+var _ data.Sweeper = (*Backend)(nil)
+var _ data.RangeGetter = (*Backend)(nil)
+
+// TempFileMaxAge is how long a temporary file can stay untouched before being considered abandoned
+const TempFileMaxAge = time.Hour
 
 // Config describes configuration for File Databackend
 type Config struct {
-	Directory string
+	Directory      string
+	TempDir        string // Files are written here until fully uploaded, must be on the same filesystem as Directory ( default Directory/.tmp )
+	XAccelRedirect string // Internal nginx location serving Directory. Files are served by nginx if set
+	AllowSymlinks  bool   // Follow symbolic links of Directory pointing outside of it ( ex : to spread the files on several disks )
 }
 
 // NewConfig instantiate a new default configuration
@@ -59,9 +73,54 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return reader, nil
 }
 
+// GetFileRange implementation for file data backend will seek the file handle to the offset
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	_, path, err := b.getPathCompat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %s : %s", path, err)
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("unable to seek file %s : %s", path, err)
+	}
+
+	return data.NewLimitedReadCloser(f, length), nil
+}
+
+// GetAccelRedirect implementation for file data backend will return the file path
+// relative to the internal nginx location serving the data directory
+func (b *Backend) GetAccelRedirect(file *common.File) (location string, err error) {
+	if b.Config.XAccelRedirect == "" {
+		return "", nil
+	}
+
+	_, path, err := b.getPathCompat(file)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(b.Config.Directory, path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(b.Config.XAccelRedirect, "/") + "/" + filepath.ToSlash(rel), nil
+}
+
 // AddFile implementation for file data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
+	if file == nil || len(file.UploadID) < 3 {
+		return fmt.Errorf("file not initialized")
+	}
+
 	dir, path, err := b.getPath(file)
 	if err != nil {
 		return err
@@ -73,11 +132,28 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return fmt.Errorf("unable to create upload directory")
 	}
 
-	// Create file
-	out, err := os.Create(path)
+	err = b.checkPath(dir)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so incomplete uploads never land in the data directory
+	tempDir := b.getTempDir()
+	err = os.MkdirAll(tempDir, 0777)
+	if err != nil {
+		return fmt.Errorf("unable to create temporary directory %s : %s", tempDir, err)
+	}
+
+	out, err := ioutil.TempFile(tempDir, file.ID+"-")
 	if err != nil {
-		return fmt.Errorf("unable to create file %s : %s", path, err)
+		return fmt.Errorf("unable to create temporary file for %s : %s", path, err)
 	}
+	defer func() {
+		_ = out.Close()
+		if err != nil {
+			_ = os.Remove(out.Name())
+		}
+	}()
 
 	// Copy file data from the client request body
 	// to the file system
@@ -86,9 +162,71 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		return fmt.Errorf("unable to save file %s : %s", path, err)
 	}
 
+	// Temporary files are created readable by their owner only, data files must be readable by other processes ( nginx, backups, ... )
+	err = out.Chmod(0644)
+	if err != nil {
+		return fmt.Errorf("unable to set permissions of file %s : %s", path, err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("unable to save file %s : %s", path, err)
+	}
+
+	err = os.Rename(out.Name(), path)
+	if err != nil {
+		return fmt.Errorf("unable to save file %s : %s", path, err)
+	}
+
 	return nil
 }
 
+// SweepTempFiles implementation for file data backend will delete the temporary files
+// left behind by a crash or a killed process once they have not been written for TempFileMaxAge
+func (b *Backend) SweepTempFiles() (removed int, err error) {
+	tempDir := b.getTempDir()
+	infos, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unable to list temporary files in %s : %s", tempDir, err)
+	}
+
+	deadline := time.Now().Add(-TempFileMaxAge)
+	for _, info := range infos {
+		if info.IsDir() || info.ModTime().After(deadline) {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(tempDir, info.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("unable to remove temporary file %s : %s", info.Name(), err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// GetTempDir implementation for file data backend will return the directory of the incomplete uploads
+// so the temporary copies of the files stay on the data backend filesystem
+func (b *Backend) GetTempDir(file *common.File) (dir string, err error) {
+	dir = b.getTempDir()
+	err = os.MkdirAll(dir, 0777)
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary directory %s : %s", dir, err)
+	}
+	return dir, nil
+}
+
+func (b *Backend) getTempDir() string {
+	if b.Config.TempDir != "" {
+		return b.Config.TempDir
+	}
+	return filepath.Join(b.Config.Directory, ".tmp")
+}
+
 // RemoveFile implementation for file data backend will delete the given
 // file from filesystem
 func (b *Backend) RemoveFile(file *common.File) (err error) {
@@ -116,16 +254,66 @@ func (b *Backend) getPath(file *common.File) (dir string, path string, err error
 	// it gives 3844 possibilities reaching 65535 files per
 	// directory at ~250.000.000 files uploaded.
 
-	if file == nil || file.ID == "" || len(file.ID) < 3 || len(file.UploadID) < 3 {
+	if file == nil || file.ID == "" || len(file.ID) < 3 {
 		return "", "", fmt.Errorf("file not initialized")
 	}
 
+	err = checkID(file.ID)
+	if err != nil {
+		return "", "", err
+	}
+
 	dir = fmt.Sprintf("%s/%s", b.Config.Directory, file.ID[:2])
 	path = fmt.Sprintf("%s/%s", dir, file.ID)
 
+	if !isInDirectory(b.Config.Directory, path) {
+		return "", "", fmt.Errorf("invalid file id %s : outside of the data directory", file.ID)
+	}
+
 	return dir, path, nil
 }
 
+// checkID reject the IDs that could be used to build a path outside of the data directory
+func checkID(id string) error {
+	if id == "." || id == ".." || strings.ContainsAny(id, "/\\\x00") || filepath.IsAbs(id) {
+		return fmt.Errorf("invalid id %s", id)
+	}
+	return nil
+}
+
+// isInDirectory return true if path is lexically inside dir
+func isInDirectory(dir string, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkPath ensure an existing path is inside the data directory once symbolic links are resolved
+// so a link planted in the data directory can't be used to read, write or remove files elsewhere
+func (b *Backend) checkPath(path string) error {
+	if b.Config.AllowSymlinks {
+		return nil
+	}
+
+	root, err := filepath.EvalSymlinks(b.Config.Directory)
+	if err != nil {
+		return fmt.Errorf("unable to resolve data directory %s : %s", b.Config.Directory, err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %s : %s", path, err)
+	}
+
+	if !isInDirectory(root, resolved) {
+		return fmt.Errorf("%s is outside of the data directory", path)
+	}
+
+	return nil
+}
+
 var errNoSuchFileOrDirectory = fmt.Errorf("no such file or directory")
 
 func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err error) {
@@ -138,6 +326,10 @@ func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err
 
 	info, err := os.Stat(path)
 	if err == nil && !info.IsDir() {
+		err = b.checkPath(path)
+		if err != nil {
+			return "", "", err
+		}
 		return dir, path, nil
 	}
 	if !os.IsNotExist(err) {
@@ -146,14 +338,31 @@ func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err
 
 	// For compatibility with <1.3 implementations
 
+	if file == nil || len(file.UploadID) < 3 {
+		return "", "", errNoSuchFileOrDirectory
+	}
+
+	err = checkID(file.UploadID)
+	if err != nil {
+		return "", "", err
+	}
+
 	dir = fmt.Sprintf("%s/%s/%s", b.Config.Directory, file.UploadID[:2], file.UploadID)
 	path = fmt.Sprintf("%s/%s", dir, file.ID)
 
+	if !isInDirectory(b.Config.Directory, path) {
+		return "", "", fmt.Errorf("invalid upload id %s : outside of the data directory", file.UploadID)
+	}
+
 	info, err = os.Stat(path)
 	if err == nil {
 		if info.IsDir() {
 			return "", "", fmt.Errorf("file is a directory")
 		}
+		err = b.checkPath(path)
+		if err != nil {
+			return "", "", err
+		}
 		return dir, path, nil
 	}
 	if !os.IsNotExist(err) {
@@ -162,3 +371,43 @@ func (b *Backend) getPathCompat(file *common.File) (dir string, path string, err
 
 	return "", "", errNoSuchFileOrDirectory
 }
+
+// ForEachFile implementation for file data backend will walk the data directory
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	err = filepath.Walk(b.Config.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == b.Config.Directory {
+				// Nothing has been uploaded yet
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			// Incomplete uploads are not files of the data backend yet
+			if filepath.Clean(path) == filepath.Clean(b.getTempDir()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.Config.Directory, path)
+		if err != nil {
+			return err
+		}
+
+		file := &common.File{ID: info.Name(), CreatedAt: info.ModTime()}
+
+		// For compatibility with <1.3 implementations files are stored in an upload directory
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) == 3 {
+			file.UploadID = parts[1]
+		}
+
+		return f(file)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list files in %s : %s", b.Config.Directory, err)
+	}
+
+	return nil
+}
@@ -7,7 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -74,6 +76,54 @@ func TestAddFileInvalidReader(t *testing.T) {
 	require.Contains(t, err.Error(), "io error", "invalid error")
 }
 
+func TestAddFileInvalidReaderCleanup(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	reader := common.NewErrorReader(errors.New("io error"))
+	err := backend.AddFile(file, reader)
+	require.Error(t, err, "missing error")
+
+	_, path, err := backend.getPath(file)
+	require.NoError(t, err, "unable to get file path")
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "partial file should not exist")
+
+	infos, err := ioutil.ReadDir(backend.getTempDir())
+	require.NoError(t, err, "unable to list temporary files")
+	require.Len(t, infos, 0, "temporary file has not been removed")
+}
+
+func TestAddFileCustomTempDir(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	backend.Config.TempDir = backend.Config.Directory + "/custom"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	infos, err := ioutil.ReadDir(backend.Config.TempDir)
+	require.NoError(t, err, "temporary directory has not been created")
+	require.Len(t, infos, 0, "temporary file has not been renamed")
+
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	defer reader.Close()
+
+	read, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data", string(read), "invalid file content")
+}
+
 func TestAddFile(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -98,6 +148,25 @@ func TestAddFile(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestAddFileMode(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	_, path, err := backend.getPathCompat(file)
+	require.NoError(t, err, "unable to get file path")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err, "unable to stat file")
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm(), "invalid file mode")
+}
+
 func TestGetFileInvalidDirectory(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -146,6 +215,49 @@ func TestGetFile(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestGetFileRange(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("0123456789"))
+	require.NoError(t, err, "unable to add file")
+
+	// Parts of the file can be read concurrently
+	parts := make([]string, 5)
+	errs := make(chan error, len(parts))
+	for i := range parts {
+		go func(i int) {
+			fileReader, err := backend.GetFileRange(file, int64(i*2), 2)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer fileReader.Close()
+
+			read, err := ioutil.ReadAll(fileReader)
+			parts[i] = string(read)
+			errs <- err
+		}(i)
+	}
+	for range parts {
+		require.NoError(t, <-errs, "unable to read file range")
+	}
+	require.Equal(t, []string{"01", "23", "45", "67", "89"}, parts, "invalid file ranges content")
+
+	fileReader, err := backend.GetFileRange(file, 7, 42)
+	require.NoError(t, err, "unable to get file range")
+	read, err := ioutil.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "789", string(read), "invalid file range content")
+
+	_, err = backend.GetFileRange(upload.NewFile(), 0, 1)
+	require.Error(t, err, "able to get missing file range")
+}
+
 func TestGetFileCompathPath(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -178,6 +290,62 @@ func TestGetFileCompathPath(t *testing.T) {
 	require.Equal(t, "data", string(read), "inavlid file content")
 }
 
+func TestGetAccelRedirect(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	location, err := backend.GetAccelRedirect(file)
+	require.NoError(t, err, "unable to get accel redirect")
+	require.Empty(t, location, "accel redirect should be disabled")
+
+	backend.Config.XAccelRedirect = "/plik-files/"
+
+	location, err = backend.GetAccelRedirect(file)
+	require.NoError(t, err, "unable to get accel redirect")
+	require.Equal(t, fmt.Sprintf("/plik-files/%s/%s", file.ID[:2], file.ID), location, "invalid accel redirect")
+}
+
+func TestGetAccelRedirectCompatPath(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+	backend.Config.XAccelRedirect = "/plik-files"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	dir := fmt.Sprintf("%s/%s/%s", backend.Config.Directory, file.UploadID[:2], file.UploadID)
+	err := os.MkdirAll(dir, 0777)
+	require.NoError(t, err, "error creating directories")
+
+	err = ioutil.WriteFile(fmt.Sprintf("%s/%s", dir, file.ID), []byte("data"), 0644)
+	require.NoError(t, err, "error writing file")
+
+	location, err := backend.GetAccelRedirect(file)
+	require.NoError(t, err, "unable to get accel redirect")
+	require.Equal(t, fmt.Sprintf("/plik-files/%s/%s/%s", file.UploadID[:2], file.UploadID, file.ID), location, "invalid accel redirect")
+}
+
+func TestGetAccelRedirectMissingFile(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+	backend.Config.XAccelRedirect = "/plik-files"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	_, err := backend.GetAccelRedirect(file)
+	require.Error(t, err, "missing error")
+}
+
 func TestRemoveFileInvalidDirectory(t *testing.T) {
 	backend, clean := newBackend(t)
 	defer clean()
@@ -303,3 +471,234 @@ func TestRemoveFileCompatPath(t *testing.T) {
 	_, err = os.Open(path)
 	require.Error(t, err, "able to open removed file")
 }
+
+func TestForEachFile(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	// For compatibility with <1.3 implementations
+	compatFile := upload.NewFile()
+	compatFile.ID = "COMPATFILEXXXXXX"
+	dir := fmt.Sprintf("%s/%s/%s", backend.Config.Directory, file.UploadID[:2], file.UploadID)
+	err = os.MkdirAll(dir, 0777)
+	require.NoError(t, err, "error creating directories")
+	err = ioutil.WriteFile(fmt.Sprintf("%s/%s", dir, compatFile.ID), []byte("data"), 0644)
+	require.NoError(t, err, "error writing file")
+
+	files := make(map[string]*common.File)
+	err = backend.ForEachFile(func(f *common.File) error {
+		files[f.ID] = f
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Len(t, files, 2, "invalid file count")
+
+	require.NotNil(t, files[file.ID], "missing file")
+	require.Equal(t, "", files[file.ID].UploadID, "invalid upload id")
+	require.False(t, files[file.ID].CreatedAt.IsZero(), "missing modification date")
+
+	require.NotNil(t, files[compatFile.ID], "missing compat file")
+	require.Equal(t, file.UploadID, files[compatFile.ID].UploadID, "invalid upload id")
+
+	// Files can be removed without knowing their upload
+	err = backend.RemoveFile(files[file.ID])
+	require.NoError(t, err, "unable to remove file")
+
+	_, err = backend.GetFile(file)
+	require.Error(t, err, "file has not been removed")
+}
+
+func TestForEachFileEmptyDirectory(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	backend.Config.Directory = backend.Config.Directory + "/missing"
+
+	err := backend.ForEachFile(func(f *common.File) error {
+		return errors.New("unexpected file")
+	})
+	require.NoError(t, err, "unable to list files")
+}
+
+func TestForEachFileSkipTempDir(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	err := os.MkdirAll(backend.getTempDir(), 0777)
+	require.NoError(t, err, "unable to create temporary directory")
+	err = ioutil.WriteFile(backend.getTempDir()+"/FILEXXXXXXXXXXXX-123", []byte("data"), 0644)
+	require.NoError(t, err, "error writing file")
+
+	err = backend.ForEachFile(func(f *common.File) error {
+		return errors.New("unexpected file")
+	})
+	require.NoError(t, err, "unable to list files")
+}
+
+func TestSweepTempFiles(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	removed, err := backend.SweepTempFiles()
+	require.NoError(t, err, "unable to sweep missing temporary directory")
+	require.Equal(t, 0, removed, "invalid removed count")
+
+	err = os.MkdirAll(backend.getTempDir(), 0777)
+	require.NoError(t, err, "unable to create temporary directory")
+
+	stale := backend.getTempDir() + "/stale"
+	err = ioutil.WriteFile(stale, []byte("data"), 0644)
+	require.NoError(t, err, "error writing file")
+	old := time.Now().Add(-2 * TempFileMaxAge)
+	err = os.Chtimes(stale, old, old)
+	require.NoError(t, err, "unable to change modification date")
+
+	fresh := backend.getTempDir() + "/fresh"
+	err = ioutil.WriteFile(fresh, []byte("data"), 0644)
+	require.NoError(t, err, "error writing file")
+
+	removed, err = backend.SweepTempFiles()
+	require.NoError(t, err, "unable to sweep temporary files")
+	require.Equal(t, 1, removed, "invalid removed count")
+
+	_, err = os.Stat(stale)
+	require.True(t, os.IsNotExist(err), "stale temporary file has not been removed")
+	_, err = os.Stat(fresh)
+	require.NoError(t, err, "fresh temporary file has been removed")
+}
+
+func TestGetTempDir(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	dir, err := backend.GetTempDir(&common.File{})
+	require.NoError(t, err, "unable to get temporary directory")
+	require.Equal(t, backend.getTempDir(), dir, "invalid temporary directory")
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err, "temporary directory has not been created")
+	require.True(t, info.IsDir(), "temporary directory is not a directory")
+
+	backend.Config.TempDir = backend.Config.Directory + "/custom"
+	dir, err = backend.GetTempDir(&common.File{})
+	require.NoError(t, err, "unable to get temporary directory")
+	require.Equal(t, backend.Config.TempDir, dir, "invalid temporary directory")
+}
+
+func TestPathTraversal(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	// A file outside of the data directory that must never be read, written or removed
+	outside, err := ioutil.TempFile("", "pliktest-outside")
+	require.NoError(t, err, "unable to create outside file")
+	defer func() { _ = os.Remove(outside.Name()) }()
+	_, err = outside.WriteString("secret")
+	require.NoError(t, err, "unable to write outside file")
+	require.NoError(t, outside.Close(), "unable to close outside file")
+
+	ids := []string{
+		"../../" + filepath.Base(outside.Name()),
+		"..abc",
+		"../" + filepath.Base(outside.Name()),
+		outside.Name(),
+		"ab\\..\\..\\etc",
+		"ab\x00cd",
+	}
+
+	for _, id := range ids {
+		file := &common.File{ID: id, UploadID: "uploadID"}
+
+		_, err = backend.GetFile(file)
+		require.Error(t, err, "file id %s must be rejected", id)
+
+		_, err = backend.GetFileRange(file, 0, 1)
+		require.Error(t, err, "file id %s must be rejected", id)
+
+		err = backend.AddFile(file, bytes.NewBufferString("data"))
+		require.Error(t, err, "file id %s must be rejected", id)
+
+		err = backend.RemoveFile(file)
+		require.Error(t, err, "file id %s must be rejected", id)
+
+		// Compatibility paths are built from the upload ID
+		file = &common.File{ID: "fileID", UploadID: id}
+		_, err = backend.GetFile(file)
+		require.Error(t, err, "upload id %s must be rejected", id)
+	}
+
+	content, err := ioutil.ReadFile(outside.Name())
+	require.NoError(t, err, "the outside file must not be removed")
+	require.Equal(t, "secret", string(content), "the outside file must not be overwritten")
+}
+
+func TestPathTraversalSymlink(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	outside, err := ioutil.TempDir("", "pliktest-outside")
+	require.NoError(t, err, "unable to create outside directory")
+	defer func() { _ = os.RemoveAll(outside) }()
+
+	err = ioutil.WriteFile(filepath.Join(outside, "fileID"), []byte("secret"), 0600)
+	require.NoError(t, err, "unable to write outside file")
+
+	// A shard directory of the data directory linked to another directory
+	err = os.Symlink(outside, filepath.Join(backend.Config.Directory, "fi"))
+	require.NoError(t, err, "unable to create symlink")
+
+	file := &common.File{ID: "fileID", UploadID: "uploadID"}
+
+	_, err = backend.GetFile(file)
+	require.Error(t, err, "symlinks escaping the data directory must not be followed")
+	require.Contains(t, err.Error(), "is outside of the data directory", "invalid error message")
+
+	err = backend.AddFile(file, bytes.NewBufferString("data"))
+	require.Error(t, err, "symlinks escaping the data directory must not be followed")
+
+	err = backend.RemoveFile(file)
+	require.Error(t, err, "symlinks escaping the data directory must not be followed")
+
+	content, err := ioutil.ReadFile(filepath.Join(outside, "fileID"))
+	require.NoError(t, err, "the outside file must not be removed")
+	require.Equal(t, "secret", string(content), "the outside file must not be overwritten")
+
+	// Unless explicitly allowed
+	backend.Config.AllowSymlinks = true
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	read, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.NoError(t, reader.Close(), "unable to close file")
+	require.Equal(t, "secret", string(read), "invalid file content")
+}
+
+func TestPathSymlinkedDirectory(t *testing.T) {
+	backend, clean := newBackend(t)
+	defer clean()
+
+	// The data directory itself may be a symlink
+	link := backend.Config.Directory + "-link"
+	err := os.Symlink(backend.Config.Directory, link)
+	require.NoError(t, err, "unable to create symlink")
+	defer func() { _ = os.Remove(link) }()
+	backend.Config.Directory = link
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	upload.InitializeForTests()
+
+	err = backend.AddFile(file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	require.NoError(t, reader.Close(), "unable to close file")
+}
@@ -2,18 +2,27 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/root-gg/utils"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure File Data Backend implements data.Backend interface
+// Ensure GCS Data Backend implements data.Backend, data.Lister, data.ContextAdder, data.RangeGetter and data.RetryableErrorChecker interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.Lister = (*Backend)(nil)
+var _ data.ContextAdder = (*Backend)(nil)
+var _ data.RangeGetter = (*Backend)(nil)
+var _ data.RetryableErrorChecker = (*Backend)(nil)
 
 // Config describes configuration for Google Cloud Storage data backend
 type Config struct {
@@ -64,23 +73,60 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return reader, nil
 }
 
+// GetFileRange implementation for Google Cloud Storage Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	// Get object name
+	objectName := b.getObjectName(file.UploadID, file.ID)
+
+	// Get the part of the object
+	reader, err = b.client.Bucket(b.Config.Bucket).Object(objectName).NewRangeReader(context.Background(), offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get GCS object %s : %s", objectName, err)
+	}
+
+	return reader, nil
+}
+
 // AddFile implementation for Google Cloud Storage Data Backend
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
+	return b.AddFileWithContext(context.Background(), file, fileReader)
+}
+
+// AddFileWithContext implementation for Google Cloud Storage Data Backend
+func (b *Backend) AddFileWithContext(ctx context.Context, file *common.File, fileReader io.Reader) (err error) {
 	// Get object name
 	objectName := b.getObjectName(file.UploadID, file.ID)
 
-	// Get a writer
-	wc := b.client.Bucket(b.Config.Bucket).Object(objectName).NewWriter(context.Background())
-	defer wc.Close()
+	// Get a writer, canceling the context aborts the upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wc := b.client.Bucket(b.Config.Bucket).Object(objectName).NewWriter(ctx)
 
 	_, err = io.Copy(wc, fileReader)
 	if err != nil {
-		return fmt.Errorf("Unable to write GCS object %s : %s", objectName, err)
+		cancel()
+		_ = wc.Close()
+		return fmt.Errorf("Unable to write GCS object %s : %w", objectName, err)
+	}
+
+	// The object is only created once the writer is closed
+	err = wc.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to write GCS object %s : %w", objectName, err)
 	}
 
 	return nil
 }
 
+// IsRetryableError implementation for Google Cloud Storage Data Backend
+func (b *Backend) IsRetryableError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= http.StatusInternalServerError || apiErr.Code == http.StatusTooManyRequests || apiErr.Code == http.StatusRequestTimeout
+	}
+	return false
+}
+
 // RemoveFile implementation for Google Cloud Storage Data Backend
 func (b *Backend) RemoveFile(file *common.File) (err error) {
 	// Get object name
@@ -100,6 +146,37 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for Google Cloud Storage Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	query := &storage.Query{}
+	if b.Config.Folder != "" {
+		query.Prefix = b.Config.Folder + "/"
+	}
+
+	it := b.client.Bucket(b.Config.Bucket).Objects(context.Background(), query)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to list GCS objects : %s", err)
+		}
+
+		// Object names are "uploadID.fileID"
+		name := strings.TrimPrefix(attrs.Name, query.Prefix)
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			continue
+		}
+
+		err = f(&common.File{UploadID: name[:i], ID: name[i+1:], CreatedAt: attrs.Updated})
+		if err != nil {
+			return err
+		}
+	}
+}
+
 func (b *Backend) getObjectName(uploadID string, fileID string) string {
 	if b.Config.Folder != "" {
 		return fmt.Sprintf("%s/%s.%s", b.Config.Folder, uploadID, fileID)
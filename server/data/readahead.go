@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Ensure ReadAheadBackend implements data.Backend, data.Lister, data.AccelRedirecter, data.Sweeper, data.StorageClassTransitioner, data.RangeGetter,
+// data.ContextAdder and data.RetryableErrorChecker interfaces
+var _ Backend = (*ReadAheadBackend)(nil)
+var _ Lister = (*ReadAheadBackend)(nil)
+var _ AccelRedirecter = (*ReadAheadBackend)(nil)
+var _ Sweeper = (*ReadAheadBackend)(nil)
+var _ StorageClassTransitioner = (*ReadAheadBackend)(nil)
+var _ RangeGetter = (*ReadAheadBackend)(nil)
+var _ ContextAdder = (*ReadAheadBackend)(nil)
+var _ RetryableErrorChecker = (*ReadAheadBackend)(nil)
+
+// ReadAheadBackend prefetch the files read from the data backend ahead of the client consumption.
+// Each file reader buffers at most bufferSize bytes so the data backend latency is hidden from slow clients
+// without holding whole files in memory.
+type ReadAheadBackend struct {
+	backend    Backend
+	bufferSize int
+}
+
+// NewReadAheadBackend instantiate a new ReadAheadBackend
+func NewReadAheadBackend(backend Backend, bufferSize int) (b *ReadAheadBackend) {
+	b = new(ReadAheadBackend)
+	b.backend = backend
+	b.bufferSize = bufferSize
+	return b
+}
+
+// AddFile add the file to the data backend
+func (b *ReadAheadBackend) AddFile(file *common.File, reader io.Reader) (err error) {
+	return b.backend.AddFile(file, reader)
+}
+
+// AddFileWithContext add the file to the data backend, the write is aborted once the context is canceled
+// if the data backend supports it
+func (b *ReadAheadBackend) AddFileWithContext(ctx context.Context, file *common.File, reader io.Reader) (err error) {
+	if adder, ok := b.backend.(ContextAdder); ok {
+		return adder.AddFileWithContext(ctx, file, reader)
+	}
+	return b.backend.AddFile(file, reader)
+}
+
+// IsRetryableError return true if the data backend error is transient
+func (b *ReadAheadBackend) IsRetryableError(err error) bool {
+	return isTransientError(b.backend, err)
+}
+
+// GetFile get the file from the data backend and start prefetching it
+func (b *ReadAheadBackend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	reader, err = b.backend.GetFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewReadAheadReader(reader, b.bufferSize), nil
+}
+
+// GetFileRange get a part of the file from the data backend and start prefetching it
+func (b *ReadAheadBackend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	reader, err = GetFileRange(b.backend, file, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return NewReadAheadReader(reader, b.bufferSize), nil
+}
+
+// RemoveFile remove the file from the data backend
+func (b *ReadAheadBackend) RemoveFile(file *common.File) (err error) {
+	return b.backend.RemoveFile(file)
+}
+
+// ForEachFile execute f for every file of the data backend if it supports listing files
+func (b *ReadAheadBackend) ForEachFile(f func(file *common.File) error) (err error) {
+	lister, ok := b.backend.(Lister)
+	if !ok {
+		return fmt.Errorf("data backend does not support listing files")
+	}
+	return lister.ForEachFile(f)
+}
+
+// GetAccelRedirect return the internal redirect location of the file if the data backend supports it
+func (b *ReadAheadBackend) GetAccelRedirect(file *common.File) (location string, err error) {
+	if redirecter, ok := b.backend.(AccelRedirecter); ok {
+		return redirecter.GetAccelRedirect(file)
+	}
+	return "", nil
+}
+
+// SweepTempFiles delete the abandoned temporary files of the data backend if it supports it
+func (b *ReadAheadBackend) SweepTempFiles() (removed int, err error) {
+	if sweeper, ok := b.backend.(Sweeper); ok {
+		return sweeper.SweepTempFiles()
+	}
+	return 0, nil
+}
+
+// GetTempDir return the temporary directory of the data backend if it supports it
+func (b *ReadAheadBackend) GetTempDir(file *common.File) (dir string, err error) {
+	return GetTempDir(b.backend, file)
+}
+
+// GetStorageClassTransitionAge return the age after which files are transitioned if the data backend supports it
+func (b *ReadAheadBackend) GetStorageClassTransitionAge() time.Duration {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.GetStorageClassTransitionAge()
+	}
+	return 0
+}
+
+// TransitionStorageClass move the file to a cheaper storage class if the data backend supports it
+func (b *ReadAheadBackend) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.TransitionStorageClass(file)
+	}
+	return false, nil
+}
+
+// readAheadReader read the source in a background goroutine into a ring buffer
+type readAheadReader struct {
+	source io.ReadCloser
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buffer []byte
+	start  int   // Offset of the first buffered byte
+	length int   // Number of buffered bytes
+	err    error // Error returned by the source ( io.EOF once fully read )
+	closed bool
+}
+
+// NewReadAheadReader return a reader prefetching at most bufferSize bytes from the source.
+// The source is read until the end of the file or until the reader is closed.
+func NewReadAheadReader(source io.ReadCloser, bufferSize int) io.ReadCloser {
+	if bufferSize <= 0 {
+		return source
+	}
+
+	r := &readAheadReader{source: source, buffer: make([]byte, bufferSize)}
+	r.cond = sync.NewCond(&r.mu)
+	go r.fill()
+
+	return r
+}
+
+func (r *readAheadReader) fill() {
+	for {
+		r.mu.Lock()
+		for r.length == len(r.buffer) && !r.closed {
+			r.cond.Wait()
+		}
+		if r.closed {
+			r.mu.Unlock()
+			return
+		}
+
+		// Free space after the buffered data, only the reader goroutine touches it
+		var free []byte
+		end := r.start + r.length
+		if end >= len(r.buffer) {
+			free = r.buffer[end-len(r.buffer) : r.start]
+		} else {
+			free = r.buffer[end:]
+		}
+		r.mu.Unlock()
+
+		n, err := r.source.Read(free)
+
+		r.mu.Lock()
+		r.length += n
+		if err != nil {
+			r.err = err
+		}
+		r.cond.Broadcast()
+		r.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read the prefetched data, it blocks only if the buffer is empty
+func (r *readAheadReader) Read(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for r.length == 0 && r.err == nil && !r.closed {
+		r.cond.Wait()
+	}
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if r.length == 0 {
+		return 0, r.err
+	}
+
+	end := r.start + r.length
+	if end > len(r.buffer) {
+		end = len(r.buffer)
+	}
+	n = copy(p, r.buffer[r.start:end])
+
+	r.start = (r.start + n) % len(r.buffer)
+	r.length -= n
+	r.cond.Broadcast()
+
+	return n, nil
+}
+
+// Close stop prefetching and close the source
+func (r *readAheadReader) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+
+	return r.source.Close()
+}
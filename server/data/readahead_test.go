@@ -0,0 +1,107 @@
+package data_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+// countingReadCloser count the bytes read from the source
+type countingReadCloser struct {
+	io.Reader
+	read   chan int
+	closed bool
+}
+
+func (r *countingReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.read <- n
+	return n, err
+}
+
+func (r *countingReadCloser) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestReadAheadBackend(t *testing.T) {
+	backend := data.NewReadAheadBackend(data_test.NewBackend(), 4)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+
+	err := backend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, "data data data", string(content), "invalid file content")
+	require.NoError(t, reader.Close(), "unable to close reader")
+
+	reader, err = backend.GetFileRange(file, 5, 6)
+	require.NoError(t, err, "unable to get file range")
+	content, err = ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "data d", string(content), "invalid file range content")
+	require.NoError(t, reader.Close(), "unable to close reader")
+
+	err = backend.RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+
+	_, err = backend.GetFile(file)
+	common.RequireError(t, err, "file not found")
+}
+
+func TestReadAheadReaderBounded(t *testing.T) {
+	source := &countingReadCloser{Reader: strings.NewReader(strings.Repeat("x", 100)), read: make(chan int, 100)}
+	reader := data.NewReadAheadReader(source, 10)
+
+	// The source is read ahead until the buffer is full
+	prefetched := 0
+	for prefetched < 10 {
+		select {
+		case n := <-source.read:
+			prefetched += n
+		case <-time.After(time.Second):
+			t.Fatalf("source has not been prefetched")
+		}
+	}
+
+	select {
+	case <-source.read:
+		t.Fatalf("source read beyond the buffer size")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	buf := make([]byte, 4)
+	n, err := io.ReadFull(reader, buf)
+	require.NoError(t, err, "unable to read")
+	require.Equal(t, 4, n, "invalid read size")
+
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read")
+	require.Len(t, content, 96, "invalid content size")
+
+	err = reader.Close()
+	require.NoError(t, err, "unable to close reader")
+	require.True(t, source.closed, "source should be closed")
+
+	_, err = reader.Read(buf)
+	require.Equal(t, io.ErrClosedPipe, err, "invalid read after close error")
+}
+
+func TestReadAheadReaderDisabled(t *testing.T) {
+	source := ioutil.NopCloser(strings.NewReader("data"))
+	require.Equal(t, source, data.NewReadAheadReader(source, 0), "reader should not be wrapped")
+}
@@ -0,0 +1,315 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Ensure RetryBackend implements data.Backend, data.Lister, data.AccelRedirecter, data.Sweeper, data.StorageClassTransitioner and data.RangeGetter interfaces
+var _ Backend = (*RetryBackend)(nil)
+var _ Lister = (*RetryBackend)(nil)
+var _ AccelRedirecter = (*RetryBackend)(nil)
+var _ Sweeper = (*RetryBackend)(nil)
+var _ StorageClassTransitioner = (*RetryBackend)(nil)
+var _ RangeGetter = (*RetryBackend)(nil)
+
+// ErrWriteTimeout is returned when a data backend write attempt lasts longer than the write timeout
+var ErrWriteTimeout = errors.New("data backend write timeout")
+
+// RetryableErrorChecker interface describes data backends able to tell transient errors from permanent ones.
+type RetryableErrorChecker interface {
+	// IsRetryableError return true if writing the file again may succeed ( server errors, throttling, ... )
+	IsRetryableError(err error) bool
+}
+
+// RetryBackend retry failed writes of a data backend with an exponential backoff.
+// The file data is spooled to a temporary file while it is written so it can be sent again.
+type RetryBackend struct {
+	backend      Backend
+	timeout      time.Duration
+	maxRetries   int
+	backoff      time.Duration
+	spoolDirPath string
+}
+
+// NewRetryBackend instantiate a new RetryBackend
+//   - timeout : maximum duration of a write attempt ( 0 : no timeout )
+//   - maxRetries : maximum number of retries of a failed write ( 0 : no retry )
+func NewRetryBackend(backend Backend, timeout time.Duration, maxRetries int) (b *RetryBackend) {
+	b = new(RetryBackend)
+	b.backend = backend
+	b.timeout = timeout
+	b.maxRetries = maxRetries
+	b.backoff = time.Second
+	return b
+}
+
+// WithBackoff set the delay before the first retry, it is doubled after each retry
+func (b *RetryBackend) WithBackoff(backoff time.Duration) *RetryBackend {
+	b.backoff = backoff
+	return b
+}
+
+// WithSpoolDirectory set the directory of the temporary files ( default : os.TempDir() )
+func (b *RetryBackend) WithSpoolDirectory(path string) *RetryBackend {
+	b.spoolDirPath = path
+	return b
+}
+
+// AddFile add the file to the data backend, transient errors are retried
+func (b *RetryBackend) AddFile(file *common.File, reader io.Reader) (err error) {
+	if b.maxRetries <= 0 {
+		return b.addFile(file, reader)
+	}
+
+	spool, err := newSpoolReader(b.spoolDirPath, reader)
+	if err != nil {
+		return err
+	}
+	defer spool.close()
+
+	reader = spool
+	backoff := b.backoff
+	for retry := 0; ; retry++ {
+		err = b.addFile(file, reader)
+		if err == nil {
+			return nil
+		}
+
+		// Errors reading the uploaded data can't be fixed by writing it again
+		if spool.err != nil || retry >= b.maxRetries || !isTransientError(b.backend, err) {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+
+		reader = spool.replay()
+	}
+}
+
+// addFile add the file to the data backend and cancel the write once the data backend has spent more than the
+// write timeout on it. The time spent waiting for the uploaded data is not counted.
+func (b *RetryBackend) addFile(file *common.File, reader io.Reader) (err error) {
+	if b.timeout <= 0 {
+		return b.backend.AddFile(file, reader)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchdog := newWriteWatchdog(reader, b.timeout, cancel)
+	defer watchdog.stop()
+
+	if adder, ok := b.backend.(ContextAdder); ok {
+		err = adder.AddFileWithContext(ctx, file, watchdog)
+	} else {
+		err = b.backend.AddFile(file, watchdog)
+	}
+
+	if err != nil && watchdog.isExpired() {
+		return ErrWriteTimeout
+	}
+	return err
+}
+
+// isTransientError return true if the operation may succeed later ( timeouts, server errors, throttling, ... )
+func isTransientError(backend Backend, err error) bool {
+	if err == ErrWriteTimeout {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if checker, ok := backend.(RetryableErrorChecker); ok {
+		return checker.IsRetryableError(err)
+	}
+
+	return false
+}
+
+// IsRetryableError return true if the data backend error is transient
+func (b *RetryBackend) IsRetryableError(err error) bool {
+	return isTransientError(b.backend, err)
+}
+
+// GetFile get the file from the data backend
+func (b *RetryBackend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	return b.backend.GetFile(file)
+}
+
+// GetFileRange get a part of the file from the data backend
+func (b *RetryBackend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	return GetFileRange(b.backend, file, offset, length)
+}
+
+// RemoveFile remove the file from the data backend
+func (b *RetryBackend) RemoveFile(file *common.File) (err error) {
+	return b.backend.RemoveFile(file)
+}
+
+// ForEachFile execute f for every file of the data backend if it supports listing files
+func (b *RetryBackend) ForEachFile(f func(file *common.File) error) (err error) {
+	lister, ok := b.backend.(Lister)
+	if !ok {
+		return fmt.Errorf("data backend does not support listing files")
+	}
+	return lister.ForEachFile(f)
+}
+
+// GetAccelRedirect return the internal location of the file if the data backend supports it
+func (b *RetryBackend) GetAccelRedirect(file *common.File) (location string, err error) {
+	if redirecter, ok := b.backend.(AccelRedirecter); ok {
+		return redirecter.GetAccelRedirect(file)
+	}
+	return "", nil
+}
+
+// SweepTempFiles delete the abandoned temporary files of the data backend if it supports it
+func (b *RetryBackend) SweepTempFiles() (removed int, err error) {
+	if sweeper, ok := b.backend.(Sweeper); ok {
+		return sweeper.SweepTempFiles()
+	}
+	return 0, nil
+}
+
+// GetTempDir return the temporary directory of the data backend if it supports it
+func (b *RetryBackend) GetTempDir(file *common.File) (dir string, err error) {
+	return GetTempDir(b.backend, file)
+}
+
+// GetStorageClassTransitionAge return the age after which files are transitioned if the data backend supports it
+func (b *RetryBackend) GetStorageClassTransitionAge() time.Duration {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.GetStorageClassTransitionAge()
+	}
+	return 0
+}
+
+// TransitionStorageClass move the file to a cheaper storage class if the data backend supports it
+func (b *RetryBackend) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	if transitioner, ok := b.backend.(StorageClassTransitioner); ok {
+		return transitioner.TransitionStorageClass(file)
+	}
+	return false, nil
+}
+
+// writeWatchdog measure the time spent by the data backend between the reads of the uploaded data
+// and cancel the write once it exceeds the timeout
+type writeWatchdog struct {
+	reader io.Reader
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	budget  time.Duration // Remaining time the data backend may spend
+	since   time.Time     // Time the data backend got the data back
+	timer   *time.Timer
+	expired bool
+}
+
+func newWriteWatchdog(reader io.Reader, timeout time.Duration, cancel context.CancelFunc) (w *writeWatchdog) {
+	w = &writeWatchdog{reader: reader, cancel: cancel, budget: timeout, since: time.Now()}
+	w.timer = time.AfterFunc(timeout, w.expire)
+	return w
+}
+
+func (w *writeWatchdog) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expired = true
+	w.cancel()
+}
+
+func (w *writeWatchdog) isExpired() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.expired
+}
+
+func (w *writeWatchdog) stop() {
+	w.timer.Stop()
+}
+
+// Read pause the watchdog while reading the uploaded data
+func (w *writeWatchdog) Read(p []byte) (n int, err error) {
+	w.mu.Lock()
+	w.timer.Stop()
+	w.budget -= time.Since(w.since)
+	if w.expired || w.budget <= 0 {
+		w.expired = true
+		w.mu.Unlock()
+		return 0, ErrWriteTimeout
+	}
+	w.mu.Unlock()
+
+	n, err = w.reader.Read(p)
+
+	w.mu.Lock()
+	w.since = time.Now()
+	if !w.expired {
+		w.timer.Reset(w.budget)
+	}
+	w.mu.Unlock()
+
+	return n, err
+}
+
+// spoolReader copy the data read from the source to a temporary file so it can be read again from the beginning
+type spoolReader struct {
+	source  io.Reader
+	spool   *os.File
+	spooled int64
+	err     error // Error reading the source
+}
+
+func newSpoolReader(dir string, source io.Reader) (r *spoolReader, err error) {
+	spool, err := ioutil.TempFile(dir, "plik-spool-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create spool file : %s", err)
+	}
+
+	// The file is only used through the open file handle
+	_ = os.Remove(spool.Name())
+
+	return &spoolReader{source: source, spool: spool}, nil
+}
+
+// Read the data from the source and spool it
+func (r *spoolReader) Read(p []byte) (n int, err error) {
+	n, err = r.source.Read(p)
+	if n > 0 {
+		_, werr := r.spool.WriteAt(p[:n], r.spooled)
+		if werr != nil {
+			r.err = fmt.Errorf("unable to write spool file : %s", werr)
+			return n, r.err
+		}
+		r.spooled += int64(n)
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// replay return a reader of the spooled data followed by the rest of the source
+func (r *spoolReader) replay() io.Reader {
+	return io.MultiReader(io.NewSectionReader(r.spool, 0, r.spooled), r)
+}
+
+func (r *spoolReader) close() {
+	_ = r.spool.Close()
+}
@@ -0,0 +1,179 @@
+package data_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+var errTransient = errors.New("transient error")
+var errPermanent = errors.New("permanent error")
+
+// flakyBackend fail the first writes after reading some data
+type flakyBackend struct {
+	*data_test.Backend
+	failures int
+	err      error
+	attempts int
+	delay    time.Duration // Time spent by the backend between reads
+}
+
+func newFlakyBackend(failures int, err error) *flakyBackend {
+	return &flakyBackend{Backend: data_test.NewBackend(), failures: failures, err: err}
+}
+
+func (b *flakyBackend) AddFile(file *common.File, reader io.Reader) (err error) {
+	b.attempts++
+
+	if b.attempts <= b.failures {
+		_, err = io.ReadFull(reader, make([]byte, 4))
+		if err != nil {
+			return err
+		}
+		return b.err
+	}
+
+	if b.delay > 0 {
+		buf := make([]byte, 1)
+		for {
+			time.Sleep(b.delay)
+			_, err = reader.Read(buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return b.Backend.AddFile(file, reader)
+}
+
+func (b *flakyBackend) IsRetryableError(err error) bool {
+	return err == errTransient
+}
+
+// contextBackend block until the write is canceled
+type contextBackend struct {
+	*data_test.Backend
+}
+
+func (b *contextBackend) AddFileWithContext(ctx context.Context, file *common.File, reader io.Reader) (err error) {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// slowReader wait before each read
+type slowReader struct {
+	reader io.Reader
+	delay  time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (n int, err error) {
+	time.Sleep(r.delay)
+	return r.reader.Read(p[:1])
+}
+
+func getContent(t *testing.T, backend data.Backend, file *common.File) string {
+	reader, err := backend.GetFile(file)
+	require.NoError(t, err, "unable to get file")
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	return string(content)
+}
+
+func TestRetryBackend(t *testing.T) {
+	backend := newFlakyBackend(2, errTransient)
+	retryBackend := data.NewRetryBackend(backend, 0, 3).WithBackoff(time.Millisecond)
+
+	file := &common.File{ID: "file"}
+	err := retryBackend.AddFile(file, bytes.NewBufferString("data data data"))
+	require.NoError(t, err, "unable to add file")
+	require.Equal(t, 3, backend.attempts, "invalid number of attempts")
+	require.Equal(t, "data data data", getContent(t, retryBackend, file), "invalid file content")
+
+	err = retryBackend.RemoveFile(file)
+	require.NoError(t, err, "unable to remove file")
+	require.NotContains(t, backend.GetFiles(), file.ID)
+}
+
+func TestRetryBackendMaxRetries(t *testing.T) {
+	backend := newFlakyBackend(10, errTransient)
+	retryBackend := data.NewRetryBackend(backend, 0, 3).WithBackoff(time.Millisecond)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errTransient, err, "invalid error")
+	require.Equal(t, 4, backend.attempts, "invalid number of attempts")
+}
+
+func TestRetryBackendPermanentError(t *testing.T) {
+	backend := newFlakyBackend(10, errPermanent)
+	retryBackend := data.NewRetryBackend(backend, 0, 3).WithBackoff(time.Millisecond)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data data data"))
+	require.Equal(t, errPermanent, err, "invalid error")
+	require.Equal(t, 1, backend.attempts, "permanent errors should not be retried")
+}
+
+func TestRetryBackendSourceError(t *testing.T) {
+	backend := newFlakyBackend(10, errTransient)
+	retryBackend := data.NewRetryBackend(backend, 0, 3).WithBackoff(time.Millisecond)
+
+	reader, writer := io.Pipe()
+	_ = writer.CloseWithError(common.ErrTransferAborted)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, reader)
+	require.Equal(t, common.ErrTransferAborted, err, "invalid error")
+	require.Equal(t, 1, backend.attempts, "errors reading the uploaded data should not be retried")
+}
+
+func TestRetryBackendWriteTimeout(t *testing.T) {
+	backend := newFlakyBackend(0, nil)
+	backend.delay = 50 * time.Millisecond
+	retryBackend := data.NewRetryBackend(backend, 20*time.Millisecond, 0)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data"))
+	require.Equal(t, data.ErrWriteTimeout, err, "invalid error")
+}
+
+func TestRetryBackendWriteTimeoutSlowClient(t *testing.T) {
+	backend := newFlakyBackend(0, nil)
+	retryBackend := data.NewRetryBackend(backend, 20*time.Millisecond, 0)
+
+	// The time spent waiting for the uploaded data does not count
+	file := &common.File{ID: "file"}
+	err := retryBackend.AddFile(file, &slowReader{reader: bytes.NewBufferString("data"), delay: 10 * time.Millisecond})
+	require.NoError(t, err, "unable to add file")
+	require.Equal(t, "data", getContent(t, retryBackend, file), "invalid file content")
+}
+
+func TestRetryBackendWriteTimeoutContext(t *testing.T) {
+	retryBackend := data.NewRetryBackend(&contextBackend{Backend: data_test.NewBackend()}, 20*time.Millisecond, 0)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data"))
+	require.Equal(t, data.ErrWriteTimeout, err, "invalid error")
+}
+
+func TestRetryBackendForEachFile(t *testing.T) {
+	backend := data_test.NewBackend()
+	retryBackend := data.NewRetryBackend(backend, 0, 3)
+
+	err := retryBackend.AddFile(&common.File{ID: "file"}, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to add file")
+
+	var files []string
+	err = retryBackend.ForEachFile(func(file *common.File) error {
+		files = append(files, file.ID)
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Equal(t, []string{"file"}, files, "invalid files")
+}
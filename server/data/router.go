@@ -0,0 +1,173 @@
+package data
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// Ensure Router implements data.Backend, data.Lister, data.AccelRedirecter, data.Sweeper, data.StorageClassTransitioner and data.RangeGetter interfaces
+var _ Backend = (*Router)(nil)
+var _ Lister = (*Router)(nil)
+var _ AccelRedirecter = (*Router)(nil)
+var _ Sweeper = (*Router)(nil)
+var _ StorageClassTransitioner = (*Router)(nil)
+var _ RangeGetter = (*Router)(nil)
+
+// Router dispatch files to named data backends using the File.DataBackend field.
+// Files without data backend name are stored in the default data backend.
+type Router struct {
+	backends map[string]Backend
+}
+
+// NewRouter instantiate a new data backend Router
+func NewRouter(defaultBackend Backend) (router *Router) {
+	router = new(Router)
+	router.backends = make(map[string]Backend)
+	router.backends[""] = defaultBackend
+	return router
+}
+
+// Register a named data backend
+func (router *Router) Register(name string, backend Backend) *Router {
+	router.backends[name] = backend
+	return router
+}
+
+// GetBackend return the data backend storing the file
+func (router *Router) GetBackend(file *common.File) (backend Backend, err error) {
+	backend, ok := router.backends[file.DataBackend]
+	if !ok {
+		return nil, fmt.Errorf("unknown data backend %s", file.DataBackend)
+	}
+	return backend, nil
+}
+
+// AddFile add the file to its data backend
+func (router *Router) AddFile(file *common.File, reader io.Reader) (err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return err
+	}
+	return backend.AddFile(file, reader)
+}
+
+// GetFile get the file from its data backend
+func (router *Router) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return nil, err
+	}
+	return backend.GetFile(file)
+}
+
+// GetFileRange get a part of the file from its data backend
+func (router *Router) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return nil, err
+	}
+	return GetFileRange(backend, file, offset, length)
+}
+
+// RemoveFile remove the file from its data backend
+func (router *Router) RemoveFile(file *common.File) (err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return err
+	}
+	return backend.RemoveFile(file)
+}
+
+// ForEachFile execute f for every file of every data backend, all of them must implement the Lister interface
+func (router *Router) ForEachFile(f func(file *common.File) error) (err error) {
+	var names []string
+	for name := range router.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		lister, ok := router.backends[name].(Lister)
+		if !ok {
+			return fmt.Errorf("data backend %s does not support listing files", name)
+		}
+
+		err = lister.ForEachFile(func(file *common.File) error {
+			file.DataBackend = name
+			return f(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAccelRedirect return the internal location of the file if its data backend supports it
+func (router *Router) GetAccelRedirect(file *common.File) (location string, err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return "", err
+	}
+
+	if redirecter, ok := backend.(AccelRedirecter); ok {
+		return redirecter.GetAccelRedirect(file)
+	}
+
+	return "", nil
+}
+
+// SweepTempFiles delete the abandoned temporary files of every data backend supporting it
+func (router *Router) SweepTempFiles() (removed int, err error) {
+	for name, backend := range router.backends {
+		if sweeper, ok := backend.(Sweeper); ok {
+			n, err := sweeper.SweepTempFiles()
+			removed += n
+			if err != nil {
+				return removed, fmt.Errorf("unable to sweep data backend %s : %s", name, err)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// GetTempDir return the temporary directory of the data backend storing the file if it supports it
+func (router *Router) GetTempDir(file *common.File) (dir string, err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return "", err
+	}
+	return GetTempDir(backend, file)
+}
+
+// GetStorageClassTransitionAge return the shortest age after which the files of a data backend are transitioned,
+// 0 if no data backend transitions its files
+func (router *Router) GetStorageClassTransitionAge() (age time.Duration) {
+	for _, backend := range router.backends {
+		if transitioner, ok := backend.(StorageClassTransitioner); ok {
+			backendAge := transitioner.GetStorageClassTransitionAge()
+			if backendAge > 0 && (age == 0 || backendAge < age) {
+				age = backendAge
+			}
+		}
+	}
+	return age
+}
+
+// TransitionStorageClass move the file to a cheaper storage class if its data backend supports it
+func (router *Router) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	backend, err := router.GetBackend(file)
+	if err != nil {
+		return false, err
+	}
+	if transitioner, ok := backend.(StorageClassTransitioner); ok {
+		return transitioner.TransitionStorageClass(file)
+	}
+	return false, nil
+}
@@ -0,0 +1,107 @@
+package data_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func TestRouter(t *testing.T) {
+	defaultBackend := data_test.NewBackend()
+	archiveBackend := data_test.NewBackend()
+	router := data.NewRouter(defaultBackend).Register("archive", archiveBackend)
+
+	file := &common.File{ID: "file"}
+	archived := &common.File{ID: "archived", DataBackend: "archive"}
+
+	for _, f := range []*common.File{file, archived} {
+		err := router.AddFile(f, bytes.NewBufferString(f.ID))
+		require.NoError(t, err, "unable to add file")
+	}
+
+	require.Contains(t, defaultBackend.GetFiles(), file.ID)
+	require.NotContains(t, defaultBackend.GetFiles(), archived.ID)
+	require.Contains(t, archiveBackend.GetFiles(), archived.ID)
+	require.NotContains(t, archiveBackend.GetFiles(), file.ID)
+
+	reader, err := router.GetFile(archived)
+	require.NoError(t, err, "unable to get file")
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file")
+	require.Equal(t, archived.ID, string(content), "invalid file content")
+
+	reader, err = router.GetFileRange(archived, 2, 42)
+	require.NoError(t, err, "unable to get file range")
+	content, err = ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "chived", string(content), "invalid file range content")
+
+	files := make(map[string]string)
+	err = router.ForEachFile(func(f *common.File) error {
+		files[f.ID] = f.DataBackend
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Equal(t, map[string]string{file.ID: "", archived.ID: "archive"}, files, "invalid files")
+
+	err = router.RemoveFile(archived)
+	require.NoError(t, err, "unable to remove file")
+	require.NotContains(t, archiveBackend.GetFiles(), archived.ID)
+	require.Contains(t, defaultBackend.GetFiles(), file.ID)
+
+	location, err := router.GetAccelRedirect(file)
+	require.NoError(t, err, "unable to get accel redirect")
+	require.Equal(t, "", location, "invalid accel redirect")
+}
+
+func TestRouterStorageClassTransition(t *testing.T) {
+	defaultBackend := data_test.NewBackend()
+	archiveBackend := data_test.NewBackend()
+	coldBackend := data_test.NewBackend()
+	router := data.NewRouter(defaultBackend).Register("archive", archiveBackend).Register("cold", coldBackend)
+
+	require.Equal(t, time.Duration(0), router.GetStorageClassTransitionAge(), "transitions should be disabled")
+
+	archiveBackend.SetStorageClassTransitionAge(48 * time.Hour)
+	coldBackend.SetStorageClassTransitionAge(24 * time.Hour)
+	require.Equal(t, 24*time.Hour, router.GetStorageClassTransitionAge(), "invalid transition age")
+
+	file := &common.File{ID: "file", CreatedAt: time.Now().Add(-36 * time.Hour)}
+	archived := &common.File{ID: "archived", DataBackend: "archive", CreatedAt: file.CreatedAt}
+	cold := &common.File{ID: "cold", DataBackend: "cold", CreatedAt: file.CreatedAt}
+
+	for f, expected := range map[*common.File]bool{file: false, archived: false, cold: true} {
+		transitioned, err := router.TransitionStorageClass(f)
+		require.NoError(t, err, "unable to transition file")
+		require.Equal(t, expected, transitioned, "invalid transition of file %s", f.ID)
+	}
+}
+
+func TestRouterUnknownBackend(t *testing.T) {
+	router := data.NewRouter(data_test.NewBackend())
+	file := &common.File{ID: "file", DataBackend: "foo"}
+
+	err := router.AddFile(file, bytes.NewBufferString("data"))
+	common.RequireError(t, err, "unknown data backend foo")
+
+	_, err = router.GetFile(file)
+	common.RequireError(t, err, "unknown data backend foo")
+
+	err = router.RemoveFile(file)
+	common.RequireError(t, err, "unknown data backend foo")
+
+	_, err = router.GetTempDir(file)
+	common.RequireError(t, err, "unknown data backend foo")
+
+	// The testing data backend has no temporary directory
+	dir, err := data.GetTempDir(router, &common.File{ID: "file"})
+	require.NoError(t, err, "unable to get temporary directory")
+	require.Equal(t, "", dir, "invalid temporary directory")
+}
@@ -4,6 +4,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -13,20 +18,45 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Swift Data Backend implements data.Backend interface
+// Ensure S3 Data Backend implements data.Backend, data.Lister, data.ContextAdder, data.RangeGetter, data.RetryableErrorChecker
+// and data.StorageClassTransitioner interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.Lister = (*Backend)(nil)
+var _ data.StorageClassTransitioner = (*Backend)(nil)
+var _ data.ContextAdder = (*Backend)(nil)
+var _ data.RangeGetter = (*Backend)(nil)
+var _ data.RetryableErrorChecker = (*Backend)(nil)
+
+// S3 multipart upload part size limits
+const (
+	minPartSize = 5 * 1024 * 1024
+	maxPartSize = 5 * 1024 * 1024 * 1024
+)
+
+// Matches the regional AWS S3 endpoints ( s3.eu-west-3.amazonaws.com, s3-eu-west-3.amazonaws.com, s3.dualstack.eu-west-3.amazonaws.com )
+var awsRegionalEndpointRegexp = regexp.MustCompile(`^s3[.-](?:dualstack\.)?([a-z0-9-]+)\.amazonaws\.com$`)
 
 // Config describes configuration for Swift data backend
 type Config struct {
-	Endpoint        string
+	Endpoint        string // host:port or URL, the URL scheme overrides UseSSL
 	AccessKeyID     string
 	SecretAccessKey string
 	Bucket          string
-	Location        string
+	Location        string // Location of the bucket if it has to be created ( default : Region or us-east-1 )
+	Region          string // Region used to sign the requests ( empty : the bucket location is discovered )
 	Prefix          string
 	PartSize        uint64
+	PartConcurrency uint
 	UseSSL          bool
+	UsePathStyle    bool // Address buckets as endpoint/bucket instead of bucket.endpoint ( MinIO and most S3 compatible stores )
 	SSE             string
+
+	StorageClass           string // Storage class of the new objects ( empty : bucket default, ex : STANDARD_IA for cold uploads )
+	TransitionStorageClass string // Storage class the objects are moved to once older than TransitionAfter ( ex : GLACIER_IR )
+	TransitionAfter        string // Age of the objects to move to TransitionStorageClass ( ex : "30d", empty : disabled )
+	transitionAfter        time.Duration
+
+	Transport http.RoundTripper // Transport of the connections to the endpoint ( nil : minio default transport )
 }
 
 // NewConfig instantiate a new default configuration
@@ -34,9 +64,17 @@ type Config struct {
 func NewConfig(params map[string]interface{}) (config *Config) {
 	config = new(Config)
 	config.Bucket = "plik"
-	config.Location = "us-east-1"
 	config.PartSize = 16 * 1000 * 1000 // 16MB
+	config.PartConcurrency = 1
 	utils.Assign(config, params)
+
+	if config.Location == "" {
+		config.Location = config.Region
+	}
+	if config.Location == "" {
+		config.Location = "us-east-1"
+	}
+
 	return
 }
 
@@ -57,15 +95,80 @@ func (config *Config) Validate() error {
 	if config.Location == "" {
 		return fmt.Errorf("missing location")
 	}
-	if config.PartSize < 5*1000*1000 {
-		return fmt.Errorf("invalid part size")
+
+	host, _, err := config.getEndpoint()
+	if err != nil {
+		return err
+	}
+	if config.Region != "" {
+		if config.Location != config.Region {
+			return fmt.Errorf("bucket location %s does not match region %s", config.Location, config.Region)
+		}
+		if match := awsRegionalEndpointRegexp.FindStringSubmatch(host); match != nil && match[1] != config.Region {
+			return fmt.Errorf("endpoint %s does not match region %s", host, config.Region)
+		}
+	}
+
+	if config.PartSize < minPartSize {
+		return fmt.Errorf("invalid part size, S3 requires at least 5MiB")
+	}
+	if config.PartSize > maxPartSize {
+		return fmt.Errorf("invalid part size, S3 allows at most 5GiB")
+	}
+	if config.PartConcurrency < 1 {
+		return fmt.Errorf("invalid part concurrency")
 	}
+
+	config.transitionAfter = 0
+	if config.TransitionAfter != "" {
+		transitionAfter, err := common.ParseTTL(config.TransitionAfter)
+		if err != nil {
+			return fmt.Errorf("unable to parse TransitionAfter : %s", err)
+		}
+		if transitionAfter <= 0 {
+			return fmt.Errorf("invalid TransitionAfter, expected a positive duration")
+		}
+		if config.TransitionStorageClass == "" {
+			return fmt.Errorf("TransitionAfter needs a TransitionStorageClass")
+		}
+		config.transitionAfter = time.Duration(transitionAfter) * time.Second
+	}
+
 	return nil
 }
 
+// getEndpoint return the endpoint host and whether to use SSL
+func (config *Config) getEndpoint() (host string, secure bool, err error) {
+	if !strings.Contains(config.Endpoint, "://") {
+		return config.Endpoint, config.UseSSL, nil
+	}
+
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid endpoint %s : %s", config.Endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, fmt.Errorf("invalid endpoint scheme %s, expected http or https", u.Scheme)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return "", false, fmt.Errorf("invalid endpoint %s, expected scheme://host:port", config.Endpoint)
+	}
+
+	return u.Host, u.Scheme == "https", nil
+}
+
+// getBucketLookup return how the client addresses the bucket
+func (config *Config) getBucketLookup() minio.BucketLookupType {
+	if config.UsePathStyle {
+		return minio.BucketLookupPath
+	}
+	return minio.BucketLookupAuto
+}
+
 // BackendDetails additional backend metadata
 type BackendDetails struct {
-	SSEKey string
+	SSEKey       string
+	StorageClass string `json:",omitempty"`
 }
 
 // Backend object
@@ -85,10 +188,17 @@ func NewBackend(config *Config) (b *Backend, err error) {
 		return nil, fmt.Errorf("invalid s3 data backend config : %s", err)
 	}
 
-	b.client, err = minio.New(config.Endpoint, &minio.Options{
-		Creds: credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
-		//Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
-		Secure: config.UseSSL,
+	endpoint, secure, err := config.getEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	b.client, err = minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+		Transport:    config.Transport,
+		Secure:       secure,
+		Region:       config.Region,
+		BucketLookup: config.getBucketLookup(),
 	})
 	if err != nil {
 		return nil, err
@@ -125,18 +235,52 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), getOpts)
 }
 
+// GetFileRange implementation for S3 Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	getOpts := minio.GetObjectOptions{}
+
+	// Configure server side encryption
+	getOpts.ServerSideEncryption, err = b.getServerSideEncryption(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = getOpts.SetRange(offset, offset+length-1)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.client.GetObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), getOpts)
+}
+
 // AddFile implementation for S3 Data Backend
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
+	return b.AddFileWithContext(context.TODO(), file, fileReader)
+}
+
+// AddFileWithContext implementation for S3 Data Backend
+func (b *Backend) AddFileWithContext(ctx context.Context, file *common.File, fileReader io.Reader) (err error) {
 	putOpts := minio.PutObjectOptions{ContentType: file.Type}
 
+	// Number of parts uploaded in parallel, each one being buffered in memory
+	putOpts.NumThreads = b.config.PartConcurrency
+
 	// Configure server side encryption
 	putOpts.ServerSideEncryption, err = b.getServerSideEncryption(file)
 	if err != nil {
 		return err
 	}
 
+	if b.config.StorageClass != "" {
+		putOpts.StorageClass = b.config.StorageClass
+		err = setStorageClass(file, b.config.StorageClass)
+		if err != nil {
+			return err
+		}
+	}
+
 	if file.Size > 0 {
-		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), fileReader, file.Size, putOpts)
+		_, err = b.client.PutObject(ctx, b.config.Bucket, b.getObjectName(file.ID), fileReader, file.Size, putOpts)
 	} else {
 		// https://github.com/minio/minio-go/issues/989
 		// Minio defaults to 128MB chunks and has to actually allocate a buffer of this size before uploading the chunk
@@ -144,11 +288,26 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 		// We default to 16MB which allow to store files up to 160GB ( 10000 chunks of 16MB ), feel free to adjust this parameter to your needs.
 		putOpts.PartSize = b.config.PartSize
 
-		_, err = b.client.PutObject(context.TODO(), b.config.Bucket, b.getObjectName(file.ID), fileReader, -1, putOpts)
+		_, err = b.client.PutObject(ctx, b.config.Bucket, b.getObjectName(file.ID), fileReader, -1, putOpts)
 	}
 	return err
 }
 
+// IsRetryableError implementation for S3 Data Backend
+func (b *Backend) IsRetryableError(err error) bool {
+	errResponse := minio.ToErrorResponse(err)
+	if errResponse.StatusCode >= http.StatusInternalServerError || errResponse.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	switch errResponse.Code {
+	case "SlowDown", "RequestTimeout", "InternalError", "ServiceUnavailable":
+		return true
+	}
+
+	return false
+}
+
 // RemoveFile implementation for S3 Data Backend
 func (b *Backend) RemoveFile(file *common.File) (err error) {
 	objectName := b.getObjectName(file.ID)
@@ -165,6 +324,27 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for S3 Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	prefix := b.getObjectName("")
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	for object := range b.client.ListObjects(ctx, b.config.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("Unable to list s3 objects : %s", object.Err)
+		}
+
+		err = f(&common.File{ID: strings.TrimPrefix(object.Key, prefix), CreatedAt: object.LastModified})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *Backend) getObjectName(name string) string {
 	if b.config.Prefix != "" {
 		return fmt.Sprintf("%s/%s", b.config.Prefix, name)
@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func newTestConfig(params map[string]interface{}) *Config {
+	merged := map[string]interface{}{
+		"Endpoint":        "127.0.0.1:9000",
+		"AccessKeyID":     "access_key_id",
+		"SecretAccessKey": "access_key_secret",
+	}
+	for key, value := range params {
+		merged[key] = value
+	}
+	return NewConfig(merged)
+}
+
+func TestNewConfigLocation(t *testing.T) {
+	config := newTestConfig(nil)
+	require.Equal(t, "us-east-1", config.Location)
+	require.Equal(t, "", config.Region)
+	require.NoError(t, config.Validate())
+
+	config = newTestConfig(map[string]interface{}{"Region": "eu-west-3"})
+	require.Equal(t, "eu-west-3", config.Location, "location should default to the region")
+	require.NoError(t, config.Validate())
+
+	config = newTestConfig(map[string]interface{}{"Region": "eu-west-3", "Location": "us-east-1"})
+	common.RequireError(t, config.Validate(), "bucket location us-east-1 does not match region eu-west-3")
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	config := newTestConfig(map[string]interface{}{"UseSSL": true})
+	host, secure, err := config.getEndpoint()
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:9000", host)
+	require.True(t, secure)
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "http://minio:9000", "UseSSL": true})
+	host, secure, err = config.getEndpoint()
+	require.NoError(t, err)
+	require.Equal(t, "minio:9000", host)
+	require.False(t, secure, "the endpoint scheme should override UseSSL")
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "https://minio:9000/"})
+	host, secure, err = config.getEndpoint()
+	require.NoError(t, err)
+	require.Equal(t, "minio:9000", host)
+	require.True(t, secure)
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "ftp://minio:9000"})
+	common.RequireError(t, config.Validate(), "invalid endpoint scheme ftp")
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "https://minio:9000/plik"})
+	common.RequireError(t, config.Validate(), "expected scheme://host:port")
+}
+
+func TestConfigEndpointRegion(t *testing.T) {
+	config := newTestConfig(map[string]interface{}{"Endpoint": "s3.eu-west-3.amazonaws.com", "Region": "eu-west-3"})
+	require.NoError(t, config.Validate())
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "https://s3.dualstack.eu-west-3.amazonaws.com", "Region": "eu-west-3"})
+	require.NoError(t, config.Validate())
+
+	config = newTestConfig(map[string]interface{}{"Endpoint": "s3-eu-west-3.amazonaws.com", "Region": "us-west-2"})
+	common.RequireError(t, config.Validate(), "endpoint s3-eu-west-3.amazonaws.com does not match region us-west-2")
+
+	// The bucket location is discovered by the global endpoint
+	config = newTestConfig(map[string]interface{}{"Endpoint": "s3.amazonaws.com", "Region": "us-west-2"})
+	require.NoError(t, config.Validate())
+}
+
+func TestConfigBucketLookup(t *testing.T) {
+	config := newTestConfig(nil)
+	require.Equal(t, minio.BucketLookupAuto, config.getBucketLookup())
+
+	config = newTestConfig(map[string]interface{}{"UsePathStyle": true})
+	require.Equal(t, minio.BucketLookupPath, config.getBucketLookup())
+}
@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// GetStorageClassTransitionAge implementation for S3 Data Backend
+func (b *Backend) GetStorageClassTransitionAge() time.Duration {
+	return b.config.transitionAfter
+}
+
+// TransitionStorageClass implementation for S3 Data Backend
+// S3 can't change the storage class of an object in place so the object is copied over itself with the new storage class
+func (b *Backend) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	if b.config.transitionAfter <= 0 || time.Since(file.CreatedAt) < b.config.transitionAfter {
+		return false, nil
+	}
+
+	storageClass, err := getStorageClass(file)
+	if err != nil {
+		return false, err
+	}
+	if storageClass == b.config.TransitionStorageClass {
+		return false, nil
+	}
+
+	sse, err := b.getServerSideEncryption(file)
+	if err != nil {
+		return false, err
+	}
+
+	objectName := b.getObjectName(file.ID)
+	src := minio.CopySrcOptions{Bucket: b.config.Bucket, Object: objectName}
+	if sse != nil && sse.Type() == encrypt.SSEC {
+		src.Encryption = sse
+	}
+
+	// Replacing the metadata is the only way to set the storage class of the copy, the content type has to be set again
+	dst := minio.CopyDestOptions{
+		Bucket:          b.config.Bucket,
+		Object:          objectName,
+		Encryption:      sse,
+		ReplaceMetadata: true,
+		UserMetadata: map[string]string{
+			"Content-Type":        file.Type,
+			"X-Amz-Storage-Class": b.config.TransitionStorageClass,
+		},
+	}
+
+	// A single copy request is limited to 5GiB, bigger objects are copied in parts
+	if file.Size > maxPartSize {
+		_, err = b.client.ComposeObject(context.TODO(), dst, src)
+	} else {
+		_, err = b.client.CopyObject(context.TODO(), dst, src)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to change the storage class of s3 object %s : %s", objectName, err)
+	}
+
+	err = setStorageClass(file, b.config.TransitionStorageClass)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get the storage class of the file from the file backend details
+func getStorageClass(file *common.File) (storageClass string, err error) {
+	if file.BackendDetails == "" {
+		return "", nil
+	}
+
+	backendDetails := &BackendDetails{}
+	err = json.Unmarshal([]byte(file.BackendDetails), backendDetails)
+	if err != nil {
+		return "", fmt.Errorf("unable to deserialize backend details : %s", err)
+	}
+
+	return backendDetails.StorageClass, nil
+}
+
+// Save the storage class of the file in the file backend details
+func setStorageClass(file *common.File, storageClass string) (err error) {
+	backendDetails := &BackendDetails{}
+
+	if file.BackendDetails != "" {
+		err = json.Unmarshal([]byte(file.BackendDetails), backendDetails)
+		if err != nil {
+			return fmt.Errorf("unable to deserialize backend details : %s", err)
+		}
+	}
+
+	backendDetails.StorageClass = storageClass
+
+	backendDetailsJSON, err := json.Marshal(backendDetails)
+	if err != nil {
+		return fmt.Errorf("unable to serialize backend details : %s", err)
+	}
+
+	file.BackendDetails = string(backendDetailsJSON)
+	return nil
+}
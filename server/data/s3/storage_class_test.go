@@ -0,0 +1,67 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestConfigTransitionAfter(t *testing.T) {
+	config := newTestConfig(map[string]interface{}{"TransitionAfter": "30d", "TransitionStorageClass": "STANDARD_IA"})
+	require.NoError(t, config.Validate())
+	require.Equal(t, 30*24*time.Hour, config.transitionAfter)
+
+	config = newTestConfig(map[string]interface{}{"TransitionAfter": "30d"})
+	common.RequireError(t, config.Validate(), "TransitionAfter needs a TransitionStorageClass")
+
+	config = newTestConfig(map[string]interface{}{"TransitionAfter": "foo", "TransitionStorageClass": "STANDARD_IA"})
+	common.RequireError(t, config.Validate(), "unable to parse TransitionAfter")
+
+	config = newTestConfig(map[string]interface{}{"TransitionAfter": "0", "TransitionStorageClass": "STANDARD_IA"})
+	common.RequireError(t, config.Validate(), "invalid TransitionAfter, expected a positive duration")
+}
+
+func TestStorageClassBackendDetails(t *testing.T) {
+	file := &common.File{}
+	storageClass, err := getStorageClass(file)
+	require.NoError(t, err)
+	require.Equal(t, "", storageClass)
+
+	err = setServerSideEncryptionKey(file, "key")
+	require.NoError(t, err)
+	err = setStorageClass(file, "STANDARD_IA")
+	require.NoError(t, err)
+
+	storageClass, err = getStorageClass(file)
+	require.NoError(t, err)
+	require.Equal(t, "STANDARD_IA", storageClass)
+
+	key, err := getServerSideEncryptionKey(file)
+	require.NoError(t, err)
+	require.Equal(t, "key", key, "the SSE key must be kept")
+
+	file.BackendDetails = "invalid"
+	_, err = getStorageClass(file)
+	common.RequireError(t, err, "unable to deserialize backend details")
+}
+
+func TestTransitionStorageClassNotDue(t *testing.T) {
+	config := newTestConfig(map[string]interface{}{"TransitionAfter": "24h", "TransitionStorageClass": "STANDARD_IA"})
+	require.NoError(t, config.Validate())
+	b := &Backend{config: config}
+
+	// Recent files are not transitioned
+	transitioned, err := b.TransitionStorageClass(&common.File{ID: "file", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	require.False(t, transitioned)
+
+	// Files already in the transition storage class are not copied again
+	file := &common.File{ID: "file", CreatedAt: time.Now().Add(-48 * time.Hour)}
+	require.NoError(t, setStorageClass(file, "STANDARD_IA"))
+	transitioned, err = b.TransitionStorageClass(file)
+	require.NoError(t, err)
+	require.False(t, transitioned)
+}
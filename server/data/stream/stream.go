@@ -10,6 +10,7 @@ import (
 )
 
 // Ensure Stream Data Backend implements data.Backend interface
+// Streams are read only once so they can't implement data.RangeGetter
 var _ data.Backend = (*Backend)(nil)
 
 // Backend object
@@ -1,8 +1,11 @@
 package swift
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
 	"github.com/ncw/swift"
 	"github.com/root-gg/utils"
@@ -11,8 +14,11 @@ import (
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Swift Data Backend implements data.Backend interface
+// Ensure Swift Data Backend implements data.Backend, data.Lister, data.RangeGetter and data.RetryableErrorChecker interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.Lister = (*Backend)(nil)
+var _ data.RangeGetter = (*Backend)(nil)
+var _ data.RetryableErrorChecker = (*Backend)(nil)
 
 // Config describes configuration for Swift data backend
 type Config struct {
@@ -62,6 +68,26 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return reader, nil
 }
 
+// GetFileRange implementation for Swift Data Backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	err = b.auth()
+	if err != nil {
+		return nil, err
+	}
+
+	reader, pipeWriter := io.Pipe()
+	objectID := objectID(file)
+	headers := swift.Headers{"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}
+	go func() {
+		// The hash of a part of the object can't be checked
+		_, e := b.connection.ObjectGet(b.config.Container, objectID, pipeWriter, false, headers)
+		defer func() { _ = pipeWriter.CloseWithError(e) }()
+	}()
+
+	// This does only very basic checking and basically always return nil, error will happen when reading from the reader
+	return reader, nil
+}
+
 // AddFile implementation for Swift Data Backend
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	err = b.auth()
@@ -71,9 +97,13 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 
 	objectID := objectID(file)
 	object, err := b.connection.ObjectCreate(b.config.Container, objectID, true, "", "", nil)
+	if err != nil {
+		return err
+	}
 
 	_, err = io.Copy(object, fileReader)
 	if err != nil {
+		_ = object.Close()
 		return err
 	}
 	err = object.Close()
@@ -84,6 +114,19 @@ func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
 	return nil
 }
 
+// IsRetryableError implementation for Swift Data Backend
+func (b *Backend) IsRetryableError(err error) bool {
+	var swiftErr *swift.Error
+	if errors.As(err, &swiftErr) {
+		switch swiftErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, swift.RateLimit.StatusCode:
+			return true
+		}
+		return swiftErr.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
 // RemoveFile implementation for Swift Data Backend
 func (b *Backend) RemoveFile(file *common.File) (err error) {
 	err = b.auth()
@@ -104,6 +147,34 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for Swift Data Backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	err = b.auth()
+	if err != nil {
+		return err
+	}
+
+	objects, err := b.connection.ObjectsAll(b.config.Container, nil)
+	if err != nil {
+		return fmt.Errorf("unable to list swift objects : %s", err)
+	}
+
+	for _, object := range objects {
+		// Object names are "uploadID.fileID"
+		i := strings.LastIndex(object.Name, ".")
+		if i < 0 {
+			continue
+		}
+
+		err = f(&common.File{UploadID: object.Name[:i], ID: object.Name[i+1:], CreatedAt: object.LastModified})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func objectID(file *common.File) string {
 	return file.UploadID + "." + file.ID
 }
@@ -6,19 +6,27 @@ import (
 	"io"
 	"io/ioutil"
 	"sync"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/data"
 )
 
-// Ensure Testing Data Backend implements data.Backend interface
+// Ensure Testing Data Backend implements data.Backend, data.Lister, data.RangeGetter and data.StorageClassTransitioner interfaces
 var _ data.Backend = (*Backend)(nil)
+var _ data.Lister = (*Backend)(nil)
+var _ data.RangeGetter = (*Backend)(nil)
+var _ data.StorageClassTransitioner = (*Backend)(nil)
+
+// TransitionedBackendDetails is set as the backend details of the transitioned files
+const TransitionedBackendDetails = "transitioned"
 
 // Backend object
 type Backend struct {
-	files map[string][]byte
-	err   error
-	mu    sync.Mutex
+	files         map[string][]byte
+	err           error
+	transitionAge time.Duration
+	mu            sync.Mutex
 }
 
 // NewBackend instantiate a new Testing Data Backend
@@ -51,6 +59,31 @@ func (b *Backend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
 	return nil, errors.New("file not found")
 }
 
+// GetFileRange implementation for testing data backend
+func (b *Backend) GetFileRange(file *common.File, offset int64, length int64) (reader io.ReadCloser, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	content, ok := b.files[file.ID]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	end := offset + length
+	if end > int64(len(content)) {
+		end = int64(len(content))
+	}
+
+	return ioutil.NopCloser(bytes.NewBuffer(content[offset:end])), nil
+}
+
 // AddFile implementation for testing data backend will creates a new file for the given upload
 // and save it on filesystem with the given file reader
 func (b *Backend) AddFile(file *common.File, fileReader io.Reader) (err error) {
@@ -90,6 +123,54 @@ func (b *Backend) RemoveFile(file *common.File) (err error) {
 	return nil
 }
 
+// ForEachFile implementation for testing data backend
+func (b *Backend) ForEachFile(f func(file *common.File) error) (err error) {
+	b.mu.Lock()
+	var files []*common.File
+	for id := range b.files {
+		files = append(files, &common.File{ID: id})
+	}
+	e := b.err
+	b.mu.Unlock()
+
+	if e != nil {
+		return e
+	}
+
+	for _, file := range files {
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetStorageClassTransitionAge implementation for testing data backend
+func (b *Backend) GetStorageClassTransitionAge() time.Duration {
+	return b.transitionAge
+}
+
+// TransitionStorageClass implementation for testing data backend flag the files older than the transition age
+func (b *Backend) TransitionStorageClass(file *common.File) (transitioned bool, err error) {
+	if b.err != nil {
+		return false, b.err
+	}
+
+	if b.transitionAge <= 0 || time.Since(file.CreatedAt) < b.transitionAge || file.BackendDetails == TransitionedBackendDetails {
+		return false, nil
+	}
+
+	file.BackendDetails = TransitionedBackendDetails
+	return true, nil
+}
+
+// SetStorageClassTransitionAge set the age after which the files are transitioned ( 0 : disabled )
+func (b *Backend) SetStorageClassTransitionAge(age time.Duration) {
+	b.transitionAge = age
+}
+
 // SetError set the error that this backend will return on any subsequent method call
 func (b *Backend) SetError(err error) {
 	b.err = err
@@ -3,6 +3,7 @@ package file
 import (
 	"bytes"
 	"errors"
+	"io/ioutil"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -85,6 +86,27 @@ func TestGetFile(t *testing.T) {
 	require.NoError(t, err, "unable to get file")
 }
 
+func TestGetFileRange(t *testing.T) {
+	backend := NewBackend()
+	upload := &common.Upload{}
+	file := upload.NewFile()
+
+	err := backend.AddFile(file, bytes.NewBufferString("0123456789"))
+	require.NoError(t, err, "unable to add file")
+
+	reader, err := backend.GetFileRange(file, 2, 4)
+	require.NoError(t, err, "unable to get file range")
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "2345", string(content))
+
+	reader, err = backend.GetFileRange(file, 8, 42)
+	require.NoError(t, err, "unable to get file range")
+	content, err = ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file range")
+	require.Equal(t, "89", string(content))
+}
+
 func TestRemoveFileError(t *testing.T) {
 	backend := NewBackend()
 	backend.SetError(errors.New("error"))
@@ -116,3 +138,26 @@ func TestRemoveFile(t *testing.T) {
 	require.Error(t, err, "unable to get file")
 	require.Equal(t, "file not found", err.Error(), "invalid error message")
 }
+
+func TestForEachFile(t *testing.T) {
+	backend := NewBackend()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+
+	err := backend.AddFile(file, &bytes.Buffer{})
+	require.NoError(t, err, "unable to add file")
+
+	var files []*common.File
+	err = backend.ForEachFile(func(f *common.File) error {
+		files = append(files, f)
+		return nil
+	})
+	require.NoError(t, err, "unable to list files")
+	require.Len(t, files, 1, "invalid file count")
+	require.Equal(t, file.ID, files[0].ID, "invalid file id")
+
+	backend.SetError(errors.New("error"))
+	err = backend.ForEachFile(func(f *common.File) error { return nil })
+	require.Error(t, err, "missing error")
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// AbortFile abort the transfer of a file, delete the partially uploaded data and mark the file as deleted
+// Aborting a file that is already removed or deleted does nothing
+func AbortFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Check authorization
+	if !upload.IsAdmin {
+		ctx.Forbidden("you are not allowed to abort file transfers of this upload")
+		return
+	}
+
+	// Stream transfers block until the download begins
+	if upload.Stream {
+		ctx.BadRequest("stream file transfers can't be aborted")
+		return
+	}
+
+	// Get file from context
+	file := ctx.GetFile()
+	if file == nil {
+		panic("missing file from context")
+	}
+
+	if !checkAuthorization(ctx, common.AuthorizationActionDelete, upload, file) {
+		return
+	}
+
+	// Stop the transfer if it is in flight on this server
+	var aborted bool
+	if file.Status == common.FileUploading {
+		if transfers := ctx.GetTransfers(); transfers != nil {
+			var err error
+			aborted, err = transfers.Abort(file.ID)
+			if err != nil {
+				ctx.InternalServerError("unable to abort file transfer", err)
+				return
+			}
+		}
+
+		// The transfer may have ended in the meantime
+		f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+		if err != nil {
+			ctx.InternalServerError("unable to get file", err)
+			return
+		}
+		if f == nil {
+			ctx.NotFound("file not found")
+			return
+		}
+		file = f
+	}
+
+	switch file.Status {
+	case common.FileMissing:
+		err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileMissing, common.FileDeleted)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			return
+		}
+	case common.FileUploading:
+		// Data backends drop the partial data of failed transfers.
+		// If the file is transferred by another server or the data can't be deleted now
+		// it will be deleted by the next cleaning cycle.
+		newStatus := common.FileRemoved
+		if aborted {
+			if err := ctx.GetDataBackend().RemoveFile(file); err != nil {
+				log.Warningf("unable to remove aborted file %s from the data backend : %s", file.ID, err)
+			} else {
+				newStatus = common.FileDeleted
+			}
+		}
+
+		err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileUploading, newStatus)
+		if err != nil {
+			ctx.InternalServerError("unable to update file status", err)
+			return
+		}
+	case common.FileUploaded:
+		ctx.BadRequest("file has already been uploaded")
+		return
+	}
+
+	common.WriteJSONResponse(resp, file)
+}
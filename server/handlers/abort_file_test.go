@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestAbortFileMissing(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileMissing
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(respBody, fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, common.FileDeleted, fileResult.Status, "invalid file status")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err)
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+}
+
+func TestAbortFileInFlight(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetTransfers(common.NewTransfers())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploading
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	stop := make(chan struct{})
+	done := ctx.GetTransfers().Register(file.ID, func() { close(stop) })
+	go func() {
+		<-stop
+		done()
+	}()
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err)
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+
+	_, err = ctx.GetDataBackend().GetFile(file)
+	require.Error(t, err, "partial data should have been removed")
+}
+
+func TestAbortFileNotInFlight(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetTransfers(common.NewTransfers())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploading
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err)
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+}
+
+func TestAbortFileUploaded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file has already been uploaded")
+}
+
+func TestAbortFileDeleted(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileDeleted
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestAbortFileStream(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, Stream: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploading
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "stream file transfers can't be aborted")
+}
+
+func TestAbortFileNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploading
+	upload.InitializeForTests()
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("DELETE", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"/transfer", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AbortFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to abort file transfers of this upload")
+}
@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 
@@ -17,7 +24,10 @@ type preprocessOutputReturn struct {
 	size     int64
 	md5sum   string
 	mimeType string
+	header   []byte // Beginning of the data to detect the media metadata
 	err      error
+
+	checksumErr bool // The data does not match the checksum sent by the client or it is missing
 }
 
 // AddFile add a file to an existing upload.
@@ -45,8 +55,12 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	// The expected md5sum of the file may be sent in the X-Plik-Md5 header or in a "md5" form field
+	expectedMd5 := req.Header.Get("X-Plik-Md5")
+
 	// Read multipart body until the "file" part
 	var fileName string
+	var contentEncoding string
 	for {
 		part, errPart := multiPartReader.NextPart()
 		if errPart == io.EOF {
@@ -56,9 +70,18 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 			ctx.InvalidParameter("multipart form : %s", errPart)
 			return
 		}
+		if part.FormName() == "md5" {
+			expectedMd5, err = readChecksumField(part)
+			if err != nil {
+				ctx.InvalidParameter("md5 form field : %s", err)
+				return
+			}
+			continue
+		}
 		if part.FormName() == "file" {
 			fileReader = part
 			fileName = part.FileName()
+			contentEncoding = part.Header.Get("Content-Encoding")
 			break
 		}
 	}
@@ -66,70 +89,180 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		ctx.MissingParameter("file from multipart form")
 		return
 	}
-	if fileName == "" {
+	// Files uploaded without a name are named from the DefaultFilename template
+	if fileName == "" && config.DefaultFilename == "" {
 		ctx.MissingParameter("file name from multipart form")
 		return
 	}
 
+	if expectedMd5 != "" && !common.IsValidMd5(expectedMd5) {
+		ctx.InvalidParameter("md5 checksum %s", expectedMd5)
+		return
+	}
+
 	// Get file from context
 	file := ctx.GetFile()
+
+	authorizationFile := file
+	if authorizationFile == nil {
+		authorizationFile = &common.File{Name: fileName}
+	}
+	if !checkAuthorization(ctx, common.AuthorizationActionUpload, upload, authorizationFile) {
+		return
+	}
+
+	file, ok := prepareFile(ctx, upload, file, fileName, contentEncoding)
+	if !ok {
+		return
+	}
+
+	// Update request logger prefix
+	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
+	log.SetPrefix(prefix)
+
+	// Files may be added by someone else than the upload owner using the upload token
+	maxFileSize, err := ctx.GetUploadMaxFileSize(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload owner", err)
+		return
+	}
+
+	checkMd5 := func(md5sum string) error {
+		return checkFileChecksum(multiPartReader, expectedMd5, md5sum, config.RequireUploadChecksum)
+	}
+	if !saveFile(ctx, upload, file, fileReader, maxFileSize, checkMd5) {
+		return
+	}
+
+	// The extracted files are listed with the upload
+	if _, ok := expandArchive(ctx, upload, file); !ok {
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	file.Sanitize()
+
+	if ctx.IsQuick() {
+		// Do our best to print the file url in the response.
+		var url string
+		if upload.DownloadDomain != "" {
+			url = upload.DownloadDomain
+		} else if ctx.GetConfig().GetDownloadDomain() != nil {
+			url = ctx.GetConfig().GetDownloadDomain().String()
+		} else {
+			url = ctx.GetConfig().GetRequestServerURL(req).String()
+		}
+
+		url += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name)
+
+		_, _ = resp.Write([]byte(url + "\n"))
+	} else {
+		common.WriteJSONResponse(resp, file)
+	}
+}
+
+// prepareFile create the file if it was not declared with the upload and check that it can be uploaded.
+// The response is written and false is returned if it can't
+func prepareFile(ctx *context.Context, upload *common.Upload, file *common.File, fileName string, contentEncoding string) (*common.File, bool) {
+	config := ctx.GetConfig()
+
+	var err error
 	if file == nil {
 		count, err := ctx.GetMetadataBackend().CountUploadFiles(upload.ID)
 		if err != nil {
 			ctx.InternalServerError("unable get upload file count", err)
-			return
+			return nil, false
 		}
 
 		if count >= config.MaxFilePerUpload {
 			// TODO there is a slight race condition here
 			// THIS SHOULD BE A DB CONSTRAINT
 			ctx.BadRequest("maximum number file per upload reached, limit is %d", config.MaxFilePerUpload)
-			return
+			return nil, false
 		}
 
 		// Create a new file object
 		file, err = ctx.CreateFile(upload, &common.File{Name: fileName})
 		if err != nil {
 			ctx.BadRequest("unable to create file : %s", err.Error())
-			return
+			return nil, false
+		}
+
+		err = ctx.CheckMetadataSize(upload, file)
+		if err != nil {
+			if errors.Is(err, common.ErrMetadataTooLarge) {
+				ctx.RequestEntityTooLarge("unable to create file : %s", err)
+				return nil, false
+			}
+			ctx.InternalServerError("unable to check upload metadata size", err)
+			return nil, false
 		}
 
 		// Update metadata
 		err = ctx.GetMetadataBackend().CreateFile(file)
 		if err != nil {
 			ctx.InternalServerError("unable to create file", err)
-			return
+			return nil, false
 		}
 	} else {
-		if file.Name != fileName {
+		if fileName != "" && file.Name != fileName {
 			ctx.BadRequest("invalid file name")
-			return
+			return nil, false
 		}
 	}
 
-	// Update request logger prefix
-	prefix := fmt.Sprintf("%s[%s]", log.Prefix, file.Name)
-	log.SetPrefix(prefix)
-
 	if file.Status != common.FileMissing {
 		ctx.BadRequest("invalid file status %s, expected %s", file.Status, common.FileMissing)
-		return
+		return nil, false
+	}
+
+	// Pre-compressed data is stored as is and served back with its Content-Encoding
+	if config.ContentEncodingPassthrough {
+		file.ContentEncoding, err = common.SanitizeContentEncoding(contentEncoding)
+		if err != nil {
+			ctx.BadRequest("%s", err)
+			return nil, false
+		}
 	}
 
+	return file, true
+}
+
+// saveFile stream the file data to the data backend and update the file metadata.
+// The response is written and false is returned if it fails
+func saveFile(ctx *context.Context, upload *common.Upload, file *common.File, fileReader io.Reader, maxFileSize int64, checkMd5 func(md5sum string) error) bool {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
 	// Update file status
-	err = ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileUploading)
+	err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileUploading)
 	if err != nil {
 		ctx.InternalServerError("unable to update file status", err)
-		return
+		return false
+	}
+
+	// Publish the upload progress to the clients following it
+	var tracker *common.UploadProgressTracker
+	if broker := ctx.GetUploadProgressBroker(); broker != nil {
+		tracker = broker.Track(file)
+		defer tracker.Done()
 	}
 
 	// Pipe file data from the request body to a preprocessing goroutine
 	//  - Guess content type
 	//  - Compute/Limit upload size
 	//  - Compute md5sum
+	//  - Publish upload progress
 	preprocessReader, preprocessWriter := io.Pipe()
-	preprocessOutputCh := make(chan preprocessOutputReturn)
-	go preprocessor(ctx, fileReader, preprocessWriter, preprocessOutputCh)
+	preprocessOutputCh := make(chan preprocessOutputReturn, 1)
+	go preprocessor(ctx, fileReader, maxFileSize, preprocessWriter, preprocessOutputCh, tracker, checkMd5)
+
+	// Let the client abort the transfer, the data backend gets an error and drops the partial data
+	if transfers := ctx.GetTransfers(); transfers != nil && !upload.Stream {
+		done := transfers.Register(file.ID, func() { _ = preprocessReader.CloseWithError(common.ErrTransferAborted) })
+		defer done()
+	}
 
 	// Save file in the data backend
 	var backend data.Backend
@@ -141,26 +274,76 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 
 	err = backend.AddFile(file, preprocessReader)
 	if err != nil {
+		// A file not matching its checksum is aborted before the data backend gets the end of the data
+		select {
+		case preprocessOutput := <-preprocessOutputCh:
+			if preprocessOutput.checksumErr {
+				resetFileStatus(ctx, file)
+				handleHTTPError(ctx, preprocessOutput.err)
+				return false
+			}
+		default:
+		}
+
 		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
 		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
 		ctx.InternalServerError("unable to save file", err)
-		return
+		return false
 	}
 
 	// Get preprocessor goroutine output
 	preprocessOutput := <-preprocessOutputCh
+	if preprocessOutput.checksumErr {
+		if err := backend.RemoveFile(file); err != nil {
+			log.Warningf("unable to remove corrupted file %s from the data backend : %s", file.ID, err)
+		}
+		resetFileStatus(ctx, file)
+		handleHTTPError(ctx, preprocessOutput.err)
+		return false
+	}
 	if preprocessOutput.err != nil {
 		// TODO : file status is left to common.FileUploading we should set it to some common.FileUploadError
 		// TODO : or we can set it back to common.FileMissing if we are sure data backends will handle that
 		handleHTTPError(ctx, preprocessOutput.err)
-		return
+		return false
+	}
+
+	// Read the stored data back to catch silent write corruption
+	if config.VerifyAfterWrite && !upload.Stream {
+		err = verifyFile(backend, file, preprocessOutput.md5sum)
+		if err != nil {
+			// Remove the corrupted data and let the client upload the file again
+			if err := backend.RemoveFile(file); err != nil {
+				log.Warningf("unable to remove corrupted file %s from the data backend : %s", file.ID, err)
+			}
+			if err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileUploading, common.FileMissing); err != nil {
+				log.Warningf("unable to update corrupted file %s status : %s", file.ID, err)
+			}
+			ctx.InternalServerError("unable to verify file", err)
+			return false
+		}
 	}
 
 	// Fill-in file information
 	file.Type = preprocessOutput.mimeType
+	if file.ContentEncoding != "" {
+		// The content type can't be detected from encoded data
+		file.Type = mime.TypeByExtension(path.Ext(file.Name))
+		if file.Type == "" {
+			file.Type = "application/octet-stream"
+		}
+	}
 	file.Size = preprocessOutput.size
 	file.Md5 = preprocessOutput.md5sum
 
+	// Encrypted or encoded data can't be parsed
+	if config.DetectMediaMetadata && file.EncryptionScheme == "" && file.ContentEncoding == "" {
+		media := common.DetectMediaMetadata(file.Type, preprocessOutput.header)
+		file.Width = media.Width
+		file.Height = media.Height
+		file.Duration = media.Duration
+	}
+
 	// Update file status
 	if upload.Stream {
 		file.Status = common.FileDeleted
@@ -172,41 +355,66 @@ func AddFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	err = ctx.GetMetadataBackend().UpdateFile(file, common.FileUploading)
 	if err != nil {
 		ctx.InternalServerError("unable to update file metadata", err)
-		return
+		return false
 	}
 
-	// Remove all private information (ip, data backend details, ...) before
-	// sending metadata back to the client
-	file.Sanitize()
+	// The TTL clock of TTLFromCompletion uploads starts over once the last byte is received
+	upload.SetCompleted(time.Now())
+	err = ctx.GetMetadataBackend().UpdateUploadCompletion(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to update upload metadata", err)
+		return false
+	}
 
-	if ctx.IsQuick() {
-		// Do our best to print the file url in the response.
-		var url string
-		if ctx.GetConfig().GetDownloadDomain() != nil {
-			url = ctx.GetConfig().GetDownloadDomain().String()
-		} else {
-			url = ctx.GetConfig().GetServerURL().String()
+	generateThumbnail(ctx, upload, file)
+
+	// The upload ready notification is sent once all the files of the upload have been uploaded
+	if config.UploadReadyWebhook != "" && !upload.Stream {
+		err = ctx.GetMetadataBackend().SetUploadReadyNotificationPending(upload.ID)
+		if err != nil {
+			log.Warningf("unable to flag upload %s for ready notification : %s", upload.ID, err)
 		}
+	}
 
-		url += fmt.Sprintf("/file/%s/%s/%s", upload.ID, file.ID, file.Name)
+	return true
+}
 
-		_, _ = resp.Write([]byte(url + "\n"))
-	} else {
-		common.WriteJSONResponse(resp, file)
+// verifyFile read the file back from the data backend and compare its md5sum with the uploaded data
+func verifyFile(backend data.Backend, file *common.File, md5sum string) (err error) {
+	reader, err := backend.GetFile(file)
+	if err != nil {
+		return err
 	}
+	defer func() { _ = reader.Close() }()
+
+	md5Hash := md5.New()
+	_, err = io.Copy(md5Hash, reader)
+	if err != nil {
+		return err
+	}
+
+	storedMd5sum := fmt.Sprintf("%x", md5Hash.Sum(nil))
+	if storedMd5sum != md5sum {
+		return fmt.Errorf("stored file md5sum %s does not match uploaded data md5sum %s", storedMd5sum, md5sum)
+	}
+
+	return nil
 }
 
 //  - Guess content type
 //  - Compute/Limit upload size
-//  - Compute md5sum
-func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.WriteCloser, outputCh chan preprocessOutputReturn) {
+//  - Compute md5sum and check it against the checksum sent by the client
+//  - Publish upload progress
+func preprocessor(ctx *context.Context, file io.Reader, maxFileSize int64, preprocessWriter *io.PipeWriter, outputCh chan preprocessOutputReturn, tracker *common.UploadProgressTracker, checkMd5 func(md5sum string) error) {
 	log := ctx.GetLogger()
-	maxFileSize := ctx.GetMaxFileSize()
 
 	var err error
 	var totalBytes int64
 	var mimeType string
 	var md5sum string
+	var header []byte
+
+	detectMediaMetadata := ctx.GetConfig().DetectMediaMetadata
 
 	md5Hash := md5.New()
 	buf := make([]byte, 1048)
@@ -231,6 +439,15 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 			mimeType = http.DetectContentType(buf)
 		}
 
+		// Keep the beginning of the data to detect the media metadata
+		if detectMediaMetadata && len(header) < common.MediaMetadataHeaderSize {
+			n := common.MediaMetadataHeaderSize - len(header)
+			if n > bytesRead {
+				n = bytesRead
+			}
+			header = append(header, buf[:n]...)
+		}
+
 		// Increment size
 		totalBytes += int64(bytesRead)
 
@@ -257,6 +474,19 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 			err = fmt.Errorf("invalid number of bytes written. Expected %d but got %d", bytesRead, bytesWritten)
 			break
 		}
+
+		tracker.Update(totalBytes)
+	}
+
+	// The data backend drops the data if the checksum does not match as it never gets the end of the file
+	if err == nil {
+		md5sum = fmt.Sprintf("%x", md5Hash.Sum(nil))
+		if errChecksum := checkMd5(md5sum); errChecksum != nil {
+			outputCh <- preprocessOutputReturn{err: errChecksum, checksumErr: true}
+			close(outputCh)
+			_ = preprocessWriter.CloseWithError(errChecksum)
+			return
+		}
 	}
 
 	errClose := preprocessWriter.Close()
@@ -267,9 +497,69 @@ func preprocessor(ctx *context.Context, file io.Reader, preprocessWriter io.Writ
 	if err != nil {
 		outputCh <- preprocessOutputReturn{err: err}
 	} else {
-		md5sum = fmt.Sprintf("%x", md5Hash.Sum(nil))
-		outputCh <- preprocessOutputReturn{size: totalBytes, md5sum: md5sum, mimeType: mimeType}
+		outputCh <- preprocessOutputReturn{size: totalBytes, md5sum: md5sum, mimeType: mimeType, header: header}
 	}
 
 	close(outputCh)
 }
+
+// resetFileStatus let the client upload the file again
+func resetFileStatus(ctx *context.Context, file *common.File) {
+	err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileUploading, common.FileMissing)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to update file %s status : %s", file.ID, err)
+	}
+}
+
+// readChecksumField read the md5sum sent in a multipart form field
+func readChecksumField(part io.Reader) (md5sum string, err error) {
+	value, err := ioutil.ReadAll(io.LimitReader(part, 64))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// checkFileChecksum compare the md5sum of the received data with the one sent by the client.
+// Clients streaming their data may only send the "md5" form field after the file
+func checkFileChecksum(multiPartReader *multipart.Reader, expectedMd5 string, md5sum string, required bool) (err error) {
+	if expectedMd5 == "" {
+		part, errPart := multiPartReader.NextPart()
+		if errPart == nil && part.FormName() == "md5" {
+			expectedMd5, err = readExpectedChecksum(part)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return compareFileChecksum(expectedMd5, md5sum, required)
+}
+
+// readExpectedChecksum read and validate the md5sum sent in a "md5" form field
+func readExpectedChecksum(part io.Reader) (expectedMd5 string, err error) {
+	expectedMd5, err = readChecksumField(part)
+	if err != nil {
+		return "", common.NewHTTPError("unable to read md5 form field", err, http.StatusBadRequest)
+	}
+	if !common.IsValidMd5(expectedMd5) {
+		return "", common.NewHTTPError(fmt.Sprintf("invalid md5 checksum %s", expectedMd5), nil, http.StatusBadRequest)
+	}
+	return expectedMd5, nil
+}
+
+// compareFileChecksum compare the md5sum of the received data with the expected one if any
+func compareFileChecksum(expectedMd5 string, md5sum string, required bool) error {
+	if expectedMd5 == "" {
+		if required {
+			return common.NewHTTPError("missing file md5 checksum ( X-Plik-Md5 header or md5 form field )", nil, http.StatusBadRequest)
+		}
+		return nil
+	}
+
+	if !strings.EqualFold(expectedMd5, md5sum) {
+		return common.NewHTTPError(fmt.Sprintf("file md5sum %s does not match the expected md5sum %s", md5sum, expectedMd5), nil, http.StatusUnprocessableEntity)
+	}
+
+	return nil
+}
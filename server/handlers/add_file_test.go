@@ -4,17 +4,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	data_test "github.com/root-gg/plik/server/data/testing"
 )
 
 var content = "data data data"
@@ -95,6 +101,77 @@ func TestAddFileWithID(t *testing.T) {
 	require.Equal(t, int64(len(content)), fileResult.Size, "invalid file size")
 }
 
+func TestAddFileTTLFromCompletion(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, TTL: 3600, TTLFromCompletion: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	// Simulate an upload created long before the end of the file upload
+	deadline := time.Now().Add(time.Minute)
+	upload.ExpireAt = &deadline
+	err := ctx.GetMetadataBackend().UpdateUploadExpirationDate(upload)
+	require.NoError(t, err, "unable to update upload expiration date")
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	start := time.Now()
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, result.CompletedAt, "missing completion date")
+	require.False(t, result.CompletedAt.Before(start.Truncate(time.Second)), "invalid completion date")
+	require.Equal(t, result.CompletedAt.Add(time.Hour).Unix(), result.ExpireAt.Unix(), "TTL should start from the completion date")
+}
+
+func TestAddFileDetectMediaMetadata(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DetectMediaMetadata = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "image.png"
+	createTestUpload(t, ctx, upload)
+
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 640, 480)))
+	require.NoError(t, err, "unable to encode png")
+
+	reader, contentType, err := getMultipartFormData(file.Name, buf)
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(respBody, fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, "image/png", fileResult.Type, "invalid file type")
+	require.Equal(t, 640, fileResult.Width, "invalid file width")
+	require.Equal(t, 480, fileResult.Height, "invalid file height")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 640, f.Width, "invalid file width")
+	require.Equal(t, 480, f.Height, "invalid file height")
+}
+
 func TestAddStreamFileWithID(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -166,6 +243,171 @@ func TestAddFileWithoutID(t *testing.T) {
 	require.Equal(t, int64(len(content)), fileResult.Size, "invalid file size")
 }
 
+func TestAddFileMetadataTooLarge(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadMetadataBytes = 10
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	upload.NewFile().Name = "file.txt"
+	createTestUpload(t, ctx, upload)
+	ctx.SetFile(nil)
+
+	reader, contentType, err := getMultipartFormData("other.txt", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusRequestEntityTooLarge, "unable to create file : upload metadata too large (17 bytes), maximum size is 10 bytes")
+}
+
+func TestAddFileNotAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &common.AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, common.AuthorizationActionUpload, request.Action, "invalid action")
+		require.Equal(t, "file.exe", request.FileName, "invalid file name")
+
+		_, _ = resp.Write([]byte(`{"allow":false,"reason":"executables are not allowed"}`))
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	reader, contentType, err := getMultipartFormData("file.exe", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "upload not allowed : executables are not allowed")
+
+	count, err := ctx.GetMetadataBackend().CountUploadFiles(upload.ID)
+	require.NoError(t, err, "unable to count upload files")
+	require.Equal(t, 0, count, "no file should have been created")
+}
+
+func getMultipartFormDataWithContentEncoding(name string, encoding string, in io.Reader) (out io.Reader, contentType string, err error) {
+	buffer := new(bytes.Buffer)
+	multipartWriter := multipart.NewWriter(buffer)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Content-Encoding", encoding)
+
+	writer, err := multipartWriter.CreatePart(header)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create multipartWriter : %s", err)
+	}
+
+	_, err = io.Copy(writer, in)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = multipartWriter.Close()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buffer, multipartWriter.FormDataContentType(), nil
+}
+
+func TestAddFileContentEncoding(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ContentEncodingPassthrough = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	gzipped := gzipData(t, content)
+	reader, contentType, err := getMultipartFormDataWithContentEncoding("app.js", "gzip", bytes.NewBuffer(gzipped))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, common.ContentEncodingGzip, fileResult.ContentEncoding, "invalid file content encoding")
+	require.Equal(t, int64(len(gzipped)), fileResult.Size, "invalid file size")
+	require.Contains(t, fileResult.Type, "javascript", "invalid file type")
+
+	file, err := ctx.GetMetadataBackend().GetFile(fileResult.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.ContentEncodingGzip, file.ContentEncoding, "invalid file content encoding")
+}
+
+func TestAddFileContentEncodingDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	reader, contentType, err := getMultipartFormDataWithContentEncoding("app.js", "gzip", bytes.NewBuffer(gzipData(t, content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var fileResult = &common.File{}
+	err = json.Unmarshal(rr.Body.Bytes(), fileResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "", fileResult.ContentEncoding, "content encoding should be ignored")
+}
+
+func TestAddFileUnsupportedContentEncoding(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ContentEncodingPassthrough = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	reader, contentType, err := getMultipartFormDataWithContentEncoding("app.js", "br", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unsupported content encoding br")
+}
+
 func TestAddFileWithoutUploadInContext(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -396,6 +638,39 @@ func TestAddFileWithEmptyName(t *testing.T) {
 	context.TestBadRequest(t, rr, "missing file name from multipart form")
 }
 
+func TestAddFileWithDefaultFilename(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DefaultFilename = "upload-{fileId}.txt"
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData("", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	file := &common.File{}
+	err = json.Unmarshal(respBody, file)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "upload-"+file.ID+".txt", file.Name, "invalid file name")
+
+	file, err = ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, "upload-"+file.ID+".txt", file.Name, "invalid file name")
+}
+
 func TestAddFileWithInvalidFieldName(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -565,6 +840,44 @@ func TestAddFileQuickDownloadDomain(t *testing.T) {
 	require.Equal(t, url, string(respBody), "invalid url")
 }
 
+func TestAddFileQuickPinnedDownloadDomain(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadDomain = "https://plik.root.gg"
+	config.DownloadDomains = []string{"https://team.root.gg"}
+	err := config.Initialize()
+	require.NoError(t, err, "config initialization error")
+
+	ctx := newTestingContext(config)
+	ctx.SetQuick(true)
+
+	upload := &common.Upload{IsAdmin: true, DownloadDomain: "https://team.root.gg"}
+	createTestUpload(t, ctx, upload)
+
+	name := "file"
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err, "unable to get upload files")
+	require.Len(t, files, 1, "missing file")
+
+	url := fmt.Sprintf("https://team.root.gg/file/%s/%s/%s\n", upload.ID, files[0].ID, name)
+
+	require.Equal(t, url, string(respBody), "invalid url")
+}
+
 func TestAddFileTooBig(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().MaxFileSize = 5
@@ -586,3 +899,284 @@ func TestAddFileTooBig(t *testing.T) {
 
 	context.TestBadRequest(t, rr, "file too big")
 }
+
+func TestAddFileTooBigUploadOwner(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	// The file is added with the upload token by someone else than the upload owner
+	owner := common.NewUser(common.ProviderLocal, "owner")
+	owner.MaxFileSize = 5
+	err := ctx.GetMetadataBackend().CreateUser(owner)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{IsAdmin: true, User: owner.ID}
+	createTestUpload(t, ctx, upload)
+
+	name := "file"
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "file too big")
+}
+
+func TestAddFileUploadOwnerLimit(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().MaxFileSize = 5
+
+	// The upload owner limit applies rather than the default one
+	owner := common.NewUser(common.ProviderLocal, "owner")
+	owner.MaxFileSize = 1024
+	err := ctx.GetMetadataBackend().CreateUser(owner)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{IsAdmin: true, User: owner.ID}
+	createTestUpload(t, ctx, upload)
+
+	name := "file"
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+
+	context.TestOK(t, rr)
+}
+
+// corruptingBackend returns different data than what was written
+type corruptingBackend struct {
+	*data_test.Backend
+}
+
+func (b *corruptingBackend) GetFile(file *common.File) (reader io.ReadCloser, err error) {
+	return ioutil.NopCloser(bytes.NewBufferString("corrupted")), nil
+}
+
+func TestAddFileVerifyAfterWrite(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().VerifyAfterWrite = true
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+	require.Equal(t, contentMD5, f.Md5, "invalid file md5")
+}
+
+func TestAddFileVerifyAfterWriteCorrupted(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().VerifyAfterWrite = true
+
+	backend := &corruptingBackend{Backend: data_test.NewBackend()}
+	ctx.SetDataBackend(backend)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestInternalServerError(t, rr, "unable to verify file")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileMissing, f.Status, "invalid file status")
+	require.NotContains(t, backend.GetFiles(), file.ID, "corrupted file should have been removed")
+}
+
+func TestAddFileUploadProgress(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	broker := common.NewUploadProgressBroker()
+	ctx.SetUploadProgressBroker(broker)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	progressCh, snapshot, cancel := broker.Subscribe(upload.ID)
+	defer cancel()
+	require.Len(t, snapshot, 0, "no file should be in flight")
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var last *common.UploadProgress
+	for last == nil || !last.Done {
+		last = <-progressCh
+		require.Equal(t, file.ID, last.FileID, "invalid file id")
+	}
+	require.Equal(t, int64(len(content)), last.Received, "invalid received bytes")
+	require.False(t, broker.IsInFlight(upload.ID), "file should not be in flight anymore")
+}
+
+func getMultipartFormDataWithChecksum(name string, md5sum string, in io.Reader) (out io.Reader, contentType string, err error) {
+	buffer := new(bytes.Buffer)
+	multipartWriter := multipart.NewWriter(buffer)
+
+	writer, err := multipartWriter.CreateFormFile("file", name)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create multipartWriter : %s", err)
+	}
+
+	_, err = io.Copy(writer, in)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The checksum of streamed data is sent after the file
+	err = multipartWriter.WriteField("md5", md5sum)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = multipartWriter.Close()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return buffer, multipartWriter.FormDataContentType(), nil
+}
+
+func TestAddFileChecksumHeader(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().RequireUploadChecksum = true
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+	req.Header.Set("X-Plik-Md5", contentMD5)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestAddFileChecksumField(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().RequireUploadChecksum = true
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormDataWithChecksum(file.Name, contentMD5, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestAddFileChecksumMismatch(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	backend := data_test.NewBackend()
+	ctx.SetDataBackend(backend)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormDataWithChecksum(file.Name, "d41d8cd98f00b204e9800998ecf8427e", bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusUnprocessableEntity, "does not match the expected md5sum")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileMissing, f.Status, "invalid file status")
+	require.NotContains(t, backend.GetFiles(), file.ID, "corrupted file should not be stored")
+}
+
+func TestAddFileChecksumMissing(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().RequireUploadChecksum = true
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing file md5 checksum")
+}
+
+func TestAddFileChecksumInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, bytes.NewBuffer([]byte(content)))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+	req.Header.Set("X-Plik-Md5", "foo")
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid md5 checksum foo")
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// maxUploadParamsSize is the maximum size of the JSON upload params sent in the "upload" form field
+const maxUploadParamsSize = 1048576
+
+// CreateUploadWithFiles create an upload and add all the files of the multipart request in a single round-trip.
+// The upload params may be sent as JSON in an "upload" form field before the files
+func CreateUploadWithFiles(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	if !ctx.IsWhitelisted() {
+		ctx.Forbidden("untrusted source IP address")
+		return
+	}
+
+	multiPartReader, err := req.MultipartReader()
+	if err != nil {
+		ctx.InvalidParameter("multipart form : %s", err)
+		return
+	}
+
+	part, err := multiPartReader.NextPart()
+	if err != nil && err != io.EOF {
+		ctx.InvalidParameter("multipart form : %s", err)
+		return
+	}
+
+	var body []byte
+	if part != nil && part.FormName() == "upload" {
+		body, err = ioutil.ReadAll(io.LimitReader(part, maxUploadParamsSize+1))
+		if err != nil {
+			ctx.BadRequest("unable to read upload form field : %s", err)
+			return
+		}
+		if len(body) > maxUploadParamsSize {
+			ctx.BadRequest("upload form field is too large, maximum size is %d bytes", maxUploadParamsSize)
+			return
+		}
+		part = nil
+	}
+
+	// Deserialize json upload params
+	uploadParams, version, err := getUploadParams(ctx, body)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize upload form field : %s", err)
+		return
+	}
+
+	// Anonymous uploads may have to solve a CAPTCHA
+	err = ctx.VerifyCaptcha(req)
+	if err != nil {
+		ctx.Forbidden("%s", err)
+		return
+	}
+
+	err = ctx.CheckTOSAcceptance(req)
+	if err != nil {
+		ctx.TOSNotAccepted(err)
+		return
+	}
+
+	upload, ok := createUpload(ctx, uploadParams)
+	if !ok {
+		return
+	}
+
+	// Streaming uploads block until each file is downloaded
+	if upload.Stream {
+		ctx.BadRequest("unable to create upload : stream uploads can't add several files in a single request")
+		return
+	}
+
+	// Update request logger prefix
+	prefix := fmt.Sprintf("%s[%s]", log.Prefix, upload.ID)
+	log.SetPrefix(prefix)
+	ctx.SetUpload(upload)
+
+	// Save the upload to the metadata database
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	if err != nil {
+		ctx.InternalServerError("create upload error", err)
+		return
+	}
+
+	// You are always admin of your own uploads
+	upload.IsAdmin = true
+
+	_, ok = addFiles(ctx, upload, multiPartReader, part)
+	if !ok {
+		return
+	}
+
+	upload.Files, err = ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return
+	}
+
+	if upload.ProtectedByPassword {
+		// Add Authorization header to the response for convenience
+		header := common.EncodeAuthBasicHeader(uploadParams.Login, uploadParams.Password)
+		resp.Header().Add("Authorization", "Basic "+header)
+	}
+
+	writeCreatedUpload(ctx, resp, upload, version)
+}
+
+// AddFiles add all the files of the multipart request to an existing upload in a single round-trip
+func AddFiles(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Check authorization
+	if !upload.IsAdmin {
+		ctx.Forbidden("you are not allowed to add file to this upload")
+		return
+	}
+
+	if upload.Stream {
+		ctx.BadRequest("stream uploads can't add several files in a single request")
+		return
+	}
+
+	multiPartReader, err := req.MultipartReader()
+	if err != nil {
+		ctx.InvalidParameter("multipart form : %s", err)
+		return
+	}
+
+	files, ok := addFiles(ctx, upload, multiPartReader, nil)
+	if !ok {
+		return
+	}
+
+	// Remove all private information (ip, data backend details, ...) before
+	// sending metadata back to the client
+	for _, file := range files {
+		file.Sanitize()
+	}
+
+	common.WriteJSONResponse(resp, files)
+}
+
+// addFiles stream the "file" parts of the multipart request to the data backend one after the other.
+// Files declared with the upload are matched by name, other files are created. The expected md5sum of a file
+// may be sent in a "md5" form field before or after the file part. The maximum file size and the user storage
+// quota apply to the whole request.
+// The response is written and false is returned if a file can't be saved, the files saved so far are kept
+func addFiles(ctx *context.Context, upload *common.Upload, multiPartReader *multipart.Reader, part *multipart.Part) (files []*common.File, ok bool) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	declared, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return nil, false
+	}
+
+	// Files may be added by someone else than the upload owner using the upload token
+	maxFileSize, err := ctx.GetUploadMaxFileSize(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload owner", err)
+		return nil, false
+	}
+
+	quotaLeft, err := ctx.GetUserQuotaLeft(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to get user storage quota", err)
+		return nil, false
+	}
+
+	prefix := log.Prefix
+	defer log.SetPrefix(prefix)
+
+	var expectedMd5 string
+	eof := false
+	for {
+		if part == nil {
+			if eof {
+				break
+			}
+			part, err = multiPartReader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				ctx.InvalidParameter("multipart form : %s", err)
+				return nil, false
+			}
+		}
+
+		current := part
+		part = nil
+
+		if current.FormName() == "md5" {
+			expectedMd5, err = readExpectedChecksum(current)
+			if err != nil {
+				handleHTTPError(ctx, err)
+				return nil, false
+			}
+			continue
+		}
+		if current.FormName() != "file" {
+			continue
+		}
+
+		// Files uploaded without a name are named from the DefaultFilename template
+		fileName := current.FileName()
+		if fileName == "" && config.DefaultFilename == "" {
+			ctx.MissingParameter("file name from multipart form")
+			return nil, false
+		}
+
+		var file *common.File
+		file, declared = takeDeclaredFile(declared, fileName)
+
+		authorizationFile := file
+		if authorizationFile == nil {
+			authorizationFile = &common.File{Name: fileName}
+		}
+		if !checkAuthorization(ctx, common.AuthorizationActionUpload, upload, authorizationFile) {
+			return nil, false
+		}
+
+		file, ok = prepareFile(ctx, upload, file, fileName, current.Header.Get("Content-Encoding"))
+		if !ok {
+			return nil, false
+		}
+
+		// Update request logger prefix
+		log.SetPrefix(fmt.Sprintf("%s[%s]", prefix, file.Name))
+
+		limit := maxFileSize
+		if quotaLeft >= 0 && quotaLeft < limit {
+			limit = quotaLeft
+		}
+
+		fileMd5 := expectedMd5
+		expectedMd5 = ""
+		checkMd5 := func(md5sum string) (err error) {
+			// The part following the file is kept for the next iteration if it is not its md5sum
+			if fileMd5 == "" {
+				next, errPart := multiPartReader.NextPart()
+				if errPart == io.EOF {
+					eof = true
+				} else if errPart == nil && next.FormName() == "md5" {
+					fileMd5, err = readExpectedChecksum(next)
+					if err != nil {
+						return err
+					}
+				} else if errPart == nil {
+					part = next
+				}
+			}
+			return compareFileChecksum(fileMd5, md5sum, config.RequireUploadChecksum)
+		}
+
+		if !saveFile(ctx, upload, file, current, limit, checkMd5) {
+			return nil, false
+		}
+
+		if quotaLeft >= 0 {
+			quotaLeft -= file.Size
+		}
+
+		files = append(files, file)
+
+		extracted, ok := expandArchive(ctx, upload, file)
+		if !ok {
+			return nil, false
+		}
+		for _, f := range extracted {
+			if quotaLeft >= 0 {
+				quotaLeft -= f.Size
+			}
+		}
+		files = append(files, extracted...)
+	}
+
+	if len(files) == 0 {
+		ctx.MissingParameter("file from multipart form")
+		return nil, false
+	}
+
+	return files, true
+}
+
+// takeDeclaredFile return the first declared file with this name still waiting to be uploaded
+// and the remaining declared files
+func takeDeclaredFile(declared []*common.File, name string) (*common.File, []*common.File) {
+	for i, file := range declared {
+		if file.Name == name && file.Status == common.FileMissing {
+			return file, append(declared[:i:i], declared[i+1:]...)
+		}
+	}
+	return nil, declared
+}
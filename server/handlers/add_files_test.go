@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// getMultiFileFormData build a multipart form from { field name, file name, value } parts, the file name is empty for form fields
+func getMultiFileFormData(t *testing.T, parts ...[3]string) (out io.Reader, contentType string) {
+	buffer := new(bytes.Buffer)
+	multipartWriter := multipart.NewWriter(buffer)
+
+	for _, part := range parts {
+		var writer io.Writer
+		var err error
+		if part[1] == "" {
+			writer, err = multipartWriter.CreateFormField(part[0])
+		} else {
+			writer, err = multipartWriter.CreateFormFile(part[0], part[1])
+		}
+		require.NoError(t, err, "unable to create multipart part")
+
+		_, err = writer.Write([]byte(part[2]))
+		require.NoError(t, err, "unable to write multipart part")
+	}
+
+	require.NoError(t, multipartWriter.Close(), "unable to close multipart writer")
+
+	return buffer, multipartWriter.FormDataContentType()
+}
+
+func getUploadedFile(t *testing.T, ctx *context.Context, file *common.File) string {
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	require.NoError(t, err, "unable to get file %s", file.Name)
+	defer func() { _ = reader.Close() }()
+
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read file %s", file.Name)
+
+	return string(data)
+}
+
+func TestCreateUploadWithFiles(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"upload", "", `{"ttl":3600,"files":[{"fileName":"a.txt","reference":"0"}]}`},
+		[3]string{"file", "a.txt", "aaa"},
+		[3]string{"file", "b.txt", content},
+		[3]string{"md5", "", contentMD5},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWithFiles(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	upload := &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.NotEqual(t, "", upload.ID, "missing upload id")
+	require.NotEqual(t, "", upload.UploadToken, "missing upload token")
+	require.Equal(t, 3600, upload.TTL, "invalid upload TTL")
+	require.Len(t, upload.Files, 2, "invalid upload files")
+
+	for _, file := range upload.Files {
+		require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+		file.UploadID = upload.ID
+		switch file.Name {
+		case "a.txt":
+			require.Equal(t, "0", file.Reference, "declared file expected")
+			require.Equal(t, "aaa", getUploadedFile(t, ctx, file))
+		case "b.txt":
+			require.Equal(t, contentMD5, file.Md5, "invalid file md5")
+			require.Equal(t, content, getUploadedFile(t, ctx, file))
+		default:
+			require.Fail(t, "unexpected file "+file.Name)
+		}
+	}
+}
+
+func TestCreateUploadWithFilesDefaultParams(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	reader, contentType := getMultiFileFormData(t, [3]string{"file", "file.txt", content})
+	req, err := http.NewRequest("POST", "/upload/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWithFiles(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	upload := &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, ctx.GetConfig().DefaultTTL, upload.TTL, "invalid upload TTL")
+	require.Len(t, upload.Files, 1, "invalid upload files")
+}
+
+func TestCreateUploadWithFilesStream(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"upload", "", `{"stream":true}`},
+		[3]string{"file", "file.txt", content},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWithFiles(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to create upload : stream uploads can't add several files in a single request")
+}
+
+func TestCreateUploadWithFilesNotWhitelisted(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetWhitelisted(false)
+
+	req, err := http.NewRequest("POST", "/upload/files", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadWithFiles(ctx, rr, req)
+	context.TestForbidden(t, rr, "untrusted source IP address")
+}
+
+func TestAddFiles(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"md5", "", contentMD5},
+		[3]string{"file", "a.txt", content},
+		[3]string{"comment", "", "ignored"},
+		[3]string{"file", "b.txt", "bbb"},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var files []*common.File
+	err = json.Unmarshal(rr.Body.Bytes(), &files)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, files, 2, "invalid files")
+
+	require.Equal(t, "a.txt", files[0].Name, "invalid file name")
+	require.Equal(t, contentMD5, files[0].Md5, "invalid file md5")
+	require.Equal(t, "b.txt", files[1].Name, "invalid file name")
+	require.Equal(t, int64(3), files[1].Size, "invalid file size")
+
+	for _, file := range files {
+		require.Equal(t, common.FileUploaded, file.Status, "invalid file status")
+	}
+}
+
+func TestAddFilesTooManyFiles(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFilePerUpload = 1
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"file", "a.txt", content},
+		[3]string{"file", "b.txt", content},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestBadRequest(t, rr, "maximum number file per upload reached, limit is 1")
+
+	// The files received before are kept
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 1, "invalid files")
+	require.Equal(t, common.FileUploaded, files[0].Status, "invalid file status")
+}
+
+func TestAddFilesUserQuota(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUserSize = 20
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true, User: "user"}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"file", "a.txt", content},
+		[3]string{"file", "b.txt", content},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestBadRequest(t, rr, "file too big (limit is set to 6 B)")
+}
+
+func TestAddFilesChecksumMismatch(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"file", "a.txt", content},
+		[3]string{"md5", "", "00000000000000000000000000000000"},
+		[3]string{"file", "b.txt", content},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusUnprocessableEntity, "file md5sum "+contentMD5+" does not match the expected md5sum 00000000000000000000000000000000")
+}
+
+func TestAddFilesRequireChecksum(t *testing.T) {
+	config := common.NewConfiguration()
+	config.RequireUploadChecksum = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"file", "a.txt", content},
+		[3]string{"md5", "", contentMD5},
+		[3]string{"file", "b.txt", content},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing file md5 checksum ( X-Plik-Md5 header or md5 form field )")
+}
+
+func TestAddFilesMissingFile(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t, [3]string{"md5", "", contentMD5})
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestBadRequest(t, rr, "missing file from multipart form")
+}
+
+func TestAddFilesNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to add file to this upload")
+}
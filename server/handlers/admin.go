@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
 
 	"github.com/root-gg/plik/server/common"
 
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
 )
 
 // GetUsers return users
@@ -39,12 +46,218 @@ func GetServerStatistics(ctx *context.Context, resp http.ResponseWriter, req *ht
 		return
 	}
 
+	// Only take into account uploads created since a date ( RFC3339 ) or a duration ago ( ex: 24h )
+	var since *time.Time
+	if value := req.URL.Query().Get("since"); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			t := time.Now().Add(-duration)
+			since = &t
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+			since = &t
+		} else {
+			ctx.InvalidParameter("since")
+			return
+		}
+	}
+
 	// Get server statistics
-	stats, err := ctx.GetMetadataBackend().GetServerStatistics()
+	stats, err := ctx.GetMetadataBackend().GetServerStatistics(since)
 	if err != nil {
 		ctx.InternalServerError("unable to get server statistics : %s", err)
 		return
 	}
 
+	stats.DataBackend.Name = ctx.GetConfig().DataBackend
+	if ctx.GetConfig().DataBackendCircuitBreakerThreshold > 0 {
+		stats.DataBackend.CircuitBreaker = data.GetCircuitState(ctx.GetDataBackend())
+	}
+	stats.StreamBackend.Name = "stream"
+
+	for _, app := range ctx.GetConfig().ClientApps {
+		appStats, err := ctx.GetMetadataBackend().GetClientAppStatistics(app.Name, since)
+		if err != nil {
+			ctx.InternalServerError("unable to get client app statistics", err)
+			return
+		}
+		appStats.Requests, appStats.RateLimited = app.GetRequestCounts()
+		stats.ClientApps = append(stats.ClientApps, appStats)
+	}
+
 	common.WriteJSONResponse(resp, stats)
 }
+
+// ServerBanner is the request body to update the server banner
+type ServerBanner struct {
+	Banner string `json:"banner"`
+}
+
+// SetServerBanner update the server banner at runtime, overriding the ServerBanner configuration
+func SetServerBanner(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest(fmt.Sprintf("unable to read request body : %s", err))
+		return
+	}
+
+	banner := &ServerBanner{}
+	err = json.Unmarshal(body, banner)
+	if err != nil {
+		ctx.BadRequest(fmt.Sprintf("unable to deserialize request body : %s", err))
+		return
+	}
+
+	metadataBackend := ctx.GetMetadataBackend()
+	setting, err := metadataBackend.GetSetting(common.ServerBannerSettingKey)
+	if err != nil {
+		ctx.InternalServerError("unable to get server banner", err)
+		return
+	}
+
+	if setting == nil {
+		err = metadataBackend.CreateSetting(&common.Setting{Key: common.ServerBannerSettingKey, Value: banner.Banner})
+	} else {
+		err = metadataBackend.UpdateSetting(common.ServerBannerSettingKey, setting.Value, banner.Banner)
+	}
+	if err != nil {
+		ctx.InternalServerError("unable to save server banner", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, banner)
+}
+
+// ResetServerBanner remove the server banner set at runtime to restore the ServerBanner configuration
+func ResetServerBanner(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	err := ctx.GetMetadataBackend().DeleteSetting(common.ServerBannerSettingKey)
+	if err != nil {
+		ctx.InternalServerError("unable to remove server banner", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// PurgeUserUploadsBatchSize is the number of uploads removed per metadata transaction when purging user uploads
+const PurgeUserUploadsBatchSize = 100
+
+// UserErasure is the result of a user uploads purge
+type UserErasure struct {
+	Uploads     int  `json:"uploads"`
+	Files       int  `json:"files"`
+	Errors      int  `json:"errors"`
+	UserDeleted bool `json:"userDeleted"`
+}
+
+// PurgeUserUploads delete all the uploads data and metadata of a user and optionally the user account itself
+func PurgeUserUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+
+	// Check authorization
+	if !ctx.IsAdmin() {
+		ctx.Forbidden("you need administrator privileges")
+		return
+	}
+
+	userID := mux.Vars(req)["userID"]
+	if userID == "" {
+		ctx.MissingParameter("user ID")
+		return
+	}
+
+	user, err := ctx.GetMetadataBackend().GetUser(userID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user", err)
+		return
+	}
+	if user == nil {
+		ctx.NotFound("user not found")
+		return
+	}
+
+	erasure := &UserErasure{}
+	metadataBackend := ctx.GetMetadataBackend()
+	dataBackend := ctx.GetDataBackend()
+
+	// Remove the uploads in batches to avoid locking the database for too long
+	for {
+		uploadIDs, err := metadataBackend.RemoveUserUploadsBatch(userID, PurgeUserUploadsBatchSize)
+		if err != nil {
+			ctx.InternalServerError("unable to remove user uploads", err)
+			return
+		}
+		if len(uploadIDs) == 0 {
+			break
+		}
+		erasure.Uploads += len(uploadIDs)
+
+		// Delete the data now rather than waiting for the cleaning routine
+		// Files that can't be deleted are left removed and will be retried by the cleaning routine
+		for _, uploadID := range uploadIDs {
+			files, err := metadataBackend.GetFiles(uploadID)
+			if err != nil {
+				ctx.InternalServerError("unable to get user upload files", err)
+				return
+			}
+
+			purged := true
+			for _, file := range files {
+				if file.Status != common.FileRemoved {
+					continue
+				}
+
+				err = data.RemoveFile(dataBackend, file)
+				if err == nil {
+					err = metadataBackend.UpdateFileStatus(file, common.FileRemoved, common.FileDeleted)
+				}
+				if err != nil {
+					log.Warningf("unable to delete file %s/%s : %s", file.UploadID, file.ID, err)
+					erasure.Errors++
+					purged = false
+					continue
+				}
+
+				erasure.Files++
+			}
+
+			if purged {
+				err = metadataBackend.DeleteRemovedUpload(uploadID)
+				if err != nil {
+					log.Warningf("unable to delete upload %s : %s", uploadID, err)
+					erasure.Errors++
+				}
+			}
+		}
+	}
+
+	if req.URL.Query().Get("deleteUser") == "true" {
+		erasure.UserDeleted, err = metadataBackend.DeleteUser(userID)
+		if err != nil {
+			ctx.InternalServerError("unable to delete user", err)
+			return
+		}
+	}
+
+	// Keep a trace of the erasure
+	log.Warningf("user %s erased by admin %s : %d uploads and %d files deleted, %d errors, user deleted : %t",
+		userID, ctx.GetUser().ID, erasure.Uploads, erasure.Files, erasure.Errors, erasure.UserDeleted)
+
+	common.WriteJSONResponse(resp, erasure)
+}
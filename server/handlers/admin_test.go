@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
@@ -154,6 +157,89 @@ func TestGetServerStatistics(t *testing.T) {
 	require.Equal(t, int64(50), stats.TotalSize, "invalid total file size")
 	require.Equal(t, 10, stats.AnonymousUploads, "invalid anonymous upload count")
 	require.Equal(t, int64(20), stats.AnonymousSize, "invalid anonymous total file size")
+	require.Equal(t, 20, stats.UploadsToday, "invalid today upload count")
+	require.NotNil(t, stats.DataBackend, "missing data backend statistics")
+	require.Equal(t, ctx.GetConfig().DataBackend, stats.DataBackend.Name, "invalid data backend name")
+	require.Equal(t, 20, stats.DataBackend.Uploads, "invalid data backend upload count")
+	require.NotNil(t, stats.StreamBackend, "missing stream backend statistics")
+	require.Equal(t, 0, stats.StreamBackend.Uploads, "invalid stream backend upload count")
+}
+
+func TestGetServerStatisticsClientApps(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	app := &common.ClientApp{Name: "backup", Key: "secret", RateLimit: 1}
+	ctx.GetConfig().ClientApps = []*common.ClientApp{app}
+	app.Allow()
+	app.Allow()
+
+	upload := &common.Upload{ClientApp: "backup"}
+	file := upload.NewFile()
+	file.Size = 2
+	file.Status = common.FileUploaded
+	upload.InitializeForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create error")
+
+	req, err := http.NewRequest("GET", "/stats", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetServerStatistics(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var stats *common.ServerStats
+	err = json.Unmarshal(rr.Body.Bytes(), &stats)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, stats.ClientApps, 1, "invalid client app statistics")
+	require.Equal(t, "backup", stats.ClientApps[0].Name, "invalid client app name")
+	require.Equal(t, 1, stats.ClientApps[0].Uploads, "invalid client app upload count")
+	require.Equal(t, int64(2), stats.ClientApps[0].TotalSize, "invalid client app total file size")
+	require.Equal(t, int64(2), stats.ClientApps[0].Requests, "invalid client app request count")
+	require.Equal(t, int64(1), stats.ClientApps[0].RateLimited, "invalid client app rate limited count")
+}
+
+func TestGetServerStatisticsSince(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	upload.CreatedAt = time.Now().Add(-48 * time.Hour)
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create error")
+
+	upload = &common.Upload{}
+	upload.InitializeForTests()
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create error")
+
+	for _, since := range []string{"24h", time.Now().Add(-24 * time.Hour).Format(time.RFC3339)} {
+		req, err := http.NewRequest("GET", "/stats?since="+url.QueryEscape(since), bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetServerStatistics(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		var stats *common.ServerStats
+		err = json.Unmarshal(rr.Body.Bytes(), &stats)
+		require.NoError(t, err, "unable to unmarshal response body")
+		require.Equal(t, 1, stats.Uploads, "invalid upload count")
+	}
+}
+
+func TestGetServerStatisticsInvalidSince(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	req, err := http.NewRequest("GET", "/stats?since=yesterday", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetServerStatistics(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid since")
 }
 
 func TestGetServerStatisticsNoUser(t *testing.T) {
@@ -199,3 +285,210 @@ func TestGetServerStatisticsMetadataBackendError(t *testing.T) {
 
 	context.TestInternalServerError(t, rr, "database is closed")
 }
+
+func TestSetServerBanner(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ServerBanner = "default banner"
+	ctx := newTestingContext(config)
+	createAdminUser(t, ctx)
+
+	for _, banner := range []string{"maintenance tonight", ""} {
+		req, err := http.NewRequest("POST", "/banner", bytes.NewBufferString(`{"banner":"`+banner+`"}`))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		SetServerBanner(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		setting, err := ctx.GetMetadataBackend().GetSetting(common.ServerBannerSettingKey)
+		require.NoError(t, err, "unable to get server banner setting")
+		require.NotNil(t, setting, "missing server banner setting")
+		require.Equal(t, banner, setting.Value, "invalid server banner")
+
+		req, err = http.NewRequest("GET", "/config", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr = ctx.NewRecorder(req)
+		GetConfiguration(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		var result *common.Configuration
+		err = json.Unmarshal(rr.Body.Bytes(), &result)
+		require.NoError(t, err, "unable to unmarshal response body")
+		require.Equal(t, banner, result.ServerBanner, "invalid server banner")
+	}
+
+	require.Equal(t, "default banner", config.ServerBanner, "configuration has been modified")
+}
+
+func TestSetServerBannerNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/banner", bytes.NewBufferString(`{"banner":"foo"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	SetServerBanner(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestSetServerBannerInvalidBody(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	req, err := http.NewRequest("POST", "/banner", bytes.NewBufferString("foo"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	SetServerBanner(ctx, rr, req)
+	context.TestBadRequest(t, rr, "unable to deserialize request body")
+}
+
+func TestResetServerBanner(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ServerBanner = "default banner"
+	ctx := newTestingContext(config)
+	createAdminUser(t, ctx)
+
+	err := ctx.GetMetadataBackend().CreateSetting(&common.Setting{Key: common.ServerBannerSettingKey, Value: "foo"})
+	require.NoError(t, err, "unable to create server banner setting")
+
+	req, err := http.NewRequest("DELETE", "/banner", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ResetServerBanner(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	setting, err := ctx.GetMetadataBackend().GetSetting(common.ServerBannerSettingKey)
+	require.NoError(t, err, "unable to get server banner setting")
+	require.Nil(t, setting, "server banner setting has not been removed")
+
+	req, err = http.NewRequest("GET", "/config", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	GetConfiguration(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var result *common.Configuration
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "default banner", result.ServerBanner, "invalid server banner")
+}
+
+func TestResetServerBannerNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("DELETE", "/banner", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ResetServerBanner(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
+
+func TestPurgeUserUploads(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	var files []*common.File
+	for i := 0; i < PurgeUserUploadsBatchSize+1; i++ {
+		upload := &common.Upload{User: user.ID}
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+		createTestUpload(t, ctx, upload)
+		files = append(files, file)
+
+		err = ctx.GetDataBackend().AddFile(file, bytes.NewBufferString("data"))
+		require.NoError(t, err, "unable to add file")
+	}
+
+	other := &common.Upload{User: "other"}
+	createTestUpload(t, ctx, other)
+
+	req, err := http.NewRequest("DELETE", "/user/"+user.ID+"/uploads?deleteUser=true", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	PurgeUserUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	erasure := &UserErasure{}
+	err = json.Unmarshal(respBody, erasure)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, PurgeUserUploadsBatchSize+1, erasure.Uploads, "invalid upload count")
+	require.Equal(t, PurgeUserUploadsBatchSize+1, erasure.Files, "invalid file count")
+	require.Equal(t, 0, erasure.Errors, "invalid error count")
+	require.True(t, erasure.UserDeleted, "user should be deleted")
+
+	for _, file := range files {
+		_, err = ctx.GetDataBackend().GetFile(file)
+		require.Error(t, err, "file data should have been deleted")
+
+		f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Nil(t, f, "file metadata should have been deleted")
+	}
+
+	u, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, u, "user should have been deleted")
+
+	upload, err := ctx.GetMetadataBackend().GetUpload(other.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, upload, "other user upload should not have been removed")
+}
+
+func TestPurgeUserUploadsKeepUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	req, err := http.NewRequest("DELETE", "/user/"+user.ID+"/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": user.ID})
+
+	rr := ctx.NewRecorder(req)
+	PurgeUserUploads(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.NotNil(t, u, "user should not have been deleted")
+}
+
+func TestPurgeUserUploadsUserNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createAdminUser(t, ctx)
+
+	req, err := http.NewRequest("DELETE", "/user/foo/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"userID": "foo"})
+
+	rr := ctx.NewRecorder(req)
+	PurgeUserUploads(ctx, rr, req)
+	context.TestNotFound(t, rr, "user not found")
+}
+
+func TestPurgeUserUploadsNotAdmin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("DELETE", "/user/foo/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	PurgeUserUploads(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+}
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -12,7 +16,6 @@ import (
 // CreateUpload create a new upload
 func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	log := ctx.GetLogger()
-	config := ctx.GetConfig()
 
 	if !ctx.IsWhitelisted() {
 		ctx.Forbidden("untrusted source IP address")
@@ -29,23 +32,67 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 	}
 
 	// Deserialize json body
-	uploadParams := &common.Upload{}
-	version := 0
-	if len(body) > 0 {
-		version, err = common.UnmarshalUpload(body, uploadParams)
+	uploadParams, version, err := getUploadParams(ctx, body)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize request body : %s", err)
+		return
+	}
+
+	// Anonymous uploads may have to solve a CAPTCHA
+	err = ctx.VerifyCaptcha(req)
+	if err != nil {
+		ctx.Forbidden("%s", err)
+		return
+	}
+
+	err = ctx.CheckTOSAcceptance(req)
+	if err != nil {
+		ctx.TOSNotAccepted(err)
+		return
+	}
+
+	// Return the existing upload if the request is a retry
+	var idempotencyHash string
+	idempotencyKey := req.Header.Get("Idempotency-Key")
+	if idempotencyKey != "" {
+		if ctx.GetUser() == nil {
+			ctx.BadRequest("idempotency key is only available to authenticated users")
+			return
+		}
+
+		if len(idempotencyKey) > 255 {
+			ctx.InvalidParameter("idempotency key, maximum length is 255 characters")
+			return
+		}
+
+		idempotencyHash, err = getUploadParamsHash(uploadParams)
+		if err != nil {
+			ctx.InternalServerError("unable to hash upload params", err)
+			return
+		}
+
+		upload, err := getUploadByIdempotencyKey(ctx, idempotencyKey, idempotencyHash)
 		if err != nil {
-			ctx.BadRequest("unable to deserialize request body : %s", err)
+			handleHTTPError(ctx, err)
+			return
+		}
+		if upload != nil {
+			writeCreatedUpload(ctx, resp, upload, version)
 			return
 		}
 	}
 
 	// Create upload from user params
-	upload, err := ctx.CreateUpload(uploadParams)
-	if err != nil {
-		ctx.BadRequest("unable to create upload : %s", err)
+	upload, ok := createUpload(ctx, uploadParams)
+	if !ok {
 		return
 	}
 
+	if idempotencyKey != "" {
+		upload.IdempotencyKey = &idempotencyKey
+		upload.IdempotencyHash = idempotencyHash
+	}
+
 	// Update request logger prefix
 	prefix := fmt.Sprintf("%s[%s]", log.Prefix, upload.ID)
 	log.SetPrefix(prefix)
@@ -54,15 +101,29 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 	// Save the upload to the metadata database
 	err = ctx.GetMetadataBackend().CreateUpload(upload)
 	if err != nil {
+		if idempotencyKey != "" {
+			// A concurrent request might have created the upload in the meantime
+			existing, err := getUploadByIdempotencyKey(ctx, idempotencyKey, idempotencyHash)
+			if err == nil && existing != nil {
+				writeCreatedUpload(ctx, resp, existing, version)
+				return
+			}
+		}
+
 		ctx.InternalServerError("create upload error", err)
 		return
 	}
 
-	// You are admin of your own uploads
-	upload.IsAdmin = true
-
-	// Hide private information (IP, data backend details, User ID, Login/Password, ...)
-	upload.Sanitize(config)
+	// Make room for the upload in the user storage quota, the storage might have been used by another upload since the check
+	err = ctx.EvictUserUploads(upload)
+	if err != nil {
+		errRemove := ctx.GetMetadataBackend().RemoveUpload(upload.ID)
+		if errRemove != nil {
+			log.Warningf("unable to remove upload %s : %s", upload.ID, errRemove)
+		}
+		ctx.BadRequest("unable to create upload : %s", err)
+		return
+	}
 
 	if upload.ProtectedByPassword {
 		// Add Authorization header to the response for convenience
@@ -72,11 +133,180 @@ func CreateUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 		resp.Header().Add("Authorization", "Basic "+header)
 	}
 
+	writeCreatedUpload(ctx, resp, upload, version)
+}
+
+// createUpload create the upload from the client params and check that it can be saved ( authorization, user quota ).
+// The response is written and false is returned if it can't
+func createUpload(ctx *context.Context, uploadParams *common.Upload) (*common.Upload, bool) {
+	upload, err := ctx.CreateUpload(uploadParams)
+	if err != nil {
+		if errors.Is(err, common.ErrMetadataTooLarge) {
+			ctx.RequestEntityTooLarge("unable to create upload : %s", err)
+			return nil, false
+		}
+		ctx.BadRequest("unable to create upload : %s", err)
+		return nil, false
+	}
+
+	if !checkAuthorization(ctx, common.AuthorizationActionUpload, upload, nil) {
+		return nil, false
+	}
+
+	// The oldest uploads of the user are only evicted once the upload is saved
+	err = ctx.CheckUserQuota(upload)
+	if err != nil {
+		ctx.BadRequest("unable to create upload : %s", err)
+		return nil, false
+	}
+
+	return upload, true
+}
+
+// Get the upload previously created by the context user with the same idempotency key
+func getUploadByIdempotencyKey(ctx *context.Context, idempotencyKey string, idempotencyHash string) (upload *common.Upload, err error) {
+	upload, err = ctx.GetMetadataBackend().GetUploadByIdempotencyKey(ctx.GetUser().ID, idempotencyKey)
+	if err != nil {
+		return nil, common.NewHTTPError("unable to get upload", err, http.StatusInternalServerError)
+	}
+	if upload == nil {
+		return nil, nil
+	}
+
+	// Expired uploads might not have been cleaned yet, the key can be used again
+	if upload.IsExpired() {
+		err = ctx.GetMetadataBackend().ReleaseUploadIdempotencyKey(upload.ID)
+		if err != nil {
+			return nil, common.NewHTTPError("unable to release idempotency key", err, http.StatusInternalServerError)
+		}
+		return nil, nil
+	}
+
+	// A user authenticated with a token can only retry uploads created with such token
+	token := ctx.GetToken()
+	if token != nil && upload.Token != token.Token {
+		return nil, common.NewHTTPError("idempotency key already used", nil, http.StatusConflict)
+	}
+
+	// Uploads created before the params were hashed match any retry
+	if upload.IdempotencyHash != "" && upload.IdempotencyHash != idempotencyHash {
+		return nil, common.NewHTTPError("idempotency key already used with different upload params", nil, http.StatusUnprocessableEntity)
+	}
+
+	return upload, nil
+}
+
+// getUploadParamsHash return a hash of the upload params to tell apart retries from requests reusing an idempotency key
+// The params are hashed once deserialized so the JSON formatting and the API version of the request don't matter
+func getUploadParamsHash(params *common.Upload) (string, error) {
+	bytes, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeCreatedUpload(ctx *context.Context, resp http.ResponseWriter, upload *common.Upload, version int) {
+	// The API version negotiated with the Accept header takes precedence over the request body format
+	if ctx.GetAPIVersion() != 0 {
+		version = ctx.GetAPIVersion()
+	}
+
+	// You are admin of your own uploads
+	upload.IsAdmin = true
+
+	if secret := ctx.GetConfig().ManagementLinkSecret; secret != "" {
+		upload.ManagementURL = common.NewManagementLink(ctx.GetConfig(), upload.ID, common.ManagementActionRemove).GetURL(secret)
+	}
+
+	// Hide private information (IP, data backend details, User ID, Login/Password, ...)
+	upload.Sanitize(ctx.GetConfig())
+
 	// Print upload metadata in the json response.
 	bytes, err := common.MarshalUpload(upload, version)
 	if err != nil {
 		ctx.InternalServerError("unable to serialize upload", err)
+		return
 	}
 
 	_, _ = resp.Write(bytes)
 }
+
+// PrecheckUpload tells whether an upload would be accepted without creating anything
+// so clients can fail fast before sending large files
+func PrecheckUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	precheck := &common.UploadPrecheck{}
+
+	if !ctx.IsWhitelisted() {
+		precheck.Reason = "untrusted source IP address"
+		common.WriteJSONResponse(resp, precheck)
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	// Deserialize json body
+	uploadParams, _, err := getUploadParams(ctx, body)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize request body : %s", err)
+		return
+	}
+
+	// Run the same checks as the upload creation ( file size, number of files, TTL, ... ) but don't save anything
+	upload, err := ctx.CreateUpload(uploadParams)
+	if err != nil {
+		precheck.Reason = err.Error()
+		common.WriteJSONResponse(resp, precheck)
+		return
+	}
+
+	// The oldest uploads of the user would be evicted to make room for the upload
+	err = ctx.CheckUserQuota(upload)
+	if err != nil {
+		precheck.Reason = err.Error()
+		common.WriteJSONResponse(resp, precheck)
+		return
+	}
+
+	precheck.Accepted = true
+	common.WriteJSONResponse(resp, precheck)
+}
+
+// getUploadParams deserialize the upload params of the request body
+// The settings of the selected upload preset are used as default values
+func getUploadParams(ctx *context.Context, body []byte) (params *common.Upload, version int, err error) {
+	params = ctx.NewUploadParams()
+	if len(body) == 0 {
+		return params, 0, nil
+	}
+
+	version, err = common.UnmarshalUpload(body, params)
+	if err != nil {
+		return nil, version, err
+	}
+
+	// Unknown presets are reported when creating the upload
+	preset := ctx.GetConfig().GetUploadPreset(params.Preset)
+	if preset == nil {
+		return params, version, nil
+	}
+
+	// Client params override the preset settings that are not locked
+	params = ctx.NewUploadParams()
+	preset.Apply(params)
+	version, err = common.UnmarshalUpload(body, params)
+	if err != nil {
+		return nil, version, err
+	}
+
+	return params, version, nil
+}
@@ -3,8 +3,12 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
 
 	"testing"
 
@@ -45,9 +49,36 @@ func TestCreateUploadWithoutOptions(t *testing.T) {
 	require.NotEqual(t, "", upload.ID, "missing upload id")
 	require.NotEqual(t, "", upload.UploadToken, "missing upload token")
 	require.True(t, upload.IsAdmin, "invalid upload admin status") // You are always admin of your own upload
+	require.Equal(t, "", upload.ManagementURL, "management links are disabled")
 
 }
 
+func TestCreateUploadManagementURL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ManagementLinkSecret = "secret"
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	managementURL, err := url.Parse(upload.ManagementURL)
+	require.NoError(t, err, "invalid management URL")
+	require.Equal(t, "/upload/"+upload.ID, managementURL.Path, "invalid management URL path")
+
+	link, err := common.ParseManagementLink(config.ManagementLinkSecret, upload.ID, managementURL.Query())
+	require.NoError(t, err, "invalid management link")
+	require.Equal(t, common.ManagementActionRemove, link.Action, "invalid management link action")
+}
+
 func TestCreateUploadWithOptions(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -110,6 +141,88 @@ func TestCreateUploadWithOptions(t *testing.T) {
 	require.Equal(t, "Basic "+common.EncodeAuthBasicHeader("foo", "bar"), rr.Header().Get("Authorization"))
 }
 
+func TestCreateUploadWithServerDefaults(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FeatureOneShot = common.FeatureDefault
+	config.FeatureRemovable = common.FeatureDefault
+	ctx := newTestingContext(config)
+
+	createUpload := func(body string) *common.Upload {
+		req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte(body)))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		respBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+
+		var upload = &common.Upload{}
+		err = json.Unmarshal(respBody, upload)
+		require.NoError(t, err, "unable to unmarshal response body")
+
+		return upload
+	}
+
+	upload := createUpload("")
+	require.True(t, upload.OneShot, "invalid upload oneshot status")
+	require.True(t, upload.Removable, "invalid upload removable status")
+
+	upload = createUpload(`{"ttl":60}`)
+	require.True(t, upload.OneShot, "invalid upload oneshot status")
+	require.True(t, upload.Removable, "invalid upload removable status")
+
+	upload = createUpload(`{"oneShot":false,"removable":false}`)
+	require.False(t, upload.OneShot, "invalid upload oneshot status")
+	require.False(t, upload.Removable, "invalid upload removable status")
+}
+
+func TestCreateUploadWithPreset(t *testing.T) {
+	config := common.NewConfiguration()
+	config.UploadPresets = []*common.UploadPreset{
+		{Name: "ephemeral", TTL: 3600, OneShot: true},
+		{Name: "locked", TTL: 3600, OneShot: true, LockOneShot: true},
+	}
+	ctx := newTestingContext(config)
+
+	createUpload := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte(body)))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, rr, req)
+		return rr
+	}
+
+	// The preset settings are used as default values
+	rr := createUpload(`{"preset":"ephemeral"}`)
+	context.TestOK(t, rr)
+
+	var upload = &common.Upload{}
+	err := json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, "ephemeral", upload.Preset, "invalid upload preset")
+	require.Equal(t, 3600, upload.TTL, "invalid upload TTL")
+	require.True(t, upload.OneShot, "invalid upload oneshot status")
+
+	// Unlocked settings can be overridden by the client
+	rr = createUpload(`{"preset":"ephemeral","oneShot":false,"ttl":60}`)
+	context.TestOK(t, rr)
+
+	upload = &common.Upload{}
+	err = json.Unmarshal(rr.Body.Bytes(), upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, 60, upload.TTL, "invalid upload TTL")
+	require.False(t, upload.OneShot, "invalid upload oneshot status")
+
+	rr = createUpload(`{"preset":"locked","oneShot":false}`)
+	context.TestBadRequest(t, rr, "one shot setting is locked by upload preset locked")
+
+	rr = createUpload(`{"preset":"foo"}`)
+	context.TestBadRequest(t, rr, "invalid upload preset foo")
+}
+
 func TestCreateWithForbiddenOptions(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -195,6 +308,38 @@ func TestCreateNotWhitelisted(t *testing.T) {
 	context.TestForbidden(t, rr, "untrusted source IP address")
 }
 
+func TestCreateUploadCaptcha(t *testing.T) {
+	captcha := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprintf(resp, `{"success":%t}`, req.PostFormValue("response") == "valid")
+	}))
+	defer captcha.Close()
+
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().CaptchaProvider = common.CaptchaHCaptcha
+	ctx.GetConfig().CaptchaVerifyURL = captcha.URL
+
+	createUpload := func(response string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		if response != "" {
+			req.Header.Set(context.CaptchaResponseHeader, response)
+		}
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, rr, req)
+		return rr
+	}
+
+	context.TestForbidden(t, createUpload(""), "missing captcha response")
+	context.TestForbidden(t, createUpload("invalid"), "invalid captcha response")
+	context.TestOK(t, createUpload("valid"))
+
+	// Authenticated users bypass the CAPTCHA
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	context.TestOK(t, createUpload(""))
+}
+
 func TestCreateInvalidRequestBody(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -207,6 +352,146 @@ func TestCreateInvalidRequestBody(t *testing.T) {
 	context.TestBadRequest(t, rr, "unable to deserialize request body")
 }
 
+func TestCreateUploadWithIdempotencyKey(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	createUpload := func() *common.Upload {
+		req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set("Idempotency-Key", "key")
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		respBody, err := ioutil.ReadAll(rr.Body)
+		require.NoError(t, err, "unable to read response body")
+
+		var upload = &common.Upload{}
+		err = json.Unmarshal(respBody, upload)
+		require.NoError(t, err, "unable to unmarshal response body")
+
+		return upload
+	}
+
+	upload1 := createUpload()
+	upload2 := createUpload()
+	require.Equal(t, upload1.ID, upload2.ID, "invalid upload id")
+	require.Equal(t, upload1.UploadToken, upload2.UploadToken, "invalid upload token")
+	require.True(t, upload2.IsAdmin, "invalid upload admin status")
+
+	err = ctx.GetMetadataBackend().RemoveUpload(upload1.ID)
+	require.NoError(t, err, "unable to remove upload")
+
+	upload3 := createUpload()
+	require.NotEqual(t, upload1.ID, upload3.ID, "invalid upload id")
+}
+
+func TestCreateUploadWithIdempotencyKeyDifferentParams(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	createUpload := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/upload", bytes.NewBufferString(body))
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set("Idempotency-Key", "key")
+
+		rr := ctx.NewRecorder(req)
+		CreateUpload(ctx, rr, req)
+		return rr
+	}
+
+	context.TestOK(t, createUpload(`{"comments":"foo"}`))
+
+	// The JSON formatting does not matter
+	context.TestOK(t, createUpload(`{ "comments" : "foo" }`))
+
+	rr := createUpload(`{"comments":"bar"}`)
+	context.TestFail(t, rr, http.StatusUnprocessableEntity, "idempotency key already used with different upload params")
+}
+
+func TestCreateUploadWithIdempotencyKeyExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	// Expired uploads not cleaned yet don't block the key
+	key := "key"
+	expireAt := time.Now().Add(-time.Hour)
+	expired := &common.Upload{User: user.ID, IdempotencyKey: &key, ExpireAt: &expireAt}
+	createTestUpload(t, ctx, expired)
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Idempotency-Key", key)
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var upload = &common.Upload{}
+	err = json.Unmarshal(respBody, upload)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEqual(t, expired.ID, upload.ID, "invalid upload id")
+}
+
+func TestCreateUploadWithIdempotencyKeyAnonymous(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Idempotency-Key", "key")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "idempotency key is only available to authenticated users")
+}
+
+func TestCreateUploadWithIdempotencyKeyOtherToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	key := "key"
+	upload := &common.Upload{User: user.ID, IdempotencyKey: &key}
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetToken(token)
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Idempotency-Key", key)
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+
+	context.TestFail(t, rr, http.StatusConflict, "idempotency key already used")
+}
+
 type NeverEndingReader struct{}
 
 func (r *NeverEndingReader) Read(p []byte) (n int, err error) {
@@ -228,6 +513,240 @@ func TestCreateUpload_BodyTooBig(t *testing.T) {
 	context.TestBadRequest(t, rr, "request body too large")
 }
 
+func precheckTestUpload(t *testing.T, ctx *context.Context, body []byte) (precheck *common.UploadPrecheck) {
+	req, err := http.NewRequest("POST", "/upload/precheck", bytes.NewBuffer(body))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	PrecheckUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	precheck = &common.UploadPrecheck{}
+	err = json.Unmarshal(rr.Body.Bytes(), precheck)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	return precheck
+}
+
+func TestPrecheckUpload(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFileSize = 1000
+	ctx := newTestingContext(config)
+
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.Files = append(uploadToCreate.Files, &common.File{Name: "file", Size: 1000})
+
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	precheck := precheckTestUpload(t, ctx, reqBody)
+	require.True(t, precheck.Accepted, "upload should be accepted")
+	require.Empty(t, precheck.Reason, "invalid reason")
+
+	stats, err := ctx.GetMetadataBackend().GetServerStatistics(nil)
+	require.NoError(t, err, "unable to get server statistics")
+	require.Equal(t, 0, stats.Uploads, "upload has been created")
+}
+
+func TestPrecheckUploadFileTooBig(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFileSize = 1000
+	ctx := newTestingContext(config)
+
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.Files = append(uploadToCreate.Files, &common.File{Name: "file", Size: 1001})
+
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	precheck := precheckTestUpload(t, ctx, reqBody)
+	require.False(t, precheck.Accepted, "upload should not be accepted")
+	require.Contains(t, precheck.Reason, "file is too big", "invalid reason")
+}
+
+// createQuotaTestUploads create uploads of 100 bytes for the user from the oldest to the newest
+func createQuotaTestUploads(t *testing.T, ctx *context.Context, user *common.User, count int) (uploads []*common.Upload) {
+	for i := 0; i < count; i++ {
+		upload := &common.Upload{User: user.ID}
+		upload.CreatedAt = time.Now().Add(time.Duration(i-count) * time.Hour)
+		file := upload.NewFile()
+		file.Size = 100
+		file.Status = common.FileUploaded
+		createTestUpload(t, ctx, upload)
+		uploads = append(uploads, upload)
+	}
+	return uploads
+}
+
+func createQuotaTestRequest(t *testing.T, size int64) (req *http.Request) {
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.Files = append(uploadToCreate.Files, &common.File{Name: "file", Size: size})
+
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err = http.NewRequest("POST", "/upload", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+	return req
+}
+
+func TestCreateUploadUserQuotaReject(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.MaxUserSize = 300
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+	createQuotaTestUploads(t, ctx, user, 2)
+
+	rr := ctx.NewRecorder(createQuotaTestRequest(t, 100))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 100))
+	context.TestOK(t, rr)
+
+	rr = ctx.NewRecorder(createQuotaTestRequest(t, 100))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 100))
+	context.TestOK(t, rr) // Only the uploaded files are counted
+
+	createQuotaTestUploads(t, ctx, user, 1)
+
+	rr = ctx.NewRecorder(createQuotaTestRequest(t, 1))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 1))
+	context.TestBadRequest(t, rr, "unable to create upload : user storage quota exceeded (300 B used of 300 B)")
+
+	rr = ctx.NewRecorder(createQuotaTestRequest(t, 301))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 301))
+	context.TestBadRequest(t, rr, "unable to create upload : upload is too big (301 B), maximum user storage is 300 B")
+}
+
+func TestCreateUploadMetadataTooLarge(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadMetadataBytes = 10
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBufferString(`{"comments":"0123456789+"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusRequestEntityTooLarge, "unable to create upload : upload metadata too large (11 bytes), maximum size is 10 bytes")
+}
+
+func TestCreateUploadUserQuotaEvictOldest(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.MaxUserSize = 300
+	config.QuotaExceededPolicy = common.QuotaExceededEvictOldest
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+	uploads := createQuotaTestUploads(t, ctx, user, 3)
+
+	// The precheck does not evict anything
+	precheck := precheckTestUpload(t, ctx, []byte(`{"files":[{"fileName":"file","fileSize":150}]}`))
+	require.True(t, precheck.Accepted, "upload should be accepted")
+
+	rr := ctx.NewRecorder(createQuotaTestRequest(t, 150))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 150))
+	context.TestOK(t, rr)
+
+	for i, upload := range uploads {
+		result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+		require.NoError(t, err, "unable to get upload")
+		if i < 2 {
+			require.Nil(t, result, "oldest upload %d should have been evicted", i)
+		} else {
+			require.NotNil(t, result, "newest upload should not have been evicted")
+		}
+	}
+
+	// Uploads bigger than the quota are still rejected
+	precheck = precheckTestUpload(t, ctx, []byte(`{"files":[{"fileName":"file","fileSize":301}]}`))
+	require.False(t, precheck.Accepted, "upload should not be accepted")
+	require.Contains(t, precheck.Reason, "upload is too big", "invalid reason")
+}
+
+func TestCreateUploadUserQuotaEvictionWebhook(t *testing.T) {
+	events := make(chan *context.QuotaEvictionNotification, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		event := &context.QuotaEvictionNotification{}
+		err := json.NewDecoder(req.Body).Decode(event)
+		if err == nil {
+			events <- event
+		}
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.MaxUserSize = 300
+	config.QuotaExceededPolicy = common.QuotaExceededEvictOldest
+	config.QuotaEvictionWebhook = server.URL
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+	uploads := createQuotaTestUploads(t, ctx, user, 3)
+
+	rr := ctx.NewRecorder(createQuotaTestRequest(t, 150))
+	CreateUpload(ctx, rr, createQuotaTestRequest(t, 150))
+	context.TestOK(t, rr)
+
+	var evicted []string
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			require.Equal(t, context.QuotaEvictionEvent, event.Event, "invalid event")
+			require.Equal(t, user.ID, event.User, "invalid event user")
+			require.Equal(t, int64(100), event.Size, "invalid event size")
+			require.Equal(t, 1, event.Files, "invalid event files")
+			require.NotEmpty(t, event.EvictedBy, "missing new upload id")
+			evicted = append(evicted, event.UploadID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("missing eviction event")
+		}
+	}
+	require.ElementsMatch(t, []string{uploads[0].ID, uploads[1].ID}, evicted, "invalid evicted uploads")
+}
+
+func TestPrecheckUploadTooManyFiles(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFilePerUpload = 1
+	ctx := newTestingContext(config)
+
+	uploadToCreate := &common.Upload{}
+	uploadToCreate.Files = append(uploadToCreate.Files, &common.File{Name: "file1"}, &common.File{Name: "file2"})
+
+	reqBody, err := json.Marshal(uploadToCreate)
+	require.NoError(t, err, "unable to marshal request body")
+
+	precheck := precheckTestUpload(t, ctx, reqBody)
+	require.False(t, precheck.Accepted, "upload should not be accepted")
+	require.Equal(t, "too many files. maximum is 1", precheck.Reason, "invalid reason")
+}
+
+func TestPrecheckUploadNotWhitelisted(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetWhitelisted(false)
+
+	precheck := precheckTestUpload(t, ctx, []byte{})
+	require.False(t, precheck.Accepted, "upload should not be accepted")
+	require.Equal(t, "untrusted source IP address", precheck.Reason, "invalid reason")
+}
+
+func TestPrecheckUploadInvalidRequestBody(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/upload/precheck", bytes.NewBuffer([]byte("invalid request body")))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	PrecheckUpload(ctx, rr, req)
+
+	context.TestBadRequest(t, rr, "unable to deserialize request body")
+}
+
 //func TestCreateWithMetadataBackendError(t *testing.T) {
 //	ctx := newTestingContext(common.NewConfiguration())
 //	ctx.GetMetadataBackend().(*metadatadata_test.Backend).SetError(errors.New("metadata backend error"))
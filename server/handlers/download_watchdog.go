@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// errDownloadIdleTimeout is returned by the download watchdog writes once the download has been aborted
+var errDownloadIdleTimeout = errors.New("download idle timeout")
+
+// downloadWatchdog abort a download once no data has been written to the client for the DownloadIdleTimeout.
+// The download is stuck either reading the data backend or writing to a client that does not read anymore,
+// so it closes the data backend reader and expires the client connection deadline to unblock both.
+type downloadWatchdog struct {
+	writer  io.Writer
+	conn    net.Conn
+	ctx     *context.Context
+	timeout time.Duration
+
+	mu      sync.Mutex
+	reader  io.Closer // Data backend reader of the file being downloaded
+	writing bool      // Whether the download is waiting for the client
+	timer   *time.Timer
+	expired bool
+}
+
+// newDownloadWatchdog wraps the writer of the response to the client.
+// The watchdog is a no-op if DownloadIdleTimeout is not set.
+func newDownloadWatchdog(ctx *context.Context, req *http.Request, writer io.Writer) (w *downloadWatchdog) {
+	w = &downloadWatchdog{writer: writer, ctx: ctx, timeout: ctx.GetConfig().GetDownloadIdleTimeout()}
+	if w.timeout > 0 {
+		w.conn = common.GetConn(req.Context())
+		w.timer = time.AfterFunc(w.timeout, w.expire)
+	}
+	return w
+}
+
+// setReader set the data backend reader to close if the download is aborted
+func (w *downloadWatchdog) setReader(reader io.Closer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.reader = reader
+}
+
+func (w *downloadWatchdog) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expired = true
+
+	reason := "the data backend did not send any data"
+	if w.writing {
+		reason = "the client did not read any data"
+	}
+	w.ctx.GetLogger().Warningf("aborting download : %s for %s", reason, w.timeout)
+
+	if w.reader != nil {
+		_ = w.reader.Close()
+	}
+	if w.conn != nil {
+		_ = w.conn.SetDeadline(time.Now())
+	}
+}
+
+func (w *downloadWatchdog) isExpired() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.expired
+}
+
+func (w *downloadWatchdog) stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// Write reset the watchdog each time some data has been written to the client
+func (w *downloadWatchdog) Write(p []byte) (n int, err error) {
+	if w.timer == nil {
+		return w.writer.Write(p)
+	}
+
+	w.mu.Lock()
+	if w.expired {
+		w.mu.Unlock()
+		return 0, errDownloadIdleTimeout
+	}
+	w.writing = true
+	w.mu.Unlock()
+
+	n, err = w.writer.Write(p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writing = false
+	if w.expired {
+		return n, errDownloadIdleTimeout
+	}
+	if n > 0 {
+		w.timer.Reset(w.timeout)
+	}
+
+	return n, err
+}
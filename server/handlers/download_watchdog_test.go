@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestDownloadWatchdogDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	req := httptest.NewRequest("GET", "/", nil)
+
+	buffer := &bytes.Buffer{}
+	watchdog := newDownloadWatchdog(ctx, req, buffer)
+	defer watchdog.stop()
+
+	_, err := io.Copy(watchdog, bytes.NewBufferString("data"))
+	require.NoError(t, err)
+	require.Equal(t, "data", buffer.String())
+	require.False(t, watchdog.isExpired())
+}
+
+func TestDownloadWatchdogBackendIdle(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadIdleTimeout = "1"
+	err := config.Initialize()
+	require.NoError(t, err)
+
+	ctx := newTestingContext(config)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	buffer := &bytes.Buffer{}
+	watchdog := newDownloadWatchdog(ctx, req, buffer)
+	defer watchdog.stop()
+
+	reader, writer := io.Pipe()
+	watchdog.setReader(reader)
+
+	go func() {
+		_, _ = writer.Write([]byte("data"))
+		// Stall without closing the pipe
+	}()
+
+	start := time.Now()
+	_, err = io.Copy(watchdog, reader)
+	require.Error(t, err, "stalled download should be aborted")
+	require.True(t, watchdog.isExpired())
+	require.True(t, time.Since(start) < 5*time.Second, "download aborted too late")
+	require.Equal(t, "data", buffer.String())
+
+	_, err = watchdog.Write([]byte("data"))
+	require.Equal(t, errDownloadIdleTimeout, err)
+}
+
+func TestDownloadWatchdogReset(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadIdleTimeout = "1"
+	err := config.Initialize()
+	require.NoError(t, err)
+
+	ctx := newTestingContext(config)
+	req := httptest.NewRequest("GET", "/", nil)
+
+	buffer := &bytes.Buffer{}
+	watchdog := newDownloadWatchdog(ctx, req, buffer)
+	defer watchdog.stop()
+
+	for i := 0; i < 4; i++ {
+		time.Sleep(500 * time.Millisecond)
+		_, err = watchdog.Write([]byte("data"))
+		require.NoError(t, err, "slow but steady download should not be aborted")
+	}
+	require.False(t, watchdog.isExpired())
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+)
+
+// expandArchive extract the regular files of an uploaded zip or tar archive as separate files of the upload.
+// All the entries are checked against the upload limits and the maximum expansion ratio before any file is created.
+// The archive is removed afterwards unless the upload keeps archives.
+// The response is written and false is returned if it fails, the files extracted so far are kept
+func expandArchive(ctx *context.Context, upload *common.Upload, archive *common.File) (files []*common.File, ok bool) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	// Encrypted or encoded data can't be parsed
+	format := common.GetArchiveFormat(archive.Name)
+	if !upload.ExpandArchives || format == "" || archive.EncryptionScheme != "" || archive.ContentEncoding != "" {
+		return nil, true
+	}
+
+	// Zip archives need random access to their data, the copy is written next to the temporary files of the data backend
+	tempDir, err := data.GetTempDir(ctx.GetDataBackend(), archive)
+	if err != nil {
+		ctx.InternalServerError("unable to get archive temporary directory", err)
+		return nil, false
+	}
+
+	tmp, err := ioutil.TempFile(tempDir, "plik_archive_")
+	if err != nil {
+		ctx.InternalServerError("unable to create archive temporary file", err)
+		return nil, false
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	err = copyFileData(ctx, archive, tmp)
+	if err != nil {
+		ctx.InternalServerError("unable to read archive from the data backend", err)
+		return nil, false
+	}
+
+	maxFileSize, err := ctx.GetUploadMaxFileSize(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload owner", err)
+		return nil, false
+	}
+
+	quotaLeft, err := ctx.GetUserQuotaLeft(upload)
+	if err != nil {
+		ctx.InternalServerError("unable to get user storage quota", err)
+		return nil, false
+	}
+
+	count, err := ctx.GetMetadataBackend().CountUploadFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable get upload file count", err)
+		return nil, false
+	}
+
+	// The entry sizes are enforced by the archive readers so they can be trusted
+	var total int64
+	maxTotal := archive.Size * int64(config.ExpandArchiveMaxRatio)
+	err = common.WalkArchive(tmp, archive.Size, format, func(entry *common.ArchiveEntry) error {
+		count++
+		if count > config.MaxFilePerUpload {
+			return common.NewHTTPError(fmt.Sprintf("unable to expand archive : maximum number file per upload reached, limit is %d", config.MaxFilePerUpload), nil, http.StatusBadRequest)
+		}
+		if entry.Size > maxFileSize {
+			return common.NewHTTPError(fmt.Sprintf("unable to expand archive : file %s too big (limit is set to %s)", entry.Name, humanize.Bytes(uint64(maxFileSize))), nil, http.StatusBadRequest)
+		}
+		total += entry.Size
+		if total > maxTotal {
+			return common.NewHTTPError(fmt.Sprintf("unable to expand archive : archive expands to more than %d times its size", config.ExpandArchiveMaxRatio), nil, http.StatusRequestEntityTooLarge)
+		}
+		if quotaLeft >= 0 && total > quotaLeft {
+			return common.NewHTTPError(fmt.Sprintf("unable to expand archive : not enough storage quota left (%s)", humanize.Bytes(uint64(quotaLeft))), nil, http.StatusBadRequest)
+		}
+		return nil
+	})
+	if err != nil {
+		handleArchiveError(ctx, err)
+		return nil, false
+	}
+
+	// The checksum of the archive was checked, the extracted files can't have one
+	noChecksum := func(md5sum string) error { return nil }
+
+	prefix := log.Prefix
+	defer log.SetPrefix(prefix)
+
+	err = common.WalkArchive(tmp, archive.Size, format, func(entry *common.ArchiveEntry) error {
+		params := &common.File{Name: entry.Name, RelativePath: path.Join(archive.RelativePath, entry.RelativePath)}
+		file, err := ctx.CreateFile(upload, params)
+		if err != nil {
+			return common.NewHTTPError("unable to expand archive : unable to create file", err, http.StatusBadRequest)
+		}
+
+		err = ctx.CheckMetadataSize(upload, file)
+		if err != nil {
+			if errors.Is(err, common.ErrMetadataTooLarge) {
+				return common.NewHTTPError("unable to expand archive : unable to create file", err, http.StatusRequestEntityTooLarge)
+			}
+			return common.NewHTTPError("unable to check upload metadata size", err, http.StatusInternalServerError)
+		}
+
+		err = ctx.GetMetadataBackend().CreateFile(file)
+		if err != nil {
+			return common.NewHTTPError("unable to create file", err, http.StatusInternalServerError)
+		}
+
+		reader, err := entry.Open()
+		if err != nil {
+			return common.NewHTTPError(fmt.Sprintf("unable to expand archive : unable to read %s", entry.Name), err, http.StatusBadRequest)
+		}
+		defer func() { _ = reader.Close() }()
+
+		log.SetPrefix(fmt.Sprintf("%s[%s]", prefix, file.Name))
+
+		// The response is already written if the file can't be saved
+		if !saveFile(ctx, upload, file, reader, maxFileSize, noChecksum) {
+			return errResponseWritten
+		}
+
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		if err != errResponseWritten {
+			handleArchiveError(ctx, err)
+		}
+		return nil, false
+	}
+
+	log.SetPrefix(prefix)
+	log.Infof("archive %s expanded to %d files", archive.Name, len(files))
+
+	if !upload.KeepArchives {
+		err = ctx.GetMetadataBackend().RemoveFile(archive)
+		if err != nil {
+			ctx.InternalServerError("unable to remove expanded archive", err)
+			return nil, false
+		}
+	}
+
+	return files, true
+}
+
+var errResponseWritten = errors.New("response already written")
+
+// handleArchiveError answer with the HTTP error status or a bad request for invalid archives
+func handleArchiveError(ctx *context.Context, err error) {
+	if _, ok := err.(common.HTTPError); ok {
+		handleHTTPError(ctx, err)
+		return
+	}
+	ctx.BadRequest("unable to expand archive : %s", err)
+}
+
+// copyFileData copy the data of a file from the data backend
+func copyFileData(ctx *context.Context, file *common.File, writer io.Writer) (err error) {
+	reader, err := ctx.GetDataBackend().GetFile(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	_, err = io.Copy(writer, reader)
+	return err
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestZip(t *testing.T, entries ...[2]string) []byte {
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	for _, entry := range entries {
+		writer, err := zipWriter.Create(entry[0])
+		require.NoError(t, err, "unable to create zip entry")
+		_, err = writer.Write([]byte(entry[1]))
+		require.NoError(t, err, "unable to write zip entry")
+	}
+	require.NoError(t, zipWriter.Close(), "unable to close zip writer")
+	return buf.Bytes()
+}
+
+func addTestArchive(t *testing.T, ctx *context.Context, upload *common.Upload, name string, data []byte) *httptest.ResponseRecorder {
+	reader, contentType, err := getMultipartFormData(name, bytes.NewBuffer(data))
+	require.NoError(t, err, "unable get multipart form data")
+
+	req, err := http.NewRequest("POST", "/file/"+upload.ID, reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	return rr
+}
+
+func getTestUploadFiles(t *testing.T, ctx *context.Context, upload *common.Upload) map[string]*common.File {
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	require.NoError(t, err, "unable to get upload files")
+
+	byPath := make(map[string]*common.File)
+	for _, file := range files {
+		byPath[path.Join(file.RelativePath, file.Name)] = file
+	}
+	return byPath
+}
+
+func TestAddFileExpandArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	data := createTestZip(t, [2]string{"a.txt", "aaa"}, [2]string{"dir/b.txt", content}, [2]string{"dir/", ""})
+	rr := addTestArchive(t, ctx, upload, "archive.zip", data)
+	context.TestOK(t, rr)
+
+	archive := &common.File{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), archive), "unable to unmarshal response body")
+	require.Equal(t, common.FileRemoved, archive.Status, "the archive must be removed")
+
+	files := getTestUploadFiles(t, ctx, upload)
+	require.Len(t, files, 3, "invalid upload files")
+
+	require.Equal(t, common.FileUploaded, files["a.txt"].Status, "invalid file status")
+	require.Equal(t, "aaa", getUploadedFile(t, ctx, files["a.txt"]))
+
+	require.Equal(t, "dir", files["dir/b.txt"].RelativePath, "invalid file relative path")
+	require.Equal(t, contentMD5, files["dir/b.txt"].Md5, "invalid file md5")
+	require.Equal(t, content, getUploadedFile(t, ctx, files["dir/b.txt"]))
+}
+
+func TestAddFileExpandArchiveKeepArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true, KeepArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestArchive(t, ctx, upload, "archive.zip", createTestZip(t, [2]string{"a.txt", "aaa"}))
+	context.TestOK(t, rr)
+
+	files := getTestUploadFiles(t, ctx, upload)
+	require.Len(t, files, 2, "invalid upload files")
+	require.Equal(t, common.FileUploaded, files["archive.zip"].Status, "the archive must be kept")
+	require.Equal(t, common.FileUploaded, files["a.txt"].Status, "invalid file status")
+}
+
+func TestAddFileExpandArchiveDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestArchive(t, ctx, upload, "archive.zip", createTestZip(t, [2]string{"a.txt", "aaa"}))
+	context.TestOK(t, rr)
+
+	files := getTestUploadFiles(t, ctx, upload)
+	require.Len(t, files, 1, "the archive must not be expanded")
+}
+
+func TestAddFileExpandArchiveMaxRatio(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ExpandArchiveMaxRatio = 10
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestArchive(t, ctx, upload, "bomb.zip", createTestZip(t, [2]string{"zeros", strings.Repeat("0", 1000000)}))
+	context.TestFail(t, rr, http.StatusRequestEntityTooLarge, "unable to expand archive : archive expands to more than 10 times its size")
+
+	files := getTestUploadFiles(t, ctx, upload)
+	require.Len(t, files, 1, "no file must be extracted")
+	require.Equal(t, common.FileUploaded, files["bomb.zip"].Status, "the archive must be kept")
+}
+
+func TestAddFileExpandArchiveTooManyFiles(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFilePerUpload = 2
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestArchive(t, ctx, upload, "archive.zip", createTestZip(t, [2]string{"a.txt", "aaa"}, [2]string{"b.txt", "bbb"}))
+	context.TestBadRequest(t, rr, "unable to expand archive : maximum number file per upload reached, limit is 2")
+}
+
+func TestAddFileExpandArchiveInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	rr := addTestArchive(t, ctx, upload, "archive.zip", []byte(content))
+	context.TestBadRequest(t, rr, "unable to expand archive : invalid zip archive")
+
+	rr = addTestArchive(t, ctx, upload, "evil.zip", createTestZip(t, [2]string{"../evil.txt", "evil"}))
+	context.TestBadRequest(t, rr, "unable to expand archive : invalid archive entry ../evil.txt")
+}
+
+func TestAddFilesExpandArchive(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{IsAdmin: true, ExpandArchives: true}
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType := getMultiFileFormData(t,
+		[3]string{"file", "archive.zip", string(createTestZip(t, [2]string{"a.txt", "aaa"}))},
+		[3]string{"file", "b.txt", "bbb"},
+	)
+
+	req, err := http.NewRequest("POST", "/upload/"+upload.ID+"/files", reader)
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Content-Type", contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFiles(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var files []*common.File
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &files), "unable to unmarshal response body")
+	require.Len(t, files, 3, "invalid files")
+
+	require.Equal(t, "archive.zip", files[0].Name, "invalid file name")
+	require.Equal(t, common.FileRemoved, files[0].Status, "invalid file status")
+	require.Equal(t, "a.txt", files[1].Name, "invalid file name")
+	require.Equal(t, common.FileUploaded, files[1].Status, "invalid file status")
+	require.Equal(t, "b.txt", files[2].Name, "invalid file name")
+}
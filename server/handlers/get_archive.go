@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"path"
 	"strings"
 
+	"github.com/dustin/go-humanize"
 	"github.com/gorilla/mux"
 
 	"github.com/root-gg/plik/server/common"
@@ -27,11 +29,19 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		panic("missing upload from context")
 	}
 
+	if !checkDownloadAccess(ctx, upload, nil) {
+		return
+	}
+
 	if upload.Stream {
 		ctx.BadRequest("archive feature is not available in stream mode")
 		return
 	}
 
+	if !checkArchiveLimits(ctx, upload) {
+		return
+	}
+
 	// Set content type
 	resp.Header().Set("Content-Type", "application/zip")
 
@@ -71,9 +81,9 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 	// -> The client should download file instead of displaying it
 	dl := req.URL.Query().Get("dl")
 	if dl != "" {
-		resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachement; filename="%s"`, fileName))
+		resp.Header().Set("Content-Disposition", common.FormatContentDisposition(common.ContentDispositionAttachment, fileName, common.ASCIIFileName(fileName)))
 	} else {
-		resp.Header().Set("Content-Disposition", fmt.Sprintf(`filename="%s"`, fileName))
+		resp.Header().Set("Content-Disposition", common.FormatContentDisposition("", fileName, common.ASCIIFileName(fileName)))
 	}
 
 	// HEAD Request => Do not print file, user just wants http headers
@@ -82,9 +92,14 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		// Get files to archive
 
 		var files []*common.File
+		var consumed int
 		f := func(file *common.File) error {
-			// Ignore uploading, missing, removed, one shot already downloaded,...
-			if file.Status != common.FileUploaded {
+			if isOneShotConsumed(upload, file) {
+				consumed++
+			}
+
+			// Ignore uploading, missing, removed, expired, one shot already downloaded,...
+			if file.Status != common.FileUploaded || file.IsExpired() {
 				return nil
 			}
 
@@ -97,6 +112,7 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 			}
 
 			files = append(files, file)
+			addFileDownload(ctx, file)
 
 			return nil
 		}
@@ -107,14 +123,25 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		}
 
 		if len(files) == 0 {
+			if consumed > 0 && (ctx.GetConfig().RevealGoneReason || ctx.GetConfig().ExpiredPageURL != "") {
+				ctx.Expired("upload %s files have already been downloaded", upload.ID)
+				return
+			}
 			ctx.BadRequest("nothing to archive")
 			return
 		}
 
+		addUploadDownload(ctx, upload)
+		touchUpload(ctx, upload)
+
 		backend := ctx.GetDataBackend()
 
 		// The zip archive is piped directly to http response body without buffering
-		archive := zip.NewWriter(resp)
+		watchdog := newDownloadWatchdog(ctx, req, resp)
+		defer watchdog.stop()
+		counter := &countingWriter{Writer: watchdog}
+		defer func() { addDownloadedBytes(ctx, upload, counter.written) }()
+		archive := zip.NewWriter(counter)
 
 		for _, file := range files {
 			fileReader, err := backend.GetFile(file)
@@ -122,23 +149,39 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 				ctx.InternalServerError("unable to get file from data backend", err)
 				return
 			}
+			watchdog.setReader(fileReader)
 
-			fileWriter, err := archive.Create(file.Name)
+			// Extracting the archive rebuilds the uploaded folder tree
+			fileWriter, err := archive.Create(path.Join(file.RelativePath, file.Name))
 			if err != nil {
 				ctx.InternalServerError("error while creating zip archive", err)
 				return
 			}
 
+			// Archived files are always decoded
+			decodedReader, err := decodeContent(file, fileReader)
+			if err != nil {
+				ctx.InternalServerError("unable to decode file", err)
+				return
+			}
+
+			reader, release := limitDownloadBandwidth(ctx, decodedReader)
+
 			// File is piped directly to zip archive thus to the http response body without buffering
-			_, err = io.Copy(fileWriter, fileReader)
+			_, err = io.Copy(fileWriter, reader)
 			if err != nil {
 				log.Warningf("error while copying zip archive to response body : %s", err)
 			}
+			release()
 
 			err = fileReader.Close()
 			if err != nil {
 				log.Warningf("error while closing zip archive reader : %s", err)
 			}
+
+			if watchdog.isExpired() {
+				return
+			}
 		}
 
 		err = archive.Close()
@@ -148,3 +191,56 @@ func GetArchive(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		}
 	}
 }
+
+// countingWriter count the bytes written to the underlying writer
+type countingWriter struct {
+	io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// checkArchiveLimits refuse to generate archives of uploads bigger than MaxArchiveSize or with more than MaxFilesInArchive files,
+// it returns false if the request has failed
+// The size is computed from the file sizes in the metadata before anything is read from the data backend
+func checkArchiveLimits(ctx *context.Context, upload *common.Upload) bool {
+	maxArchiveSize := ctx.GetConfig().MaxArchiveSize
+	maxFilesInArchive := ctx.GetConfig().MaxFilesInArchive
+	if maxArchiveSize <= 0 && maxFilesInArchive <= 0 {
+		return true
+	}
+
+	var size int64
+	var count int
+	f := func(file *common.File) error {
+		if file.Status == common.FileUploaded && !file.IsExpired() {
+			size += file.Size
+			count++
+		}
+		return nil
+	}
+
+	err := ctx.GetMetadataBackend().ForEachUploadFiles(upload.ID, f)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return false
+	}
+
+	if maxFilesInArchive > 0 && count > maxFilesInArchive {
+		ctx.RequestEntityTooLarge("upload has too many files to be archived (%d), maximum is %d files per archive, please download the files individually",
+			count, maxFilesInArchive)
+		return false
+	}
+
+	if maxArchiveSize > 0 && size > maxArchiveSize {
+		ctx.RequestEntityTooLarge("upload is too big to be archived (%s), maximum archive size is %s, please download the files individually",
+			humanize.Bytes(uint64(size)), humanize.Bytes(uint64(maxArchiveSize)))
+		return false
+	}
+
+	return true
+}
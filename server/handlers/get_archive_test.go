@@ -4,8 +4,10 @@ import (
 	"archive/zip"
 	"bytes"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 
 	"testing"
 
@@ -69,6 +71,172 @@ func TestGetArchive(t *testing.T) {
 	content, err := ioutil.ReadAll(fileReader)
 	require.NoError(t, err, "unable to read archived file")
 	require.Equal(t, data, string(content), "invalid archived file content")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 1, f.DownloadCount, "invalid download count")
+}
+
+func TestGetArchiveMaxTotalDownloadBytes(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{MaxTotalDownloadBytes: 1}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+
+	getArchive := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+		rr := ctx.NewRecorder(req)
+		GetArchive(ctx, rr, req)
+		return rr
+	}
+
+	rr := getArchive()
+	context.TestOK(t, rr)
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, int64(rr.Body.Len()), u.DownloadedBytes, "invalid upload downloaded bytes")
+
+	context.TestFail(t, getArchive(), http.StatusGone, "upload maximum total download bytes have been served")
+}
+
+func TestGetArchiveMaxArchiveSize(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxArchiveSize = 10
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{OneShot: true}
+	for i := 0; i < 2; i++ {
+		file := upload.NewFile()
+		file.Name = "file"
+		file.Status = common.FileUploaded
+		file.Size = 6
+	}
+
+	// Files not uploaded are not archived
+	missing := upload.NewFile()
+	missing.Name = "missing"
+	missing.Size = 100
+
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusRequestEntityTooLarge, "upload is too big to be archived (12 B), maximum archive size is 10 B, please download the files individually")
+
+	// Nothing has been downloaded
+	f, err := ctx.GetMetadataBackend().GetFile(upload.Files[0].ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "one shot file should not have been removed")
+	require.Equal(t, 0, f.DownloadCount, "invalid download count")
+}
+
+func TestGetArchiveMaxFilesInArchive(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFilesInArchive = 2
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{OneShot: true}
+	for i := 0; i < 3; i++ {
+		file := upload.NewFile()
+		file.Name = fmt.Sprintf("file%d", i)
+		file.Status = common.FileUploaded
+	}
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusRequestEntityTooLarge, "upload has too many files to be archived (3), maximum is 2 files per archive, please download the files individually")
+
+	// Nothing has been downloaded
+	f, err := ctx.GetMetadataBackend().GetFile(upload.Files[0].ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "one shot file should not have been removed")
+}
+
+func TestGetArchiveRelativePath(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.RelativePath = "project/src"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	z, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err, "unable to unzip response body")
+	require.Len(t, z.File, 1, "invalid archive file count")
+	require.Equal(t, "project/src/file", z.File[0].Name, "invalid archived file path")
+}
+
+func TestGetArchiveContentEncoding(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file.txt"
+	file.ContentEncoding = common.ContentEncodingGzip
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer(gzipData(t, "data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+	rr := ctx.NewRecorder(req)
+	GetArchive(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	z, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err, "unable to unzip response body")
+	require.Len(t, z.File, 1, "invalid archive file count")
+
+	fileReader, err := z.File[0].Open()
+	require.NoError(t, err, "unable to open archived file")
+
+	content, err := ioutil.ReadAll(fileReader)
+	require.NoError(t, err, "unable to read archived file")
+	require.Equal(t, "data", string(content), "archived file should be decoded")
 }
 
 func TestGetArchiveStreaming(t *testing.T) {
@@ -140,6 +308,42 @@ func TestGetArchiveFileNameTooLong(t *testing.T) {
 	context.TestBadRequest(t, rr, "archive name too long")
 }
 
+func TestGetArchiveRequireAuthForDownload(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.RequireAuthForDownload = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	getArchive := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/archive/"+upload.ID+"/"+"archive.zip", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"filename": "archive.zip"})
+
+		rr := ctx.NewRecorder(req)
+		GetArchive(ctx, rr, req)
+		return rr
+	}
+
+	context.TestUnauthorized(t, getArchive(), "please login to download this file")
+
+	// Upload admins can download the upload
+	upload.IsAdmin = true
+	context.TestOK(t, getArchive())
+
+	upload.IsAdmin = false
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	context.TestOK(t, getArchive())
+}
+
 func TestGetArchiveInvalidDownloadDomain(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
@@ -32,6 +36,10 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		panic("missing file from context")
 	}
 
+	if !checkDownloadAccess(ctx, upload, file) {
+		return
+	}
+
 	// File status check
 	if upload.Stream {
 		if file.Status != common.FileUploading {
@@ -39,21 +47,109 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 			return
 		}
 	} else {
+		if isOneShotConsumed(upload, file) && (ctx.GetConfig().RevealGoneReason || ctx.GetConfig().ExpiredPageURL != "") {
+			ctx.Expired("file %s (%s) has already been downloaded", file.Name, file.ID)
+			return
+		}
 		if file.Status != common.FileUploaded {
 			ctx.NotFound("file %s (%s) is not available : %s", file.Name, file.ID, file.Status)
 			return
 		}
 	}
 
-	if req.Method == "GET" && upload.OneShot {
-		// Update file status
-		// For streaming upload the status is set to deleted by the add_file handler
-		err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
+	// If "filename" GET param is set the file is served under that name instead
+	filename := file.Name
+	asciiFilename := file.ASCIIName
+	if asciiFilename == "" {
+		asciiFilename = common.ASCIIFileName(filename)
+	}
+	if override := strings.TrimSpace(req.URL.Query().Get("filename")); override != "" {
+		if strings.ContainsAny(override, "/\\\r\n\"") {
+			ctx.InvalidParameter("filename")
+			return
+		}
+
+		// The override is sanitized like the names of uploaded files ( no bidi or control characters, length )
+		override, err := ctx.GetConfig().CheckFileName(override)
 		if err != nil {
-			ctx.InternalServerError("unable to update file status", err)
+			ctx.InvalidParameter("filename : %s", err)
+			return
+		}
+		filename = override
+		asciiFilename = common.ASCIIFileName(override)
+	}
+
+	// Encoded files are served as is to clients accepting the encoding and decoded by the server otherwise
+	var decode bool
+	if file.ContentEncoding != "" {
+		resp.Header().Set("Vary", "Accept-Encoding")
+		decode = !acceptsEncoding(req.Header.Get("Accept-Encoding"), file.ContentEncoding)
+	}
+
+	// Get file in data backend
+	var backend data.Backend
+	if upload.Stream {
+		backend = ctx.GetStreamBackend()
+	} else {
+		backend = ctx.GetDataBackend()
+	}
+
+	// Let the frontend reverse proxy serve the file if the data backend supports it
+	var accelRedirect string
+	if redirecter, ok := backend.(data.AccelRedirecter); ok && req.Method == "GET" && !decode {
+		location, err := redirecter.GetAccelRedirect(file)
+		if err != nil {
+			ctx.InternalServerError("unable to get file from data backend", err)
+			return
+		}
+		accelRedirect = location
+	}
+
+	// A single byte range of stored files can be requested to resume a download
+	// The reverse proxy handles ranges itself when serving the file
+	// Ranges of decoded files can't be computed without decoding the beginning of the file
+	var rangeStart, rangeEnd int64
+	var ranged bool
+	if !upload.Stream && file.Size > 0 && accelRedirect == "" && !decode {
+		resp.Header().Set("Accept-Ranges", "bytes")
+
+		rangeHeader := req.Header.Get("Range")
+		if rangeHeader != "" {
+			var err error
+			rangeStart, rangeEnd, err = parseRange(rangeHeader, file.Size)
+			if err != nil {
+				resp.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+				ctx.Fail(err.Error(), nil, http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			ranged = true
+		}
+	}
+
+	// OneShot files are only consumed once fully delivered so interrupted downloads can be resumed for a while
+	trackOneShot := upload.OneShot && !upload.Stream && accelRedirect == "" && !decode && ctx.GetConfig().GetOneShotResumeWindow() > 0
+
+	// Bytes of the OneShot file delivered once the response is over
+	oneShotDelivered := rangeStart
+	if req.Method == "GET" && upload.OneShot {
+		if trackOneShot {
+			if !startOneShotDownload(ctx, file, rangeStart, ranged) {
+				return
+			}
+			defer func() { endOneShotDownload(ctx, file, oneShotDelivered) }()
+		} else {
+			// Update file status
+			// For streaming upload the status is set to deleted by the add_file handler
+			err := ctx.GetMetadataBackend().UpdateFileStatus(file, file.Status, common.FileRemoved)
+			if err != nil {
+				ctx.InternalServerError("unable to update file status", err)
+			}
 		}
 	}
 
+	// Display the file in the browser or download it depending on its actual type
+	disposition := ctx.GetConfig().GetContentDisposition(upload, file.Type)
+
 	// Avoid rendering HTML in browser
 	if strings.Contains(file.Type, "html") {
 		file.Type = "text/plain"
@@ -68,11 +164,16 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	resp.Header().Set("Content-Type", file.Type)
 
 	/* Additional security headers for possibly unsafe content */
+	if ctx.GetConfig().DownloadContentSecurityPolicy != "" {
+		resp.Header().Set("Content-Security-Policy", ctx.GetConfig().DownloadContentSecurityPolicy)
+	} else {
+		// Do not serve the files with the web UI policy set by the security headers middleware
+		resp.Header().Del("Content-Security-Policy")
+	}
 	if ctx.GetConfig().EnhancedWebSecurity {
 		resp.Header().Set("X-Content-Type-Options", "nosniff")
 		resp.Header().Set("X-XSS-Protection", "1; mode=block")
 		resp.Header().Set("X-Frame-Options", "DENY")
-		resp.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'none'; style-src 'none'; img-src 'none'; connect-src 'none'; font-src 'none'; object-src 'none'; media-src 'self'; child-src 'none'; form-action 'none'; frame-ancestors 'none'; plugin-types; sandbox")
 	}
 
 	/* Additional header for disabling cache if the upload is OneShot */
@@ -82,42 +183,295 @@ func GetFile(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 		resp.Header().Set("Expires", "0")                                         // Proxies
 	}
 
-	if file.Size > 0 {
+	if ranged {
+		resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rangeStart, rangeEnd, file.Size))
+		resp.Header().Set("Content-Length", strconv.FormatInt(rangeEnd-rangeStart+1, 10))
+	} else if file.Size > 0 && !decode {
 		resp.Header().Set("Content-Length", strconv.Itoa(int(file.Size)))
 	}
 
+	if file.ContentEncoding != "" && !decode {
+		resp.Header().Set("Content-Encoding", file.ContentEncoding)
+	}
+
 	// If "dl" GET params is set
-	// -> Set Content-Disposition header
 	// -> The client should download file instead of displaying it
 	dl := req.URL.Query().Get("dl")
 	if dl != "" {
-		resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachement; filename="%s"`, file.Name))
-	} else {
-		resp.Header().Set("Content-Disposition", fmt.Sprintf(`filename="%s"`, file.Name))
+		disposition = common.ContentDispositionAttachment
 	}
+	resp.Header().Set("Content-Disposition", common.FormatContentDisposition(disposition, filename, asciiFilename))
 
 	// HEAD Request => Do not print file, user just wants http headers
 	// GET  Request => Print file content
 	if req.Method == "GET" {
-		// Get file in data backend
-		var backend data.Backend
-		if upload.Stream {
-			backend = ctx.GetStreamBackend()
-		} else {
-			backend = ctx.GetDataBackend()
+		touchUpload(ctx, upload)
+
+		// Requests resuming an interrupted download are not counted as new downloads
+		if rangeStart == 0 {
+			addFileDownload(ctx, file)
+			addUploadDownload(ctx, upload)
+		}
+
+		if accelRedirect != "" {
+			// The reverse proxy will set the Content-Length of the actual response
+			resp.Header().Del("Content-Length")
+			resp.Header().Set("X-Accel-Redirect", accelRedirect)
+			addDownloadedBytes(ctx, upload, file.Size)
+			return
 		}
 
-		fileReader, err := backend.GetFile(file)
+		var fileReader io.ReadCloser
+		var err error
+		if ranged {
+			// Data backends implementing data.RangeGetter only read the requested range
+			// so download managers can efficiently fetch parts of the file concurrently
+			fileReader, err = data.GetFileRange(backend, file, rangeStart, rangeEnd-rangeStart+1)
+		} else {
+			fileReader, err = backend.GetFile(file)
+		}
 		if err != nil {
 			ctx.InternalServerError("unable to get file from data backend", err)
 			return
 		}
 		defer func() { _ = fileReader.Close() }()
 
+		var reader io.Reader = fileReader
+		if ranged {
+			resp.WriteHeader(http.StatusPartialContent)
+		}
+
+		if decode {
+			reader, err = decodeContent(file, reader)
+			if err != nil {
+				ctx.InternalServerError("unable to decode file", err)
+				return
+			}
+		}
+
+		reader, release := limitDownloadBandwidth(ctx, reader)
+		defer release()
+
+		var writer io.Writer = resp
+		if !upload.Stream {
+			// Stream downloads legitimately wait for the uploader
+			watchdog := newDownloadWatchdog(ctx, req, resp)
+			watchdog.setReader(fileReader)
+			defer watchdog.stop()
+			writer = watchdog
+		}
+		if trackOneShot {
+			writer = &oneShotProgressWriter{writer: writer, ctx: ctx, file: file, delivered: rangeStart, savedAt: time.Now()}
+		}
+
 		// File is piped directly to http response body without buffering
-		_, err = io.Copy(resp, fileReader)
+		written, err := io.Copy(writer, reader)
 		if err != nil {
 			log.Warningf("error while copying file to response : %s", err)
 		}
+
+		addDownloadedBytes(ctx, upload, written)
+		oneShotDelivered = rangeStart + written
+	} else if ranged {
+		resp.WriteHeader(http.StatusPartialContent)
+	}
+}
+
+// parseRange parse a single byte range "bytes=start-end", "bytes=start-" or "bytes=-suffix" of a file of the given size
+func parseRange(header string, size int64) (start int64, end int64, err error) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, fmt.Errorf("invalid range %s", header)
+	}
+
+	spec := strings.TrimPrefix(header, "bytes=")
+	if strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("multiple ranges are not supported")
+	}
+
+	bounds := strings.SplitN(spec, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %s", header)
+	}
+	first := strings.TrimSpace(bounds[0])
+	last := strings.TrimSpace(bounds[1])
+
+	// The last N bytes of the file
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, fmt.Errorf("invalid range %s", header)
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range %s", header)
+	}
+	if start >= size {
+		return 0, 0, fmt.Errorf("range starts after the end of the file")
+	}
+
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid range %s", header)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, nil
+}
+
+// acceptsEncoding tells whether the Accept-Encoding header of the client allows the given content encoding
+func acceptsEncoding(header string, encoding string) bool {
+	var accepted, explicit, wildcard, hasWildcard bool
+
+	for _, element := range strings.Split(header, ",") {
+		params := strings.Split(element, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+
+		switch coding {
+		case encoding, "x-" + encoding:
+			explicit = true
+			accepted = quality > 0
+		case "*":
+			hasWildcard = true
+			wildcard = quality > 0
+		}
+	}
+
+	if explicit {
+		return accepted
+	}
+	return hasWildcard && wildcard
+}
+
+// decodeContent decode the data of a file stored with a content encoding
+func decodeContent(file *common.File, reader io.Reader) (io.Reader, error) {
+	switch file.ContentEncoding {
+	case "":
+		return reader, nil
+	case common.ContentEncodingGzip:
+		return gzip.NewReader(reader)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %s", file.ContentEncoding)
+	}
+}
+
+// startOneShotDownload check that a OneShot file can be downloaded and mark the beginning of its download
+// Once started the download can only be resumed by range requests starting exactly at the last delivered byte
+// during the resume window, and only one download can be in progress at a time
+func startOneShotDownload(ctx *context.Context, file *common.File, start int64, ranged bool) bool {
+	if file.LastDownloadAt != nil {
+		if time.Since(*file.LastDownloadAt) > ctx.GetConfig().GetOneShotResumeWindow() {
+			consumeOneShotFile(ctx, file)
+			ctx.NotFound("file %s (%s) is not available : download resume window has expired", file.Name, file.ID)
+			return false
+		}
+
+		if !ranged && file.DeliveredBytes > 0 {
+			ctx.NotFound("file %s (%s) is not available : download in progress, use a range request to resume it", file.Name, file.ID)
+			return false
+		}
+	}
+
+	if start != file.DeliveredBytes {
+		ctx.Fail(fmt.Sprintf("unable to resume download at byte %d, the download must be resumed at byte %d", start, file.DeliveredBytes), nil, http.StatusRequestedRangeNotSatisfiable)
+		return false
+	}
+
+	// The file metadata might be outdated, the database tells if the download can actually start
+	started, err := ctx.GetMetadataBackend().StartFileDownload(file, start)
+	if err != nil {
+		ctx.InternalServerError("unable to update file metadata", err)
+		return false
+	}
+	if !started {
+		ctx.NotFound("file %s (%s) is not available : download in progress", file.Name, file.ID)
+		return false
+	}
+
+	return true
+}
+
+// endOneShotDownload save the progress of a OneShot file download and consume the file once fully delivered
+func endOneShotDownload(ctx *context.Context, file *common.File, delivered int64) {
+	if delivered >= file.Size {
+		consumeOneShotFile(ctx, file)
+		return
+	}
+
+	err := ctx.GetMetadataBackend().EndFileDownload(file, delivered)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to save file delivery progress : %s", err)
+	}
+}
+
+// oneShotProgressInterval is how often the delivery progress of a OneShot file is saved during its download
+const oneShotProgressInterval = 5 * time.Second
+
+// oneShotProgressWriter save the delivery progress of a OneShot file while it is downloaded
+// so long downloads are not mistaken for interrupted ones once the resume window has elapsed
+type oneShotProgressWriter struct {
+	writer    io.Writer
+	ctx       *context.Context
+	file      *common.File
+	delivered int64
+	savedAt   time.Time
+}
+
+func (w *oneShotProgressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.writer.Write(p)
+	w.delivered += int64(n)
+
+	if time.Since(w.savedAt) >= oneShotProgressInterval {
+		w.savedAt = time.Now()
+		errSave := w.ctx.GetMetadataBackend().UpdateFileDelivery(w.file, w.delivered)
+		if errSave != nil {
+			w.ctx.GetLogger().Warningf("unable to save file delivery progress : %s", errSave)
+		}
+	}
+
+	return n, err
+}
+
+// consumeOneShotFile remove a OneShot file so it can't be downloaded anymore
+func consumeOneShotFile(ctx *context.Context, file *common.File) {
+	err := ctx.GetMetadataBackend().UpdateFileStatus(file, common.FileUploaded, common.FileRemoved)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to remove one shot file : %s", err)
+	}
+}
+
+// limitDownloadBandwidth wraps the reader to get a fair share of the server download bandwidth if it is limited.
+// The returned function must be called once the download is over to release the share.
+func limitDownloadBandwidth(ctx *context.Context, reader io.Reader) (io.Reader, func()) {
+	limiter := ctx.GetDownloadLimiter()
+	if limiter == nil {
+		return reader, func() {}
+	}
+
+	limitedReader := limiter.NewReader(reader)
+	return limitedReader, func() {
+		_ = limitedReader.Close()
+		ctx.GetLogger().Debugf("download rate : %s/s", humanize.Bytes(uint64(limitedReader.GetRate())))
 	}
 }
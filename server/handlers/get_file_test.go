@@ -2,19 +2,27 @@ package handlers
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
 
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+	data_file "github.com/root-gg/plik/server/data/file"
 	data_test "github.com/root-gg/plik/server/data/testing"
 )
 
@@ -65,7 +73,354 @@ func TestGetFile(t *testing.T) {
 	require.NotEmpty(t, rr.Header().Get("X-XSS-Protection"))
 	require.NotEmpty(t, rr.Header().Get("X-Frame-Options"))
 	require.NotEmpty(t, rr.Header().Get("Content-Security-Policy"))
-	require.Equal(t, rr.Header().Get("Content-Disposition"), fmt.Sprintf(`attachement; filename="%s"`, file.Name))
+	require.Equal(t, rr.Header().Get("Content-Disposition"), fmt.Sprintf(`attachment; filename="%s"`, file.Name))
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 1, f.DownloadCount, "invalid download count")
+}
+
+func TestGetFileDownloadNotification(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadNotificationWebhook = "https://hooks.root.gg/plik"
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	for i := 0; i < 2; i++ {
+		ctx.SetUpload(upload)
+		ctx.SetFile(file)
+
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+	}
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload metadata")
+	require.Equal(t, 2, u.PendingDownloads, "invalid pending download count")
+	require.NotNil(t, u.PendingDownloadsSince, "missing pending downloads date")
+}
+
+func gzipData(t *testing.T, data string) []byte {
+	buffer := new(bytes.Buffer)
+	writer := gzip.NewWriter(buffer)
+	_, err := writer.Write([]byte(data))
+	require.NoError(t, err, "unable to gzip data")
+	require.NoError(t, writer.Close(), "unable to gzip data")
+	return buffer.Bytes()
+}
+
+func TestGetFileContentEncoding(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	data := "data data data"
+	gzipped := gzipData(t, data)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file.txt"
+	file.Status = common.FileUploaded
+	file.Type = "text/plain"
+	file.ContentEncoding = common.ContentEncodingGzip
+	file.Size = int64(len(gzipped))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer(gzipped))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	// The client accepts the encoding, the data is served as is
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "gzip", rr.Header().Get("Content-Encoding"), "invalid response content encoding")
+	require.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"), "invalid response vary header")
+	require.Equal(t, strconv.Itoa(len(gzipped)), rr.Header().Get("Content-Length"), "invalid response content length")
+	require.Equal(t, gzipped, rr.Body.Bytes(), "invalid file content")
+
+	// The client does not accept the encoding, the data is decoded by the server
+	req.Header.Set("Accept-Encoding", "gzip;q=0, *")
+	req.Header.Set("Range", "bytes=0-3")
+
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "", rr.Header().Get("Content-Encoding"), "invalid response content encoding")
+	require.Equal(t, "", rr.Header().Get("Content-Length"), "invalid response content length")
+	require.Equal(t, "", rr.Header().Get("Accept-Ranges"), "ranges of decoded files should not be accepted")
+	require.Equal(t, data, rr.Body.String(), "invalid decoded file content")
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	accepted := []string{"gzip", "GZIP", "deflate, gzip", "gzip;q=0.5", "x-gzip", "*", "br, *;q=0.1"}
+	for _, header := range accepted {
+		require.True(t, acceptsEncoding(header, "gzip"), "gzip should be accepted by %s", header)
+	}
+
+	refused := []string{"", "identity", "deflate, br", "gzip;q=0", "*;q=0", "gzip;q=0, *"}
+	for _, header := range refused {
+		require.False(t, acceptsEncoding(header, "gzip"), "gzip should not be accepted by %s", header)
+	}
+}
+
+func TestGetFileContentDisposition(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DefaultContentDisposition = common.ContentDispositionAttachment
+	config.ContentDispositions = map[string]string{"image/*": common.ContentDispositionInline}
+	ctx := newTestingContext(config)
+
+	data := "data"
+
+	upload := &common.Upload{}
+	image := upload.NewFile()
+	image.Name = "image.png"
+	image.Status = common.FileUploaded
+	image.Type = "image/png"
+	image.Size = int64(len(data))
+	archive := upload.NewFile()
+	archive.Name = "archive.zip"
+	archive.Status = common.FileUploaded
+	archive.Type = "application/zip"
+	archive.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	for _, file := range upload.Files {
+		err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+		require.NoError(t, err, "unable to create test file")
+	}
+
+	getDisposition := func(file *common.File, query string) string {
+		ctx.SetUpload(upload)
+		ctx.SetFile(file)
+
+		req, err := http.NewRequest("HEAD", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+query, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestOK(t, rr)
+
+		return rr.Header().Get("Content-Disposition")
+	}
+
+	require.Equal(t, `inline; filename="image.png"`, getDisposition(image, ""))
+	require.Equal(t, `attachment; filename="archive.zip"`, getDisposition(archive, ""))
+	require.Equal(t, `attachment; filename="image.png"`, getDisposition(image, "?dl=1"))
+
+	upload.ContentDisposition = common.ContentDispositionInline
+	require.Equal(t, `inline; filename="archive.zip"`, getDisposition(archive, ""))
+	require.Equal(t, `attachment; filename="archive.zip"`, getDisposition(archive, "?dl=1"))
+}
+
+func TestGetFileWithAccelRedirect(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	dir, err := ioutil.TempDir("", "pliktest")
+	require.NoError(t, err, "unable to create temp directory")
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	ctx.SetDataBackend(data_file.NewBackend(&data_file.Config{Directory: dir, XAccelRedirect: "/plik-files"}))
+
+	data := "data"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "/plik-files/"+file.ID[:2]+"/"+file.ID, rr.Header().Get("X-Accel-Redirect"), "invalid accel redirect header")
+	require.Empty(t, rr.Header().Get("Content-Length"), "invalid response content length")
+	require.Equal(t, 0, rr.Body.Len(), "file content should not be streamed")
+}
+
+func TestGetFileWithFilename(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	data := "data"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "data"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?dl=1&filename=report-2024.csv", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, `attachment; filename="report-2024.csv"`, rr.Header().Get("Content-Disposition"))
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, data, string(respBody), "invalid file content")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, "data", f.Name, "invalid file name")
+}
+
+func TestGetFileWithFilenameNormalized(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxFilenameLength = 20
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "data"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	getFile := func(filename string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?dl=1&filename="+filename, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		return rr
+	}
+
+	// Bidi overrides can't be used to disguise the extension
+	rr := getFile("invoice%E2%80%AEfdp.exe")
+	context.TestOK(t, rr)
+	require.Equal(t, `attachment; filename="invoicefdp.exe"`, rr.Header().Get("Content-Disposition"))
+
+	rr = getFile("%E2%80%AE")
+	context.TestInvalidParameter(t, rr, "filename")
+
+	rr = getFile("a-very-long-file-name.txt")
+	context.TestInvalidParameter(t, rr, "filename")
+
+	config.TruncateLongFilenames = true
+	rr = getFile("a-very-long-file-name.txt")
+	context.TestOK(t, rr)
+	require.Equal(t, `attachment; filename="a-very-long-file.txt"`, rr.Header().Get("Content-Disposition"))
+}
+
+func TestGetFileUnicodeFilename(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "日本.pdf"
+	file.ASCIIName = "__.pdf"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?dl=1", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, `attachment; filename="__.pdf"; filename*=UTF-8''%E6%97%A5%E6%9C%AC.pdf`, rr.Header().Get("Content-Disposition"))
+}
+
+func TestGetFileWithInvalidFilename(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{OneShot: true}
+	file := upload.NewFile()
+	file.Name = "data"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	for _, filename := range []string{"../passwd", "dir\\file", "foo%0D%0ASet-Cookie:%20bar", "foo%22bar"} {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name+"?filename="+filename, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		context.TestInvalidParameter(t, rr, "filename")
+	}
+
+	// The OneShot download must not have been consumed
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestGetFileWithDownloadLimiter(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	limiter := common.NewBandwidthLimiter(1000000)
+	ctx.SetDownloadLimiter(limiter)
+
+	data := "data"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, data, string(respBody), "invalid file content")
+
+	require.Equal(t, int64(0), limiter.GetActiveReaders(), "bandwidth share has not been released")
 }
 
 func TestGetOneShotFile(t *testing.T) {
@@ -107,6 +462,330 @@ func TestGetOneShotFile(t *testing.T) {
 	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
 }
 
+func TestGetOneShotFileAlreadyDownloaded(t *testing.T) {
+	config := common.NewConfiguration()
+	config.RevealGoneReason = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	upload.OneShot = true
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileRemoved
+	file.DownloadCount = 1
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusGone, "has already been downloaded")
+
+	// Do not reveal that the file has been downloaded
+	config.RevealGoneReason = false
+
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestNotFound(t, rr, "is not available")
+
+	// Browsers are redirected to the expired page
+	config.ExpiredPageURL = "https://portal.root.gg/expired.html"
+	ctx.SetRedirectOnFailure(true)
+	req.Header.Set("Accept", "text/html")
+
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	require.Equal(t, http.StatusFound, rr.Code, "browsers must be redirected to the expired page")
+	require.Equal(t, config.ExpiredPageURL, rr.Header().Get("Location"), "invalid redirect location")
+}
+
+func TestGetFileInactivityTTL(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	data := "data"
+
+	upload := &common.Upload{InactivityTTL: 3600}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	// Simulate an upload about to expire
+	deadline := time.Now().Add(time.Minute)
+	upload.ExpireAt = &deadline
+	err = ctx.GetMetadataBackend().UpdateUploadExpirationDate(upload)
+	require.NoError(t, err, "unable to update upload expiration date")
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	start := time.Now()
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, result.LastAccessedAt, "missing last access date")
+	require.False(t, result.LastAccessedAt.Before(start.Truncate(time.Second)), "invalid last access date")
+	require.Equal(t, result.LastAccessedAt.Add(time.Hour).Unix(), result.ExpireAt.Unix(), "expiration date should be pushed back")
+}
+
+func TestGetFileRange(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	data := "0123456789"
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	getFileRange := func(rangeHeader string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req.Header.Set("Range", rangeHeader)
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		return rr
+	}
+
+	rr := getFileRange("bytes=2-5")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+	require.Equal(t, "bytes 2-5/10", rr.Header().Get("Content-Range"), "invalid content range")
+	require.Equal(t, "4", rr.Header().Get("Content-Length"), "invalid content length")
+	require.Equal(t, "2345", rr.Body.String(), "invalid file content")
+
+	rr = getFileRange("bytes=7-")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+	require.Equal(t, "789", rr.Body.String(), "invalid file content")
+
+	rr = getFileRange("bytes=-2")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+	require.Equal(t, "89", rr.Body.String(), "invalid file content")
+
+	rr = getFileRange("bytes=10-")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code, "invalid response status code")
+	require.Equal(t, "bytes */10", rr.Header().Get("Content-Range"), "invalid content range")
+
+	// Resumed downloads are not counted as new downloads
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, 0, f.DownloadCount, "invalid download count")
+}
+
+func TestParseRange(t *testing.T) {
+	for header, expected := range map[string][2]int64{
+		"bytes=0-9":    {0, 9},
+		"bytes=0-":     {0, 9},
+		"bytes=3-5":    {3, 5},
+		"bytes=3-42":   {3, 9},
+		"bytes=-3":     {7, 9},
+		"bytes=-42":    {0, 9},
+		"bytes= 1 - 2": {1, 2},
+	} {
+		start, end, err := parseRange(header, 10)
+		require.NoError(t, err, "unable to parse range %s", header)
+		require.Equal(t, expected[0], start, "invalid range start for %s", header)
+		require.Equal(t, expected[1], end, "invalid range end for %s", header)
+	}
+
+	for _, header := range []string{"", "foo", "bytes=", "bytes=-", "bytes=a-", "bytes=5-3", "bytes=10-", "bytes=-0", "bytes=0-1,3-4"} {
+		_, _, err := parseRange(header, 10)
+		require.Error(t, err, "invalid range %s should not be parsed", header)
+	}
+}
+
+func newOneShotResumeTestingContext(t *testing.T, data string) (ctx *context.Context, upload *common.Upload, file *common.File) {
+	config := common.NewConfiguration()
+	config.OneShotResumeWindow = "5m"
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	ctx = newTestingContext(config)
+
+	upload = &common.Upload{OneShot: true}
+	file = upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	return ctx, upload, file
+}
+
+func getOneShotFileForTests(t *testing.T, ctx *context.Context, upload *common.Upload, file *common.File, rangeHeader string) *httptest.ResponseRecorder {
+	// Reload the file metadata like the File middleware would
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	ctx.SetFile(f)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	return rr
+}
+
+func TestGetOneShotFileResume(t *testing.T) {
+	ctx, upload, file := newOneShotResumeTestingContext(t, "0123456789")
+
+	// Simulate an interrupted download
+	rr := getOneShotFileForTests(t, ctx, upload, file, "bytes=0-3")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+	require.Equal(t, "0123", rr.Body.String(), "invalid file content")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "one shot file should not be consumed yet")
+	require.Equal(t, int64(4), f.DeliveredBytes, "invalid delivered bytes")
+
+	// The download can't be restarted without a range request
+	rr = getOneShotFileForTests(t, ctx, upload, file, "")
+	context.TestNotFound(t, rr, "download in progress")
+
+	// The download can't be resumed after what has been delivered
+	rr = getOneShotFileForTests(t, ctx, upload, file, "bytes=5-")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code, "invalid response status code")
+
+	// What has been delivered can't be downloaded again
+	for _, rangeHeader := range []string{"bytes=0-", "bytes=2-", "bytes=3-3"} {
+		rr = getOneShotFileForTests(t, ctx, upload, file, rangeHeader)
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code, "invalid response status code for %s", rangeHeader)
+	}
+
+	rr = getOneShotFileForTests(t, ctx, upload, file, "bytes=4-")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+	require.Equal(t, "456789", rr.Body.String(), "invalid file content")
+
+	f, err = ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileRemoved, f.Status, "one shot file should be consumed once fully delivered")
+
+	rr = getOneShotFileForTests(t, ctx, upload, file, "bytes=0-")
+	context.TestNotFound(t, rr, "is not available")
+}
+
+func TestGetOneShotFileResumeReplay(t *testing.T) {
+	ctx, upload, file := newOneShotResumeTestingContext(t, "0123456789")
+
+	rr := getOneShotFileForTests(t, ctx, upload, file, "bytes=0-3")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+
+	rr = getOneShotFileForTests(t, ctx, upload, file, "bytes=0-")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rr.Code, "invalid response status code")
+	require.NotContains(t, rr.Body.String(), "0123", "delivered bytes should not be served again")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "one shot file should not be consumed yet")
+	require.Equal(t, int64(4), f.DeliveredBytes, "invalid delivered bytes")
+	require.False(t, f.DownloadInProgress, "download should not be in progress")
+}
+
+// blockingResponseWriter block the first write to the response until released
+type blockingResponseWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingResponseWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.writing)
+		<-w.release
+	})
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestGetOneShotFileConcurrentDownloads(t *testing.T) {
+	ctx, upload, file := newOneShotResumeTestingContext(t, "0123456789")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+
+	// The first download is blocked while it streams the file
+	c := &context.Context{}
+	c.SetConfig(ctx.GetConfig())
+	c.SetLogger(ctx.GetConfig().NewLogger())
+	c.SetDataBackend(ctx.GetDataBackend())
+	c.SetMetadataBackend(ctx.GetMetadataBackend())
+	c.SetUpload(upload)
+	c.SetFile(f)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	writer := &blockingResponseWriter{ResponseRecorder: c.NewRecorder(req), writing: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		GetFile(c, writer, req)
+	}()
+	<-writer.writing
+
+	// Other downloads are refused whatever the range
+	ctx.SetUpload(upload)
+	for _, rangeHeader := range []string{"", "bytes=0-"} {
+		rr := getOneShotFileForTests(t, ctx, upload, file, rangeHeader)
+		context.TestNotFound(t, rr, "download in progress")
+	}
+
+	close(writer.release)
+	<-done
+
+	context.TestOK(t, writer.ResponseRecorder)
+	require.Equal(t, "0123456789", writer.Body.String(), "invalid file content")
+
+	f, err = ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileRemoved, f.Status, "one shot file should be consumed once fully delivered")
+}
+
+func TestGetOneShotFileResumeWindowExpired(t *testing.T) {
+	ctx, upload, file := newOneShotResumeTestingContext(t, "0123456789")
+
+	rr := getOneShotFileForTests(t, ctx, upload, file, "bytes=0-3")
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid response status code")
+
+	// Move the last download date before the resume window
+	lastDownloadAt := time.Now().Add(-ctx.GetConfig().GetOneShotResumeWindow() - time.Minute)
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	f.LastDownloadAt = &lastDownloadAt
+	err = ctx.GetMetadataBackend().UpdateFile(f, common.FileUploaded)
+	require.NoError(t, err, "unable to update file metadata")
+
+	rr = getOneShotFileForTests(t, ctx, upload, file, "bytes=4-")
+	context.TestNotFound(t, rr, "download resume window has expired")
+
+	f, err = ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileRemoved, f.Status, "one shot file should be consumed once the resume window has expired")
+}
+
 func TestGetStreamingFile(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	backend := data_test.NewBackend()
@@ -192,6 +871,77 @@ func TestGetDeletedFile(t *testing.T) {
 	context.TestNotFound(t, rr, fmt.Sprintf("file %s (%s) is not available : deleted", file.Name, file.ID))
 }
 
+func TestGetFileRequireAuthForDownload(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FeatureAuthentication = common.FeatureEnabled
+	config.RequireAuthForDownload = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	getFile := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		return rr
+	}
+
+	context.TestUnauthorized(t, getFile(), "please login to download this file")
+
+	// Public uploads can be downloaded anonymously
+	upload.Public = true
+	context.TestOK(t, getFile())
+
+	upload.Public = false
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+	context.TestOK(t, getFile())
+}
+
+func TestGetFileMaxTotalDownloadBytes(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	data := "data"
+
+	upload := &common.Upload{MaxTotalDownloadBytes: 6}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	getFile := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		rr := ctx.NewRecorder(req)
+		GetFile(ctx, rr, req)
+		return rr
+	}
+
+	context.TestOK(t, getFile())
+	context.TestOK(t, getFile())
+	context.TestFail(t, getFile(), http.StatusGone, "upload maximum total download bytes have been served")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, int64(2*len(data)), u.DownloadedBytes, "invalid upload downloaded bytes")
+}
+
 func TestGetFileInvalidDownloadDomain(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -312,6 +1062,31 @@ func TestGetFileDataBackendError(t *testing.T) {
 	context.TestInternalServerError(t, rr, "unable to get file from data backend : data backend error")
 }
 
+func TestGetFileCircuitOpen(t *testing.T) {
+	config := common.NewConfiguration()
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	ctx.GetDataBackend().(*data_test.Backend).SetError(data.ErrCircuitOpen)
+	req, err := http.NewRequest("GET", "/file/", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusServiceUnavailable, "data backend temporarily unavailable")
+}
+
 func TestGetFileInvalidStatus(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -361,3 +1136,168 @@ func TestGetFileInvalidStatusStreaming(t *testing.T) {
 	GetFile(ctx, rr, req)
 	context.TestNotFound(t, rr, "is not available")
 }
+
+func TestGetFileDownloadContentSecurityPolicy(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadContentSecurityPolicy = "sandbox"
+	ctx := newTestingContext(config)
+
+	data := "data data data"
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(data))
+	createTestUpload(t, ctx, upload)
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte(data)))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, "sandbox", rr.Header().Get("Content-Security-Policy"), "invalid Content-Security-Policy header")
+	require.Empty(t, rr.Header().Get("X-XSS-Protection"), "unexpected enhanced web security header")
+}
+
+func TestGetFileNoDownloadContentSecurityPolicy(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadContentSecurityPolicy = ""
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+	err := createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// The web UI policy set by the security headers middleware must not apply to downloads
+	rr := ctx.NewRecorder(req)
+	rr.Header().Set("Content-Security-Policy", "default-src 'self'")
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Empty(t, rr.Header().Get("Content-Security-Policy"), "unexpected Content-Security-Policy header")
+}
+
+func TestGetFileDownloadCountry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plik_geoip_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	config := common.NewConfiguration()
+	config.GeoIPDatabase = dir + "/geoip.csv"
+	require.NoError(t, ioutil.WriteFile(config.GeoIPDatabase, []byte("192.0.2.0/24,FR\n198.51.100.0/24,DE\n"), 0600))
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{AllowedCountries: "FR"}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, bytes.NewBuffer([]byte("data")))
+	require.NoError(t, err, "unable to create test file")
+
+	ctx.SetUpload(upload)
+	ctx.SetFile(file)
+
+	req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx.SetSourceIP(net.ParseIP("198.51.100.1"))
+	rr := ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "downloads from DE are not allowed for this upload")
+
+	// Unknown country
+	ctx.SetSourceIP(net.ParseIP("10.0.0.1"))
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestForbidden(t, rr, "unable to resolve the country of your IP address")
+
+	config.GeoIPFailOpen = true
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	ctx.SetSourceIP(net.ParseIP("192.0.2.1"))
+	rr = ctx.NewRecorder(req)
+	GetFile(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestGetFileConcurrentDownloadCount(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{MaxTotalDownloadBytes: 1000000}
+	file := upload.NewFile()
+	file.Name = "file"
+	file.Status = common.FileUploaded
+	file.Size = int64(len(content))
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString(content))
+	require.NoError(t, err, "unable to create test file")
+
+	// Each download is served by its own request context with its own copy of the metadata
+	downloads := 50
+	var wg sync.WaitGroup
+	responses := make(chan *httptest.ResponseRecorder, downloads)
+	for i := 0; i < downloads; i++ {
+		u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+		require.NoError(t, err, "unable to get upload")
+		f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+
+		c := &context.Context{}
+		c.SetConfig(ctx.GetConfig())
+		c.SetLogger(ctx.GetConfig().NewLogger())
+		c.SetDataBackend(ctx.GetDataBackend())
+		c.SetMetadataBackend(ctx.GetMetadataBackend())
+		c.SetUpload(u)
+		c.SetFile(f)
+
+		req, err := http.NewRequest("GET", "/file/"+upload.ID+"/"+file.ID+"/"+file.Name, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		rr := c.NewRecorder(req)
+
+		// Assertions can't be made outside of the test goroutine
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			GetFile(c, rr, req)
+			responses <- rr
+		}()
+	}
+	wg.Wait()
+	close(responses)
+
+	for rr := range responses {
+		context.TestOK(t, rr)
+	}
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, downloads, u.DownloadCount, "invalid upload download count")
+	require.Equal(t, int64(downloads*len(content)), u.DownloadedBytes, "invalid upload downloaded bytes")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, downloads, f.DownloadCount, "invalid file download count")
+}
@@ -29,5 +29,12 @@ func GetUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request
 	// Hide private information (IP, data backend details, User ID, Login/Password, ...)
 	upload.Sanitize(config)
 
-	common.WriteJSONResponse(resp, upload)
+	// Print upload metadata in the json response using the negotiated API version
+	bytes, err := common.MarshalUpload(upload, ctx.GetAPIVersion())
+	if err != nil {
+		ctx.InternalServerError("unable to serialize upload", err)
+		return
+	}
+
+	_, _ = resp.Write(bytes)
 }
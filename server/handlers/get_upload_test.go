@@ -23,6 +23,10 @@ func TestGetUpload(t *testing.T) {
 	upload.Password = "secret"
 	file := upload.NewFile()
 	file.Name = "file"
+	file.Size = 42
+	file.Type = "text/plain"
+	file.Md5 = "12345"
+	file.DownloadCount = 3
 	createTestUpload(t, ctx, upload)
 	ctx.SetUpload(upload)
 
@@ -49,9 +53,44 @@ func TestGetUpload(t *testing.T) {
 	require.Len(t, uploadResult.Files, 1, "invalid upload files")
 	require.Equal(t, file.ID, uploadResult.Files[0].ID, "invalid upload files")
 	require.Equal(t, file.Name, uploadResult.Files[0].Name, "invalid upload files")
+	require.Equal(t, file.Size, uploadResult.Files[0].Size, "invalid file size")
+	require.Equal(t, file.Type, uploadResult.Files[0].Type, "invalid file type")
+	require.Equal(t, file.Md5, uploadResult.Files[0].Md5, "invalid file md5")
+	require.Equal(t, file.DownloadCount, uploadResult.Files[0].DownloadCount, "invalid file download count")
 	require.True(t, uploadResult.IsAdmin, "invalid upload admin status")
 }
 
+func TestGetUploadAPIVersion1(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetAPIVersion(common.APIVersion1)
+
+	upload := &common.Upload{IsAdmin: true}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("GET", "/upload/"+upload.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUpload(ctx, rr, req)
+
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var uploadResult = &common.UploadV1{}
+	err = json.Unmarshal(respBody, uploadResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+
+	require.Equal(t, upload.ID, uploadResult.ID, "invalid upload id")
+	require.Len(t, uploadResult.Files, 1, "invalid upload files")
+	require.Equal(t, file.Name, uploadResult.Files[file.ID].Name, "invalid upload files")
+}
+
 func TestGetUploadMissingUpload(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	gocontext "context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -155,13 +156,16 @@ func GoogleCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Re
 		conf.Endpoint = customEndpoint.(oauth2.Endpoint)
 	}
 
-	token, err := conf.Exchange(oauth2.NoContext, code)
+	// Outbound connections trust the TrustedCABundle
+	oauthCtx := gocontext.WithValue(gocontext.Background(), oauth2.HTTPClient, config.NewHTTPClient(0))
+
+	token, err := conf.Exchange(oauthCtx, code)
 	if err != nil {
 		ctx.InternalServerError("unable to get user info from Google API (1)", err)
 		return
 	}
 
-	client, err := api_oauth2.New(conf.Client(oauth2.NoContext, token))
+	client, err := api_oauth2.New(conf.Client(oauthCtx, token))
 	if err != nil {
 		ctx.InternalServerError("unable to get user info from Google API (2)", err)
 		return
@@ -186,6 +190,15 @@ func GoogleCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Re
 	}
 
 	if user == nil {
+		if !config.AutoProvisionUsers {
+			ctx.Forbidden("user account does not exist, please ask an administrator to create it")
+			return
+		}
+		if !config.IsAllowedEmailDomain(userInfo.Email) {
+			ctx.Forbidden("unauthorized email domain")
+			return
+		}
+
 		if ctx.IsWhitelisted() {
 			// Create new user
 			user = common.NewUser(common.ProviderGoogle, userInfo.Email)
@@ -224,13 +237,10 @@ func GoogleCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Re
 		}
 	}
 
-	// Set Plik session cookie and xsrf cookie
-	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	if err != nil {
-		ctx.InternalServerError("unable to generate session cookies", err)
+	// Open a new session and set Plik session cookie and xsrf cookie
+	if !login(ctx, resp, req, user) {
+		return
 	}
-	http.SetCookie(resp, sessionCookie)
-	http.SetCookie(resp, xsrfCookie)
 
 	http.Redirect(resp, req, config.Path+"/#/login", http.StatusMovedPermanently)
 }
@@ -39,6 +39,7 @@ func TestGoogleLogin(t *testing.T) {
 
 	origin := "https://plik.root.gg"
 	req.Header.Set("referer", origin)
+	req.Host = "plik.root.gg"
 
 	rr := ctx.NewRecorder(req)
 	GoogleLogin(ctx, rr, req)
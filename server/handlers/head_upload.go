@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// HeadUpload tell whether an upload exists and can be downloaded without authentication.
+// Clients polling for an upload get its expiration date and size in the response headers.
+// Password protected uploads reveal their existence but not their content, nothing is downloaded
+// and the upload expiration date is not extended.
+func HeadUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	uploadID := mux.Vars(req)["uploadID"]
+	if uploadID == "" {
+		ctx.MissingParameter("upload id")
+		return
+	}
+
+	upload, err := ctx.GetUploadByID(uploadID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload metadata", err)
+		return
+	}
+	if upload == nil {
+		ctx.NotFound("upload %s not found", uploadID)
+		return
+	}
+
+	if upload.IsExpired() {
+		ctx.Expired("upload %s has expired", uploadID)
+		return
+	}
+
+	if !checkDownloadQuota(ctx, upload) {
+		return
+	}
+
+	files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload files", err)
+		return
+	}
+
+	var count int
+	var size int64
+	for _, file := range files {
+		if file.Status == common.FileUploaded {
+			count++
+			size += file.Size
+		}
+	}
+
+	if upload.ExpireAt != nil {
+		resp.Header().Set("X-Plik-Expire-At", upload.ExpireAt.UTC().Format(time.RFC3339))
+	}
+	resp.Header().Set("X-Plik-Files", strconv.Itoa(count))
+	resp.Header().Set("X-Plik-Size", strconv.FormatInt(size, 10))
+	if upload.ProtectedByPassword {
+		resp.Header().Set("X-Plik-Password-Protected", "true")
+	}
+
+	resp.WriteHeader(http.StatusOK)
+}
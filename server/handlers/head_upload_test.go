@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func getHeadUploadRequest(t *testing.T, uploadID string) *http.Request {
+	req, err := http.NewRequest("HEAD", "/upload/"+uploadID, nil)
+	require.NoError(t, err, "unable to create new request")
+	return mux.SetURLVars(req, map[string]string{"uploadID": uploadID})
+}
+
+func TestHeadUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{ExtendTTL: true, TTL: 86400}
+	upload.ProtectedByPassword = true
+	for i, status := range []string{common.FileUploaded, common.FileUploaded, common.FileMissing} {
+		file := upload.NewFile()
+		file.Name = "file"
+		file.Status = status
+		file.Size = int64(10 * (i + 1))
+	}
+	createTestUpload(t, ctx, upload)
+	require.NotNil(t, upload.ExpireAt, "missing upload expiration date")
+	expireAt := *upload.ExpireAt
+
+	rr := ctx.NewRecorder(getHeadUploadRequest(t, upload.ID))
+	HeadUpload(ctx, rr, getHeadUploadRequest(t, upload.ID))
+	context.TestOK(t, rr)
+
+	require.Equal(t, expireAt.UTC().Format(time.RFC3339), rr.Header().Get("X-Plik-Expire-At"), "invalid expire at header")
+	require.Equal(t, "2", rr.Header().Get("X-Plik-Files"), "invalid files header")
+	require.Equal(t, "30", rr.Header().Get("X-Plik-Size"), "invalid size header")
+	require.Equal(t, "true", rr.Header().Get("X-Plik-Password-Protected"), "invalid password protected header")
+	require.Equal(t, 0, rr.Body.Len(), "unexpected response body")
+
+	// Polling must not extend the upload expiration date
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Equal(t, expireAt.Unix(), u.ExpireAt.Unix(), "upload expiration date must not be extended")
+}
+
+func TestHeadUploadNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	rr := ctx.NewRecorder(getHeadUploadRequest(t, "missing"))
+	HeadUpload(ctx, rr, getHeadUploadRequest(t, "missing"))
+	context.TestNotFound(t, rr, "upload missing not found")
+}
+
+func TestHeadUploadExpired(t *testing.T) {
+	config := common.NewConfiguration()
+	ctx := newTestingContext(config)
+
+	expireAt := time.Now().Add(-time.Hour)
+	upload := &common.Upload{ExpireAt: &expireAt}
+	createTestUpload(t, ctx, upload)
+
+	rr := ctx.NewRecorder(getHeadUploadRequest(t, upload.ID))
+	HeadUpload(ctx, rr, getHeadUploadRequest(t, upload.ID))
+	context.TestNotFound(t, rr, "has expired")
+
+	config.RevealGoneReason = true
+
+	rr = ctx.NewRecorder(getHeadUploadRequest(t, upload.ID))
+	HeadUpload(ctx, rr, getHeadUploadRequest(t, upload.ID))
+	context.TestFail(t, rr, http.StatusGone, "has expired")
+}
+
+func TestHeadUploadDownloadQuotaExceeded(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{MaxTotalDownloadBytes: 10, DownloadedBytes: 10}
+	createTestUpload(t, ctx, upload)
+
+	rr := ctx.NewRecorder(getHeadUploadRequest(t, upload.ID))
+	HeadUpload(ctx, rr, getHeadUploadRequest(t, upload.ID))
+	context.TestFail(t, rr, http.StatusGone, "upload maximum total download bytes have been served")
+}
@@ -67,13 +67,10 @@ func LocalLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	// Set Plik session cookie and xsrf cookie
-	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	if err != nil {
-		ctx.InternalServerError("unable to generate session cookies", err)
+	// Open a new session and set Plik session cookie and xsrf cookie
+	if !login(ctx, resp, req, user) {
+		return
 	}
-	http.SetCookie(resp, sessionCookie)
-	http.SetCookie(resp, xsrfCookie)
 
 	_, _ = resp.Write([]byte("ok"))
 }
@@ -90,6 +90,11 @@ func RemoveUserUploads(ctx *context.Context, resp http.ResponseWriter, req *http
 		tokenStr = token.Token
 	}
 
+	// Bulk deletions are authorized once without upload ID
+	if !checkAuthorization(ctx, common.AuthorizationActionDelete, nil, nil) {
+		return
+	}
+
 	deleted, err := ctx.GetMetadataBackend().RemoveUserUploads(userID, tokenStr)
 	if err != nil {
 		ctx.InternalServerError("unable to delete user uploads", err)
@@ -131,6 +136,11 @@ func DeleteAccount(ctx *context.Context, resp http.ResponseWriter, req *http.Req
 		return
 	}
 
+	// Deleting the account deletes all the user uploads
+	if !checkAuthorization(ctx, common.AuthorizationActionDelete, nil, nil) {
+		return
+	}
+
 	_, err := ctx.GetMetadataBackend().DeleteUser(user.ID)
 	if err != nil {
 		ctx.InternalServerError("unable to delete user account", err)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 
 	"testing"
 
@@ -102,6 +103,47 @@ func TestDeleteUserNoUser(t *testing.T) {
 	context.TestUnauthorized(t, rr, "missing user, please login first")
 }
 
+func TestDeleteUserNotAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &common.AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, common.AuthorizationActionDelete, request.Action, "invalid action")
+		require.Empty(t, request.UploadID, "invalid upload id")
+
+		_, _ = resp.Write([]byte(`{"allow":false}`))
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create test user")
+	ctx.SetUser(user)
+
+	upload := &common.Upload{}
+	upload.User = user.ID
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/me", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	DeleteAccount(ctx, rr, req)
+	context.TestForbidden(t, rr, "delete not allowed")
+
+	u, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unexpected get user error")
+	require.NotNil(t, u, "user should not have been removed")
+
+	up, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unexpected get upload error")
+	require.NotNil(t, up, "upload should not have been removed")
+}
+
 func TestGetUserUploads(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -300,6 +342,43 @@ func TestRemoveUserUploads(t *testing.T) {
 	require.Equal(t, "2 uploads removed", string(respBody), "Invalid result message")
 }
 
+func TestRemoveUserUploadsNotAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &common.AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, common.AuthorizationActionDelete, request.Action, "invalid action")
+		require.Empty(t, request.UploadID, "invalid upload id")
+
+		_, _ = resp.Write([]byte(`{"allow":false}`))
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx := newTestingContext(config)
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+
+	upload := &common.Upload{}
+	upload.User = user.ID
+	createTestUpload(t, ctx, upload)
+
+	req, err := http.NewRequest("DELETE", "/me/uploads", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RemoveUserUploads(ctx, rr, req)
+	context.TestForbidden(t, rr, "delete not allowed")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unexpected get upload error")
+	require.NotNil(t, u, "upload should not have been removed")
+}
+
 func TestRemoveUserUploadsNoUser(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
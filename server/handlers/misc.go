@@ -7,12 +7,14 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/qr"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
 )
 
 // GetVersion return the build information.
@@ -27,11 +29,32 @@ func GetVersion(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 
 // GetConfiguration return the server configuration
 func GetConfiguration(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
-	common.WriteJSONResponse(resp, ctx.GetConfig())
+	config := ctx.GetConfig()
+
+	// The server banner might have been updated at runtime by an admin
+	setting, err := ctx.GetMetadataBackend().GetSetting(common.ServerBannerSettingKey)
+	if err != nil {
+		ctx.InternalServerError("unable to get server banner", err)
+		return
+	}
+	if setting != nil {
+		c := *config
+		c.ServerBanner = setting.Value
+		config = &c
+	}
+
+	common.WriteJSONResponse(resp, config)
 }
 
-// Logout return the server configuration
+// Logout close the current session and delete the session cookies
 func Logout(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	if session := ctx.GetSession(); session != nil {
+		_, err := ctx.GetMetadataBackend().DeleteSession(session.ID)
+		if err != nil {
+			ctx.GetLogger().Warningf("unable to delete session : %s", err)
+		}
+	}
+
 	common.Logout(resp, ctx.GetAuthenticator())
 }
 
@@ -82,13 +105,198 @@ func Health(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
 	_, _ = io.WriteString(resp, "ok\n")
 }
 
+// Ready is a handler to check that the service can serve requests
+func Ready(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	if data.GetCircuitState(ctx.GetDataBackend()) == data.CircuitOpen {
+		ctx.ServiceUnavailable()
+		return
+	}
+
+	_, _ = io.WriteString(resp, "ok\n")
+}
+
 // If a download domain is specified verify that the request comes from this specific domain
 func checkDownloadDomain(ctx *context.Context) bool {
 	config := ctx.GetConfig()
 	req := ctx.GetReq()
 
-	if !config.IsValidDownloadDomain(req.Host) {
-		ctx.BadRequest("Invalid download domain %s", req.Host)
+	host := config.GetRequestHost(req)
+	if !config.IsValidDownloadDomain(host) {
+		ctx.BadRequest("Invalid download domain %s", host)
+		return false
+	}
+
+	return true
+}
+
+// If downloads require authentication verify that the request is authenticated or allowed to manage the upload
+func checkDownloadAuthentication(ctx *context.Context, upload *common.Upload) bool {
+	if !ctx.GetConfig().RequireAuthForDownload || upload.Public || upload.IsAdmin {
+		return true
+	}
+
+	if ctx.GetUser() == nil {
+		ctx.Unauthorized("please login to download this file")
+		return false
+	}
+
+	return true
+}
+
+// Run the checks shared by all the downloads of an upload ( authentication, authorization webhook, country, total
+// download bytes ), file is nil for the downloads of the whole upload
+func checkDownloadAccess(ctx *context.Context, upload *common.Upload, file *common.File) bool {
+	if !checkDownloadAuthentication(ctx, upload) {
+		return false
+	}
+
+	if !checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file) {
+		return false
+	}
+
+	if !checkDownloadCountry(ctx, upload) {
+		return false
+	}
+
+	return checkDownloadQuota(ctx, upload)
+}
+
+// Once the upload maximum total download bytes have been served its files can't be downloaded anymore
+func checkDownloadQuota(ctx *context.Context, upload *common.Upload) bool {
+	if upload.IsDownloadQuotaExceeded() {
+		ctx.Fail("upload maximum total download bytes have been served", nil, http.StatusGone)
+		return false
+	}
+
+	return true
+}
+
+// Count the bytes served for the upload
+func addDownloadedBytes(ctx *context.Context, upload *common.Upload, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+
+	_, err := ctx.GetMetadataBackend().AddUploadDownloadedBytes(upload, bytes)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to count downloaded bytes : %s", err)
+	}
+}
+
+// Count a new download of the file
+func addFileDownload(ctx *context.Context, file *common.File) {
+	_, err := ctx.GetMetadataBackend().IncrementFileDownloadCount(file)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to count file download : %s", err)
+	}
+}
+
+// isOneShotConsumed return true if the file of a OneShot upload has been removed once downloaded
+func isOneShotConsumed(upload *common.Upload, file *common.File) bool {
+	if !upload.OneShot || file.DownloadCount == 0 {
+		return false
+	}
+	return file.Status == common.FileRemoved || file.Status == common.FileDeleted
+}
+
+// Count a new download of the upload, it is also counted as a download to notify if a download
+// notification webhook is configured on the server or on the upload
+func addUploadDownload(ctx *context.Context, upload *common.Upload) {
+	_, err := ctx.GetMetadataBackend().IncrementUploadDownloadCount(upload)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to count upload download : %s", err)
+	}
+
+	if ctx.GetConfig().DownloadNotificationWebhook == "" && upload.Webhook == "" {
+		return
+	}
+
+	err = ctx.GetMetadataBackend().AddUploadPendingDownload(upload.ID)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to count upload download : %s", err)
+	}
+}
+
+// Save the last access date of the upload, the expiration date of uploads with an InactivityTTL is pushed back
+func touchUpload(ctx *context.Context, upload *common.Upload) {
+	if !upload.SetAccessed(time.Now()) {
+		return
+	}
+
+	err := ctx.GetMetadataBackend().UpdateUploadLastAccess(upload)
+	if err != nil {
+		ctx.GetLogger().Warningf("unable to update upload last access date : %s", err)
+	}
+}
+
+// If downloads of the upload are restricted by country check the country of the client IP address
+func checkDownloadCountry(ctx *context.Context, upload *common.Upload) bool {
+	config := ctx.GetConfig()
+	if !config.IsDownloadCountryRestricted(upload) {
+		return true
+	}
+
+	var country string
+	if db := config.GetGeoIPDatabase(); db != nil && ctx.GetSourceIP() != nil {
+		country = db.Country(ctx.GetSourceIP())
+	}
+
+	if country == "" {
+		if config.GeoIPFailOpen {
+			ctx.GetLogger().Warningf("unable to resolve the country of %s, allowing the download anyway", config.FormatClientIP(ctx.GetSourceIP()))
+			return true
+		}
+		ctx.Forbidden("unable to resolve the country of your IP address")
+		return false
+	}
+
+	if !config.IsDownloadCountryAllowed(upload, country) {
+		ctx.Forbidden("downloads from %s are not allowed for this upload", country)
+		return false
+	}
+
+	return true
+}
+
+// If an authorization webhook is configured ask it whether the action is allowed
+func checkAuthorization(ctx *context.Context, action string, upload *common.Upload, file *common.File) bool {
+	config := ctx.GetConfig()
+	if config.AuthorizationWebhookURL == "" {
+		return true
+	}
+
+	request := &common.AuthorizationRequest{Action: action}
+	if ctx.GetSourceIP() != nil {
+		request.SourceIP = ctx.GetSourceIP().String()
+	}
+	if user := ctx.GetUser(); user != nil {
+		request.User = user.ID
+	}
+	if upload != nil {
+		request.UploadID = upload.ID
+	}
+	if file != nil {
+		request.FileID = file.ID
+		request.FileName = file.Name
+	}
+
+	response, err := config.Authorize(request)
+	if err != nil {
+		if config.AuthorizationWebhookFailOpen {
+			ctx.GetLogger().Warningf("unable to authorize %s request, allowing it anyway : %s", action, err)
+			return true
+		}
+		ctx.GetLogger().Warningf("unable to authorize %s request : %s", action, err)
+		ctx.Forbidden("unable to authorize request")
+		return false
+	}
+
+	if !response.Allow {
+		if response.Reason != "" {
+			ctx.Forbidden("%s not allowed : %s", action, response.Reason)
+		} else {
+			ctx.Forbidden("%s not allowed", action)
+		}
 		return false
 	}
 
@@ -114,6 +322,12 @@ func getRedirectURL(ctx *context.Context, callbackPath string) (redirectURL stri
 	}
 	redirectURL += callbackPath
 
+	// Prevent open redirections by forged referer headers
+	parsedRedirectURL, err := url.Parse(redirectURL)
+	if err != nil || !ctx.GetConfig().IsAllowedRedirectURL(parsedRedirectURL, ctx.GetConfig().GetRequestHost(req)) {
+		return "", common.NewHTTPError("redirect URL not allowed", nil, http.StatusBadRequest)
+	}
+
 	return redirectURL, nil
 }
 
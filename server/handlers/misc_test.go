@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
 	data_test "github.com/root-gg/plik/server/data/testing"
 	"github.com/root-gg/plik/server/metadata"
 )
@@ -186,6 +189,7 @@ func TestGetRedirectionURL(t *testing.T) {
 
 	req, err := http.NewRequest("GET", "/auth", bytes.NewBuffer([]byte{}))
 	require.NoError(t, err, "unable to create new request")
+	req.Host = "plik.root.gg"
 
 	ctx.SetReq(req)
 
@@ -209,6 +213,51 @@ func TestGetRedirectionURL(t *testing.T) {
 	redirectURL, err = getRedirectURL(ctx, "/callback")
 	require.NoError(t, err)
 	require.Equal(t, "https://plik.root.gg/callback", redirectURL)
+
+	// From another site
+	req.Header.Set("referer", "https://evil.com")
+	_, err = getRedirectURL(ctx, "/callback")
+	common.RequireError(t, err, "redirect URL not allowed")
+}
+
+func TestGetRedirectionURLAllowedRedirectURLs(t *testing.T) {
+	config := common.NewConfiguration()
+	config.AllowedRedirectURLs = []string{"https://plik.root.gg", "https://portal.root.gg/plik"}
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("GET", "/auth", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Host = "internal.root.gg"
+
+	ctx.SetReq(req)
+
+	req.Header.Set("referer", "https://plik.root.gg/")
+	redirectURL, err := getRedirectURL(ctx, "/callback")
+	require.NoError(t, err)
+	require.Equal(t, "https://plik.root.gg/callback", redirectURL)
+
+	// Same origin is not allowed anymore
+	req.Header.Set("referer", "https://internal.root.gg/")
+	_, err = getRedirectURL(ctx, "/callback")
+	common.RequireError(t, err, "redirect URL not allowed")
+
+	// Scheme must match
+	req.Header.Set("referer", "http://plik.root.gg/")
+	_, err = getRedirectURL(ctx, "/callback")
+	common.RequireError(t, err, "redirect URL not allowed")
+
+	// Path must match
+	ctx.GetConfig().Path = "/plik"
+	req.Header.Set("referer", "https://portal.root.gg/")
+	redirectURL, err = getRedirectURL(ctx, "/callback")
+	require.NoError(t, err)
+	require.Equal(t, "https://portal.root.gg/plik/callback", redirectURL)
+
+	ctx.GetConfig().Path = "/plikevil"
+	_, err = getRedirectURL(ctx, "/callback")
+	common.RequireError(t, err, "redirect URL not allowed")
 }
 
 func TestGetRedirectionURLWithPath(t *testing.T) {
@@ -218,6 +267,7 @@ func TestGetRedirectionURLWithPath(t *testing.T) {
 
 	req, err := http.NewRequest("GET", "/logout", bytes.NewBuffer([]byte{}))
 	require.NoError(t, err, "unable to create new request")
+	req.Host = "plik.root.gg"
 
 	ctx.SetReq(req)
 
@@ -261,6 +311,105 @@ func TestCheckDownloadDomain(t *testing.T) {
 	context.TestBadRequest(t, rr, "Invalid download domain invalid.domain")
 }
 
+func TestCheckDownloadDomainForwardedHost(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DownloadDomain = "https://plik.root.gg"
+	config.UseForwardedHeaders = true
+	config.TrustedProxies = []string{"10.0.0.1"}
+	require.NoError(t, config.Initialize())
+
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("GET", "/files/my.file", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Host = "plik.internal:8080"
+	req.Header.Set("X-Forwarded-Host", "plik.root.gg")
+
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr := ctx.NewRecorder(req)
+	checkDownloadDomain(ctx)
+	context.TestOK(t, rr)
+
+	// Forwarded headers of untrusted peers are ignored
+	req.RemoteAddr = "1.2.3.4:1234"
+	rr = ctx.NewRecorder(req)
+	checkDownloadDomain(ctx)
+	context.TestBadRequest(t, rr, "Invalid download domain plik.internal:8080")
+}
+
+func newAuthorizationTestServer(t *testing.T, status int, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &common.AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, common.AuthorizationActionDownload, request.Action, "invalid action")
+		require.Equal(t, "upload", request.UploadID, "invalid upload id")
+		require.Equal(t, "file", request.FileID, "invalid file id")
+
+		resp.WriteHeader(status)
+		_, _ = resp.Write([]byte(response))
+	}))
+}
+
+func TestCheckAuthorization(t *testing.T) {
+	upload := &common.Upload{ID: "upload"}
+	file := &common.File{ID: "file", Name: "file.txt"}
+
+	req, err := http.NewRequest("GET", "/file/upload/file/file.txt", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// No webhook
+	ctx := newTestingContext(common.NewConfiguration())
+	rr := ctx.NewRecorder(req)
+	require.True(t, checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file))
+	context.TestOK(t, rr)
+
+	// Allowed
+	server := newAuthorizationTestServer(t, http.StatusOK, `{"allow":true}`)
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx = newTestingContext(config)
+	rr = ctx.NewRecorder(req)
+	require.True(t, checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file))
+	context.TestOK(t, rr)
+
+	// Denied
+	server = newAuthorizationTestServer(t, http.StatusOK, `{"allow":false,"reason":"quarantined"}`)
+	defer server.Close()
+
+	config.AuthorizationWebhookURL = server.URL
+	rr = ctx.NewRecorder(req)
+	require.False(t, checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file))
+	context.TestForbidden(t, rr, "download not allowed : quarantined")
+}
+
+func TestCheckAuthorizationWebhookError(t *testing.T) {
+	upload := &common.Upload{ID: "upload"}
+	file := &common.File{ID: "file", Name: "file.txt"}
+
+	server := newAuthorizationTestServer(t, http.StatusInternalServerError, "")
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "/file/upload/file/file.txt", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Fail closed
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx := newTestingContext(config)
+	rr := ctx.NewRecorder(req)
+	require.False(t, checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file))
+	context.TestForbidden(t, rr, "unable to authorize request")
+
+	// Fail open
+	config.AuthorizationWebhookFailOpen = true
+	rr = ctx.NewRecorder(req)
+	require.True(t, checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file))
+	context.TestOK(t, rr)
+}
+
 func TestHealth(t *testing.T) {
 	config := common.NewConfiguration()
 	require.NoError(t, config.Initialize())
@@ -273,3 +422,36 @@ func TestHealth(t *testing.T) {
 	Health(ctx, rr, req)
 	context.TestOK(t, rr)
 }
+
+func TestReady(t *testing.T) {
+	config := common.NewConfiguration()
+	require.NoError(t, config.Initialize())
+
+	req, err := http.NewRequest("GET", "/ready", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx := newTestingContext(config)
+	rr := ctx.NewRecorder(req)
+	Ready(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestReadyCircuitOpen(t *testing.T) {
+	config := common.NewConfiguration()
+	require.NoError(t, config.Initialize())
+
+	backend := data_test.NewBackend()
+	backend.SetError(data.ErrWriteTimeout)
+	breaker := data.NewCircuitBreakerBackend(backend, 1, time.Hour)
+	_, err := breaker.GetFile(&common.File{ID: "file"})
+	require.Equal(t, data.ErrWriteTimeout, err, "invalid error")
+
+	req, err := http.NewRequest("GET", "/ready", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	ctx := newTestingContext(config)
+	ctx.SetDataBackend(breaker)
+	rr := ctx.NewRecorder(req)
+	Ready(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusServiceUnavailable, "data backend temporarily unavailable")
+}
@@ -88,7 +88,7 @@ func OvhLogin(ctx *context.Context, resp http.ResponseWriter, req *http.Request)
 	ovhReq.Header.Add("Content-type", "application/json")
 
 	// Do request
-	client := &http.Client{}
+	client := config.NewHTTPClient(0)
 	ovhResp, err := client.Do(ovhReq)
 	if err != nil {
 		ctx.InternalServerError(fmt.Sprintf("error with OVH API %s", u), err)
@@ -223,7 +223,7 @@ func OvhCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 	ovhReq.Header.Add("X-Ovh-Signature", fmt.Sprintf("$1$%x", h.Sum(nil)))
 
 	// Do request
-	client := &http.Client{}
+	client := config.NewHTTPClient(0)
 	ovhResp, err := client.Do(ovhReq)
 	if err != nil {
 		ctx.InternalServerError(fmt.Sprintf("error with OVH API %s", url), err)
@@ -252,6 +252,15 @@ func OvhCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 	}
 
 	if user == nil {
+		if !config.AutoProvisionUsers {
+			ctx.Forbidden("user account does not exist, please ask an administrator to create it")
+			return
+		}
+		if !config.IsAllowedEmailDomain(userInfo.Email) {
+			ctx.Forbidden("unauthorized email domain")
+			return
+		}
+
 		if ctx.IsWhitelisted() {
 			// Create new user
 			user = common.NewUser(common.ProviderOVH, userInfo.Nichandle)
@@ -271,13 +280,10 @@ func OvhCallback(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 		}
 	}
 
-	// Set Plik session cookie and xsrf cookie
-	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	if err != nil {
-		ctx.InternalServerError("unable to generate session cookies", err)
+	// Open a new session and set Plik session cookie and xsrf cookie
+	if !login(ctx, resp, req, user) {
+		return
 	}
-	http.SetCookie(resp, sessionCookie)
-	http.SetCookie(resp, xsrfCookie)
 
 	http.Redirect(resp, req, config.Path+"/#/login", http.StatusMovedPermanently)
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 
 	"net/url"
 	"strconv"
@@ -31,6 +32,7 @@ func TestOVHLogin(t *testing.T) {
 
 	origin := "https://plik.root.gg"
 	req.Header.Set("referer", origin)
+	req.Host = "plik.root.gg"
 
 	ovhUserConsentResponse := &ovhUserConsentResponse{
 		ValidationURL: "http://127.0.0.1:8765/auth/validation",
@@ -107,6 +109,7 @@ func TestOVHLoginInvalidOVHResponse(t *testing.T) {
 
 	origin := "https://plik.root.gg"
 	req.Header.Set("referer", origin)
+	req.Host = "plik.root.gg"
 
 	handler := func(resp http.ResponseWriter, req *http.Request) {
 		resp.WriteHeader(http.StatusInternalServerError)
@@ -133,6 +136,7 @@ func TestOVHLoginInvalidOVHResponse2(t *testing.T) {
 
 	origin := "https://plik.root.gg"
 	req.Header.Set("referer", origin)
+	req.Host = "plik.root.gg"
 
 	handler := func(resp http.ResponseWriter, req *http.Request) {
 		resp.Write([]byte("invalid json"))
@@ -432,6 +436,70 @@ func TestOVHCallbackCreateUserNotWhitelisted(t *testing.T) {
 	context.TestForbidden(t, rr, "unable to create user from untrusted source IP address")
 }
 
+func TestOVHCallbackProvisioning(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetConfig().OvhAuthentication = true
+	ctx.GetConfig().OvhAPIEndpoint = "http://127.0.0.1:" + strconv.Itoa(common.APIMockServerDefaultPort)
+	ctx.GetConfig().OvhAPIKey = "ovh_api_key"
+	ctx.GetConfig().OvhAPISecret = "ovh_api_secret"
+
+	ovhUserResponse := &ovhUserResponse{
+		Nichandle: "plik",
+		FirstName: "plik",
+		LastName:  "root-gg",
+		Email:     "plik@root.gg",
+	}
+
+	handler := func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/me" {
+			responseBody, err := json.Marshal(ovhUserResponse)
+			require.NoError(t, err, "unable to marshal OVH user response")
+			resp.Write(responseBody)
+			return
+		}
+		resp.WriteHeader(http.StatusInternalServerError)
+	}
+
+	shutdown, err := common.StartAPIMockServer(http.HandlerFunc(handler))
+	defer shutdown()
+	require.NoError(t, err, "unable to start OVH api mock server")
+
+	callback := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/auth/ovh/callback", bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+
+		session := jwt.New(jwt.SigningMethodHS256)
+		session.Claims.(jwt.MapClaims)["ovh-consumer-key"] = "consumerKey"
+		session.Claims.(jwt.MapClaims)["ovh-api-endpoint"] = "http://127.0.0.1:" + strconv.Itoa(common.APIMockServerDefaultPort)
+		sessionString, err := session.SignedString([]byte(ctx.GetConfig().OvhAPISecret))
+		require.NoError(t, err, "unable to generate session string")
+		req.AddCookie(&http.Cookie{Name: "plik-ovh-session", Value: sessionString, Path: "/"})
+
+		rr := ctx.NewRecorder(req)
+		OvhCallback(ctx, rr, req)
+		return rr
+	}
+
+	ctx.GetConfig().AllowedEmailDomains = []string{"plik.io"}
+	context.TestForbidden(t, callback(), "unauthorized email domain")
+
+	ctx.GetConfig().AllowedEmailDomains = nil
+	ctx.GetConfig().AutoProvisionUsers = false
+	context.TestForbidden(t, callback(), "user account does not exist, please ask an administrator to create it")
+
+	user, err := ctx.GetMetadataBackend().GetUser("ovh:plik")
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, user, "user should not have been created")
+
+	// Existing accounts can login even if they are not allowed to be provisioned
+	ctx.GetConfig().AllowedEmailDomains = []string{"plik.io"}
+	err = ctx.GetMetadataBackend().CreateUser(common.NewUser(common.ProviderOVH, "plik"))
+	require.NoError(t, err, "unable to create user")
+	require.Equal(t, http.StatusMovedPermanently, callback().Code, "handler returned wrong status code")
+}
+
 func TestOVHCallbackAuthDisabled(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
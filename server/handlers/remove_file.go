@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -16,7 +17,8 @@ func RemoveFile(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 	}
 
 	// Check authorization
-	if !upload.Removable && !upload.IsAdmin {
+	// Uploads protected by a management password are only removable by their admins
+	if !upload.IsAdmin && (!upload.Removable || upload.IsProtectedByManagementPassword()) {
 		ctx.Forbidden("you are not allowed to remove files from this upload")
 		return
 	}
@@ -28,6 +30,10 @@ func RemoveFile(ctx *context.Context, resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	if !checkAuthorization(ctx, common.AuthorizationActionDelete, upload, file) {
+		return
+	}
+
 	// Delete file
 	err := ctx.GetMetadataBackend().RemoveFile(file)
 	if err != nil {
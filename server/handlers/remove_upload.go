@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -16,11 +17,16 @@ func RemoveUpload(ctx *context.Context, resp http.ResponseWriter, req *http.Requ
 	}
 
 	// Check authorization
-	if !upload.Removable && !upload.IsAdmin {
+	// Uploads protected by a management password are only removable by their admins
+	if !upload.IsAdmin && (!upload.Removable || upload.IsProtectedByManagementPassword()) {
 		ctx.Forbidden("you are not allowed to remove this upload")
 		return
 	}
 
+	if !checkAuthorization(ctx, common.AuthorizationActionDelete, upload, nil) {
+		return
+	}
+
 	err := ctx.GetMetadataBackend().RemoveUpload(upload.ID)
 	if err != nil {
 		ctx.InternalServerError("unable tuto delete upload", err)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 
 	"testing"
 
@@ -79,6 +80,49 @@ func TestRemoveUploadNotAdmin(t *testing.T) {
 	context.TestForbidden(t, rr, "you are not allowed to remove this upload")
 }
 
+func TestRemoveUploadRemovableWithManagementPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{Removable: true, ManagementPassword: "hash"}
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("DELETE", "/upload/"+upload.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RemoveUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "you are not allowed to remove this upload")
+}
+
+func TestRemoveUploadNotAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte(`{"allow":false}`))
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{IsAdmin: true}
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+
+	req, err := http.NewRequest("DELETE", "/upload/"+upload.ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RemoveUpload(ctx, rr, req)
+	context.TestForbidden(t, rr, "delete not allowed")
+
+	u, err := ctx.GetMetadataBackend().GetUpload(upload.ID)
+	require.NoError(t, err, "unexpected get upload error")
+	require.NotNil(t, u, "upload should not have been removed")
+}
+
 func TestRemoveUploadNoUpload(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
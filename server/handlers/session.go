@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// login open a new session for the user and set the session cookies, it returns false if the request has failed
+func login(ctx *context.Context, resp http.ResponseWriter, req *http.Request, user *common.User) bool {
+	sourceIP := ctx.GetConfig().FormatClientIP(ctx.GetSourceIP())
+
+	session := common.NewSession(user, sourceIP, req.UserAgent())
+	err := ctx.GetMetadataBackend().CreateSession(session)
+	if err != nil {
+		ctx.InternalServerError("unable to create session", err)
+		return false
+	}
+
+	sessionCookie, xsrfCookie, err := ctx.GetAuthenticator().GenAuthCookies(user, session)
+	if err != nil {
+		ctx.InternalServerError("unable to generate session cookies", err)
+		return false
+	}
+	http.SetCookie(resp, sessionCookie)
+	http.SetCookie(resp, xsrfCookie)
+
+	return true
+}
+
+// GetUserSessions return the web sessions of the user
+func GetUserSessions(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	sessions, err := ctx.GetMetadataBackend().GetUserSessions(user.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user sessions", err)
+		return
+	}
+
+	for _, session := range sessions {
+		session.Current = ctx.GetSession() != nil && session.ID == ctx.GetSession().ID
+	}
+
+	common.WriteJSONResponse(resp, sessions)
+}
+
+// RevokeSession close a web session of the user, its session cookie can't be used anymore
+func RevokeSession(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Get session to revoke from URL params
+	vars := mux.Vars(req)
+	sessionID, ok := vars["sessionID"]
+	if !ok || sessionID == "" {
+		ctx.MissingParameter("session id")
+		return
+	}
+
+	session, err := ctx.GetMetadataBackend().GetSession(sessionID)
+	if err != nil {
+		ctx.InternalServerError("unable to get session", err)
+		return
+	}
+
+	if session == nil || session.UserID != user.ID {
+		ctx.NotFound("session not found")
+		return
+	}
+
+	_, err = ctx.GetMetadataBackend().DeleteSession(session.ID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete session", err)
+		return
+	}
+
+	// Revoking the current session is a logout
+	if ctx.GetSession() != nil && session.ID == ctx.GetSession().ID {
+		common.Logout(resp, ctx.GetAuthenticator())
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
+
+// RevokeOtherSessions close all the web sessions of the user but the current one
+func RevokeOtherSessions(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	var currentSessionID string
+	if ctx.GetSession() != nil {
+		currentSessionID = ctx.GetSession().ID
+	}
+
+	removed, err := ctx.GetMetadataBackend().DeleteUserSessions(user.ID, currentSessionID)
+	if err != nil {
+		ctx.InternalServerError("unable to delete sessions", err)
+		return
+	}
+
+	_, _ = resp.Write([]byte(fmt.Sprintf("%d sessions revoked", removed)))
+}
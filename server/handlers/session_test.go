@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/root-gg/utils"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestSessions(t *testing.T, ctx *context.Context, user *common.User, count int) (sessions []*common.Session) {
+	for i := 0; i < count; i++ {
+		session := common.NewSession(user, "1.2.3.4", "agent")
+		err := ctx.GetMetadataBackend().CreateSession(session)
+		require.NoError(t, err, "unable to create session")
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+func TestLocalLoginCreateSession(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.GetAuthenticator().SessionTimeout = 3600
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Login = "user"
+	user.Password, _ = common.HashPassword("password")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "create user error")
+
+	credentials, _ := utils.ToJson(struct{ Login, Password string }{"user", "password"})
+	req, err := http.NewRequest("POST", "/auth/local/login", bytes.NewBuffer(credentials))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("User-Agent", "test agent")
+
+	rr := ctx.NewRecorder(req)
+	LocalLogin(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	sessions, err := ctx.GetMetadataBackend().GetUserSessions(user.ID)
+	require.NoError(t, err, "unable to get user sessions")
+	require.Len(t, sessions, 1, "invalid session count")
+	require.Equal(t, "test agent", sessions[0].UserAgent, "invalid session user agent")
+
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == common.SessionCookieName {
+			_, sid, _, err := ctx.GetAuthenticator().ParseSessionCookie(cookie.Value)
+			require.NoError(t, err, "unable to parse session cookie")
+			require.Equal(t, sessions[0].ID, sid, "invalid session cookie session id")
+			return
+		}
+	}
+	t.Fatalf("missing session cookie")
+}
+
+func TestGetUserSessions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	sessions := createTestSessions(t, ctx, user, 2)
+	ctx.SetSession(sessions[1])
+
+	other := common.NewUser(common.ProviderLocal, "other")
+	createTestSessions(t, ctx, other, 1)
+
+	req, err := http.NewRequest("GET", "/me/sessions", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserSessions(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result []*common.Session
+	err = json.Unmarshal(respBody, &result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Len(t, result, 2, "invalid session count")
+
+	for _, session := range result {
+		require.Equal(t, session.ID == sessions[1].ID, session.Current, "invalid current session")
+		require.Equal(t, "1.2.3.4", session.IP, "invalid session ip")
+		require.Equal(t, "agent", session.UserAgent, "invalid session user agent")
+	}
+}
+
+func TestGetUserSessionsNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "/me/sessions", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	GetUserSessions(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	sessions := createTestSessions(t, ctx, user, 2)
+	ctx.SetSession(sessions[0])
+
+	req, err := http.NewRequest("DELETE", "/me/session/"+sessions[1].ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"sessionID": sessions[1].ID})
+
+	rr := ctx.NewRecorder(req)
+	RevokeSession(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Empty(t, rr.Result().Cookies(), "revoking another session should not logout")
+
+	session, err := ctx.GetMetadataBackend().GetSession(sessions[1].ID)
+	require.NoError(t, err, "unable to get session")
+	require.Nil(t, session, "session should have been revoked")
+
+	session, err = ctx.GetMetadataBackend().GetSession(sessions[0].ID)
+	require.NoError(t, err, "unable to get session")
+	require.NotNil(t, session, "current session should not have been revoked")
+}
+
+func TestRevokeCurrentSession(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	sessions := createTestSessions(t, ctx, user, 1)
+	ctx.SetSession(sessions[0])
+
+	req, err := http.NewRequest("DELETE", "/me/session/"+sessions[0].ID, bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"sessionID": sessions[0].ID})
+
+	rr := ctx.NewRecorder(req)
+	RevokeSession(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Len(t, rr.Result().Cookies(), 2, "session cookies should have been deleted")
+}
+
+func TestRevokeSessionNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	// Sessions of other users can't be revoked
+	other := common.NewUser(common.ProviderLocal, "other")
+	sessions := createTestSessions(t, ctx, other, 1)
+
+	for _, sessionID := range []string{"invalid", sessions[0].ID} {
+		req, err := http.NewRequest("DELETE", "/me/session/"+sessionID, bytes.NewBuffer([]byte{}))
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"sessionID": sessionID})
+
+		rr := ctx.NewRecorder(req)
+		RevokeSession(ctx, rr, req)
+		context.TestNotFound(t, rr, "session not found")
+	}
+
+	session, err := ctx.GetMetadataBackend().GetSession(sessions[0].ID)
+	require.NoError(t, err, "unable to get session")
+	require.NotNil(t, session, "other user session should not have been revoked")
+}
+
+func TestRevokeSessionMissingSessionID(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("DELETE", "/me/session/", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RevokeSession(ctx, rr, req)
+	context.TestMissingParameter(t, rr, "session id")
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	sessions := createTestSessions(t, ctx, user, 3)
+	ctx.SetSession(sessions[1])
+
+	req, err := http.NewRequest("DELETE", "/me/sessions", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RevokeOtherSessions(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "2 sessions revoked", string(respBody), "invalid result message")
+
+	remaining, err := ctx.GetMetadataBackend().GetUserSessions(user.ID)
+	require.NoError(t, err, "unable to get user sessions")
+	require.Len(t, remaining, 1, "invalid session count")
+	require.Equal(t, sessions[1].ID, remaining[0].ID, "current session should have been kept")
+}
+
+func TestLogoutDeleteSession(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	ctx.SetUser(user)
+
+	sessions := createTestSessions(t, ctx, user, 1)
+	ctx.SetSession(sessions[0])
+
+	req, err := http.NewRequest("GET", "/auth/logout", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Logout(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	session, err := ctx.GetMetadataBackend().GetSession(sessions[0].ID)
+	require.NoError(t, err, "unable to get session")
+	require.Nil(t, session, "session should have been deleted")
+}
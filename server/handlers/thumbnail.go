@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"runtime"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+	"github.com/root-gg/plik/server/data"
+	"github.com/root-gg/plik/server/metadata"
+)
+
+// Limit the number of thumbnails generated concurrently as the images are decoded in memory
+var thumbnailSemaphore = make(chan struct{}, runtime.NumCPU())
+
+// generateThumbnail store a thumbnail of the uploaded image in the data backend in the background
+func generateThumbnail(ctx *context.Context, upload *common.Upload, file *common.File) {
+	config := ctx.GetConfig()
+
+	// OneShot files must not be previewed without being consumed and encrypted or encoded files can't be decoded
+	if !config.GenerateThumbnails || upload.Stream || upload.OneShot || file.EncryptionScheme != "" || file.ContentEncoding != "" || !common.IsThumbnailType(file.Type) {
+		return
+	}
+
+	log := ctx.GetLogger()
+	backend := ctx.GetDataBackend()
+	metadataBackend := ctx.GetMetadataBackend()
+	size := config.ThumbnailSize
+
+	// The file is sanitized before being sent back to the client
+	f := *file
+
+	go func() {
+		thumbnailSemaphore <- struct{}{}
+		defer func() { <-thumbnailSemaphore }()
+
+		err := storeThumbnail(backend, metadataBackend, &f, size)
+		if err != nil {
+			log.Warningf("unable to generate thumbnail of file %s : %s", f.ID, err)
+		}
+	}()
+}
+
+func storeThumbnail(backend data.Backend, metadataBackend *metadata.Backend, file *common.File, size int) (err error) {
+	reader, err := backend.GetFile(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	thumbnail, err := common.GenerateThumbnail(reader, size)
+	if err != nil {
+		return err
+	}
+
+	err = backend.AddFile(file.ThumbnailFile(), bytes.NewReader(thumbnail))
+	if err != nil {
+		return err
+	}
+
+	return metadataBackend.SetFileThumbnail(file)
+}
+
+// GetThumbnail download the thumbnail of an image
+func GetThumbnail(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	if !checkDownloadDomain(ctx) {
+		return
+	}
+
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	// Get the file id from the url params
+	fileID := mux.Vars(req)["fileID"]
+	if fileID == "" {
+		ctx.MissingParameter("file ID")
+		return
+	}
+
+	file, err := ctx.GetMetadataBackend().GetFile(fileID)
+	if err != nil {
+		ctx.InternalServerError("unable to get file metadata", err)
+		return
+	}
+	if file == nil || file.UploadID != upload.ID {
+		ctx.NotFound("file %s not found", fileID)
+		return
+	}
+
+	// Thumbnails are downloads of the file content and follow the same restrictions
+	if !checkDownloadAccess(ctx, upload, file) {
+		return
+	}
+
+	if file.Status != common.FileUploaded || !file.Thumbnail {
+		ctx.NotFound("thumbnail of file %s not found", fileID)
+		return
+	}
+
+	resp.Header().Set("Content-Type", common.ThumbnailContentType)
+	if ctx.GetConfig().DownloadContentSecurityPolicy != "" {
+		resp.Header().Set("Content-Security-Policy", ctx.GetConfig().DownloadContentSecurityPolicy)
+	} else {
+		// Do not serve the files with the web UI policy set by the security headers middleware
+		resp.Header().Del("Content-Security-Policy")
+	}
+
+	if req.Method == "GET" {
+		reader, err := ctx.GetDataBackend().GetFile(file.ThumbnailFile())
+		if err != nil {
+			ctx.InternalServerError("unable to get thumbnail from data backend", err)
+			return
+		}
+		defer func() { _ = reader.Close() }()
+
+		limitedReader, release := limitDownloadBandwidth(ctx, reader)
+		defer release()
+
+		written, err := io.Copy(resp, limitedReader)
+		if err != nil {
+			ctx.GetLogger().Warningf("error while copying thumbnail to response : %s", err)
+		}
+
+		addDownloadedBytes(ctx, upload, written)
+	}
+}
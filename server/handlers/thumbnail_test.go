@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestImageFile(t *testing.T, ctx *context.Context) (upload *common.Upload, file *common.File) {
+	buf := &bytes.Buffer{}
+	err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 512, 256)))
+	require.NoError(t, err, "unable to encode png")
+
+	upload = &common.Upload{}
+	file = upload.NewFile()
+	file.Name = "image.png"
+	file.Type = "image/png"
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err = createTestFile(ctx, file, buf)
+	require.NoError(t, err, "unable to create test file")
+
+	return upload, file
+}
+
+func getThumbnailRequest(t *testing.T, ctx *context.Context, upload *common.Upload, file *common.File) *http.Request {
+	req, err := http.NewRequest("GET", "/upload/"+upload.ID+"/"+file.ID+"/thumbnail", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	return mux.SetURLVars(req, map[string]string{"uploadID": upload.ID, "fileID": file.ID})
+}
+
+func TestGetThumbnail(t *testing.T) {
+	config := common.NewConfiguration()
+	config.GenerateThumbnails = true
+	ctx := newTestingContext(config)
+
+	upload, file := createTestImageFile(t, ctx)
+
+	err := storeThumbnail(ctx.GetDataBackend(), ctx.GetMetadataBackend(), file, config.ThumbnailSize)
+	require.NoError(t, err, "unable to store thumbnail")
+	require.True(t, file.Thumbnail, "file should have a thumbnail")
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, file)
+
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, common.ThumbnailContentType, rr.Header().Get("Content-Type"), "invalid response content type")
+	require.NotEmpty(t, rr.Header().Get("Content-Security-Policy"))
+
+	img, err := jpeg.Decode(rr.Body)
+	require.NoError(t, err, "invalid thumbnail")
+	require.Equal(t, 256, img.Bounds().Dx(), "invalid thumbnail width")
+	require.Equal(t, 128, img.Bounds().Dy(), "invalid thumbnail height")
+}
+
+func TestGetThumbnailDownloadCountry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plik_geoip_")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	config := common.NewConfiguration()
+	config.GeoIPDatabase = dir + "/geoip.csv"
+	require.NoError(t, ioutil.WriteFile(config.GeoIPDatabase, []byte("192.0.2.0/24,FR\n198.51.100.0/24,DE\n"), 0600))
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	config.GenerateThumbnails = true
+	ctx := newTestingContext(config)
+
+	upload, file := createTestImageFile(t, ctx)
+	upload.AllowedCountries = "FR"
+
+	err = storeThumbnail(ctx.GetDataBackend(), ctx.GetMetadataBackend(), file, config.ThumbnailSize)
+	require.NoError(t, err, "unable to store thumbnail")
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, file)
+
+	ctx.SetSourceIP(net.ParseIP("198.51.100.1"))
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestForbidden(t, rr, "downloads from DE are not allowed for this upload")
+
+	ctx.SetSourceIP(net.ParseIP("192.0.2.1"))
+	rr = ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestGetThumbnailMaxTotalDownloadBytes(t *testing.T) {
+	config := common.NewConfiguration()
+	config.GenerateThumbnails = true
+	ctx := newTestingContext(config)
+
+	upload, file := createTestImageFile(t, ctx)
+	upload.MaxTotalDownloadBytes = 1
+
+	err := storeThumbnail(ctx.GetDataBackend(), ctx.GetMetadataBackend(), file, config.ThumbnailSize)
+	require.NoError(t, err, "unable to store thumbnail")
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, file)
+
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestOK(t, rr)
+	require.Equal(t, int64(rr.Body.Len()), upload.DownloadedBytes, "thumbnail bytes should be counted")
+
+	rr = ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusGone, "upload maximum total download bytes have been served")
+}
+
+func TestGetThumbnailNoThumbnail(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload, file := createTestImageFile(t, ctx)
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, file)
+
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestNotFound(t, rr, "thumbnail of file "+file.ID+" not found")
+}
+
+func TestGetThumbnailFileNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, &common.File{ID: "missing"})
+
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestNotFound(t, rr, "file missing not found")
+}
+
+func TestGetThumbnailOtherUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	_, file := createTestImageFile(t, ctx)
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	ctx.SetUpload(upload)
+	req := getThumbnailRequest(t, ctx, upload, file)
+
+	rr := ctx.NewRecorder(req)
+	GetThumbnail(ctx, rr, req)
+	context.TestNotFound(t, rr, "file "+file.ID+" not found")
+}
+
+func TestStoreThumbnailInvalidImage(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Type = "image/png"
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("not an image"))
+	require.NoError(t, err, "unable to create test file")
+
+	err = storeThumbnail(ctx.GetDataBackend(), ctx.GetMetadataBackend(), file, 256)
+	common.RequireError(t, err, "unable to decode image")
+	require.False(t, file.Thumbnail, "file should not have a thumbnail")
+}
+
+func TestAddFileGenerateThumbnail(t *testing.T) {
+	config := common.NewConfiguration()
+	config.GenerateThumbnails = true
+	ctx := newTestingContext(config)
+
+	img := &bytes.Buffer{}
+	err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 64, 64)))
+	require.NoError(t, err, "unable to encode png")
+
+	upload := &common.Upload{IsAdmin: true}
+	file := upload.NewFile()
+	file.Name = "image.png"
+	createTestUpload(t, ctx, upload)
+
+	reader, contentType, err := getMultipartFormData(file.Name, img)
+	require.NoError(t, err, "unable get multipart form data")
+
+	req := getUploadRequest(t, upload, file, reader, contentType)
+
+	rr := ctx.NewRecorder(req)
+	AddFile(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Eventually(t, func() bool {
+		f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+		return err == nil && f != nil && f.Thumbnail
+	}, 5*time.Second, 10*time.Millisecond, "thumbnail not generated")
+
+	_, err = ctx.GetDataBackend().GetFile(file.ThumbnailFile())
+	require.NoError(t, err, "missing thumbnail in the data backend")
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -46,6 +48,12 @@ func CreateToken(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 		}
 	}
 
+	err = token.AllowedOrigins.Validate()
+	if err != nil {
+		ctx.BadRequest("invalid allowed origins : %s", err)
+		return
+	}
+
 	// Generate token uuid and set creation date
 	token.Initialize()
 	token.UserID = user.ID
@@ -103,3 +111,98 @@ func RevokeToken(ctx *context.Context, resp http.ResponseWriter, req *http.Reque
 
 	_, _ = resp.Write([]byte("ok"))
 }
+
+// RotateToken replace a token by a new one with the same user, comment and allowed origins.
+// The rotated token is deleted or stays valid during the requested grace period.
+func RotateToken(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Get token to rotate from URL params
+	vars := mux.Vars(req)
+	tokenStr, ok := vars["token"]
+	if !ok || tokenStr == "" {
+		ctx.MissingParameter("token")
+		return
+	}
+
+	// A token can only be used to rotate itself
+	if ctx.GetToken() != nil && ctx.GetToken().Token != tokenStr {
+		ctx.Forbidden("a token can only rotate itself")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest(fmt.Sprintf("unable to read request body : %s", err))
+		return
+	}
+
+	// Deserialize json body
+	params := &struct {
+		GracePeriod int `json:"gracePeriod"`
+	}{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, params)
+		if err != nil {
+			ctx.BadRequest(fmt.Sprintf("unable to deserialize request body : %s", err))
+			return
+		}
+	}
+
+	if params.GracePeriod < 0 {
+		ctx.BadRequest("invalid grace period %d", params.GracePeriod)
+		return
+	}
+
+	token, err := ctx.GetMetadataBackend().GetToken(tokenStr)
+	if err != nil {
+		ctx.InternalServerError("unable to get token : %s", err)
+		return
+	}
+
+	if token == nil || token.UserID != user.ID || token.IsExpired() {
+		ctx.NotFound("token not found")
+		return
+	}
+
+	if token.ExpireAt != nil {
+		ctx.BadRequest("token has already been rotated")
+		return
+	}
+
+	rotated := token.Rotate()
+
+	var expireAt *time.Time
+	if params.GracePeriod > 0 {
+		deadline := time.Now().Add(time.Duration(params.GracePeriod) * time.Second)
+		expireAt = &deadline
+	}
+
+	err = ctx.GetMetadataBackend().RotateToken(token, rotated, expireAt)
+	if errors.Is(err, common.ErrTokenAlreadyRotated) {
+		ctx.BadRequest("token has already been rotated")
+		return
+	}
+	if err != nil {
+		ctx.InternalServerError("unable to rotate token : %s", err)
+		return
+	}
+
+	// Print the new token in the json response.
+	var bytes []byte
+	if bytes, err = utils.ToJson(rotated); err != nil {
+		panic(fmt.Errorf("unable to serialize json response : %s", err))
+	}
+
+	_, _ = resp.Write(bytes)
+}
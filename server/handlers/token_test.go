@@ -88,6 +88,38 @@ func TestCreateTokenWithForbiddenOptions(t *testing.T) {
 	require.Equal(t, token.Comment, tokenResult.Comment, "invalid token comment")
 }
 
+func TestCreateTokenAllowedOrigins(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	req, err := http.NewRequest("POST", "/me/token", bytes.NewBufferString(`{"allowedOrigins":["https://App.example.com/"]}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var tokenResult = &common.Token{}
+	err = json.Unmarshal(rr.Body.Bytes(), tokenResult)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, common.TokenOrigins{"https://app.example.com"}, tokenResult.AllowedOrigins, "invalid allowed origins")
+
+	token, err := ctx.GetMetadataBackend().GetToken(tokenResult.Token)
+	require.NoError(t, err, "unable to get token")
+	require.Equal(t, common.TokenOrigins{"https://app.example.com"}, token.AllowedOrigins, "invalid allowed origins")
+
+	req, err = http.NewRequest("POST", "/me/token", bytes.NewBufferString(`{"allowedOrigins":["app.example.com"]}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateToken(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid allowed origins : invalid origin app.example.com")
+}
+
 func TestCreateTokenMissingUser(t *testing.T) {
 	config := common.NewConfiguration()
 	ctx := newTestingContext(config)
@@ -170,3 +202,107 @@ func TestRevokeTokenMissingUser(t *testing.T) {
 	RevokeToken(ctx, rr, req)
 	context.TestUnauthorized(t, rr, "missing user, please login first")
 }
+
+func TestRotateToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	token := user.NewToken()
+	token.Comment = "token comment"
+	token.AllowedOrigins = common.TokenOrigins{"https://app.example.com"}
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	req, err := http.NewRequest("POST", "/me/token/"+token.Token+"/rotate", bytes.NewBufferString(`{"gracePeriod":3600}`))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"token": token.Token})
+
+	rr := ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	var rotated = &common.Token{}
+	err = json.Unmarshal(rr.Body.Bytes(), rotated)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEqual(t, token.Token, rotated.Token, "token should have changed")
+	require.Equal(t, token.Comment, rotated.Comment, "invalid token comment")
+	require.Equal(t, token.AllowedOrigins, rotated.AllowedOrigins, "invalid token allowed origins")
+
+	result, err := ctx.GetMetadataBackend().GetToken(rotated.Token)
+	require.NoError(t, err, "unable to get token")
+	require.NotNil(t, result, "missing rotated token")
+	require.Equal(t, user.ID, result.UserID, "invalid token user")
+
+	result, err = ctx.GetMetadataBackend().GetToken(token.Token)
+	require.NoError(t, err, "unable to get token")
+	require.NotNil(t, result, "old token should be valid during the grace period")
+	require.NotNil(t, result.ExpireAt, "missing old token expiration date")
+	require.True(t, result.ExpireAt.After(time.Now().Add(59*time.Minute)), "invalid old token expiration date")
+
+	// The old token can't be rotated twice
+	rr = ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestBadRequest(t, rr, "token has already been rotated")
+
+	// Rotate without grace period
+	req, err = http.NewRequest("POST", "/me/token/"+rotated.Token+"/rotate", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"token": rotated.Token})
+
+	rr = ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	result, err = ctx.GetMetadataBackend().GetToken(rotated.Token)
+	require.NoError(t, err, "unable to get token")
+	require.Nil(t, result, "old token should have been deleted")
+}
+
+func TestRotateTokenInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user1")
+	token := user.NewToken()
+	other := user.NewToken()
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	req, err := http.NewRequest("POST", "/me/token/invalid_token_id/rotate", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"token": "invalid_token_id"})
+
+	rr := ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestNotFound(t, rr, "token not found")
+
+	req, err = http.NewRequest("POST", "/me/token/"+token.Token+"/rotate", bytes.NewBufferString(`{"gracePeriod":-1}`))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"token": token.Token})
+
+	rr = ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid grace period -1")
+
+	// Authenticated with another token
+	ctx.SetToken(other)
+	req, err = http.NewRequest("POST", "/me/token/"+token.Token+"/rotate", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"token": token.Token})
+
+	rr = ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestForbidden(t, rr, "a token can only rotate itself")
+}
+
+func TestRotateTokenMissingUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/me/token/token/rotate", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	RotateToken(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// AcceptTOS record the acceptance of the current version of the terms of service
+// The acceptance is saved in the user account if any and in a session cookie, the signed
+// acceptance token is also returned for clients without cookies to pass it in the X-Plik-TOS-Acceptance header
+func AcceptTOS(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+	if !config.RequireTOSAcceptance {
+		ctx.BadRequest("terms of service acceptance is not required")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	// Deserialize json body
+	acceptance := &common.TOSAcceptance{}
+	err = json.Unmarshal(body, acceptance)
+	if err != nil {
+		ctx.BadRequest("unable to deserialize request body : %s", err)
+		return
+	}
+
+	// Clients must accept the terms of service they displayed, not blindly the latest ones
+	if acceptance.Version != config.TOSVersion {
+		ctx.InvalidParameter("terms of service version, current version is %s", config.TOSVersion)
+		return
+	}
+	acceptance.AcceptedAt = time.Now()
+
+	if user := ctx.GetUser(); user != nil {
+		err = ctx.GetMetadataBackend().UpdateUserTOSAcceptance(user.ID, acceptance.Version, acceptance.AcceptedAt)
+		if err != nil {
+			ctx.InternalServerError("unable to save terms of service acceptance", err)
+			return
+		}
+		user.TOSAcceptedVersion = acceptance.Version
+		user.TOSAcceptedAt = &acceptance.AcceptedAt
+	}
+
+	cookie, err := ctx.GetAuthenticator().GenTOSCookie(acceptance)
+	if err != nil {
+		ctx.InternalServerError("unable to save terms of service acceptance", err)
+		return
+	}
+	http.SetCookie(resp, cookie)
+	acceptance.Token = cookie.Value
+
+	ctx.GetLogger().Infof("terms of service version %s accepted", acceptance.Version)
+
+	common.WriteJSONResponse(resp, acceptance)
+}
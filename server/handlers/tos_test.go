@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func newTOSTestingContext(version string) *context.Context {
+	config := common.NewConfiguration()
+	config.RequireTOSAcceptance = true
+	config.TOSVersion = version
+	return newTestingContext(config)
+}
+
+func acceptTestTOS(t *testing.T, ctx *context.Context, version string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/tos", bytes.NewBufferString(`{"version":"`+version+`"}`))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	AcceptTOS(ctx, rr, req)
+	return rr
+}
+
+func createTOSTestUpload(t *testing.T, ctx *context.Context, setup func(req *http.Request)) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	if setup != nil {
+		setup(req)
+	}
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	return rr
+}
+
+func TestAcceptTOS(t *testing.T) {
+	ctx := newTOSTestingContext("v1")
+
+	rr := acceptTestTOS(t, ctx, "v1")
+	context.TestOK(t, rr)
+
+	acceptance := &common.TOSAcceptance{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), acceptance), "unable to unmarshal response body")
+	require.Equal(t, "v1", acceptance.Version, "invalid terms of service version")
+	require.NotEmpty(t, acceptance.Token, "missing terms of service acceptance token")
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1, "missing terms of service cookie")
+	require.Equal(t, common.TOSCookieName, cookies[0].Name, "invalid cookie name")
+	require.Equal(t, acceptance.Token, cookies[0].Value, "invalid cookie value")
+}
+
+func TestAcceptTOSInvalidVersion(t *testing.T) {
+	ctx := newTOSTestingContext("v2")
+
+	rr := acceptTestTOS(t, ctx, "v1")
+	context.TestBadRequest(t, rr, "invalid terms of service version, current version is v2")
+}
+
+func TestAcceptTOSNotRequired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	rr := acceptTestTOS(t, ctx, "v1")
+	context.TestBadRequest(t, rr, "terms of service acceptance is not required")
+}
+
+func TestAcceptTOSUser(t *testing.T) {
+	ctx := newTOSTestingContext("v1")
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	require.NoError(t, ctx.GetMetadataBackend().CreateUser(user), "unable to create user")
+	ctx.SetUser(user)
+
+	context.TestOK(t, acceptTestTOS(t, ctx, "v1"))
+
+	result, err := ctx.GetMetadataBackend().GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Equal(t, "v1", result.TOSAcceptedVersion, "invalid accepted terms of service version")
+	require.NotNil(t, result.TOSAcceptedAt, "missing terms of service acceptance date")
+
+	// The acceptance is saved in the user account, no cookie is needed
+	context.TestOK(t, createTOSTestUpload(t, ctx, nil))
+
+	// Updating the terms of service forces users to accept them again
+	ctx.GetConfig().TOSVersion = "v2"
+	rr := createTOSTestUpload(t, ctx, nil)
+	context.TestFail(t, rr, http.StatusPreconditionRequired, "terms of service version v2 must be accepted before uploading")
+}
+
+func TestCreateUploadTOSNotAccepted(t *testing.T) {
+	ctx := newTOSTestingContext("v1")
+
+	rr := createTOSTestUpload(t, ctx, nil)
+	context.TestFail(t, rr, http.StatusPreconditionRequired, "terms of service version v1 must be accepted before uploading")
+	require.Equal(t, "v1", rr.Header().Get(common.TOSVersionHeader), "invalid terms of service version header")
+
+	rr = createTOSTestUpload(t, ctx, func(req *http.Request) {
+		req.Header.Set(common.TOSAcceptanceHeader, "invalid")
+	})
+	context.TestFail(t, rr, http.StatusPreconditionRequired, "must be accepted before uploading")
+}
+
+func TestCreateUploadTOSAccepted(t *testing.T) {
+	ctx := newTOSTestingContext("v1")
+
+	rr := acceptTestTOS(t, ctx, "v1")
+	context.TestOK(t, rr)
+	cookie := rr.Result().Cookies()[0]
+
+	// Browsers send the session cookie
+	context.TestOK(t, createTOSTestUpload(t, ctx, func(req *http.Request) {
+		req.AddCookie(cookie)
+	}))
+
+	// Other clients send the acceptance token in a header
+	context.TestOK(t, createTOSTestUpload(t, ctx, func(req *http.Request) {
+		req.Header.Set(common.TOSAcceptanceHeader, cookie.Value)
+	}))
+
+	// Updating the terms of service forces clients to accept them again
+	ctx.GetConfig().TOSVersion = "v2"
+	rr = createTOSTestUpload(t, ctx, func(req *http.Request) {
+		req.AddCookie(cookie)
+	})
+	context.TestFail(t, rr, http.StatusPreconditionRequired, "terms of service version v2 must be accepted before uploading")
+}
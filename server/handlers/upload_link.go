@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// CreateUploadLink create a signed upload link to let a third party create one upload on behalf of the user
+func CreateUploadLink(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Read request body
+	defer func() { _ = req.Body.Close() }()
+
+	req.Body = http.MaxBytesReader(resp, req.Body, 1048576)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ctx.BadRequest("unable to read request body : %s", err)
+		return
+	}
+
+	// Deserialize json body
+	link := &common.UploadLink{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, link)
+		if err != nil {
+			ctx.BadRequest("unable to deserialize request body : %s", err)
+			return
+		}
+	}
+
+	if link.Validity == 0 {
+		link.Validity = common.UploadLinkDefaultValidity
+	}
+	if link.Validity < 0 || link.Validity > common.UploadLinkMaxValidity {
+		ctx.InvalidParameter("validity, maximum is %d seconds", common.UploadLinkMaxValidity)
+		return
+	}
+	if link.MaxFileSize < 0 {
+		ctx.InvalidParameter("maxFileSize")
+		return
+	}
+	if link.MaxTTL < 0 {
+		ctx.InvalidParameter("maxTTL")
+		return
+	}
+
+	// Admins may create upload links for other users
+	if link.User != "" && link.User != user.ID {
+		if !user.IsAdmin {
+			ctx.Forbidden("you need administrator privileges")
+			return
+		}
+
+		owner, err := ctx.GetMetadataBackend().GetUser(link.User)
+		if err != nil {
+			ctx.InternalServerError("unable to get user", err)
+			return
+		}
+		if owner == nil {
+			ctx.NotFound("user not found")
+			return
+		}
+	} else {
+		link.User = user.ID
+	}
+
+	link.ID = common.GenerateRandomID(16)
+	link.ExpireAt = time.Now().Add(time.Duration(link.Validity) * time.Second)
+
+	link.Token, err = ctx.GetAuthenticator().SignUploadLink(link)
+	if err != nil {
+		ctx.InternalServerError("unable to create upload link", err)
+		return
+	}
+
+	common.WriteJSONResponse(resp, link)
+}
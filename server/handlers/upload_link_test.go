@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestCreateUploadLink(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+	ctx.SetUser(user)
+
+	reqBody, err := json.Marshal(&common.UploadLink{Validity: 3600, MaxFileSize: 1024, AllowedExtensions: []string{".pdf"}})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var link = &common.UploadLink{}
+	err = json.Unmarshal(respBody, link)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEmpty(t, link.ID, "missing upload link id")
+	require.Equal(t, user.ID, link.User, "invalid upload link user")
+	require.NotEmpty(t, link.Token, "missing upload link token")
+	require.WithinDuration(t, time.Now().Add(time.Hour), link.ExpireAt, time.Minute, "invalid upload link expiration date")
+
+	parsed, err := ctx.GetAuthenticator().ParseUploadLink(link.Token)
+	require.NoError(t, err, "invalid upload link token")
+	require.Equal(t, link.ID, parsed.ID, "invalid upload link id")
+	require.Equal(t, int64(1024), parsed.MaxFileSize, "invalid upload link max file size")
+	require.Equal(t, []string{".pdf"}, parsed.AllowedExtensions, "invalid upload link allowed extensions")
+}
+
+func TestCreateUploadLinkNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
+
+func TestCreateUploadLinkInvalidValidity(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	reqBody, err := json.Marshal(&common.UploadLink{Validity: common.UploadLinkMaxValidity + 1})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err := http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestBadRequest(t, rr, "invalid validity")
+}
+
+func TestCreateUploadLinkForOtherUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	other := common.NewUser(common.ProviderLocal, "other")
+	err := ctx.GetMetadataBackend().CreateUser(other)
+	require.NoError(t, err, "unable to add user")
+
+	reqBody, err := json.Marshal(&common.UploadLink{User: other.ID})
+	require.NoError(t, err, "unable to marshal request body")
+
+	// Regular users can't create upload links for other users
+	ctx.SetUser(common.NewUser(common.ProviderLocal, "user"))
+
+	req, err := http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestForbidden(t, rr, "you need administrator privileges")
+
+	// Admins can
+	createAdminUser(t, ctx)
+
+	req, err = http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var link = &common.UploadLink{}
+	err = json.Unmarshal(respBody, link)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.Equal(t, other.ID, link.User, "invalid upload link user")
+
+	// Unknown users
+	reqBody, err = json.Marshal(&common.UploadLink{User: "local:foo"})
+	require.NoError(t, err, "unable to marshal request body")
+
+	req, err = http.NewRequest("POST", "/me/uploadlink", bytes.NewBuffer(reqBody))
+	require.NoError(t, err, "unable to create new request")
+
+	rr = ctx.NewRecorder(req)
+	CreateUploadLink(ctx, rr, req)
+	context.TestNotFound(t, rr, "user not found")
+}
+
+func TestCreateUploadWithUploadLink(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureForced
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to add user")
+
+	link := &common.UploadLink{ID: "link", User: user.ID, ExpireAt: time.Now().Add(time.Hour), Owner: user}
+	link.Token, err = ctx.GetAuthenticator().SignUploadLink(link)
+	require.NoError(t, err, "unable to sign upload link")
+	ctx.SetUploadLink(link)
+
+	req, err := http.NewRequest("POST", "/upload", bytes.NewBuffer([]byte("{}")))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+
+	var result = &common.Upload{}
+	err = json.Unmarshal(respBody, result)
+	require.NoError(t, err, "unable to unmarshal response body")
+	require.NotEmpty(t, result.UploadToken, "missing upload token")
+
+	upload, err := ctx.GetMetadataBackend().GetUploadByLinkID(link.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.NotNil(t, upload, "missing upload")
+	require.Equal(t, result.ID, upload.ID, "invalid upload id")
+	require.Equal(t, user.ID, upload.User, "invalid upload user")
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// UploadProgressKeepAlive is the delay between two keep alive comments of idle upload progress streams
+var UploadProgressKeepAlive = 15 * time.Second
+
+// GetUploadProgress stream the progress of the upload files as server-sent events
+//   - A "progress" event is sent with the bytes received for each file in flight
+//   - A "done" event is sent and the stream is closed once no file is in flight anymore
+func GetUploadProgress(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Get upload from context
+	upload := ctx.GetUpload()
+	if upload == nil {
+		panic("missing upload from context")
+	}
+
+	broker := ctx.GetUploadProgressBroker()
+	if broker == nil {
+		panic("missing upload progress broker from context")
+	}
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		ctx.InternalServerError("unable to stream upload progress", fmt.Errorf("response writer does not implement http.Flusher"))
+		return
+	}
+
+	progressCh, snapshot, cancel := broker.Subscribe(upload.ID)
+	defer cancel()
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK)
+
+	for _, progress := range snapshot {
+		if writeUploadProgressEvent(resp, "progress", progress) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(UploadProgressKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(resp, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case progress := <-progressCh:
+			if writeUploadProgressEvent(resp, "progress", progress) != nil {
+				return
+			}
+			if progress.Done && !broker.IsInFlight(upload.ID) {
+				_ = writeUploadProgressEvent(resp, "done", nil)
+				flusher.Flush()
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeUploadProgressEvent(resp http.ResponseWriter, event string, progress *common.UploadProgress) (err error) {
+	data := []byte("{}")
+	if progress != nil {
+		data, err = json.Marshal(progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
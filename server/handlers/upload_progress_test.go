@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestGetUploadProgress(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	broker := common.NewUploadProgressBroker()
+	ctx.SetUploadProgressBroker(broker)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "file"
+	createTestUpload(t, ctx, upload)
+	ctx.SetUpload(upload)
+
+	tracker := broker.Track(file)
+
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		GetUploadProgress(ctx, resp, req)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err, "unable to get upload progress")
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode, "invalid status code")
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"), "invalid content type")
+
+	// The progress of the files in flight is sent first
+	reader := bufio.NewReader(resp.Body)
+	event, err := reader.ReadString('\n')
+	require.NoError(t, err, "unable to read event")
+	require.Equal(t, "event: progress\n", event, "invalid event")
+	data, err := reader.ReadString('\n')
+	require.NoError(t, err, "unable to read event data")
+
+	progress := &common.UploadProgress{}
+	err = json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), progress)
+	require.NoError(t, err, "unable to unmarshal upload progress")
+	require.Equal(t, file.ID, progress.FileID, "invalid file id")
+	require.False(t, progress.Done, "file should be in flight")
+
+	tracker.Update(42)
+	tracker.Done()
+
+	// The stream ends once no file is in flight
+	body, err := ioutil.ReadAll(reader)
+	require.NoError(t, err, "unable to read upload progress stream")
+	require.Contains(t, string(body), `"received":42,"done":true`, "missing final progress")
+	require.True(t, strings.HasSuffix(string(body), "event: done\ndata: {}\n\n"), "missing done event")
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"archive/zip"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// userExportFileName is the name of the archive of the user uploads
+const userExportFileName = "plik-export.zip"
+
+// ExportUserUploads download the files of all the active uploads of the user in a single zip archive
+// The archive is generated on the fly, zip64 is used by the zip writer for archives larger than 4GB
+func ExportUserUploads(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	log := ctx.GetLogger()
+	config := ctx.GetConfig()
+
+	// Get user from context
+	user := ctx.GetUser()
+	if user == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	// Exporting everything is sensitive, a token or a long lived session cookie is not enough
+	session := ctx.GetSession()
+	if session == nil || time.Since(session.CreatedAt) > config.GetUserExportReauthWindow() {
+		ctx.Unauthorized("please login again to export your uploads")
+		return
+	}
+
+	files, ok := getUserExportFiles(ctx, user)
+	if !ok {
+		return
+	}
+
+	if len(files) == 0 {
+		ctx.BadRequest("nothing to export")
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/zip")
+	resp.Header().Set("Content-Disposition", common.FormatContentDisposition(common.ContentDispositionAttachment, userExportFileName, userExportFileName))
+
+	/* Additional security headers for possibly unsafe content */
+	resp.Header().Set("X-Content-Type-Options", "nosniff")
+	resp.Header().Set("X-Frame-Options", "DENY")
+	resp.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	// HEAD Request => Do not print file, user just wants http headers
+	if req.Method != "GET" {
+		return
+	}
+
+	log.Infof("exporting %d files of user %s", len(files), user.ID)
+
+	backend := ctx.GetDataBackend()
+
+	// The zip archive is piped directly to http response body without buffering
+	watchdog := newDownloadWatchdog(ctx, req, resp)
+	defer watchdog.stop()
+	archive := zip.NewWriter(watchdog)
+
+	for _, file := range files {
+		fileReader, err := backend.GetFile(file)
+		if err != nil {
+			ctx.InternalServerError("unable to get file from data backend", err)
+			return
+		}
+		watchdog.setReader(fileReader)
+
+		// Each upload is exported in its own folder
+		fileWriter, err := archive.Create(path.Join(file.UploadID, file.RelativePath, file.Name))
+		if err != nil {
+			ctx.InternalServerError("error while creating zip archive", err)
+			return
+		}
+
+		// Archived files are always decoded
+		decodedReader, err := decodeContent(file, fileReader)
+		if err != nil {
+			ctx.InternalServerError("unable to decode file", err)
+			return
+		}
+
+		reader, release := limitDownloadBandwidth(ctx, decodedReader)
+
+		// File is piped directly to zip archive thus to the http response body without buffering
+		_, err = io.Copy(fileWriter, reader)
+		if err != nil {
+			log.Warningf("error while copying zip archive to response body : %s", err)
+		}
+		release()
+
+		err = fileReader.Close()
+		if err != nil {
+			log.Warningf("error while closing zip archive reader : %s", err)
+		}
+
+		if watchdog.isExpired() {
+			return
+		}
+	}
+
+	err := archive.Close()
+	if err != nil {
+		log.Warningf("error while closing zip archive : %s", err)
+		return
+	}
+}
+
+// getUserExportFiles return the uploaded files of the active uploads of the user, it returns false if the request has failed
+// The archive is refused if it would be bigger than MaxArchiveSize or contain more than MaxFilesInArchive files
+// Stream uploads are not exported as their files are not stored
+// The download country restrictions and the authorization webhook apply to every exported file
+func getUserExportFiles(ctx *context.Context, user *common.User) (files []*common.File, ok bool) {
+	config := ctx.GetConfig()
+
+	// Files are listed once all the uploads are known to not nest database queries
+	var uploads []*common.Upload
+	err := ctx.GetMetadataBackend().ForEachUserUploads(user.ID, "", func(upload *common.Upload) error {
+		if !upload.Stream && !upload.IsExpired() {
+			uploads = append(uploads, upload)
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.InternalServerError("unable to get user uploads", err)
+		return nil, false
+	}
+
+	var size int64
+	for _, upload := range uploads {
+		// The export is refused if any of the files could not be downloaded individually
+		if !checkDownloadCountry(ctx, upload) {
+			return nil, false
+		}
+
+		var uploadFiles []*common.File
+		err = ctx.GetMetadataBackend().ForEachUploadFiles(upload.ID, func(file *common.File) error {
+			if file.Status == common.FileUploaded && !file.IsExpired() {
+				uploadFiles = append(uploadFiles, file)
+			}
+			return nil
+		})
+		if err != nil {
+			ctx.InternalServerError("unable to get upload files", err)
+			return nil, false
+		}
+
+		for _, file := range uploadFiles {
+			if !checkAuthorization(ctx, common.AuthorizationActionDownload, upload, file) {
+				return nil, false
+			}
+			files = append(files, file)
+			size += file.Size
+		}
+	}
+
+	if config.MaxFilesInArchive > 0 && len(files) > config.MaxFilesInArchive {
+		ctx.RequestEntityTooLarge("uploads have too many files to be exported (%d), maximum is %d files per archive, please download the uploads individually",
+			len(files), config.MaxFilesInArchive)
+		return nil, false
+	}
+
+	if config.MaxArchiveSize > 0 && size > config.MaxArchiveSize {
+		ctx.RequestEntityTooLarge("uploads are too big to be exported (%s), maximum archive size is %s, please download the uploads individually",
+			humanize.Bytes(uint64(size)), humanize.Bytes(uint64(config.MaxArchiveSize)))
+		return nil, false
+	}
+
+	return files, true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func newUserExportTestingContext(t *testing.T, config *common.Configuration) (ctx *context.Context, user *common.User) {
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	ctx = newTestingContext(config)
+
+	user = common.NewUser(common.ProviderLocal, "user")
+	require.NoError(t, ctx.GetMetadataBackend().CreateUser(user), "unable to create user")
+	ctx.SetUser(user)
+	ctx.SetSession(common.NewSession(user, "", ""))
+
+	return ctx, user
+}
+
+func createUserExportTestUpload(t *testing.T, ctx *context.Context, upload *common.Upload, files map[string]string) {
+	upload.User = ctx.GetUser().ID
+	for name := range files {
+		file := upload.NewFile()
+		file.Name = name
+		file.Status = common.FileUploaded
+		file.Size = int64(len(files[name]))
+	}
+	createTestUpload(t, ctx, upload)
+
+	for _, file := range upload.Files {
+		require.NoError(t, createTestFile(ctx, file, bytes.NewBufferString(files[file.Name])), "unable to create test file")
+	}
+}
+
+func exportTestUserUploads(t *testing.T, ctx *context.Context) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/me/export", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ExportUserUploads(ctx, rr, req)
+	return rr
+}
+
+func TestExportUserUploads(t *testing.T) {
+	ctx, _ := newUserExportTestingContext(t, common.NewConfiguration())
+
+	upload1 := &common.Upload{}
+	createUserExportTestUpload(t, ctx, upload1, map[string]string{"a.txt": "aaa"})
+
+	upload2 := &common.Upload{OneShot: true}
+	createUserExportTestUpload(t, ctx, upload2, map[string]string{"b.txt": "bbb"})
+
+	// Expired, stream and other users uploads are not exported
+	expireAt := time.Now().Add(-time.Hour)
+	createUserExportTestUpload(t, ctx, &common.Upload{ExpireAt: &expireAt}, map[string]string{"expired.txt": "expired"})
+	createTestUpload(t, ctx, &common.Upload{User: ctx.GetUser().ID, Stream: true})
+	createTestUpload(t, ctx, &common.Upload{User: "other"})
+
+	rr := exportTestUserUploads(t, ctx)
+	context.TestOK(t, rr)
+	require.Equal(t, "application/zip", rr.Header().Get("Content-Type"), "invalid response content type")
+	require.Contains(t, rr.Header().Get("Content-Disposition"), "attachment", "invalid response content disposition")
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	z, err := zip.NewReader(bytes.NewReader(respBody), int64(len(respBody)))
+	require.NoError(t, err, "unable to unzip response body")
+
+	content := make(map[string]string)
+	for _, f := range z.File {
+		reader, err := f.Open()
+		require.NoError(t, err, "unable to open archived file")
+		data, err := ioutil.ReadAll(reader)
+		require.NoError(t, err, "unable to read archived file")
+		content[f.Name] = string(data)
+	}
+	require.Equal(t, map[string]string{upload1.ID + "/a.txt": "aaa", upload2.ID + "/b.txt": "bbb"}, content, "invalid archive content")
+
+	// Exporting one shot uploads does not consume them
+	f, err := ctx.GetMetadataBackend().GetFile(upload2.Files[0].ID)
+	require.NoError(t, err, "unable to get file metadata")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+}
+
+func TestExportUserUploadsReauthentication(t *testing.T) {
+	ctx, user := newUserExportTestingContext(t, common.NewConfiguration())
+	createUserExportTestUpload(t, ctx, &common.Upload{}, map[string]string{"a.txt": "aaa"})
+
+	// Tokens are not enough
+	ctx.SetSession(nil)
+	context.TestFail(t, exportTestUserUploads(t, ctx), http.StatusUnauthorized, "please login again to export your uploads")
+
+	session := common.NewSession(user, "", "")
+	session.CreatedAt = time.Now().Add(-time.Hour)
+	ctx.SetSession(session)
+	context.TestFail(t, exportTestUserUploads(t, ctx), http.StatusUnauthorized, "please login again to export your uploads")
+}
+
+func TestExportUserUploadsLimits(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxArchiveSize = 5
+	ctx, _ := newUserExportTestingContext(t, config)
+
+	createUserExportTestUpload(t, ctx, &common.Upload{}, map[string]string{"a.txt": "aaa"})
+	createUserExportTestUpload(t, ctx, &common.Upload{}, map[string]string{"b.txt": "bbb"})
+	context.TestFail(t, exportTestUserUploads(t, ctx), http.StatusRequestEntityTooLarge, "uploads are too big to be exported (6 B), maximum archive size is 5 B")
+
+	config.MaxArchiveSize = 0
+	config.MaxFilesInArchive = 1
+	context.TestFail(t, exportTestUserUploads(t, ctx), http.StatusRequestEntityTooLarge, "uploads have too many files to be exported (2), maximum is 1 files per archive")
+}
+
+func TestExportUserUploadsNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	context.TestUnauthorized(t, exportTestUserUploads(t, ctx), "missing user, please login first")
+}
+
+func TestExportUserUploadsEmpty(t *testing.T) {
+	ctx, _ := newUserExportTestingContext(t, common.NewConfiguration())
+	context.TestBadRequest(t, exportTestUserUploads(t, ctx), "nothing to export")
+}
+
+func TestExportUserUploadsNotAuthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		request := &common.AuthorizationRequest{}
+		err := json.NewDecoder(req.Body).Decode(request)
+		require.NoError(t, err, "unable to decode authorization request")
+		require.Equal(t, common.AuthorizationActionDownload, request.Action, "invalid action")
+
+		if request.FileName == "b.txt" {
+			_, _ = resp.Write([]byte(`{"allow":false,"reason":"quarantined"}`))
+			return
+		}
+		_, _ = resp.Write([]byte(`{"allow":true}`))
+	}))
+	defer server.Close()
+
+	config := common.NewConfiguration()
+	config.AuthorizationWebhookURL = server.URL
+	ctx, _ := newUserExportTestingContext(t, config)
+
+	createUserExportTestUpload(t, ctx, &common.Upload{}, map[string]string{"a.txt": "aaa"})
+	rr := exportTestUserUploads(t, ctx)
+	context.TestOK(t, rr)
+
+	createUserExportTestUpload(t, ctx, &common.Upload{}, map[string]string{"b.txt": "bbb"})
+	rr = exportTestUserUploads(t, ctx)
+	context.TestForbidden(t, rr, "download not allowed : quarantined")
+	require.Empty(t, rr.Header().Get("Content-Disposition"), "archive headers should not be sent")
+}
+
+func TestExportUserUploadsCountryRestricted(t *testing.T) {
+	ctx, _ := newUserExportTestingContext(t, common.NewConfiguration())
+
+	createUserExportTestUpload(t, ctx, &common.Upload{AllowedCountries: "FR"}, map[string]string{"a.txt": "aaa"})
+	rr := exportTestUserUploads(t, ctx)
+	context.TestForbidden(t, rr, "unable to resolve the country of your IP address")
+	require.Empty(t, rr.Header().Get("Content-Disposition"), "archive headers should not be sent")
+}
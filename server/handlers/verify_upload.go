@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// VerifyUploadPassword check the credentials of a password protected upload
+// without touching the data backend nor consuming a OneShot download
+func VerifyUploadPassword(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	// Get the upload id from the url params
+	uploadID := mux.Vars(req)["uploadID"]
+	if uploadID == "" {
+		ctx.MissingParameter("upload id")
+		return
+	}
+
+	upload, err := ctx.GetUploadByID(uploadID)
+	if err != nil {
+		ctx.InternalServerError("unable to get upload metadata", err)
+		return
+	}
+	if upload == nil || upload.IsExpired() {
+		ctx.NotFound("upload %s not found", uploadID)
+		return
+	}
+
+	if upload.ProtectedByPassword {
+		err = ctx.CheckUploadBasicAuth(upload, req.Header.Get("Authorization"))
+		if _, ok := err.(common.HTTPError); ok {
+			handleHTTPError(ctx, err)
+			return
+		}
+		if err != nil {
+			ctx.Forbidden(err.Error())
+			return
+		}
+	}
+
+	_, _ = resp.Write([]byte("ok"))
+}
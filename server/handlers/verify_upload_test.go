@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/root-gg/utils"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createTestProtectedUpload(t *testing.T, ctx *context.Context) (upload *common.Upload) {
+	var err error
+
+	upload = &common.Upload{}
+	upload.OneShot = true
+	upload.ProtectedByPassword = true
+	upload.Login = "login"
+	upload.Password, err = utils.Md5sum(common.EncodeAuthBasicHeader("login", "password"))
+	require.NoError(t, err, "unable to hash upload credentials")
+	createTestUpload(t, ctx, upload)
+	return upload
+}
+
+func newVerifyUploadRequest(t *testing.T, uploadID string, authorization string) (req *http.Request) {
+	req, err := http.NewRequest("POST", "/upload/"+uploadID+"/verify", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": uploadID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return req
+}
+
+func TestVerifyUploadPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createTestProtectedUpload(t, ctx)
+
+	req := newVerifyUploadRequest(t, upload.ID, "Basic "+common.EncodeAuthBasicHeader("login", "password"))
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	respBody, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "ok", string(respBody), "invalid response body")
+}
+
+func TestVerifyUploadPasswordInvalidPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createTestProtectedUpload(t, ctx)
+
+	req := newVerifyUploadRequest(t, upload.ID, "Basic "+common.EncodeAuthBasicHeader("login", "foo"))
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestForbidden(t, rr, "invalid credentials")
+}
+
+func TestVerifyUploadPasswordMissingHeader(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createTestProtectedUpload(t, ctx)
+
+	req := newVerifyUploadRequest(t, upload.ID, "")
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestForbidden(t, rr, "missing Authorization header")
+}
+
+func TestVerifyUploadPasswordNotProtected(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	createTestUpload(t, ctx, upload)
+
+	req := newVerifyUploadRequest(t, upload.ID, "")
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestVerifyUploadPasswordMissingUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req := newVerifyUploadRequest(t, "foo", "")
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestNotFound(t, rr, "upload foo not found")
+}
+
+func TestVerifyUploadPasswordMaxAttempts(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadPasswordAttempts = 2
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize configuration")
+	ctx := newTestingContext(config)
+	upload := createTestProtectedUpload(t, ctx)
+
+	invalid := "Basic " + common.EncodeAuthBasicHeader("login", "foo")
+	valid := "Basic " + common.EncodeAuthBasicHeader("login", "password")
+
+	// Requests without credentials are not counted
+	req := newVerifyUploadRequest(t, upload.ID, "")
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestForbidden(t, rr, "missing Authorization header")
+
+	req = newVerifyUploadRequest(t, upload.ID, invalid)
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestForbidden(t, rr, "invalid credentials")
+
+	// A successful attempt resets the counter
+	req = newVerifyUploadRequest(t, upload.ID, valid)
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	req = newVerifyUploadRequest(t, upload.ID, invalid)
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestForbidden(t, rr, "invalid credentials")
+
+	req = newVerifyUploadRequest(t, upload.ID, invalid)
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusTooManyRequests, "too many failed password attempts, retry in 15m0s")
+
+	// Even the valid password is rejected until the end of the lockout
+	req = newVerifyUploadRequest(t, upload.ID, valid)
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusTooManyRequests, "too many failed password attempts")
+}
+
+func TestVerifyUploadPasswordMaxAttemptsPerIP(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadPasswordAttempts = 1
+	config.UploadPasswordAttemptsPerIP = true
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize configuration")
+	ctx := newTestingContext(config)
+	upload := createTestProtectedUpload(t, ctx)
+
+	ctx.SetSourceIP(net.ParseIP("1.1.1.1"))
+	req := newVerifyUploadRequest(t, upload.ID, "Basic "+common.EncodeAuthBasicHeader("login", "foo"))
+	rr := ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusTooManyRequests, "too many failed password attempts")
+
+	// Other clients are not locked out
+	ctx.SetSourceIP(net.ParseIP("2.2.2.2"))
+	req = newVerifyUploadRequest(t, upload.ID, "Basic "+common.EncodeAuthBasicHeader("login", "password"))
+	rr = ctx.NewRecorder(req)
+	VerifyUploadPassword(ctx, rr, req)
+	context.TestOK(t, rr)
+}
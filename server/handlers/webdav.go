@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// WebDAVPrefix is the path of the WebDAV filesystem
+const WebDAVPrefix = "/webdav"
+
+var errWebDAVIsDirectory = errors.New("is a directory")
+
+// WebDAV expose the uploads of the authenticated user as a read-only WebDAV filesystem.
+// Each upload is a directory named after the upload ID containing the uploaded files.
+func WebDAV(ctx *context.Context, resp http.ResponseWriter, req *http.Request) {
+	config := ctx.GetConfig()
+	log := ctx.GetLogger()
+
+	if ctx.GetUser() == nil {
+		ctx.Unauthorized("missing user, please login first")
+		return
+	}
+
+	switch req.Method {
+	case "OPTIONS", "PROPFIND":
+	case "GET", "HEAD":
+		// Downloads have to be allowed by the authorization webhook like the regular downloads
+		fs := newWebDAVFileSystem(ctx)
+		f, err := fs.open(strings.TrimPrefix(req.URL.Path, WebDAVPrefix))
+		if err == nil && f.file != nil {
+			if !checkAuthorization(ctx, common.AuthorizationActionDownload, f.upload, f.file) {
+				return
+			}
+			setWebDAVDownloadHeaders(ctx, resp, f.file)
+		}
+	default:
+		resp.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND")
+		ctx.Fail("the WebDAV filesystem is read-only", nil, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The hrefs of the PROPFIND responses are built from the request path
+	path := strings.TrimSuffix(config.Path, "/")
+	req.URL.Path = path + req.URL.Path
+
+	handler := &webdav.Handler{
+		Prefix:     path + WebDAVPrefix,
+		FileSystem: newWebDAVFileSystem(ctx),
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(req *http.Request, err error) {
+			if err != nil && !os.IsNotExist(err) {
+				log.Warningf("WebDAV %s %s error : %s", req.Method, req.URL.Path, err)
+			}
+		},
+	}
+	handler.ServeHTTP(resp, req)
+}
+
+// setWebDAVDownloadHeaders prevent the files served by the WebDAV filesystem from being rendered by browsers.
+// Unlike the regular downloads WebDAV is served from the web UI origin, so the files are always downloaded
+// as attachments and sandboxed even if DownloadContentSecurityPolicy is disabled.
+func setWebDAVDownloadHeaders(ctx *context.Context, resp http.ResponseWriter, file *common.File) {
+	csp := ctx.GetConfig().DownloadContentSecurityPolicy
+	if csp == "" {
+		csp = common.DefaultDownloadContentSecurityPolicy
+	}
+	resp.Header().Set("Content-Security-Policy", csp)
+	resp.Header().Set("X-Content-Type-Options", "nosniff")
+	asciiFilename := file.ASCIIName
+	if asciiFilename == "" {
+		asciiFilename = common.ASCIIFileName(file.Name)
+	}
+	resp.Header().Set("Content-Disposition", common.FormatContentDisposition(common.ContentDispositionAttachment, file.Name, asciiFilename))
+}
+
+// webdavFileSystem map the uploads of the user to directories and the uploaded files to regular files
+type webdavFileSystem struct {
+	ctx *context.Context
+}
+
+func newWebDAVFileSystem(ctx *context.Context) *webdavFileSystem {
+	return &webdavFileSystem{ctx: ctx}
+}
+
+// Mkdir is not allowed as the filesystem is read-only
+func (fs *webdavFileSystem) Mkdir(c gocontext.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+// RemoveAll is not allowed as the filesystem is read-only
+func (fs *webdavFileSystem) RemoveAll(c gocontext.Context, name string) error {
+	return os.ErrPermission
+}
+
+// Rename is not allowed as the filesystem is read-only
+func (fs *webdavFileSystem) Rename(c gocontext.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+// OpenFile open a directory or a file for reading
+func (fs *webdavFileSystem) OpenFile(c gocontext.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	return fs.open(name)
+}
+
+// Stat return the details of a directory or a file
+func (fs *webdavFileSystem) Stat(c gocontext.Context, name string) (os.FileInfo, error) {
+	f, err := fs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info, nil
+}
+
+func (fs *webdavFileSystem) open(name string) (f *webdavFile, err error) {
+	parts := strings.SplitN(strings.Trim(name, "/"), "/", 2)
+
+	if parts[0] == "" {
+		return fs.openRoot()
+	}
+
+	upload, err := fs.getUpload(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.getFiles(upload)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 1 {
+		f = &webdavFile{fs: fs, upload: upload}
+		f.info = &webdavFileInfo{name: upload.ID, modTime: upload.CreatedAt, dir: true}
+		for _, file := range files {
+			f.children = append(f.children, newWebDAVFileInfo(file))
+		}
+		return f, nil
+	}
+
+	for _, file := range files {
+		if file.Name == parts[1] {
+			return &webdavFile{fs: fs, upload: upload, file: file, info: newWebDAVFileInfo(file)}, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+func (fs *webdavFileSystem) openRoot() (f *webdavFile, err error) {
+	user := fs.ctx.GetUser()
+
+	f = &webdavFile{fs: fs}
+	f.info = &webdavFileInfo{name: "/", modTime: user.CreatedAt, dir: true}
+
+	err = fs.ctx.GetMetadataBackend().ForEachUserUploads(user.ID, "", func(upload *common.Upload) error {
+		if isWebDAVVisible(fs.ctx.GetConfig(), upload) {
+			f.children = append(f.children, &webdavFileInfo{name: upload.ID, modTime: upload.CreatedAt, dir: true})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// getUpload return the upload if it belongs to the user and can be served by the WebDAV filesystem
+func (fs *webdavFileSystem) getUpload(uploadID string) (upload *common.Upload, err error) {
+	upload, err = fs.ctx.GetUploadByID(uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload == nil || upload.User != fs.ctx.GetUser().ID || !isWebDAVVisible(fs.ctx.GetConfig(), upload) {
+		return nil, os.ErrNotExist
+	}
+
+	return upload, nil
+}
+
+// getFiles return the uploaded files, only the oldest file is served when several files share the same name
+func (fs *webdavFileSystem) getFiles(upload *common.Upload) (files []*common.File, err error) {
+	all, err := fs.ctx.GetMetadataBackend().GetFiles(upload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].CreatedAt.Before(all[j].CreatedAt) })
+
+	names := make(map[string]bool)
+	for _, file := range all {
+		if file.Status != common.FileUploaded || file.ContentEncoding != "" || names[file.Name] {
+			continue
+		}
+		names[file.Name] = true
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// isWebDAVVisible return true if the upload can be served by the WebDAV filesystem.
+// Uploads with download restrictions are only available through the regular download routes.
+func isWebDAVVisible(config *common.Configuration, upload *common.Upload) bool {
+	return !upload.IsExpired() && !upload.OneShot && !upload.Stream && !upload.ProtectedByPassword && upload.MaxTotalDownloadBytes == 0 &&
+		!config.IsDownloadCountryRestricted(upload)
+}
+
+// webdavFile is an upload directory or an uploaded file. Data backends can't seek so the file is read again
+// from the beginning when the client seeks backward.
+type webdavFile struct {
+	fs       *webdavFileSystem
+	info     *webdavFileInfo
+	upload   *common.Upload
+	file     *common.File
+	children []os.FileInfo
+
+	reader   io.ReadCloser
+	position int64 // Position of the data backend reader
+	offset   int64 // Position requested by the client
+}
+
+// Read the file data from the data backend
+func (f *webdavFile) Read(p []byte) (n int, err error) {
+	if f.file == nil {
+		return 0, errWebDAVIsDirectory
+	}
+
+	if f.reader != nil && f.position > f.offset {
+		_ = f.reader.Close()
+		f.reader = nil
+	}
+
+	if f.reader == nil {
+		f.reader, err = f.fs.ctx.GetDataBackend().GetFile(f.file)
+		if err != nil {
+			return 0, err
+		}
+		f.position = 0
+
+		if f.offset == 0 {
+			addFileDownload(f.fs.ctx, f.file)
+			addUploadDownload(f.fs.ctx, f.upload)
+			touchUpload(f.fs.ctx, f.upload)
+		}
+	}
+
+	if f.position < f.offset {
+		skipped, err := io.CopyN(ioutil.Discard, f.reader, f.offset-f.position)
+		f.position += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = f.reader.Read(p)
+	f.position += int64(n)
+	f.offset = f.position
+
+	return n, err
+}
+
+// Seek set the position of the next read
+func (f *webdavFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return 0, os.ErrInvalid
+	}
+
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Readdir return the content of the directory
+func (f *webdavFile) Readdir(count int) (infos []os.FileInfo, err error) {
+	if f.file != nil {
+		return nil, os.ErrInvalid
+	}
+
+	if count <= 0 {
+		infos, f.children = f.children, nil
+		return infos, nil
+	}
+
+	if len(f.children) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(f.children) {
+		count = len(f.children)
+	}
+	infos, f.children = f.children[:count], f.children[count:]
+
+	return infos, nil
+}
+
+// Stat return the details of the directory or file
+func (f *webdavFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// Write is not allowed as the filesystem is read-only
+func (f *webdavFile) Write(p []byte) (n int, err error) {
+	return 0, os.ErrPermission
+}
+
+// Close the data backend reader
+func (f *webdavFile) Close() error {
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+// webdavFileInfo implements os.FileInfo and webdav.ContentTyper
+type webdavFileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	dir         bool
+	contentType string
+}
+
+func newWebDAVFileInfo(file *common.File) *webdavFileInfo {
+	return &webdavFileInfo{name: file.Name, size: file.Size, modTime: file.CreatedAt, contentType: file.Type}
+}
+
+func (info *webdavFileInfo) Name() string       { return info.name }
+func (info *webdavFileInfo) Size() int64        { return info.size }
+func (info *webdavFileInfo) ModTime() time.Time { return info.modTime }
+func (info *webdavFileInfo) IsDir() bool        { return info.dir }
+func (info *webdavFileInfo) Sys() interface{}   { return nil }
+
+func (info *webdavFileInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ContentType return the file type detected on upload to avoid reading the file data
+func (info *webdavFileInfo) ContentType(c gocontext.Context) (string, error) {
+	if info.contentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return info.contentType, nil
+}
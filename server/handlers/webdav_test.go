@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func createWebDAVTestUpload(t *testing.T, ctx *context.Context, user *common.User, upload *common.Upload) (file *common.File) {
+	upload.User = user.ID
+	file = upload.NewFile()
+	file.Name = "file.txt"
+	file.Type = "text/plain"
+	file.Size = 4
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create test file")
+
+	return file
+}
+
+func newWebDAVTestContext(t *testing.T) (ctx *context.Context, user *common.User) {
+	ctx = newTestingContext(common.NewConfiguration())
+	user = common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	ctx.SetUser(user)
+	return ctx, user
+}
+
+func TestWebDAVPropfind(t *testing.T) {
+	ctx, user := newWebDAVTestContext(t)
+
+	upload := &common.Upload{}
+	createWebDAVTestUpload(t, ctx, user, upload)
+
+	oneShot := &common.Upload{OneShot: true}
+	createWebDAVTestUpload(t, ctx, user, oneShot)
+
+	other := &common.Upload{}
+	createWebDAVTestUpload(t, ctx, common.NewUser(common.ProviderLocal, "other"), other)
+
+	req, err := http.NewRequest("PROPFIND", "/webdav/", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Depth", "1")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	require.Equal(t, http.StatusMultiStatus, rr.Code, "invalid status code")
+
+	body, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Contains(t, string(body), "/webdav/"+upload.ID+"/", "missing upload directory")
+	require.NotContains(t, string(body), oneShot.ID, "one shot uploads should not be exposed")
+	require.NotContains(t, string(body), other.ID, "uploads of other users should not be exposed")
+
+	req, err = http.NewRequest("PROPFIND", "/webdav/"+upload.ID+"/", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Depth", "1")
+
+	rr = ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	require.Equal(t, http.StatusMultiStatus, rr.Code, "invalid status code")
+
+	body, err = ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Contains(t, string(body), "/webdav/"+upload.ID+"/file.txt", "missing file")
+	require.Contains(t, string(body), "text/plain", "missing file content type")
+}
+
+func TestWebDAVGetFile(t *testing.T) {
+	ctx, user := newWebDAVTestContext(t)
+
+	upload := &common.Upload{}
+	file := createWebDAVTestUpload(t, ctx, user, upload)
+
+	req, err := http.NewRequest("GET", "/webdav/"+upload.ID+"/file.txt", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	body, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "data", string(body), "invalid file content")
+
+	f, err := ctx.GetMetadataBackend().GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, 1, f.DownloadCount, "invalid download count")
+}
+
+func TestWebDAVGetFileSecurityHeaders(t *testing.T) {
+	ctx, user := newWebDAVTestContext(t)
+	ctx.GetConfig().DownloadContentSecurityPolicy = ""
+
+	upload := &common.Upload{}
+	upload.User = user.ID
+	file := upload.NewFile()
+	file.Name = "index.html"
+	file.Type = "text/html"
+	file.Size = 4
+	file.Status = common.FileUploaded
+	createTestUpload(t, ctx, upload)
+
+	err := createTestFile(ctx, file, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create test file")
+
+	req, err := http.NewRequest("GET", "/webdav/"+upload.ID+"/index.html", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, common.DefaultDownloadContentSecurityPolicy, rr.Header().Get("Content-Security-Policy"), "invalid content security policy")
+	require.Contains(t, rr.Header().Get("Content-Security-Policy"), "sandbox", "missing sandbox")
+	require.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"), "invalid content type options")
+	require.Equal(t, common.FormatContentDisposition(common.ContentDispositionAttachment, "index.html", "index.html"), rr.Header().Get("Content-Disposition"), "invalid content disposition")
+}
+
+func TestWebDAVGetFileRange(t *testing.T) {
+	ctx, user := newWebDAVTestContext(t)
+
+	upload := &common.Upload{}
+	createWebDAVTestUpload(t, ctx, user, upload)
+
+	req, err := http.NewRequest("GET", "/webdav/"+upload.ID+"/file.txt", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("Range", "bytes=1-2")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	require.Equal(t, http.StatusPartialContent, rr.Code, "invalid status code")
+
+	body, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err, "unable to read response body")
+	require.Equal(t, "at", string(body), "invalid file content")
+}
+
+func TestWebDAVGetFileNotFound(t *testing.T) {
+	ctx, user := newWebDAVTestContext(t)
+
+	upload := &common.Upload{ProtectedByPassword: true}
+	createWebDAVTestUpload(t, ctx, user, upload)
+
+	req, err := http.NewRequest("GET", "/webdav/"+upload.ID+"/file.txt", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code, "password protected uploads should not be exposed")
+}
+
+func TestWebDAVReadOnly(t *testing.T) {
+	ctx, _ := newWebDAVTestContext(t)
+
+	req, err := http.NewRequest("PUT", "/webdav/upload/file.txt", bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	context.TestFail(t, rr, http.StatusMethodNotAllowed, "the WebDAV filesystem is read-only")
+}
+
+func TestWebDAVNoUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("PROPFIND", "/webdav/", bytes.NewBuffer([]byte{}))
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAV(ctx, rr, req)
+	context.TestUnauthorized(t, rr, "missing user, please login first")
+}
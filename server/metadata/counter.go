@@ -0,0 +1,27 @@
+package metadata
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// incrementCounter atomically add delta to a counter column of the row matching id and return the new value
+// The increment is computed by the database and the value is read back in the same transaction, so
+// concurrent increments are never lost and each caller gets the value resulting from its own increment
+// Rows created before the column was added have a NULL counter which is counted as 0
+func (b *Backend) incrementCounter(model interface{}, id string, column string, delta int64) (value int64, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(model).Where("id = ?", id).Update(column, gorm.Expr("COALESCE("+column+", 0) + ?", delta))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%s not found", id)
+		}
+
+		return tx.Model(model).Select(column).Where("id = ?", id).Row().Scan(&value)
+	})
+
+	return value, err
+}
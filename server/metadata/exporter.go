@@ -1,7 +1,9 @@
 package metadata
 
 import (
+	"bufio"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -26,64 +28,110 @@ type object struct {
 	Object interface{}
 }
 
+// Metadata export file formats
+const (
+	ExportFormatBinary = "binary" // Snappy compressed gob stream
+	ExportFormatJSONL  = "jsonl"  // One JSON object per line
+)
+
+// ExportOptions for metadata exports
+type ExportOptions struct {
+	Format string
+}
+
 type exporter struct {
 	writer     io.WriteCloser
 	compressor *snappy.Writer
-	encoder    *gob.Encoder
+	buffer     *bufio.Writer
+	encode     func(obj *object) error
 }
 
-func newExporter(path string) (e *exporter, err error) {
+func newExporter(path string, format string) (e *exporter, err error) {
 	e = &exporter{}
 
+	switch format {
+	case "", ExportFormatBinary, ExportFormatJSONL:
+	default:
+		return nil, fmt.Errorf("invalid export format %s, expected %s or %s", format, ExportFormatBinary, ExportFormatJSONL)
+	}
+
 	// Open file for writing
 	e.writer, err = os.Create(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if format == ExportFormatJSONL {
+		e.buffer = bufio.NewWriter(e.writer)
+		encoder := json.NewEncoder(e.buffer)
+		e.encode = func(obj *object) error {
+			record, err := newJSONRecord(obj)
+			if err != nil {
+				return err
+			}
+			return encoder.Encode(record)
+		}
+		return e, nil
+	}
+
 	// Snappy compressor
 	e.compressor = snappy.NewBufferedWriter(e.writer)
 
 	// Gob encoder
+	registerGobTypes()
+	encoder := gob.NewEncoder(e.compressor)
+	e.encode = func(obj *object) error {
+		return encoder.Encode(obj)
+	}
+
+	return e, nil
+}
+
+func registerGobTypes() {
 	gob.Register(&common.Upload{})
 	gob.Register(&common.File{})
 	gob.Register(&common.User{})
 	gob.Register(&common.Token{})
 	gob.Register(&common.Setting{})
-	e.encoder = gob.NewEncoder(e.compressor)
-
-	return e, nil
 }
 
 func (e *exporter) addUpload(upload *common.Upload) (err error) {
 	obj := &object{Type: metadataTypeUpload, Object: upload}
-	return e.encoder.Encode(obj)
+	return e.encode(obj)
 }
 
 func (e *exporter) addFile(file *common.File) (err error) {
 	obj := &object{Type: metadataTypeFile, Object: file}
-	return e.encoder.Encode(obj)
+	return e.encode(obj)
 }
 
 func (e *exporter) addUser(user *common.User) (err error) {
 	obj := &object{Type: metadataTypeUser, Object: user}
-	return e.encoder.Encode(obj)
+	return e.encode(obj)
 }
 
 func (e *exporter) addToken(token *common.Token) (err error) {
 	obj := &object{Type: metadataTypeToken, Object: token}
-	return e.encoder.Encode(obj)
+	return e.encode(obj)
 }
 
 func (e *exporter) addSetting(setting *common.Setting) (err error) {
 	obj := &object{Type: metadataTypeSetting, Object: setting}
-	return e.encoder.Encode(obj)
+	return e.encode(obj)
 }
 
 func (e *exporter) close() (err error) {
-	err = e.compressor.Close()
-	if err != nil {
-		return err
+	if e.compressor != nil {
+		err = e.compressor.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if e.buffer != nil {
+		err = e.buffer.Flush()
+		if err != nil {
+			return err
+		}
 	}
 	err = e.writer.Close()
 	if err != nil {
@@ -92,14 +140,19 @@ func (e *exporter) close() (err error) {
 	return nil
 }
 
-// Export exports all metadata from the backend to a compressed binary file
-func (b *Backend) Export(path string) (err error) {
-	e, err := newExporter(path)
+// Export exports all metadata from the backend to a compressed binary file or a JSON lines file
+// Objects are streamed to the file one by one
+func (b *Backend) Export(path string, options *ExportOptions) (err error) {
+	e, err := newExporter(path, options.Format)
 	if err != nil {
 		return err
 	}
 
-	defer func() { _ = e.close() }()
+	defer func() {
+		if closeErr := e.close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
 
 	count := 0
 	err = b.ForEachUsers(func(user *common.User) error {
@@ -1,6 +1,8 @@
 package metadata
 
 import (
+	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -31,7 +33,7 @@ func TestBackend_Export(t *testing.T) {
 	createMetadata(t, b)
 
 	path := "/tmp/plik.metadata.test.snappy.gob"
-	err := b.Export(path)
+	err := b.Export(path, &ExportOptions{})
 	require.NoError(t, err, "export error %s", err)
 
 	b = newTestMetadataBackend()
@@ -54,7 +56,7 @@ func TestBackend_ExportRemovedFiles(t *testing.T) {
 	require.NoError(t, err, "unable to delete upload")
 
 	path := "/tmp/plik.metadata.test.snappy.gob"
-	err = b.Export(path)
+	err = b.Export(path, &ExportOptions{})
 	require.NoError(t, err, "export error %s", err)
 
 	shutdownTestMetadataBackend(b)
@@ -64,3 +66,63 @@ func TestBackend_ExportRemovedFiles(t *testing.T) {
 	err = b.Import(path, &ImportOptions{})
 	require.NoError(t, err, "import error %s", err)
 }
+
+func TestBackend_ExportJSONL(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Password = "hash"
+	user.NewToken()
+	createUser(t, b, user)
+
+	upload := &common.Upload{User: user.ID, UserMetadata: common.UserMetadata{"ticket": "PLIK-42"}}
+	file := upload.NewFile()
+	file.BackendDetails = "details"
+	createUpload(t, b, upload)
+
+	err := b.RemoveUpload(upload.ID)
+	require.NoError(t, err, "unable to delete upload")
+
+	path := "/tmp/plik.metadata.test.jsonl"
+	err = b.Export(path, &ExportOptions{Format: ExportFormatJSONL})
+	require.NoError(t, err, "export error %s", err)
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "unable to read export file")
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 4, "invalid line count")
+	require.True(t, strings.HasPrefix(lines[0], `{"type":"user","object":{"ID":"`+user.ID+`"`), "invalid line %s", lines[0])
+
+	shutdownTestMetadataBackend(b)
+	b = newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	err = b.Import(path, &ImportOptions{})
+	require.NoError(t, err, "import error %s", err)
+
+	u, err := b.GetUser(user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, u, "missing user")
+	require.Equal(t, "hash", u.Password, "hidden fields should be exported")
+
+	upl := &common.Upload{}
+	err = b.db.Unscoped().Take(upl, &common.Upload{ID: upload.ID}).Error
+	require.NoError(t, err, "missing upload")
+	require.True(t, upl.DeletedAt.Valid, "upload should still be soft deleted")
+	require.Equal(t, upload.UserMetadata, upl.UserMetadata)
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err)
+	require.NotNil(t, f, "missing file")
+	require.Equal(t, "details", f.BackendDetails, "hidden fields should be exported")
+	require.Equal(t, upload.ID, f.UploadID)
+}
+
+func TestBackend_ExportInvalidFormat(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	err := b.Export("/tmp/plik.metadata.test.xml", &ExportOptions{Format: "xml"})
+	common.RequireError(t, err, "invalid export format xml")
+}
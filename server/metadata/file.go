@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -35,6 +36,92 @@ func (b *Backend) GetFiles(uploadID string) (files []*common.File, err error) {
 	return files, err
 }
 
+// StartFileDownload mark the beginning of a download of an uploaded file from the given offset
+// Only one download can be in progress at a time and a download can only start where the previous one stopped.
+// It returns false if the file is not available, if a download is in progress or if the offset does not match
+func (b *Backend) StartFileDownload(file *common.File, start int64) (started bool, err error) {
+	now := time.Now()
+	result := b.db.Model(&common.File{}).
+		Where("id = ? AND status = ? AND download_in_progress = ? AND delivered_bytes = ?", file.ID, common.FileUploaded, false, start).
+		Updates(map[string]interface{}{"download_in_progress": true, "last_download_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != int64(1) {
+		return false, nil
+	}
+
+	file.DownloadInProgress = true
+	file.LastDownloadAt = &now
+
+	return true, nil
+}
+
+// UpdateFileDelivery save how many bytes of a file have been delivered if more than previously saved
+func (b *Backend) UpdateFileDelivery(file *common.File, deliveredBytes int64) error {
+	now := time.Now()
+	result := b.db.Model(&common.File{}).
+		Where("id = ? AND delivered_bytes < ?", file.ID, deliveredBytes).
+		Updates(map[string]interface{}{"delivered_bytes": deliveredBytes, "last_download_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	file.DeliveredBytes = deliveredBytes
+	file.LastDownloadAt = &now
+
+	return nil
+}
+
+// EndFileDownload save how many bytes of a file have been delivered and mark the end of its download
+func (b *Backend) EndFileDownload(file *common.File, deliveredBytes int64) error {
+	now := time.Now()
+	result := b.db.Model(&common.File{}).
+		Where("id = ?", file.ID).
+		Updates(map[string]interface{}{
+			"delivered_bytes":      gorm.Expr("CASE WHEN delivered_bytes < ? THEN ? ELSE delivered_bytes END", deliveredBytes, deliveredBytes),
+			"download_in_progress": false,
+			"last_download_at":     now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if deliveredBytes > file.DeliveredBytes {
+		file.DeliveredBytes = deliveredBytes
+	}
+	file.DownloadInProgress = false
+	file.LastDownloadAt = &now
+
+	return nil
+}
+
+// IncrementFileDownloadCount atomically increment the number of downloads of a file and return the new count
+func (b *Backend) IncrementFileDownloadCount(file *common.File) (count int, err error) {
+	value, err := b.incrementCounter(&common.File{}, file.ID, "download_count", 1)
+	if err != nil {
+		return 0, fmt.Errorf("unable to update file download count : %s", err)
+	}
+
+	file.DownloadCount = int(value)
+
+	return file.DownloadCount, nil
+}
+
+// SetFileDeleteFailure count a failed deletion of the file from the data backend and save when to try again
+func (b *Backend) SetFileDeleteFailure(file *common.File, nextAttemptAt time.Time) error {
+	err := b.db.Model(&common.File{}).Where("id = ?", file.ID).
+		Updates(map[string]interface{}{"delete_attempts": gorm.Expr("delete_attempts + 1"), "next_delete_attempt_at": nextAttemptAt}).Error
+	if err != nil {
+		return fmt.Errorf("unable to update file delete attempts : %s", err)
+	}
+
+	file.DeleteAttempts++
+	file.NextDeleteAttemptAt = &nextAttemptAt
+
+	return nil
+}
+
 // UpdateFile update a file in DB. Status ensure the file status has not changed since loaded
 func (b *Backend) UpdateFile(file *common.File, status string) error {
 	result := b.db.Where(&common.File{ID: file.ID, Status: status}).Save(file)
@@ -63,6 +150,23 @@ func (b *Backend) UpdateFileStatus(file *common.File, oldStatus string, newStatu
 	return nil
 }
 
+// SetFileThumbnail flag the file as having a thumbnail stored in the data backend
+func (b *Backend) SetFileThumbnail(file *common.File) error {
+	err := b.db.Model(&common.File{}).Where("id = ?", file.ID).Update("thumbnail", true).Error
+	if err != nil {
+		return err
+	}
+
+	file.Thumbnail = true
+
+	return nil
+}
+
+// UpdateFileBackendDetails save the data backend details of the file
+func (b *Backend) UpdateFileBackendDetails(file *common.File) error {
+	return b.db.Model(&common.File{}).Where("id = ?", file.ID).Update("backend_details", file.BackendDetails).Error
+}
+
 // RemoveFile change the file status to removed
 // The file will then be deleted from the data backend by the server and the status changed to deleted.
 func (b *Backend) RemoveFile(file *common.File) error {
@@ -82,6 +186,51 @@ func (b *Backend) RemoveFile(file *common.File) error {
 	}
 }
 
+// RemoveExpiredFiles remove the files that have expired before their upload.
+// Uploads are soft deleted once all their files have expired
+func (b *Backend) RemoveExpiredFiles() (removed int, err error) {
+	var files []*common.File
+	err = b.db.Where("expire_at < ?", time.Now()).
+		Where("status IN ?", []string{common.FileMissing, common.FileUploading, common.FileUploaded}).
+		Find(&files).Error
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch expired files : %s", err)
+	}
+
+	var errors []error
+	for _, file := range files {
+		err = b.RemoveFile(file)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		removed++
+
+		var alive int64
+		err = b.db.Model(&common.File{}).Where("upload_id = ?", file.UploadID).
+			Where("status IN ?", []string{common.FileMissing, common.FileUploading, common.FileUploaded}).
+			Count(&alive).Error
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		if alive == 0 {
+			err = b.RemoveUpload(file.UploadID)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return removed, fmt.Errorf("unable to remove %d expired files", len(errors))
+	}
+
+	return removed, nil
+}
+
 // ForEachUploadFiles execute f for each file of the upload
 func (b *Backend) ForEachUploadFiles(uploadID string, f func(file *common.File) error) (err error) {
 	rows, err := b.db.Model(&common.File{}).Where(&common.File{UploadID: uploadID}).Rows()
@@ -128,6 +277,29 @@ func (b *Backend) ForEachRemovedFile(f func(file *common.File) error) (err error
 	return nil
 }
 
+// ForEachUploadedFileCreatedBefore execute f for every uploaded file created before the deadline
+func (b *Backend) ForEachUploadedFileCreatedBefore(deadline time.Time, f func(file *common.File) error) (err error) {
+	rows, err := b.db.Model(&common.File{}).Where("status = ? AND created_at < ?", common.FileUploaded, deadline).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		file := &common.File{}
+		err = b.db.ScanRows(rows, file)
+		if err != nil {
+			return err
+		}
+		err = f(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CountUploadFiles count how many files have been added to an upload
 func (b *Backend) CountUploadFiles(uploadID string) (count int, err error) {
 	var c int64 // Gorm V2 requires int64 for counts
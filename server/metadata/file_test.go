@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -128,6 +129,122 @@ func TestBackend_UpdateFileStatus(t *testing.T) {
 	require.Error(t, err, "update file status error expected")
 }
 
+func TestBackend_StartFileDownload(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	started, err := b.StartFileDownload(file, 0)
+	require.NoError(t, err, "start file download error")
+	require.False(t, started, "should not be able to download a missing file")
+
+	err = b.UpdateFileStatus(file, common.FileMissing, common.FileUploaded)
+	require.NoError(t, err, "update file status error")
+
+	started, err = b.StartFileDownload(file, 0)
+	require.NoError(t, err, "start file download error")
+	require.True(t, started, "unable to start file download")
+	require.NotNil(t, file.LastDownloadAt, "missing last download date")
+
+	started, err = b.StartFileDownload(file, 0)
+	require.NoError(t, err, "start file download error")
+	require.False(t, started, "should not be able to start file download twice")
+
+	// The download can only be resumed where it stopped once over
+	err = b.EndFileDownload(file, 4)
+	require.NoError(t, err, "end file download error")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, int64(4), f.DeliveredBytes, "invalid delivered bytes")
+	require.False(t, f.DownloadInProgress, "download should not be in progress")
+
+	started, err = b.StartFileDownload(file, 0)
+	require.NoError(t, err, "start file download error")
+	require.False(t, started, "should not be able to download delivered bytes again")
+
+	started, err = b.StartFileDownload(file, 4)
+	require.NoError(t, err, "start file download error")
+	require.True(t, started, "unable to resume file download")
+	require.True(t, file.DownloadInProgress, "download should be in progress")
+
+	// Delivered bytes never decrease
+	err = b.EndFileDownload(file, 2)
+	require.NoError(t, err, "end file download error")
+
+	f, err = b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, int64(4), f.DeliveredBytes, "invalid delivered bytes")
+}
+
+func TestBackend_UpdateFileDelivery(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	err := b.UpdateFileDelivery(file, 42)
+	require.NoError(t, err, "update file delivery error")
+
+	err = b.UpdateFileDelivery(file, 10)
+	require.NoError(t, err, "update file delivery error")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, int64(42), f.DeliveredBytes, "delivered bytes should never decrease")
+	require.NotNil(t, f.LastDownloadAt, "missing last download date")
+}
+
+func TestBackend_IncrementFileDownloadCount(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	count, err := b.IncrementFileDownloadCount(file)
+	require.NoError(t, err, "increment file download count error")
+	require.Equal(t, 1, count, "invalid download count")
+
+	count, err = b.IncrementFileDownloadCount(file)
+	require.NoError(t, err, "increment file download count error")
+	require.Equal(t, 2, count, "invalid download count")
+	require.Equal(t, 2, file.DownloadCount, "invalid download count")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, 2, f.DownloadCount, "invalid download count")
+}
+
+func TestBackend_SetFileDeleteFailure(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	next := time.Now().Add(time.Hour)
+	err := b.SetFileDeleteFailure(file, next)
+	require.NoError(t, err, "set file delete failure error")
+
+	err = b.SetFileDeleteFailure(file, next)
+	require.NoError(t, err, "set file delete failure error")
+	require.Equal(t, 2, file.DeleteAttempts, "invalid delete attempts")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, 2, f.DeleteAttempts, "invalid delete attempts")
+	require.NotNil(t, f.NextDeleteAttemptAt, "missing next delete attempt date")
+	require.True(t, f.NextDeleteAttemptAt.After(time.Now()), "invalid next delete attempt date")
+}
+
 func TestBackend_RemoveFile(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -175,6 +292,61 @@ func TestBackend_RemoveFile(t *testing.T) {
 	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
 }
 
+func TestBackend_RemoveExpiredFiles(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	expired := time.Now().Add(-time.Hour)
+	alive := time.Now().Add(time.Hour)
+
+	upload := &common.Upload{}
+	manifest := upload.NewFile()
+	manifest.Status = common.FileUploaded
+	manifest.ExpireAt = &expired
+	data := upload.NewFile()
+	data.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	upload2 := &common.Upload{}
+	file2 := upload2.NewFile()
+	file2.Status = common.FileUploaded
+	file2.ExpireAt = &alive
+	createUpload(t, b, upload2)
+
+	removed, err := b.RemoveExpiredFiles()
+	require.NoError(t, err, "remove expired files error")
+	require.Equal(t, 1, removed, "invalid removed file count")
+
+	f, err := b.GetFile(manifest.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+
+	f, err = b.GetFile(data.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, common.FileUploaded, f.Status, "invalid file status")
+
+	u, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, u, "upload should not be removed")
+
+	// The upload is removed once all its files have expired
+	data.ExpireAt = &expired
+	err = b.UpdateFile(data, common.FileUploaded)
+	require.NoError(t, err, "update file error")
+
+	removed, err = b.RemoveExpiredFiles()
+	require.NoError(t, err, "remove expired files error")
+	require.Equal(t, 1, removed, "invalid removed file count")
+
+	u, err = b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, u, "upload should be removed")
+
+	u, err = b.GetUpload(upload2.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, u, "upload should not be removed")
+}
+
 func TestBackend_ForEachUploadFiles(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -267,3 +439,69 @@ func TestBackend_ForEachFile(t *testing.T) {
 	err = b.ForEachFile(f)
 	require.Errorf(t, err, "expected")
 }
+
+func TestBackend_SetFileThumbnail(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	err := b.SetFileThumbnail(file)
+	require.NoError(t, err, "set file thumbnail error")
+	require.True(t, file.Thumbnail, "file should have a thumbnail")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.True(t, f.Thumbnail, "file should have a thumbnail")
+}
+
+func TestBackend_UpdateFileBackendDetails(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	file.BackendDetails = `{"StorageClass":"STANDARD_IA"}`
+	err := b.UpdateFileBackendDetails(file)
+	require.NoError(t, err, "update file backend details error")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, file.BackendDetails, f.BackendDetails, "invalid backend details")
+}
+
+func TestBackend_ForEachUploadedFileCreatedBefore(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	old := upload.NewFile()
+	old.Status = common.FileUploaded
+	upload.NewFile().Status = common.FileUploaded
+	upload.NewFile().Status = common.FileRemoved
+	createUpload(t, b, upload)
+
+	err := b.db.Model(&common.File{}).Where("id = ?", old.ID).Update("created_at", time.Now().Add(-48*time.Hour)).Error
+	require.NoError(t, err, "unable to update file creation date")
+
+	var files []*common.File
+	f := func(file *common.File) error {
+		files = append(files, file)
+		return nil
+	}
+
+	err = b.ForEachUploadedFileCreatedBefore(time.Now().Add(-24*time.Hour), f)
+	require.NoError(t, err, "for each uploaded file error")
+	require.Len(t, files, 1, "file count mismatch")
+	require.Equal(t, old.ID, files[0].ID, "invalid file")
+
+	f = func(file *common.File) error {
+		return fmt.Errorf("expected")
+	}
+	err = b.ForEachUploadedFileCreatedBefore(time.Now(), f)
+	require.Error(t, err, "for each uploaded file error expected")
+}
@@ -1,7 +1,9 @@
 package metadata
 
 import (
+	"bufio"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -19,9 +21,8 @@ type ImportOptions struct {
 }
 
 type importer struct {
-	reader       io.ReadCloser
-	decompressor *snappy.Reader
-	decoder      *gob.Decoder
+	reader io.ReadCloser
+	decode func() (obj *object, err error)
 }
 
 func newImporter(path string) (i *importer, err error) {
@@ -33,16 +34,41 @@ func newImporter(path string) (i *importer, err error) {
 		return nil, err
 	}
 
-	// Snappy decompressor
-	i.decompressor = snappy.NewReader(i.reader)
+	// JSON lines exports start with a JSON object, binary exports with the snappy stream identifier
+	buffer := bufio.NewReader(i.reader)
+	first, err := buffer.Peek(1)
+	if err != nil && err != io.EOF {
+		_ = i.reader.Close()
+		return nil, err
+	}
 
-	// Gog decoder
-	gob.Register(&common.Upload{})
-	gob.Register(&common.File{})
-	gob.Register(&common.User{})
-	gob.Register(&common.Token{})
-	gob.Register(&common.Setting{})
-	i.decoder = gob.NewDecoder(i.decompressor)
+	if len(first) == 1 && first[0] == '{' {
+		decoder := json.NewDecoder(buffer)
+		i.decode = func() (obj *object, err error) {
+			record := &jsonRecord{}
+			err = decoder.Decode(record)
+			if err != nil {
+				return nil, err
+			}
+			return record.toObject()
+		}
+		return i, nil
+	}
+
+	// Snappy decompressor
+	decompressor := snappy.NewReader(buffer)
+
+	// Gob decoder
+	registerGobTypes()
+	decoder := gob.NewDecoder(decompressor)
+	i.decode = func() (obj *object, err error) {
+		obj = &object{}
+		err = decoder.Decode(obj)
+		if err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
 
 	return i, nil
 }
@@ -51,7 +77,7 @@ func (i *importer) close() (err error) {
 	return i.reader.Close()
 }
 
-// Import imports metadata from a compressed binary file
+// Import imports metadata from a compressed binary file or a JSON lines file
 func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 	i, err := newImporter(path)
 	if err != nil {
@@ -64,8 +90,8 @@ func (b *Backend) Import(path string, options *ImportOptions) (err error) {
 	var uploadErrors, fileErrors, userErrors, tokenErrors, settingErrors int
 
 	for {
-		obj := &object{}
-		err = i.decoder.Decode(obj)
+		var obj *object
+		obj, err = i.decode()
 		if err == io.EOF {
 			break
 		} else if err != nil {
@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// jsonRecord is a line of a JSON lines metadata export
+type jsonRecord struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+var jsonRecordTypes = map[metadataType]string{
+	metadataTypeUpload:  "upload",
+	metadataTypeFile:    "file",
+	metadataTypeUser:    "user",
+	metadataTypeToken:   "token",
+	metadataTypeSetting: "setting",
+}
+
+func newJSONRecord(obj *object) (record *jsonRecord, err error) {
+	name, ok := jsonRecordTypes[obj.Type]
+	if !ok {
+		return nil, fmt.Errorf("invalid object type")
+	}
+
+	// The API JSON representation hides some fields ( password hashes, backend details, ... ) that have to be exported
+	value := reflect.ValueOf(obj.Object).Elem()
+	untagged := value.Convert(getUntaggedType(value.Type()))
+
+	record = &jsonRecord{Type: name}
+	record.Object, err = json.Marshal(untagged.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (record *jsonRecord) toObject() (obj *object, err error) {
+	obj = &object{}
+
+	switch record.Type {
+	case "upload":
+		obj.Type, obj.Object = metadataTypeUpload, &common.Upload{}
+	case "file":
+		obj.Type, obj.Object = metadataTypeFile, &common.File{}
+	case "user":
+		obj.Type, obj.Object = metadataTypeUser, &common.User{}
+	case "token":
+		obj.Type, obj.Object = metadataTypeToken, &common.Token{}
+	case "setting":
+		obj.Type, obj.Object = metadataTypeSetting, &common.Setting{}
+	default:
+		return nil, fmt.Errorf("invalid object type %s", record.Type)
+	}
+
+	value := reflect.ValueOf(obj.Object).Elem()
+	untagged := reflect.New(getUntaggedType(value.Type()))
+
+	err = json.Unmarshal(record.Object, untagged.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s : %s", record.Type, err)
+	}
+
+	value.Set(untagged.Elem().Convert(value.Type()))
+	return obj, nil
+}
+
+var untaggedTypes sync.Map
+
+// getUntaggedType return a copy of the struct type encoding all its fields in JSON under their Go name.
+// Struct types only differing by their tags can be converted to one another.
+func getUntaggedType(t reflect.Type) reflect.Type {
+	if untagged, ok := untaggedTypes.Load(t); ok {
+		return untagged.(reflect.Type)
+	}
+
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		field.Tag = reflect.StructTag(fmt.Sprintf(`json:"%s"`, field.Name))
+		fields = append(fields, field)
+	}
+
+	untagged := reflect.StructOf(fields)
+	untaggedTypes.Store(t, untagged)
+	return untagged
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-gormigrate/gormigrate/v2"
@@ -18,16 +19,17 @@ import (
 
 // Config metadata backend configuration
 type Config struct {
-	Driver             string
-	ConnectionString   string
-	EraseFirst         bool
-	MaxOpenConns       int
-	MaxIdleConns       int
-	Debug              bool
-	SlowQueryThreshold string // Duration string
-	noMigrations       bool   // For testing
-	migrationFilter    func([]*gormigrate.Migration) []*gormigrate.Migration
-	disableSchemaInit  bool // For testing
+	Driver               string
+	ConnectionString     string
+	EraseFirst           bool
+	MaxOpenConns         int
+	MaxIdleConns         int
+	Debug                bool
+	SlowQueryThreshold   string // Duration string
+	DisableAutoMigration bool   // Refuse to start instead of applying pending migrations, use "plikd migrate" to apply them
+	noMigrations         bool   // For testing
+	migrationFilter      func([]*gormigrate.Migration) []*gormigrate.Migration
+	disableSchemaInit    bool // For testing
 }
 
 // NewConfig instantiate a new default configuration
@@ -128,7 +130,7 @@ func NewBackend(config *Config, log *logger.Logger) (b *Backend, err error) {
 
 	// For testing
 	if config.EraseFirst {
-		err = b.db.Migrator().DropTable("files", "uploads", "tokens", "users", "settings", "migrations")
+		err = b.db.Migrator().DropTable("files", "uploads", "tokens", "users", "settings", "sessions", "upload_password_attempts", "migrations")
 		if err != nil {
 			return nil, fmt.Errorf("unable to drop tables : %s", err)
 		}
@@ -155,8 +157,22 @@ func NewBackend(config *Config, log *logger.Logger) (b *Backend, err error) {
 }
 
 // Initialize the metadata backend.
-//  - Create or update the database schema if needed
+//   - Create or update the database schema if needed
 func (b *Backend) initializeSchema() (err error) {
+	applied, pending, unknown, err := b.getSchemaStatus()
+	if err != nil {
+		return fmt.Errorf("unable to get database schema version : %s", err)
+	}
+
+	// Running an older binary could silently corrupt the data
+	if len(unknown) > 0 {
+		return fmt.Errorf("database schema is more recent than expected, unknown migrations : %s", strings.Join(unknown, ", "))
+	}
+
+	if len(applied) > 0 && len(pending) > 0 && b.Config.DisableAutoMigration {
+		return fmt.Errorf("database schema is outdated, please run \"plikd migrate\" to apply pending migrations : %s", strings.Join(pending, ", "))
+	}
+
 	m := gormigrate.New(b.db, gormigrate.DefaultOptions, b.getMigrations())
 
 	if !b.Config.disableSchemaInit {
@@ -170,6 +186,8 @@ func (b *Backend) initializeSchema() (err error) {
 				&common.User{},
 				&common.Token{},
 				&common.Setting{},
+				&common.Session{},
+				&common.UploadPasswordAttempts{},
 			)
 
 			return err
@@ -231,7 +249,7 @@ func (b *Backend) Shutdown() (err error) {
 }
 
 // Clean metadata database
-//  - Remove orphan files and tokens
+//   - Remove orphan files and tokens
 func (b *Backend) Clean() error {
 	return b.clean(b.db)
 }
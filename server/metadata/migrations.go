@@ -1,12 +1,19 @@
 package metadata
 
 import (
+	"sort"
 	"time"
 
 	"github.com/go-gormigrate/gormigrate/v2"
 	"gorm.io/gorm"
 )
 
+// schemaInitMigrationID is the migration ID gormigrate reserves for the schema initialization
+const schemaInitMigrationID = "SCHEMA_INIT"
+
+// retiredMigrations have been applied by previous versions but are not needed anymore
+var retiredMigrations = []string{"0000-cleaning"}
+
 func (b *Backend) getMigrations() []*gormigrate.Migration {
 	migrations := []*gormigrate.Migration{
 		{
@@ -137,12 +144,875 @@ func (b *Backend) getMigrations() []*gormigrate.Migration {
 				b.log.Criticalf("Something went wrong. Please check database status manually")
 				return nil
 			},
+		}, {
+			ID: "0004-idempotency-key",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					User           string  `json:"user,omitempty" gorm:"index:idx_upload_user;uniqueIndex:idx_upload_user_idempotency_key,priority:1"`
+					IdempotencyKey *string `json:"-" gorm:"uniqueIndex:idx_upload_user_idempotency_key,priority:2"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0004-idempotency-key")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0005-management-password",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ManagementPassword string `json:"managementPassword,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0005-management-password")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0006-file-encryption",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					EncryptionScheme string `json:"encryptionScheme,omitempty"`
+					EncryptionNonce  string `json:"encryptionNonce,omitempty"`
+					WrappedKey       string `json:"wrappedKey,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0006-file-encryption")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0007-token-last-used",
+			Migrate: func(tx *gorm.DB) error {
+				type Token struct {
+					LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+					LastUsedIP string     `json:"lastUsedIP,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0007-token-last-used")
+				return b.setupTxForMigration(tx).AutoMigrate(&Token{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0008-upload-public",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					Public bool `json:"public"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0008-upload-public")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0009-oneshot-download-tracking",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					DeliveredBytes int64      `json:"-"`
+					LastDownloadAt *time.Time `json:"-"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0009-oneshot-download-tracking")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0010-data-backend-routing",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					DataBackend string `json:"dataBackend,omitempty"`
+				}
+
+				type File struct {
+					DataBackend string `json:"-"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0010-data-backend-routing")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{}, &File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0011-upload-download-domain",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					DownloadDomain string `json:"downloadDomain"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0011-upload-download-domain")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0012-upload-expiry-warning",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ExpiryWarningSent bool `json:"-"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0012-upload-expiry-warning")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0013-upload-client-app",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ClientApp string `json:"clientApp,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0013-upload-client-app")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0014-upload-content-disposition",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ContentDisposition string `json:"contentDisposition,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0014-upload-content-disposition")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0015-upload-link",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					UploadLinkID *string `json:"-" gorm:"uniqueIndex:idx_upload_link_id"`
+					UploadLink   string  `json:"-"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0015-upload-link")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0016-file-thumbnail",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					Thumbnail bool `json:"thumbnail"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0016-file-thumbnail")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0017-upload-downloaded-bytes",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					MaxTotalDownloadBytes int64 `json:"maxTotalDownloadBytes,omitempty"`
+					DownloadedBytes       int64 `json:"downloadedBytes,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0017-upload-downloaded-bytes")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0018-file-relative-path",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					RelativePath string `json:"relativePath,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0018-file-relative-path")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
 		},
-	}
+		{
+			ID: "0019-file-content-encoding",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					ContentEncoding string `json:"contentEncoding,omitempty"`
+				}
 
-	if b.Config.migrationFilter != nil {
-		migrations = b.Config.migrationFilter(migrations)
-	}
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
 
-	return migrations
+				b.log.Warning("Applying database migration 0019-file-content-encoding")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0020-upload-preset",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					Preset string `json:"preset,omitempty"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0020-upload-preset")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0021-file-download-count",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					DownloadCount int `json:"downloadCount"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0021-file-download-count")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0022-file-delete-attempts",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					DeleteAttempts      int
+					NextDeleteAttemptAt *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0022-file-delete-attempts")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0023-upload-user-metadata",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					UserMetadata string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0023-upload-user-metadata")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0024-file-media-metadata",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					Width    int
+					Height   int
+					Duration float64
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0024-file-media-metadata")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0025-upload-pending-downloads",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					PendingDownloads      int
+					PendingDownloadsSince *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0025-upload-pending-downloads")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0026-upload-ttl-from-completion",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					TTLFromCompletion bool
+					CompletedAt       *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0026-upload-ttl-from-completion")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0027-token-allowed-origins",
+			Migrate: func(tx *gorm.DB) error {
+				type Token struct {
+					AllowedOrigins string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0027-token-allowed-origins")
+				return b.setupTxForMigration(tx).AutoMigrate(&Token{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0028-upload-inactivity-ttl",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					InactivityTTL  int
+					LastAccessedAt *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0028-upload-inactivity-ttl")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0029-token-expire-at",
+			Migrate: func(tx *gorm.DB) error {
+				type Token struct {
+					ExpireAt *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0029-token-expire-at")
+				return b.setupTxForMigration(tx).AutoMigrate(&Token{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0030-upload-ready-notification",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ReadyNotificationPending bool
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0030-upload-ready-notification")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0031-sessions",
+			Migrate: func(tx *gorm.DB) error {
+				type Session struct {
+					ID     string `gorm:"primary_key"`
+					UserID string `gorm:"index:idx_session_user_id"`
+
+					IP        string
+					UserAgent string
+
+					CreatedAt  time.Time
+					LastSeenAt time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0031-sessions")
+				return b.setupTxForMigration(tx).AutoMigrate(&Session{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0032-file-ttl",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					TTL      int
+					ExpireAt *time.Time `gorm:"index:idx_file_expire_at"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0032-file-ttl")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0033-upload-download-countries",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					AllowedCountries string
+					BlockedCountries string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0033-upload-download-countries")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0034-upload-expand-archives",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					ExpandArchives bool
+					KeepArchives   bool
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0034-upload-expand-archives")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0035-upload-password-attempts",
+			Migrate: func(tx *gorm.DB) error {
+				type UploadPasswordAttempts struct {
+					UploadID string `gorm:"primary_key"`
+					IP       string `gorm:"primary_key"`
+
+					Failures    int
+					LockedUntil *time.Time
+					UpdatedAt   time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0035-upload-password-attempts")
+				return b.setupTxForMigration(tx).AutoMigrate(&UploadPasswordAttempts{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		}, {
+			ID: "0036-user-last-login",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					LastLoginAt             *time.Time
+					InactivityWarningSentAt *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0036-user-last-login")
+				err = b.setupTxForMigration(tx).AutoMigrate(&User{})
+				if err != nil {
+					return err
+				}
+
+				// Existing users are considered active at the time of the migration
+				// to not delete them as soon as InactiveUserRetention is enabled
+				return tx.Model(&User{}).Where("last_login_at IS NULL").Update("last_login_at", time.Now()).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0037-upload-webhook",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					Webhook string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0037-upload-webhook")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0038-file-ascii-name",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					ASCIIName string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0038-file-ascii-name")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0039-user-tos-acceptance",
+			Migrate: func(tx *gorm.DB) error {
+				type User struct {
+					TOSAcceptedVersion string
+					TOSAcceptedAt      *time.Time
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0039-user-tos-acceptance")
+				return b.setupTxForMigration(tx).AutoMigrate(&User{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0040-upload-download-count",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					DownloadCount int
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0040-upload-download-count")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0041-upload-idempotency-hash",
+			Migrate: func(tx *gorm.DB) error {
+				type Upload struct {
+					IdempotencyHash string
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0041-upload-idempotency-hash")
+				return b.setupTxForMigration(tx).AutoMigrate(&Upload{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+		{
+			ID: "0042-file-download-in-progress",
+			Migrate: func(tx *gorm.DB) error {
+				type File struct {
+					DownloadInProgress bool `gorm:"not null;default:false"`
+				}
+
+				err := b.clean(tx)
+				if err != nil {
+					return err
+				}
+
+				b.log.Warning("Applying database migration 0042-file-download-in-progress")
+				return b.setupTxForMigration(tx).AutoMigrate(&File{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				b.log.Criticalf("Something went wrong. Please check database status manually")
+				return nil
+			},
+		},
+	}
+
+	if b.Config.migrationFilter != nil {
+		migrations = b.Config.migrationFilter(migrations)
+	}
+
+	return migrations
+}
+
+// getSchemaStatus compare the migrations applied to the database with the migrations known by this binary
+func (b *Backend) getSchemaStatus() (applied []string, pending []string, unknown []string, err error) {
+	options := gormigrate.DefaultOptions
+	if b.db.Migrator().HasTable(options.TableName) {
+		err = b.db.Table(options.TableName).Pluck(options.IDColumnName, &applied).Error
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	isKnown := make(map[string]bool)
+	isKnown[schemaInitMigrationID] = true
+	for _, id := range retiredMigrations {
+		isKnown[id] = true
+	}
+
+	isApplied := make(map[string]bool)
+	for _, id := range applied {
+		isApplied[id] = true
+	}
+
+	for _, migration := range b.getMigrations() {
+		isKnown[migration.ID] = true
+		if !isApplied[migration.ID] {
+			pending = append(pending, migration.ID)
+		}
+	}
+
+	for _, id := range applied {
+		if !isKnown[id] {
+			unknown = append(unknown, id)
+		}
+	}
+
+	return applied, pending, unknown, nil
+}
+
+// GetSchemaVersion return the last migration applied to the database and the last migration known by this binary
+func (b *Backend) GetSchemaVersion() (version string, expected string, err error) {
+	applied, _, _, err := b.getSchemaStatus()
+	if err != nil {
+		return "", "", err
+	}
+
+	// Migration IDs are prefixed by a sequence number
+	sort.Strings(applied)
+	for _, id := range applied {
+		if id != schemaInitMigrationID {
+			version = id
+		}
+	}
+
+	migrations := b.getMigrations()
+	if len(migrations) > 0 {
+		expected = migrations[len(migrations)-1].ID
+	}
+
+	return version, expected, nil
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-gormigrate/gormigrate/v2"
 	"github.com/root-gg/logger"
 	"github.com/root-gg/plik/server/common"
 	"github.com/stretchr/testify/require"
@@ -177,7 +178,7 @@ func TestGenerateExport(t *testing.T) {
 
 	fmt.Printf("Missing metadata export dump %s\n", path)
 
-	err = b.Export(path)
+	err = b.Export(path, &ExportOptions{})
 	require.NoError(t, err, "unable to export metadata")
 }
 
@@ -430,3 +431,59 @@ func TestMigrationsFromSQLDumps(t *testing.T) {
 		shutdownTestMetadataBackend(b)
 	}
 }
+
+func TestGetSchemaVersion(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	migrations := b.getMigrations()
+	lastMigration := migrations[len(migrations)-1].ID
+
+	version, expected, err := b.GetSchemaVersion()
+	require.NoError(t, err, "unable to get schema version")
+	require.Equal(t, lastMigration, version, "invalid schema version")
+	require.Equal(t, lastMigration, expected, "invalid expected schema version")
+}
+
+func TestDisableAutoMigration(t *testing.T) {
+	// Initialize the database with a binary unaware of the last migration
+	testConfig := &Config{}
+	*testConfig = *metadataBackendConfig
+	testConfig.migrationFilter = func(migrations []*gormigrate.Migration) []*gormigrate.Migration {
+		return migrations[:len(migrations)-1]
+	}
+	b, err := NewBackend(testConfig, logger.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+	shutdownTestMetadataBackend(b)
+
+	*testConfig = *metadataBackendConfig
+	testConfig.EraseFirst = false
+	testConfig.DisableAutoMigration = true
+	_, err = NewBackend(testConfig, logger.NewLogger())
+	common.RequireError(t, err, "database schema is outdated")
+
+	// Apply the pending migration
+	testConfig.DisableAutoMigration = false
+	b, err = NewBackend(testConfig, logger.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+	shutdownTestMetadataBackend(b)
+
+	// Migrations are idempotent
+	testConfig.DisableAutoMigration = true
+	b, err = NewBackend(testConfig, logger.NewLogger())
+	require.NoError(t, err, "unable to create metadata backend")
+	shutdownTestMetadataBackend(b)
+}
+
+func TestUnknownMigration(t *testing.T) {
+	b := newTestMetadataBackend()
+	err := b.db.Exec("INSERT INTO migrations (id) VALUES (?)", "9999-from-the-future").Error
+	require.NoError(t, err, "unable to insert migration")
+	shutdownTestMetadataBackend(b)
+
+	testConfig := &Config{}
+	*testConfig = *metadataBackendConfig
+	testConfig.EraseFirst = false
+	_, err = NewBackend(testConfig, logger.NewLogger())
+	common.RequireError(t, err, "database schema is more recent than expected, unknown migrations : 9999-from-the-future")
+}
@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// CreateSession create a new session in DB
+func (b *Backend) CreateSession(session *common.Session) (err error) {
+	return b.db.Create(session).Error
+}
+
+// GetSession return a session from the DB ( return nil and no error if not found )
+func (b *Backend) GetSession(sessionID string) (session *common.Session, err error) {
+	session = &common.Session{}
+	err = b.db.Where(&common.Session{ID: sessionID}).Take(session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return session, err
+}
+
+// GetUserSessions return all the sessions of a user, the most recently seen first
+func (b *Backend) GetUserSessions(userID string) (sessions []*common.Session, err error) {
+	err = b.db.Where(&common.Session{UserID: userID}).Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch user sessions : %s", err)
+	}
+	return sessions, nil
+}
+
+// UpdateSessionLastSeen save when and from where a session was last seen
+// Only the last seen columns are updated to not overwrite concurrent changes
+func (b *Backend) UpdateSessionLastSeen(sessionID string, lastSeenAt time.Time, ip string) (err error) {
+	result := b.db.Model(&common.Session{}).Where(&common.Session{ID: sessionID}).Updates(map[string]interface{}{
+		"last_seen_at": lastSeenAt,
+		"ip":           ip,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("unable to update session metadata : %s", result.Error)
+	}
+
+	return nil
+}
+
+// DeleteSession remove a session from the DB
+func (b *Backend) DeleteSession(sessionID string) (deleted bool, err error) {
+	result := b.db.Delete(&common.Session{ID: sessionID})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to delete session metadata : %s", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteUserSessions remove all the sessions of a user but the one to keep ( if any )
+func (b *Backend) DeleteUserSessions(userID string, keepSessionID string) (removed int, err error) {
+	result := b.db.Where("user_id = ? AND id <> ?", userID, keepSessionID).Delete(&common.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete sessions metadata : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
+// DeleteExpiredSessions remove the sessions created before deadline, their session cookies have timed out
+func (b *Backend) DeleteExpiredSessions(deadline time.Time) (removed int, err error) {
+	result := b.db.Where("created_at < ?", deadline).Delete(&common.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete expired sessions : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
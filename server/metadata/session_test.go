@@ -0,0 +1,126 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestBackend_Sessions(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	session, err := b.GetSession("session")
+	require.NoError(t, err, "get session error")
+	require.Nil(t, session, "non nil session")
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	session1 := common.NewSession(user, "1.1.1.1", "agent1")
+	session1.LastSeenAt = time.Now().Add(-time.Hour)
+	err = b.CreateSession(session1)
+	require.NoError(t, err, "create session error")
+
+	session2 := common.NewSession(user, "2.2.2.2", "agent2")
+	err = b.CreateSession(session2)
+	require.NoError(t, err, "create session error")
+
+	other := common.NewUser(common.ProviderLocal, "other")
+	createUser(t, b, other)
+	session3 := common.NewSession(other, "3.3.3.3", "agent3")
+	err = b.CreateSession(session3)
+	require.NoError(t, err, "create session error")
+
+	session, err = b.GetSession(session1.ID)
+	require.NoError(t, err, "get session error")
+	require.NotNil(t, session, "missing session")
+	require.Equal(t, user.ID, session.UserID, "invalid session user")
+	require.Equal(t, "agent1", session.UserAgent, "invalid session user agent")
+
+	sessions, err := b.GetUserSessions(user.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Len(t, sessions, 2, "invalid session count")
+	require.Equal(t, session2.ID, sessions[0].ID, "most recently seen session should be first")
+
+	err = b.UpdateSessionLastSeen(session1.ID, time.Now().Add(time.Minute), "4.4.4.4")
+	require.NoError(t, err, "update session last seen error")
+
+	sessions, err = b.GetUserSessions(user.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Equal(t, session1.ID, sessions[0].ID, "most recently seen session should be first")
+	require.Equal(t, "4.4.4.4", sessions[0].IP, "invalid session ip")
+
+	deleted, err := b.DeleteSession(session1.ID)
+	require.NoError(t, err, "delete session error")
+	require.True(t, deleted, "session should have been deleted")
+
+	deleted, err = b.DeleteSession(session1.ID)
+	require.NoError(t, err, "delete session error")
+	require.False(t, deleted, "session should not have been deleted")
+
+	session4 := common.NewSession(user, "5.5.5.5", "agent4")
+	err = b.CreateSession(session4)
+	require.NoError(t, err, "create session error")
+
+	removed, err := b.DeleteUserSessions(user.ID, session4.ID)
+	require.NoError(t, err, "delete user sessions error")
+	require.Equal(t, 1, removed, "invalid removed session count")
+
+	sessions, err = b.GetUserSessions(user.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Len(t, sessions, 1, "invalid session count")
+	require.Equal(t, session4.ID, sessions[0].ID, "current session should have been kept")
+
+	sessions, err = b.GetUserSessions(other.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Len(t, sessions, 1, "other user sessions should have been kept")
+}
+
+func TestBackend_DeleteExpiredSessions(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	expired := common.NewSession(user, "", "")
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour)
+	err := b.CreateSession(expired)
+	require.NoError(t, err, "create session error")
+
+	session := common.NewSession(user, "", "")
+	err = b.CreateSession(session)
+	require.NoError(t, err, "create session error")
+
+	removed, err := b.DeleteExpiredSessions(time.Now().Add(-time.Hour))
+	require.NoError(t, err, "delete expired sessions error")
+	require.Equal(t, 1, removed, "invalid removed session count")
+
+	sessions, err := b.GetUserSessions(user.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Len(t, sessions, 1, "invalid session count")
+	require.Equal(t, session.ID, sessions[0].ID, "invalid remaining session")
+}
+
+func TestBackend_DeleteUserSessions(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	err := b.CreateSession(common.NewSession(user, "", ""))
+	require.NoError(t, err, "create session error")
+
+	deleted, err := b.DeleteUser(user.ID)
+	require.NoError(t, err, "delete user error")
+	require.True(t, deleted, "user should have been deleted")
+
+	sessions, err := b.GetUserSessions(user.ID)
+	require.NoError(t, err, "get user sessions error")
+	require.Len(t, sessions, 0, "user sessions should have been deleted")
+}
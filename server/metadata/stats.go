@@ -1,6 +1,12 @@
 package metadata
 
-import "github.com/root-gg/plik/server/common"
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/root-gg/plik/server/common"
+)
 
 // GetUploadStatistics return statistics about uploads
 // for userID and tokenStr params : nil doesn't activate the filter, empty string enables the filter with an empty value to generate statistics about anonymous upload
@@ -58,20 +64,45 @@ func (b *Backend) GetUserStatistics(userID string, tokenStr *string) (stats *com
 	return stats, nil
 }
 
-// GetServerStatistics return statistics about user all uploads
-func (b *Backend) GetServerStatistics() (stats *common.ServerStats, err error) {
+// GetServerStatistics return statistics about all uploads
+// if since is not nil only uploads created after since are taken into account
+func (b *Backend) GetServerStatistics(since *time.Time) (stats *common.ServerStats, err error) {
 	users, err := b.CountUsers()
 	if err != nil {
 		return nil, err
 	}
 
-	uploads, files, size, err := b.GetUploadStatistics(nil, nil)
+	window := func(db *gorm.DB) *gorm.DB {
+		if since != nil {
+			return db.Where("uploads.created_at >= ?", since)
+		}
+		return db
+	}
+
+	uploads, files, size, err := b.getUploadStatistics(window)
+	if err != nil {
+		return nil, err
+	}
+
+	anonUploads, _, anonSize, err := b.getUploadStatistics(window, func(db *gorm.DB) *gorm.DB {
+		return db.Where("uploads.user = ?", "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	expiredUploads, _, _, err := b.getUploadStatistics(window, func(db *gorm.DB) *gorm.DB {
+		return db.Where("uploads.expire_at < ?", time.Now())
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	anonID := ""
-	anonUploads, _, anonSize, err := b.GetUploadStatistics(&anonID, nil)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	uploadsToday, _, _, err := b.getUploadStatistics(func(db *gorm.DB) *gorm.DB {
+		return db.Where("uploads.created_at >= ?", today)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +114,81 @@ func (b *Backend) GetServerStatistics() (stats *common.ServerStats, err error) {
 		Files:            files,
 		TotalSize:        size,
 		AnonymousSize:    anonSize,
+		ExpiredUploads:   expiredUploads,
+		UploadsToday:     uploadsToday,
+	}
+
+	stats.DataBackend, err = b.getBackendStatistics(window, false)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.StreamBackend, err = b.getBackendStatistics(window, true)
+	if err != nil {
+		return nil, err
 	}
 
 	return stats, nil
 }
+
+// GetClientAppStatistics return statistics about the uploads created by a client app
+// if since is not nil only uploads created after since are taken into account
+func (b *Backend) GetClientAppStatistics(name string, since *time.Time) (stats *common.ClientAppStats, err error) {
+	uploads, files, size, err := b.getUploadStatistics(func(db *gorm.DB) *gorm.DB {
+		if since != nil {
+			db = db.Where("uploads.created_at >= ?", since)
+		}
+		return db.Where("uploads.client_app = ?", name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.ClientAppStats{
+		Name:      name,
+		Uploads:   uploads,
+		Files:     files,
+		TotalSize: size,
+	}
+
+	return stats, nil
+}
+
+func (b *Backend) getBackendStatistics(window func(db *gorm.DB) *gorm.DB, stream bool) (stats *common.BackendStats, err error) {
+	uploads, files, size, err := b.getUploadStatistics(window, func(db *gorm.DB) *gorm.DB {
+		return db.Where("uploads.stream = ?", stream)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats = &common.BackendStats{
+		Uploads:   uploads,
+		Files:     files,
+		TotalSize: size,
+	}
+
+	return stats, nil
+}
+
+// getUploadStatistics return statistics about the uploads matching the scopes conditions on the uploads table
+func (b *Backend) getUploadStatistics(scopes ...func(db *gorm.DB) *gorm.DB) (uploads int, files int, size int64, err error) {
+
+	// Count uploads
+	var uploadsCount int64 // Gorm V2 requires int64 for counts
+	err = b.db.Model(&common.Upload{}).Scopes(scopes...).Count(&uploadsCount).Error
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	// Count files
+	stmt := b.db.Model(&common.File{}).Select("count(files.id), coalesce(sum(size),0)").Where("files.status = ?", common.FileUploaded)
+	stmt = stmt.Joins("join uploads on uploads.id = files.upload_id").Scopes(scopes...)
+
+	err = stmt.Row().Scan(&files, &size)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return int(uploadsCount), files, size, nil
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -200,9 +201,93 @@ func TestBackend_GetServerStatistics(t *testing.T) {
 		createUpload(t, b, upload)
 	}
 
-	stats, err := b.GetServerStatistics()
+	stats, err := b.GetServerStatistics(nil)
 	require.NoError(t, err, "unexpected error")
 	require.Equal(t, 20, stats.Uploads, "invalid upload count")
 	require.Equal(t, 200, stats.Files, "invalid file count")
 	require.Equal(t, int64(400), stats.TotalSize, "invalid file size")
+	require.Equal(t, 20, stats.UploadsToday, "invalid today upload count")
+	require.Equal(t, 0, stats.ExpiredUploads, "invalid expired upload count")
+}
+
+func TestBackend_GetServerStatisticsSince(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	// Old expired upload
+	old := &common.Upload{}
+	file := old.NewFile()
+	file.Size = 2
+	file.Status = common.FileUploaded
+	old.InitializeForTests()
+	old.CreatedAt = time.Now().Add(-48 * time.Hour)
+	deadline := time.Now().Add(-24 * time.Hour)
+	old.ExpireAt = &deadline
+	err := b.CreateUpload(old)
+	require.NoError(t, err, "create upload error")
+
+	// Recent stream upload
+	stream := &common.Upload{Stream: true}
+	file = stream.NewFile()
+	file.Size = 3
+	file.Status = common.FileUploading
+	createUpload(t, b, stream)
+
+	// Recent upload
+	recent := &common.Upload{}
+	file = recent.NewFile()
+	file.Size = 4
+	file.Status = common.FileUploaded
+	createUpload(t, b, recent)
+
+	stats, err := b.GetServerStatistics(nil)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 3, stats.Uploads, "invalid upload count")
+	require.Equal(t, 2, stats.Files, "invalid file count")
+	require.Equal(t, int64(6), stats.TotalSize, "invalid file size")
+	require.Equal(t, 1, stats.ExpiredUploads, "invalid expired upload count")
+	require.Equal(t, 2, stats.DataBackend.Uploads, "invalid data backend upload count")
+	require.Equal(t, int64(6), stats.DataBackend.TotalSize, "invalid data backend file size")
+	require.Equal(t, 1, stats.StreamBackend.Uploads, "invalid stream backend upload count")
+
+	since := time.Now().Add(-time.Hour)
+	stats, err = b.GetServerStatistics(&since)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 2, stats.Uploads, "invalid upload count")
+	require.Equal(t, 1, stats.Files, "invalid file count")
+	require.Equal(t, int64(4), stats.TotalSize, "invalid file size")
+	require.Equal(t, 0, stats.ExpiredUploads, "invalid expired upload count")
+	require.Equal(t, 1, stats.DataBackend.Uploads, "invalid data backend upload count")
+	require.Equal(t, 1, stats.StreamBackend.Uploads, "invalid stream backend upload count")
+}
+
+func TestBackend_GetClientAppStatistics(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for i := 0; i < 3; i++ {
+		upload := &common.Upload{ClientApp: "backup"}
+		file := upload.NewFile()
+		file.Size = 10
+		file.Status = common.FileUploaded
+		createUpload(t, b, upload)
+	}
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Size = 10
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	stats, err := b.GetClientAppStatistics("backup", nil)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, "backup", stats.Name, "invalid client app name")
+	require.Equal(t, 3, stats.Uploads, "invalid upload count")
+	require.Equal(t, 3, stats.Files, "invalid file count")
+	require.Equal(t, int64(30), stats.TotalSize, "invalid file size")
+
+	since := time.Now().Add(time.Hour)
+	stats, err = b.GetClientAppStatistics("backup", &since)
+	require.NoError(t, err, "unexpected error")
+	require.Equal(t, 0, stats.Uploads, "invalid upload count")
 }
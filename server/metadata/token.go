@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
@@ -45,6 +46,65 @@ func (b *Backend) GetTokens(userID string, pagingQuery *common.PagingQuery) (tok
 	return tokens, &c, err
 }
 
+// UpdateTokenLastUsed save when and from where a token was last used
+// Only the last used columns are updated to not overwrite concurrent changes
+func (b *Backend) UpdateTokenLastUsed(tokenStr string, lastUsedAt time.Time, lastUsedIP string) (err error) {
+	result := b.db.Model(&common.Token{}).Where(&common.Token{Token: tokenStr}).Updates(map[string]interface{}{
+		"last_used_at": lastUsedAt,
+		"last_used_ip": lastUsedIP,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("unable to update token metadata : %s", result.Error)
+	}
+
+	return nil
+}
+
+// RotateToken save the new token and expire the rotated token at expireAt ( nil : delete it now )
+// The uploads created with the rotated token are transferred to the new token.
+// common.ErrTokenAlreadyRotated is returned if the token has already been rotated
+func (b *Backend) RotateToken(token *common.Token, rotated *common.Token, expireAt *time.Time) (err error) {
+	return b.db.Transaction(func(tx *gorm.DB) (err error) {
+		// Concurrent rotations of the same token can't both succeed
+		var result *gorm.DB
+		if expireAt == nil {
+			result = tx.Where("token = ? AND expire_at IS NULL", token.Token).Delete(&common.Token{})
+		} else {
+			result = tx.Model(&common.Token{}).Where("token = ? AND expire_at IS NULL", token.Token).Update("expire_at", expireAt)
+		}
+		if result.Error != nil {
+			return fmt.Errorf("unable to update token metadata : %s", result.Error)
+		}
+		if result.RowsAffected != 1 {
+			return common.ErrTokenAlreadyRotated
+		}
+
+		err = tx.Create(rotated).Error
+		if err != nil {
+			return fmt.Errorf("unable to create token metadata : %s", err)
+		}
+
+		err = tx.Model(&common.Upload{}).Where("token = ?", token.Token).Update("token", rotated.Token).Error
+		if err != nil {
+			return fmt.Errorf("unable to update uploads metadata : %s", err)
+		}
+
+		token.ExpireAt = expireAt
+
+		return nil
+	})
+}
+
+// DeleteExpiredTokens remove the rotated tokens once their grace period is over
+func (b *Backend) DeleteExpiredTokens() (removed int, err error) {
+	result := b.db.Where("expire_at IS NOT NULL AND expire_at < ?", time.Now()).Delete(&common.Token{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete expired tokens : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
+
 // DeleteToken remove a token from the DB
 func (b *Backend) DeleteToken(tokenStr string) (deleted bool, err error) {
 
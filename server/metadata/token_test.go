@@ -2,7 +2,9 @@ package metadata
 
 import (
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -63,6 +65,28 @@ func TestBackend_GetTokens(t *testing.T) {
 	require.NotNil(t, cursor, "invalid nil cursor")
 }
 
+func TestBackend_UpdateTokenLastUsed(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	token.Comment = "blah"
+	createUser(t, b, user)
+
+	lastUsedAt := time.Now().Add(-time.Hour)
+	err := b.UpdateTokenLastUsed(token.Token, lastUsedAt, "1.2.3.4")
+	require.NoError(t, err, "update token last used error")
+
+	result, err := b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "missing token")
+	require.NotNil(t, result.LastUsedAt, "missing token last used date")
+	require.Equal(t, lastUsedAt.Unix(), result.LastUsedAt.Unix(), "invalid token last used date")
+	require.Equal(t, "1.2.3.4", result.LastUsedIP, "invalid token last used ip")
+	require.Equal(t, token.Comment, result.Comment, "invalid token comment")
+}
+
 func TestBackend_DeleteToken(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -88,6 +112,152 @@ func TestBackend_DeleteToken(t *testing.T) {
 	require.Nil(t, tokenResult, "non nil token")
 }
 
+func TestBackend_RotateToken(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	token.Comment = "blah"
+	createUser(t, b, user)
+
+	// Rotate with a grace period
+	rotated := token.Rotate()
+	expireAt := time.Now().Add(time.Hour)
+	err := b.RotateToken(token, rotated, &expireAt)
+	require.NoError(t, err, "rotate token error")
+
+	result, err := b.GetToken(rotated.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "missing rotated token")
+	require.Equal(t, user.ID, result.UserID, "invalid rotated token user id")
+	require.Equal(t, "blah", result.Comment, "invalid rotated token comment")
+	require.Nil(t, result.ExpireAt, "rotated token should not expire")
+
+	result, err = b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "old token should be valid during the grace period")
+	require.NotNil(t, result.ExpireAt, "missing old token expiration date")
+	require.Equal(t, expireAt.Unix(), result.ExpireAt.Unix(), "invalid old token expiration date")
+
+	// Rotate without grace period
+	token = rotated
+	rotated = token.Rotate()
+	err = b.RotateToken(token, rotated, nil)
+	require.NoError(t, err, "rotate token error")
+
+	result, err = b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.Nil(t, result, "old token should have been deleted")
+
+	result, err = b.GetToken(rotated.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "missing rotated token")
+}
+
+func TestBackend_RotateTokenUploads(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	createUser(t, b, user)
+
+	upload := &common.Upload{User: user.ID, Token: token.Token}
+	createUpload(t, b, upload)
+
+	other := &common.Upload{User: user.ID}
+	createUpload(t, b, other)
+
+	rotated := token.Rotate()
+	expireAt := time.Now().Add(time.Hour)
+	err := b.RotateToken(token, rotated, &expireAt)
+	require.NoError(t, err, "rotate token error")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, rotated.Token, result.Token, "upload should belong to the new token")
+
+	result, err = b.GetUpload(other.ID)
+	require.NoError(t, err, "get upload error")
+	require.Empty(t, result.Token, "invalid upload token")
+
+	// A token can only be rotated once
+	err = b.RotateToken(token, token.Rotate(), nil)
+	require.Equal(t, common.ErrTokenAlreadyRotated, err, "invalid rotate token error")
+}
+
+func TestBackend_RotateTokenConcurrent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	createUser(t, b, user)
+
+	count := 10
+	var wg sync.WaitGroup
+	errors := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copied := *token
+			errors <- b.RotateToken(&copied, copied.Rotate(), nil)
+		}()
+	}
+	wg.Wait()
+	close(errors)
+
+	rotations := 0
+	for err := range errors {
+		if err == nil {
+			rotations++
+		} else {
+			require.Equal(t, common.ErrTokenAlreadyRotated, err, "invalid rotate token error")
+		}
+	}
+	require.Equal(t, 1, rotations, "a token should only be rotated once")
+
+	count, err := b.CountUserTokens(user.ID)
+	require.NoError(t, err, "count user tokens error")
+	require.Equal(t, 1, count, "invalid user tokens count")
+}
+
+func TestBackend_DeleteExpiredTokens(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	expiring := user.NewToken()
+	expired := user.NewToken()
+	createUser(t, b, user)
+
+	err := b.RotateToken(expiring, expiring.Rotate(), nil)
+	require.NoError(t, err, "rotate token error")
+
+	deadline := time.Now().Add(time.Hour)
+	err = b.RotateToken(token, token.Rotate(), &deadline)
+	require.NoError(t, err, "rotate token error")
+
+	deadline = time.Now().Add(-time.Hour)
+	err = b.RotateToken(expired, expired.Rotate(), &deadline)
+	require.NoError(t, err, "rotate token error")
+
+	removed, err := b.DeleteExpiredTokens()
+	require.NoError(t, err, "delete expired tokens error")
+	require.Equal(t, 1, removed, "invalid removed token count")
+
+	result, err := b.GetToken(expired.Token)
+	require.NoError(t, err, "get token error")
+	require.Nil(t, result, "expired token should have been deleted")
+
+	result, err = b.GetToken(token.Token)
+	require.NoError(t, err, "get token error")
+	require.NotNil(t, result, "token should not have been deleted")
+}
+
 func TestBackend_CountUserTokens(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
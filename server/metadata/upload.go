@@ -16,8 +16,135 @@ func (b *Backend) CreateUpload(upload *common.Upload) (err error) {
 }
 
 // UpdateUploadExpirationDate updates an upload expiration date in DB
+// The expiry warning will be sent again before the new expiration date
 func (b *Backend) UpdateUploadExpirationDate(upload *common.Upload) (err error) {
-	return b.db.Model(upload).Update("expire_at", upload.ExpireAt).Error
+	upload.ExpiryWarningSent = false
+	return b.db.Model(upload).Updates(map[string]interface{}{"expire_at": upload.ExpireAt, "expiry_warning_sent": false}).Error
+}
+
+// UpdateUploadLastAccess updates an upload last access and expiration dates in DB
+// The expiry warning will be sent again before the new expiration date
+func (b *Backend) UpdateUploadLastAccess(upload *common.Upload) (err error) {
+	upload.ExpiryWarningSent = false
+	return b.db.Model(upload).Updates(map[string]interface{}{"last_accessed_at": upload.LastAccessedAt, "expire_at": upload.ExpireAt, "expiry_warning_sent": false}).Error
+}
+
+// UpdateUploadCompletion updates an upload completion and expiration dates in DB
+// The expiry warning will be sent again before the new expiration date
+func (b *Backend) UpdateUploadCompletion(upload *common.Upload) (err error) {
+	upload.ExpiryWarningSent = false
+	return b.db.Model(upload).Updates(map[string]interface{}{"completed_at": upload.CompletedAt, "expire_at": upload.ExpireAt, "expiry_warning_sent": false}).Error
+}
+
+// GetUploadsExpiringBefore return the uploads expiring before deadline whose expiry warning has not been sent yet
+func (b *Backend) GetUploadsExpiringBefore(deadline time.Time) (uploads []*common.Upload, err error) {
+	err = b.db.Where("expire_at < ? AND expiry_warning_sent = ?", deadline, false).Order("expire_at").Find(&uploads).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch expiring uploads : %s", err)
+	}
+	return uploads, nil
+}
+
+// SetUploadExpiryWarningSent flag the upload expiry warning as sent
+// Return false if it was already flagged ( by another Plik instance for example )
+func (b *Backend) SetUploadExpiryWarningSent(uploadID string) (ok bool, err error) {
+	result := b.db.Model(&common.Upload{}).Where("id = ? AND expiry_warning_sent = ?", uploadID, false).Update("expiry_warning_sent", true)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update upload expiry warning : %s", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// AddUploadPendingDownload atomically count a download to notify, the notification window starts with the first one
+func (b *Backend) AddUploadPendingDownload(uploadID string) (err error) {
+	err = b.db.Model(&common.Upload{}).Where("id = ?", uploadID).Updates(map[string]interface{}{
+		"pending_downloads":       gorm.Expr("pending_downloads + ?", 1),
+		"pending_downloads_since": gorm.Expr("COALESCE(pending_downloads_since, ?)", time.Now()),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("unable to update upload pending downloads : %s", err)
+	}
+	return nil
+}
+
+// GetUploadsWithPendingDownloadsBefore return the uploads with downloads to notify since before deadline
+func (b *Backend) GetUploadsWithPendingDownloadsBefore(deadline time.Time) (uploads []*common.Upload, err error) {
+	err = b.db.Where("pending_downloads > ? AND pending_downloads_since <= ?", 0, deadline).Order("pending_downloads_since").Find(&uploads).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch uploads with pending downloads : %s", err)
+	}
+	return uploads, nil
+}
+
+// ClearUploadPendingDownloads remove the notified downloads from the upload pending downloads
+// Downloads counted since the upload was fetched start a new notification window
+// Return false if they were already cleared ( by another Plik instance for example )
+func (b *Backend) ClearUploadPendingDownloads(upload *common.Upload) (ok bool, err error) {
+	result := b.db.Model(&common.Upload{}).Where("id = ? AND pending_downloads = ?", upload.ID, upload.PendingDownloads).
+		Updates(map[string]interface{}{"pending_downloads": 0, "pending_downloads_since": nil})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update upload pending downloads : %s", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	result = b.db.Model(&common.Upload{}).Where("id = ? AND pending_downloads > ?", upload.ID, upload.PendingDownloads).
+		Updates(map[string]interface{}{"pending_downloads": gorm.Expr("pending_downloads - ?", upload.PendingDownloads), "pending_downloads_since": time.Now()})
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update upload pending downloads : %s", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetUploadReadyNotificationPending flag the upload to be checked for readiness by the upload ready notifications
+func (b *Backend) SetUploadReadyNotificationPending(uploadID string) (err error) {
+	err = b.db.Model(&common.Upload{}).Where("id = ?", uploadID).Update("ready_notification_pending", true).Error
+	if err != nil {
+		return fmt.Errorf("unable to update upload ready notification : %s", err)
+	}
+	return nil
+}
+
+// GetUploadsWithPendingReadyNotification return the uploads flagged for an upload ready notification with their files
+func (b *Backend) GetUploadsWithPendingReadyNotification() (uploads []*common.Upload, err error) {
+	err = b.db.Preload("Files").Where("ready_notification_pending = ?", true).Order("completed_at").Find(&uploads).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch uploads with pending ready notification : %s", err)
+	}
+	return uploads, nil
+}
+
+// ClearUploadReadyNotificationPending reset the upload ready notification flag
+// Return false if it was already reset ( by another Plik instance for example )
+func (b *Backend) ClearUploadReadyNotificationPending(uploadID string) (ok bool, err error) {
+	result := b.db.Model(&common.Upload{}).Where("id = ? AND ready_notification_pending = ?", uploadID, true).Update("ready_notification_pending", false)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update upload ready notification : %s", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// AddUploadDownloadedBytes atomically add bytes to the amount of data served for the upload and return the new total
+func (b *Backend) AddUploadDownloadedBytes(upload *common.Upload, bytes int64) (total int64, err error) {
+	total, err = b.incrementCounter(&common.Upload{}, upload.ID, "downloaded_bytes", bytes)
+	if err != nil {
+		return 0, fmt.Errorf("unable to update upload downloaded bytes : %s", err)
+	}
+
+	upload.DownloadedBytes = total
+	return total, nil
+}
+
+// IncrementUploadDownloadCount atomically increment the number of downloads of an upload and return the new count
+func (b *Backend) IncrementUploadDownloadCount(upload *common.Upload) (count int, err error) {
+	value, err := b.incrementCounter(&common.Upload{}, upload.ID, "download_count", 1)
+	if err != nil {
+		return 0, fmt.Errorf("unable to update upload download count : %s", err)
+	}
+
+	upload.DownloadCount = int(value)
+	return upload.DownloadCount, nil
 }
 
 // GetUpload return an upload from the DB ( return nil and no error if not found )
@@ -34,6 +161,45 @@ func (b *Backend) GetUpload(ID string) (upload *common.Upload, err error) {
 	return upload, err
 }
 
+// GetUploadByIdempotencyKey return the upload created by a user with the given idempotency key ( return nil and no error if not found )
+func (b *Backend) GetUploadByIdempotencyKey(userID string, key string) (upload *common.Upload, err error) {
+	upload = &common.Upload{}
+
+	err = b.db.Preload("Files").Take(upload, &common.Upload{User: userID, IdempotencyKey: &key}).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return upload, err
+}
+
+// ReleaseUploadIdempotencyKey release the idempotency key of an upload so it can be used again
+func (b *Backend) ReleaseUploadIdempotencyKey(uploadID string) (err error) {
+	err = b.db.Model(&common.Upload{ID: uploadID}).Update("idempotency_key", nil).Error
+	if err != nil {
+		return fmt.Errorf("unable to release upload idempotency key : %s", err)
+	}
+
+	return nil
+}
+
+// GetUploadByLinkID return the upload created with the given upload link ( return nil and no error if not found )
+// Removed uploads are returned too as upload links can only be used once
+func (b *Backend) GetUploadByLinkID(linkID string) (upload *common.Upload, err error) {
+	upload = &common.Upload{}
+
+	err = b.db.Unscoped().Take(upload, &common.Upload{UploadLinkID: &linkID}).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return upload, err
+}
+
 // GetUploads return uploads from DB
 // userID and tokenStr are filters
 // set withFiles to also fetch the files
@@ -70,6 +236,15 @@ func (b *Backend) GetUploads(userID string, tokenStr string, withFiles bool, pag
 	return uploads, &c, err
 }
 
+// GetUserUploadsOldestFirst return the uploads of a user with their files from the oldest to the newest
+func (b *Backend) GetUserUploadsOldestFirst(userID string) (uploads []*common.Upload, err error) {
+	err = b.db.Where(&common.Upload{User: userID}).Preload("Files").Order("created_at, id").Find(&uploads).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch user uploads : %s", err)
+	}
+	return uploads, nil
+}
+
 // RemoveUpload soft delete upload ( just set upload.DeletedAt field ) and remove all files
 // The upload metadata will still be present in the metadata database as well as all the files
 // Until all the files are deleted from the data backend and
@@ -80,6 +255,12 @@ func (b *Backend) RemoveUpload(uploadID string) (err error) {
 			return fmt.Errorf("unable to delete upload files : %s", err)
 		}
 
+		// Release the idempotency key so it can be used again
+		err = tx.Model(&common.Upload{ID: uploadID}).Update("idempotency_key", nil).Error
+		if err != nil {
+			return fmt.Errorf("unable to release upload idempotency key : %s", err)
+		}
+
 		err = tx.Delete(&common.Upload{ID: uploadID}).Error
 		if err != nil {
 			return fmt.Errorf("unable to (soft) delete upload : %s", err)
@@ -204,6 +385,37 @@ func (b *Backend) DeleteRemovedUploads() (removed int, err error) {
 	return removed, nil
 }
 
+// DeleteRemovedUpload delete the upload and file metadata of a removed upload from the database
+// All the upload files must have been deleted from the data backend first
+func (b *Backend) DeleteRemovedUpload(uploadID string) (err error) {
+	return b.db.Transaction(func(tx *gorm.DB) (err error) {
+		var count int64
+		err = tx.Model(&common.File{}).Not(&common.File{Status: common.FileDeleted}).Where(&common.File{UploadID: uploadID}).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("unable to count files for upload %s : %s", uploadID, err)
+		}
+
+		if count > 0 {
+			return fmt.Errorf("unable to delete upload %s because %d files are still not deleted", uploadID, count)
+		}
+
+		err = tx.Where(&common.File{UploadID: uploadID}).Delete(&common.File{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete files for upload %s : %s", uploadID, err)
+		}
+
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&common.Upload{ID: uploadID})
+		if result.Error != nil {
+			return fmt.Errorf("unable to delete upload %s : %s", uploadID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("upload %s is not removed", uploadID)
+		}
+
+		return nil
+	})
+}
+
 // ForEachUpload execute f for every upload in the database
 func (b *Backend) forEachUpload(f func(upload *common.Upload) error, unscoped bool) (err error) {
 	stmt := b.db.Model(&common.Upload{})
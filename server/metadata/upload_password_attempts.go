@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// GetUploadPasswordAttempts return the failed password attempts on an upload ( return nil and no error if none )
+func (b *Backend) GetUploadPasswordAttempts(uploadID string, ip string) (attempts *common.UploadPasswordAttempts, err error) {
+	attempts = &common.UploadPasswordAttempts{}
+	err = b.db.Where("upload_id = ? AND ip = ?", uploadID, ip).Take(attempts).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// ReserveUploadPasswordAttempt atomically count a password attempt on an upload before the password is checked
+// and lock it until now + lockout once maxFailures is reached. The caller must refuse the attempt if more than
+// maxFailures attempts are counted and reset the attempts if the password is valid.
+// Attempts older than lockout and attempts that led to an expired lock are forgotten.
+// The increment is computed by the database so concurrent attempts are never lost
+func (b *Backend) ReserveUploadPasswordAttempt(uploadID string, ip string, maxFailures int, lockout time.Duration, now time.Time) (attempts *common.UploadPasswordAttempts, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		// Concurrent first attempts must not fail on the primary key
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&common.UploadPasswordAttempts{UploadID: uploadID, IP: ip, UpdatedAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&common.UploadPasswordAttempts{}).Where("upload_id = ? AND ip = ?", uploadID, ip).
+			Where("(locked_until IS NOT NULL AND locked_until <= ?) OR (locked_until IS NULL AND updated_at < ?)", now, now.Add(-lockout)).
+			Updates(map[string]interface{}{"failures": 0, "locked_until": nil}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&common.UploadPasswordAttempts{}).Where("upload_id = ? AND ip = ?", uploadID, ip).
+			Updates(map[string]interface{}{"failures": gorm.Expr("failures + 1"), "updated_at": now}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&common.UploadPasswordAttempts{}).Where("upload_id = ? AND ip = ?", uploadID, ip).
+			Where("locked_until IS NULL AND failures >= ?", maxFailures).
+			Update("locked_until", now.Add(lockout)).Error
+		if err != nil {
+			return err
+		}
+
+		attempts = &common.UploadPasswordAttempts{}
+		return tx.Where("upload_id = ? AND ip = ?", uploadID, ip).Take(attempts).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to update upload password attempts : %s", err)
+	}
+
+	return attempts, nil
+}
+
+// ResetUploadPasswordAttempts forget the failed password attempts on an upload
+func (b *Backend) ResetUploadPasswordAttempts(uploadID string, ip string) (err error) {
+	err = b.db.Where("upload_id = ? AND ip = ?", uploadID, ip).Delete(&common.UploadPasswordAttempts{}).Error
+	if err != nil {
+		return fmt.Errorf("unable to delete upload password attempts : %s", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredUploadPasswordAttempts remove the failed password attempts not updated since deadline
+func (b *Backend) DeleteExpiredUploadPasswordAttempts(deadline time.Time) (removed int, err error) {
+	result := b.db.Where("updated_at < ?", deadline).Delete(&common.UploadPasswordAttempts{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete expired upload password attempts : %s", result.Error)
+	}
+
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend_UploadPasswordAttempts(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	attempts, err := b.GetUploadPasswordAttempts("upload", "")
+	require.NoError(t, err, "get upload password attempts error")
+	require.Nil(t, attempts, "non nil upload password attempts")
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		attempts, err = b.ReserveUploadPasswordAttempt("upload", "", 3, time.Minute, now)
+		require.NoError(t, err, "reserve upload password attempt error")
+		require.Equal(t, i, attempts.Failures, "invalid failures")
+		require.Equal(t, i == 3, attempts.IsLocked(now), "invalid lock")
+	}
+
+	attempts, err = b.GetUploadPasswordAttempts("upload", "")
+	require.NoError(t, err, "get upload password attempts error")
+	require.NotNil(t, attempts, "missing upload password attempts")
+	require.Equal(t, 3, attempts.Failures, "invalid failures")
+	require.True(t, attempts.IsLocked(now), "upload should be locked")
+	require.False(t, attempts.IsLocked(now.Add(2*time.Minute)), "lock should be expired")
+
+	// Attempts are still counted while the upload is locked
+	attempts, err = b.ReserveUploadPasswordAttempt("upload", "", 3, time.Minute, now)
+	require.NoError(t, err, "reserve upload password attempt error")
+	require.Equal(t, 4, attempts.Failures, "invalid failures")
+	require.True(t, attempts.IsLocked(now), "upload should be locked")
+
+	// Attempts from other IP addresses are counted separately
+	attempts, err = b.GetUploadPasswordAttempts("upload", "1.1.1.1")
+	require.NoError(t, err, "get upload password attempts error")
+	require.Nil(t, attempts, "non nil upload password attempts")
+
+	// Failures are counted again once the lock has expired
+	attempts, err = b.ReserveUploadPasswordAttempt("upload", "", 3, time.Minute, now.Add(2*time.Minute))
+	require.NoError(t, err, "reserve upload password attempt error")
+	require.Equal(t, 1, attempts.Failures, "invalid failures")
+	require.Nil(t, attempts.LockedUntil, "upload should not be locked")
+
+	err = b.ResetUploadPasswordAttempts("upload", "")
+	require.NoError(t, err, "reset upload password attempts error")
+
+	attempts, err = b.GetUploadPasswordAttempts("upload", "")
+	require.NoError(t, err, "get upload password attempts error")
+	require.Nil(t, attempts, "non nil upload password attempts")
+}
+
+func TestBackend_DeleteExpiredUploadPasswordAttempts(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	now := time.Now()
+	_, err := b.ReserveUploadPasswordAttempt("old", "", 3, time.Minute, now.Add(-time.Hour))
+	require.NoError(t, err, "reserve upload password attempt error")
+	_, err = b.ReserveUploadPasswordAttempt("recent", "", 3, time.Minute, now)
+	require.NoError(t, err, "reserve upload password attempt error")
+
+	removed, err := b.DeleteExpiredUploadPasswordAttempts(now.Add(-time.Minute))
+	require.NoError(t, err, "delete expired upload password attempts error")
+	require.Equal(t, 1, removed, "invalid removed count")
+
+	attempts, err := b.GetUploadPasswordAttempts("recent", "")
+	require.NoError(t, err, "get upload password attempts error")
+	require.NotNil(t, attempts, "missing upload password attempts")
+}
+
+func TestBackend_ReserveUploadPasswordAttempt_Concurrent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	now := time.Now()
+	count := 20
+
+	var wg sync.WaitGroup
+	failures := make(chan int, count)
+	errors := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempts, err := b.ReserveUploadPasswordAttempt("upload", "", 3, time.Minute, now)
+			if err != nil {
+				errors <- err
+				return
+			}
+			failures <- attempts.Failures
+		}()
+	}
+	wg.Wait()
+	close(failures)
+	close(errors)
+
+	for err := range errors {
+		require.NoError(t, err, "reserve upload password attempt error")
+	}
+
+	// Each attempt must have reserved its own slot
+	seen := make(map[int]bool)
+	for f := range failures {
+		require.False(t, seen[f], "duplicate failures count %d", f)
+		seen[f] = true
+	}
+	require.Len(t, seen, count, "invalid attempts count")
+
+	attempts, err := b.GetUploadPasswordAttempts("upload", "")
+	require.NoError(t, err, "get upload password attempts error")
+	require.Equal(t, count, attempts.Failures, "invalid failures")
+	require.True(t, attempts.IsLocked(now), "upload should be locked")
+}
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -18,6 +19,40 @@ func createUpload(t *testing.T, b *Backend, upload *common.Upload) {
 	require.NoError(t, err, "create upload error : %s", err)
 }
 
+func TestBackend_DeleteRemovedUpload(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	createUpload(t, b, upload)
+
+	err := b.DeleteRemovedUpload(upload.ID)
+	common.RequireError(t, err, "because 1 files are still not deleted")
+
+	err = b.UpdateFileStatus(file, common.FileUploaded, common.FileDeleted)
+	require.NoError(t, err, "update file status error")
+
+	err = b.DeleteRemovedUpload(upload.ID)
+	common.RequireError(t, err, "is not removed")
+
+	err = b.RemoveUpload(upload.ID)
+	require.NoError(t, err, "remove upload error")
+
+	err = b.DeleteRemovedUpload(upload.ID)
+	require.NoError(t, err, "delete removed upload error")
+
+	files, err := b.GetFiles(upload.ID)
+	require.NoError(t, err, "get files error")
+	require.Len(t, files, 0, "files should have been deleted")
+
+	var count int64
+	err = b.db.Model(&common.Upload{}).Unscoped().Where("id = ?", upload.ID).Count(&count).Error
+	require.NoError(t, err, "count uploads error")
+	require.Equal(t, int64(0), count, "upload should have been deleted")
+}
+
 func TestBackend_CreateUpload(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -51,6 +86,138 @@ func TestBackend_GetUpload(t *testing.T) {
 	require.Equal(t, upload.UploadToken, result.UploadToken, "invalid upload token")
 }
 
+func TestBackend_GetUploadUserMetadata(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{UserMetadata: common.UserMetadata{"ticket": "PLIK-42"}}
+	createUpload(t, b, upload)
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, upload.UserMetadata, result.UserMetadata, "invalid upload user metadata")
+
+	upload = &common.Upload{}
+	createUpload(t, b, upload)
+
+	result, err = b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, result.UserMetadata, "invalid upload user metadata")
+}
+
+func TestBackend_AddUploadDownloadedBytes(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	createUpload(t, b, upload)
+
+	total, err := b.AddUploadDownloadedBytes(upload, 42)
+	require.NoError(t, err, "add upload downloaded bytes error")
+	require.Equal(t, int64(42), total, "invalid upload downloaded bytes")
+	require.Equal(t, int64(42), upload.DownloadedBytes, "invalid upload downloaded bytes")
+
+	// Another request serving the same upload
+	other, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+
+	total, err = b.AddUploadDownloadedBytes(other, 8)
+	require.NoError(t, err, "add upload downloaded bytes error")
+	require.Equal(t, int64(50), total, "the bytes served by the other request must be counted")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, int64(50), result.DownloadedBytes, "invalid upload downloaded bytes")
+}
+
+func TestBackend_IncrementUploadDownloadCount(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	createUpload(t, b, upload)
+
+	count, err := b.IncrementUploadDownloadCount(upload)
+	require.NoError(t, err, "increment upload download count error")
+	require.Equal(t, 1, count, "invalid download count")
+
+	// Another request downloading the same upload
+	other, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+
+	count, err = b.IncrementUploadDownloadCount(other)
+	require.NoError(t, err, "increment upload download count error")
+	require.Equal(t, 2, count, "invalid download count")
+	require.Equal(t, 2, other.DownloadCount, "invalid download count")
+
+	_, err = b.IncrementUploadDownloadCount(&common.Upload{ID: "missing"})
+	common.RequireError(t, err, "unable to update upload download count : missing not found")
+}
+
+func TestBackend_IncrementUploadDownloadCount_Concurrent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	createUpload(t, b, upload)
+
+	// Each download works on its own copy of the metadata like concurrent requests do
+	downloads := 50
+	counts := make(chan int, downloads)
+	errs := make(chan error, 3*downloads)
+
+	var wg sync.WaitGroup
+	for i := 0; i < downloads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			u := &common.Upload{ID: upload.ID}
+			count, err := b.IncrementUploadDownloadCount(u)
+			if err != nil {
+				errs <- err
+				return
+			}
+			counts <- count
+
+			_, err = b.IncrementFileDownloadCount(&common.File{ID: file.ID})
+			if err != nil {
+				errs <- err
+			}
+
+			_, err = b.AddUploadDownloadedBytes(u, 10)
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(counts)
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err, "concurrent download counting error")
+	}
+
+	// Every download got its own count
+	seen := make(map[int]bool)
+	for count := range counts {
+		require.False(t, seen[count], "download count %d returned twice", count)
+		seen[count] = true
+	}
+	require.Len(t, seen, downloads, "invalid download counts")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.Equal(t, downloads, result.DownloadCount, "invalid upload download count")
+	require.Equal(t, int64(10*downloads), result.DownloadedBytes, "invalid upload downloaded bytes")
+
+	f, err := b.GetFile(file.ID)
+	require.NoError(t, err, "get file error")
+	require.Equal(t, downloads, f.DownloadCount, "invalid file download count")
+}
+
 func TestBackend_GetUpload_NotFound(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -60,6 +227,102 @@ func TestBackend_GetUpload_NotFound(t *testing.T) {
 	require.Nil(t, upload, "upload not nil")
 }
 
+func TestBackend_GetUploadByIdempotencyKey(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	key := "key"
+	upload := &common.Upload{User: "user", IdempotencyKey: &key}
+	_ = upload.NewFile()
+	createUpload(t, b, upload)
+
+	result, err := b.GetUploadByIdempotencyKey("user", key)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, result, "upload not found")
+	require.Equal(t, upload.ID, result.ID, "invalid upload id")
+	require.Len(t, result.Files, 1, "invalid upload files")
+
+	result, err = b.GetUploadByIdempotencyKey("other user", key)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, result, "upload not nil")
+
+	// Same key for another user
+	upload2 := &common.Upload{User: "other user", IdempotencyKey: &key}
+	createUpload(t, b, upload2)
+
+	// Same key for the same user
+	upload3 := &common.Upload{User: "user", IdempotencyKey: &key}
+	upload3.InitializeForTests()
+	err = b.CreateUpload(upload3)
+	require.Error(t, err, "duplicate idempotency key")
+
+	// Removing the upload releases the key
+	err = b.RemoveUpload(upload.ID)
+	require.NoError(t, err, "remove upload error")
+
+	result, err = b.GetUploadByIdempotencyKey("user", key)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, result, "upload not nil")
+
+	upload4 := &common.Upload{User: "user", IdempotencyKey: &key}
+	createUpload(t, b, upload4)
+}
+
+func TestBackend_ReleaseUploadIdempotencyKey(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	key := "key"
+	upload := &common.Upload{User: "user", IdempotencyKey: &key}
+	createUpload(t, b, upload)
+
+	err := b.ReleaseUploadIdempotencyKey(upload.ID)
+	require.NoError(t, err, "release upload idempotency key error")
+
+	result, err := b.GetUploadByIdempotencyKey("user", key)
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, result, "upload not nil")
+
+	result, err = b.GetUpload(upload.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, result, "upload should not be removed")
+
+	upload2 := &common.Upload{User: "user", IdempotencyKey: &key}
+	createUpload(t, b, upload2)
+}
+
+func TestBackend_GetUploadByLinkID(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	linkID := "link"
+	upload := &common.Upload{User: "user", UploadLinkID: &linkID}
+	createUpload(t, b, upload)
+
+	result, err := b.GetUploadByLinkID(linkID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, result, "upload not found")
+	require.Equal(t, upload.ID, result.ID, "invalid upload id")
+
+	result, err = b.GetUploadByLinkID("other link")
+	require.NoError(t, err, "get upload error")
+	require.Nil(t, result, "upload not nil")
+
+	// Upload links can only be used once
+	upload2 := &common.Upload{User: "user", UploadLinkID: &linkID}
+	upload2.InitializeForTests()
+	err = b.CreateUpload(upload2)
+	require.Error(t, err, "duplicate upload link")
+
+	// Even if the upload has been removed
+	err = b.RemoveUpload(upload.ID)
+	require.NoError(t, err, "remove upload error")
+
+	result, err = b.GetUploadByLinkID(linkID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, result, "upload not found")
+}
+
 func TestBackend_GetUploads_MissingPagingQuery(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -85,6 +348,36 @@ func TestBackend_DeleteUpload(t *testing.T) {
 	require.Nil(t, upload, "upload not nil")
 }
 
+func TestBackend_GetUserUploadsOldestFirst(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	for i := 1; i <= 3; i++ {
+		upload := &common.Upload{User: "user", Comments: fmt.Sprintf("%d", i)}
+		upload.CreatedAt = time.Now().Add(time.Duration(i) * time.Minute)
+		upload.NewFile()
+		createUpload(t, b, upload)
+	}
+
+	other := &common.Upload{User: "other"}
+	createUpload(t, b, other)
+
+	uploads, err := b.GetUserUploadsOldestFirst("user")
+	require.NoError(t, err, "get user uploads error")
+	require.Len(t, uploads, 3, "invalid upload count")
+	for i, upload := range uploads {
+		require.Equal(t, fmt.Sprintf("%d", i+1), upload.Comments, "invalid upload sequence")
+		require.Len(t, upload.Files, 1, "missing upload files")
+	}
+
+	err = b.RemoveUpload(uploads[0].ID)
+	require.NoError(t, err, "remove upload error")
+
+	uploads, err = b.GetUserUploadsOldestFirst("user")
+	require.NoError(t, err, "get user uploads error")
+	require.Len(t, uploads, 2, "removed uploads should not be returned")
+}
+
 func TestBackend_GetUploads(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -473,3 +766,162 @@ func TestBackend_UpdateUploadExpirationDate(t *testing.T) {
 	time.Sleep(time.Second)
 	require.True(t, upload.IsExpired())
 }
+
+func TestBackend_UpdateUploadLastAccess(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	upload.InactivityTTL = 60
+	createUpload(t, b, upload)
+
+	accessedAt := time.Now().Add(time.Hour)
+	require.True(t, upload.SetAccessed(accessedAt))
+	upload.ExpiryWarningSent = true
+	err := b.UpdateUploadLastAccess(upload)
+	require.NoError(t, err)
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err)
+	require.NotNil(t, result.LastAccessedAt)
+	require.Equal(t, 60, result.InactivityTTL)
+	require.Equal(t, accessedAt.Unix(), result.LastAccessedAt.Unix())
+	require.Equal(t, accessedAt.Add(time.Minute).Unix(), result.ExpireAt.Unix())
+	require.False(t, result.ExpiryWarningSent)
+}
+
+func TestBackend_UploadExpiryWarning(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	expiring := &common.Upload{}
+	expiring.TTL = 3600
+	createUpload(t, b, expiring)
+
+	later := &common.Upload{}
+	later.TTL = 86400 * 7
+	createUpload(t, b, later)
+
+	unlimited := &common.Upload{}
+	createUpload(t, b, unlimited)
+
+	uploads, err := b.GetUploadsExpiringBefore(time.Now().Add(24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, expiring.ID, uploads[0].ID)
+
+	ok, err := b.SetUploadExpiryWarningSent(expiring.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.SetUploadExpiryWarningSent(expiring.ID)
+	require.NoError(t, err)
+	require.False(t, ok, "expiry warning should be flagged only once")
+
+	uploads, err = b.GetUploadsExpiringBefore(time.Now().Add(24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, uploads, 0)
+
+	// Extending the expiration date re-arms the expiry warning
+	expiring, err = b.GetUpload(expiring.ID)
+	require.NoError(t, err)
+	require.True(t, expiring.ExpiryWarningSent)
+
+	expiring.ExtendExpirationDate()
+	err = b.UpdateUploadExpirationDate(expiring)
+	require.NoError(t, err)
+
+	uploads, err = b.GetUploadsExpiringBefore(time.Now().Add(24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+}
+
+func TestBackend_UploadPendingDownloads(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	createUpload(t, b, upload)
+
+	other := &common.Upload{}
+	createUpload(t, b, other)
+
+	for i := 0; i < 3; i++ {
+		err := b.AddUploadPendingDownload(upload.ID)
+		require.NoError(t, err)
+	}
+
+	uploads, err := b.GetUploadsWithPendingDownloadsBefore(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, uploads, 0, "notification window should not be over yet")
+
+	uploads, err = b.GetUploadsWithPendingDownloadsBefore(time.Now())
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, upload.ID, uploads[0].ID)
+	require.Equal(t, 3, uploads[0].PendingDownloads)
+	require.NotNil(t, uploads[0].PendingDownloadsSince)
+
+	// A download counted after the uploads were fetched starts a new notification window
+	err = b.AddUploadPendingDownload(upload.ID)
+	require.NoError(t, err)
+
+	ok, err := b.ClearUploadPendingDownloads(uploads[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.ClearUploadPendingDownloads(uploads[0])
+	require.NoError(t, err)
+	require.False(t, ok, "pending downloads should be cleared only once")
+
+	result, err := b.GetUpload(upload.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.PendingDownloads)
+	require.NotNil(t, result.PendingDownloadsSince)
+
+	ok, err = b.ClearUploadPendingDownloads(result)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	result, err = b.GetUpload(upload.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.PendingDownloads)
+	require.Nil(t, result.PendingDownloadsSince)
+}
+
+func TestBackend_UploadReadyNotification(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	upload := &common.Upload{}
+	upload.NewFile()
+	createUpload(t, b, upload)
+
+	other := &common.Upload{}
+	createUpload(t, b, other)
+
+	uploads, err := b.GetUploadsWithPendingReadyNotification()
+	require.NoError(t, err)
+	require.Len(t, uploads, 0)
+
+	err = b.SetUploadReadyNotificationPending(upload.ID)
+	require.NoError(t, err)
+
+	uploads, err = b.GetUploadsWithPendingReadyNotification()
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, upload.ID, uploads[0].ID)
+	require.Len(t, uploads[0].Files, 1, "files should be loaded")
+
+	ok, err := b.ClearUploadReadyNotificationPending(upload.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = b.ClearUploadReadyNotificationPending(upload.ID)
+	require.NoError(t, err)
+	require.False(t, ok, "ready notification should be cleared only once")
+
+	uploads, err = b.GetUploadsWithPendingReadyNotification()
+	require.NoError(t, err)
+	require.Len(t, uploads, 0)
+}
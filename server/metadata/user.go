@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pilagod/gorm-cursor-paginator/v2/paginator"
 	"gorm.io/gorm"
@@ -40,6 +41,63 @@ func (b *Backend) GetUser(ID string) (user *common.User, err error) {
 	return user, err
 }
 
+// UpdateUserLastLogin save when a user last logged in, this cancels any pending inactivity warning
+// Only the last login columns are updated to not overwrite concurrent changes
+func (b *Backend) UpdateUserLastLogin(userID string, lastLoginAt time.Time) (err error) {
+	result := b.db.Model(&common.User{}).Where(&common.User{ID: userID}).Updates(map[string]interface{}{
+		"last_login_at":              lastLoginAt,
+		"inactivity_warning_sent_at": nil,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("unable to update user metadata : %s", result.Error)
+	}
+
+	return nil
+}
+
+// UpdateUserTOSAcceptance save which version of the terms of service a user accepted and when
+// Only the terms of service columns are updated to not overwrite concurrent changes
+func (b *Backend) UpdateUserTOSAcceptance(userID string, version string, acceptedAt time.Time) (err error) {
+	result := b.db.Model(&common.User{}).Where(&common.User{ID: userID}).Updates(map[string]interface{}{
+		"tos_accepted_version": version,
+		"tos_accepted_at":      acceptedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("unable to update user metadata : %s", result.Error)
+	}
+
+	return nil
+}
+
+// GetUsersInactiveSince return the non admin users who did not log in since deadline
+// Users who never logged in are inactive since their creation
+func (b *Backend) GetUsersInactiveSince(deadline time.Time) (users []*common.User, err error) {
+	err = b.db.Where("is_admin = ? AND COALESCE(last_login_at, created_at) < ?", false, deadline).Order("id").Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch inactive users : %s", err)
+	}
+	return users, nil
+}
+
+// SetUserInactivityWarningSent flag the user inactivity warning as sent
+// Return false if it was already flagged ( by another Plik instance for example )
+func (b *Backend) SetUserInactivityWarningSent(userID string, sentAt time.Time) (ok bool, err error) {
+	result := b.db.Model(&common.User{}).Where("id = ? AND inactivity_warning_sent_at IS NULL", userID).Update("inactivity_warning_sent_at", sentAt)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to update user inactivity warning : %s", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UnsetUserInactivityWarningSent clear the user inactivity warning flag so the warning is sent again
+func (b *Backend) UnsetUserInactivityWarningSent(userID string) (err error) {
+	err = b.db.Model(&common.User{}).Where(&common.User{ID: userID}).Update("inactivity_warning_sent_at", nil).Error
+	if err != nil {
+		return fmt.Errorf("unable to update user inactivity warning : %s", err)
+	}
+	return nil
+}
+
 // GetUsers return all users
 // provider is an optional filter
 func (b *Backend) GetUsers(provider string, withTokens bool, pagingQuery *common.PagingQuery) (users []*common.User, cursor *paginator.Cursor, err error) {
@@ -122,6 +180,41 @@ func (b *Backend) RemoveUserUploads(userID string, tokenStr string) (removed int
 	return deleted, nil
 }
 
+// RemoveUserUploadsBatch deletes at most limit uploads of the user in a single transaction
+// It returns the IDs of the removed uploads, none once all the user uploads have been removed
+func (b *Backend) RemoveUserUploadsBatch(userID string, limit int) (uploadIDs []string, err error) {
+	err = b.db.Transaction(func(tx *gorm.DB) (err error) {
+		err = tx.Model(&common.Upload{}).Where(&common.Upload{User: userID}).Order("id").Limit(limit).Pluck("id", &uploadIDs).Error
+		if err != nil {
+			return fmt.Errorf("unable to fetch user uploads : %s", err)
+		}
+
+		for _, uploadID := range uploadIDs {
+			err = b.removeUploadFiles(tx, uploadID)
+			if err != nil {
+				return fmt.Errorf("unable to delete upload files : %s", err)
+			}
+
+			err = tx.Model(&common.Upload{ID: uploadID}).Update("idempotency_key", nil).Error
+			if err != nil {
+				return fmt.Errorf("unable to release upload idempotency key : %s", err)
+			}
+
+			err = tx.Delete(&common.Upload{ID: uploadID}).Error
+			if err != nil {
+				return fmt.Errorf("unable to (soft) delete upload : %s", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadIDs, nil
+}
+
 // DeleteUser delete a user from the DB
 func (b *Backend) DeleteUser(userID string) (deleted bool, err error) {
 	_, err = b.RemoveUserUploads(userID, "")
@@ -136,6 +229,12 @@ func (b *Backend) DeleteUser(userID string) (deleted bool, err error) {
 			return fmt.Errorf("unable to delete tokens metadata : %s", err)
 		}
 
+		// Delete user sessions
+		err = tx.Where(&common.Session{UserID: userID}).Delete(&common.Session{}).Error
+		if err != nil {
+			return fmt.Errorf("unable to delete sessions metadata : %s", err)
+		}
+
 		// Delete user
 		result := tx.Where(&common.User{ID: userID}).Delete(common.User{})
 		if result.Error != nil {
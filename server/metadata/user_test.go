@@ -3,6 +3,7 @@ package metadata
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -117,6 +118,92 @@ func TestBackend_GetUsers(t *testing.T) {
 	require.Error(t, err, "get user error expected")
 }
 
+func TestBackend_UpdateUserLastLogin(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	warningSentAt := time.Now()
+	user := &common.User{ID: "user", Name: "foo", InactivityWarningSentAt: &warningSentAt}
+	createUser(t, b, user)
+
+	lastLoginAt := time.Now()
+	err := b.UpdateUserLastLogin(user.ID, lastLoginAt)
+	require.NoError(t, err, "update user last login error")
+
+	result, err := b.GetUser(user.ID)
+	require.NoError(t, err, "get user error")
+	require.NotNil(t, result.LastLoginAt, "missing last login date")
+	require.Equal(t, lastLoginAt.Unix(), result.LastLoginAt.Unix(), "invalid last login date")
+	require.Nil(t, result.InactivityWarningSentAt, "the inactivity warning must be cancelled")
+	require.Equal(t, user.Name, result.Name, "invalid user name")
+}
+
+func TestBackend_UpdateUserTOSAcceptance(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := &common.User{ID: "user", Name: "foo"}
+	createUser(t, b, user)
+
+	acceptedAt := time.Now()
+	err := b.UpdateUserTOSAcceptance(user.ID, "v2", acceptedAt)
+	require.NoError(t, err, "update user terms of service acceptance error")
+
+	result, err := b.GetUser(user.ID)
+	require.NoError(t, err, "get user error")
+	require.Equal(t, "v2", result.TOSAcceptedVersion, "invalid accepted terms of service version")
+	require.NotNil(t, result.TOSAcceptedAt, "missing terms of service acceptance date")
+	require.Equal(t, acceptedAt.Unix(), result.TOSAcceptedAt.Unix(), "invalid terms of service acceptance date")
+	require.Equal(t, user.Name, result.Name, "invalid user name")
+}
+
+func TestBackend_GetUsersInactiveSince(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	createUser(t, b, &common.User{ID: "inactive", LastLoginAt: &old})
+	createUser(t, b, &common.User{ID: "active", LastLoginAt: &recent})
+	createUser(t, b, &common.User{ID: "admin", IsAdmin: true, LastLoginAt: &old})
+	createUser(t, b, &common.User{ID: "never", CreatedAt: old})
+	createUser(t, b, &common.User{ID: "new"})
+
+	users, err := b.GetUsersInactiveSince(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err, "get inactive users error")
+	require.Len(t, users, 2, "invalid inactive users")
+	require.Equal(t, "inactive", users[0].ID, "invalid inactive user")
+	require.Equal(t, "never", users[1].ID, "invalid inactive user")
+}
+
+func TestBackend_SetUserInactivityWarningSent(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := &common.User{ID: "user"}
+	createUser(t, b, user)
+
+	ok, err := b.SetUserInactivityWarningSent(user.ID, time.Now())
+	require.NoError(t, err, "set user inactivity warning error")
+	require.True(t, ok, "the inactivity warning must be flagged")
+
+	ok, err = b.SetUserInactivityWarningSent(user.ID, time.Now())
+	require.NoError(t, err, "set user inactivity warning error")
+	require.False(t, ok, "the inactivity warning must be flagged only once")
+
+	result, err := b.GetUser(user.ID)
+	require.NoError(t, err, "get user error")
+	require.NotNil(t, result.InactivityWarningSentAt, "missing inactivity warning date")
+
+	err = b.UnsetUserInactivityWarningSent(user.ID)
+	require.NoError(t, err, "unset user inactivity warning error")
+
+	ok, err = b.SetUserInactivityWarningSent(user.ID, time.Now())
+	require.NoError(t, err, "set user inactivity warning error")
+	require.True(t, ok, "the inactivity warning must be flagged again")
+}
+
 func TestBackend_DeleteUser(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
@@ -229,6 +316,54 @@ func TestBackend_DeleteUserUploads(t *testing.T) {
 	require.Equal(t, 2, deleted, "invalid upload count")
 }
 
+func TestBackend_RemoveUserUploadsBatch(t *testing.T) {
+	b := newTestMetadataBackend()
+	defer shutdownTestMetadataBackend(b)
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	createUser(t, b, user)
+
+	for i := 0; i < 5; i++ {
+		upload := &common.Upload{}
+		upload.User = user.ID
+		file := upload.NewFile()
+		file.Status = common.FileUploaded
+		createUpload(t, b, upload)
+	}
+
+	other := &common.Upload{}
+	other.User = "blah"
+	createUpload(t, b, other)
+
+	removed := 0
+	for {
+		uploadIDs, err := b.RemoveUserUploadsBatch(user.ID, 2)
+		require.NoError(t, err, "remove user uploads batch error")
+		require.True(t, len(uploadIDs) <= 2, "invalid batch size")
+		if len(uploadIDs) == 0 {
+			break
+		}
+
+		for _, uploadID := range uploadIDs {
+			upload, err := b.GetUpload(uploadID)
+			require.NoError(t, err, "get upload error")
+			require.Nil(t, upload, "upload should have been removed")
+
+			files, err := b.GetFiles(uploadID)
+			require.NoError(t, err, "get files error")
+			require.Len(t, files, 1, "invalid file count")
+			require.Equal(t, common.FileRemoved, files[0].Status, "invalid file status")
+		}
+
+		removed += len(uploadIDs)
+	}
+	require.Equal(t, 5, removed, "invalid removed upload count")
+
+	upload, err := b.GetUpload(other.ID)
+	require.NoError(t, err, "get upload error")
+	require.NotNil(t, upload, "other user upload should not have been removed")
+}
+
 func TestBackend_CountUsers(t *testing.T) {
 	b := newTestMetadataBackend()
 	defer shutdownTestMetadataBackend(b)
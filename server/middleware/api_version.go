@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// APIVersion negotiate the API version of the responses using the Accept header and save it to the request context
+func APIVersion(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Add("Vary", "Accept")
+
+		version, err := common.ParseAPIVersion(req.Header.Get("Accept"))
+		if err != nil {
+			ctx.Fail(err.Error(), nil, http.StatusNotAcceptable)
+			return
+		}
+
+		if version != 0 {
+			ctx.SetAPIVersion(version)
+			resp.Header().Set("Content-Type", common.APIVersionMediaType(version))
+		}
+
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestAPIVersion(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Accept", "application/vnd.plik.v1+json")
+
+	rr := ctx.NewRecorder(req)
+	APIVersion(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+	require.Equal(t, common.APIVersion1, ctx.GetAPIVersion(), "invalid API version")
+	require.Equal(t, "application/vnd.plik.v1+json", rr.Header().Get("Content-Type"), "invalid content type")
+	require.Equal(t, "Accept", rr.Header().Get("Vary"), "invalid vary header")
+}
+
+func TestAPIVersionDefault(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Accept", "application/json")
+
+	rr := ctx.NewRecorder(req)
+	APIVersion(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+	require.Equal(t, 0, ctx.GetAPIVersion(), "invalid API version")
+}
+
+func TestAPIVersionUnsupported(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("Accept", "application/vnd.plik.v42+json")
+
+	rr := ctx.NewRecorder(req)
+	APIVersion(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestFail(t, rr, http.StatusNotAcceptable, "unsupported API version application/vnd.plik.v42+json")
+}
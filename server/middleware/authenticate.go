@@ -1,46 +1,97 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
+// TokenLastUsedUpdateInterval throttle the token last used updates to not write to the DB on every request
+const TokenLastUsedUpdateInterval = time.Minute
+
+// SessionLastSeenUpdateInterval throttle the session last seen updates to not write to the DB on every request
+const SessionLastSeenUpdateInterval = time.Minute
+
+// UserLastLoginUpdateInterval throttle the user last login updates to not write to the DB on every request
+const UserLastLoginUpdateInterval = time.Hour
+
 // Authenticate verify that a request has either a whitelisted url or a valid auth token
 func Authenticate(allowToken bool) context.Middleware {
 	return func(ctx *context.Context, next http.Handler) http.Handler {
 		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 			config := ctx.GetConfig()
 
+			// Get client app from API key header
+			if allowToken {
+				apiKeyHeader := req.Header.Get(common.APIKeyHeader)
+				if apiKeyHeader != "" {
+					app := config.GetClientAppByKey(apiKeyHeader)
+					if app == nil {
+						ctx.Forbidden("invalid API key")
+						return
+					}
+					if !app.Allow() {
+						ctx.Fail(fmt.Sprintf("rate limit exceeded, client app %s is limited to %d requests per minute", app.Name, app.RateLimit), nil, http.StatusTooManyRequests)
+						return
+					}
+
+					// Save client app in the request context
+					ctx.SetClientApp(app)
+				}
+			}
+
 			if config.FeatureAuthentication != common.FeatureDisabled {
 				if allowToken {
-					// Get user from token header
-					tokenHeader := req.Header.Get("X-PlikToken")
-					if tokenHeader != "" {
-						token, err := ctx.GetMetadataBackend().GetToken(tokenHeader)
+					// Get upload link from header, the link only allows to create an upload on behalf of its owner
+					uploadLinkHeader := req.Header.Get(common.UploadLinkHeader)
+					if uploadLinkHeader != "" {
+						link, err := ctx.GetAuthenticator().ParseUploadLink(uploadLinkHeader)
+						if err != nil {
+							ctx.Forbidden("invalid upload link")
+							return
+						}
+						if link.IsExpired() {
+							ctx.Forbidden("upload link has expired")
+							return
+						}
+
+						upload, err := ctx.GetMetadataBackend().GetUploadByLinkID(link.ID)
 						if err != nil {
-							ctx.InternalServerError("unable to get token", err)
+							ctx.InternalServerError("unable to get upload", err)
 							return
 						}
-						if token == nil {
-							ctx.Forbidden("invalid token")
+						if upload != nil {
+							ctx.Forbidden("upload link has already been used")
 							return
 						}
 
-						user, err := ctx.GetMetadataBackend().GetUser(token.UserID)
+						owner, err := ctx.GetMetadataBackend().GetUser(link.User)
 						if err != nil {
 							ctx.InternalServerError("unable to get user", err)
 							return
 						}
-						if user == nil {
-							ctx.Forbidden("invalid token")
+						if owner == nil {
+							ctx.Forbidden("invalid upload link")
 							return
 						}
 
-						// Save user and token in the request context
-						ctx.SetUser(user)
-						ctx.SetToken(token)
+						// Save upload link in the request context
+						link.Owner = owner
+						ctx.SetUploadLink(link)
+
+						next.ServeHTTP(resp, req)
+						return
+					}
+
+					// Get user from token header
+					tokenHeader := req.Header.Get("X-PlikToken")
+					if tokenHeader != "" {
+						if !authenticateToken(ctx, tokenHeader) {
+							return
+						}
 
 						next.ServeHTTP(resp, req)
 						return
@@ -50,7 +101,7 @@ func Authenticate(allowToken bool) context.Middleware {
 				sessionCookie, err := req.Cookie(common.SessionCookieName)
 				if err == nil && sessionCookie != nil {
 					// Parse session cookie
-					uid, xsrf, err := ctx.GetAuthenticator().ParseSessionCookie(sessionCookie.Value)
+					uid, sid, xsrf, err := ctx.GetAuthenticator().ParseSessionCookie(sessionCookie.Value)
 					if err != nil {
 						common.Logout(resp, ctx.GetAuthenticator())
 						ctx.Forbidden("invalid session")
@@ -72,6 +123,18 @@ func Authenticate(allowToken bool) context.Middleware {
 						}
 					}
 
+					// Get session, the session cookie is invalid once the session has been revoked
+					session, err := ctx.GetMetadataBackend().GetSession(sid)
+					if err != nil {
+						ctx.InternalServerError("unable to get session", err)
+						return
+					}
+					if session == nil || session.UserID != uid {
+						common.Logout(resp, ctx.GetAuthenticator())
+						ctx.Forbidden("invalid session : session has been revoked")
+						return
+					}
+
 					// Get user from session
 					user, err := ctx.GetMetadataBackend().GetUser(uid)
 					if err != nil {
@@ -85,8 +148,12 @@ func Authenticate(allowToken bool) context.Middleware {
 						return
 					}
 
-					// Save user in the request context
+					// Save user and session in the request context
 					ctx.SetUser(user)
+					ctx.SetSession(session)
+
+					updateSessionLastSeen(ctx, session)
+					updateUserLastLogin(ctx, user)
 				}
 			}
 
@@ -94,3 +161,109 @@ func Authenticate(allowToken bool) context.Middleware {
 		})
 	}
 }
+
+// authenticateToken save the user of the token in the request context, it returns false if the request has failed
+func authenticateToken(ctx *context.Context, tokenStr string) bool {
+	token, err := ctx.GetMetadataBackend().GetToken(tokenStr)
+	if err != nil {
+		ctx.InternalServerError("unable to get token", err)
+		return false
+	}
+	if token == nil || token.IsExpired() {
+		ctx.Forbidden("invalid token")
+		return false
+	}
+
+	user, err := ctx.GetMetadataBackend().GetUser(token.UserID)
+	if err != nil {
+		ctx.InternalServerError("unable to get user", err)
+		return false
+	}
+	if user == nil {
+		ctx.Forbidden("invalid token")
+		return false
+	}
+
+	if !token.AllowedOrigins.Allow(getRequestOrigin(ctx.GetReq())) {
+		ctx.Forbidden("token is not allowed from this origin")
+		return false
+	}
+
+	// Save user and token in the request context
+	ctx.SetUser(user)
+	ctx.SetToken(token)
+
+	updateTokenLastUsed(ctx, token)
+	updateUserLastLogin(ctx, user)
+
+	return true
+}
+
+// getRequestOrigin return the Origin header of the request or the referrer if the browser did not send it
+func getRequestOrigin(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	if origin := req.Header.Get("Origin"); origin != "" && origin != "null" {
+		return origin
+	}
+	return req.Referer()
+}
+
+// updateTokenLastUsed save the token last used date and source IP in the background
+// This is best effort, failing to do so must not fail the request
+func updateTokenLastUsed(ctx *context.Context, token *common.Token) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < TokenLastUsedUpdateInterval {
+		return
+	}
+
+	sourceIP := ctx.GetConfig().FormatClientIP(ctx.GetSourceIP())
+
+	metadataBackend := ctx.GetMetadataBackend()
+	log := ctx.GetLogger()
+	go func() {
+		err := metadataBackend.UpdateTokenLastUsed(token.Token, now, sourceIP)
+		if err != nil {
+			log.Warningf("unable to update token last used date : %s", err)
+		}
+	}()
+}
+
+// updateSessionLastSeen save the session last seen date and source IP in the background
+// This is best effort, failing to do so must not fail the request
+func updateSessionLastSeen(ctx *context.Context, session *common.Session) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < SessionLastSeenUpdateInterval {
+		return
+	}
+
+	sourceIP := ctx.GetConfig().FormatClientIP(ctx.GetSourceIP())
+
+	metadataBackend := ctx.GetMetadataBackend()
+	log := ctx.GetLogger()
+	go func() {
+		err := metadataBackend.UpdateSessionLastSeen(session.ID, now, sourceIP)
+		if err != nil {
+			log.Warningf("unable to update session last seen date : %s", err)
+		}
+	}()
+}
+
+// updateUserLastLogin save the user last login date in the background, this cancels any pending inactivity warning
+// This is best effort, failing to do so must not fail the request
+func updateUserLastLogin(ctx *context.Context, user *common.User) {
+	now := time.Now()
+	if user.InactivityWarningSentAt == nil && user.LastLoginAt != nil && now.Sub(*user.LastLoginAt) < UserLastLoginUpdateInterval {
+		return
+	}
+
+	metadataBackend := ctx.GetMetadataBackend()
+	log := ctx.GetLogger()
+	go func() {
+		err := metadataBackend.UpdateUserLastLogin(user.ID, now)
+		if err != nil {
+			log.Warningf("unable to update user last login date : %s", err)
+		}
+	}()
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"bytes"
+	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -11,6 +13,18 @@ import (
 	"github.com/root-gg/plik/server/context"
 )
 
+// newTestSessionCookie save a new session of the user and return its session cookie
+func newTestSessionCookie(t *testing.T, ctx *context.Context, user *common.User) *http.Cookie {
+	session := common.NewSession(user, "", "")
+	err := ctx.GetMetadataBackend().CreateSession(session)
+	require.NoError(t, err, "unable to save session")
+
+	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user, session)
+	require.NoError(t, err, "unable to generate session cookie")
+
+	return sessionCookie
+}
+
 func getTestSessionAuthenticator() *common.SessionAuthenticator {
 	return &common.SessionAuthenticator{
 		SignatureKey:   "secret_key",
@@ -35,6 +49,197 @@ func TestAuthenticateTokenNoUser(t *testing.T) {
 	context.TestForbidden(t, rr, "invalid token")
 }
 
+func TestAuthenticateInvalidAPIKey(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().ClientApps = []*common.ClientApp{{Name: "backup", Key: "secret"}}
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.APIKeyHeader, "foo")
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "invalid API key")
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().ClientApps = []*common.ClientApp{{Name: "backup", Key: "secret"}}
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.APIKeyHeader, "secret")
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+	require.NotNil(t, ctx.GetClientApp(), "missing client app from context")
+	require.Equal(t, "backup", ctx.GetClientApp().Name, "invalid client app")
+	require.Nil(t, ctx.GetUser(), "client apps are not users")
+}
+
+func TestAuthenticateAPIKeyNotAllowed(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().ClientApps = []*common.ClientApp{{Name: "backup", Key: "secret"}}
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.APIKeyHeader, "secret")
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(false)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+	require.Nil(t, ctx.GetClientApp(), "API keys should only be resolved when tokens are allowed")
+}
+
+func TestAuthenticateAPIKeyRateLimit(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().ClientApps = []*common.ClientApp{{Name: "backup", Key: "secret", RateLimit: 1}}
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.APIKeyHeader, "secret")
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestFail(t, rr, http.StatusTooManyRequests, "rate limit exceeded, client app backup is limited to 1 requests per minute")
+}
+
+func newTestUploadLink(t *testing.T, ctx *context.Context, user *common.User, validity time.Duration) *common.UploadLink {
+	link := &common.UploadLink{ID: common.GenerateRandomID(16), User: user.ID, ExpireAt: time.Now().Add(validity)}
+
+	token, err := ctx.GetAuthenticator().SignUploadLink(link)
+	require.NoError(t, err, "unable to sign upload link")
+	link.Token = token
+
+	return link
+}
+
+func TestAuthenticateUploadLink(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	link := newTestUploadLink(t, ctx, user, time.Hour)
+
+	req, err := http.NewRequest("POST", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.UploadLinkHeader, link.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestOK(t, rr)
+	require.NotNil(t, ctx.GetUploadLink(), "missing upload link from context")
+	require.Equal(t, link.ID, ctx.GetUploadLink().ID, "invalid upload link")
+	require.Equal(t, user.ID, ctx.GetUploadLink().Owner.ID, "invalid upload link owner")
+	require.Nil(t, ctx.GetUser(), "upload links should not authenticate the user")
+}
+
+func TestAuthenticateUploadLinkInvalid(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
+
+	for _, value := range []string{"foo", sessionCookie.Value} {
+		req, err := http.NewRequest("POST", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+
+		req.Header.Set(common.UploadLinkHeader, value)
+
+		rr := ctx.NewRecorder(req)
+		Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+		context.TestForbidden(t, rr, "invalid upload link")
+	}
+}
+
+func TestAuthenticateUploadLinkExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	link := newTestUploadLink(t, ctx, user, -time.Hour)
+
+	req, err := http.NewRequest("POST", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.UploadLinkHeader, link.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "upload link has expired")
+}
+
+func TestAuthenticateUploadLinkAlreadyUsed(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	link := newTestUploadLink(t, ctx, user, time.Hour)
+
+	upload := &common.Upload{User: user.ID, UploadLinkID: &link.ID}
+	upload.InitializeForTests()
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	req, err := http.NewRequest("POST", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.UploadLinkHeader, link.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "upload link has already been used")
+}
+
+func TestAuthenticateUploadLinkUnknownUser(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	link := newTestUploadLink(t, ctx, common.NewUser(common.ProviderLocal, "user"), time.Hour)
+
+	req, err := http.NewRequest("POST", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set(common.UploadLinkHeader, link.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "invalid upload link")
+}
+
 func TestAuthenticateToken(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -61,6 +266,156 @@ func TestAuthenticateToken(t *testing.T) {
 	require.Equal(t, token.Token, tokenFromContext.Token, "invalid token from context")
 }
 
+func TestAuthenticateExpiredToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	deadline := time.Now().Add(-time.Minute)
+	token.ExpireAt = &deadline
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid token")
+}
+
+func TestAuthenticateTokenAllowedOrigins(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	token.AllowedOrigins = common.TokenOrigins{"https://app.example.com"}
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "token is not allowed from this origin")
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "token is not allowed from this origin")
+
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, token.Token, ctx.GetToken().Token, "invalid token from context")
+
+	req.Header.Del("Origin")
+	req.Header.Set("Referer", "https://app.example.com/upload")
+	rr = ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "referrer should be accepted without origin")
+}
+
+func TestAuthenticateTokenLastUsed(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	// Last used date is updated in the background
+	require.Eventually(t, func() bool {
+		token, err = ctx.GetMetadataBackend().GetToken(token.Token)
+		require.NoError(t, err, "unable to get token")
+		return token.LastUsedAt != nil
+	}, time.Second, 10*time.Millisecond, "missing token last used date")
+	require.Equal(t, "1.2.3.4", token.LastUsedIP, "invalid token last used ip")
+}
+
+func TestAuthenticateTokenLastUsedThrottled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	lastUsedAt := time.Now().Add(-TokenLastUsedUpdateInterval / 2)
+	token.LastUsedAt = &lastUsedAt
+	token.LastUsedIP = "4.3.2.1"
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	// Give a chance to an unexpected background update to happen
+	time.Sleep(50 * time.Millisecond)
+
+	token, err = ctx.GetMetadataBackend().GetToken(token.Token)
+	require.NoError(t, err, "unable to get token")
+	require.Equal(t, "4.3.2.1", token.LastUsedIP, "token last used should not have been updated")
+}
+
+func TestAuthenticateUserLastLogin(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	lastLoginAt := time.Now().Add(-UserLastLoginUpdateInterval / 2)
+	user.LastLoginAt = &lastLoginAt
+	warningSentAt := time.Now()
+	user.InactivityWarningSentAt = &warningSentAt
+	token := user.NewToken()
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	req.Header.Set("X-PlikToken", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(true)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+
+	// A pending inactivity warning is cancelled despite the throttling
+	require.Eventually(t, func() bool {
+		user, err = ctx.GetMetadataBackend().GetUser(user.ID)
+		require.NoError(t, err, "unable to get user")
+		return user.InactivityWarningSentAt == nil
+	}, time.Second, 10*time.Millisecond, "the inactivity warning should have been cancelled")
+	require.True(t, user.LastLoginAt.After(lastLoginAt), "invalid user last login date")
+}
+
 func TestAuthenticateInvalidSessionCookie(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -91,8 +446,7 @@ func TestAuthenticateMissingXSRFHeader(t *testing.T) {
 	require.NoError(t, err, "unable to create new request")
 
 	// Generate session cookie
-	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	require.NoError(t, err, "unable to create new request")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
 	req.AddCookie(sessionCookie)
 
 	rr := ctx.NewRecorder(req)
@@ -112,8 +466,7 @@ func TestAuthenticateInvalidXSRFHeader(t *testing.T) {
 	require.NoError(t, err, "unable to create new request")
 
 	// Generate session cookie
-	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	require.NoError(t, err, "unable to create new request")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
 	req.AddCookie(sessionCookie)
 
 	req.Header.Set("X-XSRFToken", "invalid_header_value")
@@ -124,6 +477,62 @@ func TestAuthenticateInvalidXSRFHeader(t *testing.T) {
 	context.TestForbidden(t, rr, "invalid xsrf header")
 }
 
+func TestAuthenticateRevokedSession(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Generate session cookie without saving the session
+	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user, common.NewSession(user, "", ""))
+	require.NoError(t, err, "unable to generate session cookie")
+	req.AddCookie(sessionCookie)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(false)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid session : session has been revoked")
+}
+
+func TestAuthenticateSessionLastSeen(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+	ctx.SetAuthenticator(getTestSessionAuthenticator())
+	ctx.SetSourceIP(net.ParseIP("1.2.3.4"))
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user")
+
+	session := common.NewSession(user, "4.3.2.1", "agent")
+	session.LastSeenAt = time.Now().Add(-2 * SessionLastSeenUpdateInterval)
+	err = ctx.GetMetadataBackend().CreateSession(session)
+	require.NoError(t, err, "unable to save session")
+
+	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user, session)
+	require.NoError(t, err, "unable to generate session cookie")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.AddCookie(sessionCookie)
+
+	rr := ctx.NewRecorder(req)
+	Authenticate(false)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, session.ID, ctx.GetSession().ID, "invalid session from context")
+
+	require.Eventually(t, func() bool {
+		session, err = ctx.GetMetadataBackend().GetSession(session.ID)
+		require.NoError(t, err, "unable to get session")
+		return session.IP == "1.2.3.4"
+	}, time.Second, 10*time.Millisecond, "session last seen should have been updated")
+}
+
 func TestAuthenticateNoUser(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -135,8 +544,7 @@ func TestAuthenticateNoUser(t *testing.T) {
 	require.NoError(t, err, "unable to create new request")
 
 	// Generate session cookie
-	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	require.NoError(t, err, "unable to create new request")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
 	req.AddCookie(sessionCookie)
 
 	rr := ctx.NewRecorder(req)
@@ -159,8 +567,7 @@ func TestAuthenticate(t *testing.T) {
 	require.NoError(t, err, "unable to create new request")
 
 	// Generate session cookie
-	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	require.NoError(t, err, "unable to create new request")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
 	req.AddCookie(sessionCookie)
 
 	rr := ctx.NewRecorder(req)
@@ -183,8 +590,7 @@ func TestAuthenticateAdminUser(t *testing.T) {
 	require.NoError(t, err, "unable to create new request")
 
 	// Generate session cookie
-	sessionCookie, _, err := ctx.GetAuthenticator().GenAuthCookies(user)
-	require.NoError(t, err, "unable to create new request")
+	sessionCookie := newTestSessionCookie(t, ctx, user)
 	req.AddCookie(sessionCookie)
 
 	rr := ctx.NewRecorder(req)
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// ConnectionLimit reject the requests of the client IP addresses exceeding MaxConnectionsPerIP concurrent requests
+// The slot is released when the request completes or the connection drops
+func ConnectionLimit(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		limiter := ctx.GetConnectionLimiter()
+		if limiter == nil {
+			next.ServeHTTP(resp, req)
+			return
+		}
+
+		sourceIP := ctx.GetSourceIP()
+		if sourceIP == nil {
+			ctx.InternalServerError("missing source IP address from context", nil)
+			return
+		}
+
+		ip := sourceIP.String()
+		if !limiter.Acquire(ip) {
+			ctx.Fail(fmt.Sprintf("too many concurrent connections, maximum is %d per IP address", ctx.GetConfig().MaxConnectionsPerIP), nil, http.StatusTooManyRequests)
+			return
+		}
+		defer limiter.Release(ip)
+
+		next.ServeHTTP(resp, req)
+	})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestConnectionLimitDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ConnectionLimit(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+}
+
+func TestConnectionLimit(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxConnectionsPerIP = 1
+	ctx := newTestingContext(config)
+	ctx.SetSourceIP(net.ParseIP("1.1.1.1"))
+
+	limiter := common.NewConnectionLimiter(config.MaxConnectionsPerIP)
+	ctx.SetConnectionLimiter(limiter)
+
+	var active int
+	handler := http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		active = limiter.GetActiveConnections("1.1.1.1")
+	})
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ConnectionLimit(ctx, handler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, 1, active, "the request should hold a connection slot")
+	require.Equal(t, 0, limiter.GetActiveConnections("1.1.1.1"), "the connection slot should be released")
+
+	// Another request of the same IP address is in progress
+	require.True(t, limiter.Acquire("1.1.1.1"))
+
+	rr = ctx.NewRecorder(req)
+	ConnectionLimit(ctx, handler).ServeHTTP(rr, req)
+	context.TestFail(t, rr, http.StatusTooManyRequests, "too many concurrent connections, maximum is 1 per IP address")
+	require.Equal(t, 1, limiter.GetActiveConnections("1.1.1.1"), "a rejected request should not release a slot")
+}
+
+func TestConnectionLimitMissingSourceIP(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxConnectionsPerIP = 1
+	ctx := newTestingContext(config)
+	ctx.SetConnectionLimiter(common.NewConnectionLimiter(config.MaxConnectionsPerIP))
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ConnectionLimit(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestInternalServerError(t, rr, "missing source IP address from context")
+}
@@ -3,7 +3,6 @@ package middleware
 import (
 	"net/http"
 
-	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -15,8 +14,25 @@ func CreateUpload(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		// Anonymous uploads may have to solve a CAPTCHA
+		if err := ctx.VerifyCaptcha(req); err != nil {
+			ctx.Forbidden("%s", err)
+			return
+		}
+
+		if err := ctx.CheckTOSAcceptance(req); err != nil {
+			ctx.TOSNotAccepted(err)
+			return
+		}
+
 		// Create upload with default params
-		upload, err := ctx.CreateUpload(&common.Upload{})
+		upload, err := ctx.CreateUpload(ctx.NewUploadParams())
+		if err != nil {
+			ctx.BadRequest("unable to create upload : %s", err)
+			return
+		}
+
+		err = ctx.CheckUserQuota(upload)
 		if err != nil {
 			ctx.BadRequest("unable to create upload : %s", err)
 			return
@@ -29,6 +45,17 @@ func CreateUpload(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
+		// The oldest uploads of the user are only evicted once the upload is saved
+		err = ctx.EvictUserUploads(upload)
+		if err != nil {
+			errRemove := ctx.GetMetadataBackend().RemoveUpload(upload.ID)
+			if errRemove != nil {
+				ctx.GetLogger().Warningf("unable to remove upload %s : %s", upload.ID, errRemove)
+			}
+			ctx.BadRequest("unable to create upload : %s", err)
+			return
+		}
+
 		// You are always admin of your own uploads
 		upload.IsAdmin = true
 
@@ -60,6 +60,33 @@ func TestCreateUploadNotWhitelisted(t *testing.T) {
 	context.TestForbidden(t, rr, "untrusted source IP address")
 }
 
+func TestCreateUploadCaptcha(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().CaptchaProvider = common.CaptchaHCaptcha
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "missing captcha response")
+	require.Nil(t, ctx.GetUpload(), "upload should not be created")
+}
+
+func TestCreateUploadTOSNotAccepted(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().RequireTOSAcceptance = true
+	ctx.GetConfig().TOSVersion = "v1"
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	CreateUpload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestFail(t, rr, http.StatusPreconditionRequired, "terms of service version v1 must be accepted before uploading")
+	require.Nil(t, ctx.GetUpload(), "upload should not be created")
+}
+
 func TestCreateUploadInvalidContext(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureForced
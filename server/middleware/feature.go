@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// Feature reject the requests with a 404 error if the feature has been turned off by the DisabledFeatures configuration
+func Feature(name string) context.Middleware {
+	return func(ctx *context.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if ctx.GetConfig().IsFeatureDisabled(name) {
+				ctx.NotFound("the %s feature is disabled", name)
+				return
+			}
+
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
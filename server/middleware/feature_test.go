@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestFeature(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("GET", "/archive", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Feature(common.DisableableArchive)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+}
+
+func TestFeatureDisabled(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DisabledFeatures = map[string]bool{common.DisableableArchive: true}
+	ctx := newTestingContext(config)
+
+	req, err := http.NewRequest("GET", "/archive", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	Feature(common.DisableableArchive)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "the archive feature is disabled")
+}
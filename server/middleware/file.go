@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -49,8 +50,14 @@ func File(ctx *context.Context, next http.Handler) http.Handler {
 			return
 		}
 
-		// Compare url filename with upload filename
-		if file.Name != fileName {
+		// Test if file is not expired
+		if file.IsExpired() {
+			ctx.Expired("file %s has expired", fileID)
+			return
+		}
+
+		// Compare url filename with upload filename, the stored file name is normalized
+		if file.Name != fileName && file.Name != common.NormalizeFileName(fileName) {
 			ctx.InvalidParameter("file name")
 			return
 		}
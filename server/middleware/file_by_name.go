@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// FileByName retrieve the requested file metadata by name instead of file ID and save it in the request context.
+// If several files of the upload share the name the FileNameCollisionPolicy decides which one is served.
+func FileByName(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		// Get upload from context
+		upload := ctx.GetUpload()
+		if upload == nil {
+			ctx.InternalServerError("missing upload from context", nil)
+			return
+		}
+
+		// Get the file name from the url params
+		fileName := mux.Vars(req)["filename"]
+		if fileName == "" {
+			ctx.MissingParameter("file name")
+			return
+		}
+
+		files, err := ctx.GetMetadataBackend().GetFiles(upload.ID)
+		if err != nil {
+			ctx.InternalServerError("unable to get upload files", err)
+			return
+		}
+
+		// File names are normalized when stored, files created before may still have their original name
+		normalizedName := common.NormalizeFileName(fileName)
+
+		var matches []*common.File
+		for _, file := range files {
+			// Removed files can't be downloaded anymore
+			if file.Status == common.FileRemoved || file.Status == common.FileDeleted {
+				continue
+			}
+			if file.MatchName(fileName) || file.MatchName(normalizedName) {
+				matches = append(matches, file)
+			}
+		}
+
+		if len(matches) == 0 {
+			ctx.NotFound("file %s not found", fileName)
+			return
+		}
+
+		if len(matches) > 1 && ctx.GetConfig().FileNameCollisionPolicy == common.FileNameCollisionConflict {
+			ctx.Fail(fmt.Sprintf("%d files are named %s, use the file ID to download them", len(matches), fileName), nil, http.StatusConflict)
+			return
+		}
+
+		// Serve the first uploaded file
+		sort.Slice(matches, func(i, j int) bool {
+			if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+				return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+			}
+			return matches[i].ID < matches[j].ID
+		})
+
+		// Save file in the request context
+		ctx.SetFile(matches[0])
+
+		next.ServeHTTP(resp, req)
+	})
+}
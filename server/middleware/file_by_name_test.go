@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func getFileByName(ctx *context.Context, fileName string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	if err != nil {
+		panic(err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"filename": fileName})
+
+	rr := ctx.NewRecorder(req)
+	FileByName(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func createFilesByName(t *testing.T, ctx *context.Context) (upload *common.Upload) {
+	upload = &common.Upload{}
+	upload.InitializeForTests()
+
+	now := time.Now()
+	for i, name := range []string{"file", "file", "removed", "main.go"} {
+		file := upload.NewFile()
+		file.Name = name
+		file.Status = common.FileUploaded
+		file.CreatedAt = now.Add(time.Duration(i) * time.Second)
+	}
+	upload.Files[2].Status = common.FileRemoved
+	upload.Files[3].RelativePath = "project/src"
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	ctx.SetUpload(upload)
+	return upload
+}
+
+func TestFileByNameNoUpload(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	rr := getFileByName(ctx, "file")
+	context.TestInternalServerError(t, rr, "missing upload from context")
+}
+
+func TestFileByNameNoFileName(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.SetUpload(&common.Upload{})
+
+	rr := getFileByName(ctx, "")
+	context.TestMissingParameter(t, rr, "file name")
+}
+
+func TestFileByName(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	upload := createFilesByName(t, ctx)
+
+	rr := getFileByName(ctx, "file")
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, upload.Files[0].ID, ctx.GetFile().ID, "the first uploaded file should be served")
+
+	rr = getFileByName(ctx, "project/src/main.go")
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, upload.Files[3].ID, ctx.GetFile().ID, "invalid file from context")
+}
+
+func TestFileByNameNotFound(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	createFilesByName(t, ctx)
+
+	rr := getFileByName(ctx, "missing")
+	context.TestNotFound(t, rr, "file missing not found")
+
+	rr = getFileByName(ctx, "removed")
+	context.TestNotFound(t, rr, "file removed not found")
+}
+
+func TestFileByNameConflict(t *testing.T) {
+	config := common.NewConfiguration()
+	config.FileNameCollisionPolicy = common.FileNameCollisionConflict
+	ctx := newTestingContext(config)
+	upload := createFilesByName(t, ctx)
+
+	rr := getFileByName(ctx, "file")
+	context.TestFail(t, rr, http.StatusConflict, "2 files are named file, use the file ID to download them")
+
+	rr = getFileByName(ctx, "main.go")
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, upload.Files[3].ID, ctx.GetFile().ID, "invalid file from context")
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
@@ -136,6 +138,36 @@ func TestFile(t *testing.T) {
 	require.Equal(t, file.ID, f.ID, "invalid file from context")
 }
 
+func TestFileExpired(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Name = "filename"
+	deadline := time.Now().Add(-time.Minute)
+	file.ExpireAt = &deadline
+	ctx.SetUpload(upload)
+
+	upload.InitializeForTests()
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "create upload error")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"fileID":   file.ID,
+		"filename": file.Name,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	File(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestNotFound(t, rr, fmt.Sprintf("file %s has expired", file.ID))
+}
+
 func TestFileMetadataBackendError(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
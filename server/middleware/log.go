@@ -26,6 +26,13 @@ func (resp *statusCodeResponseWriter) WriteHeader(code int) {
 	resp.ResponseWriter.WriteHeader(code)
 }
 
+// Flush implement the http.Flusher interface for streamed responses
+func (resp *statusCodeResponseWriter) Flush() {
+	if flusher, ok := resp.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // Log the http request
 func Log(ctx *context.Context, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
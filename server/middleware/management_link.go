@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+// ManagementLink verify the signed management link of the request, if any, and save it to the request context
+// The Upload middleware then grants the upload admin rights to the link for this action only
+func ManagementLink(action string) context.Middleware {
+	return func(ctx *context.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			query := req.URL.Query()
+			if query.Get("signature") == "" {
+				next.ServeHTTP(resp, req)
+				return
+			}
+
+			secret := ctx.GetConfig().ManagementLinkSecret
+			if secret == "" {
+				ctx.Forbidden("management links are disabled")
+				return
+			}
+
+			link, err := common.ParseManagementLink(secret, mux.Vars(req)["uploadID"], query)
+			if err != nil {
+				ctx.Forbidden("%s", err)
+				return
+			}
+			if link.Action != action {
+				ctx.Forbidden("invalid management link action")
+				return
+			}
+
+			ctx.SetManagementLink(link)
+
+			next.ServeHTTP(resp, req)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func getManagementLinkRequest(t *testing.T, uploadID string, link *common.ManagementLink, secret string) *http.Request {
+	req, err := http.NewRequest("DELETE", link.GetURL(secret), &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	return mux.SetURLVars(req, map[string]string{"uploadID": uploadID})
+}
+
+func TestManagementLink(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ManagementLinkSecret = "secret"
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	upload.ProtectedByPassword = true
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	link := common.NewManagementLink(config, upload.ID, common.ManagementActionRemove)
+	req := getManagementLinkRequest(t, upload.ID, link, config.ManagementLinkSecret)
+
+	rr := ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, Upload(ctx, common.DummyHandler)).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+	require.NotNil(t, ctx.GetManagementLink(), "missing management link from context")
+	require.True(t, ctx.GetUpload().IsAdmin, "the management link must grant the upload admin rights")
+}
+
+func TestManagementLinkNone(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("DELETE", "/upload/uploadID", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+	require.Nil(t, ctx.GetManagementLink(), "unexpected management link in context")
+}
+
+func TestManagementLinkDisabled(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	link := &common.ManagementLink{UploadID: "uploadID", Action: common.ManagementActionRemove, ExpireAt: time.Now().Add(time.Hour)}
+	req := getManagementLinkRequest(t, "uploadID", link, "secret")
+
+	rr := ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "management links are disabled")
+}
+
+func TestManagementLinkInvalid(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ManagementLinkSecret = "secret"
+	require.NoError(t, config.Initialize(), "unable to initialize config")
+	ctx := newTestingContext(config)
+
+	link := common.NewManagementLink(config, "uploadID", common.ManagementActionRemove)
+
+	// Forged signature
+	req := getManagementLinkRequest(t, "uploadID", link, "another secret")
+	rr := ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid management link")
+
+	// Link of another upload
+	req = getManagementLinkRequest(t, "anotherUploadID", link, config.ManagementLinkSecret)
+	rr = ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid management link")
+
+	// Link of another action
+	req = getManagementLinkRequest(t, "uploadID", link, config.ManagementLinkSecret)
+	rr = ctx.NewRecorder(req)
+	ManagementLink("extend")(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid management link action")
+
+	// Expired link
+	link.ExpireAt = time.Now().Add(-time.Minute)
+	req = getManagementLinkRequest(t, "uploadID", link, config.ManagementLinkSecret)
+	rr = ctx.NewRecorder(req)
+	ManagementLink(common.ManagementActionRemove)(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "management link has expired")
+}
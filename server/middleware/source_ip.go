@@ -37,8 +37,8 @@ func SourceIP(ctx *context.Context, next http.Handler) http.Handler {
 		// Save source IP address in the context
 		ctx.SetSourceIP(sourceIP)
 
-		// Update request logger prefix
-		prefix := fmt.Sprintf("%s[%s]", log.Prefix, sourceIP.String())
+		// Update request logger prefix, the full address is kept in the context for access checks
+		prefix := fmt.Sprintf("%s[%s]", log.Prefix, config.FormatClientIP(sourceIP))
 		log.SetPrefix(prefix)
 
 		next.ServeHTTP(resp, req)
@@ -70,3 +70,20 @@ func TestSourceIPFromHeader(t *testing.T) {
 	ip := ctx.GetSourceIP()
 	require.Equal(t, "1.1.1.1", ip.String(), "invalid source ip from context")
 }
+
+func TestSourceIPAnonymized(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().AnonymizeClientIP = true
+	prefix := ctx.GetLogger().Prefix
+
+	req, err := http.NewRequest("GET", "url", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.RemoteAddr = "1.1.1.1:1111"
+
+	rr := ctx.NewRecorder(req)
+	SourceIP(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, "1.1.1.1", ctx.GetSourceIP().String(), "the full source ip must be kept for access checks")
+	require.Equal(t, prefix+"[1.1.1.0]", ctx.GetLogger().Prefix, "the source ip must be anonymized in the logs")
+}
@@ -3,12 +3,10 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gorilla/mux"
 
-	"github.com/root-gg/utils"
-
+	"github.com/root-gg/plik/server/common"
 	"github.com/root-gg/plik/server/context"
 )
 
@@ -26,7 +24,7 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		}
 
 		// Get upload metadata
-		upload, err := ctx.GetMetadataBackend().GetUpload(uploadID)
+		upload, err := ctx.GetUploadByID(uploadID)
 		if err != nil {
 			ctx.InternalServerError("unable to get upload metadata", err)
 			return
@@ -42,7 +40,7 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 
 		// Test if upload is not expired
 		if upload.IsExpired() {
-			ctx.NotFound("upload %s has expired", uploadID)
+			ctx.Expired("upload %s has expired", uploadID)
 			return
 		}
 
@@ -54,6 +52,8 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		//  - Remove the upload
 		// There are several ways to be considered admin of an upload
 		//  - Providing the correct UploadToken (authenticated or not)
+		//  - Providing a valid signed management link for the requested action
+		//  - Providing the correct management password (authenticated or not)
 		//  - Being authenticated with an Admin user
 		//  - Being authenticated with a cookie with the user having created the upload
 		//  - Being authenticated with a token with the user and token having create the upload
@@ -62,6 +62,8 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 		uploadToken := req.Header.Get("X-UploadToken")
 		if uploadToken != "" && uploadToken == upload.UploadToken {
 			upload.IsAdmin = true
+		} else if link := ctx.GetManagementLink(); link != nil && link.UploadID == upload.ID {
+			upload.IsAdmin = true
 		} else {
 			token := ctx.GetToken()
 			if token != nil {
@@ -82,6 +84,21 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 			}
 		}
 
+		// The management password is checked last as it is expensive and counted as a failed password attempt
+		managementPassword := req.Header.Get("X-ManagementPassword")
+		if !upload.IsAdmin && managementPassword != "" {
+			err = ctx.CheckUploadManagementPassword(upload, managementPassword)
+			if httpError, ok := err.(common.HTTPError); ok {
+				ctx.Fail(httpError.Message, httpError.Err, httpError.StatusCode)
+				return
+			}
+			if err != nil {
+				ctx.Forbidden(err.Error())
+				return
+			}
+			upload.IsAdmin = true
+		}
+
 		forbidden := func(message string) {
 			resp.Header().Set("WWW-Authenticate", "Basic realm=\"plik\"")
 
@@ -94,31 +111,13 @@ func Upload(ctx *context.Context, next http.Handler) http.Handler {
 
 		// Handle basic auth if upload is password protected
 		if upload.ProtectedByPassword && !upload.IsAdmin {
-			if req.Header.Get("Authorization") == "" {
-				forbidden("missing Authorization header")
-				return
-			}
-
-			// Basic auth Authorization header must be set to
-			// "Basic base64("login:password")". Only the md5sum
-			// of the base64 string is saved in the upload metadata
-			auth := strings.Split(req.Header.Get("Authorization"), " ")
-			if len(auth) != 2 {
-				forbidden("invalid Authorization header")
+			err = ctx.CheckUploadBasicAuth(upload, req.Header.Get("Authorization"))
+			if httpError, ok := err.(common.HTTPError); ok {
+				ctx.Fail(httpError.Message, httpError.Err, httpError.StatusCode)
 				return
 			}
-			if auth[0] != "Basic" {
-				forbidden("invalid http authorization scheme")
-				return
-			}
-			var md5sum string
-			md5sum, err = utils.Md5sum(auth[1])
 			if err != nil {
-				forbidden("unable to hash credentials")
-				return
-			}
-			if md5sum != upload.Password {
-				forbidden("invalid credentials")
+				forbidden(err.Error())
 				return
 			}
 		}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/base64"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -71,6 +73,46 @@ func TestUpload(t *testing.T) {
 	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
 }
 
+func TestUploadCaseInsensitiveID(t *testing.T) {
+	config := common.NewConfiguration()
+	config.CaseInsensitiveUploadIDs = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	upload.ID = "abcdefgh"
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	// Created before the option was enabled
+	legacy := &common.Upload{}
+	legacy.InitializeForTests()
+	legacy.ID = "AbCdEfGh12"
+	err = ctx.GetMetadataBackend().CreateUpload(legacy)
+	require.NoError(t, err, "Unable to create upload")
+
+	getUpload := func(uploadID string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": uploadID})
+
+		rr := ctx.NewRecorder(req)
+		Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := getUpload("AbCdEfGh")
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
+
+	rr = getUpload(legacy.ID)
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.Equal(t, legacy.ID, ctx.GetUpload().ID, "invalid upload from context")
+
+	rr = getUpload("abcdefgh12")
+	context.TestNotFound(t, rr, "upload abcdefgh12 not found")
+}
+
 func TestUploadExpired(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 
@@ -97,6 +139,70 @@ func TestUploadExpired(t *testing.T) {
 	context.TestNotFound(t, rr, "upload "+upload.ID+" has expired")
 }
 
+func TestUploadExpiredRevealGoneReason(t *testing.T) {
+	config := common.NewConfiguration()
+	config.RevealGoneReason = true
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	deadline := time.Now().Add(-10 * time.Minute)
+	upload.ExpireAt = &deadline
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestFail(t, rr, http.StatusGone, "upload "+upload.ID+" has expired")
+}
+
+func TestUploadExpiredPageURL(t *testing.T) {
+	config := common.NewConfiguration()
+	config.ExpiredPageURL = "https://portal.root.gg/expired.html"
+	ctx := newTestingContext(config)
+	ctx.SetRedirectOnFailure(true)
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	deadline := time.Now().Add(-10 * time.Minute)
+	upload.ExpireAt = &deadline
+
+	err := ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusFound, rr.Code, "browsers must be redirected to the expired page")
+	require.Equal(t, config.ExpiredPageURL, rr.Header().Get("Location"), "invalid redirect location")
+
+	// API clients still get an error
+	req.Header.Set("Accept", "application/json")
+	rr = ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestNotFound(t, rr, "upload "+upload.ID+" has expired")
+
+	config.RevealGoneReason = true
+	rr = ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestFail(t, rr, http.StatusGone, "upload "+upload.ID+" has expired")
+}
+
 func TestUploadExtendTTL(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureExtendTTL = common.FeatureEnabled
@@ -190,6 +296,131 @@ func TestUploadToken(t *testing.T) {
 	require.True(t, ctx.GetUpload().IsAdmin, "invalid upload admin status")
 }
 
+func TestUploadManagementPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.ManagementPassword, err = common.HashUploadSecret("password")
+	require.NoError(t, err, "unable to hash management password")
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	req.Header.Set("X-ManagementPassword", "password")
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.NotNil(t, ctx.GetUpload(), "invalid upload from context")
+	require.True(t, ctx.GetUpload().IsAdmin, "invalid upload admin status")
+}
+
+func TestUploadInvalidManagementPassword(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ManagementPassword, err = common.HashUploadSecret("password")
+	require.NoError(t, err, "unable to hash management password")
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	req.Header.Set("X-ManagementPassword", "invalid")
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestForbidden(t, rr, "invalid management password")
+}
+
+func TestUploadInvalidManagementPasswordUploadToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	var err error
+
+	upload := &common.Upload{}
+	upload.ManagementPassword, err = common.HashUploadSecret("password")
+	require.NoError(t, err, "unable to hash management password")
+	upload.UploadToken = "token"
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	// The management password is not checked if the upload token is valid
+	req.Header.Set("X-UploadToken", upload.UploadToken)
+	req.Header.Set("X-ManagementPassword", "invalid")
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.True(t, ctx.GetUpload().IsAdmin, "invalid upload admin status")
+}
+
+func TestUploadManagementPasswordMaxAttempts(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadPasswordAttempts = 2
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize configuration")
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.ManagementPassword, err = common.HashUploadSecret("password")
+	require.NoError(t, err, "unable to hash management password")
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	check := func(password string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+		require.NoError(t, err, "unable to create new request")
+		req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+		req.Header.Set("X-ManagementPassword", password)
+
+		rr := ctx.NewRecorder(req)
+		Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	context.TestForbidden(t, check("invalid"), "invalid management password")
+	context.TestFail(t, check("invalid"), http.StatusTooManyRequests, "too many failed password attempts")
+
+	// The valid password can't be checked until the end of the lockout
+	context.TestFail(t, check("password"), http.StatusTooManyRequests, "too many failed password attempts")
+}
+
 func TestUploadUser(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -255,6 +486,42 @@ func TestUploadUserToken(t *testing.T) {
 	require.True(t, ctx.GetUpload().IsAdmin, "invalid upload admin status")
 }
 
+func TestUploadRotatedUserToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	upload.User = user.ID
+	upload.Token = token.Token
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	rotated := token.Rotate()
+	err = ctx.GetMetadataBackend().RotateToken(token, rotated, nil)
+	require.NoError(t, err, "unable to rotate token")
+
+	// Uploads created with the rotated token can be managed with the new token
+	ctx.SetUser(user)
+	ctx.SetToken(rotated)
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "invalid handler response status code")
+	require.True(t, ctx.GetUpload().IsAdmin, "invalid upload admin status")
+}
+
 func TestUploadUserAdmin(t *testing.T) {
 	ctx := newTestingContext(common.NewConfiguration())
 	ctx.GetConfig().FeatureAuthentication = common.FeatureEnabled
@@ -439,3 +706,88 @@ func TestUploadPassword(t *testing.T) {
 	require.Equal(t, upload.ID, ctx.GetUpload().ID, "invalid upload from context")
 	require.False(t, upload.IsAdmin, "invalid upload admin status")
 }
+
+func TestUploadPasswordMaxAttempts(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadPasswordAttempts = 1
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize configuration")
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	req, err := http.NewRequest("GET", "", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	// Fake gorilla/mux vars
+	vars := map[string]string{
+		"uploadID": upload.ID,
+	}
+	req = mux.SetURLVars(req, vars)
+
+	req.Header.Set("Authorization", "Basic invalid_creds")
+
+	rr := ctx.NewRecorder(req)
+	Upload(ctx, common.DummyHandler).ServeHTTP(rr, req)
+
+	context.TestFail(t, rr, http.StatusTooManyRequests, "too many failed password attempts")
+}
+
+func TestUploadPasswordMaxAttemptsConcurrent(t *testing.T) {
+	config := common.NewConfiguration()
+	config.MaxUploadPasswordAttempts = 3
+	err := config.Initialize()
+	require.NoError(t, err, "unable to initialize configuration")
+	ctx := newTestingContext(config)
+
+	upload := &common.Upload{}
+	upload.ProtectedByPassword = true
+	upload.Login = "login"
+	upload.Password = "password"
+	upload.InitializeForTests()
+
+	err = ctx.GetMetadataBackend().CreateUpload(upload)
+	require.NoError(t, err, "Unable to create upload")
+
+	count := 20
+	var wg sync.WaitGroup
+	codes := make(chan int, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each request has its own context
+			c := &context.Context{}
+			c.SetConfig(config)
+			c.SetLogger(config.NewLogger())
+			c.SetMetadataBackend(ctx.GetMetadataBackend())
+
+			req := httptest.NewRequest("GET", "/", &bytes.Buffer{})
+			req = mux.SetURLVars(req, map[string]string{"uploadID": upload.ID})
+			req.SetBasicAuth("login", "invalid")
+
+			rr := c.NewRecorder(req)
+			Upload(c, common.DummyHandler).ServeHTTP(rr, req)
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	// Only the attempts before the lock are checked, whatever the concurrency
+	unauthorized := 0
+	for code := range codes {
+		if code == http.StatusUnauthorized {
+			unauthorized++
+		} else {
+			require.Equal(t, http.StatusTooManyRequests, code, "invalid status code")
+		}
+	}
+	require.Equal(t, config.MaxUploadPasswordAttempts-1, unauthorized, "invalid number of checked attempts")
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/root-gg/plik/server/context"
+)
+
+// WebDAVAuthenticate authenticate WebDAV clients using basic auth with a user token as password
+// WebDAV clients can't send the X-PlikToken header, the login is ignored
+func WebDAVAuthenticate(ctx *context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, password, ok := req.BasicAuth()
+		if !ok || password == "" {
+			resp.Header().Set("WWW-Authenticate", "Basic realm=\"plik\"")
+			ctx.Unauthorized("please provide a user token as basic auth password")
+			return
+		}
+
+		if !authenticateToken(ctx, password) {
+			return
+		}
+
+		next.ServeHTTP(resp, req)
+	})
+}
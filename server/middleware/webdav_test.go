@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/context"
+)
+
+func TestWebDAVAuthenticate(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	token := user.NewToken()
+
+	err := ctx.GetMetadataBackend().CreateUser(user)
+	require.NoError(t, err, "unable to save user : %s", err)
+
+	req, err := http.NewRequest("PROPFIND", "/webdav/", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.SetBasicAuth("plik", token.Token)
+
+	rr := ctx.NewRecorder(req)
+	WebDAVAuthenticate(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestOK(t, rr)
+
+	require.Equal(t, user.ID, ctx.GetUser().ID, "missing user from context")
+	require.Equal(t, token.Token, ctx.GetToken().Token, "invalid token from context")
+}
+
+func TestWebDAVAuthenticateMissingCredentials(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("PROPFIND", "/webdav/", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+
+	rr := ctx.NewRecorder(req)
+	WebDAVAuthenticate(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestUnauthorized(t, rr, "please provide a user token as basic auth password")
+	require.Equal(t, "Basic realm=\"plik\"", rr.Header().Get("WWW-Authenticate"), "missing authenticate header")
+}
+
+func TestWebDAVAuthenticateInvalidToken(t *testing.T) {
+	ctx := newTestingContext(common.NewConfiguration())
+
+	req, err := http.NewRequest("PROPFIND", "/webdav/", &bytes.Buffer{})
+	require.NoError(t, err, "unable to create new request")
+	req.SetBasicAuth("plik", "token")
+
+	rr := ctx.NewRecorder(req)
+	WebDAVAuthenticate(ctx, common.DummyHandler).ServeHTTP(rr, req)
+	context.TestForbidden(t, rr, "invalid token")
+}
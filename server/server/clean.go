@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
 )
 
 /*
@@ -24,8 +25,11 @@ import (
       - Is triggered periodically by a running Plik server with IsAutoClean true
       - Can be triggered manually from the CLI
 
+      0 Send expiry warnings for uploads expiring within ExpiryWarningLeadTime
       1 Mark expired uploads and files as removed and ready to be cleaned
+        Files with their own TTL are removed on their own, their upload is removed once all its files have expired
       2 Deletes all the removed files from the data backend
+        Failed deletions are retried by the next runs after a backoff delay and alerted after DeleteFailureAlertThreshold failures
       3 Purge (real delete) removed upload and files from the metadata backend
 */
 
@@ -57,6 +61,15 @@ func (ps *PlikServer) uploadsCleaningRoutine() {
 func (ps *PlikServer) Clean() {
 	log := ps.config.NewLogger()
 
+	// 0 - warn about uploads expiring soon
+	sent, err := ps.SendExpiryWarnings()
+	if sent > 0 {
+		log.Infof("sent %d expiry warnings", sent)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
 	// 1 - soft delete expired uploads
 	removed, err := ps.metadataBackend.RemoveExpiredUploads()
 	if removed > 0 {
@@ -66,6 +79,14 @@ func (ps *PlikServer) Clean() {
 		log.Warning(err.Error())
 	}
 
+	removedFiles, err := ps.metadataBackend.RemoveExpiredFiles()
+	if removedFiles > 0 {
+		log.Infof("removed %d expired files", removedFiles)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
 	// 2 - delete removed files
 	deleted, err := ps.PurgeDeletedFiles()
 	if deleted > 0 {
@@ -85,7 +106,62 @@ func (ps *PlikServer) Clean() {
 		log.Warning(err.Error())
 	}
 
-	// 4 - clean metadata database
+	// 4 - delete rotated tokens once their grace period is over
+
+	expired, err := ps.metadataBackend.DeleteExpiredTokens()
+	if expired > 0 {
+		log.Infof("deleted %d expired tokens", expired)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 5 - delete timed out web sessions
+
+	expiredSessions, err := ps.metadataBackend.DeleteExpiredSessions(time.Now().Add(-time.Duration(ps.config.GetSessionTimeout()) * time.Second))
+	if expiredSessions > 0 {
+		log.Infof("deleted %d expired sessions", expiredSessions)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 6 - forget the failed upload password attempts once their lockout is over
+
+	if ps.config.MaxUploadPasswordAttempts > 0 {
+		expiredAttempts, err := ps.metadataBackend.DeleteExpiredUploadPasswordAttempts(time.Now().Add(-ps.config.GetUploadPasswordLockout()))
+		if expiredAttempts > 0 {
+			log.Infof("deleted %d expired upload password attempts", expiredAttempts)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+
+	// 7 - warn then delete the users who did not log in for InactiveUserRetention, their uploads are purged by the next run
+
+	warned, deletedUsers, err := ps.DeleteInactiveUsers()
+	if warned > 0 {
+		log.Infof("sent %d inactivity warnings", warned)
+	}
+	if deletedUsers > 0 {
+		log.Infof("deleted %d inactive users", deletedUsers)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 8 - move the old files to a cheaper storage class
+
+	transitioned, err := ps.TransitionStorageClasses()
+	if transitioned > 0 {
+		log.Infof("moved %d files to a cheaper storage class", transitioned)
+	}
+	if err != nil {
+		log.Warning(err.Error())
+	}
+
+	// 9 - clean metadata database
 
 	err = ps.metadataBackend.Clean()
 	if err != nil {
@@ -97,12 +173,19 @@ func (ps *PlikServer) Clean() {
 func (ps *PlikServer) PurgeDeletedFiles() (deleted int, err error) {
 	log := ps.config.NewLogger()
 
+	now := time.Now()
+
 	var errors []error
 	f := func(file *common.File) (err error) {
-		err = ps.dataBackend.RemoveFile(file)
+		// Wait for the backoff delay after a failed deletion
+		if file.NextDeleteAttemptAt != nil && now.Before(*file.NextDeleteAttemptAt) {
+			return nil
+		}
+
+		err = data.RemoveFile(ps.dataBackend, file)
 		if err != nil {
 			errors = append(errors, err)
-			log.Warningf("unable to delete file %s/%s : %s, will retry", file.UploadID, file.ID, err)
+			ps.onDeleteFailure(file, err)
 			return nil
 		}
 
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// DeleteFailureEvent is the event type of delete failure alerts
+const DeleteFailureEvent = "file.delete_failed"
+
+// maxDeleteRetryBackoff caps the delay between two attempts to delete a file
+const maxDeleteRetryBackoff = 24 * time.Hour
+
+// DeleteFailureAlert is posted as JSON to the DeleteFailureWebhook once a file failed to be deleted
+// DeleteFailureAlertThreshold times from the data backend
+type DeleteFailureAlert struct {
+	Event       string `json:"event"`
+	UploadID    string `json:"uploadId"`
+	FileID      string `json:"fileId"`
+	DataBackend string `json:"dataBackend,omitempty"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error"`
+}
+
+// getDeleteRetryBackoff return the delay before the next attempt to delete a file, doubled after each failure
+func (ps *PlikServer) getDeleteRetryBackoff(attempts int) time.Duration {
+	base := ps.config.GetDeleteRetryBackoff()
+
+	backoff := base
+	for i := 1; i < attempts && backoff < maxDeleteRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxDeleteRetryBackoff && base < maxDeleteRetryBackoff {
+		backoff = maxDeleteRetryBackoff
+	}
+
+	return backoff
+}
+
+// onDeleteFailure save the failed deletion of the file so it is retried after the backoff delay
+// and alert once it failed DeleteFailureAlertThreshold times
+func (ps *PlikServer) onDeleteFailure(file *common.File, deleteErr error) {
+	log := ps.config.NewLogger()
+
+	err := ps.metadataBackend.SetFileDeleteFailure(file, time.Now().Add(ps.getDeleteRetryBackoff(file.DeleteAttempts+1)))
+	if err != nil {
+		log.Warningf("unable to delete file %s/%s : %s, will retry", file.UploadID, file.ID, deleteErr)
+		log.Warning(err.Error())
+		return
+	}
+
+	threshold := ps.config.DeleteFailureAlertThreshold
+	if threshold <= 0 || file.DeleteAttempts != threshold {
+		log.Warningf("unable to delete file %s/%s : %s, will retry", file.UploadID, file.ID, deleteErr)
+		return
+	}
+
+	log.Criticalf("unable to delete file %s/%s after %d attempts : %s, will retry", file.UploadID, file.ID, file.DeleteAttempts, deleteErr)
+
+	if ps.config.DeleteFailureWebhook == "" {
+		return
+	}
+
+	alert := &DeleteFailureAlert{
+		Event:       DeleteFailureEvent,
+		UploadID:    file.UploadID,
+		FileID:      file.ID,
+		DataBackend: file.DataBackend,
+		Attempts:    file.DeleteAttempts,
+		Error:       deleteErr.Error(),
+	}
+
+	err = ps.config.PostWebhookEvent(ps.config.DeleteFailureWebhook, alert)
+	if err != nil {
+		log.Warningf("unable to send delete failure alert for file %s/%s : %s", file.UploadID, file.ID, err)
+	}
+}
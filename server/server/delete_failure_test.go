@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func createRemovedFile(t *testing.T, ps *PlikServer) *common.File {
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+	file.Status = common.FileRemoved
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+	return file
+}
+
+func TestPurgeDeletedFilesDeleteFailure(t *testing.T) {
+	var alerts []*DeleteFailureAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		alert := &DeleteFailureAlert{}
+		err := json.NewDecoder(req.Body).Decode(alert)
+		require.NoError(t, err, "unable to decode delete failure alert")
+		alerts = append(alerts, alert)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.DeleteRetryBackoff = "0"
+	ps.config.DeleteFailureAlertThreshold = 2
+	ps.config.DeleteFailureWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	file := createRemovedFile(t, ps)
+
+	backend := data_test.NewBackend()
+	ps.dataBackend = backend
+	backend.SetError(errors.New("data backend error"))
+
+	for i := 1; i <= 3; i++ {
+		deleted, err := ps.PurgeDeletedFiles()
+		common.RequireError(t, err, "unable to delete 1 files")
+		require.Equal(t, 0, deleted, "invalid deleted count")
+
+		f, err := ps.metadataBackend.GetFile(file.ID)
+		require.NoError(t, err, "unable to get file")
+		require.Equal(t, i, f.DeleteAttempts, "invalid delete attempts")
+	}
+
+	// The alert is sent only once
+	require.Len(t, alerts, 1, "invalid alert count")
+	require.Equal(t, DeleteFailureEvent, alerts[0].Event, "invalid event")
+	require.Equal(t, file.UploadID, alerts[0].UploadID, "invalid upload id")
+	require.Equal(t, file.ID, alerts[0].FileID, "invalid file id")
+	require.Equal(t, 2, alerts[0].Attempts, "invalid attempts")
+	require.Equal(t, "data backend error", alerts[0].Error, "invalid error")
+
+	backend.SetError(nil)
+
+	deleted, err := ps.PurgeDeletedFiles()
+	require.NoError(t, err, "unable to purge deleted files")
+	require.Equal(t, 1, deleted, "invalid deleted count")
+
+	f, err := ps.metadataBackend.GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "invalid file status")
+}
+
+func TestPurgeDeletedFilesRetryBackoff(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	file := createRemovedFile(t, ps)
+
+	backend := data_test.NewBackend()
+	ps.dataBackend = backend
+	backend.SetError(errors.New("data backend error"))
+
+	_, err := ps.PurgeDeletedFiles()
+	common.RequireError(t, err, "unable to delete 1 files")
+
+	backend.SetError(nil)
+
+	// The deletion is not retried before the backoff delay
+	deleted, err := ps.PurgeDeletedFiles()
+	require.NoError(t, err, "unable to purge deleted files")
+	require.Equal(t, 0, deleted, "invalid deleted count")
+
+	f, err := ps.metadataBackend.GetFile(file.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileRemoved, f.Status, "invalid file status")
+	require.Equal(t, 1, f.DeleteAttempts, "invalid delete attempts")
+}
+
+func TestGetDeleteRetryBackoff(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	require.Equal(t, time.Hour, ps.getDeleteRetryBackoff(1))
+	require.Equal(t, 2*time.Hour, ps.getDeleteRetryBackoff(2))
+	require.Equal(t, 16*time.Hour, ps.getDeleteRetryBackoff(5))
+	require.Equal(t, 24*time.Hour, ps.getDeleteRetryBackoff(6))
+	require.Equal(t, 24*time.Hour, ps.getDeleteRetryBackoff(100))
+
+	ps.config.DeleteRetryBackoff = "0"
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, time.Duration(0), ps.getDeleteRetryBackoff(10))
+
+	ps.config.DeleteRetryBackoff = "2d"
+	err = ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+	require.Equal(t, 48*time.Hour, ps.getDeleteRetryBackoff(10))
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// DownloadNotificationEvent is the event type of download notifications
+const DownloadNotificationEvent = "upload.downloaded"
+
+// Delay between two checks of the uploads whose download notification window is over
+const downloadNotificationInterval = time.Minute
+
+// DownloadNotification is posted as JSON to the DownloadNotificationWebhook once per DownloadNotificationWindow
+// with the number of downloads of the upload during the window
+type DownloadNotification struct {
+	Event     string     `json:"event"`
+	UploadID  string     `json:"uploadId"`
+	User      string     `json:"user,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Downloads int        `json:"downloads"`
+	Since     *time.Time `json:"since"`
+	Until     time.Time  `json:"until"`
+}
+
+// downloadNotificationsRoutine periodically notify the downloads of the uploads
+func (ps *PlikServer) downloadNotificationsRoutine() {
+	log := ps.config.NewLogger()
+	for {
+		ps.mu.Lock()
+		done := ps.done
+		ps.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		time.Sleep(downloadNotificationInterval)
+
+		sent, err := ps.SendDownloadNotifications()
+		if sent > 0 {
+			log.Infof("sent %d download notifications", sent)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+}
+
+// SendDownloadNotifications notify the DownloadNotificationWebhook and the upload webhook of the downloads of the uploads
+// whose first download not notified yet is older than DownloadNotificationWindow
+// The pending downloads are cleared before the webhook is called so each download is notified at most once
+func (ps *PlikServer) SendDownloadNotifications() (sent int, err error) {
+	if ps.config.DownloadNotificationWebhook == "" && !ps.config.IsUploadWebhookEnabled() {
+		return 0, nil
+	}
+
+	uploads, err := ps.metadataBackend.GetUploadsWithPendingDownloadsBefore(time.Now().Add(-ps.config.GetDownloadNotificationWindow()))
+	if err != nil {
+		return 0, err
+	}
+
+	log := ps.config.NewLogger()
+
+	var errors []error
+	for _, upload := range uploads {
+		ok, err := ps.metadataBackend.ClearUploadPendingDownloads(upload)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if !ok {
+			// Already sent by another Plik instance
+			continue
+		}
+
+		err = ps.sendDownloadNotification(upload)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to send download notification for upload %s : %s", upload.ID, err)
+			continue
+		}
+
+		sent++
+	}
+
+	if len(errors) > 0 {
+		return sent, fmt.Errorf("unable to send %d download notifications", len(errors))
+	}
+
+	return sent, nil
+}
+
+func (ps *PlikServer) sendDownloadNotification(upload *common.Upload) (err error) {
+	notification := &DownloadNotification{
+		Event:     DownloadNotificationEvent,
+		UploadID:  upload.ID,
+		User:      upload.User,
+		Downloads: upload.PendingDownloads,
+		Since:     upload.PendingDownloadsSince,
+		Until:     time.Now(),
+	}
+
+	if upload.User != "" {
+		user, err := ps.metadataBackend.GetUser(upload.User)
+		if err != nil {
+			return fmt.Errorf("unable to get upload user : %s", err)
+		}
+		if user != nil {
+			notification.Email = user.Email
+		}
+	}
+
+	return ps.postUploadWebhookEvent(ps.config.DownloadNotificationWebhook, upload, notification)
+}
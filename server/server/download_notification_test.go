@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestSendDownloadNotifications(t *testing.T) {
+	var notifications []*DownloadNotification
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		notification := &DownloadNotification{}
+		err := json.NewDecoder(req.Body).Decode(notification)
+		require.NoError(t, err, "unable to decode download notification")
+		notifications = append(notifications, notification)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.DownloadNotificationWebhook = webhook.URL
+	ps.config.DownloadNotificationWindow = "0"
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Email = "user@root.gg"
+	err = ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{User: user.ID}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	other := &common.Upload{}
+	other.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(other)
+	require.NoError(t, err, "unable to create upload")
+
+	for i := 0; i < 12; i++ {
+		err = ps.metadataBackend.AddUploadPendingDownload(upload.ID)
+		require.NoError(t, err, "unable to add pending download")
+	}
+
+	sent, err := ps.SendDownloadNotifications()
+	require.NoError(t, err, "unable to send download notifications")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, notifications, 1, "invalid notification count")
+	require.Equal(t, DownloadNotificationEvent, notifications[0].Event, "invalid event")
+	require.Equal(t, upload.ID, notifications[0].UploadID, "invalid upload id")
+	require.Equal(t, user.ID, notifications[0].User, "invalid user")
+	require.Equal(t, user.Email, notifications[0].Email, "invalid email")
+	require.Equal(t, 12, notifications[0].Downloads, "invalid download count")
+	require.NotNil(t, notifications[0].Since, "missing window start date")
+
+	// Downloads are notified only once
+	sent, err = ps.SendDownloadNotifications()
+	require.NoError(t, err, "unable to send download notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Len(t, notifications, 1, "invalid notification count")
+}
+
+func TestSendDownloadNotificationsWindow(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.DownloadNotificationWebhook = "http://127.0.0.1:1"
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	err = ps.metadataBackend.AddUploadPendingDownload(upload.ID)
+	require.NoError(t, err, "unable to add pending download")
+
+	// The downloads are aggregated until the end of the window
+	sent, err := ps.SendDownloadNotifications()
+	require.NoError(t, err, "unable to send download notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+}
+
+func TestSendDownloadNotificationsWebhookError(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.DownloadNotificationWebhook = webhook.URL
+	ps.config.DownloadNotificationWindow = "0"
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	err = ps.metadataBackend.AddUploadPendingDownload(upload.ID)
+	require.NoError(t, err, "unable to add pending download")
+
+	sent, err := ps.SendDownloadNotifications()
+	common.RequireError(t, err, "unable to send 1 download notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+}
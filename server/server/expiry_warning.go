@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// ExpiryWarningEvent is the event type of expiry warnings
+const ExpiryWarningEvent = "upload.expiring"
+
+// ExpiryWarning is posted as JSON to the ExpiryWarningWebhook before an upload expires
+type ExpiryWarning struct {
+	Event    string     `json:"event"`
+	UploadID string     `json:"uploadId"`
+	User     string     `json:"user,omitempty"`
+	Email    string     `json:"email,omitempty"`
+	ExpireAt *time.Time `json:"expireAt"`
+}
+
+// SendExpiryWarnings notify the ExpiryWarningWebhook and the upload webhook of uploads expiring within ExpiryWarningLeadTime
+// Each upload is flagged before the webhook is called so the warning is sent at most once
+func (ps *PlikServer) SendExpiryWarnings() (sent int, err error) {
+	leadTime := ps.config.GetExpiryWarningLeadTime()
+	if leadTime <= 0 {
+		return 0, nil
+	}
+
+	uploads, err := ps.metadataBackend.GetUploadsExpiringBefore(time.Now().Add(leadTime))
+	if err != nil {
+		return 0, err
+	}
+
+	log := ps.config.NewLogger()
+
+	var errors []error
+	for _, upload := range uploads {
+		if ps.config.ExpiryWarningWebhook == "" && upload.Webhook == "" {
+			// Nobody to warn
+			continue
+		}
+
+		ok, err := ps.metadataBackend.SetUploadExpiryWarningSent(upload.ID)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if !ok {
+			// Already sent by another Plik instance
+			continue
+		}
+
+		err = ps.sendExpiryWarning(upload)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to send expiry warning for upload %s : %s", upload.ID, err)
+			continue
+		}
+
+		sent++
+	}
+
+	if len(errors) > 0 {
+		return sent, fmt.Errorf("unable to send %d expiry warnings", len(errors))
+	}
+
+	return sent, nil
+}
+
+func (ps *PlikServer) sendExpiryWarning(upload *common.Upload) (err error) {
+	warning := &ExpiryWarning{
+		Event:    ExpiryWarningEvent,
+		UploadID: upload.ID,
+		User:     upload.User,
+		ExpireAt: upload.ExpireAt,
+	}
+
+	if upload.User != "" {
+		user, err := ps.metadataBackend.GetUser(upload.User)
+		if err != nil {
+			return fmt.Errorf("unable to get upload user : %s", err)
+		}
+		if user != nil {
+			warning.Email = user.Email
+		}
+	}
+
+	return ps.postUploadWebhookEvent(ps.config.ExpiryWarningWebhook, upload, warning)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestSendExpiryWarnings(t *testing.T) {
+	var warnings []*ExpiryWarning
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		warning := &ExpiryWarning{}
+		err := json.NewDecoder(req.Body).Decode(warning)
+		require.NoError(t, err, "unable to decode expiry warning")
+		warnings = append(warnings, warning)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.ExpiryWarningLeadTime = "24h"
+	ps.config.ExpiryWarningWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Email = "user@root.gg"
+	err = ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	expiring := &common.Upload{TTL: 3600, User: user.ID}
+	expiring.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(expiring)
+	require.NoError(t, err, "unable to create upload")
+
+	later := &common.Upload{TTL: 86400 * 7}
+	later.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(later)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, err := ps.SendExpiryWarnings()
+	require.NoError(t, err, "unable to send expiry warnings")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, warnings, 1, "invalid warning count")
+	require.Equal(t, ExpiryWarningEvent, warnings[0].Event, "invalid event")
+	require.Equal(t, expiring.ID, warnings[0].UploadID, "invalid upload id")
+	require.Equal(t, user.ID, warnings[0].User, "invalid user")
+	require.Equal(t, user.Email, warnings[0].Email, "invalid email")
+	require.NotNil(t, warnings[0].ExpireAt, "missing expiration date")
+
+	// Warnings are sent only once
+	sent, err = ps.SendExpiryWarnings()
+	require.NoError(t, err, "unable to send expiry warnings")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Len(t, warnings, 1, "invalid warning count")
+}
+
+func TestSendExpiryWarningsWebhookError(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.ExpiryWarningLeadTime = "24h"
+	ps.config.ExpiryWarningWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{TTL: 3600}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, err := ps.SendExpiryWarnings()
+	common.RequireError(t, err, "unable to send 1 expiry warnings")
+	require.Equal(t, 0, sent, "invalid sent count")
+}
+
+func TestSendExpiryWarningsDisabled(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	upload := &common.Upload{TTL: 3600}
+	upload.InitializeForTests()
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, err := ps.SendExpiryWarnings()
+	require.NoError(t, err, "unable to send expiry warnings")
+	require.Equal(t, 0, sent, "invalid sent count")
+}
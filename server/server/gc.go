@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+/*
+  Plik garbage collection design :
+    - Data and metadata may drift apart after a crash, a manual intervention or a failed cleaning
+      - Orphan blobs are files present in the data backend without (or with deleted) metadata
+        Thumbnail blobs are matched with the metadata of the file they were generated from
+      - Missing data are uploaded files whose data can't be found in the data backend
+
+    - Blobs and files more recent than the grace period are ignored to not interfere with in-flight uploads
+
+    - The garbage collection is only triggered manually from the CLI and is a dry run unless asked otherwise
+*/
+
+// GarbageCollectionReport summarize a garbage collection run
+type GarbageCollectionReport struct {
+	Blobs        int
+	Files        int
+	OrphanBlobs  []*common.File
+	MissingFiles []*common.File
+	Errors       []error
+}
+
+// GarbageCollect cross-reference the data backend with the metadata backend
+// Orphan blobs and files without data older than gracePeriod are reported and deleted if delete is true
+func (ps *PlikServer) GarbageCollect(gracePeriod time.Duration, delete bool) (report *GarbageCollectionReport, err error) {
+	log := ps.config.NewLogger()
+
+	lister, ok := ps.dataBackend.(data.Lister)
+	if !ok {
+		return nil, fmt.Errorf("data backend %s does not support listing files", ps.config.DataBackend)
+	}
+
+	report = &GarbageCollectionReport{}
+	deadline := time.Now().Add(-gracePeriod)
+
+	// File IDs are unique across uploads so they are enough to match blobs with metadata
+	blobs := make(map[string]*common.File)
+	err = lister.ForEachFile(func(blob *common.File) error {
+		blobs[blob.ID] = blob
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list data backend files : %s", err)
+	}
+	report.Blobs = len(blobs)
+
+	files := make(map[string]*common.File)
+	err = ps.metadataBackend.ForEachFile(func(file *common.File) error {
+		files[file.ID] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list metadata backend files : %s", err)
+	}
+	report.Files = len(files)
+
+	for id, blob := range blobs {
+		if blob.CreatedAt.After(deadline) {
+			continue
+		}
+
+		thumbnail := strings.HasSuffix(id, common.ThumbnailFileIDSuffix)
+		file, ok := files[strings.TrimSuffix(id, common.ThumbnailFileIDSuffix)]
+		if ok {
+			// Removed files will be deleted by the cleaning routine
+			if file.Status != common.FileDeleted {
+				continue
+			}
+			if thumbnail {
+				blob = file.ThumbnailFile()
+			} else {
+				blob = file
+			}
+		}
+
+		report.OrphanBlobs = append(report.OrphanBlobs, blob)
+		if !delete {
+			continue
+		}
+
+		err = ps.dataBackend.RemoveFile(blob)
+		if err != nil {
+			log.Warningf("unable to delete orphan blob %s/%s : %s", blob.UploadID, blob.ID, err)
+			report.Errors = append(report.Errors, err)
+		}
+	}
+
+	for id, file := range files {
+		if file.Status != common.FileUploaded || file.CreatedAt.After(deadline) {
+			continue
+		}
+
+		if _, ok := blobs[id]; ok {
+			continue
+		}
+
+		report.MissingFiles = append(report.MissingFiles, file)
+		if !delete {
+			continue
+		}
+
+		err = ps.metadataBackend.UpdateFileStatus(file, common.FileUploaded, common.FileDeleted)
+		if err != nil {
+			log.Warningf("unable to delete file %s/%s without data : %s", file.UploadID, file.ID, err)
+			report.Errors = append(report.Errors, err)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func createGarbageCollectionTestData(t *testing.T, ps *PlikServer) (orphan *common.File, missing *common.File, ok *common.File) {
+	upload := &common.Upload{}
+	ok = upload.NewFile()
+	ok.Status = common.FileUploaded
+	missing = upload.NewFile()
+	missing.Status = common.FileUploaded
+	upload.InitializeForTests()
+
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to save upload")
+
+	err = ps.dataBackend.AddFile(ok, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to save file")
+
+	orphan = common.NewFile()
+	orphan.UploadID = upload.ID
+	err = ps.dataBackend.AddFile(orphan, bytes.NewBufferString("data"))
+	require.NoError(t, err, "unable to save file")
+
+	return orphan, missing, ok
+}
+
+func TestGarbageCollectDryRun(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.dataBackend = data_test.NewBackend()
+
+	orphan, missing, ok := createGarbageCollectionTestData(t, ps)
+
+	report, err := ps.GarbageCollect(0, false)
+	require.NoError(t, err, "unexpected garbage collection error")
+	require.Equal(t, 2, report.Blobs, "invalid blob count")
+	require.Equal(t, 2, report.Files, "invalid file count")
+	require.Len(t, report.OrphanBlobs, 1, "invalid orphan blobs")
+	require.Equal(t, orphan.ID, report.OrphanBlobs[0].ID, "invalid orphan blob")
+	require.Len(t, report.MissingFiles, 1, "invalid files without data")
+	require.Equal(t, missing.ID, report.MissingFiles[0].ID, "invalid file without data")
+
+	err = getTestFile(t, ps, orphan, "data")
+	require.NoError(t, err, "orphan blob should not have been deleted")
+
+	f, err := ps.metadataBackend.GetFile(missing.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileUploaded, f.Status, "file without data should not have been deleted")
+
+	err = getTestFile(t, ps, ok, "data")
+	require.NoError(t, err, "file should not have been deleted")
+}
+
+func TestGarbageCollectDelete(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.dataBackend = data_test.NewBackend()
+
+	orphan, missing, ok := createGarbageCollectionTestData(t, ps)
+
+	report, err := ps.GarbageCollect(0, true)
+	require.NoError(t, err, "unexpected garbage collection error")
+	require.Len(t, report.OrphanBlobs, 1, "invalid orphan blobs")
+	require.Len(t, report.MissingFiles, 1, "invalid files without data")
+	require.Len(t, report.Errors, 0, "unexpected errors")
+
+	err = getTestFile(t, ps, orphan, "data")
+	require.Error(t, err, "orphan blob should have been deleted")
+
+	f, err := ps.metadataBackend.GetFile(missing.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, common.FileDeleted, f.Status, "file without data should have been deleted")
+
+	err = getTestFile(t, ps, ok, "data")
+	require.NoError(t, err, "file should not have been deleted")
+
+	report, err = ps.GarbageCollect(0, true)
+	require.NoError(t, err, "unexpected garbage collection error")
+	require.Len(t, report.OrphanBlobs, 0, "invalid orphan blobs")
+	require.Len(t, report.MissingFiles, 0, "invalid files without data")
+}
+
+func TestGarbageCollectGracePeriod(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.dataBackend = data_test.NewBackend()
+
+	_, _, _ = createGarbageCollectionTestData(t, ps)
+
+	report, err := ps.GarbageCollect(time.Hour, true)
+	require.NoError(t, err, "unexpected garbage collection error")
+	require.Len(t, report.MissingFiles, 0, "recent files should be ignored")
+}
+
+func TestGarbageCollectDataBackendError(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	dataBackend := data_test.NewBackend()
+	dataBackend.SetError(errors.New("data backend error"))
+	ps.dataBackend = dataBackend
+
+	_, err := ps.GarbageCollect(0, false)
+	common.RequireError(t, err, "unable to list data backend files")
+}
+
+func TestGarbageCollectThumbnails(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.dataBackend = data_test.NewBackend()
+
+	orphan, _, ok := createGarbageCollectionTestData(t, ps)
+
+	err := ps.dataBackend.AddFile(ok.ThumbnailFile(), bytes.NewBufferString("thumbnail"))
+	require.NoError(t, err, "unable to save thumbnail")
+
+	err = ps.dataBackend.AddFile(orphan.ThumbnailFile(), bytes.NewBufferString("thumbnail"))
+	require.NoError(t, err, "unable to save thumbnail")
+
+	report, err := ps.GarbageCollect(0, true)
+	require.NoError(t, err, "unexpected garbage collection error")
+	require.Len(t, report.OrphanBlobs, 2, "invalid orphan blobs")
+	require.Len(t, report.Errors, 0, "unexpected errors")
+
+	err = getTestFile(t, ps, ok.ThumbnailFile(), "thumbnail")
+	require.NoError(t, err, "thumbnail should not have been deleted")
+
+	err = getTestFile(t, ps, orphan.ThumbnailFile(), "thumbnail")
+	require.Error(t, err, "orphan thumbnail should have been deleted")
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// InactiveUserEvent is the event type of inactive user warnings
+const InactiveUserEvent = "user.inactive"
+
+// InactiveUserWarning is posted as JSON to the InactiveUserWebhook before an inactive user account is deleted
+type InactiveUserWarning struct {
+	Event       string    `json:"event"`
+	User        string    `json:"user"`
+	Login       string    `json:"login,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+	DeleteAt    time.Time `json:"deleteAt"`
+}
+
+// DeleteInactiveUsers warn the users who did not log in for almost InactiveUserRetention
+// then delete them and their uploads once InactiveUserRetention is over
+// Users are deleted at least InactiveUserWarningLeadTime after the warning even if it has been sent late
+func (ps *PlikServer) DeleteInactiveUsers() (warned int, deleted int, err error) {
+	retention := ps.config.GetInactiveUserRetention()
+	if retention <= 0 {
+		return 0, 0, nil
+	}
+	leadTime := ps.config.GetInactiveUserWarningLeadTime()
+
+	now := time.Now()
+	users, err := ps.metadataBackend.GetUsersInactiveSince(now.Add(leadTime - retention))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	log := ps.config.NewLogger()
+
+	var errors []error
+	for _, user := range users {
+		if user.InactivityWarningSentAt == nil {
+			ok, err := ps.metadataBackend.SetUserInactivityWarningSent(user.ID, now)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+			if !ok {
+				// Already sent by another Plik instance
+				continue
+			}
+
+			err = ps.sendInactiveUserWarning(user, now)
+			if err != nil {
+				errors = append(errors, err)
+				log.Warningf("unable to send inactivity warning for user %s : %s", user.ID, err)
+
+				// The user must not be deleted without having been warned
+				err = ps.metadataBackend.UnsetUserInactivityWarningSent(user.ID)
+				if err != nil {
+					log.Warningf("unable to reset inactivity warning for user %s : %s", user.ID, err)
+				}
+				continue
+			}
+
+			warned++
+			continue
+		}
+
+		// Check again as the user might have logged in since the list was fetched
+		current, err := ps.metadataBackend.GetUser(user.ID)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if current == nil || !isInactiveUserExpired(current, now, retention, leadTime) {
+			continue
+		}
+
+		ok, err := ps.metadataBackend.DeleteUser(current.ID)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to delete inactive user %s : %s", current.ID, err)
+			continue
+		}
+		if ok {
+			log.Infof("deleted inactive user %s, last login at %s", current.ID, current.GetLastActivity().Format(time.RFC3339))
+			deleted++
+		}
+	}
+
+	if len(errors) > 0 {
+		return warned, deleted, fmt.Errorf("unable to process %d inactive users", len(errors))
+	}
+
+	return warned, deleted, nil
+}
+
+// isInactiveUserExpired return true if the user has been inactive for longer than the retention and warned for long enough
+func isInactiveUserExpired(user *common.User, now time.Time, retention time.Duration, leadTime time.Duration) bool {
+	if user.IsAdmin || user.InactivityWarningSentAt == nil {
+		return false
+	}
+	return now.Sub(user.GetLastActivity()) >= retention && now.Sub(*user.InactivityWarningSentAt) >= leadTime
+}
+
+func (ps *PlikServer) sendInactiveUserWarning(user *common.User, now time.Time) (err error) {
+	warning := &InactiveUserWarning{
+		Event:       InactiveUserEvent,
+		User:        user.ID,
+		Login:       user.Login,
+		Email:       user.Email,
+		LastLoginAt: user.GetLastActivity(),
+		DeleteAt:    user.GetLastActivity().Add(ps.config.GetInactiveUserRetention()),
+	}
+
+	// Users are warned at least InactiveUserWarningLeadTime before being deleted
+	if earliest := now.Add(ps.config.GetInactiveUserWarningLeadTime()); warning.DeleteAt.Before(earliest) {
+		warning.DeleteAt = earliest
+	}
+
+	return ps.config.PostWebhookEvent(ps.config.InactiveUserWebhook, warning)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func createInactiveTestUser(t *testing.T, ps *PlikServer, login string, inactiveFor time.Duration, warnedFor time.Duration) *common.User {
+	user := common.NewUser(common.ProviderLocal, login)
+	user.Login = login
+	user.Email = login + "@root.gg"
+
+	lastLoginAt := time.Now().Add(-inactiveFor)
+	user.LastLoginAt = &lastLoginAt
+	if warnedFor > 0 {
+		warningSentAt := time.Now().Add(-warnedFor)
+		user.InactivityWarningSentAt = &warningSentAt
+	}
+
+	err := ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+	return user
+}
+
+func TestDeleteInactiveUsers(t *testing.T) {
+	var warnings []*InactiveUserWarning
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		warning := &InactiveUserWarning{}
+		err := json.NewDecoder(req.Body).Decode(warning)
+		require.NoError(t, err, "unable to decode inactivity warning")
+		warnings = append(warnings, warning)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.InactiveUserRetention = "30d"
+	ps.config.InactiveUserWarningLeadTime = "7d"
+	ps.config.InactiveUserWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	day := 24 * time.Hour
+	inactive := createInactiveTestUser(t, ps, "inactive", 29*day, 0)
+	createInactiveTestUser(t, ps, "active", day, 0)
+	expired := createInactiveTestUser(t, ps, "expired", 60*day, 8*day)
+	warned := createInactiveTestUser(t, ps, "warned", 60*day, day)
+
+	admin := createInactiveTestUser(t, ps, "admin", 60*day, 8*day)
+	admin.IsAdmin = true
+	err = ps.metadataBackend.UpdateUser(admin)
+	require.NoError(t, err, "unable to update user")
+
+	upload := &common.Upload{User: expired.ID}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, deleted, err := ps.DeleteInactiveUsers()
+	require.NoError(t, err, "unable to delete inactive users")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Equal(t, 1, deleted, "invalid deleted count")
+
+	require.Len(t, warnings, 1, "invalid warning count")
+	require.Equal(t, InactiveUserEvent, warnings[0].Event, "invalid event")
+	require.Equal(t, inactive.ID, warnings[0].User, "invalid user")
+	require.Equal(t, inactive.Email, warnings[0].Email, "invalid email")
+	require.Equal(t, inactive.LastLoginAt.Unix(), warnings[0].LastLoginAt.Unix(), "invalid last login date")
+	require.True(t, warnings[0].DeleteAt.After(time.Now().Add(7*day-time.Minute)), "users must be warned at least the lead time before deletion")
+
+	for _, user := range []*common.User{inactive, warned, admin} {
+		u, err := ps.metadataBackend.GetUser(user.ID)
+		require.NoError(t, err, "unable to get user")
+		require.NotNil(t, u, "user %s must not be deleted", user.ID)
+	}
+
+	u, err := ps.metadataBackend.GetUser(expired.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, u, "inactive user must be deleted")
+
+	up, err := ps.metadataBackend.GetUpload(upload.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.Nil(t, up, "inactive user uploads must be removed")
+
+	// Warnings are sent only once
+	sent, deleted, err = ps.DeleteInactiveUsers()
+	require.NoError(t, err, "unable to delete inactive users")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Equal(t, 0, deleted, "invalid deleted count")
+	require.Len(t, warnings, 1, "invalid warning count")
+}
+
+func TestDeleteInactiveUsersWebhookError(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.InactiveUserRetention = "30d"
+	ps.config.InactiveUserWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	user := createInactiveTestUser(t, ps, "user", 60*24*time.Hour, 0)
+
+	sent, deleted, err := ps.DeleteInactiveUsers()
+	common.RequireError(t, err, "unable to process 1 inactive users")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Equal(t, 0, deleted, "invalid deleted count")
+
+	// The warning will be sent again by the next run
+	u, err := ps.metadataBackend.GetUser(user.ID)
+	require.NoError(t, err, "unable to get user")
+	require.Nil(t, u.InactivityWarningSentAt, "the inactivity warning must not be flagged")
+}
+
+func TestDeleteInactiveUsersDisabled(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	createInactiveTestUser(t, ps, "user", 3650*24*time.Hour, 365*24*time.Hour)
+
+	sent, deleted, err := ps.DeleteInactiveUsers()
+	require.NoError(t, err, "unable to delete inactive users")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Equal(t, 0, deleted, "invalid deleted count")
+}
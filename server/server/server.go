@@ -37,7 +37,11 @@ type PlikServer struct {
 	dataBackend     data.Backend
 	streamBackend   data.Backend
 
-	authenticator *common.SessionAuthenticator
+	authenticator     *common.SessionAuthenticator
+	downloadLimiter   *common.BandwidthLimiter
+	connectionLimiter *common.ConnectionLimiter
+	uploadProgress    *common.UploadProgressBroker
+	transfers         *common.Transfers
 
 	httpServer *http.Server
 
@@ -102,6 +106,16 @@ func (ps *PlikServer) start() (err error) {
 		return fmt.Errorf("unable to initialize data backend : %s", err)
 	}
 
+	// Remove temporary files left behind by uploads interrupted by a previous crash
+	if sweeper, ok := ps.dataBackend.(data.Sweeper); ok {
+		removed, err := sweeper.SweepTempFiles()
+		if err != nil {
+			log.Warningf("unable to remove abandoned temporary files : %s", err)
+		} else if removed > 0 {
+			log.Infof("removed %d abandoned temporary files", removed)
+		}
+	}
+
 	err = ps.initializeStreamBackend()
 	if err != nil {
 		return fmt.Errorf("unable to initialize stream backend : %s", err)
@@ -112,10 +126,29 @@ func (ps *PlikServer) start() (err error) {
 		return fmt.Errorf("unable to initialize session authenticator : %s", err)
 	}
 
+	ps.uploadProgress = common.NewUploadProgressBroker()
+	ps.transfers = common.NewTransfers()
+
+	if ps.config.MaxDownloadBytesPerSecond > 0 {
+		ps.downloadLimiter = common.NewBandwidthLimiter(ps.config.MaxDownloadBytesPerSecond)
+	}
+
+	if ps.config.MaxConnectionsPerIP > 0 {
+		ps.connectionLimiter = common.NewConnectionLimiter(ps.config.MaxConnectionsPerIP)
+	}
+
 	if ps.config.IsAutoClean() {
 		go ps.uploadsCleaningRoutine()
 	}
 
+	if ps.config.DownloadNotificationWebhook != "" || ps.config.IsUploadWebhookEnabled() {
+		go ps.downloadNotificationsRoutine()
+	}
+
+	if ps.config.UploadReadyWebhook != "" {
+		go ps.uploadReadyNotificationsRoutine()
+	}
+
 	handler := ps.getHTTPHandler()
 
 	var proto string
@@ -128,10 +161,10 @@ func (ps *PlikServer) start() (err error) {
 			return fmt.Errorf("unable to start plik server without ssl certificates")
 		}
 
-		ps.httpServer = &http.Server{Addr: address, Handler: handler, TLSConfig: tlsConfig}
+		ps.httpServer = &http.Server{Addr: address, Handler: handler, TLSConfig: tlsConfig, ConnContext: common.WithConn}
 	} else {
 		proto = "http"
-		ps.httpServer = &http.Server{Addr: address, Handler: handler}
+		ps.httpServer = &http.Server{Addr: address, Handler: handler, ConnContext: common.WithConn}
 	}
 
 	log.Infof("Starting server at %s://%s", proto, address)
@@ -218,7 +251,7 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	emptyChain := context.NewChain(middleware.Context(ps.setupContext))
 
 	// The base middleware chain
-	stdChain := emptyChain.Append(middleware.SourceIP, middleware.Log, middleware.Recover)
+	stdChain := emptyChain.Append(middleware.SourceIP, middleware.Log, middleware.Recover, middleware.ConnectionLimit, middleware.APIVersion)
 
 	// A chain that authenticates user from session cookies
 	authChain := stdChain.Append(middleware.Authenticate(false), middleware.Impersonate)
@@ -238,19 +271,28 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 
 	// HTTP Api routes configuration
 	router := mux.NewRouter()
-	router.Handle("/", tokenChain.Append(middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
+	router.Handle("/", tokenChain.Append(middleware.Feature(common.DisableableQuickUpload), middleware.CreateUpload).Then(handlers.AddFile)).Methods("POST")
 	router.Handle("/config", stdChain.Then(handlers.GetConfiguration)).Methods("GET")
-	router.Handle("/version", stdChain.Then(handlers.GetVersion)).Methods("GET")
+	router.Handle("/version", stdChain.Append(middleware.Feature(common.DisableableVersion)).Then(handlers.GetVersion)).Methods("GET")
 	router.Handle("/upload", tokenChain.Then(handlers.CreateUpload)).Methods("POST")
+	router.Handle("/upload/files", tokenChain.Append(middleware.Feature(common.DisableableMultiFileUpload)).Then(handlers.CreateUploadWithFiles)).Methods("POST")
+	router.Handle("/upload/precheck", tokenChain.Append(middleware.Feature(common.DisableableUploadPrecheck)).Then(handlers.PrecheckUpload)).Methods("POST")
 	router.Handle("/upload/{uploadID}", authChain.Append(middleware.Upload).Then(handlers.GetUpload)).Methods("GET")
-	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.RemoveUpload)).Methods("DELETE")
+	router.Handle("/upload/{uploadID}", stdChain.Append(middleware.Feature(common.DisableableUploadHead)).Then(handlers.HeadUpload)).Methods("HEAD")
+	router.Handle("/upload/{uploadID}", tokenChain.Append(middleware.Feature(common.DisableableRemoveUpload), middleware.ManagementLink(common.ManagementActionRemove), middleware.Upload).Then(handlers.RemoveUpload)).Methods("DELETE")
+	router.Handle("/upload/{uploadID}/progress", authChain.Append(middleware.Feature(common.DisableableUploadProgress), middleware.Upload).Then(handlers.GetUploadProgress)).Methods("GET")
+	router.Handle("/upload/{uploadID}/files/{filename:.+}", authChainWithRedirect.Append(middleware.Upload, middleware.FileByName).Then(handlers.GetFile)).Methods("HEAD", "GET")
+	router.Handle("/upload/{uploadID}/{fileID}/thumbnail", authChainWithRedirect.Append(middleware.Feature(common.DisableableThumbnail), middleware.Upload).Then(handlers.GetThumbnail)).Methods("HEAD", "GET")
+	router.Handle("/upload/{uploadID}/files", tokenChain.Append(middleware.Feature(common.DisableableMultiFileUpload), middleware.Upload).Then(handlers.AddFiles)).Methods("POST")
+	router.Handle("/upload/{uploadID}/verify", authChain.Then(handlers.VerifyUploadPassword)).Methods("POST")
 	router.Handle("/file/{uploadID}", tokenChain.Append(middleware.Upload).Then(handlers.AddFile)).Methods("POST")
 	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
-	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.RemoveFile)).Methods("DELETE")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}", tokenChain.Append(middleware.Feature(common.DisableableRemoveFile)).AppendChain(getFileChain).Then(handlers.RemoveFile)).Methods("DELETE")
+	router.Handle("/file/{uploadID}/{fileID}/{filename}/transfer", tokenChain.AppendChain(getFileChain).Then(handlers.AbortFile)).Methods("DELETE")
 	router.Handle("/file/{uploadID}/{fileID}/{filename}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
 	router.Handle("/stream/{uploadID}/{fileID}/{filename}", tokenChain.AppendChain(getFileChain).Then(handlers.AddFile)).Methods("POST")
 	router.Handle("/stream/{uploadID}/{fileID}/{filename}", authChainWithRedirect.AppendChain(getFileChain).Then(handlers.GetFile)).Methods("HEAD", "GET")
-	router.Handle("/archive/{uploadID}/{filename}", authChainWithRedirect.Append(middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET")
+	router.Handle("/archive/{uploadID}/{filename}", authChainWithRedirect.Append(middleware.Feature(common.DisableableArchive), middleware.Upload).Then(handlers.GetArchive)).Methods("HEAD", "GET")
 	router.Handle("/auth/google/login", authChain.Then(handlers.GoogleLogin)).Methods("GET")
 	router.Handle("/auth/google/callback", stdChainWithRedirect.Then(handlers.GoogleCallback)).Methods("GET")
 	router.Handle("/auth/ovh/login", authChain.Then(handlers.OvhLogin)).Methods("GET")
@@ -258,17 +300,38 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	router.Handle("/auth/local/login", authChain.Then(handlers.LocalLogin)).Methods("POST")
 	router.Handle("/auth/logout", authChain.Then(handlers.Logout)).Methods("GET")
 	router.Handle("/me", authChain.Then(handlers.UserInfo)).Methods("GET")
-	router.Handle("/me", authChain.Then(handlers.DeleteAccount)).Methods("DELETE")
-	router.Handle("/me/token", pagingChain.Then(handlers.GetUserTokens)).Methods("GET")
-	router.Handle("/me/token", authChain.Then(handlers.CreateToken)).Methods("POST")
-	router.Handle("/me/token/{token}", authChain.Then(handlers.RevokeToken)).Methods("DELETE")
-	router.Handle("/me/uploads", pagingChain.Then(handlers.GetUserUploads)).Methods("GET")
-	router.Handle("/me/uploads", authChain.Then(handlers.RemoveUserUploads)).Methods("DELETE")
-	router.Handle("/me/stats", authChain.Then(handlers.GetUserStatistics)).Methods("GET")
-	router.Handle("/stats", authChain.Then(handlers.GetServerStatistics)).Methods("GET")
+	router.Handle("/me", authChain.Append(middleware.Feature(common.DisableableDeleteAccount)).Then(handlers.DeleteAccount)).Methods("DELETE")
+	router.Handle("/me/token", pagingChain.Append(middleware.Feature(common.DisableableUserTokens)).Then(handlers.GetUserTokens)).Methods("GET")
+	router.Handle("/me/token", authChain.Append(middleware.Feature(common.DisableableUserTokens)).Then(handlers.CreateToken)).Methods("POST")
+	router.Handle("/me/token/{token}", authChain.Append(middleware.Feature(common.DisableableUserTokens)).Then(handlers.RevokeToken)).Methods("DELETE")
+	router.Handle("/me/token/{token}/rotate", tokenChain.Append(middleware.Feature(common.DisableableUserTokens)).Then(handlers.RotateToken)).Methods("POST")
+	router.Handle("/me/sessions", authChain.Then(handlers.GetUserSessions)).Methods("GET")
+	router.Handle("/me/sessions", authChain.Then(handlers.RevokeOtherSessions)).Methods("DELETE")
+	router.Handle("/me/session/{sessionID}", authChain.Then(handlers.RevokeSession)).Methods("DELETE")
+	router.Handle("/me/export", authChain.Append(middleware.Feature(common.DisableableUserExport)).Then(handlers.ExportUserUploads)).Methods("HEAD", "GET")
+	router.Handle("/me/uploadlink", authChain.Append(middleware.Feature(common.DisableableUploadLinks)).Then(handlers.CreateUploadLink)).Methods("POST")
+	router.Handle("/me/uploads", pagingChain.Append(middleware.Feature(common.DisableableUserUploads)).Then(handlers.GetUserUploads)).Methods("GET")
+	router.Handle("/me/uploads", authChain.Append(middleware.Feature(common.DisableableRemoveUpload)).Then(handlers.RemoveUserUploads)).Methods("DELETE")
+	router.Handle("/me/stats", authChain.Append(middleware.Feature(common.DisableableStats)).Then(handlers.GetUserStatistics)).Methods("GET")
+	router.Handle("/stats", authChain.Append(middleware.Feature(common.DisableableStats)).Then(handlers.GetServerStatistics)).Methods("GET")
+	router.Handle("/banner", authChain.Then(handlers.SetServerBanner)).Methods("POST")
+	router.Handle("/banner", authChain.Then(handlers.ResetServerBanner)).Methods("DELETE")
 	router.Handle("/users", pagingChain.Then(handlers.GetUsers)).Methods("GET")
-	router.Handle("/qrcode", stdChain.Then(handlers.GetQrCode)).Methods("GET")
+	router.Handle("/user/{userID}/uploads", authChain.Then(handlers.PurgeUserUploads)).Methods("DELETE")
+	router.Handle("/tos", tokenChain.Then(handlers.AcceptTOS)).Methods("POST")
+	router.Handle("/qrcode", stdChain.Append(middleware.Feature(common.DisableableQrCode)).Then(handlers.GetQrCode)).Methods("GET")
 	router.Handle("/health", emptyChain.Then(handlers.Health)).Methods("GET")
+	router.Handle("/ready", emptyChain.Then(handlers.Ready)).Methods("GET")
+
+	if ps.config.WebDAVEnabled {
+		webdavChain := stdChain.Append(middleware.WebDAVAuthenticate)
+		router.Handle(handlers.WebDAVPrefix, webdavChain.Then(handlers.WebDAV))
+		router.PathPrefix(handlers.WebDAVPrefix + "/").Handler(webdavChain.Then(handlers.WebDAV))
+	}
+
+	if ps.config.RootRedirectURL != "" {
+		router.Handle("/", http.RedirectHandler(ps.config.RootRedirectURL, http.StatusFound)).Methods("HEAD", "GET")
+	}
 
 	if !ps.config.NoWebInterface {
 
@@ -283,6 +346,7 @@ func (ps *PlikServer) getHTTPHandler() (handler http.Handler) {
 	}
 
 	handler = common.StripPrefix(ps.config.Path, router)
+	handler = common.SecurityHeaders(ps.config, handler)
 	return handler
 }
 
@@ -320,17 +384,24 @@ func (ps *PlikServer) WithDataBackend(backend data.Backend) *PlikServer {
 }
 
 // NewDataBackend Initialize data backend from type and data backend configuration
-func NewDataBackend(impl string, params map[string]interface{}) (backend data.Backend, err error) {
+// The S3 and Swift data backends connect through transport if not nil
+func NewDataBackend(impl string, params map[string]interface{}, transport http.RoundTripper) (backend data.Backend, err error) {
 	switch impl {
 	case "file":
 		backend = file.NewBackend(file.NewConfig(params))
 	case "s3":
-		backend, err = s3.NewBackend(s3.NewConfig(params))
+		s3Config := s3.NewConfig(params)
+		s3Config.Transport = transport
+		backend, err = s3.NewBackend(s3Config)
 		if err != nil {
 			return nil, err
 		}
 	case "swift":
-		backend = swift.NewBackend(swift.NewConfig(params))
+		swiftConfig := swift.NewConfig(params)
+		if transport != nil {
+			swiftConfig.Transport = transport
+		}
+		backend = swift.NewBackend(swiftConfig)
 	case "gcs":
 		backend, err = gcs.NewBackend(gcs.NewConfig(params))
 		if err != nil {
@@ -345,10 +416,86 @@ func NewDataBackend(impl string, params map[string]interface{}) (backend data.Ba
 	return backend, nil
 }
 
+// newRetryDataBackend retry the writes to the cloud data backends if configured
+func newRetryDataBackend(config *common.Configuration, impl string, backend data.Backend) data.Backend {
+	switch impl {
+	case "s3", "swift", "gcs":
+	default:
+		return backend
+	}
+
+	if config.GetDataBackendWriteTimeout() <= 0 && config.DataBackendMaxRetries <= 0 {
+		return backend
+	}
+
+	return data.NewRetryBackend(backend, config.GetDataBackendWriteTimeout(), config.DataBackendMaxRetries)
+}
+
+// newReadAheadDataBackend prefetch the files read from the cloud data backends if configured
+func newReadAheadDataBackend(config *common.Configuration, impl string, backend data.Backend) data.Backend {
+	switch impl {
+	case "s3", "swift", "gcs":
+	default:
+		return backend
+	}
+
+	if config.DataBackendReadBufferSize <= 0 {
+		return backend
+	}
+
+	return data.NewReadAheadBackend(backend, config.DataBackendReadBufferSize)
+}
+
+// newResilientDataBackend wrap the data backend with the retry and circuit breaker layers if configured
+func newResilientDataBackend(config *common.Configuration, impl string, backend data.Backend) data.Backend {
+	backend = newRetryDataBackend(config, impl, backend)
+
+	if config.DataBackendCircuitBreakerThreshold <= 0 {
+		return backend
+	}
+
+	return data.NewCircuitBreakerBackend(backend, config.DataBackendCircuitBreakerThreshold, config.GetDataBackendCircuitBreakerCooldown())
+}
+
+// getDataBackendTransport return the transport trusting the TrustedCABundle if set
+// The data backends keep their own default transport otherwise
+func getDataBackendTransport(config *common.Configuration) http.RoundTripper {
+	if config.TrustedCABundle == "" {
+		return nil
+	}
+	return config.GetHTTPTransport()
+}
+
+// NewDataBackendFromConfig Initialize the default data backend and the additional named data backends
+// Files are dispatched to the named data backends by a data.Router if any is configured
+func NewDataBackendFromConfig(config *common.Configuration) (backend data.Backend, err error) {
+	transport := getDataBackendTransport(config)
+	backend, err = NewDataBackend(config.DataBackend, config.DataBackendConfig, transport)
+	if err != nil {
+		return nil, err
+	}
+	backend = newResilientDataBackend(config, config.DataBackend, newReadAheadDataBackend(config, config.DataBackend, backend))
+
+	if len(config.DataBackends) == 0 {
+		return backend, nil
+	}
+
+	router := data.NewRouter(backend)
+	for _, route := range config.DataBackends {
+		namedBackend, err := NewDataBackend(route.Backend, route.Config, transport)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize data backend %s : %s", route.Name, err)
+		}
+		router.Register(route.Name, newResilientDataBackend(config, route.Backend, newReadAheadDataBackend(config, route.Backend, namedBackend)))
+	}
+
+	return router, nil
+}
+
 // Initialize data backend from type found in configuration
 func (ps *PlikServer) initializeDataBackend() (err error) {
 	if ps.dataBackend == nil {
-		ps.dataBackend, err = NewDataBackend(ps.config.DataBackend, ps.config.DataBackendConfig)
+		ps.dataBackend, err = NewDataBackendFromConfig(ps.config)
 		if err != nil {
 			return err
 		}
@@ -449,4 +596,8 @@ func (ps *PlikServer) setupContext(ctx *context.Context) {
 	ctx.SetDataBackend(ps.dataBackend)
 	ctx.SetStreamBackend(ps.streamBackend)
 	ctx.SetAuthenticator(ps.authenticator)
+	ctx.SetDownloadLimiter(ps.downloadLimiter)
+	ctx.SetConnectionLimiter(ps.connectionLimiter)
+	ctx.SetUploadProgressBroker(ps.uploadProgress)
+	ctx.SetTransfers(ps.transfers)
 }
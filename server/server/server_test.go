@@ -2,9 +2,11 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
 	data_test "github.com/root-gg/plik/server/data/testing"
 	"github.com/root-gg/plik/server/metadata"
 )
@@ -167,6 +170,29 @@ func TestDataBackend(t *testing.T) {
 	require.Error(t, err, "able to get removed file")
 }
 
+func TestNewDataBackendFromConfig(t *testing.T) {
+	config := common.NewConfiguration()
+	config.DataBackend = "testing"
+
+	backend, err := NewDataBackendFromConfig(config)
+	require.NoError(t, err, "unable to initialize data backend")
+	require.IsType(t, &data_test.Backend{}, backend, "invalid data backend")
+
+	config.DataBackends = []*common.DataBackendRoute{{Name: "archive", Backend: "testing"}}
+	backend, err = NewDataBackendFromConfig(config)
+	require.NoError(t, err, "unable to initialize data backend")
+	router, ok := backend.(*data.Router)
+	require.True(t, ok, "invalid data backend")
+
+	archived, err := router.GetBackend(&common.File{DataBackend: "archive"})
+	require.NoError(t, err, "unable to get named data backend")
+	require.IsType(t, &data_test.Backend{}, archived, "invalid data backend")
+
+	config.DataBackends = []*common.DataBackendRoute{{Name: "archive", Backend: "foo"}}
+	_, err = NewDataBackendFromConfig(config)
+	common.RequireError(t, err, "unable to initialize data backend archive")
+}
+
 func TestHealth(t *testing.T) {
 	ps := newPlikServer()
 	defer ps.ShutdownNow()
@@ -186,6 +212,50 @@ func TestHealth(t *testing.T) {
 	require.Equal(t, "ok\n", string(body))
 }
 
+func TestRootRedirect(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.config.RootRedirectURL = "https://portal.root.gg"
+
+	handler := ps.getHTTPHandler()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusFound, rr.Code)
+	require.Equal(t, "https://portal.root.gg", rr.Header().Get("Location"))
+
+	req = httptest.NewRequest("GET", "/version", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, "API routes should not be redirected")
+}
+
+func TestDisabledFeatures(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+	ps.config.DisabledFeatures = map[string]bool{common.DisableableVersion: true}
+
+	handler := ps.getHTTPHandler()
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code, "disabled feature should not be served")
+
+	req = httptest.NewRequest("GET", "/config", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	config := &common.Configuration{}
+	err := json.Unmarshal(rr.Body.Bytes(), config)
+	require.NoError(t, err, "unable to unmarshal configuration")
+	require.True(t, config.DisabledFeatures[common.DisableableVersion], "disabled features should be advertised")
+}
+
 func TestClean(t *testing.T) {
 	ps := newPlikServer()
 	defer ps.ShutdownNow()
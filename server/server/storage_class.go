@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+	"github.com/root-gg/plik/server/data"
+)
+
+// TransitionStorageClasses move the files older than the transition age of their data backend to a cheaper storage class
+// The new storage class is saved in the file backend details
+func (ps *PlikServer) TransitionStorageClasses() (transitioned int, err error) {
+	transitioner, ok := ps.dataBackend.(data.StorageClassTransitioner)
+	if !ok {
+		return 0, nil
+	}
+
+	age := transitioner.GetStorageClassTransitionAge()
+	if age <= 0 {
+		return 0, nil
+	}
+
+	log := ps.config.NewLogger()
+
+	var errors []error
+	f := func(file *common.File) error {
+		ok, err := transitioner.TransitionStorageClass(file)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to transition file %s/%s : %s", file.UploadID, file.ID, err)
+			return nil
+		}
+		if !ok {
+			return nil
+		}
+
+		err = ps.metadataBackend.UpdateFileBackendDetails(file)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to save the storage class of file %s/%s : %s", file.UploadID, file.ID, err)
+			return nil
+		}
+
+		transitioned++
+		return nil
+	}
+
+	err = ps.metadataBackend.ForEachUploadedFileCreatedBefore(time.Now().Add(-age), f)
+	if err != nil {
+		return transitioned, err
+	}
+	if len(errors) > 0 {
+		return transitioned, fmt.Errorf("unable to transition %d files", len(errors))
+	}
+
+	return transitioned, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+	data_test "github.com/root-gg/plik/server/data/testing"
+)
+
+func TestTransitionStorageClasses(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	backend := data_test.NewBackend()
+	ps.dataBackend = backend
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	old := upload.NewFile()
+	old.Status = common.FileUploaded
+	recent := upload.NewFile()
+	recent.Status = common.FileUploaded
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	// Transitions are disabled
+	transitioned, err := ps.TransitionStorageClasses()
+	require.NoError(t, err, "unable to transition files")
+	require.Equal(t, 0, transitioned, "invalid transitioned count")
+
+	backend.SetStorageClassTransitionAge(time.Hour)
+
+	old.CreatedAt = time.Now().Add(-2 * time.Hour)
+	err = ps.metadataBackend.UpdateFile(old, common.FileUploaded)
+	require.NoError(t, err, "unable to update file")
+
+	transitioned, err = ps.TransitionStorageClasses()
+	require.NoError(t, err, "unable to transition files")
+	require.Equal(t, 1, transitioned, "invalid transitioned count")
+
+	f, err := ps.metadataBackend.GetFile(old.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, data_test.TransitionedBackendDetails, f.BackendDetails, "the storage class should have been saved")
+
+	f, err = ps.metadataBackend.GetFile(recent.ID)
+	require.NoError(t, err, "unable to get file")
+	require.Equal(t, "", f.BackendDetails, "recent files should not be transitioned")
+
+	// Files are transitioned only once
+	transitioned, err = ps.TransitionStorageClasses()
+	require.NoError(t, err, "unable to transition files")
+	require.Equal(t, 0, transitioned, "invalid transitioned count")
+}
+
+func TestTransitionStorageClassesError(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	backend := data_test.NewBackend()
+	backend.SetStorageClassTransitionAge(time.Hour)
+	ps.dataBackend = backend
+
+	upload := &common.Upload{}
+	upload.InitializeForTests()
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	file.CreatedAt = time.Now().Add(-2 * time.Hour)
+	err = ps.metadataBackend.UpdateFile(file, common.FileUploaded)
+	require.NoError(t, err, "unable to update file")
+
+	backend.SetError(errors.New("data backend error"))
+
+	transitioned, err := ps.TransitionStorageClasses()
+	common.RequireError(t, err, "unable to transition 1 files")
+	require.Equal(t, 0, transitioned, "invalid transitioned count")
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// UploadReadyEvent is the event type of upload ready notifications
+const UploadReadyEvent = "upload.ready"
+
+// ScanVerdictNotScanned is the scan verdict of the files as Plik does not inspect the content of the uploads
+const ScanVerdictNotScanned = "not_scanned"
+
+// Delay between two checks of the uploads whose files have been uploaded
+const uploadReadyNotificationInterval = 10 * time.Second
+
+// UploadReadyNotification is posted as JSON to the UploadReadyWebhook once all the files of an upload
+// have been stored and checksummed
+type UploadReadyNotification struct {
+	Event    string             `json:"event"`
+	UploadID string             `json:"uploadId"`
+	User     string             `json:"user,omitempty"`
+	Email    string             `json:"email,omitempty"`
+	Size     int64              `json:"size"`
+	Scan     string             `json:"scan"`
+	Files    []*UploadReadyFile `json:"files"`
+}
+
+// UploadReadyFile describe a file of an upload ready notification
+type UploadReadyFile struct {
+	ID   string `json:"id"`
+	Name string `json:"fileName"`
+	Type string `json:"fileType"`
+	Size int64  `json:"fileSize"`
+	Md5  string `json:"fileMd5"`
+	Scan string `json:"scan"`
+}
+
+// uploadReadyNotificationsRoutine periodically notify the uploads whose files have been uploaded
+func (ps *PlikServer) uploadReadyNotificationsRoutine() {
+	log := ps.config.NewLogger()
+	for {
+		ps.mu.Lock()
+		done := ps.done
+		ps.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		time.Sleep(uploadReadyNotificationInterval)
+
+		sent, err := ps.SendUploadReadyNotifications()
+		if sent > 0 {
+			log.Infof("sent %d upload ready notifications", sent)
+		}
+		if err != nil {
+			log.Warning(err.Error())
+		}
+	}
+}
+
+// SendUploadReadyNotifications notify the UploadReadyWebhook of the uploads whose files have all been uploaded
+// since the last notification. Uploads with files still being uploaded are checked again on the next run.
+// The flag is cleared before the webhook is called so each upload completion is notified at most once
+func (ps *PlikServer) SendUploadReadyNotifications() (sent int, err error) {
+	if ps.config.UploadReadyWebhook == "" {
+		return 0, nil
+	}
+
+	uploads, err := ps.metadataBackend.GetUploadsWithPendingReadyNotification()
+	if err != nil {
+		return 0, err
+	}
+
+	log := ps.config.NewLogger()
+
+	var errors []error
+	for _, upload := range uploads {
+		if !isUploadReady(upload) {
+			continue
+		}
+
+		ok, err := ps.metadataBackend.ClearUploadReadyNotificationPending(upload.ID)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if !ok {
+			// Already sent by another Plik instance
+			continue
+		}
+
+		err = ps.sendUploadReadyNotification(upload)
+		if err != nil {
+			errors = append(errors, err)
+			log.Warningf("unable to send upload ready notification for upload %s : %s", upload.ID, err)
+			continue
+		}
+
+		sent++
+	}
+
+	if len(errors) > 0 {
+		return sent, fmt.Errorf("unable to send %d upload ready notifications", len(errors))
+	}
+
+	return sent, nil
+}
+
+// isUploadReady return true if no file of the upload is waiting to be uploaded or being uploaded
+func isUploadReady(upload *common.Upload) bool {
+	ready := false
+	for _, file := range upload.Files {
+		switch file.Status {
+		case common.FileMissing, common.FileUploading:
+			return false
+		case common.FileUploaded:
+			ready = true
+		}
+	}
+	return ready
+}
+
+func (ps *PlikServer) sendUploadReadyNotification(upload *common.Upload) (err error) {
+	notification := &UploadReadyNotification{
+		Event:    UploadReadyEvent,
+		UploadID: upload.ID,
+		User:     upload.User,
+		Scan:     ScanVerdictNotScanned,
+	}
+
+	for _, file := range upload.Files {
+		if file.Status != common.FileUploaded {
+			continue
+		}
+
+		notification.Size += file.Size
+		notification.Files = append(notification.Files, &UploadReadyFile{
+			ID:   file.ID,
+			Name: file.Name,
+			Type: file.Type,
+			Size: file.Size,
+			Md5:  file.Md5,
+			Scan: ScanVerdictNotScanned,
+		})
+	}
+
+	if upload.User != "" {
+		user, err := ps.metadataBackend.GetUser(upload.User)
+		if err != nil {
+			return fmt.Errorf("unable to get upload user : %s", err)
+		}
+		if user != nil {
+			notification.Email = user.Email
+		}
+	}
+
+	return ps.config.PostWebhookEvent(ps.config.UploadReadyWebhook, notification)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func TestSendUploadReadyNotifications(t *testing.T) {
+	var notifications []*UploadReadyNotification
+	webhook := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		notification := &UploadReadyNotification{}
+		err := json.NewDecoder(req.Body).Decode(notification)
+		require.NoError(t, err, "unable to decode upload ready notification")
+		notifications = append(notifications, notification)
+	}))
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.UploadReadyWebhook = webhook.URL
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	user := common.NewUser(common.ProviderLocal, "user")
+	user.Email = "user@root.gg"
+	err = ps.metadataBackend.CreateUser(user)
+	require.NoError(t, err, "unable to create user")
+
+	upload := &common.Upload{User: user.ID}
+	file1 := upload.NewFile()
+	file1.Name = "file1"
+	file1.Size = 12
+	file1.Md5 = "md5sum1"
+	file1.Status = common.FileUploaded
+	file2 := upload.NewFile()
+	file2.Name = "file2"
+	file2.Status = common.FileUploading
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	err = ps.metadataBackend.SetUploadReadyNotificationPending(upload.ID)
+	require.NoError(t, err, "unable to flag upload")
+
+	// The upload is not ready until all its files have been uploaded
+	sent, err := ps.SendUploadReadyNotifications()
+	require.NoError(t, err, "unable to send upload ready notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+
+	file2.Size = 30
+	file2.Md5 = "md5sum2"
+	file2.Status = common.FileUploaded
+	err = ps.metadataBackend.UpdateFile(file2, common.FileUploading)
+	require.NoError(t, err, "unable to update file")
+
+	sent, err = ps.SendUploadReadyNotifications()
+	require.NoError(t, err, "unable to send upload ready notifications")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, notifications, 1, "invalid notification count")
+	require.Equal(t, UploadReadyEvent, notifications[0].Event, "invalid event")
+	require.Equal(t, upload.ID, notifications[0].UploadID, "invalid upload id")
+	require.Equal(t, user.ID, notifications[0].User, "invalid user")
+	require.Equal(t, user.Email, notifications[0].Email, "invalid email")
+	require.Equal(t, int64(42), notifications[0].Size, "invalid size")
+	require.Equal(t, ScanVerdictNotScanned, notifications[0].Scan, "invalid scan verdict")
+	require.Len(t, notifications[0].Files, 2, "invalid file count")
+
+	files := make(map[string]*UploadReadyFile)
+	for _, file := range notifications[0].Files {
+		files[file.ID] = file
+	}
+	require.Equal(t, "file2", files[file2.ID].Name, "invalid file name")
+	require.Equal(t, int64(30), files[file2.ID].Size, "invalid file size")
+	require.Equal(t, "md5sum2", files[file2.ID].Md5, "invalid file md5")
+
+	// Upload completions are notified only once
+	sent, err = ps.SendUploadReadyNotifications()
+	require.NoError(t, err, "unable to send upload ready notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Len(t, notifications, 1, "invalid notification count")
+}
+
+func TestSendUploadReadyNotificationsDisabled(t *testing.T) {
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	upload := &common.Upload{}
+	file := upload.NewFile()
+	file.Status = common.FileUploaded
+	upload.InitializeForTests()
+	err := ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	err = ps.metadataBackend.SetUploadReadyNotificationPending(upload.ID)
+	require.NoError(t, err, "unable to flag upload")
+
+	sent, err := ps.SendUploadReadyNotifications()
+	require.NoError(t, err, "unable to send upload ready notifications")
+	require.Equal(t, 0, sent, "invalid sent count")
+}
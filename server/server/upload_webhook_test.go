@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+func newUploadWebhookServer(t *testing.T, events *[]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		event := make(map[string]interface{})
+		err := json.NewDecoder(req.Body).Decode(&event)
+		require.NoError(t, err, "unable to decode event")
+		*events = append(*events, event)
+	}))
+}
+
+func TestUploadWebhookDownloadNotifications(t *testing.T) {
+	var events []map[string]interface{}
+	webhook := newUploadWebhookServer(t, &events)
+	defer webhook.Close()
+
+	var globalEvents []map[string]interface{}
+	globalWebhook := newUploadWebhookServer(t, &globalEvents)
+	defer globalWebhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.DownloadNotificationWindow = "0"
+	ps.config.UploadWebhookAllowlist = []string{webhook.URL + "/plik"}
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{Webhook: webhook.URL + "/plik/42"}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	err = ps.metadataBackend.AddUploadPendingDownload(upload.ID)
+	require.NoError(t, err, "unable to add pending download")
+
+	// Without a global webhook only the upload webhook is notified
+	sent, err := ps.SendDownloadNotifications()
+	require.NoError(t, err, "unable to send download notifications")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, events, 1, "invalid event count")
+	require.Equal(t, DownloadNotificationEvent, events[0]["event"], "invalid event")
+	require.Equal(t, upload.ID, events[0]["uploadId"], "invalid upload id")
+
+	// With a global webhook both are notified
+	ps.config.DownloadNotificationWebhook = globalWebhook.URL
+	err = ps.metadataBackend.AddUploadPendingDownload(upload.ID)
+	require.NoError(t, err, "unable to add pending download")
+
+	sent, err = ps.SendDownloadNotifications()
+	require.NoError(t, err, "unable to send download notifications")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, events, 2, "invalid event count")
+	require.Len(t, globalEvents, 1, "invalid global event count")
+}
+
+func TestUploadWebhookExpiryWarnings(t *testing.T) {
+	var events []map[string]interface{}
+	webhook := newUploadWebhookServer(t, &events)
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.ExpiryWarningLeadTime = "24h"
+	ps.config.UploadWebhookAllowlist = []string{webhook.URL}
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{TTL: 3600, Webhook: webhook.URL + "/expiring"}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	other := &common.Upload{TTL: 3600}
+	other.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(other)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, err := ps.SendExpiryWarnings()
+	require.NoError(t, err, "unable to send expiry warnings")
+	require.Equal(t, 1, sent, "invalid sent count")
+	require.Len(t, events, 1, "invalid event count")
+	require.Equal(t, ExpiryWarningEvent, events[0]["event"], "invalid event")
+	require.Equal(t, upload.ID, events[0]["uploadId"], "invalid upload id")
+
+	// Uploads without webhook are not flagged so they are still warned if a global webhook is set later
+	u, err := ps.metadataBackend.GetUpload(other.ID)
+	require.NoError(t, err, "unable to get upload")
+	require.False(t, u.ExpiryWarningSent, "upload without webhook must not be flagged")
+}
+
+func TestUploadWebhookNotAllowedAnymore(t *testing.T) {
+	var events []map[string]interface{}
+	webhook := newUploadWebhookServer(t, &events)
+	defer webhook.Close()
+
+	ps := newPlikServer()
+	defer ps.ShutdownNow()
+
+	ps.config.ExpiryWarningLeadTime = "24h"
+	ps.config.UploadWebhookAllowlist = []string{"https://hooks.root.gg"}
+	err := ps.config.Initialize()
+	require.NoError(t, err, "unable to initialize config")
+
+	upload := &common.Upload{TTL: 3600, Webhook: webhook.URL}
+	upload.InitializeForTests()
+	err = ps.metadataBackend.CreateUpload(upload)
+	require.NoError(t, err, "unable to create upload")
+
+	sent, err := ps.SendExpiryWarnings()
+	common.RequireError(t, err, "unable to send 1 expiry warnings")
+	require.Equal(t, 0, sent, "invalid sent count")
+	require.Len(t, events, 0, "webhook must not be called")
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/root-gg/plik/server/common"
+)
+
+// postUploadWebhookEvent post the event of an upload to the server webhook if set and to the upload webhook if set
+// The upload webhook is checked again as the UploadWebhookAllowlist might have changed since the upload creation
+func (ps *PlikServer) postUploadWebhookEvent(URL string, upload *common.Upload, event interface{}) (err error) {
+	if URL != "" {
+		err = ps.config.PostWebhookEvent(URL, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	if upload.Webhook != "" {
+		err = ps.config.CheckUploadWebhook(upload.Webhook)
+		if err != nil {
+			return fmt.Errorf("invalid upload webhook : %s", err)
+		}
+		err = ps.config.PostWebhookEvent(upload.Webhook, event)
+		if err != nil {
+			return fmt.Errorf("upload webhook : %s", err)
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,11 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
             $scope.refreshUser();
         };
 
+        $scope.displaySessions = function () {
+            $scope.display = 'sessions';
+            $scope.refreshUser();
+        };
+
         // Get server config
         $config.config
             .then(function (config) {
@@ -33,6 +38,7 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 $scope.user = user;
                 $scope.getUploads();
                 $scope.getTokens();
+                $scope.getSessions();
                 $scope.getUserStats();
             })
                 .then(null, function (error) {
@@ -88,6 +94,17 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 });
         };
 
+        // Get user web sessions
+        $scope.getSessions = function () {
+            $api.getUserSessions()
+                .then(function (sessions) {
+                    $scope.sessions = sessions;
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
         // Get user statistics
         $scope.getUserStats = function () {
             $api.getUserStats()
@@ -174,6 +191,42 @@ plik.controller('HomeCtrl', ['$scope', '$api', '$config', '$dialog', '$location'
                 });
         };
 
+        // Revoke a web session
+        $scope.revokeSession = function (session) {
+            $api.revokeSession(session.id)
+                .then(function () {
+                    if (session.current) {
+                        $config.refreshUser();
+                        $location.path('/');
+                    } else {
+                        $scope.getSessions();
+                    }
+                })
+                .then(null, function (error) {
+                    $dialog.alert(error);
+                });
+        };
+
+        // Revoke all web sessions but the current one
+        $scope.revokeOtherSessions = function () {
+            $dialog.alert({
+                title: "Really ?",
+                message: "You will be logged out from all your other browsers.",
+                confirm: true
+            }).result.then(
+                function () {
+                    $api.revokeOtherSessions()
+                        .then(function () {
+                            $scope.getSessions();
+                        })
+                        .then(null, function (error) {
+                            $dialog.alert(error);
+                        });
+                }, function () {
+                    // Avoid "Possibly unhandled rejection"
+                });
+        };
+
         // Log out
         $scope.logout = function () {
             $api.logout()
@@ -50,6 +50,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 $scope.upload.stream = $scope.isFeatureDefault("stream")
                 $scope.password = $scope.isFeatureDefault("password")
                 $scope.enableComments = $scope.isFeatureDefault("comments")
+                $scope.upload.downloadDomain = config.downloadDomain
 
                 $scope.configReady.resolve(true);
             })
@@ -93,7 +94,22 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             $scope.mode = 'download';
             $scope.upload.id = id;
             $scope.upload.uploadToken = $location.search().uploadToken;
-            $api.getUpload($scope.upload.id, $scope.upload.uploadToken)
+
+            // Upload login and password shared in the URL fragment ( #/?id=...&pw=... )
+            // Browsers never send the fragment to the server, remove it from the URL so that
+            // it is not leaked by the QR code requests.
+            var authenticated = $q.when();
+            var credentials = decodePasswordFragment($location.search().pw);
+            if (credentials) {
+                $location.search('pw', null).replace();
+                $scope.basicAuth = btoa(credentials.login + ":" + credentials.password);
+                authenticated = $api.authenticateUpload($scope.upload.id, $scope.upload.uploadToken, credentials.login, credentials.password);
+            }
+
+            authenticated
+                .then(function () {
+                    return $api.getUpload($scope.upload.id, $scope.upload.uploadToken);
+                })
                 .then(function (upload) {
                     _.extend($scope.upload, upload);
                     $scope.files = $scope.upload.files;
@@ -125,8 +141,48 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 $location.path('/login');
             }
             $scope.setDefaultTTL();
+            $scope.setupCaptcha();
         });
 
+        // Anonymous uploads have to solve a CAPTCHA if the server is configured to
+        var captchaScripts = {
+            hcaptcha: "https://js.hcaptcha.com/1/api.js",
+            recaptcha: "https://www.google.com/recaptcha/api.js"
+        };
+        var captchaWidget = null;
+
+        $scope.isCaptchaRequired = function () {
+            return $scope.config && $scope.config.captchaProvider && !$scope.user && $scope.mode !== 'download';
+        };
+
+        $scope.getCaptchaAPI = function () {
+            return $scope.config.captchaProvider === "hcaptcha" ? window.hcaptcha : window.grecaptcha;
+        };
+
+        $scope.setupCaptcha = function () {
+            if (!$scope.isCaptchaRequired()) return;
+
+            window.plikCaptchaLoaded = function () {
+                captchaWidget = $scope.getCaptchaAPI().render("captcha", {sitekey: $scope.config.captchaSiteKey});
+            };
+
+            var script = document.createElement("script");
+            script.src = captchaScripts[$scope.config.captchaProvider] + "?onload=plikCaptchaLoaded&render=explicit";
+            script.async = true;
+            document.head.appendChild(script);
+        };
+
+        $scope.getCaptchaResponse = function () {
+            if (!$scope.isCaptchaRequired() || captchaWidget === null) return null;
+            return $scope.getCaptchaAPI().getResponse(captchaWidget);
+        };
+
+        // A CAPTCHA response can only be verified once
+        $scope.resetCaptcha = function () {
+            if (!$scope.isCaptchaRequired() || captchaWidget === null) return;
+            $scope.getCaptchaAPI().reset(captchaWidget);
+        };
+
         //
         $scope.fileNameValidator = function (fileName) {
             if (_.isUndefined(fileName)) return false;
@@ -293,7 +349,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 });
                 if (ko) return;
 
-                $api.createUpload($scope.upload)
+                $api.createUpload($scope.upload, $scope.getCaptchaResponse())
                     .then(function (upload) {
                         $scope.upload = upload;
                         // Match file using the reference
@@ -312,6 +368,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                         $scope.uploadFiles();
                     })
                     .then(null, function (error) {
+                        $scope.resetCaptcha();
                         $dialog.alert(error);
                     });
             }
@@ -424,6 +481,15 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             });
         };
 
+        // Is the upload bigger than the maximum archive size of the server
+        $scope.isArchiveTooBig = function () {
+            if (!$scope.config || !$scope.config.maxArchiveSize) return false;
+            var size = _.reduce($scope.files, function (size, file) {
+                return file.status === 'uploaded' ? size + file.fileSize : size;
+            }, 0);
+            return size > $scope.config.maxArchiveSize;
+        };
+
         // Is there at least one file not in error
         $scope.somethingOk = function () {
             return _.find($scope.files, function (file) {
@@ -443,7 +509,7 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
 
         // Build file download URL
         var getFileUrl = function (mode, uploadID, fileID, fileName, dl) {
-            var domain = $scope.config.downloadDomain ? $scope.config.downloadDomain : $api.base;
+            var domain = $scope.upload.downloadDomain || $scope.config.downloadDomain || $api.base;
             var url = domain + '/' + mode + '/' + uploadID;
             if (fileID) {
                 url += '/' + fileID;
@@ -602,6 +668,9 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
 
             // Check against server side allowed maximum
             maxTTL = $scope.config.maxTTL;
+            if ($scope.user && $scope.config.authenticatedMaxTTL) {
+                maxTTL = $scope.config.authenticatedMaxTTL;
+            }
             if ($scope.user && $scope.user.maxTTL !== 0) {
                 maxTTL = $scope.user.maxTTL;
             }
@@ -617,12 +686,27 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
                 $scope.setDefaultTTL();
             }
 
+            // Check against server side allowed minimum ( never expiring uploads are not concerned )
+            if (ok && $scope.config.minTTL > 0 && ttl > 0 && ttl < $scope.config.minTTL) {
+                var minTTL = $scope.getHumanReadableTTL($scope.config.minTTL);
+                $dialog.alert({
+                    status: 0,
+                    message: "Invalid expiration delay : " + $scope.ttlValue + " " + $scope.ttlUnit + ". " +
+                        "Minimum is : " + minTTL[0] + " " + minTTL[1],
+                });
+                $scope.setDefaultTTL();
+                ok = false;
+            }
+
             return ok;
         };
 
         // Set TTL value to server defaultTTL
         $scope.setDefaultTTL = function () {
             maxTTL = $scope.config.maxTTL;
+            if ($scope.user && $scope.config.authenticatedMaxTTL) {
+                maxTTL = $scope.config.authenticatedMaxTTL;
+            }
             if ($scope.user && $scope.user.maxTTL !== 0) {
                 maxTTL = $scope.user.maxTTL;
             }
@@ -650,6 +734,26 @@ plik.controller('MainCtrl', ['$scope', '$api', '$config', '$route', '$location',
             return $scope.upload.uploadToken && !$location.search().uploadToken;
         }
 
+        // Decode the "login:password" base64url encoded in the pw URL fragment parameter
+        function decodePasswordFragment(pw) {
+            if (!pw) return;
+            try {
+                var credentials = atob(pw.replace(/-/g, '+').replace(/_/g, '/'));
+                var index = credentials.indexOf(':');
+                if (index < 0) return;
+                return {login: credentials.substring(0, index), password: credentials.substring(index + 1)};
+            } catch (e) {
+                return;
+            }
+        }
+
+        // Return the upload URL with the login and password in the URL fragment
+        $scope.getPasswordUrl = function () {
+            if (!$scope.upload.id || !$scope.basicAuth) return;
+            var pw = $scope.basicAuth.replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+            return $api.base + '/#/?id=' + $scope.upload.id + '&pw=' + pw;
+        };
+
         // Add upload token in url so one can add/remove files later
         $scope.setAdminUrl = function () {
             $location.search('uploadToken', $scope.upload.uploadToken);
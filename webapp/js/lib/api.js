@@ -3,10 +3,11 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
     var api = {base: window.location.origin + window.location.pathname.replace(/\/$/, '')};
 
     // Make the actual HTTP call and return a promise
-    api.call = function (url, method, params, data, uploadToken) {
+    api.call = function (url, method, params, data, uploadToken, captchaResponse) {
         var promise = $q.defer();
         var headers = {};
         if (uploadToken) headers['X-UploadToken'] = uploadToken;
+        if (captchaResponse) headers['X-Captcha-Response'] = captchaResponse;
         if (api.fake_user) headers['X-Plik-Impersonate'] = api.fake_user.id;
         $http({
             url: url,
@@ -56,10 +57,32 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'GET', {}, {}, uploadToken);
     };
 
+    // Authenticate to a password protected upload using the browser HTTP authentication
+    // so that the credentials are also used by the download links of the upload files
+    api.authenticateUpload = function (uploadId, uploadToken, login, password) {
+        var promise = $q.defer();
+        var xhr = new XMLHttpRequest();
+        xhr.open('GET', api.base + '/upload/' + uploadId, true, login, password);
+        if (uploadToken) xhr.setRequestHeader('X-UploadToken', uploadToken);
+        xhr.onload = function () {
+            if (xhr.status >= 200 && xhr.status < 300) {
+                promise.resolve();
+            } else {
+                // Format HTTP error return for the dialog service
+                promise.reject({status: xhr.status, message: xhr.responseText || "Unknown error"});
+            }
+        };
+        xhr.onerror = function () {
+            promise.reject({status: 0, message: "Unknown error"});
+        };
+        xhr.send();
+        return promise.promise;
+    };
+
     // Create an upload with current settings
-    api.createUpload = function (upload) {
+    api.createUpload = function (upload, captchaResponse) {
         var url = api.base + '/upload';
-        return api.call(url, 'POST', {}, upload);
+        return api.call(url, 'POST', {}, upload, null, captchaResponse);
     };
 
     // Remove an upload
@@ -152,6 +175,24 @@ angular.module('api', ['ngFileUpload']).factory('$api', function ($http, $q, Upl
         return api.call(url, 'DELETE');
     };
 
+    // Get user web sessions
+    api.getUserSessions = function () {
+        var url = api.base + '/me/sessions';
+        return api.call(url, 'GET');
+    };
+
+    // Revoke a web session
+    api.revokeSession = function (sessionId) {
+        var url = api.base + '/me/session/' + sessionId;
+        return api.call(url, 'DELETE');
+    };
+
+    // Revoke all web sessions but the current one
+    api.revokeOtherSessions = function () {
+        var url = api.base + '/me/sessions';
+        return api.call(url, 'DELETE');
+    };
+
     // Get server version
     api.getVersion = function () {
         var url = api.base + '/version';